@@ -0,0 +1,205 @@
+package adapters
+
+import (
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/co-defi/api-server/app/commands"
+	"github.com/co-defi/api-server/domain"
+	"github.com/cosmos/btcutil/bech32"
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+	"golang.org/x/crypto/ripemd160"
+)
+
+var (
+	_ commands.WalletDeriver = (*ThorchainWalletDeriver)(nil)
+	_ commands.WalletDeriver = (*EthereumWalletDeriver)(nil)
+	_ commands.WalletDeriver = (*BitcoinWalletDeriver)(nil)
+
+	_ commands.WalletKeyDeriver = (*ThorchainWalletDeriver)(nil)
+	_ commands.WalletKeyDeriver = (*EthereumWalletDeriver)(nil)
+	_ commands.WalletKeyDeriver = (*BitcoinWalletDeriver)(nil)
+)
+
+// Derivation paths of the wallet addresses on each chain, the same paths the TSS clients derive the addresses with
+const (
+	thorchainDerivationPath = "m/44'/931'/0'/0/0"
+	ethereumDerivationPath  = "m/44'/60'/0'/0/0"
+	bitcoinDerivationPath   = "m/84'/0'/0'/0/0"
+)
+
+var errInvalidChildKey = errors.New("derived child key is invalid")
+
+// deriveCompressedPublicKey derives the public key at path as derivePublicKey does, in its compressed form
+func deriveCompressedPublicKey(hexPubKey, hexChainCode, path string) ([]byte, error) {
+	pubKey, err := derivePublicKey(hexPubKey, hexChainCode, path)
+	if err != nil {
+		return nil, err
+	}
+
+	return ethcrypto.CompressPubkey(pubKey), nil
+}
+
+// derivePublicKey derives the public key at path from the hex encoded compressed secp256k1 public key and chain code
+// with BIP32 public child key derivation. The private key of a TSS wallet is never assembled, so its hardened children
+// can't be derived, the TSS clients derive the hardened indices of the paths as normal ones and so does it.
+func derivePublicKey(hexPubKey, hexChainCode, path string) (*ecdsa.PublicKey, error) {
+	pubKeyBytes, err := hex.DecodeString(strings.TrimPrefix(hexPubKey, "0x"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key: %w", err)
+	}
+	pubKey, err := ethcrypto.DecompressPubkey(pubKeyBytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key: %w", err)
+	}
+	chainCode, err := hex.DecodeString(hexChainCode)
+	if err != nil || len(chainCode) != 32 {
+		return nil, fmt.Errorf("invalid chain code")
+	}
+
+	segments := strings.Split(path, "/")
+	if len(segments) < 2 || segments[0] != "m" {
+		return nil, fmt.Errorf("invalid derivation path %q", path)
+	}
+
+	curve := ethcrypto.S256()
+	for _, segment := range segments[1:] {
+		index, err := strconv.ParseUint(strings.TrimSuffix(segment, "'"), 10, 31)
+		if err != nil {
+			return nil, fmt.Errorf("invalid derivation path %q: %w", path, err)
+		}
+
+		data := make([]byte, 0, 37)
+		data = append(data, ethcrypto.CompressPubkey(pubKey)...)
+		data = binary.BigEndian.AppendUint32(data, uint32(index))
+
+		mac := hmac.New(sha512.New, chainCode)
+		mac.Write(data)
+		sum := mac.Sum(nil)
+
+		il := new(big.Int).SetBytes(sum[:32])
+		if il.Cmp(curve.Params().N) >= 0 {
+			return nil, errInvalidChildKey
+		}
+
+		x, y := curve.ScalarBaseMult(sum[:32])
+		x, y = curve.Add(x, y, pubKey.X, pubKey.Y)
+		if x.Sign() == 0 && y.Sign() == 0 {
+			return nil, errInvalidChildKey
+		}
+
+		pubKey = &ecdsa.PublicKey{Curve: curve, X: x, Y: y}
+		chainCode = sum[32:]
+	}
+
+	return pubKey, nil
+}
+
+// hash160 hashes the compressed public key as RIPEMD160(SHA256(public_key)) the way the Bitcoin derived chains do
+func hash160(pubKey *ecdsa.PublicKey) []byte {
+	sha := sha256.Sum256(ethcrypto.CompressPubkey(pubKey))
+	ripemd := ripemd160.New()
+	ripemd.Write(sha[:])
+	return ripemd.Sum(nil)
+}
+
+// ThorchainWalletDeriver derives the THORChain addresses of the pairs' wallets
+type ThorchainWalletDeriver struct {
+	prefix string
+}
+
+// NewThorchainWalletDeriver creates a new ThorchainWalletDeriver deriving addresses with the bech32 prefix of the network
+func NewThorchainWalletDeriver(prefix string) *ThorchainWalletDeriver {
+	return &ThorchainWalletDeriver{prefix: prefix}
+}
+
+// DeriveAddress implements commands.WalletDeriver
+func (d *ThorchainWalletDeriver) DeriveAddress(publicKey, hexChainCode string) (domain.Address, error) {
+	pubKey, err := derivePublicKey(publicKey, hexChainCode, thorchainDerivationPath)
+	if err != nil {
+		return "", err
+	}
+
+	converted, err := bech32.ConvertBits(hash160(pubKey), 8, 5, true)
+	if err != nil {
+		return "", err
+	}
+	address, err := bech32.Encode(d.prefix, converted)
+	if err != nil {
+		return "", err
+	}
+
+	return domain.Address(address), nil
+}
+
+// DerivePublicKey implements commands.WalletKeyDeriver
+func (d *ThorchainWalletDeriver) DerivePublicKey(publicKey, hexChainCode string) ([]byte, error) {
+	return deriveCompressedPublicKey(publicKey, hexChainCode, thorchainDerivationPath)
+}
+
+// EthereumWalletDeriver derives the Ethereum addresses of the pairs' wallets, the tokens share the address of the chain
+type EthereumWalletDeriver struct{}
+
+// NewEthereumWalletDeriver creates a new EthereumWalletDeriver
+func NewEthereumWalletDeriver() *EthereumWalletDeriver {
+	return &EthereumWalletDeriver{}
+}
+
+// DeriveAddress implements commands.WalletDeriver
+func (d *EthereumWalletDeriver) DeriveAddress(publicKey, hexChainCode string) (domain.Address, error) {
+	pubKey, err := derivePublicKey(publicKey, hexChainCode, ethereumDerivationPath)
+	if err != nil {
+		return "", err
+	}
+
+	return domain.Address(ethcrypto.PubkeyToAddress(*pubKey).Hex()), nil
+}
+
+// DerivePublicKey implements commands.WalletKeyDeriver
+func (d *EthereumWalletDeriver) DerivePublicKey(publicKey, hexChainCode string) ([]byte, error) {
+	return deriveCompressedPublicKey(publicKey, hexChainCode, ethereumDerivationPath)
+}
+
+// BitcoinWalletDeriver derives the native segwit (P2WPKH) Bitcoin addresses of the pairs' wallets
+type BitcoinWalletDeriver struct {
+	hrp string
+}
+
+// NewBitcoinWalletDeriver creates a new BitcoinWalletDeriver deriving addresses with the bech32 human readable part of the network
+func NewBitcoinWalletDeriver(hrp string) *BitcoinWalletDeriver {
+	return &BitcoinWalletDeriver{hrp: hrp}
+}
+
+// DeriveAddress implements commands.WalletDeriver
+func (d *BitcoinWalletDeriver) DeriveAddress(publicKey, hexChainCode string) (domain.Address, error) {
+	pubKey, err := derivePublicKey(publicKey, hexChainCode, bitcoinDerivationPath)
+	if err != nil {
+		return "", err
+	}
+
+	converted, err := bech32.ConvertBits(hash160(pubKey), 8, 5, true)
+	if err != nil {
+		return "", err
+	}
+	// The witness program is prefixed by its version, 0 for P2WPKH
+	address, err := bech32.Encode(d.hrp, append([]byte{0}, converted...))
+	if err != nil {
+		return "", err
+	}
+
+	return domain.Address(address), nil
+}
+
+// DerivePublicKey implements commands.WalletKeyDeriver
+func (d *BitcoinWalletDeriver) DerivePublicKey(publicKey, hexChainCode string) ([]byte, error) {
+	return deriveCompressedPublicKey(publicKey, hexChainCode, bitcoinDerivationPath)
+}
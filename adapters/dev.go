@@ -0,0 +1,110 @@
+package adapters
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"time"
+
+	"github.com/co-defi/api-server/app/commands"
+	"github.com/co-defi/api-server/app/queries"
+	"github.com/co-defi/api-server/domain"
+)
+
+var (
+	_ commands.LPVerifier      = (*DevChain)(nil)
+	_ commands.SaversVerifier  = (*DevChain)(nil)
+	_ commands.DepositVerifier = (*DevChain)(nil)
+	_ commands.TxBroadcaster   = (*DevChain)(nil)
+	_ commands.FeeEstimator    = (*DevChain)(nil)
+	_ commands.TxStatusChecker = (*DevChain)(nil)
+	_ queries.PriceOracle      = (*DevPrices)(nil)
+	_ queries.PositionSource   = (*DevPrices)(nil)
+)
+
+// devConfirmations is the number of blocks every transaction of the development chains is reported to be mined under,
+// more than any chain requires so the transactions are final at once
+const devConfirmations = 100
+
+// DevChain stands in for a chain in the development mode, it accepts every transaction the participants submit as they are
+// declared, broadcasts nothing and reports every transaction as final. It must never be wired to a production server.
+type DevChain struct {
+	name  string
+	asset domain.Asset
+	fee   string
+}
+
+// NewDevChain creates a new DevChain for the chain, charging the flat fee in base units of its native asset
+func NewDevChain(name string, nativeAsset domain.Asset, fee string) *DevChain {
+	return &DevChain{name: name, asset: nativeAsset, fee: fee}
+}
+
+// VerifyLP implements commands.LPVerifier by accepting every transaction
+func (c *DevChain) VerifyLP(ctx context.Context, tx commands.LPTx) error {
+	return nil
+}
+
+// VerifySavers implements commands.SaversVerifier by accepting every transaction
+func (c *DevChain) VerifySavers(ctx context.Context, tx commands.SaversTx) error {
+	return nil
+}
+
+// VerifyDeposit implements commands.DepositVerifier by accepting every transaction
+func (c *DevChain) VerifyDeposit(ctx context.Context, tx commands.DepositTx) error {
+	return nil
+}
+
+// Broadcast implements commands.TxBroadcaster without sending the transaction anywhere, its hash is made up from its payload
+func (c *DevChain) Broadcast(ctx context.Context, tx domain.SignedTx) (domain.TxHash, error) {
+	sum := sha256.Sum256(append([]byte(c.name), tx.Tx...))
+	return fmt.Sprintf("%X", sum), nil
+}
+
+// EstimateFee implements commands.FeeEstimator with the flat fee of the chain
+func (c *DevChain) EstimateFee(ctx context.Context) (commands.FeeEstimate, error) {
+	return commands.FeeEstimate{Chain: c.name, Asset: c.asset, Fee: c.fee, EstimatedAt: time.Now()}, nil
+}
+
+// CheckTx implements commands.TxStatusChecker by reporting every transaction as final
+func (c *DevChain) CheckTx(ctx context.Context, hash domain.TxHash) (commands.TxConfirmation, error) {
+	return commands.TxConfirmation{Mined: true, Confirmations: devConfirmations}, nil
+}
+
+// DevPrices stands in for the price oracle and the position source in the development mode with fixed prices.
+// The positions are valued as if the pools didn't move since the liquidity was added.
+type DevPrices struct {
+	prices map[domain.Asset]float64
+}
+
+// NewDevPrices creates a new DevPrices serving the prices in USD by asset
+func NewDevPrices(prices map[domain.Asset]float64) *DevPrices {
+	return &DevPrices{prices: prices}
+}
+
+// PriceUSD implements queries.PriceOracle
+func (p *DevPrices) PriceUSD(ctx context.Context, asset domain.Asset) (float64, error) {
+	price, ok := p.prices[asset]
+	if !ok {
+		return 0, fmt.Errorf("no price for %s", asset)
+	}
+	return price, nil
+}
+
+// Position implements queries.PositionSource with a pool of a single position, the one of the address, holding
+// a unit of the asset and the RUNE of the same value
+func (p *DevPrices) Position(ctx context.Context, pool domain.Asset, address domain.Address) (queries.LPPosition, error) {
+	assetPrice, runePrice := p.prices[pool], p.prices[domain.RuneAsset]
+	if assetPrice == 0 || runePrice == 0 {
+		return queries.LPPosition{}, nil
+	}
+
+	rune := assetPrice / runePrice
+	return queries.LPPosition{
+		Units:      1,
+		PoolUnits:  1,
+		RuneDepth:  rune,
+		AssetDepth: 1,
+		RuneAdded:  rune,
+		AssetAdded: 1,
+	}, nil
+}
@@ -0,0 +1,59 @@
+package adapters
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+
+	"github.com/co-defi/api-server/app/notifications"
+	"github.com/co-defi/api-server/app/queries"
+)
+
+var _ notifications.Channel = (*EmailChannel)(nil)
+
+// EmailChannel sends notifications by email through an SMTP server
+type EmailChannel struct {
+	addr string
+	from string
+	auth smtp.Auth
+}
+
+// NewEmailChannel creates a new EmailChannel, authentication is skipped when the username is empty
+func NewEmailChannel(addr, from, username, password string) *EmailChannel {
+	var auth smtp.Auth
+	if username != "" {
+		host, _, _ := net.SplitHostPort(addr)
+		auth = smtp.PlainAuth("", username, password, host)
+	}
+
+	return &EmailChannel{addr: addr, from: from, auth: auth}
+}
+
+// Name implements notifications.Channel
+func (c *EmailChannel) Name() string {
+	return "email"
+}
+
+// Send implements notifications.Channel
+func (c *EmailChannel) Send(ctx context.Context, recipient queries.NotificationSettings, n notifications.Notification) error {
+	if recipient.Email == "" {
+		return nil
+	}
+
+	msg := strings.Join([]string{
+		fmt.Sprintf("From: %s", c.from),
+		fmt.Sprintf("To: %s", recipient.Email),
+		fmt.Sprintf("Subject: %s", n.Title),
+		"Content-Type: text/plain; charset=UTF-8",
+		"",
+		n.Body,
+	}, "\r\n")
+
+	if err := smtp.SendMail(c.addr, c.auth, c.from, []string{recipient.Email}, []byte(msg)); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+
+	return nil
+}
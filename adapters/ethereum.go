@@ -0,0 +1,614 @@
+package adapters
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/co-defi/api-server/app/commands"
+	"github.com/co-defi/api-server/common/httpclient"
+	"github.com/co-defi/api-server/domain"
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+var (
+	_ commands.LPVerifier      = (*EthereumClient)(nil)
+	_ commands.DepositVerifier = (*EthereumClient)(nil)
+	_ commands.FeeEstimator    = (*EthereumClient)(nil)
+	_ commands.TxStatusChecker = (*EthereumClient)(nil)
+	_ commands.NonceSource     = (*EthereumClient)(nil)
+)
+
+// EthereumClient talks to Ethereum nodes through their JSON-RPC API
+type EthereumClient struct {
+	router string
+	nodes  *httpclient.Pool
+	nextId atomic.Uint64
+}
+
+// NewEthereumClient creates a new EthereumClient for the nodes at urls, the calls go to the nodes in turn and fail over to
+// the next node when one fails. router is the address of the THORChain router contract that liquidity is added through,
+// it isn't checked when empty. The options override the policies of the requests to every node.
+func NewEthereumClient(urls []string, router string, opts ...httpclient.Option) (*EthereumClient, error) {
+	// the JSON-RPC calls are reads, but for the broadcasts which the nodes accept again as already known
+	opts = append([]httpclient.Option{httpclient.WithIdempotentMethods(http.MethodPost)}, opts...)
+	nodes, err := httpclient.NewPool("ethereum", urls, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &EthereumClient{router: router, nodes: nodes}, nil
+}
+
+type rpcRequest struct {
+	JsonRPC string        `json:"jsonrpc"`
+	Id      uint64        `json:"id"`
+	Method  string        `json:"method"`
+	Params  []interface{} `json:"params"`
+}
+
+type rpcResponse struct {
+	Result json.RawMessage `json:"result"`
+	Error  *rpcError       `json:"error"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type ethTransaction struct {
+	From  string `json:"from"`
+	To    string `json:"to"`
+	Value string `json:"value"`
+	Input string `json:"input"`
+	// BlockHash and BlockNumber are null while the transaction is pending
+	BlockHash   *string `json:"blockHash"`
+	BlockNumber *string `json:"blockNumber"`
+}
+
+type ethReceipt struct {
+	Status string   `json:"status"`
+	Logs   []ethLog `json:"logs"`
+}
+
+type ethLog struct {
+	Address string   `json:"address"`
+	Topics  []string `json:"topics"`
+	Data    string   `json:"data"`
+}
+
+// VerifyLP implements commands.LPVerifier by checking that the transaction was sent successfully from the pair's wallet
+// to the THORChain router with a memo adding liquidity to the expected pool
+func (c *EthereumClient) VerifyLP(ctx context.Context, tx commands.LPTx) error {
+	etx, _, err := c.succeededTx(ctx, tx.TxHash)
+	if err != nil {
+		return err
+	}
+
+	if !strings.EqualFold(etx.From, tx.From) {
+		return fmt.Errorf("%w: transaction is sent from %s instead of %s", commands.ErrTxMismatch, etx.From, tx.From)
+	}
+	if c.router != "" && !strings.EqualFold(etx.To, c.router) {
+		return fmt.Errorf("%w: transaction is sent to %s instead of the router", commands.ErrTxMismatch, etx.To)
+	}
+
+	memo, err := decodeRouterMemo(etx.Input)
+	if err != nil {
+		return fmt.Errorf("%w: %s", commands.ErrTxMismatch, err)
+	}
+	if !isAddLiquidityMemo(memo, tx.Pool) {
+		return fmt.Errorf("%w: memo %q doesn't add liquidity to %s", commands.ErrTxMismatch, memo, tx.Pool)
+	}
+
+	return nil
+}
+
+// VerifyDeposit implements commands.DepositVerifier by checking that the transaction transferred the amount successfully
+// from the participant to the pair's wallet and that the wallet still holds it. Native transfers are checked by the value
+// of the transaction, token transfers by the Transfer events the asset's contract emitted, so the deposits made through
+// other contracts, e.g. smart wallets, are accepted as well.
+func (c *EthereumClient) VerifyDeposit(ctx context.Context, tx commands.DepositTx) error {
+	etx, receipt, err := c.succeededTx(ctx, tx.TxHash)
+	if err != nil {
+		return err
+	}
+
+	info, _ := domain.LookupAsset(tx.Asset)
+	if info.IsToken() {
+		if err := verifyTokenTransfer(receipt.Logs, info, tx); err != nil {
+			return err
+		}
+	} else {
+		if !strings.EqualFold(etx.From, tx.From) {
+			return fmt.Errorf("%w: transaction is sent from %s instead of %s", commands.ErrTxMismatch, etx.From, tx.From)
+		}
+		if !strings.EqualFold(etx.To, tx.To) {
+			return fmt.Errorf("%w: funds are transferred to %s instead of the pair's wallet %s", commands.ErrTxMismatch, etx.To, tx.To)
+		}
+		amount, ok := new(big.Int).SetString(strings.TrimPrefix(etx.Value, "0x"), 16)
+		if !ok {
+			return fmt.Errorf("%w: transaction has an invalid value %q", commands.ErrTxMismatch, etx.Value)
+		}
+		if amount.Cmp(tx.Amount) != 0 {
+			return fmt.Errorf("%w: transferred amount is %s instead of %s", commands.ErrTxMismatch, amount, tx.Amount)
+		}
+	}
+
+	balance, err := c.Balance(ctx, tx.Asset, tx.To)
+	if err != nil {
+		return err
+	}
+	if balance.Cmp(tx.Amount) < 0 {
+		return fmt.Errorf("%w: pair's wallet holds %s only, the deposit was moved out of it", commands.ErrTxMismatch, balance)
+	}
+
+	return nil
+}
+
+// erc20TransferTopic is the topic of the Transfer(address,address,uint256) event of the ERC-20 tokens
+const erc20TransferTopic = "0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef"
+
+// verifyTokenTransfer checks that the token contract emitted Transfer events moving the amount from the participant
+// to the pair's wallet, the events of other contracts or between other addresses are ignored
+func verifyTokenTransfer(logs []ethLog, info domain.AssetInfo, tx commands.DepositTx) error {
+	transferred := new(big.Int)
+	for _, l := range logs {
+		if !strings.EqualFold(l.Address, info.ContractAddress) || len(l.Topics) != 3 || !strings.EqualFold(l.Topics[0], erc20TransferTopic) {
+			continue
+		}
+		from := ethcommon.HexToAddress(l.Topics[1])
+		to := ethcommon.HexToAddress(l.Topics[2])
+		if from != ethcommon.HexToAddress(tx.From) || to != ethcommon.HexToAddress(tx.To) {
+			continue
+		}
+		amount, ok := new(big.Int).SetString(strings.TrimPrefix(l.Data, "0x"), 16)
+		if !ok {
+			return fmt.Errorf("%w: %s Transfer event has an invalid amount %q", commands.ErrTxMismatch, info.Ticker, l.Data)
+		}
+		transferred.Add(transferred, amount)
+	}
+
+	if transferred.Sign() == 0 {
+		return fmt.Errorf("%w: transaction doesn't transfer %s from %s to the pair's wallet %s", commands.ErrTxMismatch, info.Ticker, tx.From, tx.To)
+	}
+	if transferred.Cmp(tx.Amount) != 0 {
+		return fmt.Errorf("%w: transferred amount is %s instead of %s", commands.ErrTxMismatch, transferred, tx.Amount)
+	}
+
+	return nil
+}
+
+// erc20BalanceOfSelector is the selector of balanceOf(address)
+const erc20BalanceOfSelector = "70a08231"
+
+// Balance returns the balance of the address in the base units of the asset at the latest block,
+// the tokens are queried with the balanceOf function of their contract
+func (c *EthereumClient) Balance(ctx context.Context, asset domain.Asset, address domain.Address) (*big.Int, error) {
+	info, ok := domain.LookupAsset(asset)
+	if !ok || info.Chain != "ETH" {
+		return nil, fmt.Errorf("asset %s is not on Ethereum", asset)
+	}
+
+	var hexBalance string
+	if !info.IsToken() {
+		if err := c.call(ctx, "eth_getBalance", []interface{}{address, "latest"}, &hexBalance); err != nil {
+			return nil, err
+		}
+	} else {
+		data := "0x" + erc20BalanceOfSelector + hex.EncodeToString(ethcommon.LeftPadBytes(ethcommon.HexToAddress(address).Bytes(), 32))
+		call := map[string]string{"to": info.ContractAddress, "data": data}
+		if err := c.call(ctx, "eth_call", []interface{}{call, "latest"}, &hexBalance); err != nil {
+			return nil, err
+		}
+	}
+
+	balance := new(big.Int)
+	if trimmed := strings.TrimPrefix(hexBalance, "0x"); trimmed != "" {
+		if _, ok := balance.SetString(trimmed, 16); !ok {
+			return nil, fmt.Errorf("invalid %s balance %q", info.Ticker, hexBalance)
+		}
+	}
+
+	return balance, nil
+}
+
+const (
+	// transferGas is the gas used by a plain transfer of ETH
+	transferGas = 21000
+	// tokenTransferGas is the gas a transfer of the registered tokens uses at most
+	tokenTransferGas = 65000
+)
+
+// EstimateFee implements commands.FeeEstimator with the gas price suggested by the node,
+// the fees are the ones of a plain transfer and of a token transfer as the assurances and withdrawals are
+func (c *EthereumClient) EstimateFee(ctx context.Context) (commands.FeeEstimate, error) {
+	var hexPrice string
+	if err := c.call(ctx, "eth_gasPrice", []interface{}{}, &hexPrice); err != nil {
+		return commands.FeeEstimate{}, err
+	}
+	gasPrice, ok := new(big.Int).SetString(strings.TrimPrefix(hexPrice, "0x"), 16)
+	if !ok {
+		return commands.FeeEstimate{}, fmt.Errorf("invalid gas price %q", hexPrice)
+	}
+
+	return commands.FeeEstimate{
+		Chain:            "ETH",
+		Asset:            "ETH.ETH",
+		GasPrice:         gasPrice.String(),
+		Fee:              new(big.Int).Mul(gasPrice, big.NewInt(transferGas)).String(),
+		TokenTransferFee: new(big.Int).Mul(gasPrice, big.NewInt(tokenTransferGas)).String(),
+		EstimatedAt:      time.Now(),
+	}, nil
+}
+
+// NextNonce implements commands.NonceSource with the transactions count of the address, the pending ones included
+func (c *EthereumClient) NextNonce(ctx context.Context, address domain.Address) (int, error) {
+	var hexCount string
+	if err := c.call(ctx, "eth_getTransactionCount", []interface{}{address, "pending"}, &hexCount); err != nil {
+		return 0, err
+	}
+	count, err := strconv.ParseUint(strings.TrimPrefix(hexCount, "0x"), 16, 31)
+	if err != nil {
+		return 0, fmt.Errorf("invalid transaction count %q", hexCount)
+	}
+
+	return int(count), nil
+}
+
+// CheckTx implements commands.TxStatusChecker with the block the transaction is mined in and the latest block of the node
+func (c *EthereumClient) CheckTx(ctx context.Context, hash domain.TxHash) (commands.TxConfirmation, error) {
+	var etx *ethTransaction
+	if err := c.call(ctx, "eth_getTransactionByHash", []interface{}{hash}, &etx); err != nil {
+		return commands.TxConfirmation{}, err
+	}
+	if etx == nil || etx.BlockNumber == nil || etx.BlockHash == nil {
+		return commands.TxConfirmation{}, nil
+	}
+	mined, ok := new(big.Int).SetString(strings.TrimPrefix(*etx.BlockNumber, "0x"), 16)
+	if !ok {
+		return commands.TxConfirmation{}, fmt.Errorf("invalid block number %q", *etx.BlockNumber)
+	}
+
+	var receipt *ethReceipt
+	if err := c.call(ctx, "eth_getTransactionReceipt", []interface{}{hash}, &receipt); err != nil {
+		return commands.TxConfirmation{}, err
+	}
+	if receipt == nil {
+		return commands.TxConfirmation{}, nil
+	}
+
+	var hexLatest string
+	if err := c.call(ctx, "eth_blockNumber", []interface{}{}, &hexLatest); err != nil {
+		return commands.TxConfirmation{}, err
+	}
+	latest, ok := new(big.Int).SetString(strings.TrimPrefix(hexLatest, "0x"), 16)
+	if !ok {
+		return commands.TxConfirmation{}, fmt.Errorf("invalid block number %q", hexLatest)
+	}
+
+	confirmation := commands.TxConfirmation{
+		Mined:         true,
+		Confirmations: int(new(big.Int).Sub(latest, mined).Int64()) + 1,
+		BlockHash:     *etx.BlockHash,
+	}
+	if receipt.Status != "0x1" {
+		confirmation.Failed = true
+		confirmation.Reason = "transaction reverted"
+	}
+
+	return confirmation, nil
+}
+
+// succeededTx returns the transaction and its receipt once it's mined successfully
+func (c *EthereumClient) succeededTx(ctx context.Context, hash domain.TxHash) (*ethTransaction, *ethReceipt, error) {
+	var etx *ethTransaction
+	if err := c.call(ctx, "eth_getTransactionByHash", []interface{}{hash}, &etx); err != nil {
+		return nil, nil, err
+	}
+	if etx == nil {
+		return nil, nil, fmt.Errorf("%w: transaction %s not found", commands.ErrTxMismatch, hash)
+	}
+
+	var receipt *ethReceipt
+	if err := c.call(ctx, "eth_getTransactionReceipt", []interface{}{hash}, &receipt); err != nil {
+		return nil, nil, err
+	}
+	if receipt == nil {
+		return nil, nil, fmt.Errorf("%w: transaction %s is not mined yet", commands.ErrTxMismatch, hash)
+	}
+	if receipt.Status != "0x1" {
+		return nil, nil, fmt.Errorf("%w: transaction %s has failed", commands.ErrTxMismatch, hash)
+	}
+
+	return etx, receipt, nil
+}
+
+func (c *EthereumClient) call(ctx context.Context, method string, params []interface{}, result interface{}) error {
+	body, err := json.Marshal(rpcRequest{
+		JsonRPC: "2.0",
+		Id:      c.nextId.Add(1),
+		Method:  method,
+		Params:  params,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode %s request: %w", method, err)
+	}
+
+	res, err := c.nodes.Do(func(url string) (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create %s request: %w", method, err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		return req, nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to call %s: %w: %w", method, commands.ErrChainUnavailable, err)
+	}
+	defer res.Body.Close()
+
+	if httpclient.Failed(res) {
+		return fmt.Errorf("%w: ethereum node responded to %s with status %d", commands.ErrChainUnavailable, method, res.StatusCode)
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("ethereum node responded to %s with status %d", method, res.StatusCode)
+	}
+
+	var rpcRes rpcResponse
+	if err := json.NewDecoder(res.Body).Decode(&rpcRes); err != nil {
+		return fmt.Errorf("failed to decode %s response: %w", method, err)
+	}
+	if rpcRes.Error != nil {
+		return fmt.Errorf("%s failed with code %d: %s", method, rpcRes.Error.Code, rpcRes.Error.Message)
+	}
+
+	if err := json.Unmarshal(rpcRes.Result, result); err != nil {
+		return fmt.Errorf("failed to decode %s result: %w", method, err)
+	}
+
+	return nil
+}
+
+// decodeRouterMemo extracts the memo from the input of a call to the router's deposit or depositWithExpiry functions,
+// both of which take (address vault, address asset, uint256 amount, string memo, ...)
+func decodeRouterMemo(input string) (string, error) {
+	data, err := hex.DecodeString(strings.TrimPrefix(input, "0x"))
+	if err != nil {
+		return "", fmt.Errorf("invalid transaction input: %w", err)
+	}
+
+	const word = 32
+	if len(data) < 4+4*word {
+		return "", fmt.Errorf("transaction input is not a router deposit")
+	}
+	args := data[4:]
+
+	offset := new(big.Int).SetBytes(args[3*word : 4*word])
+	if !offset.IsInt64() || offset.Int64()+word > int64(len(args)) {
+		return "", fmt.Errorf("transaction input has an invalid memo offset")
+	}
+	start := offset.Int64() + word
+
+	length := new(big.Int).SetBytes(args[offset.Int64():start])
+	if !length.IsInt64() || start+length.Int64() > int64(len(args)) {
+		return "", fmt.Errorf("transaction input has an invalid memo length")
+	}
+
+	return string(args[start : start+length.Int64()]), nil
+}
+
+// isAddLiquidityMemo checks if the THORChain memo adds liquidity to the pool, e.g. +:ETH.ETH or ADD:ETH.ETH:thor1...
+func isAddLiquidityMemo(memo string, pool domain.Asset) bool {
+	parts := strings.Split(memo, ":")
+	if len(parts) < 2 {
+		return false
+	}
+
+	switch strings.ToUpper(parts[0]) {
+	case "+", "ADD", "A":
+		return domain.IsMemoAsset(parts[1], pool)
+	default:
+		return false
+	}
+}
+
+var _ commands.TxDecoder = (*EthereumTxDecoder)(nil)
+
+// EthereumTxDecoder decodes Ethereum transactions whose payload is the binary encoding of the unsigned transaction
+// and whose signature is the 65 bytes [R || S || V] signature of it
+type EthereumTxDecoder struct {
+	chainId *big.Int
+	router  string
+}
+
+// NewEthereumTxDecoder creates a new EthereumTxDecoder accepting transactions of the network with chainId.
+// The deposits into the THORChain router are decoded with their memo when its address is given, e.g. the withdrawals from the Savers vaults.
+func NewEthereumTxDecoder(chainId int64, router string) *EthereumTxDecoder {
+	return &EthereumTxDecoder{chainId: big.NewInt(chainId), router: router}
+}
+
+// erc20TransferSelector is the selector of transfer(address,uint256)
+const erc20TransferSelector = "a9059cbb"
+
+// DecodeTx implements commands.TxDecoder for native ETH transfers, ERC-20 transfers of the registered tokens and ETH deposits
+// into the THORChain router
+func (d *EthereumTxDecoder) DecodeTx(signed domain.SignedTx) (commands.DecodedTx, error) {
+	tx := new(types.Transaction)
+	if err := tx.UnmarshalBinary(signed.Tx); err != nil {
+		return commands.DecodedTx{}, fmt.Errorf("%w: payload is not an Ethereum transaction", commands.ErrTxMismatch)
+	}
+
+	if tx.ChainId() == nil || tx.ChainId().Cmp(d.chainId) != 0 {
+		return commands.DecodedTx{}, fmt.Errorf("%w: transaction is for chain %v instead of %v", commands.ErrTxMismatch, tx.ChainId(), d.chainId)
+	}
+
+	from, err := d.sender(tx, signed.Signature)
+	if err != nil {
+		return commands.DecodedTx{}, err
+	}
+
+	if tx.To() == nil {
+		return commands.DecodedTx{}, fmt.Errorf("%w: contract creation is not allowed", commands.ErrTxMismatch)
+	}
+
+	decoded := commands.DecodedTx{
+		ChainId:  d.chainId.String(),
+		Nonce:    int(tx.Nonce()),
+		From:     from,
+		GasPrice: tx.GasPrice(),
+	}
+
+	if d.router != "" && strings.EqualFold(tx.To().Hex(), d.router) {
+		if tx.Gas() < tokenTransferGas {
+			return commands.DecodedTx{}, fmt.Errorf("%w: gas limit %d is below the %d of a router deposit", commands.ErrTxMismatch, tx.Gas(), tokenTransferGas)
+		}
+		decoded.To = tx.To().Hex()
+		decoded.Asset = "ETH.ETH"
+		decoded.Amount = tx.Value()
+		decoded.Memo, err = decodeRouterMemo(hex.EncodeToString(tx.Data()))
+		if err != nil {
+			return commands.DecodedTx{}, fmt.Errorf("%w: %s", commands.ErrTxMismatch, err)
+		}
+		return decoded, nil
+	}
+
+	token, ok := domain.LookupAssetByContract("ETH", tx.To().Hex())
+	if !ok {
+		if len(tx.Data()) > 0 {
+			return commands.DecodedTx{}, fmt.Errorf("%w: calling unknown contract %s", commands.ErrTxMismatch, tx.To().Hex())
+		}
+		if tx.Gas() < transferGas {
+			return commands.DecodedTx{}, fmt.Errorf("%w: gas limit %d is below the %d of a transfer", commands.ErrTxMismatch, tx.Gas(), transferGas)
+		}
+		decoded.To = tx.To().Hex()
+		decoded.Asset = "ETH.ETH"
+		decoded.Amount = tx.Value()
+		return decoded, nil
+	}
+
+	if tx.Value().Sign() != 0 {
+		return commands.DecodedTx{}, fmt.Errorf("%w: token transfer must not carry ETH", commands.ErrTxMismatch)
+	}
+	// A token transfer running out of gas would leave the funds in the pair's wallet
+	if tx.Gas() < tokenTransferGas {
+		return commands.DecodedTx{}, fmt.Errorf("%w: gas limit %d is below the %d of a %s transfer", commands.ErrTxMismatch, tx.Gas(), tokenTransferGas, token.Ticker)
+	}
+	decoded.Asset = token.Asset
+	decoded.To, decoded.Amount, err = decodeERC20Transfer(tx.Data())
+	if err != nil {
+		return commands.DecodedTx{}, err
+	}
+
+	return decoded, nil
+}
+
+var _ commands.TxSigningHasher = (*EthereumTxDecoder)(nil)
+
+// SigningHash implements commands.TxSigningHasher, the transactions are signed with secp256k1 over the hash of the signer of the chain
+func (d *EthereumTxDecoder) SigningHash(signed domain.SignedTx) (domain.SignatureScheme, []byte, error) {
+	tx := new(types.Transaction)
+	if err := tx.UnmarshalBinary(signed.Tx); err != nil {
+		return "", nil, fmt.Errorf("%w: payload is not an Ethereum transaction", commands.ErrTxMismatch)
+	}
+
+	return domain.SignatureSchemeECDSASecp256k1, types.LatestSignerForChainID(d.chainId).Hash(tx).Bytes(), nil
+}
+
+func (d *EthereumTxDecoder) sender(tx *types.Transaction, signature []byte) (domain.Address, error) {
+	signer := types.LatestSignerForChainID(d.chainId)
+	signedTx, err := withSignature(tx, signer, signature)
+	if err != nil {
+		return "", err
+	}
+
+	from, err := types.Sender(signer, signedTx)
+	if err != nil {
+		return "", fmt.Errorf("%w: failed to recover the signer: %s", commands.ErrTxMismatch, err)
+	}
+
+	return from.Hex(), nil
+}
+
+// withSignature attaches the 65 bytes [R || S || V] signature to the unsigned transaction
+func withSignature(tx *types.Transaction, signer types.Signer, signature []byte) (*types.Transaction, error) {
+	if len(signature) != 65 {
+		return nil, fmt.Errorf("%w: signature must be 65 bytes", commands.ErrTxMismatch)
+	}
+
+	// Accept both the raw recovery id and the legacy 27/28 notation
+	sig := make([]byte, len(signature))
+	copy(sig, signature)
+	if sig[64] >= 27 {
+		sig[64] -= 27
+	}
+
+	signedTx, err := tx.WithSignature(signer, sig)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid signature: %s", commands.ErrTxMismatch, err)
+	}
+
+	return signedTx, nil
+}
+
+var _ commands.TxBroadcaster = (*EthereumTxBroadcaster)(nil)
+
+// EthereumTxBroadcaster broadcasts the transactions pre-signed by the participants through an Ethereum node
+type EthereumTxBroadcaster struct {
+	client  *EthereumClient
+	chainId *big.Int
+}
+
+// NewEthereumTxBroadcaster creates a new EthereumTxBroadcaster sending the transactions of the network with chainId through the client
+func NewEthereumTxBroadcaster(client *EthereumClient, chainId int64) *EthereumTxBroadcaster {
+	return &EthereumTxBroadcaster{client: client, chainId: big.NewInt(chainId)}
+}
+
+// Broadcast implements commands.TxBroadcaster by signing the payload with its signature and sending it with eth_sendRawTransaction
+func (b *EthereumTxBroadcaster) Broadcast(ctx context.Context, signed domain.SignedTx) (domain.TxHash, error) {
+	tx := new(types.Transaction)
+	if err := tx.UnmarshalBinary(signed.Tx); err != nil {
+		return "", fmt.Errorf("%w: payload is not an Ethereum transaction", commands.ErrTxMismatch)
+	}
+
+	signedTx, err := withSignature(tx, types.LatestSignerForChainID(b.chainId), signed.Signature)
+	if err != nil {
+		return "", err
+	}
+
+	raw, err := signedTx.MarshalBinary()
+	if err != nil {
+		return "", fmt.Errorf("failed to encode transaction: %w", err)
+	}
+
+	var hash domain.TxHash
+	if err := b.client.call(ctx, "eth_sendRawTransaction", []interface{}{"0x" + hex.EncodeToString(raw)}, &hash); err != nil {
+		return "", err
+	}
+
+	return hash, nil
+}
+
+// decodeERC20Transfer decodes the recipient and the amount of an ERC-20 transfer call
+func decodeERC20Transfer(data []byte) (domain.Address, *big.Int, error) {
+	const word = 32
+	if len(data) != 4+2*word || hex.EncodeToString(data[:4]) != erc20TransferSelector {
+		return "", nil, fmt.Errorf("%w: only ERC-20 transfers are allowed", commands.ErrTxMismatch)
+	}
+	args := data[4:]
+
+	to := ethcommon.BytesToAddress(args[:word])
+	amount := new(big.Int).SetBytes(args[word:])
+
+	return to.Hex(), amount, nil
+}
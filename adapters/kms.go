@@ -0,0 +1,112 @@
+package adapters
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/co-defi/api-server/common"
+	"github.com/co-defi/api-server/common/httpclient"
+)
+
+var _ common.KeyWrapper = (*KMSKeyWrapper)(nil)
+
+// KMSKeyWrapper wraps the data keys of the encryption at rest under a key of AWS KMS, requests are signed with AWS Signature Version 4
+type KMSKeyWrapper struct {
+	endpoint  *url.URL
+	region    string
+	keyId     string
+	accessKey string
+	secretKey string
+	client    *httpclient.Client
+}
+
+// NewKMSKeyWrapper creates a new KMSKeyWrapper for the KMS key (its id, ARN or alias) of the region.
+// The endpoint of the region is used when endpoint is empty.
+func NewKMSKeyWrapper(endpoint, region, keyId, accessKey, secretKey string) (*KMSKeyWrapper, error) {
+	if endpoint == "" {
+		endpoint = "https://kms." + region + ".amazonaws.com"
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid KMS endpoint %q", endpoint)
+	}
+
+	return &KMSKeyWrapper{
+		endpoint:  u,
+		region:    region,
+		keyId:     keyId,
+		accessKey: accessKey,
+		secretKey: secretKey,
+		client:    httpclient.New("kms", httpclient.WithIdempotentMethods(http.MethodPost)),
+	}, nil
+}
+
+// KeyId implements the common.KeyWrapper interface
+func (w *KMSKeyWrapper) KeyId() string {
+	return "kms:" + w.keyId
+}
+
+// WrapKey implements the common.KeyWrapper interface by encrypting the data key with the KMS key
+func (w *KMSKeyWrapper) WrapKey(ctx context.Context, dataKey []byte) ([]byte, error) {
+	var resp struct {
+		CiphertextBlob []byte
+	}
+	if err := w.call(ctx, "Encrypt", map[string]interface{}{"KeyId": w.keyId, "Plaintext": dataKey}, &resp); err != nil {
+		return nil, err
+	}
+
+	return resp.CiphertextBlob, nil
+}
+
+// UnwrapKey implements the common.KeyWrapper interface by decrypting the data key with the KMS key
+func (w *KMSKeyWrapper) UnwrapKey(ctx context.Context, wrapped []byte) ([]byte, error) {
+	var resp struct {
+		Plaintext []byte
+	}
+	if err := w.call(ctx, "Decrypt", map[string]interface{}{"KeyId": w.keyId, "CiphertextBlob": wrapped}, &resp); err != nil {
+		return nil, err
+	}
+
+	return resp.Plaintext, nil
+}
+
+// call calls the action of the KMS API and decodes its response into out
+func (w *KMSKeyWrapper) call(ctx context.Context, action string, in, out interface{}) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.endpoint.String(), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if req.URL.Path == "" {
+		req.URL.Path = "/"
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "TrentService."+action)
+	hash := sha256.Sum256(body)
+	signAWSRequest(req, "kms", w.region, w.accessKey, w.secretKey, hex.EncodeToString(hash[:]), time.Now().UTC())
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to call KMS %s: %w", action, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("failed to call KMS %s: %s: %s", action, resp.Status, b)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(out)
+}
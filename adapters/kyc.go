@@ -0,0 +1,79 @@
+package adapters
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/co-defi/api-server/app/compliance"
+	"github.com/co-defi/api-server/common/httpclient"
+	"github.com/co-defi/api-server/domain"
+)
+
+var _ compliance.Checker = (*KYCProvider)(nil)
+
+// KYCProvider verifies the participants with an HTTP KYC provider answering GET <url>/<address>
+// with {"status": "approved" | "rejected" | "pending", "reason": "..."}, an unknown address is pending
+type KYCProvider struct {
+	url    string
+	apiKey string
+	client *httpclient.Client
+}
+
+// NewKYCProvider creates a new KYCProvider for the provider at url
+func NewKYCProvider(url, apiKey string) *KYCProvider {
+	return &KYCProvider{
+		url:    strings.TrimSuffix(url, "/"),
+		apiKey: apiKey,
+		client: httpclient.New("kyc"),
+	}
+}
+
+// Name implements compliance.Checker
+func (p *KYCProvider) Name() string {
+	return "kyc:" + p.url
+}
+
+type kycResponse struct {
+	Status compliance.Status `json:"status"`
+	Reason string            `json:"reason"`
+}
+
+// Check implements compliance.Checker
+func (p *KYCProvider) Check(ctx context.Context, address domain.Address) (compliance.Status, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.url+"/"+url.PathEscape(string(address)), nil)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to create kyc request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if p.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+p.apiKey)
+	}
+
+	res, err := p.client.Do(req)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to query kyc provider: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotFound {
+		return compliance.StatusPending, "address unknown to the provider", nil
+	}
+	if res.StatusCode >= http.StatusBadRequest {
+		return "", "", fmt.Errorf("kyc provider responded with status %d", res.StatusCode)
+	}
+
+	var body kycResponse
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		return "", "", fmt.Errorf("failed to decode kyc response: %w", err)
+	}
+	switch body.Status {
+	case compliance.StatusApproved, compliance.StatusRejected, compliance.StatusPending:
+		return body.Status, body.Reason, nil
+	default:
+		return "", "", fmt.Errorf("kyc provider responded with unknown status %q", body.Status)
+	}
+}
@@ -0,0 +1,406 @@
+package adapters
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/co-defi/api-server/app/commands"
+	"github.com/co-defi/api-server/app/queries"
+	"github.com/co-defi/api-server/common/httpclient"
+	"github.com/co-defi/api-server/domain"
+)
+
+var (
+	_ commands.LPVerifier         = (*MidgardClient)(nil)
+	_ commands.SaversVerifier     = (*MidgardClient)(nil)
+	_ commands.WithdrawalVerifier = (*MidgardClient)(nil)
+	_ commands.FeeEstimator       = (*MidgardClient)(nil)
+	_ commands.TxStatusChecker    = (*MidgardClient)(nil)
+	_ queries.PriceOracle         = (*MidgardClient)(nil)
+	_ queries.PositionSource      = (*MidgardClient)(nil)
+)
+
+// MidgardClient queries THORChain Midgard instances for the actions recorded on THORChain
+type MidgardClient struct {
+	instances *httpclient.Pool
+}
+
+// NewMidgardClient creates a new MidgardClient for the Midgard instances at baseURLs, the queries go to the instances in turn
+// and fail over to the next instance when one fails. The options override the policies of the requests to every instance.
+func NewMidgardClient(baseURLs []string, opts ...httpclient.Option) (*MidgardClient, error) {
+	instances, err := httpclient.NewPool("midgard", baseURLs, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &MidgardClient{instances: instances}, nil
+}
+
+type midgardActions struct {
+	Actions []midgardAction `json:"actions"`
+}
+
+type midgardAction struct {
+	Type   string               `json:"type"`
+	Status string               `json:"status"`
+	Pools  []string             `json:"pools"`
+	In     []midgardTransaction `json:"in"`
+	Out    []midgardTransaction `json:"out"`
+}
+
+type midgardTransaction struct {
+	Address string        `json:"address"`
+	TxID    string        `json:"txID"`
+	Coins   []midgardCoin `json:"coins"`
+}
+
+type midgardCoin struct {
+	Asset  string `json:"asset"`
+	Amount string `json:"amount"`
+}
+
+// midgardDecimals is the precision of all the amounts reported by Midgard, whatever the decimals of the asset
+const midgardDecimals = 8
+
+// VerifyLP implements commands.LPVerifier by looking for a successful addLiquidity action of the transaction.
+// Midgard indexes the inbound transactions of every chain, so it can verify both sides of the pool.
+func (c *MidgardClient) VerifyLP(ctx context.Context, tx commands.LPTx) error {
+	actions, err := c.actions(ctx, tx.TxHash, "addLiquidity")
+	if err != nil {
+		return err
+	}
+
+	if len(actions) == 0 {
+		return fmt.Errorf("%w: no liquidity was added by %s", commands.ErrTxMismatch, tx.TxHash)
+	}
+
+	for _, action := range actions {
+		if action.Status != "success" {
+			continue
+		}
+		if !containsFold(action.Pools, tx.Pool) {
+			continue
+		}
+		for _, in := range action.In {
+			if sameTxID(in.TxID, tx.TxHash) && strings.EqualFold(in.Address, tx.From) {
+				return nil
+			}
+		}
+	}
+
+	return fmt.Errorf("%w: %s didn't add liquidity from %s to %s", commands.ErrTxMismatch, tx.TxHash, tx.From, tx.Pool)
+}
+
+// VerifySavers implements commands.SaversVerifier by looking for a successful addLiquidity action of the transaction
+// into the Savers vault, then checking the address holds units of the vault so the deposit wasn't refunded
+func (c *MidgardClient) VerifySavers(ctx context.Context, tx commands.SaversTx) error {
+	actions, err := c.actions(ctx, tx.TxHash, "addLiquidity")
+	if err != nil {
+		return err
+	}
+
+	added := false
+	for _, action := range actions {
+		if action.Status == "success" && containsFold(action.Pools, tx.Vault) && sentBy(action.In, tx.TxHash, tx.From) {
+			added = true
+			break
+		}
+	}
+	if !added {
+		return fmt.Errorf("%w: %s didn't deposit from %s into %s", commands.ErrTxMismatch, tx.TxHash, tx.From, tx.Vault)
+	}
+
+	var saver midgardSaver
+	if err := c.get(ctx, "/v2/saver/"+url.PathEscape(tx.From), &saver); err != nil {
+		if errors.Is(err, errMidgardNotFound) {
+			return fmt.Errorf("%w: %s has no position in %s", commands.ErrTxMismatch, tx.From, tx.Vault)
+		}
+		return err
+	}
+	for _, p := range saver.Pools {
+		// The vaults are reported by the notation of their asset
+		if strings.EqualFold(p.Pool, tx.Asset) && parseMidgardAmount(p.SaverUnits, 0) > 0 {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: %s has no position in %s", commands.ErrTxMismatch, tx.From, tx.Vault)
+}
+
+// VerifyWithdrawal implements commands.WithdrawalVerifier by looking for the withdraw action of the transaction
+// and summing the coins it paid out
+func (c *MidgardClient) VerifyWithdrawal(ctx context.Context, tx commands.WithdrawalTx) (map[domain.Asset]domain.TokenAmount, error) {
+	actions, err := c.actions(ctx, tx.TxHash, "withdraw")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, action := range actions {
+		if !containsFold(action.Pools, tx.Pool) || !sentBy(action.In, tx.TxHash, tx.From) {
+			continue
+		}
+
+		switch action.Status {
+		case "pending":
+			return nil, fmt.Errorf("%w: withdrawal %s is pending", commands.ErrTxPending, tx.TxHash)
+		case "success":
+			return sumCoins(action.Out)
+		}
+	}
+
+	if len(actions) == 0 {
+		return nil, fmt.Errorf("%w: withdrawal %s is not indexed yet", commands.ErrTxPending, tx.TxHash)
+	}
+
+	return nil, fmt.Errorf("%w: %s didn't withdraw from %s", commands.ErrTxMismatch, tx.TxHash, tx.Pool)
+}
+
+func sentBy(txs []midgardTransaction, txID string, address domain.Address) bool {
+	for _, in := range txs {
+		if sameTxID(in.TxID, txID) && strings.EqualFold(in.Address, address) {
+			return true
+		}
+	}
+	return false
+}
+
+// sumCoins sums the coins of the transactions by asset
+func sumCoins(txs []midgardTransaction) (map[domain.Asset]domain.TokenAmount, error) {
+	totals := make(map[domain.Asset]*big.Int)
+	for _, out := range txs {
+		for _, coin := range out.Coins {
+			amount, ok := new(big.Int).SetString(coin.Amount, 10)
+			if !ok {
+				return nil, fmt.Errorf("invalid midgard amount %q of %s", coin.Amount, coin.Asset)
+			}
+			asset := domain.Asset(strings.ToUpper(coin.Asset))
+			if totals[asset] == nil {
+				totals[asset] = new(big.Int)
+			}
+			totals[asset].Add(totals[asset], amount)
+		}
+	}
+
+	amounts := make(map[domain.Asset]domain.TokenAmount, len(totals))
+	for asset, total := range totals {
+		amounts[asset] = domain.TokenAmount{Amount: total.String(), Decimals: midgardDecimals}
+	}
+	return amounts, nil
+}
+
+// CheckTx implements commands.TxStatusChecker with the actions of the transaction. THORChain has instant finality,
+// so an executed transaction is final at once, and the inbound transactions THORChain refunded are failed.
+func (c *MidgardClient) CheckTx(ctx context.Context, hash domain.TxHash) (commands.TxConfirmation, error) {
+	actions, err := c.actions(ctx, hash, "")
+	if err != nil {
+		return commands.TxConfirmation{}, err
+	}
+
+	confirmation := commands.TxConfirmation{}
+	for _, action := range actions {
+		confirmation.Mined = true
+		switch {
+		case action.Type == "refund":
+			return commands.TxConfirmation{Mined: true, Failed: true, Reason: "transaction was refunded by THORChain"}, nil
+		case action.Status == "success":
+			confirmation.Confirmations = 1
+		}
+	}
+
+	return confirmation, nil
+}
+
+// actions returns the actions of the transaction, of any type when actionType is empty
+func (c *MidgardClient) actions(ctx context.Context, txID, actionType string) ([]midgardAction, error) {
+	query := url.Values{}
+	query.Set("txid", normalizeTxID(txID))
+	if actionType != "" {
+		query.Set("type", actionType)
+	}
+
+	var body midgardActions
+	if err := c.get(ctx, "/v2/actions?"+query.Encode(), &body); err != nil {
+		return nil, err
+	}
+
+	return body.Actions, nil
+}
+
+type midgardPool struct {
+	AssetPriceUSD string `json:"assetPriceUSD"`
+	AssetDepth    string `json:"assetDepth"`
+	RuneDepth     string `json:"runeDepth"`
+	Units         string `json:"units"`
+}
+
+type midgardMember struct {
+	Pools []midgardMemberPool `json:"pools"`
+}
+
+type midgardMemberPool struct {
+	Pool           string `json:"pool"`
+	LiquidityUnits string `json:"liquidityUnits"`
+	RuneAdded      string `json:"runeAdded"`
+	AssetAdded     string `json:"assetAdded"`
+}
+
+type midgardSaver struct {
+	Pools []midgardSaverPool `json:"pools"`
+}
+
+type midgardSaverPool struct {
+	Pool       string `json:"pool"`
+	SaverUnits string `json:"saverUnits"`
+	AssetAdded string `json:"assetAdded"`
+}
+
+type midgardStats struct {
+	RunePriceUSD string `json:"runePriceUSD"`
+}
+
+// PriceUSD implements queries.PriceOracle with the prices of the THORChain pools,
+// RUNE isn't a pool asset so its price comes from the network stats
+func (c *MidgardClient) PriceUSD(ctx context.Context, asset domain.Asset) (float64, error) {
+	var price string
+	if asset == domain.RuneAsset {
+		var stats midgardStats
+		if err := c.get(ctx, "/v2/stats", &stats); err != nil {
+			return 0, err
+		}
+		price = stats.RunePriceUSD
+	} else {
+		var pool midgardPool
+		if err := c.get(ctx, "/v2/pool/"+url.PathEscape(string(asset)), &pool); err != nil {
+			return 0, err
+		}
+		price = pool.AssetPriceUSD
+	}
+
+	value, err := strconv.ParseFloat(price, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid midgard price of %s: %w", asset, err)
+	}
+	return value, nil
+}
+
+type midgardConstants struct {
+	Int64Values map[string]int64 `json:"int_64_values"`
+}
+
+// EstimateFee implements commands.FeeEstimator with the flat fee of the native THORChain transactions
+func (c *MidgardClient) EstimateFee(ctx context.Context) (commands.FeeEstimate, error) {
+	var constants midgardConstants
+	if err := c.get(ctx, "/v2/thorchain/constants", &constants); err != nil {
+		return commands.FeeEstimate{}, err
+	}
+	fee, ok := constants.Int64Values["NativeTransactionFee"]
+	if !ok {
+		return commands.FeeEstimate{}, errors.New("midgard doesn't report the native transaction fee")
+	}
+
+	return commands.FeeEstimate{
+		Chain:       "THOR",
+		Asset:       domain.RuneAsset,
+		Fee:         strconv.FormatInt(fee, 10),
+		EstimatedAt: time.Now(),
+	}, nil
+}
+
+// Position implements queries.PositionSource with the membership of the address in the pool and the depths of the pool.
+// Midgard indexes both sides of the pool, so the liquidity added from the other chains is included.
+func (c *MidgardClient) Position(ctx context.Context, pool domain.Asset, address domain.Address) (queries.LPPosition, error) {
+	var member midgardMember
+	if err := c.get(ctx, "/v2/member/"+url.PathEscape(address), &member); err != nil {
+		if errors.Is(err, errMidgardNotFound) {
+			return queries.LPPosition{}, nil
+		}
+		return queries.LPPosition{}, err
+	}
+
+	var p midgardPool
+	if err := c.get(ctx, "/v2/pool/"+url.PathEscape(string(pool)), &p); err != nil {
+		return queries.LPPosition{}, err
+	}
+
+	position := queries.LPPosition{
+		PoolUnits:  parseMidgardAmount(p.Units, 0),
+		RuneDepth:  parseMidgardAmount(p.RuneDepth, midgardDecimals),
+		AssetDepth: parseMidgardAmount(p.AssetDepth, midgardDecimals),
+	}
+	for _, m := range member.Pools {
+		if !strings.EqualFold(m.Pool, pool) {
+			continue
+		}
+		position.Units = parseMidgardAmount(m.LiquidityUnits, 0)
+		position.RuneAdded = parseMidgardAmount(m.RuneAdded, midgardDecimals)
+		position.AssetAdded = parseMidgardAmount(m.AssetAdded, midgardDecimals)
+	}
+
+	return position, nil
+}
+
+// parseMidgardAmount converts the integer amount reported by Midgard to a float with the given decimals, invalid amounts are zero
+func parseMidgardAmount(amount string, decimals int) float64 {
+	return domain.TokenAmount{Amount: amount, Decimals: decimals}.Float()
+}
+
+// errMidgardNotFound is returned when Midgard doesn't know the requested resource
+var errMidgardNotFound = errors.New("not found in midgard")
+
+// get queries the path of the Midgard API and decodes the JSON response into out
+func (c *MidgardClient) get(ctx context.Context, path string, out any) error {
+	res, err := c.instances.Do(func(baseURL string) (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseURL+path, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create midgard request: %w", err)
+		}
+		return req, nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to query midgard: %w: %w", commands.ErrChainUnavailable, err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotFound {
+		return errMidgardNotFound
+	}
+	if httpclient.Failed(res) {
+		return fmt.Errorf("%w: midgard responded with status %d", commands.ErrChainUnavailable, res.StatusCode)
+	}
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("midgard responded with status %d", res.StatusCode)
+	}
+
+	if err := json.NewDecoder(res.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode midgard response: %w", err)
+	}
+
+	return nil
+}
+
+// normalizeTxID converts a transaction hash to the notation used by THORChain, upper case hex without the 0x prefix
+func normalizeTxID(txID string) string {
+	return strings.ToUpper(strings.TrimPrefix(strings.TrimPrefix(txID, "0x"), "0X"))
+}
+
+func sameTxID(a, b string) bool {
+	return normalizeTxID(a) == normalizeTxID(b)
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+
+	return false
+}
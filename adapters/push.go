@@ -0,0 +1,81 @@
+package adapters
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/co-defi/api-server/app/notifications"
+	"github.com/co-defi/api-server/app/queries"
+	"github.com/co-defi/api-server/common/httpclient"
+)
+
+var _ notifications.Channel = (*PushChannel)(nil)
+
+// PushChannel sends push notifications through an HTTP push gateway
+type PushChannel struct {
+	url    string
+	apiKey string
+	client *httpclient.Client
+}
+
+// NewPushChannel creates a new PushChannel for the gateway at url
+func NewPushChannel(url, apiKey string) *PushChannel {
+	return &PushChannel{
+		url:    url,
+		apiKey: apiKey,
+		client: httpclient.New("push"),
+	}
+}
+
+// Name implements notifications.Channel
+func (c *PushChannel) Name() string {
+	return "push"
+}
+
+type pushRequest struct {
+	Token string            `json:"token"`
+	Title string            `json:"title"`
+	Body  string            `json:"body"`
+	Data  map[string]string `json:"data"`
+}
+
+// Send implements notifications.Channel
+func (c *PushChannel) Send(ctx context.Context, recipient queries.NotificationSettings, n notifications.Notification) error {
+	if recipient.PushToken == "" {
+		return nil
+	}
+
+	body, err := json.Marshal(pushRequest{
+		Token: recipient.PushToken,
+		Title: n.Title,
+		Body:  n.Body,
+		Data:  map[string]string{"event": string(n.Event), "pair_id": n.PairId},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode push request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create push request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+
+	res, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send push notification: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("push gateway responded with status %d", res.StatusCode)
+	}
+
+	return nil
+}
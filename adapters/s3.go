@@ -0,0 +1,92 @@
+package adapters
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/co-defi/api-server/common/httpclient"
+)
+
+// S3Uploader uploads files to a bucket of an S3-compatible storage, requests are signed with AWS Signature Version 4
+type S3Uploader struct {
+	endpoint  *url.URL
+	region    string
+	bucket    string
+	accessKey string
+	secretKey string
+	client    *httpclient.Client
+}
+
+// NewS3Uploader creates a new S3Uploader for the bucket at the endpoint (e.g. https://s3.us-east-1.amazonaws.com),
+// the objects are addressed path-style so any S3-compatible storage can be used
+func NewS3Uploader(endpoint, region, bucket, accessKey, secretKey string) (*S3Uploader, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid S3 endpoint %q", endpoint)
+	}
+
+	return &S3Uploader{
+		endpoint:  u,
+		region:    region,
+		bucket:    bucket,
+		accessKey: accessKey,
+		secretKey: secretKey,
+		// the uploads are streamed from the files, so they aren't retried
+		client: httpclient.New("s3", httpclient.WithTimeout(30*time.Minute)),
+	}, nil
+}
+
+// Upload uploads the file at path as the object key
+func (u *S3Uploader) Upload(ctx context.Context, path, key string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// The payload is hashed first to sign it, then streamed from the start
+	hash := sha256.New()
+	size, err := io.Copy(hash, f)
+	if err != nil {
+		return fmt.Errorf("failed to hash %s: %w", path, err)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	segments := []string{u.bucket}
+	for _, segment := range strings.Split(key, "/") {
+		segments = append(segments, url.PathEscape(segment))
+	}
+	target := *u.endpoint
+	target.RawPath = strings.TrimSuffix(u.endpoint.Path, "/") + "/" + strings.Join(segments, "/")
+	target.Path, _ = url.PathUnescape(target.RawPath)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, target.String(), f)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	signAWSRequest(req, "s3", u.region, u.accessKey, u.secretKey, hex.EncodeToString(hash.Sum(nil)), time.Now().UTC())
+
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to upload %s: %w", key, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("failed to upload %s: %s: %s", key, resp.Status, body)
+	}
+
+	return nil
+}
@@ -0,0 +1,66 @@
+package adapters
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+
+	"github.com/co-defi/api-server/app/commands"
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+)
+
+var (
+	_ commands.SignatureVerifier = (*Secp256k1Verifier)(nil)
+	_ commands.SignatureVerifier = (*Ed25519Verifier)(nil)
+)
+
+var errSignatureMismatch = errors.New("signature doesn't match the public key")
+
+// Secp256k1Verifier verifies the ECDSA secp256k1 signatures of the THORChain, Bitcoin and Ethereum transactions,
+// made over the 32 bytes hash the chain signs the transactions by
+type Secp256k1Verifier struct{}
+
+// NewSecp256k1Verifier creates a new Secp256k1Verifier
+func NewSecp256k1Verifier() *Secp256k1Verifier {
+	return &Secp256k1Verifier{}
+}
+
+// VerifySignature implements commands.SignatureVerifier for the compressed or uncompressed public keys and the
+// [R || S] signatures, the recovery id of the [R || S || V] signatures is ignored
+func (v *Secp256k1Verifier) VerifySignature(publicKey, payloadHash, signature []byte) error {
+	if len(payloadHash) != 32 {
+		return fmt.Errorf("payload hash must be 32 bytes, got %d", len(payloadHash))
+	}
+	if len(signature) == 65 {
+		signature = signature[:64]
+	}
+	if len(signature) != 64 {
+		return fmt.Errorf("signature must be 64 or 65 bytes, got %d", len(signature))
+	}
+	if !ethcrypto.VerifySignature(publicKey, payloadHash, signature) {
+		return errSignatureMismatch
+	}
+
+	return nil
+}
+
+// Ed25519Verifier verifies the EdDSA ed25519 signatures of the transactions of the chains signing with it, made over
+// their payload hash as is
+type Ed25519Verifier struct{}
+
+// NewEd25519Verifier creates a new Ed25519Verifier
+func NewEd25519Verifier() *Ed25519Verifier {
+	return &Ed25519Verifier{}
+}
+
+// VerifySignature implements commands.SignatureVerifier
+func (v *Ed25519Verifier) VerifySignature(publicKey, payloadHash, signature []byte) error {
+	if len(publicKey) != ed25519.PublicKeySize {
+		return fmt.Errorf("public key must be %d bytes, got %d", ed25519.PublicKeySize, len(publicKey))
+	}
+	if !ed25519.Verify(publicKey, payloadHash, signature) {
+		return errSignatureMismatch
+	}
+
+	return nil
+}
@@ -0,0 +1,136 @@
+package adapters
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/co-defi/api-server/app/commands"
+	"github.com/co-defi/api-server/domain"
+)
+
+var _ commands.TxDecoder = (*ThorchainTxDecoder)(nil)
+
+// ThorchainTxDecoder decodes THORChain transactions signed in the legacy amino JSON sign mode,
+// where the signed payload is the JSON encoded StdSignDoc of the transaction
+type ThorchainTxDecoder struct {
+	chainId string
+}
+
+// NewThorchainTxDecoder creates a new ThorchainTxDecoder accepting transactions of the network with chainId
+func NewThorchainTxDecoder(chainId string) *ThorchainTxDecoder {
+	return &ThorchainTxDecoder{chainId: chainId}
+}
+
+type stdSignDoc struct {
+	ChainId  string     `json:"chain_id"`
+	Sequence string     `json:"sequence"`
+	Memo     string     `json:"memo"`
+	Msgs     []aminoMsg `json:"msgs"`
+}
+
+type aminoMsg struct {
+	Type  string          `json:"type"`
+	Value json.RawMessage `json:"value"`
+}
+
+type thorCoin struct {
+	Asset  string `json:"asset"`
+	Denom  string `json:"denom"`
+	Amount string `json:"amount"`
+}
+
+type msgDeposit struct {
+	Coins  []thorCoin `json:"coins"`
+	Memo   string     `json:"memo"`
+	Signer string     `json:"signer"`
+}
+
+type msgSend struct {
+	FromAddress string     `json:"from_address"`
+	ToAddress   string     `json:"to_address"`
+	Amount      []thorCoin `json:"amount"`
+}
+
+// DecodeTx implements commands.TxDecoder for single message MsgDeposit and MsgSend transactions
+func (d *ThorchainTxDecoder) DecodeTx(signed domain.SignedTx) (commands.DecodedTx, error) {
+	var doc stdSignDoc
+	if err := json.Unmarshal(signed.Tx, &doc); err != nil {
+		return commands.DecodedTx{}, fmt.Errorf("%w: payload is not an amino JSON sign doc", commands.ErrTxMismatch)
+	}
+
+	if doc.ChainId != d.chainId {
+		return commands.DecodedTx{}, fmt.Errorf("%w: transaction is for chain %q instead of %q", commands.ErrTxMismatch, doc.ChainId, d.chainId)
+	}
+
+	nonce, err := strconv.Atoi(doc.Sequence)
+	if err != nil {
+		return commands.DecodedTx{}, fmt.Errorf("%w: invalid sequence %q", commands.ErrTxMismatch, doc.Sequence)
+	}
+
+	if len(doc.Msgs) != 1 {
+		return commands.DecodedTx{}, fmt.Errorf("%w: transaction must have exactly one message", commands.ErrTxMismatch)
+	}
+
+	tx := commands.DecodedTx{
+		ChainId: doc.ChainId,
+		Nonce:   nonce,
+		Memo:    doc.Memo,
+	}
+
+	msg := doc.Msgs[0]
+	switch msg.Type {
+	case "thorchain/MsgDeposit":
+		var deposit msgDeposit
+		if err := json.Unmarshal(msg.Value, &deposit); err != nil {
+			return commands.DecodedTx{}, fmt.Errorf("%w: invalid MsgDeposit", commands.ErrTxMismatch)
+		}
+		tx.From = deposit.Signer
+		tx.Memo = deposit.Memo
+		tx.Asset, tx.Amount, err = decodeRuneCoins(deposit.Coins)
+	case "thorchain/MsgSend":
+		var send msgSend
+		if err := json.Unmarshal(msg.Value, &send); err != nil {
+			return commands.DecodedTx{}, fmt.Errorf("%w: invalid MsgSend", commands.ErrTxMismatch)
+		}
+		tx.From = send.FromAddress
+		tx.To = send.ToAddress
+		tx.Asset, tx.Amount, err = decodeRuneCoins(send.Amount)
+	default:
+		return commands.DecodedTx{}, fmt.Errorf("%w: unsupported message type %q", commands.ErrTxMismatch, msg.Type)
+	}
+	if err != nil {
+		return commands.DecodedTx{}, err
+	}
+
+	return tx, nil
+}
+
+var _ commands.TxSigningHasher = (*ThorchainTxDecoder)(nil)
+
+// SigningHash implements commands.TxSigningHasher, the sign docs are signed with secp256k1 over their SHA-256 hash
+func (d *ThorchainTxDecoder) SigningHash(signed domain.SignedTx) (domain.SignatureScheme, []byte, error) {
+	hash := sha256.Sum256(signed.Tx)
+	return domain.SignatureSchemeECDSASecp256k1, hash[:], nil
+}
+
+// decodeRuneCoins decodes the coins of a message which are only allowed to be RUNE
+func decodeRuneCoins(coins []thorCoin) (domain.Asset, *big.Int, error) {
+	total := new(big.Int)
+	for _, coin := range coins {
+		if !strings.EqualFold(coin.Asset, domain.RuneAsset) && !strings.EqualFold(coin.Denom, "rune") {
+			return "", nil, fmt.Errorf("%w: only RUNE can be transferred", commands.ErrTxMismatch)
+		}
+
+		amount, ok := new(big.Int).SetString(coin.Amount, 10)
+		if !ok || amount.Sign() < 0 {
+			return "", nil, fmt.Errorf("%w: invalid amount %q", commands.ErrTxMismatch, coin.Amount)
+		}
+		total.Add(total, amount)
+	}
+
+	return domain.RuneAsset, total, nil
+}
@@ -0,0 +1,347 @@
+package apikeys
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/co-defi/api-server/common"
+	"github.com/co-defi/api-server/domain"
+	"github.com/google/uuid"
+)
+
+// Scope is what an API key grants its bearer
+type Scope string
+
+const (
+	// ScopeParticipant acts as the participant of the address of the key on the participant routes
+	ScopeParticipant Scope = "participant"
+	// ScopeEvents reads the event feed
+	ScopeEvents Scope = "events:read"
+	// ScopeAdmin uses every admin route on behalf of the key
+	ScopeAdmin Scope = "admin"
+)
+
+// Scopes are the scopes an API key can be issued with
+var Scopes = []Scope{ScopeParticipant, ScopeEvents, ScopeAdmin}
+
+// keyPrefix starts every API key so the leaked keys are easy to spot
+const keyPrefix = "cdk_"
+
+// lastUsedPrecision is how often the last use of a key is recorded at most
+const lastUsedPrecision = time.Minute
+
+// Key is an API key of a bot or an internal service, its secret is only known when it's issued
+type Key struct {
+	Id   uuid.UUID `json:"id"`
+	Name string    `json:"name"`
+	// Prefix is the start of the secret to tell the keys apart
+	Prefix string  `json:"prefix"`
+	Scopes []Scope `json:"scopes"`
+	// Chain, Address and Network are the participant the key acts as with ScopeParticipant
+	Chain      common.Chain   `json:"chain,omitempty"`
+	Address    domain.Address `json:"address,omitempty"`
+	Network    domain.Network `json:"network,omitempty"`
+	CreatedBy  string         `json:"created_by"`
+	CreatedAt  time.Time      `json:"created_at"`
+	ExpiresAt  *time.Time     `json:"expires_at"`
+	RevokedAt  *time.Time     `json:"revoked_at"`
+	LastUsedAt *time.Time     `json:"last_used_at"`
+}
+
+// HasScope checks if the key grants the scope
+func (k Key) HasScope(scope Scope) bool {
+	return slices.Contains(k.Scopes, scope)
+}
+
+// Active checks if the key can still be used at the time
+func (k Key) Active(now time.Time) bool {
+	return k.RevokedAt == nil && (k.ExpiresAt == nil || now.Before(*k.ExpiresAt))
+}
+
+// Spec is what an API key is issued with or changed to, the keys without an expiry never expire
+type Spec struct {
+	Name      string         `json:"name" validate:"required,max=100"`
+	Scopes    []Scope        `json:"scopes" validate:"required,min=1,dive,oneof=participant events:read admin"`
+	Chain     common.Chain   `json:"chain,omitempty" validate:"omitempty,oneof=ETH THOR"`
+	Address   domain.Address `json:"address,omitempty" validate:"omitempty,address"`
+	Network   domain.Network `json:"network,omitempty" validate:"omitempty,network"`
+	ExpiresAt time.Time      `json:"expires_at,omitempty"`
+}
+
+var (
+	ErrKeyNotFound      = common.NewError("api_key_not_found", "API key not found")
+	ErrInvalidKey       = common.NewError("api_key_invalid", "API key is invalid, expired or revoked")
+	ErrScopeNotGranted  = common.NewError("api_key_scope_missing", "API key doesn't grant access to this route")
+	ErrParticipantScope = common.NewError("api_key_participant_required", "participant scope requires the chain and the address the key acts as")
+)
+
+// Store holds the API keys of the machine integrations, only the SHA-256 hashes of their secrets are stored.
+// The keys are managed by the operators rather than rebuilt from the events, like the blocklist.
+type Store struct {
+	db    *common.DB
+	clock common.Clock
+}
+
+// NewStore creates a new Store and its table
+func NewStore(db *common.DB, clock common.Clock) (*Store, error) {
+	_, err := db.Write.Exec(`create table if not exists api_keys (
+		id TEXT PRIMARY KEY,
+		hash TEXT UNIQUE,
+		name TEXT,
+		prefix TEXT,
+		scopes TEXT,
+		chain TEXT,
+		address TEXT,
+		network TEXT,
+		created_by TEXT,
+		created_at TEXT,
+		expires_at TEXT,
+		revoked_at TEXT,
+		last_used_at TEXT
+	);`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create api_keys table: %w", err)
+	}
+
+	return &Store{db: db, clock: clock}, nil
+}
+
+// Issue creates a new API key on behalf of the operator and returns it along with its secret, which isn't stored
+func (s *Store) Issue(ctx context.Context, spec Spec, operator string) (*Key, string, error) {
+	if err := validateSpec(spec); err != nil {
+		return nil, "", err
+	}
+
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, "", err
+	}
+	secret := keyPrefix + base64.RawURLEncoding.EncodeToString(buf)
+
+	key := Key{
+		Id:        uuid.New(),
+		Prefix:    secret[:len(keyPrefix)+6],
+		CreatedBy: operator,
+		CreatedAt: s.clock.Now().UTC(),
+	}
+	applySpec(&key, spec)
+
+	if _, err := s.db.Write.ExecContext(ctx, `insert into api_keys (id, hash, name, prefix, scopes, chain, address, network, created_by, created_at, expires_at)
+		values (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);`,
+		key.Id.String(), hashSecret(secret), key.Name, key.Prefix, mustMarshalScopes(key.Scopes), key.Chain, key.Address, key.Network,
+		key.CreatedBy, formatTime(key.CreatedAt), formatOptionalTime(key.ExpiresAt)); err != nil {
+		return nil, "", fmt.Errorf("failed to insert API key: %w", err)
+	}
+
+	return &key, secret, nil
+}
+
+// Update changes the name, the scopes, the participant and the expiry of the key
+func (s *Store) Update(ctx context.Context, id uuid.UUID, spec Spec) (*Key, error) {
+	if err := validateSpec(spec); err != nil {
+		return nil, err
+	}
+
+	key := Key{}
+	applySpec(&key, spec)
+	res, err := s.db.Write.ExecContext(ctx, `update api_keys set name = ?, scopes = ?, chain = ?, address = ?, network = ?, expires_at = ?
+		where id = ? and revoked_at is null;`,
+		key.Name, mustMarshalScopes(key.Scopes), key.Chain, key.Address, key.Network, formatOptionalTime(key.ExpiresAt), id.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to update API key: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, ErrKeyNotFound
+	}
+
+	return s.Get(ctx, id)
+}
+
+// Revoke revokes the key, it's rejected from then on
+func (s *Store) Revoke(ctx context.Context, id uuid.UUID) error {
+	res, err := s.db.Write.ExecContext(ctx, `update api_keys set revoked_at = ? where id = ? and revoked_at is null;`,
+		formatTime(s.clock.Now()), id.String())
+	if err != nil {
+		return fmt.Errorf("failed to revoke API key: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrKeyNotFound
+	}
+
+	return nil
+}
+
+// Get returns the key by id
+func (s *Store) Get(ctx context.Context, id uuid.UUID) (*Key, error) {
+	key, err := scanKey(s.db.Read.QueryRowContext(ctx, `select `+keyColumns+` from api_keys where id = ?;`, id.String()))
+	if err == sql.ErrNoRows {
+		return nil, ErrKeyNotFound
+	}
+
+	return key, err
+}
+
+// All returns the keys, the revoked ones included, the latest created first
+func (s *Store) All(ctx context.Context) ([]Key, error) {
+	rows, err := s.db.Read.QueryContext(ctx, `select `+keyColumns+` from api_keys order by datetime(created_at) desc, id;`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query API keys: %w", err)
+	}
+	defer rows.Close()
+
+	keys := []Key{}
+	for rows.Next() {
+		key, err := scanKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, *key)
+	}
+
+	return keys, rows.Err()
+}
+
+// Authenticate returns the active key of the secret and records its use
+func (s *Store) Authenticate(ctx context.Context, secret string) (*Key, error) {
+	if !strings.HasPrefix(secret, keyPrefix) {
+		return nil, ErrInvalidKey
+	}
+
+	key, err := scanKey(s.db.Read.QueryRowContext(ctx, `select `+keyColumns+` from api_keys where hash = ?;`, hashSecret(secret)))
+	if err == sql.ErrNoRows {
+		return nil, ErrInvalidKey
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	now := s.clock.Now()
+	if !key.Active(now) {
+		return nil, ErrInvalidKey
+	}
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= lastUsedPrecision {
+		if _, err := s.db.Write.ExecContext(ctx, `update api_keys set last_used_at = ? where id = ?;`, formatTime(now), key.Id.String()); err != nil {
+			return nil, fmt.Errorf("failed to record API key use: %w", err)
+		}
+	}
+
+	return key, nil
+}
+
+// AuthenticateParticipant implements common.APIKeyAuthenticator, the key must act as a participant
+func (s *Store) AuthenticateParticipant(ctx context.Context, secret string) (common.Token, error) {
+	key, err := s.Authenticate(ctx, secret)
+	if err != nil {
+		return common.Token{}, err
+	}
+	if !key.HasScope(ScopeParticipant) {
+		return common.Token{}, ErrScopeNotGranted
+	}
+
+	token := common.Token{
+		Id:       key.Id,
+		Chain:    key.Chain,
+		Address:  string(key.Address),
+		Network:  domain.NetworkOrDefault(key.Network),
+		IssuedAt: key.CreatedAt.Unix(),
+		Verified: true,
+	}
+	if key.ExpiresAt != nil {
+		token.ExpiresAt = key.ExpiresAt.Unix()
+	}
+
+	return token, nil
+}
+
+func validateSpec(spec Spec) error {
+	if err := common.Validate(spec); err != nil {
+		return err
+	}
+	if slices.Contains(spec.Scopes, ScopeParticipant) && (spec.Chain == "" || spec.Address == "") {
+		return ErrParticipantScope
+	}
+
+	return nil
+}
+
+func applySpec(key *Key, spec Spec) {
+	key.Name = spec.Name
+	key.Scopes = spec.Scopes
+	key.Chain = spec.Chain
+	key.Address = spec.Address
+	key.Network = spec.Network
+	key.ExpiresAt = nil
+	if !spec.ExpiresAt.IsZero() {
+		expiresAt := spec.ExpiresAt.UTC()
+		key.ExpiresAt = &expiresAt
+	}
+}
+
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func mustMarshalScopes(scopes []Scope) string {
+	b, err := json.Marshal(scopes)
+	if err != nil {
+		panic(err)
+	}
+	return string(b)
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+func formatOptionalTime(t *time.Time) any {
+	if t == nil {
+		return nil
+	}
+	return formatTime(*t)
+}
+
+const keyColumns = `id, name, prefix, scopes, chain, address, network, created_by, created_at, expires_at, revoked_at, last_used_at`
+
+func scanKey(row interface{ Scan(...any) error }) (*Key, error) {
+	var (
+		key                              Key
+		id, scopes, createdAt            string
+		expiresAt, revokedAt, lastUsedAt sql.NullString
+	)
+	if err := row.Scan(&id, &key.Name, &key.Prefix, &scopes, &key.Chain, &key.Address, &key.Network, &key.CreatedBy,
+		&createdAt, &expiresAt, &revokedAt, &lastUsedAt); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to scan API key: %w", err)
+	}
+	key.Id, _ = uuid.Parse(id)
+	_ = json.Unmarshal([]byte(scopes), &key.Scopes)
+	key.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+	key.ExpiresAt = parseOptionalTime(expiresAt)
+	key.RevokedAt = parseOptionalTime(revokedAt)
+	key.LastUsedAt = parseOptionalTime(lastUsedAt)
+
+	return &key, nil
+}
+
+func parseOptionalTime(s sql.NullString) *time.Time {
+	if !s.Valid {
+		return nil
+	}
+	t, err := time.Parse(time.RFC3339, s.String)
+	if err != nil {
+		return nil
+	}
+	return &t
+}
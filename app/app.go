@@ -1,11 +1,20 @@
 package app
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"time"
 
+	"github.com/co-defi/api-server/app/apikeys"
+	"github.com/co-defi/api-server/app/audit"
+	"github.com/co-defi/api-server/app/blocklist"
 	"github.com/co-defi/api-server/app/commands"
+	"github.com/co-defi/api-server/app/compliance"
+	"github.com/co-defi/api-server/app/notifications"
 	"github.com/co-defi/api-server/app/queries"
+	"github.com/co-defi/api-server/app/relay"
 	"github.com/co-defi/api-server/common"
 	"github.com/co-defi/api-server/domain"
 	"github.com/google/uuid"
@@ -18,40 +27,438 @@ import (
 type Application struct {
 	Commands Commands
 	Queries  Queries
+	AuditLog *audit.Log
+	// Blocklist holds the addresses which can't authenticate nor act on the pairs
+	Blocklist *blocklist.Blocklist
+	// APIKeys holds the API keys the bots and the internal services authenticate with
+	APIKeys *apikeys.Store
+	// Compliance verifies the participants with the provider of WithComplianceChecker and caches the results
+	Compliance *compliance.Service
+	Relay      *relay.Mailbox
+	Fees       *commands.Fees
+	// DataExports packages the personal data of the participants for them to download
+	DataExports *DataExporter
+	// KeyRotator wraps the values encrypted at rest under the current master key, it's only set along WithEncryption
+	KeyRotator *KeyRotator
+	// Clock tells the time to the commands and the workers, and to the authentication of the ports
+	Clock common.Clock
 
-	projectionsGroup *eventsourcing.Group
-	logger           zerolog.Logger
+	db                   *common.DB
+	repo                 *eventsourcing.EventRepository
+	store                *sqles.SQL
+	blobs                *common.BlobStore
+	cipher               *common.Cipher
+	projections          []common.Projection
+	projectionsGroup     *common.ProjectionGroup
+	projectionPace       common.ProjectionPace
+	projectionPaces      map[string]common.ProjectionPace
+	projectionTrigger    bool
+	savedPairs           *savedPairs
+	notificationChannels []notifications.Channel
+	lpVerifiers          commands.LPVerifiers
+	saversVerifiers      commands.SaversVerifiers
+	depositVerifiers     commands.DepositVerifiers
+	txDecoders           commands.TxDecoders
+	walletDerivers       commands.WalletDerivers
+	txBroadcasters       commands.TxBroadcasters
+	withdrawalVerifiers  commands.WithdrawalVerifiers
+	feeEstimators        commands.FeeEstimators
+	nonceSources         commands.NonceSources
+	nonces               *commands.Nonces
+	signatureVerifiers   commands.SignatureVerifiers
+	signatures           *commands.Signatures
+	txStatusCheckers     commands.TxStatusCheckers
+	refundTimeout        time.Duration
+	matchTimeout         time.Duration
+	keygenRetries        int
+	pairQuotas           commands.PairQuotas
+	allowSelfMatch       bool
+	acceptUnverifiedTxs  bool
+	complianceChecker    compliance.Checker
+	complianceTTL        time.Duration
+	priceOracle          queries.PriceOracle
+	positionSource       queries.PositionSource
+	archiveRetention     time.Duration
+	dispatcher           *notifications.Dispatcher
+	stopWorkers          context.CancelFunc
+	logger               zerolog.Logger
+	logLevels            *common.LogLevels
+	// projectionLogger logs the failures of the projections, at the level of their module
+	projectionLogger zerolog.Logger
 }
 
-func NewApplication(db *sql.DB, logger zerolog.Logger) (*Application, error) {
+// Option configures optional subsystems of the Application
+type Option func(*Application)
+
+// WithNotificationChannels enables notifying the participants about their pairs through the given channels
+func WithNotificationChannels(channels ...notifications.Channel) Option {
+	return func(app *Application) {
+		app.notificationChannels = append(app.notificationChannels, channels...)
+	}
+}
+
+// WithLPVerifier verifies the LP transactions of the assets on chain using the verifier
+func WithLPVerifier(chain string, verifier commands.LPVerifier) Option {
+	return func(app *Application) {
+		if app.lpVerifiers == nil {
+			app.lpVerifiers = make(commands.LPVerifiers)
+		}
+		app.lpVerifiers[chain] = verifier
+	}
+}
+
+// WithSaversVerifier verifies the deposits of the assets into their Savers vaults on chain using the verifier
+func WithSaversVerifier(chain string, verifier commands.SaversVerifier) Option {
+	return func(app *Application) {
+		if app.saversVerifiers == nil {
+			app.saversVerifiers = make(commands.SaversVerifiers)
+		}
+		app.saversVerifiers[chain] = verifier
+	}
+}
+
+// WithDepositVerifier verifies the deposits of the assets on chain using the verifier
+func WithDepositVerifier(chain string, verifier commands.DepositVerifier) Option {
+	return func(app *Application) {
+		if app.depositVerifiers == nil {
+			app.depositVerifiers = make(commands.DepositVerifiers)
+		}
+		app.depositVerifiers[chain] = verifier
+	}
+}
+
+// WithTxDecoder validates the transactions pre-signed by the participants on the chain using the decoder
+func WithTxDecoder(chain string, decoder commands.TxDecoder) Option {
+	return func(app *Application) {
+		if app.txDecoders == nil {
+			app.txDecoders = make(commands.TxDecoders)
+		}
+		app.txDecoders[chain] = decoder
+	}
+}
+
+// WithPriceOracle values the assets locked in the pairs in $ for the platform statistics
+func WithPriceOracle(oracle queries.PriceOracle) Option {
+	return func(app *Application) {
+		app.priceOracle = oracle
+	}
+}
+
+// WithPositionSource values the liquidity the pairs provide with the positions of the source, priced with the price oracle
+func WithPositionSource(source queries.PositionSource) Option {
+	return func(app *Application) {
+		app.positionSource = source
+	}
+}
+
+// WithWalletDeriver verifies the wallet addresses of the pairs on the chain by deriving them with the deriver
+func WithWalletDeriver(chain string, deriver commands.WalletDeriver) Option {
+	return func(app *Application) {
+		if app.walletDerivers == nil {
+			app.walletDerivers = make(commands.WalletDerivers)
+		}
+		app.walletDerivers[chain] = deriver
+	}
+}
+
+// WithTxBroadcaster broadcasts the refunds of the assets on the chain using the broadcaster
+func WithTxBroadcaster(chain string, broadcaster commands.TxBroadcaster) Option {
+	return func(app *Application) {
+		if app.txBroadcasters == nil {
+			app.txBroadcasters = make(commands.TxBroadcasters)
+		}
+		app.txBroadcasters[chain] = broadcaster
+	}
+}
+
+// WithWithdrawalVerifier settles the withdrawals of the pairs by confirming them on chain with the verifier.
+// The settlements are only computed when a price oracle is set as well.
+func WithWithdrawalVerifier(chain string, verifier commands.WithdrawalVerifier) Option {
+	return func(app *Application) {
+		if app.withdrawalVerifiers == nil {
+			app.withdrawalVerifiers = make(commands.WithdrawalVerifiers)
+		}
+		app.withdrawalVerifiers[chain] = verifier
+	}
+}
+
+// WithFeeEstimator estimates the fees of the chain with the estimator, for the clients and to reject the underfunded transactions
+func WithFeeEstimator(chain string, estimator commands.FeeEstimator) Option {
+	return func(app *Application) {
+		if app.feeEstimators == nil {
+			app.feeEstimators = make(commands.FeeEstimators)
+		}
+		app.feeEstimators[chain] = estimator
+	}
+}
+
+// WithNonceSource sets the source of the nonces the wallet addresses reached on the chain, the transactions pre-signed
+// for the wallets with a nonce already used on chain are rejected. The nonces of the chains without a source aren't checked.
+func WithNonceSource(chain string, source commands.NonceSource) Option {
+	return func(app *Application) {
+		if app.nonceSources == nil {
+			app.nonceSources = make(commands.NonceSources)
+		}
+		app.nonceSources[chain] = source
+	}
+}
+
+// WithSignatureVerifier sets the verifier of the signatures of the scheme, the transactions of the chains signing with a
+// scheme without a verifier are rejected. The chains whose decoder doesn't compute the signing hash verify the signatures themselves.
+func WithSignatureVerifier(scheme domain.SignatureScheme, verifier commands.SignatureVerifier) Option {
+	return func(app *Application) {
+		if app.signatureVerifiers == nil {
+			app.signatureVerifiers = make(commands.SignatureVerifiers)
+		}
+		app.signatureVerifiers[scheme] = verifier
+	}
+}
+
+// WithTxStatusChecker tracks the confirmations of the transactions of the pairs on the chain with the checker
+func WithTxStatusChecker(chain string, checker commands.TxStatusChecker) Option {
+	return func(app *Application) {
+		if app.txStatusCheckers == nil {
+			app.txStatusCheckers = make(commands.TxStatusCheckers)
+		}
+		app.txStatusCheckers[chain] = checker
+	}
+}
+
+// WithRefundTimeout sets how long a participant waits for the counterparty to deposit before their deposit can be refunded
+func WithRefundTimeout(timeout time.Duration) Option {
+	return func(app *Application) {
+		app.refundTimeout = timeout
+	}
+}
+
+// WithMatchConfirmationTimeout sets how long a counterparty has to confirm the wallet before the match is reverted
+// and the pair goes back to waiting, 0 never reverts the matches
+func WithMatchConfirmationTimeout(timeout time.Duration) Option {
+	return func(app *Application) {
+		app.matchTimeout = timeout
+	}
+}
+
+// WithKeygenRetries sets how many times the wallet of a match is regenerated after the participants report they failed
+// to generate it, the match is reverted on the next failure
+func WithKeygenRetries(retries int) Option {
+	return func(app *Application) {
+		app.keygenRetries = retries
+	}
+}
+
+// WithPairQuotas limits the pairs a participant address may have in progress, there are no limits by default
+func WithPairQuotas(quotas commands.PairQuotas) Option {
+	return func(app *Application) {
+		app.pairQuotas = quotas
+	}
+}
+
+// WithSelfMatching lets the participants match the pairs created from their own addresses, e.g. to test the pairs
+// end to end with a single wallet. It must not be used in production, as it lets the participants inflate their activity.
+func WithSelfMatching() Option {
+	return func(app *Application) {
+		app.allowSelfMatch = true
+	}
+}
+
+// WithUnverifiedTxs accepts the deposits, LP and Savers transactions of the chains that can't be reached or have no verifier without verifying them,
+// they are flagged as unverified in the pairs. The commands fail with the chain_unavailable error otherwise.
+// The pre-signed transactions of the chains whose signatures can't be verified are accepted as well, instead of failing with invalid_tx_signature.
+func WithUnverifiedTxs() Option {
+	return func(app *Application) {
+		app.acceptUnverifiedTxs = true
+	}
+}
+
+// WithComplianceChecker requires the participants to be verified by the checker before they create or match pairs,
+// the results are cached per address for ttl. The participants aren't verified by default.
+func WithComplianceChecker(checker compliance.Checker, ttl time.Duration) Option {
+	return func(app *Application) {
+		app.complianceChecker = checker
+		app.complianceTTL = ttl
+	}
+}
+
+// WithClock replaces the system clock, e.g. with a clock controlled by the tests
+func WithClock(clock common.Clock) Option {
+	return func(app *Application) {
+		app.Clock = clock
+	}
+}
+
+// WithEncryption encrypts the wallet secrets and the signed transactions of the pairs at rest with the cipher,
+// in the event store and in the projections. The values stored before in plaintext are still read as is.
+func WithEncryption(cipher *common.Cipher) Option {
+	return func(app *Application) {
+		app.cipher = cipher
+	}
+}
+
+// WithLogLevels logs the modules of the application at their level in levels, e.g. the projections
+func WithLogLevels(levels *common.LogLevels) Option {
+	return func(app *Application) {
+		app.logLevels = levels
+	}
+}
+
+// WithPairArchiving periodically moves the pairs that have been in a terminal status for longer than retention to the archive
+func WithPairArchiving(retention time.Duration) Option {
+	return func(app *Application) {
+		app.archiveRetention = retention
+	}
+}
+
+// WithProjectionPace sets how often the named projections look for new events, or all the projections without names.
+// The projections are paced by common.DefaultProjectionPace by default.
+func WithProjectionPace(pace common.ProjectionPace, names ...string) Option {
+	return func(app *Application) {
+		if len(names) == 0 {
+			app.projectionPace = pace
+			return
+		}
+		if app.projectionPaces == nil {
+			app.projectionPaces = make(map[string]common.ProjectionPace)
+		}
+		for _, name := range names {
+			app.projectionPaces[name] = pace
+		}
+	}
+}
+
+// WithProjectionTrigger wakes up the projections as soon as events are saved, instead of waiting for their next run.
+// The projections still look for new events at their pace, e.g. for the events saved by another process.
+func WithProjectionTrigger() Option {
+	return func(app *Application) {
+		app.projectionTrigger = true
+	}
+}
+
+func NewApplication(db *common.DB, logger zerolog.Logger, opts ...Option) (*Application, error) {
 	// Set how identifiers are generated on newly created aggregates
 	eventsourcing.SetIDFunc(func() string {
 		return uuid.New().String()
 	})
 
-	repo, store, err := createEventRepository(db)
+	app := Application{
+		Clock:          common.SystemClock,
+		refundTimeout:  defaultRefundTimeout,
+		matchTimeout:   defaultMatchConfirmationTimeout,
+		keygenRetries:  defaultKeygenRetries,
+		projectionPace: common.DefaultProjectionPace,
+		logger:         logger,
+	}
+	for _, opt := range opts {
+		opt(&app)
+	}
+	app.projectionLogger = app.logLevels.Logger(logger, common.LogModuleProjections)
+	app.Fees = commands.NewFees(app.feeEstimators, feeEstimateTTL)
+	app.nonces = commands.NewNonces(app.nonceSources)
+	app.signatures = commands.NewSignatures(app.signatureVerifiers, app.txDecoders, app.walletDerivers, app.acceptUnverifiedTxs)
+
+	repo, store, subjects, blobs, err := createEventRepository(db.Write, app.cipher)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create event repository: %w", err)
 	}
+	app.db = db
+	app.repo = repo
+	app.store = store
+	app.blobs = blobs
+	app.savedPairs = newSavedPairs()
+	repo.Subscribers().Aggregate(func(e eventsourcing.Event) {
+		app.savedPairs.saved(e.AggregateID(), int(e.Version()))
+	}, &domain.Pair{})
+
+	if app.AuditLog, err = audit.NewLog(db); err != nil {
+		return nil, fmt.Errorf("failed to prepare audit log: %w", err)
+	}
+
+	if app.Blocklist, err = blocklist.NewBlocklist(db, app.Clock); err != nil {
+		return nil, fmt.Errorf("failed to prepare blocklist: %w", err)
+	}
+
+	if app.APIKeys, err = apikeys.NewStore(db, app.Clock); err != nil {
+		return nil, fmt.Errorf("failed to prepare API keys: %w", err)
+	}
+
+	if app.Compliance, err = compliance.NewService(db, app.complianceChecker, app.complianceTTL, app.Clock); err != nil {
+		return nil, fmt.Errorf("failed to prepare compliance checks: %w", err)
+	}
+
+	if app.Relay, err = relay.NewMailbox(db); err != nil {
+		return nil, fmt.Errorf("failed to prepare relay mailbox: %w", err)
+	}
+
+	if app.cipher != nil {
+		if app.KeyRotator, err = NewKeyRotator(db, app.cipher, app.Clock); err != nil {
+			return nil, fmt.Errorf("failed to prepare key rotator: %w", err)
+		}
+	}
 
-	queries, err := newQueries(db, store)
+	queries, err := newQueries(db, store, app.priceOracle, app.positionSource, app.cipher)
 	if err != nil {
 		return nil, fmt.Errorf("failed to prepare queries: %w", err)
 	}
+	app.Queries = queries
+	app.Queries.Pairs.OnCorruptRow(app.reportCorruptRow)
 
-	app := Application{
-		Commands: Commands{
-			CreateNewPlan:     commands.NewCreateNewPlanHandler(repo),
-			CreateOrMatchPair: commands.NewCreateOrMatchPairHandler(repo, queries.Plans, queries.Pairs),
-			ConfirmPairWallet: commands.NewConfirmPairWalletHandler(repo),
-			SetPairAssurances: commands.NewSetPairAssurancesHandler(repo),
-			AddDeposit:        commands.NewAddDepositHandler(repo),
-			SignWithdrawal:    commands.NewSignWithdrawalHandler(repo),
-			SubmitLP:          commands.NewSubmitLPHandler(repo),
-			SubmitWithdrawal:  commands.NewSubmitWithdrawalHandler(repo),
-		},
-		Queries: queries,
-		logger:  logger,
+	if app.DataExports, err = NewDataExporter(db, store, app.cipher, subjects, blobs, queries, app.AuditLog, app.Clock, logger); err != nil {
+		return nil, fmt.Errorf("failed to prepare data exports: %w", err)
+	}
+
+	app.Commands = Commands{
+		CreateNewPlan:     commands.NewCreateNewPlanHandler(repo),
+		PausePlan:         commands.NewPausePlanHandler(repo),
+		ResumePlan:        commands.NewResumePlanHandler(repo),
+		SetPlanTimeouts:   commands.NewSetPlanStatusTimeoutsHandler(repo),
+		SetPlanAssurances: commands.NewSetPlanAssurancePoliciesHandler(repo),
+		CreateOrMatchPair: commands.RejectBlocked[commands.CreateOrMatchPair](commands.RequireCompliance[commands.CreateOrMatchPair](commands.NewCreateOrMatchPairHandler(repo, queries.Plans, queries.Pairs, queries.Reputation, queries.Participants, app.pairQuotas, app.allowSelfMatch, app.Clock), app.Compliance), app.Blocklist),
+		ConfirmPairWallet: commands.RejectBlocked[commands.ConfirmPairWallet](commands.NewConfirmPairWalletHandler(repo, app.walletDerivers), app.Blocklist),
+		SetPairAssurances: commands.RejectBlocked[commands.SetPairAssurances](commands.NewSetPairAssurancesHandler(repo, app.txDecoders, app.Fees, app.nonces, app.signatures), app.Blocklist),
+		ConfirmAssurances: commands.RejectBlocked[commands.ConfirmAssurances](commands.NewConfirmAssurancesHandler(repo), app.Blocklist),
+		AddDeposit:        commands.RejectBlocked[commands.AddDeposit](commands.NewAddDepositHandler(repo, app.depositVerifiers, app.acceptUnverifiedTxs), app.Blocklist),
+		SignWithdrawal:    commands.RejectBlocked[commands.SignWithdrawal](commands.NewSignWithdrawalHandler(repo, app.txDecoders, app.nonces, app.signatures), app.Blocklist),
+		SubmitLP:          commands.RejectBlocked[commands.SubmitLP](commands.NewSubmitLPHandler(repo, app.lpVerifiers, app.acceptUnverifiedTxs, app.Clock), app.Blocklist),
+		SubmitWithdrawal:  commands.RejectBlocked[commands.SubmitWithdrawal](commands.NewSubmitWithdrawalHandler(repo, app.Clock), app.Blocklist),
+		PairBatch:         commands.RejectBlocked[commands.PairBatch](commands.NewPairBatchHandler(repo, app.walletDerivers, app.txDecoders, app.Fees, app.nonces, app.signatures), app.Blocklist),
+		RequestRefund:     commands.RejectBlocked[commands.RequestRefund](commands.NewRequestRefundHandler(repo, app.txBroadcasters, app.refundTimeout, app.Clock), app.Blocklist),
+		SettleWithdrawal:  commands.NewSettleWithdrawalHandler(repo, app.withdrawalVerifiers, app.priceOracle, app.Clock),
+		TrackPairTxs:      commands.NewTrackPairTxsHandler(repo, app.txStatusCheckers, app.Clock),
+		ForcePairStatus:   commands.NewForcePairStatusHandler(repo),
+		RevertStaleMatch:  commands.NewRevertStaleMatchHandler(repo, app.matchTimeout, app.Clock),
+		EscalateOverdue:   commands.NewEscalateOverduePairHandler(repo, app.Clock),
+
+		ReportKeygenFailure: commands.RejectBlocked[commands.ReportKeygenFailure](commands.NewReportKeygenFailureHandler(repo, app.keygenRetries), app.Blocklist),
+
+		SignSaversWithdrawal:   commands.RejectBlocked[commands.SignSaversWithdrawal](commands.NewSignSaversWithdrawalHandler(repo, app.txDecoders, app.nonces, app.signatures), app.Blocklist),
+		SubmitSavers:           commands.RejectBlocked[commands.SubmitSavers](commands.NewSubmitSaversHandler(repo, app.saversVerifiers, app.acceptUnverifiedTxs, app.Clock), app.Blocklist),
+		SubmitSaversWithdrawal: commands.RejectBlocked[commands.SubmitSaversWithdrawal](commands.NewSubmitSaversWithdrawalHandler(repo, app.Clock), app.Blocklist),
+
+		ProposeEarlyWithdrawal: commands.RejectBlocked[commands.ProposeEarlyWithdrawal](commands.NewProposeEarlyWithdrawalHandler(repo, app.Clock), app.Blocklist),
+		AcceptEarlyWithdrawal:  commands.RejectBlocked[commands.AcceptEarlyWithdrawal](commands.NewAcceptEarlyWithdrawalHandler(repo), app.Blocklist),
+		ProposeExtension:       commands.RejectBlocked[commands.ProposeExtension](commands.NewProposeExtensionHandler(repo, app.Clock), app.Blocklist),
+		AcceptExtension:        commands.RejectBlocked[commands.AcceptExtension](commands.NewAcceptExtensionHandler(repo, app.Clock), app.Blocklist),
+
+		PostPairMessage: commands.RejectBlocked[commands.PostPairMessage](commands.NewPostPairMessageHandler(repo, app.Clock), app.Blocklist),
+
+		UpdateNotificationSettings: commands.NewUpdateNotificationSettingsHandler(repo),
+
+		RegisterParticipant:    commands.NewRegisterParticipantHandler(repo, queries.Participants),
+		LinkParticipantAddress: commands.NewLinkParticipantAddressHandler(repo, queries.Participants),
+		BlockAddress:           commands.NewBlockAddressHandler(repo, app.Blocklist, queries.Participants),
+		RedactParticipantData:  commands.NewRedactParticipantDataHandler(repo, &personalDataShredder{db: db, subjects: subjects, exports: app.DataExports}, queries.Participants),
+
+		EscrowMediatorShare:   commands.NewEscrowMediatorShareHandler(repo, app.cipher != nil),
+		RequestShareRecovery:  commands.NewRequestShareRecoveryHandler(repo),
+		DecideShareRecovery:   commands.NewDecideShareRecoveryHandler(repo),
+		RetrieveMediatorShare: commands.NewRetrieveMediatorShareHandler(repo, app.Clock),
+	}
+
+	if len(app.notificationChannels) > 0 {
+		app.dispatcher, err = notifications.NewDispatcher(db, store, repo, queries.NotificationSettings, queries.Pairs, app.notificationChannels, app.Clock, logger)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create notifications dispatcher: %w", err)
+		}
 	}
 
 	app.registerProjections(repo)
@@ -59,25 +466,45 @@ func NewApplication(db *sql.DB, logger zerolog.Logger) (*Application, error) {
 	return &app, nil
 }
 
-func createEventRepository(db *sql.DB) (*eventsourcing.EventRepository, *sqles.SQL, error) {
+func createEventRepository(db *sql.DB, cipher *common.Cipher) (*eventsourcing.EventRepository, *sqles.SQL, *common.SubjectKeys, *common.BlobStore, error) {
+	store, err := createEventStore(db)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+	subjects, err := common.NewSubjectKeys(db, cipher)
+	if err != nil {
+		return nil, nil, nil, nil, fmt.Errorf("failed to load subject keys: %w", err)
+	}
+	blobs, err := common.NewBlobStore(db, eventBlobThreshold)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	repo := eventsourcing.NewEventRepository(store)
+	repo.Encoder(common.NewEventEncoder(cipher, encryptedEventFields).
+		WithSubjectKeys(subjects, personalEventFields).
+		WithBlobs(blobs, offloadedEventFields))
+	registerAggregates(repo)
+
+	return repo, store, subjects, blobs, nil
+}
+
+func createEventStore(db *sql.DB) (*sqles.SQL, error) {
 	store := sqles.Open(db)
 
 	need, err := needMigration(db)
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to check if migration is needed: %w", err)
+		return nil, fmt.Errorf("failed to check if migration is needed: %w", err)
 	}
 
 	if need {
 		err = store.Migrate()
 		if err != nil {
-			return nil, nil, fmt.Errorf("failed to migrate event store: %w", err)
+			return nil, fmt.Errorf("failed to migrate event store: %w", err)
 		}
 	}
 
-	repo := eventsourcing.NewEventRepository(store)
-	registerAggregates(repo)
-
-	return repo, store, nil
+	return store, nil
 }
 
 func needMigration(db *sql.DB) (bool, error) {
@@ -93,64 +520,499 @@ func needMigration(db *sql.DB) (bool, error) {
 func registerAggregates(repo *eventsourcing.EventRepository) {
 	repo.Register(&domain.Plan{})
 	repo.Register(&domain.Pair{})
+	repo.Register(&domain.NotificationSettings{})
+	repo.Register(&domain.PairChat{})
+	repo.Register(&domain.Participant{})
+	repo.Register(&domain.MediatorKeyEscrow{})
 }
 
 func (app *Application) registerProjections(repo *eventsourcing.EventRepository) {
-	app.projectionsGroup = common.RegisterProjectionsAsGroup(
-		repo,
-		common.NewFailSafeProjection(app.Queries.Plans, app.logger),
-		common.NewFailSafeProjection(app.Queries.Pairs, app.logger),
-	)
+	projections := []common.Projection{
+		common.NewFailSafeProjection(app.Queries.Plans, app.projectionLogger),
+		common.NewFailSafeProjection(app.Queries.Pairs, app.projectionLogger),
+		common.NewFailSafeProjection(app.Queries.Reputation, app.projectionLogger),
+		common.NewFailSafeProjection(app.Queries.Stats, app.projectionLogger),
+		common.NewFailSafeProjection(app.Queries.PlanStats, app.projectionLogger),
+		common.NewFailSafeProjection(app.Queries.PairsStats, app.projectionLogger),
+		common.NewFailSafeProjection(app.Queries.NotificationSettings, app.projectionLogger),
+		common.NewFailSafeProjection(app.Queries.PairMessages, app.projectionLogger),
+		common.NewFailSafeProjection(app.Queries.Participants, app.projectionLogger),
+		common.NewFailSafeProjection(app.Queries.Escrows, app.projectionLogger),
+	}
+	if app.dispatcher != nil {
+		projections = append(projections, common.NewFailSafeProjection(app.dispatcher, app.projectionLogger))
+	}
+
+	app.projections = projections
+	app.projectionsGroup = common.NewProjectionGroup(repo, app.projectionPace, app.projectionPaces, projections...)
+	if app.projectionTrigger {
+		repo.Subscribers().All(func(eventsourcing.Event) {
+			app.projectionsGroup.Trigger()
+		})
+	}
+}
+
+// CatchUpProjections runs the projections until they handled all the stored events, for the tools and the tests driving
+// the application without starting the projections. It must not be called once the projections are started.
+func (app *Application) CatchUpProjections(ctx context.Context) error {
+	for _, projection := range app.projections {
+		p := app.repo.Projections.Projection(projection.Fetch, projection.Callback)
+		if result := p.RunToEnd(ctx); result.Error != nil {
+			return fmt.Errorf("failed to catch up projections: %w", result.Error)
+		}
+	}
+
+	return nil
+}
+
+// VerifyProjections scans the rows of the pairs and plans queries and returns the ones that can't be decoded.
+// The corrupt rows are fixed by replaying the projections, see ResetAllProjections.
+func (app *Application) VerifyProjections(ctx context.Context) ([]queries.CorruptRowError, error) {
+	corrupt, err := app.Queries.Pairs.Verify(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify pairs: %w", err)
+	}
+	plans, err := app.Queries.Plans.Verify(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify plans: %w", err)
+	}
+
+	return append(corrupt, plans...), nil
+}
+
+// AnalyzeDB gathers the statistics of the tables and indexes SQLite plans the queries with, then returns the plans it
+// picks for the common query paths of the projections
+func (app *Application) AnalyzeDB(ctx context.Context) ([]queries.QueryPlan, error) {
+	if _, err := app.db.Write.ExecContext(ctx, `analyze;`); err != nil {
+		return nil, fmt.Errorf("failed to analyze database: %w", err)
+	}
+
+	return app.Queries.Pairs.ExplainQueries(ctx)
+}
+
+// reportCorruptRow logs the rows skipped by the listings of the pairs
+func (app *Application) reportCorruptRow(err *queries.CorruptRowError) {
+	app.logger.Error().Str("table", err.Table).Str("id", err.Id).Str("column", err.Column).Err(err.Err).Msg("skipped corrupt row")
 }
 
 func (app *Application) handleProjectionErrors() {
 	for res := range app.projectionsGroup.ErrChan {
-		app.logger.Error().Err(res.Error).Str("projection", res.Name).Msg("projection error")
+		app.projectionLogger.Error().Err(res.Error).Str("projection", res.Name).Msg("projection error")
 	}
 }
 
-// StartProjections starts the projections
+// StartProjections starts the projections and the background workers
 func (app *Application) StartProjections() {
 	go app.handleProjectionErrors()
 	go app.projectionsGroup.Start()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	app.stopWorkers = cancel
+	if app.dispatcher != nil {
+		go app.dispatcher.RunReminders(ctx, deadlineRemindersInterval)
+	}
+	if app.archiveRetention > 0 {
+		go app.runPairArchiver(ctx)
+	}
+	if app.matchTimeout > 0 {
+		go app.runMatchReverter(ctx)
+	}
+	go app.runOverdueEscalation(ctx)
+	go app.runRelayPruner(ctx)
+	go app.runDataExportPruner(ctx)
+	if len(app.withdrawalVerifiers) > 0 && app.priceOracle != nil {
+		go app.runSettlements(ctx)
+	}
+	if len(app.txStatusCheckers) > 0 {
+		go app.runTxTracker(ctx)
+	}
+	if app.cipher.Rotating() {
+		go app.runKeyRotation(ctx)
+	}
 }
 
-// StopProjections stops the projections
+// StopProjections stops the projections and the background workers
 func (app *Application) StopProjections() {
+	if app.stopWorkers != nil {
+		app.stopWorkers()
+	}
 	if app.projectionsGroup != nil {
 		app.projectionsGroup.Stop()
 	}
 }
 
+const (
+	deadlineRemindersInterval = 10 * time.Minute
+	pairArchivingInterval     = time.Hour
+	relayPruningInterval      = time.Hour
+	dataExportPruningInterval = time.Hour
+	settlementInterval        = 5 * time.Minute
+	txTrackingInterval        = time.Minute
+	matchRevertingInterval    = 5 * time.Minute
+	overdueCheckingInterval   = 5 * time.Minute
+	// relayRetention is how long the relayed TSS messages are kept, the ceremonies are expected to end well within it
+	relayRetention = 24 * time.Hour
+	// feeEstimateTTL is how long the fee estimates are served before the chains are asked again
+	feeEstimateTTL = 30 * time.Second
+	// defaultRefundTimeout is how long a deposit waits for the counterparty's before it can be refunded
+	defaultRefundTimeout = 72 * time.Hour
+	// defaultMatchConfirmationTimeout is how long a counterparty has to confirm the wallet before the match is reverted
+	defaultMatchConfirmationTimeout = 24 * time.Hour
+	// defaultKeygenRetries is how many times the wallet of a match is regenerated before the match is reverted
+	defaultKeygenRetries = 3
+)
+
+func (app *Application) runPairArchiver(ctx context.Context) {
+	ticker := time.NewTicker(pairArchivingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			archived, err := app.Queries.Pairs.Archive(ctx, app.Clock.Now().Add(-app.archiveRetention))
+			if err != nil {
+				app.logger.Error().Err(err).Msg("failed to archive pairs")
+				continue
+			}
+			if len(archived) > 0 {
+				app.logger.Info().Int("count", len(archived)).Msg("pairs archived")
+			}
+		}
+	}
+}
+
+// runMatchReverter periodically puts the matched pairs whose counterparty didn't confirm the wallet in time back to waiting
+func (app *Application) runMatchReverter(ctx context.Context) {
+	ticker := time.NewTicker(matchRevertingInterval)
+	defer ticker.Stop()
+
+	status := domain.PairStatusWalletConformation
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			pairs, err := app.Queries.Pairs.Find(ctx, queries.PairFilter{Status: &status})
+			if err != nil {
+				app.logger.Error().Err(err).Msg("failed to find matched pairs")
+				continue
+			}
+			for _, p := range pairs {
+				_, err := app.Commands.RevertStaleMatch.Handle(ctx, commands.RevertStaleMatch{PairId: p.Id})
+				if errors.Is(err, commands.ErrMatchNotStale) {
+					continue
+				}
+				if err != nil {
+					app.logger.Error().Err(err).Str("pair_id", p.Id).Msg("failed to revert match")
+					continue
+				}
+				app.logger.Info().Str("pair_id", p.Id).Msg("match reverted, pair is waiting again")
+			}
+		}
+	}
+}
+
+// runOverdueEscalation periodically escalates the pairs that stayed in their status longer than the timeouts of their plans
+func (app *Application) runOverdueEscalation(ctx context.Context) {
+	ticker := time.NewTicker(overdueCheckingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, status := range domain.TimeoutStatuses {
+				app.escalateOverduePairs(ctx, status)
+			}
+		}
+	}
+}
+
+func (app *Application) escalateOverduePairs(ctx context.Context, status domain.PairStatus) {
+	pairs, err := app.Queries.Pairs.Find(ctx, queries.PairFilter{Status: &status})
+	if err != nil {
+		app.logger.Error().Err(err).Str("status", string(status)).Msg("failed to find pairs to escalate")
+		return
+	}
+
+	for _, p := range pairs {
+		if p.PlanId == "" {
+			continue
+		}
+		// Only the pairs of the plans with a timeout for the status are loaded
+		plan, err := app.Queries.Plans.Get(ctx, p.PlanId)
+		if err != nil || plan.StatusTimeouts[status] == 0 {
+			continue
+		}
+
+		action, err := app.Commands.EscalateOverdue.Handle(ctx, commands.EscalateOverduePair{PairId: p.Id})
+		if errors.Is(err, commands.ErrPairNotOverdue) {
+			continue
+		}
+		if err != nil {
+			app.logger.Error().Err(err).Str("pair_id", p.Id).Msg("failed to escalate overdue pair")
+			continue
+		}
+		if action == string(domain.OverdueActionEscalated) {
+			app.logger.Warn().Str("pair_id", p.Id).Str("status", string(status)).Msg("overdue pair needs an operator")
+			continue
+		}
+		app.logger.Info().Str("pair_id", p.Id).Str("status", string(status)).Str("action", action).Msg("overdue pair escalated")
+	}
+}
+
+// runKeyRotation wraps the values encrypted under the previous master keys with the current one in the background,
+// until every table is rotated
+func (app *Application) runKeyRotation(ctx context.Context) {
+	rewrapped, err := app.KeyRotator.Rotate(ctx, keyRotationInterval)
+	if err != nil {
+		if ctx.Err() == nil {
+			app.logger.Error().Err(err).Int("rewrapped", rewrapped).Msg("failed to rotate encryption keys")
+		}
+		return
+	}
+	app.logger.Info().Int("rewrapped", rewrapped).Str("key_id", app.cipher.KeyId()).Msg("encryption keys rotated, the previous keys can be retired")
+}
+
+func (app *Application) runRelayPruner(ctx context.Context) {
+	ticker := time.NewTicker(relayPruningInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			pruned, err := app.Relay.Prune(ctx, app.Clock.Now().Add(-relayRetention))
+			if err != nil {
+				app.logger.Error().Err(err).Msg("failed to prune relay messages")
+				continue
+			}
+			if pruned > 0 {
+				app.logger.Info().Int64("count", pruned).Msg("relay messages pruned")
+			}
+		}
+	}
+}
+
+func (app *Application) runDataExportPruner(ctx context.Context) {
+	ticker := time.NewTicker(dataExportPruningInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			pruned, err := app.DataExports.Prune(ctx, app.Clock.Now().Add(-dataExportRetention))
+			if err != nil {
+				app.logger.Error().Err(err).Msg("failed to prune data exports")
+				continue
+			}
+			if pruned > 0 {
+				app.logger.Info().Int64("count", pruned).Msg("data exports pruned")
+			}
+		}
+	}
+}
+
+// runSettlements periodically settles the withdrawn pairs, the withdrawals that aren't executed yet are retried on the next run
+func (app *Application) runSettlements(ctx context.Context) {
+	ticker := time.NewTicker(settlementInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			pairs, err := app.Queries.Pairs.Unsettled(ctx)
+			if err != nil {
+				app.logger.Error().Err(err).Msg("failed to find unsettled pairs")
+				continue
+			}
+			for _, p := range pairs {
+				_, err := app.Commands.SettleWithdrawal.Handle(ctx, commands.SettleWithdrawal{PairId: p.Id})
+				if errors.Is(err, commands.ErrSettlementPending) {
+					continue
+				}
+				if err != nil {
+					app.logger.Error().Err(err).Str("pair_id", p.Id).Msg("failed to settle withdrawal")
+					continue
+				}
+				app.logger.Info().Str("pair_id", p.Id).Msg("withdrawal settled")
+			}
+		}
+	}
+}
+
+// runTxTracker periodically checks the transactions of the pairs on chain until they're final
+func (app *Application) runTxTracker(ctx context.Context) {
+	ticker := time.NewTicker(txTrackingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			pairs, err := app.Queries.Pairs.Tracking(ctx)
+			if err != nil {
+				app.logger.Error().Err(err).Msg("failed to find pairs with tracked transactions")
+				continue
+			}
+			for _, p := range pairs {
+				if _, err := app.Commands.TrackPairTxs.Handle(ctx, commands.TrackPairTxs{PairId: p.Id}); err != nil {
+					app.logger.Error().Err(err).Str("pair_id", p.Id).Msg("failed to track transactions")
+				}
+			}
+		}
+	}
+}
+
 type Commands struct {
 	CreateNewPlan     commands.CreateNewPlanHandler
+	PausePlan         commands.PausePlanHandler
+	ResumePlan        commands.ResumePlanHandler
+	SetPlanTimeouts   commands.SetPlanStatusTimeoutsHandler
+	SetPlanAssurances commands.SetPlanAssurancePoliciesHandler
 	CreateOrMatchPair commands.CreateOrMatchPairHandler
 	ConfirmPairWallet commands.ConfirmPairWalletHandler
 	SetPairAssurances commands.SetPairAssurancesHandler
+	ConfirmAssurances commands.ConfirmAssurancesHandler
+	PairBatch         commands.PairBatchHandler
 	AddDeposit        commands.AddDepositHandler
 	SignWithdrawal    commands.SignWithdrawalHandler
 	SubmitLP          commands.SubmitLPHandler
 	SubmitWithdrawal  commands.SubmitWithdrawalHandler
+	RequestRefund     commands.RequestRefundHandler
+	SettleWithdrawal  commands.SettleWithdrawalHandler
+	TrackPairTxs      commands.TrackPairTxsHandler
+	ForcePairStatus   commands.ForcePairStatusHandler
+	RevertStaleMatch  commands.RevertStaleMatchHandler
+	EscalateOverdue   commands.EscalateOverduePairHandler
+
+	ReportKeygenFailure commands.ReportKeygenFailureHandler
+
+	SignSaversWithdrawal   commands.SignSaversWithdrawalHandler
+	SubmitSavers           commands.SubmitSaversHandler
+	SubmitSaversWithdrawal commands.SubmitSaversWithdrawalHandler
+
+	ProposeEarlyWithdrawal commands.ProposeEarlyWithdrawalHandler
+	AcceptEarlyWithdrawal  commands.AcceptEarlyWithdrawalHandler
+	ProposeExtension       commands.ProposeExtensionHandler
+	AcceptExtension        commands.AcceptExtensionHandler
+
+	PostPairMessage commands.PostPairMessageHandler
+
+	UpdateNotificationSettings commands.UpdateNotificationSettingsHandler
+
+	RegisterParticipant    commands.RegisterParticipantHandler
+	LinkParticipantAddress commands.LinkParticipantAddressHandler
+	BlockAddress           commands.BlockAddressHandler
+	RedactParticipantData  commands.RedactParticipantDataHandler
+
+	EscrowMediatorShare   commands.EscrowMediatorShareHandler
+	RequestShareRecovery  commands.RequestShareRecoveryHandler
+	DecideShareRecovery   commands.DecideShareRecoveryHandler
+	RetrieveMediatorShare commands.RetrieveMediatorShareHandler
 }
 
 type Queries struct {
-	Plans *queries.PlansQuery
-	Pairs *queries.PairsQuery
+	Plans                *queries.PlansQuery
+	Pairs                *queries.PairsQuery
+	Reputation           *queries.ReputationQuery
+	Stats                *queries.StatsQuery
+	PlanStats            *queries.PlanStatsQuery
+	PairsStats           *queries.PairsStatsQuery
+	NotificationSettings *queries.NotificationSettingsQuery
+	PairMessages         *queries.PairMessagesQuery
+	Participants         *queries.ParticipantsQuery
+	Escrows              *queries.EscrowsQuery
+	Positions            *queries.PositionsQuery
+}
+
+// projections returns the projections of the queries by name
+func (q Queries) projections() map[string]common.Projection {
+	return map[string]common.Projection{
+		q.Plans.Name():                q.Plans,
+		q.Pairs.Name():                q.Pairs,
+		q.Reputation.Name():           q.Reputation,
+		q.Stats.Name():                q.Stats,
+		q.PlanStats.Name():            q.PlanStats,
+		q.PairsStats.Name():           q.PairsStats,
+		q.NotificationSettings.Name(): q.NotificationSettings,
+		q.PairMessages.Name():         q.PairMessages,
+		q.Participants.Name():         q.Participants,
+		q.Escrows.Name():              q.Escrows,
+	}
 }
 
-func newQueries(db *sql.DB, store *sqles.SQL) (Queries, error) {
+func newQueries(db *common.DB, store *sqles.SQL, oracle queries.PriceOracle, positions queries.PositionSource, cipher *common.Cipher) (Queries, error) {
 	plans, err := queries.NewPlansQuery(db, store)
 	if err != nil {
 		return Queries{}, fmt.Errorf("failed to create plans query: %w", err)
 	}
 
-	pairs, err := queries.NewPairsQuery(db, store)
+	pairs, err := queries.NewPairsQuery(db, store, cipher)
 	if err != nil {
 		return Queries{}, fmt.Errorf("failed to create pairs query: %w", err)
 	}
 
+	reputation, err := queries.NewReputationQuery(db, store)
+	if err != nil {
+		return Queries{}, fmt.Errorf("failed to create reputation query: %w", err)
+	}
+
+	stats, err := queries.NewStatsQuery(db, store, oracle)
+	if err != nil {
+		return Queries{}, fmt.Errorf("failed to create stats query: %w", err)
+	}
+
+	planStats, err := queries.NewPlanStatsQuery(db, store)
+	if err != nil {
+		return Queries{}, fmt.Errorf("failed to create plan stats query: %w", err)
+	}
+
+	pairsStats, err := queries.NewPairsStatsQuery(db, store, oracle)
+	if err != nil {
+		return Queries{}, fmt.Errorf("failed to create pairs stats query: %w", err)
+	}
+
+	notificationSettings, err := queries.NewNotificationSettingsQuery(db, store)
+	if err != nil {
+		return Queries{}, fmt.Errorf("failed to create notification settings query: %w", err)
+	}
+
+	pairMessages, err := queries.NewPairMessagesQuery(db, store)
+	if err != nil {
+		return Queries{}, fmt.Errorf("failed to create pair messages query: %w", err)
+	}
+
+	participants, err := queries.NewParticipantsQuery(db, store)
+	if err != nil {
+		return Queries{}, fmt.Errorf("failed to create participants query: %w", err)
+	}
+
+	escrows, err := queries.NewEscrowsQuery(db, store)
+	if err != nil {
+		return Queries{}, fmt.Errorf("failed to create escrows query: %w", err)
+	}
+
 	return Queries{
-		Plans: plans,
-		Pairs: pairs,
+		Plans:                plans,
+		Pairs:                pairs,
+		Reputation:           reputation,
+		Stats:                stats,
+		PlanStats:            planStats,
+		PairsStats:           pairsStats,
+		NotificationSettings: notificationSettings,
+		PairMessages:         pairMessages,
+		Participants:         participants,
+		Escrows:              escrows,
+		Positions:            queries.NewPositionsQuery(positions, oracle),
 	}, nil
 }
@@ -0,0 +1,129 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/co-defi/api-server/common"
+	"github.com/huandu/go-sqlbuilder"
+)
+
+// Entry is the record of an authenticated mutating request
+type Entry struct {
+	Id          int64     `json:"id"`
+	Address     string    `json:"address"`
+	Method      string    `json:"method"`
+	Route       string    `json:"route"`
+	PayloadHash string    `json:"payload_hash"`
+	Status      int       `json:"status"`
+	ErrorCode   string    `json:"error_code,omitempty"`
+	IP          string    `json:"ip"`
+	Timestamp   time.Time `json:"timestamp"`
+}
+
+// Log is the append-only audit log of the authenticated actions.
+// It is not rebuilt from the events, so it is kept out of the projections and survives resetting them.
+type Log struct {
+	db *common.DB
+}
+
+// NewLog creates a new Log and its table
+func NewLog(db *common.DB) (*Log, error) {
+	l := Log{db: db}
+	if err := l.createTable(); err != nil {
+		return nil, fmt.Errorf("failed to create audit_log table: %w", err)
+	}
+
+	return &l, nil
+}
+
+func (l *Log) createTable() error {
+	_, err := l.db.Write.Exec(`create table if not exists audit_log (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		address TEXT,
+		method TEXT,
+		route TEXT,
+		payload_hash TEXT,
+		status INTEGER,
+		error_code TEXT,
+		ip TEXT,
+		timestamp TEXT
+	);
+	create index if not exists audit_log_address on audit_log (address);
+	create trigger if not exists audit_log_no_update before update on audit_log
+	begin
+		select raise(abort, 'audit log is append-only');
+	end;
+	create trigger if not exists audit_log_no_delete before delete on audit_log
+	begin
+		select raise(abort, 'audit log is append-only');
+	end;`)
+	return err
+}
+
+// Record appends the entry to the log
+func (l *Log) Record(ctx context.Context, e Entry) error {
+	_, err := l.db.Write.ExecContext(ctx, `insert into audit_log (address, method, route, payload_hash, status, error_code, ip, timestamp) values (?, ?, ?, ?, ?, ?, ?, ?);`,
+		e.Address, e.Method, e.Route, e.PayloadHash, e.Status, e.ErrorCode, e.IP, e.Timestamp.UTC().Format(time.RFC3339))
+	return err
+}
+
+// Filter narrows down the entries returned by Find, zero values are ignored
+type Filter struct {
+	Address string
+	Route   string
+	Since   time.Time
+	Until   time.Time
+	Limit   int
+}
+
+const defaultFindLimit = 100
+
+// Find returns the entries matching the filter, the latest first
+func (l *Log) Find(ctx context.Context, f Filter) ([]Entry, error) {
+	b := sqlbuilder.NewSelectBuilder()
+	b.SetFlavor(sqlbuilder.SQLite)
+	b.Select("id", "address", "method", "route", "payload_hash", "status", "error_code", "ip", "timestamp").From("audit_log")
+	if f.Address != "" {
+		b.Where(b.Equal("address", f.Address))
+	}
+	if f.Route != "" {
+		b.Where(b.Equal("route", f.Route))
+	}
+	if !f.Since.IsZero() {
+		b.Where(fmt.Sprintf("datetime(timestamp) >= datetime(%s)", b.Var(f.Since.UTC().Format(time.RFC3339))))
+	}
+	if !f.Until.IsZero() {
+		b.Where(fmt.Sprintf("datetime(timestamp) <= datetime(%s)", b.Var(f.Until.UTC().Format(time.RFC3339))))
+	}
+	if f.Limit <= 0 {
+		f.Limit = defaultFindLimit
+	}
+	b.OrderBy("id").Desc().Limit(f.Limit)
+
+	query, args := b.Build()
+	rows, err := l.db.Read.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query audit log: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []Entry{}
+	for rows.Next() {
+		var (
+			e         Entry
+			timestamp string
+		)
+		if err := rows.Scan(&e.Id, &e.Address, &e.Method, &e.Route, &e.PayloadHash, &e.Status, &e.ErrorCode, &e.IP, &timestamp); err != nil {
+			return nil, fmt.Errorf("failed to scan audit log entry: %w", err)
+		}
+		e.Timestamp, err = time.Parse(time.RFC3339, timestamp)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse audit log timestamp: %w", err)
+		}
+		entries = append(entries, e)
+	}
+
+	return entries, rows.Err()
+}
@@ -0,0 +1,68 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/co-defi/api-server/common"
+	"github.com/hallgren/eventsourcing"
+	"github.com/hallgren/eventsourcing/core"
+)
+
+// VerifyEvents replays every event of the store and returns how many there are. It fails on the events that don't belong
+// to a registered aggregate or can't be decoded, and on the aggregates whose versions have gaps.
+// The encrypted events are decoded with the cipher, see WithEncryption.
+func VerifyEvents(db *sql.DB, cipher *common.Cipher) (int, error) {
+	repo, store, _, _, err := createEventRepository(db, cipher)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create event repository: %w", err)
+	}
+
+	next := core.Version(1)
+	versions := make(map[string]eventsourcing.Version)
+	count := 0
+	p := repo.Projections.Projection(func() (core.Iterator, error) {
+		return store.All(next, exportBatchSize)
+	}, func(event eventsourcing.Event) error {
+		key := event.AggregateType() + ":" + event.AggregateID()
+		if event.Version() != versions[key]+1 {
+			return fmt.Errorf("event %d of %s has version %d, %d expected", event.GlobalVersion(), key, event.Version(), versions[key]+1)
+		}
+		versions[key] = event.Version()
+		next = core.Version(event.GlobalVersion()) + 1
+		count++
+		return nil
+	})
+	p.Strict = true
+
+	for {
+		ran, result := p.RunOnce()
+		if result.Error != nil {
+			return count, result.Error
+		}
+		if !ran {
+			return count, nil
+		}
+	}
+}
+
+// TruncateEvents deletes the events stored after until, to restore the state of that time, and returns how many were deleted.
+// The projections are reset along, so they are rebuilt from the remaining events.
+func TruncateEvents(ctx context.Context, db *sql.DB, until time.Time) (int64, error) {
+	res, err := db.ExecContext(ctx, `delete from events where datetime(timestamp) > datetime(?);`, until.UTC().Format(time.RFC3339))
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete events: %w", err)
+	}
+	deleted, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	if err := common.ResetAllProjections(db); err != nil {
+		return deleted, fmt.Errorf("failed to reset projections: %w", err)
+	}
+
+	return deleted, nil
+}
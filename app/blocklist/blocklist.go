@@ -0,0 +1,207 @@
+package blocklist
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/co-defi/api-server/common"
+	"github.com/co-defi/api-server/domain"
+)
+
+// Entry is a blocked address along with why and by whom it was blocked
+type Entry struct {
+	Address   domain.Address `json:"address"`
+	Reason    string         `json:"reason"`
+	BlockedBy string         `json:"blocked_by"`
+	BlockedAt time.Time      `json:"blocked_at"`
+}
+
+// Action is a change of the blocklist
+type Action string
+
+const (
+	ActionBlock   Action = "block"
+	ActionUnblock Action = "unblock"
+)
+
+// Change is the record of an address blocked or unblocked, kept in the append-only history of the blocklist
+type Change struct {
+	Id        int64          `json:"id"`
+	Address   domain.Address `json:"address"`
+	Action    Action         `json:"action"`
+	Reason    string         `json:"reason"`
+	Operator  string         `json:"operator"`
+	Timestamp time.Time      `json:"timestamp"`
+}
+
+// Blocklist holds the addresses of the sanctioned or abusive participants, which can't authenticate nor act on pairs.
+// It is managed by the operators rather than rebuilt from the events, so it is kept out of the projections like the audit log.
+type Blocklist struct {
+	db    *common.DB
+	clock common.Clock
+}
+
+// NewBlocklist creates a new Blocklist and its tables
+func NewBlocklist(db *common.DB, clock common.Clock) (*Blocklist, error) {
+	_, err := db.Write.Exec(`create table if not exists blocklist (
+		address TEXT PRIMARY KEY,
+		reason TEXT,
+		blocked_by TEXT,
+		blocked_at TEXT
+	);
+	create table if not exists blocklist_changes (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		address TEXT,
+		action TEXT,
+		reason TEXT,
+		operator TEXT,
+		timestamp TEXT
+	);
+	create index if not exists blocklist_changes_address on blocklist_changes (address);
+	create trigger if not exists blocklist_changes_no_update before update on blocklist_changes
+	begin
+		select raise(abort, 'blocklist changes are append-only');
+	end;
+	create trigger if not exists blocklist_changes_no_delete before delete on blocklist_changes
+	begin
+		select raise(abort, 'blocklist changes are append-only');
+	end;`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create blocklist tables: %w", err)
+	}
+
+	return &Blocklist{db: db, clock: clock}, nil
+}
+
+// normalize returns the address as it's stored, the EVM addresses are case-insensitive
+func normalize(address domain.Address) domain.Address {
+	if strings.HasPrefix(string(address), "0x") {
+		return domain.Address(strings.ToLower(string(address)))
+	}
+	return address
+}
+
+// Block blocks the address for the reason on behalf of the operator, blocking it again updates the reason
+func (b *Blocklist) Block(ctx context.Context, address domain.Address, reason, operator string) error {
+	address = normalize(address)
+	now := b.clock.Now().UTC().Format(time.RFC3339)
+
+	return b.change(ctx, address, ActionBlock, reason, operator, now, `insert into blocklist (address, reason, blocked_by, blocked_at) values (?, ?, ?, ?)
+		on conflict (address) do update set reason = excluded.reason, blocked_by = excluded.blocked_by, blocked_at = excluded.blocked_at;`,
+		address, reason, operator, now)
+}
+
+// Unblock unblocks the address for the reason on behalf of the operator, it does nothing when the address isn't blocked
+func (b *Blocklist) Unblock(ctx context.Context, address domain.Address, reason, operator string) error {
+	address = normalize(address)
+	now := b.clock.Now().UTC().Format(time.RFC3339)
+
+	return b.change(ctx, address, ActionUnblock, reason, operator, now, `delete from blocklist where address = ?;`, address)
+}
+
+// change applies the statement to the blocklist and records the change in its history in the same transaction
+func (b *Blocklist) change(ctx context.Context, address domain.Address, action Action, reason, operator, now, statement string, args ...any) error {
+	tx, err := b.db.Write.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, statement, args...)
+	if err != nil {
+		return fmt.Errorf("failed to %s address: %w", action, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil
+	}
+	if _, err := tx.ExecContext(ctx, `insert into blocklist_changes (address, action, reason, operator, timestamp) values (?, ?, ?, ?, ?);`,
+		address, action, reason, operator, now); err != nil {
+		return fmt.Errorf("failed to record blocklist change: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// Get returns the entry of the address, nil when it isn't blocked
+func (b *Blocklist) Get(ctx context.Context, address domain.Address) (*Entry, error) {
+	e, err := scanEntry(b.db.Read.QueryRowContext(ctx, `select address, reason, blocked_by, blocked_at from blocklist where address = ?;`, normalize(address)))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	return e, err
+}
+
+// IsBlocked tells whether the address is blocked
+func (b *Blocklist) IsBlocked(ctx context.Context, address domain.Address) (bool, error) {
+	e, err := b.Get(ctx, address)
+	return e != nil, err
+}
+
+// All returns the blocked addresses, the latest blocked first
+func (b *Blocklist) All(ctx context.Context) ([]Entry, error) {
+	rows, err := b.db.Read.QueryContext(ctx, `select address, reason, blocked_by, blocked_at from blocklist order by datetime(blocked_at) desc, address;`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query blocklist: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []Entry{}
+	for rows.Next() {
+		e, err := scanEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, *e)
+	}
+
+	return entries, rows.Err()
+}
+
+// Changes returns the history of the blocklist, of the address only when not empty, the latest first
+func (b *Blocklist) Changes(ctx context.Context, address domain.Address) ([]Change, error) {
+	query, args := `select id, address, action, reason, operator, timestamp from blocklist_changes order by id desc;`, []any{}
+	if address != "" {
+		query, args = `select id, address, action, reason, operator, timestamp from blocklist_changes where address = ? order by id desc;`, []any{normalize(address)}
+	}
+
+	rows, err := b.db.Read.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query blocklist changes: %w", err)
+	}
+	defer rows.Close()
+
+	changes := []Change{}
+	for rows.Next() {
+		var (
+			c         Change
+			timestamp string
+		)
+		if err := rows.Scan(&c.Id, &c.Address, &c.Action, &c.Reason, &c.Operator, &timestamp); err != nil {
+			return nil, fmt.Errorf("failed to scan blocklist change: %w", err)
+		}
+		c.Timestamp, _ = time.Parse(time.RFC3339, timestamp)
+		changes = append(changes, c)
+	}
+
+	return changes, rows.Err()
+}
+
+func scanEntry(row interface{ Scan(...any) error }) (*Entry, error) {
+	var (
+		e         Entry
+		blockedAt string
+	)
+	if err := row.Scan(&e.Address, &e.Reason, &e.BlockedBy, &blockedAt); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to scan blocklist entry: %w", err)
+	}
+	e.BlockedAt, _ = time.Parse(time.RFC3339, blockedAt)
+
+	return &e, nil
+}
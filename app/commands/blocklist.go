@@ -0,0 +1,83 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/co-defi/api-server/common"
+	"github.com/co-defi/api-server/domain"
+)
+
+// Blocklist tells whether an address is blocked from acting on the pairs
+type Blocklist interface {
+	IsBlocked(ctx context.Context, address domain.Address) (bool, error)
+}
+
+var ErrAddressBlocked = common.NewError("address_blocked", "address is blocked from using the platform")
+
+// participantCommand is a command a participant issues on their own behalf
+type participantCommand interface {
+	participant() domain.Address
+}
+
+// RejectBlocked rejects the commands of the blocked participants before handing the other ones to the handler.
+// The commands issued by the workers on behalf of no participant go through.
+func RejectBlocked[C participantCommand](handler common.CommandHandler[C], blocklist Blocklist) common.CommandHandler[C] {
+	if blocklist == nil {
+		return handler
+	}
+
+	return &blockedParticipantsFilter[C]{handler: handler, blocklist: blocklist}
+}
+
+type blockedParticipantsFilter[C participantCommand] struct {
+	handler   common.CommandHandler[C]
+	blocklist Blocklist
+}
+
+// Handle implements the command handler interface
+func (f *blockedParticipantsFilter[C]) Handle(ctx context.Context, cmd C) (string, error) {
+	if address := cmd.participant(); address != "" {
+		blocked, err := f.blocklist.IsBlocked(ctx, address)
+		if err != nil {
+			return "", fmt.Errorf("failed to check blocklist: %w", err)
+		}
+		if blocked {
+			return "", ErrAddressBlocked
+		}
+	}
+
+	return f.handler.Handle(ctx, cmd)
+}
+
+func (cmd CreateOrMatchPair) participant() domain.Address      { return cmd.ParticipantAddress }
+func (cmd ConfirmPairWallet) participant() domain.Address      { return cmd.ParticipantAddress }
+func (cmd SetPairAssurances) participant() domain.Address      { return cmd.ParticipantAddress }
+func (cmd ConfirmAssurances) participant() domain.Address      { return cmd.ParticipantAddress }
+func (cmd AddDeposit) participant() domain.Address             { return cmd.ParticipantAddress }
+func (cmd SignWithdrawal) participant() domain.Address         { return cmd.ParticipantAddress }
+func (cmd SubmitLP) participant() domain.Address               { return cmd.ParticipantAddress }
+func (cmd SignSaversWithdrawal) participant() domain.Address   { return cmd.ParticipantAddress }
+func (cmd SubmitSavers) participant() domain.Address           { return cmd.ParticipantAddress }
+func (cmd RequestRefund) participant() domain.Address          { return cmd.ParticipantAddress }
+func (cmd ProposeEarlyWithdrawal) participant() domain.Address { return cmd.ParticipantAddress }
+func (cmd AcceptEarlyWithdrawal) participant() domain.Address  { return cmd.ParticipantAddress }
+func (cmd ProposeExtension) participant() domain.Address       { return cmd.ParticipantAddress }
+func (cmd AcceptExtension) participant() domain.Address        { return cmd.ParticipantAddress }
+func (cmd PostPairMessage) participant() domain.Address        { return cmd.ParticipantAddress }
+func (cmd PairBatch) participant() domain.Address              { return cmd.ParticipantAddress }
+func (cmd ReportKeygenFailure) participant() domain.Address    { return cmd.ParticipantAddress }
+
+func (cmd SubmitWithdrawal) participant() domain.Address {
+	if cmd.ParticipantAddress == nil {
+		return ""
+	}
+	return *cmd.ParticipantAddress
+}
+
+func (cmd SubmitSaversWithdrawal) participant() domain.Address {
+	if cmd.ParticipantAddress == nil {
+		return ""
+	}
+	return *cmd.ParticipantAddress
+}
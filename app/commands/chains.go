@@ -0,0 +1,250 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"math/big"
+
+	"github.com/co-defi/api-server/common"
+	"github.com/co-defi/api-server/domain"
+)
+
+// ErrTxMismatch is returned by the chain adapters when a transaction exists on chain but doesn't do what is expected from it
+var ErrTxMismatch = errors.New("transaction does not match the expected one")
+
+// ErrChainUnavailable is wrapped by the errors of the chain adapters when their node can't be reached or keeps failing,
+// so the commands fail with it instead of an internal error
+var ErrChainUnavailable = common.NewError("chain_unavailable", "chain can't be reached to verify the transaction, try again later")
+
+// unavailableChain degrades the verification of a transaction of the asset whose chain can't be reached or has no verifier: the transaction is
+// accepted unverified when acceptUnverified is set, it's rejected with ErrChainUnavailable otherwise
+func unavailableChain(asset domain.Asset, acceptUnverified bool) (bool, error) {
+	if acceptUnverified {
+		return true, nil
+	}
+
+	info, _ := domain.LookupAsset(asset)
+	return false, ErrChainUnavailable.IncludeMeta(map[string]interface{}{"chain": info.Chain})
+}
+
+// LPTx describes the liquidity providing transaction a pair is expected to have broadcasted for one of its assets
+type LPTx struct {
+	Asset  domain.Asset
+	TxHash domain.TxHash
+	From   domain.Address
+	Pool   domain.Asset
+}
+
+// LPVerifier verifies on chain that a transaction added liquidity from the pair's multisig address to the expected pool
+type LPVerifier interface {
+	VerifyLP(ctx context.Context, tx LPTx) error
+}
+
+// LPVerifiers holds the LP verifiers by the chain they are able to verify, the transactions of the chains without a verifier are handled as the ones of the unavailable chains
+type LPVerifiers map[string]LPVerifier
+
+func (v LPVerifiers) forAsset(asset domain.Asset) (LPVerifier, bool) {
+	info, ok := domain.LookupAsset(asset)
+	if !ok {
+		return nil, false
+	}
+
+	verifier, ok := v[info.Chain]
+	return verifier, ok
+}
+
+// SaversTx describes the transaction a savers pair is expected to have broadcasted to deposit one of its assets into its Savers vault
+type SaversTx struct {
+	Asset  domain.Asset
+	TxHash domain.TxHash
+	From   domain.Address
+	Vault  domain.Asset
+}
+
+// SaversVerifier verifies on chain that a transaction deposited the asset from the pair's wallet into its Savers vault
+type SaversVerifier interface {
+	VerifySavers(ctx context.Context, tx SaversTx) error
+}
+
+// SaversVerifiers holds the savers verifiers by the chain of the assets they are able to verify, the transactions of the chains without a verifier are handled as the ones of the unavailable chains
+type SaversVerifiers map[string]SaversVerifier
+
+func (v SaversVerifiers) forAsset(asset domain.Asset) (SaversVerifier, bool) {
+	info, ok := domain.LookupAsset(asset)
+	if !ok {
+		return nil, false
+	}
+
+	verifier, ok := v[info.Chain]
+	return verifier, ok
+}
+
+// DepositTx describes the transfer a participant is expected to have made from their address to the pair's wallet
+type DepositTx struct {
+	Asset  domain.Asset
+	TxHash domain.TxHash
+	From   domain.Address
+	To     domain.Address
+	Amount *big.Int
+}
+
+// DepositVerifier verifies on chain that a transaction transferred the amount of the asset to the pair's wallet
+type DepositVerifier interface {
+	VerifyDeposit(ctx context.Context, tx DepositTx) error
+}
+
+// DepositVerifiers holds the deposit verifiers by the chain they are able to verify, the transactions of the chains without a verifier are handled as the ones of the unavailable chains
+type DepositVerifiers map[string]DepositVerifier
+
+func (v DepositVerifiers) forAsset(asset domain.Asset) (DepositVerifier, bool) {
+	info, ok := domain.LookupAsset(asset)
+	if !ok {
+		return nil, false
+	}
+
+	verifier, ok := v[info.Chain]
+	return verifier, ok
+}
+
+// DecodedTx is the chain agnostic view of an unsigned transaction pre-signed by a participant
+type DecodedTx struct {
+	ChainId string
+	Nonce   int
+	From    domain.Address
+	// To is the recipient of the transferred funds, it is empty for transactions that only carry a memo
+	To     domain.Address
+	Asset  domain.Asset
+	Amount *big.Int
+	Memo   string
+	// GasPrice is the most the transaction pays per unit of gas, it's nil on the chains charging a flat fee
+	GasPrice *big.Int
+}
+
+// TxDecoder decodes the transactions pre-signed by the participants.
+// Transactions that are malformed or meant for another network are rejected with ErrTxMismatch.
+type TxDecoder interface {
+	DecodeTx(tx domain.SignedTx) (DecodedTx, error)
+}
+
+// TxDecoders holds the transaction decoders by the chain they are able to decode
+type TxDecoders map[string]TxDecoder
+
+func (d TxDecoders) forAsset(asset domain.Asset) (TxDecoder, bool) {
+	info, ok := domain.LookupAsset(asset)
+	if !ok {
+		return nil, false
+	}
+
+	decoder, ok := d[info.Chain]
+	return decoder, ok
+}
+
+// WalletDeriver derives the address of a pair's multisig wallet on a chain from the public key the participants generated
+// together and the chain code issued to the pair, i.e. the address only the participants can sign for together
+type WalletDeriver interface {
+	DeriveAddress(publicKey, hexChainCode string) (domain.Address, error)
+}
+
+// WalletDerivers holds the wallet derivers by the chain they derive the addresses of, the addresses of the chains without a deriver are trusted as is
+type WalletDerivers map[string]WalletDeriver
+
+func (d WalletDerivers) forAsset(asset domain.Asset) (WalletDeriver, bool) {
+	info, ok := domain.LookupAsset(asset)
+	if !ok {
+		return nil, false
+	}
+
+	deriver, ok := d[info.Chain]
+	return deriver, ok
+}
+
+// TxBroadcaster broadcasts the transactions pre-signed by the participants to their chain and returns their hash
+type TxBroadcaster interface {
+	Broadcast(ctx context.Context, tx domain.SignedTx) (domain.TxHash, error)
+}
+
+// TxBroadcasters holds the transaction broadcasters by the chain they broadcast to
+type TxBroadcasters map[string]TxBroadcaster
+
+func (b TxBroadcasters) forAsset(asset domain.Asset) (TxBroadcaster, bool) {
+	info, ok := domain.LookupAsset(asset)
+	if !ok {
+		return nil, false
+	}
+
+	broadcaster, ok := b[info.Chain]
+	return broadcaster, ok
+}
+
+// ErrTxPending is returned by the chain adapters when a transaction isn't executed yet
+var ErrTxPending = errors.New("transaction is not executed yet")
+
+// WithdrawalTx describes the transaction a pair is expected to have broadcasted to withdraw its liquidity from the pool
+type WithdrawalTx struct {
+	TxHash domain.TxHash
+	From   domain.Address
+	Pool   domain.Asset
+}
+
+// WithdrawalVerifier confirms on chain that a withdrawal executed and returns the amounts it paid out by asset.
+// Withdrawals that aren't executed yet are reported with ErrTxPending and the failed ones with ErrTxMismatch.
+type WithdrawalVerifier interface {
+	VerifyWithdrawal(ctx context.Context, tx WithdrawalTx) (map[domain.Asset]domain.TokenAmount, error)
+}
+
+// WithdrawalVerifiers holds the withdrawal verifiers by the chain they are able to verify
+type WithdrawalVerifiers map[string]WithdrawalVerifier
+
+func (v WithdrawalVerifiers) forAsset(asset domain.Asset) (WithdrawalVerifier, bool) {
+	info, ok := domain.LookupAsset(asset)
+	if !ok {
+		return nil, false
+	}
+
+	verifier, ok := v[info.Chain]
+	return verifier, ok
+}
+
+// TxConfirmation is the state of a transaction on its chain
+type TxConfirmation struct {
+	// Mined tells if the transaction is included in a block, the ones waiting in the mempool aren't
+	Mined bool
+	// Failed tells if the transaction was executed but failed, Reason explains why
+	Failed        bool
+	Reason        string
+	Confirmations int
+	BlockHash     string
+}
+
+// TxStatusChecker looks up the transactions on chain along with the number of blocks they are mined under
+type TxStatusChecker interface {
+	CheckTx(ctx context.Context, hash domain.TxHash) (TxConfirmation, error)
+}
+
+// TxStatusCheckers holds the transaction status checkers by the chain they check the transactions of
+type TxStatusCheckers map[string]TxStatusChecker
+
+func (c TxStatusCheckers) forAsset(asset domain.Asset) (TxStatusChecker, bool) {
+	info, ok := domain.LookupAsset(asset)
+	if !ok {
+		return nil, false
+	}
+
+	checker, ok := c[info.Chain]
+	return checker, ok
+}
+
+// requiredConfirmations holds the number of blocks a transaction must be mined under to be confirmed by chain,
+// the chains missing have instant finality
+var requiredConfirmations = map[string]int{
+	"ETH": 12,
+	"BTC": 3,
+}
+
+func requiredConfirmationsOf(asset domain.Asset) int {
+	info, _ := domain.LookupAsset(asset)
+	if n, ok := requiredConfirmations[info.Chain]; ok {
+		return n
+	}
+	return 1
+}
@@ -0,0 +1,39 @@
+package commands
+
+import (
+	"context"
+
+	"github.com/co-defi/api-server/common"
+	"github.com/co-defi/api-server/domain"
+)
+
+// ComplianceChecker requires the participants to be verified, e.g. by a KYC provider, before they can invest
+type ComplianceChecker interface {
+	Require(ctx context.Context, address domain.Address) error
+}
+
+// RequireCompliance hands the commands to the handler only once their participant passed the compliance checks.
+// The participant is checked before the handler so a slow provider doesn't hold the locks of the handler.
+func RequireCompliance[C participantCommand](handler common.CommandHandler[C], checker ComplianceChecker) common.CommandHandler[C] {
+	if checker == nil {
+		return handler
+	}
+
+	return &complianceFilter[C]{handler: handler, checker: checker}
+}
+
+type complianceFilter[C participantCommand] struct {
+	handler common.CommandHandler[C]
+	checker ComplianceChecker
+}
+
+// Handle implements the command handler interface
+func (f *complianceFilter[C]) Handle(ctx context.Context, cmd C) (string, error) {
+	if address := cmd.participant(); address != "" {
+		if err := f.checker.Require(ctx, address); err != nil {
+			return "", err
+		}
+	}
+
+	return f.handler.Handle(ctx, cmd)
+}
@@ -0,0 +1,253 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/co-defi/api-server/common"
+	"github.com/co-defi/api-server/domain"
+	"github.com/google/uuid"
+	"github.com/hallgren/eventsourcing"
+)
+
+// shareRecoveryTTL is how long the operator of an approved recovery has to retrieve the key share
+const shareRecoveryTTL = 24 * time.Hour
+
+var (
+	ErrEscrowNotFound                = common.NewError("escrow_not_found", "no mediator key share in escrow for the pair")
+	ErrShareAlreadyEscrowed          = common.NewError("share_already_escrowed", "mediator key share of the pair is already in escrow")
+	ErrEscrowUnavailable             = common.NewError("escrow_unavailable", "mediator key escrow requires encryption at rest")
+	ErrShareRecoveryNotFound         = common.NewError("share_recovery_not_found", "share recovery not found")
+	ErrShareRecoveryNotPending       = common.NewError("share_recovery_not_pending", "share recovery is already decided")
+	ErrShareRecoveryNotApproved      = common.NewError("share_recovery_not_approved", "share recovery is not approved")
+	ErrShareRecoveryExpired          = common.NewError("share_recovery_expired", "share recovery approval has expired")
+	ErrShareRecoverySelfApproval     = common.NewError("share_recovery_self_approval", "share recovery must be decided by another operator")
+	ErrShareRecoveryOperatorMismatch = common.NewError("share_recovery_operator_mismatch", "share can only be retrieved by the operator who requested the recovery")
+)
+
+// getEscrow returns the escrow of the key share of the mediator of the pair
+func getEscrow(ctx context.Context, repo *eventsourcing.EventRepository, pairId string) (*domain.MediatorKeyEscrow, error) {
+	e := domain.MediatorKeyEscrow{}
+	if err := repo.GetWithContext(ctx, pairId, &e); err != nil {
+		if err == eventsourcing.ErrAggregateNotFound {
+			return nil, ErrEscrowNotFound
+		}
+		return nil, fmt.Errorf("failed to get escrow: %w", err)
+	}
+
+	return &e, nil
+}
+
+// getShareRecovery returns the recovery of the escrow
+func getShareRecovery(e *domain.MediatorKeyEscrow, recoveryId string) (*domain.ShareRecovery, error) {
+	r, ok := e.Recoveries[recoveryId]
+	if !ok {
+		return nil, ErrShareRecoveryNotFound
+	}
+
+	return r, nil
+}
+
+// EscrowMediatorShare is a command for an operator to generate the key share of the mediator of a pair and put it in escrow
+type EscrowMediatorShare struct {
+	PairId   string `json:"pair_id" validate:"required,uuid4"`
+	Operator string `json:"operator" validate:"required"`
+}
+
+// EscrowMediatorShareHandler is a command handler for EscrowMediatorShare, it returns the id of the pair
+type EscrowMediatorShareHandler common.CommandHandler[EscrowMediatorShare]
+
+type escrowMediatorShareHandler struct {
+	repo *eventsourcing.EventRepository
+	// encrypted tells whether the events are encrypted at rest, the shares are never stored in plaintext
+	encrypted bool
+}
+
+// NewEscrowMediatorShareHandler creates a new EscrowMediatorShareHandler, the shares are only escrowed when the events are encrypted at rest
+func NewEscrowMediatorShareHandler(repo *eventsourcing.EventRepository, encrypted bool) *escrowMediatorShareHandler {
+	return &escrowMediatorShareHandler{repo: repo, encrypted: encrypted}
+}
+
+// Handle implements the command handler interface
+func (h *escrowMediatorShareHandler) Handle(ctx context.Context, cmd EscrowMediatorShare) (string, error) {
+	if err := common.Validate(cmd); err != nil {
+		return "", err
+	}
+	if !h.encrypted {
+		return "", ErrEscrowUnavailable
+	}
+
+	if _, err := getPair(ctx, h.repo, cmd.PairId); err != nil {
+		return "", err
+	}
+
+	e := domain.MediatorKeyEscrow{}
+	if err := h.repo.GetWithContext(ctx, cmd.PairId, &e); err == nil {
+		return "", ErrShareAlreadyEscrowed
+	} else if err != eventsourcing.ErrAggregateNotFound {
+		return "", fmt.Errorf("failed to get escrow: %w", err)
+	}
+	if err := e.SetID(cmd.PairId); err != nil {
+		return "", fmt.Errorf("failed to set escrow id: %w", err)
+	}
+
+	share, err := getHexEncodedRandomBytes()
+	if err != nil {
+		return "", err
+	}
+	e.TrackChange(&e, &domain.MediatorShareEscrowed{PairId: cmd.PairId, Share: share, Operator: cmd.Operator})
+	if err := h.repo.Save(&e); err != nil {
+		return "", fmt.Errorf("failed to save escrow: %w", err)
+	}
+
+	return e.ID(), nil
+}
+
+// RequestShareRecovery is a command for an operator to request retrieving the key share of the mediator of a pair
+type RequestShareRecovery struct {
+	PairId   string `json:"pair_id" validate:"required,uuid4"`
+	Reason   string `json:"reason" validate:"required,max=1000"`
+	Operator string `json:"operator" validate:"required"`
+}
+
+// RequestShareRecoveryHandler is a command handler for RequestShareRecovery, it returns the id of the recovery
+type RequestShareRecoveryHandler common.CommandHandler[RequestShareRecovery]
+
+type requestShareRecoveryHandler struct {
+	repo *eventsourcing.EventRepository
+}
+
+// NewRequestShareRecoveryHandler creates a new RequestShareRecoveryHandler
+func NewRequestShareRecoveryHandler(repo *eventsourcing.EventRepository) *requestShareRecoveryHandler {
+	return &requestShareRecoveryHandler{repo: repo}
+}
+
+// Handle implements the command handler interface
+func (h *requestShareRecoveryHandler) Handle(ctx context.Context, cmd RequestShareRecovery) (string, error) {
+	if err := common.Validate(cmd); err != nil {
+		return "", err
+	}
+
+	e, err := getEscrow(ctx, h.repo, cmd.PairId)
+	if err != nil {
+		return "", err
+	}
+
+	id := uuid.New().String()
+	e.TrackChange(e, &domain.ShareRecoveryRequested{RecoveryId: id, Reason: cmd.Reason, Operator: cmd.Operator})
+	if err := h.repo.Save(e); err != nil {
+		return "", fmt.Errorf("failed to save escrow: %w", err)
+	}
+
+	return id, nil
+}
+
+// DecideShareRecovery is a command for an operator to approve a recovery requested by another operator, or to reject it with Reject
+type DecideShareRecovery struct {
+	PairId     string `json:"pair_id" validate:"required,uuid4"`
+	RecoveryId string `json:"recovery_id" validate:"required,uuid4"`
+	Reject     bool   `json:"reject"`
+	Reason     string `json:"reason" validate:"required_if=Reject true,max=1000"`
+	Operator   string `json:"operator" validate:"required"`
+}
+
+// DecideShareRecoveryHandler is a command handler for DecideShareRecovery, it returns the id of the recovery
+type DecideShareRecoveryHandler common.CommandHandler[DecideShareRecovery]
+
+type decideShareRecoveryHandler struct {
+	repo *eventsourcing.EventRepository
+}
+
+// NewDecideShareRecoveryHandler creates a new DecideShareRecoveryHandler
+func NewDecideShareRecoveryHandler(repo *eventsourcing.EventRepository) *decideShareRecoveryHandler {
+	return &decideShareRecoveryHandler{repo: repo}
+}
+
+// Handle implements the command handler interface
+func (h *decideShareRecoveryHandler) Handle(ctx context.Context, cmd DecideShareRecovery) (string, error) {
+	if err := common.Validate(cmd); err != nil {
+		return "", err
+	}
+
+	e, err := getEscrow(ctx, h.repo, cmd.PairId)
+	if err != nil {
+		return "", err
+	}
+	r, err := getShareRecovery(e, cmd.RecoveryId)
+	if err != nil {
+		return "", err
+	}
+	if r.Status != domain.ShareRecoveryStatusPending {
+		return "", ErrShareRecoveryNotPending
+	}
+	// A single operator can't release the share on their own
+	if r.RequestedBy == cmd.Operator {
+		return "", ErrShareRecoverySelfApproval
+	}
+
+	if cmd.Reject {
+		e.TrackChange(e, &domain.ShareRecoveryRejected{RecoveryId: cmd.RecoveryId, Reason: cmd.Reason, Operator: cmd.Operator})
+	} else {
+		e.TrackChange(e, &domain.ShareRecoveryApproved{RecoveryId: cmd.RecoveryId, Operator: cmd.Operator})
+	}
+	if err := h.repo.Save(e); err != nil {
+		return "", fmt.Errorf("failed to save escrow: %w", err)
+	}
+
+	return cmd.RecoveryId, nil
+}
+
+// RetrieveMediatorShare is a command for the operator of an approved recovery to retrieve the key share of the mediator
+type RetrieveMediatorShare struct {
+	PairId     string `json:"pair_id" validate:"required,uuid4"`
+	RecoveryId string `json:"recovery_id" validate:"required,uuid4"`
+	Operator   string `json:"operator" validate:"required"`
+}
+
+// RetrieveMediatorShareHandler is a command handler for RetrieveMediatorShare, it returns the key share.
+// An approved recovery releases the share once, within shareRecoveryTTL of its approval.
+type RetrieveMediatorShareHandler common.CommandHandler[RetrieveMediatorShare]
+
+type retrieveMediatorShareHandler struct {
+	repo  *eventsourcing.EventRepository
+	clock common.Clock
+}
+
+// NewRetrieveMediatorShareHandler creates a new RetrieveMediatorShareHandler
+func NewRetrieveMediatorShareHandler(repo *eventsourcing.EventRepository, clock common.Clock) *retrieveMediatorShareHandler {
+	return &retrieveMediatorShareHandler{repo: repo, clock: clock}
+}
+
+// Handle implements the command handler interface.
+// The retrieval is recorded before the share is returned, so no share leaves the escrow unrecorded.
+func (h *retrieveMediatorShareHandler) Handle(ctx context.Context, cmd RetrieveMediatorShare) (string, error) {
+	if err := common.Validate(cmd); err != nil {
+		return "", err
+	}
+
+	e, err := getEscrow(ctx, h.repo, cmd.PairId)
+	if err != nil {
+		return "", err
+	}
+	r, err := getShareRecovery(e, cmd.RecoveryId)
+	if err != nil {
+		return "", err
+	}
+	if r.Status != domain.ShareRecoveryStatusApproved {
+		return "", ErrShareRecoveryNotApproved.IncludeMeta(map[string]interface{}{"status": r.Status})
+	}
+	if h.clock.Now().After(r.DecidedAt.Add(shareRecoveryTTL)) {
+		return "", ErrShareRecoveryExpired
+	}
+	if r.RequestedBy != cmd.Operator {
+		return "", ErrShareRecoveryOperatorMismatch
+	}
+
+	e.TrackChange(e, &domain.MediatorShareRetrieved{RecoveryId: cmd.RecoveryId, Operator: cmd.Operator})
+	if err := h.repo.Save(e); err != nil {
+		return "", fmt.Errorf("failed to save escrow: %w", err)
+	}
+
+	return e.Share, nil
+}
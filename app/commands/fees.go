@@ -0,0 +1,104 @@
+package commands
+
+import (
+	"context"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/co-defi/api-server/common"
+	"github.com/co-defi/api-server/domain"
+)
+
+// FeeEstimate is the fee a transaction is expected to pay on a chain, in the base units of the chain's native asset
+type FeeEstimate struct {
+	Chain string       `json:"chain"`
+	Asset domain.Asset `json:"asset"`
+	// GasPrice is the price of a unit of gas, it's empty on the chains charging a flat fee
+	GasPrice string `json:"gas_price,omitempty"`
+	// Fee is the flat fee of a transaction, or the fee of a native transfer on the chains charging gas
+	Fee string `json:"fee"`
+	// TokenTransferFee is the fee of a transfer of the chain's tokens, e.g. ERC-20, it's empty on the chains without tokens.
+	// It's paid in the native asset, so the wallets refunding tokens must hold some of it.
+	TokenTransferFee string    `json:"token_transfer_fee,omitempty"`
+	EstimatedAt      time.Time `json:"estimated_at"`
+}
+
+// FeeEstimator estimates the current fees of its chain
+type FeeEstimator interface {
+	EstimateFee(ctx context.Context) (FeeEstimate, error)
+}
+
+// FeeEstimators holds the fee estimators by the chain they estimate the fees of
+type FeeEstimators map[string]FeeEstimator
+
+// minFeeRatio is the share of the estimated gas price a pre-signed transaction must pay at least,
+// the transactions paying less are unlikely to ever be mined
+const minFeeRatio = 2
+
+var ErrFeesUnavailable = common.NewError("fees_unavailable", "fees of the chain can't be estimated")
+
+// Fees estimates the fees of the chains with their estimators and caches the estimates for a while,
+// so the clients and the validation of the pre-signed transactions use the same figures
+type Fees struct {
+	estimators FeeEstimators
+	ttl        time.Duration
+
+	mu    sync.Mutex
+	cache map[string]FeeEstimate
+}
+
+// NewFees creates a new Fees serving the estimates for ttl
+func NewFees(estimators FeeEstimators, ttl time.Duration) *Fees {
+	return &Fees{estimators: estimators, ttl: ttl, cache: make(map[string]FeeEstimate)}
+}
+
+// Estimate returns the current fee estimate of the chain
+func (f *Fees) Estimate(ctx context.Context, chain string) (FeeEstimate, error) {
+	estimator, ok := f.estimators[chain]
+	if !ok {
+		return FeeEstimate{}, ErrFeesUnavailable
+	}
+
+	f.mu.Lock()
+	cached, ok := f.cache[chain]
+	f.mu.Unlock()
+	if ok && time.Since(cached.EstimatedAt) < f.ttl {
+		return cached, nil
+	}
+
+	estimate, err := estimator.EstimateFee(ctx)
+	if err != nil {
+		return FeeEstimate{}, err
+	}
+
+	f.mu.Lock()
+	f.cache[chain] = estimate
+	f.mu.Unlock()
+
+	return estimate, nil
+}
+
+// checkGasPrice explains why the gas price of the transaction on the asset's chain is drastically below the estimate,
+// it's empty when the price is fine or can't be checked
+func (f *Fees) checkGasPrice(ctx context.Context, asset domain.Asset, gasPrice *big.Int) string {
+	info, ok := domain.LookupAsset(asset)
+	if !ok || gasPrice == nil || f == nil {
+		return ""
+	}
+
+	// The transactions are still accepted when the fees can't be estimated
+	estimate, err := f.Estimate(ctx, info.Chain)
+	if err != nil || estimate.GasPrice == "" {
+		return ""
+	}
+	estimated, ok := new(big.Int).SetString(estimate.GasPrice, 10)
+	if !ok {
+		return ""
+	}
+
+	if new(big.Int).Mul(gasPrice, big.NewInt(minFeeRatio)).Cmp(estimated) < 0 {
+		return "transaction gas price " + gasPrice.String() + " is less than half of the current estimate " + estimated.String()
+	}
+	return ""
+}
@@ -0,0 +1,85 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/co-defi/api-server/common"
+	"github.com/co-defi/api-server/domain"
+)
+
+// NonceSource reports the next nonce of an address on its chain, i.e. the nonce the next transaction sent from it must have
+type NonceSource interface {
+	NextNonce(ctx context.Context, address domain.Address) (int, error)
+}
+
+// NonceSources holds the nonce sources by the chain they report the nonces of, the nonces of the chains without a source aren't checked
+type NonceSources map[string]NonceSource
+
+func (s NonceSources) forAsset(asset domain.Asset) (NonceSource, bool) {
+	info, ok := domain.LookupAsset(asset)
+	if !ok {
+		return nil, false
+	}
+
+	source, ok := s[info.Chain]
+	return source, ok
+}
+
+var (
+	ErrNonceAllocated = common.NewError("nonce_already_allocated", "nonce is already taken by another pre-signed transaction of the pair")
+	ErrNonceUsed      = common.NewError("nonce_already_used", "wallet already sent a transaction with this nonce")
+)
+
+// Nonces keeps the books of the nonces of the pairs' wallets: the pre-signed transactions must take the nonces left to them
+// by the other transactions of the pair and the nonces the wallet didn't send a transaction with yet, as the transactions
+// with a nonce already used on chain can never be mined.
+type Nonces struct {
+	sources NonceSources
+}
+
+// NewNonces creates a new Nonces checking the nonces the wallets reached with the sources of their chain
+func NewNonces(sources NonceSources) *Nonces {
+	return &Nonces{sources: sources}
+}
+
+// Check checks the nonces of the transactions pre-signed for the wallet address of the asset of the pair are free, in the pair
+// and on chain. The transactions of the chains without a nonce source are only checked against the pair.
+func (n *Nonces) Check(ctx context.Context, p domain.Pair, asset domain.Asset, txs ...domain.SignedTx) error {
+	if len(txs) == 0 {
+		return nil
+	}
+
+	lowest := txs[0].Nonce
+	taken := make(map[int]bool, len(txs))
+	for _, tx := range txs {
+		if taken[tx.Nonce] {
+			return ErrNonceAllocated.IncludeMeta(map[string]interface{}{"asset": asset, "nonce": tx.Nonce, "reason": "nonce is taken by several transactions"})
+		}
+		if kind, ok := p.NonceAllocated(asset, tx.Nonce); ok {
+			return ErrNonceAllocated.IncludeMeta(map[string]interface{}{"asset": asset, "nonce": tx.Nonce, "taken_by": kind})
+		}
+		taken[tx.Nonce] = true
+		lowest = min(lowest, tx.Nonce)
+	}
+
+	source, ok := n.sources.forAsset(asset)
+	if !ok {
+		return nil
+	}
+
+	next, err := source.NextNonce(ctx, p.Wallet.Addresses[asset])
+	if errors.Is(err, ErrChainUnavailable) {
+		info, _ := domain.LookupAsset(asset)
+		return ErrChainUnavailable.IncludeMeta(map[string]interface{}{"chain": info.Chain})
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get the next nonce of the wallet: %w", err)
+	}
+	if lowest < next {
+		return ErrNonceUsed.IncludeMeta(map[string]interface{}{"asset": asset, "nonce": lowest, "next_nonce": next})
+	}
+
+	return nil
+}
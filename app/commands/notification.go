@@ -0,0 +1,61 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/co-defi/api-server/common"
+	"github.com/co-defi/api-server/domain"
+	"github.com/hallgren/eventsourcing"
+)
+
+// UpdateNotificationSettings is a command to register the notification channels and preferences of a participant
+type UpdateNotificationSettings struct {
+	Address   domain.Address             `json:"address" validate:"required"`
+	Email     string                     `json:"email" validate:"omitempty,email"`
+	PushToken string                     `json:"push_token" validate:"omitempty,max=4096"`
+	Events    []domain.NotificationEvent `json:"events" validate:"dive,oneof=matched counterparty_deposit deadline_approaching withdrawal_completed tx_failed match_reverted status_overdue wallet_regenerated"`
+}
+
+// UpdateNotificationSettingsHandler is a command handler for UpdateNotificationSettings
+type UpdateNotificationSettingsHandler common.CommandHandler[UpdateNotificationSettings]
+
+type updateNotificationSettingsHandler struct {
+	repo *eventsourcing.EventRepository
+}
+
+// NewUpdateNotificationSettingsHandler creates a new UpdateNotificationSettingsHandler
+func NewUpdateNotificationSettingsHandler(repo *eventsourcing.EventRepository) *updateNotificationSettingsHandler {
+	return &updateNotificationSettingsHandler{repo: repo}
+}
+
+// Handle implements the command handler interface
+func (h *updateNotificationSettingsHandler) Handle(ctx context.Context, cmd UpdateNotificationSettings) (string, error) {
+	if err := common.Validate(cmd); err != nil {
+		return "", err
+	}
+
+	s := domain.NotificationSettings{}
+	if err := h.repo.GetWithContext(ctx, cmd.Address, &s); err != nil {
+		if err != eventsourcing.ErrAggregateNotFound {
+			return "", fmt.Errorf("failed to get notification settings: %w", err)
+		}
+
+		// The settings of an address are created on the first update
+		if err := s.SetID(cmd.Address); err != nil {
+			return "", fmt.Errorf("failed to set notification settings id: %w", err)
+		}
+	}
+
+	s.TrackChange(&s, &domain.NotificationSettingsUpdated{
+		Address:   cmd.Address,
+		Email:     cmd.Email,
+		PushToken: cmd.PushToken,
+		Events:    cmd.Events,
+	})
+	if err := h.repo.Save(&s); err != nil {
+		return "", fmt.Errorf("failed to save notification settings: %w", err)
+	}
+
+	return s.ID(), nil
+}
@@ -4,7 +4,11 @@ import (
 	"context"
 	"crypto/rand"
 	"encoding/hex"
+	"errors"
 	"fmt"
+	"math/big"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -17,30 +21,69 @@ import (
 // CreateOrMatchPair is a command to create a new pair or match an existing pair.
 type CreateOrMatchPair struct {
 	PlanId             string         `json:"plan_id" validate:"required,uuid4"`
-	ParticipantAsset   domain.Asset   `json:"participant_asset" validate:"required"`
+	ParticipantAsset   domain.Asset   `json:"participant_asset" validate:"required,asset"`
 	ParticipantAddress domain.Address `json:"participant_address" validate:"required"`
+	ShareMultiplier    int            `json:"share_multiplier" validate:"omitempty,min=1"`
+	Network            domain.Network `json:"network" validate:"omitempty,network"`
 }
 
 // CreateOrMatchPairHandler is a command handler for CreateOrMatchPair
 type CreateOrMatchPairHandler common.CommandHandler[CreateOrMatchPair]
 
+// PairQuotas limit the pairs a participant address may have in progress, so an address can't skew the matching
+// by flooding the plans with waiting pairs. Zero limits don't apply.
+type PairQuotas struct {
+	// MaxWaitingPerPlan is the number of pairs an address may have waiting for a counterparty in a plan
+	MaxWaitingPerPlan int
+	// MaxActive is the number of pairs an address may have in progress overall, the waiting ones included
+	MaxActive int
+}
+
 type createOrMatchPairHandler struct {
-	mutex      sync.Mutex
-	repo       *eventsourcing.EventRepository
-	plansQuery *queries.PlansQuery
-	pairsQuery *queries.PairsQuery
+	mutex             sync.Mutex
+	repo              *eventsourcing.EventRepository
+	plansQuery        *queries.PlansQuery
+	pairsQuery        *queries.PairsQuery
+	reputationQuery   *queries.ReputationQuery
+	participantsQuery *queries.ParticipantsQuery
+	quotas            PairQuotas
+	allowSelfMatch    bool
+	clock             common.Clock
 }
 
-// NewCreateOrMatchPairHandler creates a new CreateOrMatchPairHandler
-func NewCreateOrMatchPairHandler(repo *eventsourcing.EventRepository, plansQuery *queries.PlansQuery, pairsQueries *queries.PairsQuery) *createOrMatchPairHandler {
+// NewCreateOrMatchPairHandler creates a new CreateOrMatchPairHandler. The participants can't match the pairs created
+// from their own addresses unless allowSelfMatch is set, e.g. to test the pairs end to end with a single wallet.
+func NewCreateOrMatchPairHandler(
+	repo *eventsourcing.EventRepository,
+	plansQuery *queries.PlansQuery,
+	pairsQueries *queries.PairsQuery,
+	reputationQuery *queries.ReputationQuery,
+	participantsQuery *queries.ParticipantsQuery,
+	quotas PairQuotas,
+	allowSelfMatch bool,
+	clock common.Clock,
+) *createOrMatchPairHandler {
 	return &createOrMatchPairHandler{
-		repo:       repo,
-		pairsQuery: pairsQueries,
-		plansQuery: plansQuery,
+		repo:              repo,
+		pairsQuery:        pairsQueries,
+		plansQuery:        plansQuery,
+		reputationQuery:   reputationQuery,
+		participantsQuery: participantsQuery,
+		quotas:            quotas,
+		allowSelfMatch:    allowSelfMatch,
+		clock:             clock,
 	}
 }
 
-var ErrInvalidAssetForPair = common.NewError("invalid_asset_for_pair", "participant asset is not valid for the pair")
+var (
+	ErrInvalidAssetForPair    = common.NewError("invalid_asset_for_pair", "participant asset is not valid for the pair")
+	ErrInvalidShareMultiplier = common.NewError("invalid_share_multiplier", "share multiplier is out of the plan bounds")
+	ErrPlanNotInNetwork       = common.NewError("plan_not_in_network", "plan doesn't belong to the network of the participant")
+	ErrPlanUnavailable        = common.NewError("plan_unavailable", "plan doesn't accept new pairs at the moment")
+	ErrPlanAtCapacity         = common.NewError("plan_at_capacity", "plan has reached its maximum number of active pairs")
+	ErrPairQuotaExceeded      = common.NewError("pair_quota_exceeded", "participant has too many pairs in progress")
+	ErrSelfMatch              = common.NewError("self_match", "participant can't match their own pair")
+)
 
 // Handle implements the command handler interface
 func (h *createOrMatchPairHandler) Handle(ctx context.Context, cmd CreateOrMatchPair) (string, error) {
@@ -56,44 +99,107 @@ func (h *createOrMatchPairHandler) Handle(ctx context.Context, cmd CreateOrMatch
 		return "", fmt.Errorf("failed to get plan: %w", err)
 	}
 
+	if plan.Network != domain.NetworkOrDefault(cmd.Network) {
+		return "", ErrPlanNotInNetwork
+	}
+
+	activePairs, err := h.pairsQuery.CountActive(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to count active pairs: %w", err)
+	}
+	// A plan at capacity still lets the waiting pairs get matched, it only stops new pairs from being created
+	availability := plan.Availability(h.clock.Now(), activePairs[plan.Id])
+	if availability != queries.PlanAvailabilityAvailable && availability != queries.PlanAvailabilityAtCapacity {
+		return "", ErrPlanUnavailable.IncludeMeta(map[string]interface{}{"availability": availability})
+	}
+
 	if !containsAsset(plan.Assets, cmd.ParticipantAsset) {
 		return "", ErrInvalidAssetForPair
 	}
 	secondaryAsset := getSecondaryAsset(cmd.ParticipantAsset, plan.Assets)
 
+	// A missing multiplier means the participant invests a single quantum
+	multiplier := max(cmd.ShareMultiplier, 1)
+	if !plan.AllowsShareMultiplier(multiplier) {
+		return "", ErrInvalidShareMultiplier.IncludeMeta(map[string]interface{}{"max_share_multiplier": plan.MaxShareMultiplier})
+	}
+	shareValue := plan.Quantum * multiplier
+
+	participantPairs, err := h.pairsQuery.CountParticipantPairs(ctx, cmd.ParticipantAddress)
+	if err != nil {
+		return "", fmt.Errorf("failed to count participant pairs: %w", err)
+	}
+	if h.quotas.MaxActive > 0 && participantPairs.Active >= h.quotas.MaxActive {
+		return "", ErrPairQuotaExceeded.IncludeMeta(map[string]interface{}{"quota": "max_active_pairs", "limit": h.quotas.MaxActive})
+	}
+
 	// Find a pair with the same status, secondary asset as the participant asset and primary asset as the secondary asset
-	// i.e. the counterpart of the participant asset
+	// i.e. the counterpart of the participant asset, investing the same total share value
 	var status = domain.PairStatusWaiting
-	pairs, err := h.pairsQuery.Find(
-		ctx,
-		&status,
-		[]domain.Asset{secondaryAsset, cmd.ParticipantAsset},
-		true,
-		nil,
-		&plan.Quantum,
-		&plan.InvestingPeriod,
-		&plan.Security,
-		&plan.Strategy,
-		&plan.LossProtection,
-	)
+	pairs, err := h.pairsQuery.Find(ctx, queries.PairFilter{
+		Network:               &plan.Network,
+		PlanType:              &plan.Type,
+		Status:                &status,
+		Assets:                []domain.Asset{secondaryAsset, cmd.ParticipantAsset},
+		AssetsOrder:           true,
+		ShareValue:            &shareValue,
+		InvestingPeriod:       &plan.InvestingPeriod,
+		InvestingPeriodUnit:   &plan.InvestingPeriodUnit,
+		GracePeriodDays:       &plan.GracePeriodDays,
+		WalletSecurity:        &plan.Security,
+		ProfitSharingStrategy: &plan.Strategy,
+		LossProtection:        &plan.LossProtection,
+	})
 	if err != nil {
 		return "", fmt.Errorf("failed to find pairs: %w", err)
 	}
 
+	pairs, err = h.deprioritizeLowReputation(ctx, pairs)
+	if err != nil {
+		return "", fmt.Errorf("failed to rank pairs by reputation: %w", err)
+	}
+
+	// The participant is never their own counterparty, the waiting pairs of the others are matched instead. The participant
+	// is rejected when only their own pairs wait, as the pair they'd create would wait next to them for another counterparty.
+	if !h.allowSelfMatch && len(pairs) > 0 {
+		pairs, err = h.excludeOwnPairs(ctx, pairs, cmd.ParticipantAddress)
+		if err != nil {
+			return "", fmt.Errorf("failed to exclude own pairs: %w", err)
+		}
+		if len(pairs) == 0 {
+			return "", ErrSelfMatch
+		}
+	}
+
 	// If there's no suitable pair, create a new pair and wait for the counterpart
 	p := domain.Pair{}
 	if len(pairs) < 1 {
+		if availability == queries.PlanAvailabilityAtCapacity {
+			return "", ErrPlanAtCapacity.IncludeMeta(map[string]interface{}{"max_active_pairs": plan.MaxActivePairs})
+		}
+		// Matching a waiting pair is always allowed, only the new waiting pairs count against the quota of the plan
+		if h.quotas.MaxWaitingPerPlan > 0 && participantPairs.WaitingByPlan[plan.Id] >= h.quotas.MaxWaitingPerPlan {
+			return "", ErrPairQuotaExceeded.IncludeMeta(map[string]interface{}{"quota": "max_waiting_pairs_per_plan", "limit": h.quotas.MaxWaitingPerPlan})
+		}
+
 		p.TrackChange(&p, &domain.PairCreated{
+			PlanId:                plan.Id,
+			PlanType:              plan.Type,
 			ParticipantAsset:      cmd.ParticipantAsset,
 			ParticipantAddress:    cmd.ParticipantAddress,
 			SecondaryAsset:        secondaryAsset,
-			ShareValue:            plan.Quantum,
+			ShareValue:            shareValue,
 			InvestingPeriod:       plan.InvestingPeriod,
+			InvestingPeriodUnit:   plan.InvestingPeriodUnit,
+			GracePeriodDays:       plan.GracePeriodDays,
 			WalletSecurity:        plan.Security,
 			ProfitSharingStrategy: plan.Strategy,
 			LossProtection:        plan.LossProtection,
+			Network:               plan.Network,
 		})
-		p.TrackChange(&p, &domain.PairStatusChanged{Status: domain.PairStatusWaiting})
+		if err := changePairStatus(&p, domain.PairStatusWaiting); err != nil {
+			return "", err
+		}
 	} else {
 		// If there's a suitable pair, match the pair
 		err := h.repo.GetWithContext(ctx, pairs[0].Id, &p)
@@ -114,7 +220,9 @@ func (h *createOrMatchPairHandler) Handle(ctx context.Context, cmd CreateOrMatch
 			WalletEncryptionKey: encryptionKey,
 			WalletHexChainCode:  hexChainCode,
 		})
-		p.TrackChange(&p, &domain.PairStatusChanged{Status: domain.PairStatusWalletConformation})
+		if err := changePairStatus(&p, domain.PairStatusWalletConformation); err != nil {
+			return "", err
+		}
 	}
 	if err := h.repo.Save(&p); err != nil {
 		return "", fmt.Errorf("failed to save pair: %w", err)
@@ -123,6 +231,64 @@ func (h *createOrMatchPairHandler) Handle(ctx context.Context, cmd CreateOrMatch
 	return p.ID(), nil
 }
 
+// deprioritizeLowReputation moves the pairs created by low-reputation addresses to the end of the queue
+// while keeping the original order otherwise
+func (h *createOrMatchPairHandler) deprioritizeLowReputation(ctx context.Context, pairs []queries.Pair) ([]queries.Pair, error) {
+	creators := make([]domain.Address, len(pairs))
+	for i, p := range pairs {
+		creators[i] = p.ParticipantAddresses[0]
+	}
+
+	scores, err := h.reputationQuery.Scores(ctx, creators)
+	if err != nil {
+		return nil, err
+	}
+
+	// The found pairs may be shared with the query cache, so they are ranked in a copy.
+	// Within a reputation tier the pairs are matched in the order they were created, a reverted match keeps its place.
+	ranked := make([]queries.Pair, len(pairs))
+	copy(ranked, pairs)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		lowI := scores[ranked[i].ParticipantAddresses[0]] < queries.LowReputationScore
+		lowJ := scores[ranked[j].ParticipantAddresses[0]] < queries.LowReputationScore
+		if lowI != lowJ {
+			return lowJ
+		}
+		return ranked[i].CreatedAt.Before(ranked[j].CreatedAt)
+	})
+
+	return ranked, nil
+}
+
+// excludeOwnPairs drops the pairs created by the participant of the address, from the same address or from another address
+// linked to the same participant. The EVM addresses are compared case-insensitively.
+func (h *createOrMatchPairHandler) excludeOwnPairs(ctx context.Context, pairs []queries.Pair, address domain.Address) ([]queries.Pair, error) {
+	participantId, err := h.participantsQuery.IdOf(ctx, address)
+	if err != nil {
+		return nil, err
+	}
+
+	others := make([]queries.Pair, 0, len(pairs))
+	for _, p := range pairs {
+		creator := p.ParticipantAddresses[0]
+		if strings.EqualFold(creator, address) {
+			continue
+		}
+		if participantId != "" {
+			creatorId, err := h.participantsQuery.IdOf(ctx, creator)
+			if err != nil {
+				return nil, err
+			}
+			if creatorId == participantId {
+				continue
+			}
+		}
+		others = append(others, p)
+	}
+
+	return others, nil
+}
+
 func containsAsset(assets []domain.Asset, asset domain.Asset) bool {
 	for _, a := range assets {
 		if a == asset {
@@ -155,20 +321,20 @@ type ConfirmPairWallet struct {
 	PairId               string                          `json:"pair_id" validate:"required,uuid4"`
 	ParticipantAddress   domain.Address                  `json:"participant_address" validate:"required"`
 	ParticipantPublicKey string                          `json:"participant_public_key" validate:"required"`
-	WalletAddresses      map[domain.Asset]domain.Address `json:"wallet_addresses" validate:"required,len=2"`
+	WalletAddresses      map[domain.Asset]domain.Address `json:"wallet_addresses" validate:"required,len=2,dive,keys,asset,endkeys,required"`
 }
 
 // ConfirmPairWalletHandler is a command handler for ConfirmPairWallet
 type ConfirmPairWalletHandler common.CommandHandler[ConfirmPairWallet]
 
 type confirmPairWalletHandler struct {
-	repo       *eventsourcing.EventRepository
-	pairsQuery *queries.PairsQuery
+	repo     *eventsourcing.EventRepository
+	derivers WalletDerivers
 }
 
-// NewConfirmPairWalletHandler creates a new ConfirmPairWalletHandler
-func NewConfirmPairWalletHandler(repo *eventsourcing.EventRepository) *confirmPairWalletHandler {
-	return &confirmPairWalletHandler{repo: repo}
+// NewConfirmPairWalletHandler creates a new ConfirmPairWalletHandler verifying the wallet addresses with the derivers of their chains
+func NewConfirmPairWalletHandler(repo *eventsourcing.EventRepository, derivers WalletDerivers) *confirmPairWalletHandler {
+	return &confirmPairWalletHandler{repo: repo, derivers: derivers}
 }
 
 var (
@@ -176,75 +342,164 @@ var (
 	ErrInvalidPairStatus       = common.NewError("invalid_pair_status", "pair status is not valid for this operation")
 	ErrInvalidWalletAddresses  = common.NewError("invalid_wallet_addresses", "wallet addresses are not the same for both participants")
 	ErrForbiddenPairForAddress = common.NewError("forbidden_pair_for_address", "pair is not allowed for the address")
+	ErrInvalidWalletPublicKey  = common.NewError("invalid_wallet_public_key", "wallet public key is not the one shared by the participants")
+	ErrWalletAddressMismatch   = common.NewError("wallet_address_mismatch", "wallet address is not derived from the wallet public key")
+	ErrWalletAlreadyConfirmed  = common.NewError("wallet_already_confirmed", "wallet is already confirmed with the same public key and addresses")
 )
 
+// changePairStatus moves the pair through its state machine, rejecting the moves the pair isn't ready for
+func changePairStatus(p *domain.Pair, status domain.PairStatus) error {
+	if err := p.ChangeStatus(status); err != nil {
+		return ErrInvalidPairStatus.IncludeMeta(map[string]interface{}{"reason": err.Error()})
+	}
+	return nil
+}
+
+// getPair loads the pair, the missing pairs are reported with ErrPairNotFound
+func getPair(ctx context.Context, repo *eventsourcing.EventRepository, id string) (*domain.Pair, error) {
+	p := domain.Pair{}
+	if err := repo.GetWithContext(ctx, id, &p); err != nil {
+		if err == eventsourcing.ErrAggregateNotFound {
+			return nil, ErrPairNotFound
+		}
+		return nil, fmt.Errorf("failed to get pair: %w", err)
+	}
+
+	return &p, nil
+}
+
 // Handle implements the command handler interface
 func (h *confirmPairWalletHandler) Handle(ctx context.Context, cmd ConfirmPairWallet) (string, error) {
 	if err := common.Validate(cmd); err != nil {
 		return "", err
 	}
 
-	p := domain.Pair{}
-	if err := h.repo.GetWithContext(ctx, cmd.PairId, &p); err != nil {
-		if err == eventsourcing.ErrAggregateNotFound {
-			return "", ErrPairNotFound
-		}
-		return "", fmt.Errorf("failed to get pair: %w", err)
+	p, err := getPair(ctx, h.repo, cmd.PairId)
+	if err != nil {
+		return "", err
 	}
 
-	if p.Status != domain.PairStatusWalletConformation {
-		return "", ErrInvalidPairStatus
+	if err := h.apply(ctx, p, cmd); err != nil {
+		return "", err
+	}
+
+	if err := h.repo.Save(p); err != nil {
+		return "", fmt.Errorf("failed to save pair: %w", err)
+	}
+
+	return p.ID(), nil
+}
+
+// apply confirms the wallet on the pair without saving it
+func (h *confirmPairWalletHandler) apply(_ context.Context, p *domain.Pair, cmd ConfirmPairWallet) error {
+	if p.Status != domain.PairStatusWalletConformation && p.Status != domain.PairStatusAssurance {
+		return ErrInvalidPairStatus
 	}
 
 	participantAsset := p.AssetOfParticipant(cmd.ParticipantAddress)
 	if participantAsset == "" {
-		return "", ErrForbiddenPairForAddress
+		return ErrForbiddenPairForAddress
 	}
 	for asset := range cmd.WalletAddresses {
 		if !p.HasAsset(asset) {
-			return "", ErrInvalidAssetForPair
+			return ErrInvalidAssetForPair
+		}
+	}
+
+	if p.Status == domain.PairStatusAssurance {
+		if err := invalidateAssurances(p, participantAsset, cmd); err != nil {
+			return err
 		}
 	}
 
 	// TODO: Better participant identification and authentication
-	if p.Wallet != nil && !p.Wallet.AreAddressesEqual(cmd.WalletAddresses) {
-		return "", ErrInvalidWalletAddresses
+	if len(p.Wallet.Addresses) > 0 && !p.Wallet.AreAddressesEqual(cmd.WalletAddresses) {
+		return ErrInvalidWalletAddresses
+	}
+	if err := h.verifyWalletAddresses(*p, cmd); err != nil {
+		return err
 	}
 
-	p.TrackChange(&p, &domain.WalletAddressConfirmed{
+	p.TrackChange(p, &domain.WalletAddressConfirmed{
 		ParticipantAsset: participantAsset,
 		PublicKey:        cmd.ParticipantPublicKey,
 		WalletAddresses:  cmd.WalletAddresses,
 	})
 	if len(p.Wallet.PublicKeys) == 2 {
-		p.TrackChange(&p, &domain.PairStatusChanged{Status: domain.PairStatusAssurance})
+		return changePairStatus(p, domain.PairStatusAssurance)
 	}
 
-	if err := h.repo.Save(&p); err != nil {
-		return "", fmt.Errorf("failed to save pair: %w", err)
+	return nil
+}
+
+// invalidateAssurances moves the pair back to the wallet confirmation when a participant confirms a wallet generated again,
+// e.g. after a failed keygen. The assurances signed for the previous wallet can't be broadcast from the new one, so they are
+// dropped along with the confirmations and both participants sign them again once the new wallet is confirmed.
+func invalidateAssurances(p *domain.Pair, participantAsset domain.Asset, cmd ConfirmPairWallet) error {
+	var reason string
+	switch {
+	case p.Wallet.PublicKeys[participantAsset] != cmd.ParticipantPublicKey:
+		reason = "wallet public key changed"
+	case !p.Wallet.AreAddressesEqual(cmd.WalletAddresses):
+		reason = "wallet addresses changed"
+	default:
+		return ErrWalletAlreadyConfirmed
 	}
 
-	return p.ID(), nil
+	p.TrackChange(p, &domain.AssurancesInvalidated{ParticipantAsset: participantAsset, Reason: reason})
+	return changePairStatus(p, domain.PairStatusWalletConformation)
+}
+
+// verifyWalletAddresses derives the wallet addresses from the public key generated by the participants and the chain code of the pair,
+// so the participants can't agree on addresses the wallet doesn't control
+func (h *confirmPairWalletHandler) verifyWalletAddresses(p domain.Pair, cmd ConfirmPairWallet) error {
+	for asset, publicKey := range p.Wallet.PublicKeys {
+		if publicKey != cmd.ParticipantPublicKey {
+			return ErrInvalidWalletPublicKey.IncludeMeta(map[string]interface{}{"confirmed_by": asset})
+		}
+	}
+
+	for asset, address := range cmd.WalletAddresses {
+		deriver, ok := h.derivers.forAsset(asset)
+		if !ok {
+			continue
+		}
+
+		derived, err := deriver.DeriveAddress(cmd.ParticipantPublicKey, p.Wallet.HexChainCode)
+		if err != nil {
+			return ErrInvalidWalletPublicKey.IncludeMeta(map[string]interface{}{"reason": err.Error()})
+		}
+		if !strings.EqualFold(string(derived), string(address)) {
+			return ErrWalletAddressMismatch.IncludeMeta(map[string]interface{}{"asset": asset, "expected": derived})
+		}
+	}
+
+	return nil
 }
 
 // SetPairAssurances is a command to set assurances for a pair
 type SetPairAssurances struct {
 	PairId             string            `json:"pair_id" validate:"required,uuid4"`
 	ParticipantAddress domain.Address    `json:"participant_address" validate:"required"`
-	Asset              domain.Asset      `json:"asset" validate:"required"`
-	Assurances         []domain.SignedTx `json:"assurances" validate:"required"`
+	Asset              domain.Asset      `json:"asset" validate:"required,asset"`
+	Assurances         []domain.SignedTx `json:"assurances" validate:"required,max=64,dive"`
 }
 
 // SetPairAssurancesHandler is a command handler for SetPairAssurances
 type SetPairAssurancesHandler common.CommandHandler[SetPairAssurances]
 
 type setPairAssurancesHandler struct {
-	repo *eventsourcing.EventRepository
+	repo       *eventsourcing.EventRepository
+	decoders   TxDecoders
+	fees       *Fees
+	nonces     *Nonces
+	signatures *Signatures
 }
 
-// NewSetPairAssurancesHandler creates a new SetPairAssurancesHandler
-func NewSetPairAssurancesHandler(repo *eventsourcing.EventRepository) *setPairAssurancesHandler {
-	return &setPairAssurancesHandler{repo: repo}
+// NewSetPairAssurancesHandler creates a new SetPairAssurancesHandler, the assurances paying drastically less gas than
+// the estimate of the fees are rejected, their nonces are checked with nonces and their signatures with signatures
+func NewSetPairAssurancesHandler(repo *eventsourcing.EventRepository, decoders TxDecoders, fees *Fees, nonces *Nonces, signatures *Signatures) *setPairAssurancesHandler {
+	return &setPairAssurancesHandler{repo: repo, decoders: decoders, fees: fees, nonces: nonces, signatures: signatures}
 }
 
 var ErrAlreadySetAssurances = common.NewError("already_set_assurances", "assurances are already set")
@@ -255,62 +510,156 @@ func (h *setPairAssurancesHandler) Handle(ctx context.Context, cmd SetPairAssura
 		return "", err
 	}
 
-	if err := validateAssurances(cmd.Asset, cmd.Assurances); err != nil {
+	p, err := getPair(ctx, h.repo, cmd.PairId)
+	if err != nil {
 		return "", err
 	}
 
-	p := domain.Pair{}
-	if err := h.repo.GetWithContext(ctx, cmd.PairId, &p); err != nil {
-		if err == eventsourcing.ErrAggregateNotFound {
-			return "", ErrPairNotFound
-		}
-		return "", fmt.Errorf("failed to get pair: %w", err)
+	if err := h.apply(ctx, p, cmd); err != nil {
+		return "", err
+	}
+
+	// The pair moves on to deposits once both participants confirm the assurances refunding them
+	if err := h.repo.Save(p); err != nil {
+		return "", fmt.Errorf("failed to save pair: %w", err)
 	}
 
+	return p.ID(), nil
+}
+
+// apply signs the assurances on the pair without saving it
+func (h *setPairAssurancesHandler) apply(ctx context.Context, p *domain.Pair, cmd SetPairAssurances) error {
 	if p.Status != domain.PairStatusAssurance {
-		return "", ErrInvalidPairStatus
+		return ErrInvalidPairStatus
 	}
 
 	if !p.HasParticipant(cmd.ParticipantAddress) {
-		return "", ErrForbiddenPairForAddress
+		return ErrForbiddenPairForAddress
 	}
 
 	if p.HasAssurancesForAsset(cmd.Asset) {
-		return "", ErrAlreadySetAssurances
+		return ErrAlreadySetAssurances
+	}
+
+	policy, err := assurancePolicy(ctx, h.repo, *p, cmd.Asset)
+	if err != nil {
+		return err
+	}
+	if err := validateAssurances(policy, cmd.Assurances); err != nil {
+		return err
+	}
+
+	if err := h.validateAssuranceTxs(ctx, *p, cmd.Asset, cmd.Assurances); err != nil {
+		return err
+	}
+	if err := h.nonces.Check(ctx, *p, cmd.Asset, cmd.Assurances...); err != nil {
+		return err
+	}
+	for _, assurance := range cmd.Assurances {
+		if err := h.signatures.Verify(*p, cmd.Asset, assurance); err != nil {
+			return err
+		}
 	}
 
 	for _, assurance := range cmd.Assurances {
-		p.TrackChange(&p, &domain.AssetAssuranceSigned{
+		p.TrackChange(p, &domain.AssetAssuranceSigned{
 			Asset: cmd.Asset,
 			Tx:    assurance,
 		})
 	}
 
-	if len(p.Assurances) == 2 {
-		p.TrackChange(&p, &domain.PairStatusChanged{Status: domain.PairStatusDeposit})
+	return nil
+}
+
+var ErrInvalidAssurances = common.NewError("invalid_assurances", "assurances are not valid")
+
+// assurancePolicy returns the policy the assurances of the asset follow, the one of its chain in the plan of the pair.
+// The pairs created before they were linked to their plans follow the default policy.
+func assurancePolicy(ctx context.Context, repo *eventsourcing.EventRepository, p domain.Pair, asset domain.Asset) (domain.AssurancePolicy, error) {
+	chain, _ := domain.ChainOf(asset)
+	if p.PlanId == "" {
+		return domain.DefaultAssurancePolicy(p.PlanType, chain), nil
 	}
 
-	if err := h.repo.Save(&p); err != nil {
-		return "", fmt.Errorf("failed to save pair: %w", err)
+	plan, err := getPlan(ctx, repo, p.PlanId)
+	if err != nil {
+		return domain.AssurancePolicy{}, err
 	}
 
-	return p.ID(), nil
+	return plan.AssurancePolicy(chain), nil
 }
 
-var ErrInvalidAssurances = common.NewError("invalid_assurances", "assurances are not valid")
+// validateWithdrawalNonce checks that the withdrawal of the asset has the withdrawal nonce of the assurance policy of its chain,
+// so the last assurance guards it
+func validateWithdrawalNonce(ctx context.Context, repo *eventsourcing.EventRepository, p domain.Pair, asset domain.Asset, tx domain.SignedTx, invalid func(reason string) error) error {
+	policy, err := assurancePolicy(ctx, repo, p, asset)
+	if err != nil {
+		return err
+	}
+
+	nonce, ok := policy.WithdrawalNonce()
+	if !ok {
+		return invalid("assurance policy doesn't guard a withdrawal")
+	}
+	if tx.Nonce != nonce {
+		return invalid(fmt.Sprintf("withdrawal transaction must have nonce %d", nonce))
+	}
 
-func validateAssurances(asset domain.Asset, assurances []domain.SignedTx) error {
-	if !hasAssuranceWithNonce(assurances, 0) {
-		return ErrInvalidAssurances.IncludeMeta(map[string]interface{}{"missing_assurance": "missing assurance with nonce 0"})
+	return nil
+}
+
+// validateAssurances checks that the assurances include every nonce of the policy and don't exceed its number of transactions
+func validateAssurances(policy domain.AssurancePolicy, assurances []domain.SignedTx) error {
+	if policy.MaxTxs > 0 && len(assurances) > policy.MaxTxs {
+		return ErrInvalidAssurances.IncludeMeta(map[string]interface{}{"max_txs": policy.MaxTxs})
+	}
+	for _, nonce := range policy.Nonces {
+		if !hasAssuranceWithNonce(assurances, nonce) {
+			return ErrInvalidAssurances.IncludeMeta(map[string]interface{}{"missing_assurance": fmt.Sprintf("missing assurance with nonce %d", nonce)})
+		}
 	}
-	if !hasAssuranceWithNonce(assurances, 2) {
-		return ErrInvalidAssurances.IncludeMeta(map[string]interface{}{"missing_assurance": "missing assurance with nonce 2"})
+
+	return nil
+}
+
+// validateAssuranceTxs checks that every assurance refunds the asset from the pair's wallet to the participant who deposits it,
+// so the counterparty can't hand over transactions that would strand the funds in the wallet
+func (h *setPairAssurancesHandler) validateAssuranceTxs(ctx context.Context, p domain.Pair, asset domain.Asset, assurances []domain.SignedTx) error {
+	decoder, ok := h.decoders.forAsset(asset)
+	if !ok {
+		return nil
 	}
 
-	// THOR.RUNE requires assurance with nonce 4 because of the withdraw transaction
-	if asset == "THOR.RUNE" {
-		if !hasAssuranceWithNonce(assurances, 4) {
-			return ErrInvalidAssurances.IncludeMeta(map[string]interface{}{"missing_assurance": "missing assurance with nonce 4"})
+	for _, assurance := range assurances {
+		invalid := func(reason string) error {
+			return ErrInvalidAssurances.IncludeMeta(map[string]interface{}{"nonce": assurance.Nonce, "reason": reason})
+		}
+
+		decoded, err := decoder.DecodeTx(assurance)
+		if errors.Is(err, ErrTxMismatch) {
+			return invalid(err.Error())
+		}
+		if err != nil {
+			return fmt.Errorf("failed to decode assurance transaction: %w", err)
+		}
+
+		if decoded.Nonce != assurance.Nonce {
+			return invalid(fmt.Sprintf("transaction nonce %d doesn't match the declared nonce", decoded.Nonce))
+		}
+		if !strings.EqualFold(decoded.From, p.Wallet.Addresses[asset]) {
+			return invalid("transaction must be sent from the pair's wallet address of the asset")
+		}
+		if !strings.EqualFold(decoded.To, p.ParticipantsAddress[asset]) {
+			return invalid("transaction must refund the participant who deposits the asset")
+		}
+		if decoded.Asset != asset {
+			return invalid(fmt.Sprintf("transaction transfers %s instead of %s", decoded.Asset, asset))
+		}
+		if decoded.Amount == nil || decoded.Amount.Sign() <= 0 {
+			return invalid("transaction must transfer a positive amount")
+		}
+		if reason := h.fees.checkGasPrice(ctx, asset, decoded.GasPrice); reason != "" {
+			return invalid(reason)
 		}
 	}
 
@@ -326,27 +675,124 @@ func hasAssuranceWithNonce(assurances []domain.SignedTx, nonce int) bool {
 	return false
 }
 
+// ConfirmAssurances is a command for a participant to acknowledge the assurances refunding their asset.
+// The signature is made by the participant's address over domain.AssurancesAcknowledgement of the assurances, the way
+// the wallets of the asset's chain sign messages (see common.VerifySignature).
+type ConfirmAssurances struct {
+	PairId             string         `json:"pair_id" validate:"required,uuid4"`
+	ParticipantAddress domain.Address `json:"participant_address" validate:"required"`
+	Signature          []byte         `json:"signature" validate:"required"`
+}
+
+// ConfirmAssurancesHandler is a command handler for ConfirmAssurances
+type ConfirmAssurancesHandler common.CommandHandler[ConfirmAssurances]
+
+type confirmAssurancesHandler struct {
+	repo *eventsourcing.EventRepository
+}
+
+// NewConfirmAssurancesHandler creates a new ConfirmAssurancesHandler
+func NewConfirmAssurancesHandler(repo *eventsourcing.EventRepository) *confirmAssurancesHandler {
+	return &confirmAssurancesHandler{repo: repo}
+}
+
+var (
+	ErrAssurancesNotSet                = common.NewError("assurances_not_set", "assurances of the participant's asset are not set yet")
+	ErrAlreadyConfirmedAssurances      = common.NewError("already_confirmed_assurances", "assurances are already confirmed")
+	ErrInvalidAssuranceAcknowledgement = common.NewError("invalid_assurance_acknowledgement", "assurances acknowledgement signature is not valid")
+)
+
+// Handle implements the command handler interface
+func (h *confirmAssurancesHandler) Handle(ctx context.Context, cmd ConfirmAssurances) (string, error) {
+	if err := common.Validate(cmd); err != nil {
+		return "", err
+	}
+
+	p, err := getPair(ctx, h.repo, cmd.PairId)
+	if err != nil {
+		return "", err
+	}
+
+	if err := h.apply(ctx, p, cmd); err != nil {
+		return "", err
+	}
+
+	if err := h.repo.Save(p); err != nil {
+		return "", fmt.Errorf("failed to save pair: %w", err)
+	}
+
+	return p.ID(), nil
+}
+
+// apply acknowledges the assurances on the pair without saving it
+func (h *confirmAssurancesHandler) apply(_ context.Context, p *domain.Pair, cmd ConfirmAssurances) error {
+	if p.Status != domain.PairStatusAssurance {
+		return ErrInvalidPairStatus
+	}
+
+	asset := p.AssetOfParticipant(cmd.ParticipantAddress)
+	if asset == "" {
+		return ErrForbiddenPairForAddress
+	}
+
+	if !p.HasAssurancesForAsset(asset) {
+		return ErrAssurancesNotSet
+	}
+
+	if p.HasConfirmedAssurancesForAsset(asset) {
+		return ErrAlreadyConfirmedAssurances
+	}
+
+	info, _ := domain.LookupAsset(asset)
+	message := domain.AssurancesAcknowledgement(p.ID(), asset, p.Assurances[asset])
+	if err := common.VerifySignature(info.Chain, cmd.ParticipantAddress, message, cmd.Signature); err != nil {
+		return ErrInvalidAssuranceAcknowledgement.IncludeMeta(map[string]interface{}{"reason": err.Error()})
+	}
+
+	p.TrackChange(p, &domain.AssurancesConfirmed{
+		Asset:     asset,
+		Digest:    domain.AssurancesDigest(p.Assurances[asset]),
+		Signature: cmd.Signature,
+	})
+
+	if len(p.AssuranceConfirmations) == 2 {
+		return changePairStatus(p, domain.PairStatusDeposit)
+	}
+
+	return nil
+}
+
 // AddDeposit is a command to add a deposit to a pair
 type AddDeposit struct {
 	PairId             string         `json:"pair_id" validate:"required,uuid4"`
 	ParticipantAddress domain.Address `json:"participant_address" validate:"required"`
-	Asset              domain.Asset   `json:"asset" validate:"required"`
+	Asset              domain.Asset   `json:"asset" validate:"required,asset"`
 	TxHash             domain.TxHash  `json:"tx_hash" validate:"required"`
+	// Amount is the deposited amount in the base units of the asset, e.g. wei
+	Amount   string `json:"amount" validate:"required,number"`
+	Decimals int    `json:"decimals" validate:"min=0"`
 }
 
 // AddDepositHandler is a command handler for AddDeposit
 type AddDepositHandler = common.CommandHandler[AddDeposit]
 
 type addDepositHandler struct {
-	repo *eventsourcing.EventRepository
+	repo             *eventsourcing.EventRepository
+	verifiers        DepositVerifiers
+	acceptUnverified bool
 }
 
-// NewAddDepositHandler creates a new AddDepositHandler
-func NewAddDepositHandler(repo *eventsourcing.EventRepository) *addDepositHandler {
-	return &addDepositHandler{repo: repo}
+// NewAddDepositHandler creates a new AddDepositHandler, the deposits are verified on chain by the verifier of the asset's chain.
+// The deposits are accepted unverified while their chain can't be reached when acceptUnverified is set.
+func NewAddDepositHandler(repo *eventsourcing.EventRepository, verifiers DepositVerifiers, acceptUnverified bool) *addDepositHandler {
+	return &addDepositHandler{repo: repo, verifiers: verifiers, acceptUnverified: acceptUnverified}
 }
 
-var ErrAlreadyHasDeposit = common.NewError("already_has_deposit", "pair already has a deposit for this asset")
+var (
+	ErrAlreadyHasDeposit    = common.NewError("already_has_deposit", "pair already has a deposit for this asset")
+	ErrInvalidDepositAmount = common.NewError("invalid_deposit_amount", "deposit amount is not valid for the asset")
+	ErrInvalidDepositTx     = common.NewError("invalid_deposit_tx", "deposit transaction is not valid")
+)
 
 // Handle implements the command handler interface
 func (h *addDepositHandler) Handle(ctx context.Context, cmd AddDeposit) (string, error) {
@@ -374,19 +820,36 @@ func (h *addDepositHandler) Handle(ctx context.Context, cmd AddDeposit) (string,
 		return "", ErrInvalidAssetForPair
 	}
 
-	// TODO: Check the tx hash in the blockchain
-
 	if p.HasDepositForAsset(cmd.Asset) {
 		return "", ErrAlreadyHasDeposit
 	}
 
+	amount, err := parseDepositAmount(cmd)
+	if err != nil {
+		return "", err
+	}
+
+	unverified, err := h.verifyDeposit(ctx, p, cmd, amount)
+	if err != nil {
+		return "", err
+	}
+
 	p.TrackChange(&p, &domain.AssetDeposited{
-		Asset:  cmd.Asset,
-		TxHash: cmd.TxHash,
+		Asset:      cmd.Asset,
+		TxHash:     cmd.TxHash,
+		Amount:     amount.String(),
+		Decimals:   cmd.Decimals,
+		Unverified: unverified,
 	})
 
 	if len(p.Deposits) == 2 {
-		p.TrackChange(&p, &domain.PairStatusChanged{Status: domain.PairStatusPreSignWithdrawal})
+		next := domain.PairStatusPreSignWithdrawal
+		if p.IsSavers() {
+			next = domain.PairStatusPreSignSaversWithdrawal
+		}
+		if err := changePairStatus(&p, next); err != nil {
+			return "", err
+		}
 	}
 
 	if err := h.repo.Save(&p); err != nil {
@@ -396,6 +859,50 @@ func (h *addDepositHandler) Handle(ctx context.Context, cmd AddDeposit) (string,
 	return p.ID(), nil
 }
 
+// parseDepositAmount checks that the amount is positive and expressed in the decimals of the asset
+func parseDepositAmount(cmd AddDeposit) (*big.Int, error) {
+	info, _ := domain.LookupAsset(cmd.Asset)
+	if cmd.Decimals != info.Decimals {
+		return nil, ErrInvalidDepositAmount.IncludeMeta(map[string]interface{}{"decimals": info.Decimals})
+	}
+
+	amount, ok := new(big.Int).SetString(cmd.Amount, 10)
+	if !ok || amount.Sign() <= 0 {
+		return nil, ErrInvalidDepositAmount.IncludeMeta(map[string]interface{}{"reason": "amount must be a positive integer"})
+	}
+
+	return amount, nil
+}
+
+// verifyDeposit checks on chain that the participant transferred the amount to the pair's wallet, it tells if the deposit
+// is accepted unverified
+func (h *addDepositHandler) verifyDeposit(ctx context.Context, p domain.Pair, cmd AddDeposit, amount *big.Int) (bool, error) {
+	verifier, ok := h.verifiers.forAsset(cmd.Asset)
+	if !ok {
+		// The deposits of the chains without a verifier can't be told apart from made up ones
+		return unavailableChain(cmd.Asset, h.acceptUnverified)
+	}
+
+	err := verifier.VerifyDeposit(ctx, DepositTx{
+		Asset:  cmd.Asset,
+		TxHash: cmd.TxHash,
+		From:   cmd.ParticipantAddress,
+		To:     p.Wallet.Addresses[cmd.Asset],
+		Amount: amount,
+	})
+	if errors.Is(err, ErrTxMismatch) {
+		return false, ErrInvalidDepositTx.IncludeMeta(map[string]interface{}{"reason": err.Error()})
+	}
+	if errors.Is(err, ErrChainUnavailable) {
+		return unavailableChain(cmd.Asset, h.acceptUnverified)
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to verify deposit transaction: %w", err)
+	}
+
+	return false, nil
+}
+
 // SignWithdrawal is a command to sign a withdrawal transaction
 type SignWithdrawal struct {
 	PairId             string          `json:"pair_id" validate:"required,uuid4"`
@@ -407,14 +914,20 @@ type SignWithdrawal struct {
 type SignWithdrawalHandler common.CommandHandler[SignWithdrawal]
 
 type signWithdrawalHandler struct {
-	repo *eventsourcing.EventRepository
+	repo       *eventsourcing.EventRepository
+	decoders   TxDecoders
+	nonces     *Nonces
+	signatures *Signatures
 }
 
-// NewSignWithdrawalHandler creates a new SignWithdrawalHandler
-func NewSignWithdrawalHandler(repo *eventsourcing.EventRepository) *signWithdrawalHandler {
-	return &signWithdrawalHandler{repo: repo}
+// NewSignWithdrawalHandler creates a new SignWithdrawalHandler, the nonces of the transactions are checked with nonces
+// and their signatures with signatures
+func NewSignWithdrawalHandler(repo *eventsourcing.EventRepository, decoders TxDecoders, nonces *Nonces, signatures *Signatures) *signWithdrawalHandler {
+	return &signWithdrawalHandler{repo: repo, decoders: decoders, nonces: nonces, signatures: signatures}
 }
 
+var ErrInvalidWithdrawalTx = common.NewError("invalid_withdrawal_tx", "withdrawal transaction is not valid")
+
 func (h *signWithdrawalHandler) Handle(ctx context.Context, cmd SignWithdrawal) (string, error) {
 	if err := common.Validate(cmd); err != nil {
 		return "", err
@@ -436,8 +949,20 @@ func (h *signWithdrawalHandler) Handle(ctx context.Context, cmd SignWithdrawal)
 		return "", ErrForbiddenPairForAddress
 	}
 
-	p.TrackChange(&p, &domain.WithdrawTxSigned{Tx: cmd.Tx})
-	p.TrackChange(&p, &domain.PairStatusChanged{Status: domain.PairStatusLP})
+	if err := h.validateWithdrawalTx(ctx, p, cmd.Tx); err != nil {
+		return "", err
+	}
+	if err := h.nonces.Check(ctx, p, domain.RuneAsset, cmd.Tx); err != nil {
+		return "", err
+	}
+	if err := h.signatures.Verify(p, domain.RuneAsset, cmd.Tx); err != nil {
+		return "", err
+	}
+
+	p.TrackChange(&p, &domain.WithdrawTxSigned{Tx: cmd.Tx})
+	if err := changePairStatus(&p, domain.PairStatusLP); err != nil {
+		return "", err
+	}
 
 	if err := h.repo.Save(&p); err != nil {
 		return "", fmt.Errorf("failed to save pair: %w", err)
@@ -446,11 +971,68 @@ func (h *signWithdrawalHandler) Handle(ctx context.Context, cmd SignWithdrawal)
 	return p.ID(), nil
 }
 
+// validateWithdrawalTx checks that the pre-signed transaction withdraws the whole LP position of the pair from THORChain.
+// The withdrawn funds are always sent back to the addresses that provided the liquidity, which are the pair's wallet addresses,
+// so the transaction is only required to be sent from the wallet's RUNE address with a withdraw memo for the pair's pool,
+// and with the withdrawal nonce of the assurance policy of THORChain.
+func (h *signWithdrawalHandler) validateWithdrawalTx(ctx context.Context, p domain.Pair, tx domain.SignedTx) error {
+	invalid := func(reason string) error {
+		return ErrInvalidWithdrawalTx.IncludeMeta(map[string]interface{}{"reason": reason})
+	}
+
+	if err := validateWithdrawalNonce(ctx, h.repo, p, domain.RuneAsset, tx, invalid); err != nil {
+		return err
+	}
+
+	decoder, ok := h.decoders.forAsset(domain.RuneAsset)
+	if !ok {
+		return nil
+	}
+
+	decoded, err := decoder.DecodeTx(tx)
+	if errors.Is(err, ErrTxMismatch) {
+		return invalid(err.Error())
+	}
+	if err != nil {
+		return fmt.Errorf("failed to decode withdrawal transaction: %w", err)
+	}
+
+	if decoded.Nonce != tx.Nonce {
+		return invalid(fmt.Sprintf("transaction nonce %d doesn't match the declared nonce %d", decoded.Nonce, tx.Nonce))
+	}
+	if !strings.EqualFold(decoded.From, p.Wallet.Addresses[domain.RuneAsset]) {
+		return invalid("transaction must be sent from the RUNE address of the pair's wallet")
+	}
+	if decoded.To != domain.EmptyAddress || (decoded.Amount != nil && decoded.Amount.Sign() != 0) {
+		return invalid("withdrawal transaction must not transfer any funds")
+	}
+	if !isFullWithdrawalMemo(decoded.Memo, p.Pool()) {
+		return invalid(fmt.Sprintf("memo must withdraw the whole position from %s", p.Pool()))
+	}
+
+	return nil
+}
+
+// isFullWithdrawalMemo checks if the THORChain memo withdraws all the liquidity from the pool, e.g. -:BTC.BTC:10000
+func isFullWithdrawalMemo(memo string, pool domain.Asset) bool {
+	parts := strings.Split(memo, ":")
+	if len(parts) < 3 {
+		return false
+	}
+
+	switch strings.ToUpper(parts[0]) {
+	case "-", "WITHDRAW", "WD":
+		return domain.IsMemoAsset(parts[1], pool) && parts[2] == "10000"
+	default:
+		return false
+	}
+}
+
 // SubmitLP is a command to update the pair with LP transactions of both assets
 type SubmitLP struct {
 	PairId             string         `json:"pair_id" validate:"required,uuid4"`
 	ParticipantAddress domain.Address `json:"participant_address" validate:"required"`
-	Asset              domain.Asset   `json:"asset" validate:"required"`
+	Asset              domain.Asset   `json:"asset" validate:"required,asset"`
 	TxHash             domain.TxHash  `json:"tx_hash" validate:"required"`
 }
 
@@ -458,17 +1040,22 @@ type SubmitLP struct {
 type SubmitLPHandler common.CommandHandler[SubmitLP]
 
 type submitLPHandler struct {
-	repo *eventsourcing.EventRepository
+	repo             *eventsourcing.EventRepository
+	verifiers        LPVerifiers
+	acceptUnverified bool
+	clock            common.Clock
 }
 
-// NewSubmitLPHandler creates a new SubmitLPHandler
-func NewSubmitLPHandler(repo *eventsourcing.EventRepository) *submitLPHandler {
-	return &submitLPHandler{repo: repo}
+// NewSubmitLPHandler creates a new SubmitLPHandler, the deadline of the pair is computed from the time of the clock.
+// The transactions are accepted unverified while their chain can't be reached when acceptUnverified is set.
+func NewSubmitLPHandler(repo *eventsourcing.EventRepository, verifiers LPVerifiers, acceptUnverified bool, clock common.Clock) *submitLPHandler {
+	return &submitLPHandler{repo: repo, verifiers: verifiers, acceptUnverified: acceptUnverified, clock: clock}
 }
 
-const week = 7 * 24 * time.Hour
-
-var ErrAlreadyHasLP = common.NewError("already_has_lp", "pair already has LP transactions for this asset")
+var (
+	ErrAlreadyHasLP = common.NewError("already_has_lp", "pair already has LP transactions for this asset")
+	ErrInvalidLPTx  = common.NewError("invalid_lp_tx", "transaction did not add liquidity from the pair's wallet to the expected pool")
+)
 
 // Handle implements the command handler interface
 func (h *submitLPHandler) Handle(ctx context.Context, cmd SubmitLP) (string, error) {
@@ -500,11 +1087,17 @@ func (h *submitLPHandler) Handle(ctx context.Context, cmd SubmitLP) (string, err
 		return "", ErrAlreadyHasLP
 	}
 
-	p.TrackChange(&p, &domain.LPDone{
-		Asset:    cmd.Asset,
-		TxHash:   cmd.TxHash,
-		Deadline: time.Now().Add(time.Duration(p.InvestingPeriod) * week),
-	})
+	unverified, err := h.verifyLP(ctx, p, cmd)
+	if err != nil {
+		return "", err
+	}
+
+	// The investing period starts once the liquidity of both assets is provided
+	lp := &domain.LPDone{Asset: cmd.Asset, TxHash: cmd.TxHash, Unverified: unverified}
+	if len(p.LP) == len(p.Assets)-1 {
+		lp.Deadline = p.DeadlineAfter(h.clock.Now())
+	}
+	p.TrackChange(&p, lp)
 
 	if err := h.repo.Save(&p); err != nil {
 		return "", fmt.Errorf("failed to save pair: %w", err)
@@ -513,6 +1106,31 @@ func (h *submitLPHandler) Handle(ctx context.Context, cmd SubmitLP) (string, err
 	return p.ID(), nil
 }
 
+func (h *submitLPHandler) verifyLP(ctx context.Context, p domain.Pair, cmd SubmitLP) (bool, error) {
+	verifier, ok := h.verifiers.forAsset(cmd.Asset)
+	if !ok {
+		return unavailableChain(cmd.Asset, h.acceptUnverified)
+	}
+
+	err := verifier.VerifyLP(ctx, LPTx{
+		Asset:  cmd.Asset,
+		TxHash: cmd.TxHash,
+		From:   p.Wallet.Addresses[cmd.Asset],
+		Pool:   p.Pool(),
+	})
+	if errors.Is(err, ErrTxMismatch) {
+		return false, ErrInvalidLPTx.IncludeMeta(map[string]interface{}{"reason": err.Error()})
+	}
+	if errors.Is(err, ErrChainUnavailable) {
+		return unavailableChain(cmd.Asset, h.acceptUnverified)
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to verify LP transaction: %w", err)
+	}
+
+	return false, nil
+}
+
 // SubmitWithdrawal is a command to submit a withdrawal transaction
 type SubmitWithdrawal struct {
 	PairId             string          `json:"pair_id" validate:"required,uuid4"`
@@ -524,12 +1142,13 @@ type SubmitWithdrawal struct {
 type SubmitWithdrawalHandler common.CommandHandler[SubmitWithdrawal]
 
 type submitWithdrawalHandler struct {
-	repo *eventsourcing.EventRepository
+	repo  *eventsourcing.EventRepository
+	clock common.Clock
 }
 
 // NewSubmitWithdrawalHandler creates a new SubmitWithdrawalHandler
-func NewSubmitWithdrawalHandler(repo *eventsourcing.EventRepository) *submitWithdrawalHandler {
-	return &submitWithdrawalHandler{repo: repo}
+func NewSubmitWithdrawalHandler(repo *eventsourcing.EventRepository, clock common.Clock) *submitWithdrawalHandler {
+	return &submitWithdrawalHandler{repo: repo, clock: clock}
 }
 
 // Handle implements the command handler interface
@@ -554,8 +1173,310 @@ func (h *submitWithdrawalHandler) Handle(ctx context.Context, cmd SubmitWithdraw
 		return "", ErrForbiddenPairForAddress
 	}
 
+	if !p.IsWithdrawalUnlocked(h.clock.Now()) {
+		return "", withdrawalLocked(p)
+	}
+
 	p.TrackChange(&p, &domain.Withdrawn{TxHash: cmd.TxHash})
-	p.TrackChange(&p, &domain.PairStatusChanged{Status: domain.PairStatusWithdrawn})
+	if err := changePairStatus(&p, domain.PairStatusWithdrawn); err != nil {
+		return "", err
+	}
+
+	if err := h.repo.Save(&p); err != nil {
+		return "", fmt.Errorf("failed to save pair: %w", err)
+	}
+
+	return p.ID(), nil
+}
+
+var ErrWithdrawalLocked = common.NewError("withdrawal_locked", "liquidity is locked until the deadline unless both participants agree to withdraw early")
+
+// withdrawalLocked returns ErrWithdrawalLocked along with the deadline of the pair once the last LP has set it
+func withdrawalLocked(p domain.Pair) error {
+	if p.Deadline.IsZero() {
+		return ErrWithdrawalLocked
+	}
+	return ErrWithdrawalLocked.IncludeMeta(map[string]interface{}{"deadline": p.Deadline})
+}
+
+// ProposeEarlyWithdrawal is a command for a participant to propose withdrawing the liquidity before the deadline
+type ProposeEarlyWithdrawal struct {
+	PairId             string         `json:"pair_id" validate:"required,uuid4"`
+	ParticipantAddress domain.Address `json:"participant_address" validate:"required"`
+	Reason             string         `json:"reason" validate:"max=1000"`
+}
+
+// ProposeEarlyWithdrawalHandler is a command handler for ProposeEarlyWithdrawal
+type ProposeEarlyWithdrawalHandler common.CommandHandler[ProposeEarlyWithdrawal]
+
+type proposeEarlyWithdrawalHandler struct {
+	repo  *eventsourcing.EventRepository
+	clock common.Clock
+}
+
+// NewProposeEarlyWithdrawalHandler creates a new ProposeEarlyWithdrawalHandler
+func NewProposeEarlyWithdrawalHandler(repo *eventsourcing.EventRepository, clock common.Clock) *proposeEarlyWithdrawalHandler {
+	return &proposeEarlyWithdrawalHandler{repo: repo, clock: clock}
+}
+
+var (
+	ErrEarlyWithdrawalAlreadyProposed = common.NewError("early_withdrawal_already_proposed", "early withdrawal is already proposed")
+	ErrEarlyWithdrawalNotProposed     = common.NewError("early_withdrawal_not_proposed", "early withdrawal is not proposed")
+	ErrOwnEarlyWithdrawalProposal     = common.NewError("own_early_withdrawal_proposal", "early withdrawal must be accepted by the counterparty")
+	ErrEarlyWithdrawalNotNeeded       = common.NewError("early_withdrawal_not_needed", "deadline has already passed")
+)
+
+// Handle implements the command handler interface
+func (h *proposeEarlyWithdrawalHandler) Handle(ctx context.Context, cmd ProposeEarlyWithdrawal) (string, error) {
+	if err := common.Validate(cmd); err != nil {
+		return "", err
+	}
+
+	p := domain.Pair{}
+	if err := h.repo.GetWithContext(ctx, cmd.PairId, &p); err != nil {
+		if err == eventsourcing.ErrAggregateNotFound {
+			return "", ErrPairNotFound
+		}
+		return "", fmt.Errorf("failed to get pair: %w", err)
+	}
+
+	if !p.IsInvested() {
+		return "", ErrInvalidPairStatus
+	}
+
+	if !p.HasParticipant(cmd.ParticipantAddress) {
+		return "", ErrForbiddenPairForAddress
+	}
+
+	if p.IsWithdrawalUnlocked(h.clock.Now()) {
+		return "", ErrEarlyWithdrawalNotNeeded
+	}
+
+	if p.EarlyWithdrawal != nil {
+		return "", ErrEarlyWithdrawalAlreadyProposed
+	}
+
+	p.TrackChange(&p, &domain.EarlyWithdrawalProposed{
+		ParticipantAddress: cmd.ParticipantAddress,
+		Reason:             cmd.Reason,
+	})
+
+	if err := h.repo.Save(&p); err != nil {
+		return "", fmt.Errorf("failed to save pair: %w", err)
+	}
+
+	return p.ID(), nil
+}
+
+// AcceptEarlyWithdrawal is a command for the counterparty to accept the proposal to withdraw before the deadline,
+// which unlocks the withdrawal of the pair
+type AcceptEarlyWithdrawal struct {
+	PairId             string         `json:"pair_id" validate:"required,uuid4"`
+	ParticipantAddress domain.Address `json:"participant_address" validate:"required"`
+}
+
+// AcceptEarlyWithdrawalHandler is a command handler for AcceptEarlyWithdrawal
+type AcceptEarlyWithdrawalHandler common.CommandHandler[AcceptEarlyWithdrawal]
+
+type acceptEarlyWithdrawalHandler struct {
+	repo *eventsourcing.EventRepository
+}
+
+// NewAcceptEarlyWithdrawalHandler creates a new AcceptEarlyWithdrawalHandler
+func NewAcceptEarlyWithdrawalHandler(repo *eventsourcing.EventRepository) *acceptEarlyWithdrawalHandler {
+	return &acceptEarlyWithdrawalHandler{repo: repo}
+}
+
+// Handle implements the command handler interface
+func (h *acceptEarlyWithdrawalHandler) Handle(ctx context.Context, cmd AcceptEarlyWithdrawal) (string, error) {
+	if err := common.Validate(cmd); err != nil {
+		return "", err
+	}
+
+	p := domain.Pair{}
+	if err := h.repo.GetWithContext(ctx, cmd.PairId, &p); err != nil {
+		if err == eventsourcing.ErrAggregateNotFound {
+			return "", ErrPairNotFound
+		}
+		return "", fmt.Errorf("failed to get pair: %w", err)
+	}
+
+	if !p.IsInvested() {
+		return "", ErrInvalidPairStatus
+	}
+
+	if !p.HasParticipant(cmd.ParticipantAddress) {
+		return "", ErrForbiddenPairForAddress
+	}
+
+	if p.EarlyWithdrawal == nil {
+		return "", ErrEarlyWithdrawalNotProposed
+	}
+
+	if p.EarlyWithdrawal.Agreed() {
+		return "", ErrEarlyWithdrawalAlreadyProposed
+	}
+
+	if p.EarlyWithdrawal.ProposedBy == cmd.ParticipantAddress {
+		return "", ErrOwnEarlyWithdrawalProposal
+	}
+
+	p.TrackChange(&p, &domain.EarlyWithdrawalAccepted{ParticipantAddress: cmd.ParticipantAddress})
+
+	if err := h.repo.Save(&p); err != nil {
+		return "", fmt.Errorf("failed to save pair: %w", err)
+	}
+
+	return p.ID(), nil
+}
+
+// ProposeExtension is a command for a participant to propose extending the investing period of the pair,
+// either by pushing out the deadline or by rolling the pair over into a new investing period
+type ProposeExtension struct {
+	PairId              string            `json:"pair_id" validate:"required,uuid4"`
+	ParticipantAddress  domain.Address    `json:"participant_address" validate:"required"`
+	InvestingPeriod     int               `json:"investing_period" validate:"required,min=1"`
+	InvestingPeriodUnit domain.PeriodUnit `json:"investing_period_unit" validate:"omitempty,oneof=day week month"`
+	Rollover            bool              `json:"rollover"`
+}
+
+// ProposeExtensionHandler is a command handler for ProposeExtension
+type ProposeExtensionHandler common.CommandHandler[ProposeExtension]
+
+type proposeExtensionHandler struct {
+	repo  *eventsourcing.EventRepository
+	clock common.Clock
+}
+
+// NewProposeExtensionHandler creates a new ProposeExtensionHandler
+func NewProposeExtensionHandler(repo *eventsourcing.EventRepository, clock common.Clock) *proposeExtensionHandler {
+	return &proposeExtensionHandler{repo: repo, clock: clock}
+}
+
+var (
+	ErrExtensionAlreadyProposed = common.NewError("extension_already_proposed", "extension is already proposed")
+	ErrExtensionNotProposed     = common.NewError("extension_not_proposed", "extension is not proposed")
+	ErrOwnExtensionProposal     = common.NewError("own_extension_proposal", "extension must be accepted by the counterparty")
+	ErrExtensionNotAvailable    = common.NewError("extension_not_available", "investing period can only be extended until the end of the grace period")
+)
+
+// Handle implements the command handler interface
+func (h *proposeExtensionHandler) Handle(ctx context.Context, cmd ProposeExtension) (string, error) {
+	if err := common.Validate(cmd); err != nil {
+		return "", err
+	}
+
+	p := domain.Pair{}
+	if err := h.repo.GetWithContext(ctx, cmd.PairId, &p); err != nil {
+		if err == eventsourcing.ErrAggregateNotFound {
+			return "", ErrPairNotFound
+		}
+		return "", fmt.Errorf("failed to get pair: %w", err)
+	}
+
+	if !p.IsInvested() {
+		return "", ErrInvalidPairStatus
+	}
+
+	if !p.HasParticipant(cmd.ParticipantAddress) {
+		return "", ErrForbiddenPairForAddress
+	}
+
+	if err := canExtend(p, h.clock.Now()); err != nil {
+		return "", err
+	}
+
+	if p.PendingExtension != nil {
+		return "", ErrExtensionAlreadyProposed
+	}
+
+	p.TrackChange(&p, &domain.ExtensionProposed{
+		ParticipantAddress:  cmd.ParticipantAddress,
+		InvestingPeriod:     cmd.InvestingPeriod,
+		InvestingPeriodUnit: domain.PeriodUnitOrDefault(cmd.InvestingPeriodUnit),
+		Rollover:            cmd.Rollover,
+	})
+
+	if err := h.repo.Save(&p); err != nil {
+		return "", fmt.Errorf("failed to save pair: %w", err)
+	}
+
+	return p.ID(), nil
+}
+
+// canExtend checks that the investing period of the pair started and the participants can still withdraw at the given time
+func canExtend(p domain.Pair, now time.Time) error {
+	if p.Deadline.IsZero() {
+		return ErrExtensionNotAvailable.IncludeMeta(map[string]interface{}{"reason": "investing period has not started yet"})
+	}
+	if now.After(p.GraceDeadline()) {
+		return ErrExtensionNotAvailable.IncludeMeta(map[string]interface{}{"grace_deadline": p.GraceDeadline()})
+	}
+	return nil
+}
+
+// AcceptExtension is a command for the counterparty to accept the proposed extension, which recomputes the deadline of the pair
+type AcceptExtension struct {
+	PairId             string         `json:"pair_id" validate:"required,uuid4"`
+	ParticipantAddress domain.Address `json:"participant_address" validate:"required"`
+}
+
+// AcceptExtensionHandler is a command handler for AcceptExtension
+type AcceptExtensionHandler common.CommandHandler[AcceptExtension]
+
+type acceptExtensionHandler struct {
+	repo  *eventsourcing.EventRepository
+	clock common.Clock
+}
+
+// NewAcceptExtensionHandler creates a new AcceptExtensionHandler
+func NewAcceptExtensionHandler(repo *eventsourcing.EventRepository, clock common.Clock) *acceptExtensionHandler {
+	return &acceptExtensionHandler{repo: repo, clock: clock}
+}
+
+// Handle implements the command handler interface
+func (h *acceptExtensionHandler) Handle(ctx context.Context, cmd AcceptExtension) (string, error) {
+	if err := common.Validate(cmd); err != nil {
+		return "", err
+	}
+
+	p := domain.Pair{}
+	if err := h.repo.GetWithContext(ctx, cmd.PairId, &p); err != nil {
+		if err == eventsourcing.ErrAggregateNotFound {
+			return "", ErrPairNotFound
+		}
+		return "", fmt.Errorf("failed to get pair: %w", err)
+	}
+
+	if !p.IsInvested() {
+		return "", ErrInvalidPairStatus
+	}
+
+	if !p.HasParticipant(cmd.ParticipantAddress) {
+		return "", ErrForbiddenPairForAddress
+	}
+
+	if p.PendingExtension == nil {
+		return "", ErrExtensionNotProposed
+	}
+
+	if p.PendingExtension.ParticipantAddress == cmd.ParticipantAddress {
+		return "", ErrOwnExtensionProposal
+	}
+
+	now := h.clock.Now()
+	if err := canExtend(p, now); err != nil {
+		return "", err
+	}
+
+	proposal := *p.PendingExtension
+	p.TrackChange(&p, &domain.ExtensionAccepted{
+		ParticipantAddress:  cmd.ParticipantAddress,
+		Deadline:            p.ExtendedDeadline(proposal, now),
+		Rollover:            proposal.Rollover,
+		InvestingPeriod:     proposal.InvestingPeriod,
+		InvestingPeriodUnit: proposal.InvestingPeriodUnit,
+	})
 
 	if err := h.repo.Save(&p); err != nil {
 		return "", fmt.Errorf("failed to save pair: %w", err)
@@ -563,3 +1484,488 @@ func (h *submitWithdrawalHandler) Handle(ctx context.Context, cmd SubmitWithdraw
 
 	return p.ID(), nil
 }
+
+// RequestRefund is a command for the participant who deposited to get refunded when the counterparty never deposited.
+// The assurances refunding the participant are released to them, and broadcasted by the server when Broadcast is set.
+type RequestRefund struct {
+	PairId             string         `json:"pair_id" validate:"required,uuid4"`
+	ParticipantAddress domain.Address `json:"participant_address" validate:"required"`
+	Broadcast          bool           `json:"broadcast"`
+}
+
+// RequestRefundHandler is a command handler for RequestRefund
+type RequestRefundHandler common.CommandHandler[RequestRefund]
+
+type requestRefundHandler struct {
+	repo         *eventsourcing.EventRepository
+	broadcasters TxBroadcasters
+	timeout      time.Duration
+	clock        common.Clock
+}
+
+// NewRequestRefundHandler creates a new RequestRefundHandler, a deposit can be refunded once the timeout has passed since it was made
+func NewRequestRefundHandler(repo *eventsourcing.EventRepository, broadcasters TxBroadcasters, timeout time.Duration, clock common.Clock) *requestRefundHandler {
+	return &requestRefundHandler{repo: repo, broadcasters: broadcasters, timeout: timeout, clock: clock}
+}
+
+var (
+	ErrRefundNotAvailable          = common.NewError("refund_not_available", "only a deposit the counterparty didn't match can be refunded")
+	ErrRefundNotDue                = common.NewError("refund_not_due", "refund timeout has not passed yet")
+	ErrRefundBroadcastNotSupported = common.NewError("refund_broadcast_not_supported", "refund can't be broadcasted on the chain of the asset")
+)
+
+// Handle implements the command handler interface
+func (h *requestRefundHandler) Handle(ctx context.Context, cmd RequestRefund) (string, error) {
+	if err := common.Validate(cmd); err != nil {
+		return "", err
+	}
+
+	p := domain.Pair{}
+	if err := h.repo.GetWithContext(ctx, cmd.PairId, &p); err != nil {
+		if err == eventsourcing.ErrAggregateNotFound {
+			return "", ErrPairNotFound
+		}
+		return "", fmt.Errorf("failed to get pair: %w", err)
+	}
+
+	if p.Status != domain.PairStatusDeposit {
+		return "", ErrInvalidPairStatus
+	}
+
+	if !p.HasParticipant(cmd.ParticipantAddress) {
+		return "", ErrForbiddenPairForAddress
+	}
+
+	asset := p.AssetOfParticipant(cmd.ParticipantAddress)
+	if !p.HasDepositForAsset(asset) || len(p.Deposits) != 1 || !p.HasAssurancesForAsset(asset) {
+		return "", ErrRefundNotAvailable
+	}
+
+	if due := p.DepositedAt[asset].Add(h.timeout); h.clock.Now().Before(due) {
+		return "", ErrRefundNotDue.IncludeMeta(map[string]interface{}{"available_at": due})
+	}
+
+	assurances := make([]domain.SignedTx, len(p.Assurances[asset]))
+	copy(assurances, p.Assurances[asset])
+	sort.SliceStable(assurances, func(i, j int) bool {
+		return assurances[i].Nonce < assurances[j].Nonce
+	})
+
+	refund := &domain.RefundIssued{
+		Asset:              asset,
+		ParticipantAddress: cmd.ParticipantAddress,
+		Assurances:         assurances,
+	}
+	if cmd.Broadcast {
+		hash, err := h.broadcast(ctx, asset, assurances)
+		if err != nil {
+			return "", err
+		}
+		refund.TxHash = hash
+	}
+
+	p.TrackChange(&p, refund)
+	if err := changePairStatus(&p, domain.PairStatusRefunded); err != nil {
+		return "", err
+	}
+
+	if err := h.repo.Save(&p); err != nil {
+		return "", fmt.Errorf("failed to save pair: %w", err)
+	}
+
+	return p.ID(), nil
+}
+
+// broadcast sends the assurance with the lowest nonce, which is the next nonce of the pair's wallet as nothing was sent from it yet
+func (h *requestRefundHandler) broadcast(ctx context.Context, asset domain.Asset, assurances []domain.SignedTx) (domain.TxHash, error) {
+	broadcaster, ok := h.broadcasters.forAsset(asset)
+	if !ok {
+		return "", ErrRefundBroadcastNotSupported
+	}
+
+	hash, err := broadcaster.Broadcast(ctx, assurances[0])
+	if err != nil {
+		return "", fmt.Errorf("failed to broadcast refund transaction: %w", err)
+	}
+
+	return hash, nil
+}
+
+// SettleWithdrawal is a command to confirm the withdrawal of a pair on chain and report the results of its participants
+type SettleWithdrawal struct {
+	PairId string `json:"pair_id" validate:"required,uuid4"`
+}
+
+// SettleWithdrawalHandler is a command handler for SettleWithdrawal
+type SettleWithdrawalHandler common.CommandHandler[SettleWithdrawal]
+
+type settleWithdrawalHandler struct {
+	repo      *eventsourcing.EventRepository
+	verifiers WithdrawalVerifiers
+	oracle    queries.PriceOracle
+	clock     common.Clock
+}
+
+// NewSettleWithdrawalHandler creates a new SettleWithdrawalHandler, the withdrawals are confirmed by the verifier of the RUNE chain
+// they are sent on and the withdrawn amounts are valued with the oracle
+func NewSettleWithdrawalHandler(repo *eventsourcing.EventRepository, verifiers WithdrawalVerifiers, oracle queries.PriceOracle, clock common.Clock) *settleWithdrawalHandler {
+	return &settleWithdrawalHandler{repo: repo, verifiers: verifiers, oracle: oracle, clock: clock}
+}
+
+var (
+	ErrAlreadySettled        = common.NewError("already_settled", "withdrawal is already settled")
+	ErrSettlementPending     = common.NewError("settlement_pending", "withdrawal is not executed yet")
+	ErrSettlementUnavailable = common.NewError("settlement_unavailable", "withdrawals can't be verified on chain")
+)
+
+// Handle implements the command handler interface
+func (h *settleWithdrawalHandler) Handle(ctx context.Context, cmd SettleWithdrawal) (string, error) {
+	if err := common.Validate(cmd); err != nil {
+		return "", err
+	}
+
+	p := domain.Pair{}
+	if err := h.repo.GetWithContext(ctx, cmd.PairId, &p); err != nil {
+		if err == eventsourcing.ErrAggregateNotFound {
+			return "", ErrPairNotFound
+		}
+		return "", fmt.Errorf("failed to get pair: %w", err)
+	}
+
+	if p.Status != domain.PairStatusWithdrawn || p.WithdrawnTx == nil {
+		return "", ErrInvalidPairStatus
+	}
+
+	if p.Settlement != nil {
+		return "", ErrAlreadySettled
+	}
+
+	verifier, ok := h.verifiers.forAsset(domain.RuneAsset)
+	if !ok || h.oracle == nil {
+		return "", ErrSettlementUnavailable
+	}
+
+	withdrawn, err := verifier.VerifyWithdrawal(ctx, WithdrawalTx{
+		TxHash: *p.WithdrawnTx,
+		From:   p.Wallet.Addresses[domain.RuneAsset],
+		Pool:   p.Pool(),
+	})
+	var report domain.SettlementReport
+	switch {
+	case errors.Is(err, ErrTxPending):
+		return "", ErrSettlementPending
+	case errors.Is(err, ErrTxMismatch):
+		report = domain.SettlementReport{TxHash: *p.WithdrawnTx, Reason: err.Error(), SettledAt: h.clock.Now()}
+	case err != nil:
+		return "", fmt.Errorf("failed to verify withdrawal: %w", err)
+	default:
+		prices := make(map[domain.Asset]float64, len(withdrawn))
+		for asset := range withdrawn {
+			price, err := h.oracle.PriceUSD(ctx, asset)
+			if err != nil {
+				return "", fmt.Errorf("failed to price %s: %w", asset, err)
+			}
+			prices[asset] = price
+		}
+		report = p.Settle(*p.WithdrawnTx, withdrawn, prices, h.clock.Now())
+	}
+
+	p.TrackChange(&p, &domain.WithdrawalSettled{Report: report})
+
+	if err := h.repo.Save(&p); err != nil {
+		return "", fmt.Errorf("failed to save pair: %w", err)
+	}
+
+	return p.ID(), nil
+}
+
+// TrackPairTxs is a command to check the transactions of a pair on chain and record the changes of their status
+type TrackPairTxs struct {
+	PairId string `json:"pair_id" validate:"required,uuid4"`
+}
+
+// TrackPairTxsHandler is a command handler for TrackPairTxs
+type TrackPairTxsHandler common.CommandHandler[TrackPairTxs]
+
+type trackPairTxsHandler struct {
+	repo     *eventsourcing.EventRepository
+	checkers TxStatusCheckers
+	clock    common.Clock
+}
+
+// NewTrackPairTxsHandler creates a new TrackPairTxsHandler, the transactions of the chains without a checker aren't tracked
+func NewTrackPairTxsHandler(repo *eventsourcing.EventRepository, checkers TxStatusCheckers, clock common.Clock) *trackPairTxsHandler {
+	return &trackPairTxsHandler{repo: repo, checkers: checkers, clock: clock}
+}
+
+// txDropTimeout is how long a transaction can stay out of the chain before it's considered dropped
+const txDropTimeout = 24 * time.Hour
+
+// Handle implements the command handler interface
+func (h *trackPairTxsHandler) Handle(ctx context.Context, cmd TrackPairTxs) (string, error) {
+	if err := common.Validate(cmd); err != nil {
+		return "", err
+	}
+
+	p := domain.Pair{}
+	if err := h.repo.GetWithContext(ctx, cmd.PairId, &p); err != nil {
+		if err == eventsourcing.ErrAggregateNotFound {
+			return "", ErrPairNotFound
+		}
+		return "", fmt.Errorf("failed to get pair: %w", err)
+	}
+
+	// The hashes are sorted so the changes are recorded in a stable order
+	hashes := make([]domain.TxHash, 0, len(p.Txs))
+	for hash := range p.Txs {
+		hashes = append(hashes, hash)
+	}
+	sort.Strings(hashes)
+
+	now := h.clock.Now()
+	for _, hash := range hashes {
+		tracked := p.Txs[hash]
+		if !tracked.Tracking(now) {
+			continue
+		}
+		checker, ok := h.checkers.forAsset(tracked.Asset)
+		if !ok {
+			continue
+		}
+
+		confirmation, err := checker.CheckTx(ctx, hash)
+		if err != nil {
+			return "", fmt.Errorf("failed to check transaction %s: %w", hash, err)
+		}
+		if change := txStatusChange(tracked, confirmation, now); change != nil {
+			change.TxHash = hash
+			p.TrackChange(&p, change)
+		}
+	}
+
+	if !p.UnsavedEvents() {
+		return p.ID(), nil
+	}
+	if err := h.repo.Save(&p); err != nil {
+		return "", fmt.Errorf("failed to save pair: %w", err)
+	}
+
+	return p.ID(), nil
+}
+
+// txStatusChange returns the change of status of the tracked transaction given its state on chain, nil when it's unchanged
+func txStatusChange(tracked domain.TrackedTx, confirmation TxConfirmation, now time.Time) *domain.TxStatusChanged {
+	switch {
+	case confirmation.Failed:
+		return &domain.TxStatusChanged{Status: domain.TxStatusFailed, Reason: confirmation.Reason}
+	case !confirmation.Mined && tracked.Status == domain.TxStatusConfirmed:
+		return &domain.TxStatusChanged{Status: domain.TxStatusReorged, Reason: "transaction is no longer in the chain"}
+	case !confirmation.Mined && now.Sub(tracked.UpdatedAt) > txDropTimeout:
+		return &domain.TxStatusChanged{Status: domain.TxStatusFailed, Reason: "transaction was dropped without being mined"}
+	case !confirmation.Mined:
+		return nil
+	case tracked.Status == domain.TxStatusConfirmed && confirmation.BlockHash != tracked.BlockHash:
+		return &domain.TxStatusChanged{Status: domain.TxStatusReorged, Reason: "transaction moved to another block"}
+	case tracked.Status != domain.TxStatusConfirmed && confirmation.Confirmations >= requiredConfirmationsOf(tracked.Asset):
+		return &domain.TxStatusChanged{
+			Status:        domain.TxStatusConfirmed,
+			Confirmations: confirmation.Confirmations,
+			BlockHash:     confirmation.BlockHash,
+		}
+	}
+
+	return nil
+}
+
+// ForcePairStatus is an admin command to override the status of a pair whose real-world state diverged
+type ForcePairStatus struct {
+	PairId   string            `json:"pair_id" validate:"required,uuid4"`
+	Status   domain.PairStatus `json:"status" validate:"required,oneof=waiting wallet_conformation assurance deposit pre_sign_withdrawal lp withdrawn invalid refunded"`
+	Operator string            `json:"operator" validate:"required"`
+	Reason   string            `json:"reason" validate:"required,max=1000"`
+}
+
+// ForcePairStatusHandler is a command handler for ForcePairStatus
+type ForcePairStatusHandler common.CommandHandler[ForcePairStatus]
+
+type forcePairStatusHandler struct {
+	repo *eventsourcing.EventRepository
+}
+
+// NewForcePairStatusHandler creates a new ForcePairStatusHandler
+func NewForcePairStatusHandler(repo *eventsourcing.EventRepository) *forcePairStatusHandler {
+	return &forcePairStatusHandler{repo: repo}
+}
+
+// Handle implements the command handler interface
+func (h *forcePairStatusHandler) Handle(ctx context.Context, cmd ForcePairStatus) (string, error) {
+	if err := common.Validate(cmd); err != nil {
+		return "", err
+	}
+
+	p := domain.Pair{}
+	if err := h.repo.GetWithContext(ctx, cmd.PairId, &p); err != nil {
+		if err == eventsourcing.ErrAggregateNotFound {
+			return "", ErrPairNotFound
+		}
+		return "", fmt.Errorf("failed to get pair: %w", err)
+	}
+
+	if err := p.ForceStatus(cmd.Status, cmd.Operator, cmd.Reason); err != nil {
+		return "", ErrInvalidPairStatus.IncludeMeta(map[string]interface{}{"reason": err.Error()})
+	}
+
+	if err := h.repo.Save(&p); err != nil {
+		return "", fmt.Errorf("failed to save pair: %w", err)
+	}
+
+	return p.ID(), nil
+}
+
+// RevertStaleMatch is a command to put a pair back in the waiting pool when its counterparty didn't confirm the wallet in time
+type RevertStaleMatch struct {
+	PairId string `json:"pair_id" validate:"required,uuid4"`
+}
+
+// RevertStaleMatchHandler is a command handler for RevertStaleMatch
+type RevertStaleMatchHandler common.CommandHandler[RevertStaleMatch]
+
+type revertStaleMatchHandler struct {
+	repo    *eventsourcing.EventRepository
+	timeout time.Duration
+	clock   common.Clock
+}
+
+// NewRevertStaleMatchHandler creates a new RevertStaleMatchHandler, the counterparties have timeout to confirm the wallet
+func NewRevertStaleMatchHandler(repo *eventsourcing.EventRepository, timeout time.Duration, clock common.Clock) *revertStaleMatchHandler {
+	return &revertStaleMatchHandler{repo: repo, timeout: timeout, clock: clock}
+}
+
+// ErrMatchNotStale is returned when the counterparty confirmed the wallet or can still confirm it
+var ErrMatchNotStale = errors.New("counterparty can still confirm the wallet")
+
+// Handle implements the command handler interface.
+// The pair keeps its creation time, so it gets matched again in the order it was first created in.
+func (h *revertStaleMatchHandler) Handle(ctx context.Context, cmd RevertStaleMatch) (string, error) {
+	if err := common.Validate(cmd); err != nil {
+		return "", err
+	}
+
+	p := domain.Pair{}
+	if err := h.repo.GetWithContext(ctx, cmd.PairId, &p); err != nil {
+		if err == eventsourcing.ErrAggregateNotFound {
+			return "", ErrPairNotFound
+		}
+		return "", fmt.Errorf("failed to get pair: %w", err)
+	}
+
+	if p.Status != domain.PairStatusWalletConformation {
+		return "", ErrInvalidPairStatus
+	}
+
+	// Only the counterparty drops out of the pair, the creator may have confirmed the wallet of the reverted match already
+	counterpartyAsset := p.Assets[1]
+	if p.Wallet.PublicKeys[counterpartyAsset] != "" || h.clock.Now().Before(p.MatchedAt.Add(h.timeout)) {
+		return "", ErrMatchNotStale
+	}
+
+	p.TrackChange(&p, &domain.MatchReverted{
+		ParticipantAddress: p.ParticipantsAddress[counterpartyAsset],
+		Reason:             fmt.Sprintf("wallet not confirmed within %s", h.timeout),
+		Cause:              domain.MatchRevertCauseUnconfirmed,
+	})
+	if err := changePairStatus(&p, domain.PairStatusWaiting); err != nil {
+		return "", err
+	}
+
+	if err := h.repo.Save(&p); err != nil {
+		return "", fmt.Errorf("failed to save pair: %w", err)
+	}
+
+	return p.ID(), nil
+}
+
+// ReportKeygenFailure is a command for a participant to report the participants failed to generate the wallet of the match.
+// KeygenFailures is the count of failures of the wallet they failed to generate, so the failure reported by both participants counts once.
+type ReportKeygenFailure struct {
+	PairId             string         `json:"pair_id" validate:"required,uuid4"`
+	ParticipantAddress domain.Address `json:"participant_address" validate:"required"`
+	KeygenFailures     int            `json:"keygen_failures" validate:"min=0"`
+	Reason             string         `json:"reason" validate:"max=256"`
+}
+
+// ReportKeygenFailureHandler is a command handler for ReportKeygenFailure
+type ReportKeygenFailureHandler common.CommandHandler[ReportKeygenFailure]
+
+type reportKeygenFailureHandler struct {
+	repo    *eventsourcing.EventRepository
+	retries int
+}
+
+// NewReportKeygenFailureHandler creates a new ReportKeygenFailureHandler, the wallet of a match is regenerated retries times
+// before the match is reverted
+func NewReportKeygenFailureHandler(repo *eventsourcing.EventRepository, retries int) *reportKeygenFailureHandler {
+	return &reportKeygenFailureHandler{repo: repo, retries: retries}
+}
+
+var ErrKeygenFailureReported = common.NewError("keygen_failure_reported", "keygen failure of the wallet is already reported")
+
+// Handle implements the command handler interface
+func (h *reportKeygenFailureHandler) Handle(ctx context.Context, cmd ReportKeygenFailure) (string, error) {
+	if err := common.Validate(cmd); err != nil {
+		return "", err
+	}
+
+	p, err := getPair(ctx, h.repo, cmd.PairId)
+	if err != nil {
+		return "", err
+	}
+
+	if p.Status != domain.PairStatusWalletConformation {
+		return "", ErrInvalidPairStatus
+	}
+
+	participantAsset := p.AssetOfParticipant(cmd.ParticipantAddress)
+	if participantAsset == "" {
+		return "", ErrForbiddenPairForAddress
+	}
+	if cmd.KeygenFailures != p.Wallet.KeygenFailures {
+		return "", ErrKeygenFailureReported.IncludeMeta(map[string]interface{}{"keygen_failures": p.Wallet.KeygenFailures})
+	}
+
+	failures := p.Wallet.KeygenFailures + 1
+	if failures > h.retries {
+		// The creator goes back to waiting for a counterparty they may succeed to generate a wallet with
+		counterpartyAsset := p.Assets[1]
+		p.TrackChange(p, &domain.MatchReverted{
+			ParticipantAddress: p.ParticipantsAddress[counterpartyAsset],
+			Reason:             fmt.Sprintf("wallet generation failed %d times", failures),
+			Cause:              domain.MatchRevertCauseKeygen,
+		})
+		if err := changePairStatus(p, domain.PairStatusWaiting); err != nil {
+			return "", err
+		}
+	} else {
+		encryptionKey, err := getHexEncodedRandomBytes()
+		if err != nil {
+			return "", fmt.Errorf("failed to generate encryption key: %w", err)
+		}
+		hexChainCode, err := getHexEncodedRandomBytes()
+		if err != nil {
+			return "", fmt.Errorf("failed to generate chain code: %w", err)
+		}
+		p.TrackChange(p, &domain.KeygenFailed{
+			ParticipantAsset:    participantAsset,
+			Reason:              cmd.Reason,
+			Failures:            failures,
+			WalletEncryptionKey: encryptionKey,
+			WalletHexChainCode:  hexChainCode,
+		})
+	}
+
+	if err := h.repo.Save(p); err != nil {
+		return "", fmt.Errorf("failed to save pair: %w", err)
+	}
+
+	return p.ID(), nil
+}
@@ -0,0 +1,160 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/co-defi/api-server/common"
+	"github.com/co-defi/api-server/domain"
+	"github.com/hallgren/eventsourcing"
+)
+
+// Types of the commands supported in the batches of pair commands
+const (
+	PairBatchConfirmWallet     = "confirm_wallet"
+	PairBatchSetAssurances     = "set_assurances"
+	PairBatchConfirmAssurances = "confirm_assurances"
+)
+
+// PairBatch is a command to execute an ordered list of commands on a pair at once, e.g. for the mobile clients confirming the wallet
+// and the assurances back-to-back. The commands are applied in order and the pair is saved once, so either all of them succeed or none does.
+type PairBatch struct {
+	PairId             string             `json:"pair_id" validate:"required,uuid4"`
+	ParticipantAddress domain.Address     `json:"participant_address" validate:"required"`
+	Commands           []PairBatchCommand `json:"commands" validate:"required,min=1,max=8,dive"`
+}
+
+// PairBatchCommand is a command of a batch, exactly one of its commands is set and issued on the pair and by the participant of the batch
+type PairBatchCommand struct {
+	ConfirmPairWallet *ConfirmPairWallet `json:"confirm_wallet,omitempty"`
+	SetPairAssurances *SetPairAssurances `json:"set_assurances,omitempty"`
+	ConfirmAssurances *ConfirmAssurances `json:"confirm_assurances,omitempty"`
+}
+
+// Type returns the type of the command set in the batch command, empty if none or more than one is set
+func (c PairBatchCommand) Type() string {
+	types := make([]string, 0, 1)
+	if c.ConfirmPairWallet != nil {
+		types = append(types, PairBatchConfirmWallet)
+	}
+	if c.SetPairAssurances != nil {
+		types = append(types, PairBatchSetAssurances)
+	}
+	if c.ConfirmAssurances != nil {
+		types = append(types, PairBatchConfirmAssurances)
+	}
+	if len(types) != 1 {
+		return ""
+	}
+
+	return types[0]
+}
+
+// PairBatchHandler is a command handler for PairBatch
+type PairBatchHandler common.CommandHandler[PairBatch]
+
+type pairBatchHandler struct {
+	repo              *eventsourcing.EventRepository
+	confirmWallet     *confirmPairWalletHandler
+	setAssurances     *setPairAssurancesHandler
+	confirmAssurances *confirmAssurancesHandler
+}
+
+// NewPairBatchHandler creates a new PairBatchHandler, the commands are verified as by their own handlers
+func NewPairBatchHandler(repo *eventsourcing.EventRepository, derivers WalletDerivers, decoders TxDecoders, fees *Fees, nonces *Nonces, signatures *Signatures) *pairBatchHandler {
+	return &pairBatchHandler{
+		repo:              repo,
+		confirmWallet:     NewConfirmPairWalletHandler(repo, derivers),
+		setAssurances:     NewSetPairAssurancesHandler(repo, decoders, fees, nonces, signatures),
+		confirmAssurances: NewConfirmAssurancesHandler(repo),
+	}
+}
+
+var errInvalidBatchCommand = errors.New("batch command must set exactly one command")
+
+// Handle implements the command handler interface.
+// The error of the failing command is returned with the index and the type of the command in its meta, none of the commands are saved then.
+func (h *pairBatchHandler) Handle(ctx context.Context, cmd PairBatch) (string, error) {
+	if err := common.Validate(cmd); err != nil {
+		return "", err
+	}
+
+	// The commands are validated with the batch, the rest of their checks runs before loading the pair as in their own handlers
+	for i, c := range cmd.Commands {
+		if err := h.check(cmd, c); err != nil {
+			return "", batchCommandError(i, c.Type(), err)
+		}
+	}
+
+	p, err := getPair(ctx, h.repo, cmd.PairId)
+	if err != nil {
+		return "", err
+	}
+
+	for i, c := range cmd.Commands {
+		if err := h.apply(ctx, p, c); err != nil {
+			return "", batchCommandError(i, c.Type(), err)
+		}
+	}
+
+	if err := h.repo.Save(p); err != nil {
+		return "", fmt.Errorf("failed to save pair: %w", err)
+	}
+
+	return p.ID(), nil
+}
+
+// check runs the checks of the command that don't need the pair, the command must be issued on the pair and by the participant of the batch
+func (h *pairBatchHandler) check(batch PairBatch, c PairBatchCommand) error {
+	switch c.Type() {
+	case PairBatchConfirmWallet:
+		if c.ConfirmPairWallet.PairId != batch.PairId || c.ConfirmPairWallet.ParticipantAddress != batch.ParticipantAddress {
+			return ErrForbiddenPairForAddress
+		}
+		return nil
+	case PairBatchSetAssurances:
+		if c.SetPairAssurances.PairId != batch.PairId || c.SetPairAssurances.ParticipantAddress != batch.ParticipantAddress {
+			return ErrForbiddenPairForAddress
+		}
+		return nil
+	case PairBatchConfirmAssurances:
+		if c.ConfirmAssurances.PairId != batch.PairId || c.ConfirmAssurances.ParticipantAddress != batch.ParticipantAddress {
+			return ErrForbiddenPairForAddress
+		}
+		return nil
+	}
+
+	return errInvalidBatchCommand
+}
+
+// apply applies the command on the pair without saving it
+func (h *pairBatchHandler) apply(ctx context.Context, p *domain.Pair, c PairBatchCommand) error {
+	switch c.Type() {
+	case PairBatchConfirmWallet:
+		return h.confirmWallet.apply(ctx, p, *c.ConfirmPairWallet)
+	case PairBatchSetAssurances:
+		return h.setAssurances.apply(ctx, p, *c.SetPairAssurances)
+	case PairBatchConfirmAssurances:
+		return h.confirmAssurances.apply(ctx, p, *c.ConfirmAssurances)
+	}
+
+	return errInvalidBatchCommand
+}
+
+// batchCommandError includes the index and the type of the failing command in the meta of its error
+func batchCommandError(index int, typ string, err error) error {
+	var e *common.Error
+	if !errors.As(err, &e) {
+		return fmt.Errorf("failed to apply command %d (%s): %w", index, typ, err)
+	}
+
+	meta := make(map[string]interface{}, len(e.Meta)+2)
+	for k, v := range e.Meta {
+		meta[k] = v
+	}
+	meta["command_index"] = index
+	meta["command_type"] = typ
+
+	return e.IncludeMeta(meta)
+}
@@ -0,0 +1,99 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/co-defi/api-server/common"
+	"github.com/co-defi/api-server/domain"
+	"github.com/hallgren/eventsourcing"
+)
+
+const (
+	// maxPairMessages is how many messages the participants of a pair may exchange overall
+	maxPairMessages = 500
+	// maxMessagesPerWindow is how many messages a participant may post within messageRateWindow
+	maxMessagesPerWindow = 10
+	messageRateWindow    = time.Minute
+)
+
+// PostPairMessage is a command for a participant to post a message to the counterparty of their pair
+type PostPairMessage struct {
+	PairId             string         `json:"pair_id" validate:"required,uuid4"`
+	ParticipantAddress domain.Address `json:"participant_address" validate:"required"`
+	Body               string         `json:"body" validate:"required,max=2000"`
+}
+
+// PostPairMessageHandler is a command handler for PostPairMessage, it returns the sequence number of the message in its pair
+type PostPairMessageHandler common.CommandHandler[PostPairMessage]
+
+type postPairMessageHandler struct {
+	repo  *eventsourcing.EventRepository
+	clock common.Clock
+}
+
+// NewPostPairMessageHandler creates a new PostPairMessageHandler
+func NewPostPairMessageHandler(repo *eventsourcing.EventRepository, clock common.Clock) *postPairMessageHandler {
+	return &postPairMessageHandler{repo: repo, clock: clock}
+}
+
+var (
+	ErrPairChatFull       = common.NewError("pair_chat_full", "pair has reached its maximum number of messages")
+	ErrMessageRateLimited = common.NewError("message_rate_limited", "too many messages posted, try again later")
+)
+
+// Handle implements the command handler interface
+func (h *postPairMessageHandler) Handle(ctx context.Context, cmd PostPairMessage) (string, error) {
+	if err := common.Validate(cmd); err != nil {
+		return "", err
+	}
+
+	p := domain.Pair{}
+	if err := h.repo.GetWithContext(ctx, cmd.PairId, &p); err != nil {
+		if err == eventsourcing.ErrAggregateNotFound {
+			return "", ErrPairNotFound
+		}
+		return "", fmt.Errorf("failed to get pair: %w", err)
+	}
+
+	if !p.HasParticipant(cmd.ParticipantAddress) {
+		return "", ErrForbiddenPairForAddress
+	}
+
+	// There is no one to talk to until the pair is matched
+	if p.Status == domain.PairStatusWaiting {
+		return "", ErrInvalidPairStatus
+	}
+
+	chat := domain.PairChat{}
+	if err := h.repo.GetWithContext(ctx, cmd.PairId, &chat); err != nil {
+		if err != eventsourcing.ErrAggregateNotFound {
+			return "", fmt.Errorf("failed to get pair chat: %w", err)
+		}
+
+		// The chat of a pair is created with its first message
+		if err := chat.SetID(cmd.PairId); err != nil {
+			return "", fmt.Errorf("failed to set pair chat id: %w", err)
+		}
+	}
+
+	if chat.Messages >= maxPairMessages {
+		return "", ErrPairChatFull.IncludeMeta(map[string]interface{}{"max_messages": maxPairMessages})
+	}
+	if chat.PostedSince(cmd.ParticipantAddress, h.clock.Now().Add(-messageRateWindow)) >= maxMessagesPerWindow {
+		return "", ErrMessageRateLimited.IncludeMeta(map[string]interface{}{"limit": maxMessagesPerWindow, "window": messageRateWindow.String()})
+	}
+
+	chat.TrackChange(&chat, &domain.PairMessagePosted{
+		PairId: cmd.PairId,
+		From:   cmd.ParticipantAddress,
+		Body:   cmd.Body,
+	})
+	if err := h.repo.Save(&chat); err != nil {
+		return "", fmt.Errorf("failed to save pair chat: %w", err)
+	}
+
+	return strconv.Itoa(chat.Messages), nil
+}
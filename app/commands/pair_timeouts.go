@@ -0,0 +1,129 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/co-defi/api-server/common"
+	"github.com/co-defi/api-server/domain"
+	"github.com/hallgren/eventsourcing"
+)
+
+// EscalateOverduePair is a command to escalate a pair that stayed in its status longer than the timeout of its plan.
+// The participants are reminded once the timeout is over, and the pair is reverted, expired or handed over to the operators
+// once it's over twice.
+type EscalateOverduePair struct {
+	PairId string `json:"pair_id" validate:"required,uuid4"`
+}
+
+// EscalateOverduePairHandler is a command handler for EscalateOverduePair
+type EscalateOverduePairHandler common.CommandHandler[EscalateOverduePair]
+
+type escalateOverduePairHandler struct {
+	repo  *eventsourcing.EventRepository
+	clock common.Clock
+}
+
+// NewEscalateOverduePairHandler creates a new EscalateOverduePairHandler
+func NewEscalateOverduePairHandler(repo *eventsourcing.EventRepository, clock common.Clock) *escalateOverduePairHandler {
+	return &escalateOverduePairHandler{repo: repo, clock: clock}
+}
+
+// ErrPairNotOverdue is returned when the pair has no timeout in its status or the next escalation isn't due yet
+var ErrPairNotOverdue = errors.New("pair isn't overdue in its status")
+
+// Handle implements the command handler interface, it returns the action taken on the pair
+func (h *escalateOverduePairHandler) Handle(ctx context.Context, cmd EscalateOverduePair) (string, error) {
+	if err := common.Validate(cmd); err != nil {
+		return "", err
+	}
+
+	p := domain.Pair{}
+	if err := h.repo.GetWithContext(ctx, cmd.PairId, &p); err != nil {
+		if err == eventsourcing.ErrAggregateNotFound {
+			return "", ErrPairNotFound
+		}
+		return "", fmt.Errorf("failed to get pair: %w", err)
+	}
+
+	// Pairs created before they were linked to their plans have no timeouts
+	if p.PlanId == "" || !awaitingParticipants(p) {
+		return "", ErrPairNotOverdue
+	}
+	plan, err := getPlan(ctx, h.repo, p.PlanId)
+	if err != nil {
+		return "", err
+	}
+
+	timeout := plan.StatusTimeout(p.Status)
+	if timeout == 0 {
+		return "", ErrPairNotOverdue
+	}
+
+	var action domain.OverdueAction
+	elapsed := h.clock.Now().Sub(p.StatusChangedAt)
+	switch {
+	case p.Escalation == "" && elapsed >= timeout:
+		action = domain.OverdueActionReminded
+	case p.Escalation == domain.OverdueActionReminded && elapsed >= 2*timeout:
+		action = finalEscalation(p)
+	default:
+		return "", ErrPairNotOverdue
+	}
+
+	p.TrackChange(&p, &domain.PairStatusOverdue{Status: p.Status, Action: action, Timeout: domain.Duration(timeout)})
+	switch action {
+	case domain.OverdueActionReverted:
+		p.TrackChange(&p, &domain.MatchReverted{
+			ParticipantAddress: p.ParticipantsAddress[p.Assets[1]],
+			Reason:             fmt.Sprintf("wallet not confirmed within %s", 2*timeout),
+			Cause:              domain.MatchRevertCauseUnconfirmed,
+		})
+		if err := changePairStatus(&p, domain.PairStatusWaiting); err != nil {
+			return "", err
+		}
+	case domain.OverdueActionExpired:
+		if err := changePairStatus(&p, domain.PairStatusInvalid); err != nil {
+			return "", err
+		}
+	}
+
+	if err := h.repo.Save(&p); err != nil {
+		return "", fmt.Errorf("failed to save pair: %w", err)
+	}
+
+	return string(action), nil
+}
+
+// awaitingParticipants tells whether the pair waits for its participants to move it forward,
+// the pairs whose liquidity is provided or whose assets are in the Savers vaults wait for their deadline instead
+func awaitingParticipants(p domain.Pair) bool {
+	switch p.Status {
+	case domain.PairStatusLP:
+		return len(p.LP) < 2
+	case domain.PairStatusSavers:
+		return !p.HasSaversForAsset(p.Assets[0]) || !p.HasSaversForAsset(p.Assets[1])
+	}
+	return true
+}
+
+// finalEscalation is the action taken on a pair still overdue after its participants were reminded.
+// The pairs are only reverted or expired before any asset is deposited, the others are left to the operators.
+func finalEscalation(p domain.Pair) domain.OverdueAction {
+	switch p.Status {
+	case domain.PairStatusWalletConformation:
+		if p.Wallet.PublicKeys[p.Assets[1]] == "" {
+			return domain.OverdueActionReverted
+		}
+		return domain.OverdueActionExpired
+	case domain.PairStatusAssurance:
+		return domain.OverdueActionExpired
+	case domain.PairStatusDeposit:
+		if len(p.Deposits) == 0 {
+			return domain.OverdueActionExpired
+		}
+	}
+
+	return domain.OverdueActionEscalated
+}
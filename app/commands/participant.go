@@ -0,0 +1,304 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/co-defi/api-server/common"
+	"github.com/co-defi/api-server/domain"
+	"github.com/hallgren/eventsourcing"
+)
+
+// ParticipantResolver tells the participant an address belongs to, empty when the address isn't registered
+type ParticipantResolver interface {
+	IdOf(ctx context.Context, address domain.Address) (string, error)
+}
+
+var (
+	ErrParticipantNotFound  = common.NewError("participant_not_found", "participant not found")
+	ErrAddressAlreadyLinked = common.NewError("address_already_linked", "address already belongs to a participant")
+)
+
+// getParticipant returns the participant the address belongs to, nil when the address isn't registered.
+// The participants are identified by their first address, so they are found before the resolver caught up with them.
+func getParticipant(ctx context.Context, repo *eventsourcing.EventRepository, resolver ParticipantResolver, address domain.Address) (*domain.Participant, error) {
+	id, err := resolver.IdOf(ctx, address)
+	if err != nil {
+		return nil, err
+	}
+	if id == "" {
+		id = address
+	}
+
+	p := domain.Participant{}
+	if err := repo.GetWithContext(ctx, id, &p); err != nil {
+		if err == eventsourcing.ErrAggregateNotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get participant: %w", err)
+	}
+
+	return &p, nil
+}
+
+// RegisterParticipant is a command to register the participant of an address the first time it authenticates
+type RegisterParticipant struct {
+	Chain   string         `json:"chain" validate:"required"`
+	Address domain.Address `json:"address" validate:"required"`
+}
+
+// RegisterParticipantHandler is a command handler for RegisterParticipant, it returns the id of the participant
+// whether it was just registered or not
+type RegisterParticipantHandler common.CommandHandler[RegisterParticipant]
+
+type registerParticipantHandler struct {
+	repo     *eventsourcing.EventRepository
+	resolver ParticipantResolver
+	mutex    sync.Mutex
+}
+
+// NewRegisterParticipantHandler creates a new RegisterParticipantHandler
+func NewRegisterParticipantHandler(repo *eventsourcing.EventRepository, resolver ParticipantResolver) *registerParticipantHandler {
+	return &registerParticipantHandler{repo: repo, resolver: resolver}
+}
+
+// Handle implements the command handler interface
+func (h *registerParticipantHandler) Handle(ctx context.Context, cmd RegisterParticipant) (string, error) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if err := common.Validate(cmd); err != nil {
+		return "", err
+	}
+
+	existing, err := getParticipant(ctx, h.repo, h.resolver, cmd.Address)
+	if err != nil {
+		return "", err
+	}
+	if existing != nil {
+		return existing.ID(), nil
+	}
+
+	p := domain.Participant{}
+	if err := p.SetID(cmd.Address); err != nil {
+		return "", fmt.Errorf("failed to set participant id: %w", err)
+	}
+	p.TrackChange(&p, &domain.ParticipantRegistered{Chain: cmd.Chain, Address: cmd.Address})
+	if err := h.repo.Save(&p); err != nil {
+		return "", fmt.Errorf("failed to save participant: %w", err)
+	}
+
+	return p.ID(), nil
+}
+
+// LinkParticipantAddress is a command for a participant to add an address they proved to own to their addresses
+type LinkParticipantAddress struct {
+	ParticipantAddress domain.Address `json:"participant_address" validate:"required"`
+	Chain              string         `json:"chain" validate:"required"`
+	Address            domain.Address `json:"address" validate:"required,nefield=ParticipantAddress"`
+}
+
+// LinkParticipantAddressHandler is a command handler for LinkParticipantAddress
+type LinkParticipantAddressHandler common.CommandHandler[LinkParticipantAddress]
+
+type linkParticipantAddressHandler struct {
+	repo     *eventsourcing.EventRepository
+	resolver ParticipantResolver
+	mutex    sync.Mutex
+}
+
+// NewLinkParticipantAddressHandler creates a new LinkParticipantAddressHandler
+func NewLinkParticipantAddressHandler(repo *eventsourcing.EventRepository, resolver ParticipantResolver) *linkParticipantAddressHandler {
+	return &linkParticipantAddressHandler{repo: repo, resolver: resolver}
+}
+
+// Handle implements the command handler interface
+func (h *linkParticipantAddressHandler) Handle(ctx context.Context, cmd LinkParticipantAddress) (string, error) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if err := common.Validate(cmd); err != nil {
+		return "", err
+	}
+
+	p, err := getParticipant(ctx, h.repo, h.resolver, cmd.ParticipantAddress)
+	if err != nil {
+		return "", err
+	}
+	if p == nil {
+		return "", ErrParticipantNotFound
+	}
+	if p.HasAddress(cmd.Address) {
+		return p.ID(), nil
+	}
+
+	// An address belongs to a single participant, even one that registered it on its own
+	other, err := getParticipant(ctx, h.repo, h.resolver, cmd.Address)
+	if err != nil {
+		return "", err
+	}
+	if other != nil {
+		return "", ErrAddressAlreadyLinked
+	}
+
+	p.TrackChange(p, &domain.ParticipantAddressLinked{Chain: cmd.Chain, Address: cmd.Address})
+	if err := h.repo.Save(p); err != nil {
+		return "", fmt.Errorf("failed to save participant: %w", err)
+	}
+
+	return p.ID(), nil
+}
+
+// AddressBlocklist blocks and unblocks the addresses on behalf of the operators
+type AddressBlocklist interface {
+	Block(ctx context.Context, address domain.Address, reason, operator string) error
+	Unblock(ctx context.Context, address domain.Address, reason, operator string) error
+}
+
+// BlockAddress is a command for an operator to block an address, or to unblock it with Unblock
+type BlockAddress struct {
+	Address  domain.Address `json:"address" validate:"required"`
+	Reason   string         `json:"reason" validate:"required,max=1000"`
+	Operator string         `json:"operator" validate:"required"`
+	Unblock  bool           `json:"unblock"`
+}
+
+// BlockAddressHandler is a command handler for BlockAddress, it returns the id of the participant of the address if any
+type BlockAddressHandler common.CommandHandler[BlockAddress]
+
+type blockAddressHandler struct {
+	repo      *eventsourcing.EventRepository
+	blocklist AddressBlocklist
+	resolver  ParticipantResolver
+}
+
+// NewBlockAddressHandler creates a new BlockAddressHandler
+func NewBlockAddressHandler(repo *eventsourcing.EventRepository, blocklist AddressBlocklist, resolver ParticipantResolver) *blockAddressHandler {
+	return &blockAddressHandler{repo: repo, blocklist: blocklist, resolver: resolver}
+}
+
+// Handle implements the command handler interface.
+// The blocklist is changed first as it is what the authentication and the commands check, the participant mirrors it.
+func (h *blockAddressHandler) Handle(ctx context.Context, cmd BlockAddress) (string, error) {
+	if err := common.Validate(cmd); err != nil {
+		return "", err
+	}
+
+	change := h.blocklist.Block
+	if cmd.Unblock {
+		change = h.blocklist.Unblock
+	}
+	if err := change(ctx, cmd.Address, cmd.Reason, cmd.Operator); err != nil {
+		return "", err
+	}
+
+	p, err := getParticipant(ctx, h.repo, h.resolver, cmd.Address)
+	if err != nil {
+		return "", err
+	}
+	if p == nil {
+		return "", nil
+	}
+
+	// The address may be given in another case than it was registered with
+	address := cmd.Address
+	for _, a := range p.Addresses {
+		if strings.EqualFold(string(a.Address), string(address)) {
+			address = a.Address
+		}
+	}
+
+	_, blocked := p.BlockedAddresses[address]
+	switch {
+	case cmd.Unblock && blocked:
+		p.TrackChange(p, &domain.ParticipantUnblocked{Address: address, Reason: cmd.Reason, Operator: cmd.Operator})
+	case !cmd.Unblock:
+		p.TrackChange(p, &domain.ParticipantBlocked{Address: address, Reason: cmd.Reason, Operator: cmd.Operator})
+	default:
+		return p.ID(), nil
+	}
+	if err := h.repo.Save(p); err != nil {
+		return "", fmt.Errorf("failed to save participant: %w", err)
+	}
+
+	return p.ID(), nil
+}
+
+// PersonalDataShredder crypto-shreds the personal data of an address
+type PersonalDataShredder interface {
+	// Shred crypto-shreds the personal data of the address and returns the personal fields made unreadable
+	Shred(ctx context.Context, address domain.Address) ([]string, error)
+}
+
+// RedactParticipantData is a command for an operator to crypto-shred the personal data of a participant on their request,
+// the data of every address of the participant is redacted
+type RedactParticipantData struct {
+	Address  domain.Address `json:"address" validate:"required"`
+	Reason   string         `json:"reason" validate:"required,max=1000"`
+	Operator string         `json:"operator" validate:"required"`
+}
+
+// RedactParticipantDataHandler is a command handler for RedactParticipantData, it returns the id of the participant of the address if any
+type RedactParticipantDataHandler common.CommandHandler[RedactParticipantData]
+
+type redactParticipantDataHandler struct {
+	repo     *eventsourcing.EventRepository
+	shredder PersonalDataShredder
+	resolver ParticipantResolver
+}
+
+// NewRedactParticipantDataHandler creates a new RedactParticipantDataHandler
+func NewRedactParticipantDataHandler(repo *eventsourcing.EventRepository, shredder PersonalDataShredder, resolver ParticipantResolver) *redactParticipantDataHandler {
+	return &redactParticipantDataHandler{repo: repo, shredder: shredder, resolver: resolver}
+}
+
+// Handle implements the command handler interface.
+// The data is shredded first as it's what the redaction is for, the participant records it.
+func (h *redactParticipantDataHandler) Handle(ctx context.Context, cmd RedactParticipantData) (string, error) {
+	if err := common.Validate(cmd); err != nil {
+		return "", err
+	}
+
+	p, err := getParticipant(ctx, h.repo, h.resolver, cmd.Address)
+	if err != nil {
+		return "", err
+	}
+
+	addresses := []domain.Address{cmd.Address}
+	if p != nil {
+		addresses = addresses[:0]
+		for _, a := range p.Addresses {
+			addresses = append(addresses, a.Address)
+		}
+	}
+	var fields []string
+	for _, address := range addresses {
+		shredded, err := h.shredder.Shred(ctx, address)
+		if err != nil {
+			return "", fmt.Errorf("failed to shred personal data of %s: %w", address, err)
+		}
+		for _, field := range shredded {
+			if !slices.Contains(fields, field) {
+				fields = append(fields, field)
+			}
+		}
+	}
+	if p == nil {
+		return "", nil
+	}
+
+	p.TrackChange(p, &domain.DataRedacted{
+		Addresses: addresses,
+		Fields:    fields,
+		Reason:    cmd.Reason,
+		Operator:  cmd.Operator,
+	})
+	if err := h.repo.Save(p); err != nil {
+		return "", fmt.Errorf("failed to save participant: %w", err)
+	}
+
+	return p.ID(), nil
+}
@@ -2,21 +2,36 @@ package commands
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/co-defi/api-server/common"
 	"github.com/co-defi/api-server/domain"
+	"github.com/go-playground/validator/v10"
 	"github.com/hallgren/eventsourcing"
 )
 
 // CreateNewPlan is a command to create a new plan
 type CreateNewPlan struct {
-	Assets          []domain.Asset                `json:"assets,omitempty" validate:"required,len=2"`
-	Security        domain.MultiSigWalletSecurity `json:"security,omitempty" validate:"required,oneof=2-2"`
-	Strategy        domain.ProfitSharingStrategy  `json:"strategy,omitempty" validate:"required,oneof=equal_share"`
-	Quantum         int                           `json:"quantum,omitempty" validate:"required,min=1"`
-	LossProtection  float64                       `json:"loss_protection,omitempty" validate:"required,min=0.1,max=0.5"`
-	InvestingPeriod int                           `json:"investing_period,omitempty" validate:"required,min=1"`
+	Type                domain.PlanType               `json:"type,omitempty" validate:"omitempty,oneof=lp savers"`
+	Assets              []domain.Asset                `json:"assets,omitempty" validate:"required,len=2,dive,asset"`
+	Security            domain.MultiSigWalletSecurity `json:"security,omitempty" validate:"required,oneof=2-2"`
+	Strategy            domain.ProfitSharingStrategy  `json:"strategy,omitempty" validate:"required,oneof=equal_share"`
+	Quantum             int                           `json:"quantum,omitempty" validate:"required,min=1"`
+	LossProtection      float64                       `json:"loss_protection,omitempty" validate:"required,min=0.1,max=0.5"`
+	InvestingPeriod     int                           `json:"investing_period,omitempty" validate:"required,min=1"`
+	InvestingPeriodUnit domain.PeriodUnit             `json:"investing_period_unit,omitempty" validate:"omitempty,oneof=day week month"`
+	GracePeriodDays     int                           `json:"grace_period_days,omitempty" validate:"min=0"`
+	MaxShareMultiplier  int                           `json:"max_share_multiplier,omitempty" validate:"omitempty,min=1"`
+	Network             domain.Network                `json:"network,omitempty" validate:"omitempty,network"`
+	MaxActivePairs      int                           `json:"max_active_pairs,omitempty" validate:"min=0"`
+	ActiveFrom          time.Time                     `json:"active_from,omitempty"`
+	ActiveUntil         time.Time                     `json:"active_until,omitempty" validate:"omitempty,gtfield=ActiveFrom"`
+	// StatusTimeouts are how long the pairs of the plan may stay in each status before they are escalated
+	StatusTimeouts map[domain.PairStatus]domain.Duration `json:"status_timeouts,omitempty" validate:"omitempty,dive,keys,timeout_status,endkeys,gt=0"`
+	// AssurancePolicies are the assurances the participants pre-sign by chain, the chains left out follow the default policy
+	AssurancePolicies map[string]domain.AssurancePolicy `json:"assurance_policies,omitempty" validate:"omitempty,dive,keys,chain,endkeys"`
 }
 
 // CreateNewPlanHandler is a command handler for CreateNewPlan
@@ -31,20 +46,40 @@ func NewCreateNewPlanHandler(repo *eventsourcing.EventRepository) *createNewPlan
 	return &createNewPlanHandler{repo: repo}
 }
 
+var ErrInvalidPlanAssets = common.NewError("invalid_plan_assets", "assets are not valid for the plan type")
+
 // Handle implements the command handler interface
 func (h *createNewPlanHandler) Handle(ctx context.Context, cmd CreateNewPlan) (string, error) {
 	if err := common.Validate(cmd); err != nil {
 		return "", err
 	}
 
+	planType := domain.PlanTypeOrDefault(cmd.Type)
+	if planType == domain.PlanTypeSavers && containsAsset(cmd.Assets, domain.RuneAsset) {
+		return "", ErrInvalidPlanAssets.IncludeMeta(map[string]interface{}{"reason": "RUNE has no Savers vault"})
+	}
+	if err := validateAssurancePolicies(cmd.AssurancePolicies); err != nil {
+		return "", err
+	}
+
 	p := domain.Plan{}
 	p.TrackChange(&p, &domain.PlanCreated{
-		Assets:          cmd.Assets,
-		Security:        cmd.Security,
-		Strategy:        cmd.Strategy,
-		Quantum:         cmd.Quantum,
-		LossProtection:  cmd.LossProtection,
-		InvestingPeriod: cmd.InvestingPeriod,
+		Type:                planType,
+		Assets:              cmd.Assets,
+		Security:            cmd.Security,
+		Strategy:            cmd.Strategy,
+		Quantum:             cmd.Quantum,
+		LossProtection:      cmd.LossProtection,
+		InvestingPeriod:     cmd.InvestingPeriod,
+		InvestingPeriodUnit: domain.PeriodUnitOrDefault(cmd.InvestingPeriodUnit),
+		GracePeriodDays:     cmd.GracePeriodDays,
+		MaxShareMultiplier:  cmd.MaxShareMultiplier,
+		Network:             domain.NetworkOrDefault(cmd.Network),
+		MaxActivePairs:      cmd.MaxActivePairs,
+		ActiveFrom:          cmd.ActiveFrom,
+		ActiveUntil:         cmd.ActiveUntil,
+		StatusTimeouts:      cmd.StatusTimeouts,
+		AssurancePolicies:   cmd.AssurancePolicies,
 	})
 	if err := h.repo.Save(&p); err != nil {
 		return "", fmt.Errorf("failed to save plan: %w", err)
@@ -52,3 +87,208 @@ func (h *createNewPlanHandler) Handle(ctx context.Context, cmd CreateNewPlan) (s
 
 	return p.ID(), nil
 }
+
+// PausePlan is an admin command to stop a plan from accepting new pairs
+type PausePlan struct {
+	PlanId   string `json:"plan_id" validate:"required,uuid4"`
+	Operator string `json:"operator" validate:"required"`
+	Reason   string `json:"reason" validate:"required,max=1000"`
+}
+
+// PausePlanHandler is a command handler for PausePlan
+type PausePlanHandler common.CommandHandler[PausePlan]
+
+type pausePlanHandler struct {
+	repo *eventsourcing.EventRepository
+}
+
+// NewPausePlanHandler creates a new PausePlanHandler
+func NewPausePlanHandler(repo *eventsourcing.EventRepository) *pausePlanHandler {
+	return &pausePlanHandler{repo: repo}
+}
+
+var (
+	ErrPlanNotFound      = common.NewError("plan_not_found", "plan not found")
+	ErrInvalidPlanStatus = common.NewError("invalid_plan_status", "plan status is not valid for this operation")
+)
+
+// Handle implements the command handler interface
+func (h *pausePlanHandler) Handle(ctx context.Context, cmd PausePlan) (string, error) {
+	if err := common.Validate(cmd); err != nil {
+		return "", err
+	}
+
+	p, err := getPlan(ctx, h.repo, cmd.PlanId)
+	if err != nil {
+		return "", err
+	}
+
+	if err := p.Pause(cmd.Operator, cmd.Reason); err != nil {
+		return "", ErrInvalidPlanStatus.IncludeMeta(map[string]interface{}{"reason": err.Error()})
+	}
+
+	if err := h.repo.Save(p); err != nil {
+		return "", fmt.Errorf("failed to save plan: %w", err)
+	}
+
+	return p.ID(), nil
+}
+
+// ResumePlan is an admin command to let a paused plan accept new pairs again
+type ResumePlan struct {
+	PlanId   string `json:"plan_id" validate:"required,uuid4"`
+	Operator string `json:"operator" validate:"required"`
+}
+
+// ResumePlanHandler is a command handler for ResumePlan
+type ResumePlanHandler common.CommandHandler[ResumePlan]
+
+type resumePlanHandler struct {
+	repo *eventsourcing.EventRepository
+}
+
+// NewResumePlanHandler creates a new ResumePlanHandler
+func NewResumePlanHandler(repo *eventsourcing.EventRepository) *resumePlanHandler {
+	return &resumePlanHandler{repo: repo}
+}
+
+// Handle implements the command handler interface
+func (h *resumePlanHandler) Handle(ctx context.Context, cmd ResumePlan) (string, error) {
+	if err := common.Validate(cmd); err != nil {
+		return "", err
+	}
+
+	p, err := getPlan(ctx, h.repo, cmd.PlanId)
+	if err != nil {
+		return "", err
+	}
+
+	if err := p.Resume(cmd.Operator); err != nil {
+		return "", ErrInvalidPlanStatus.IncludeMeta(map[string]interface{}{"reason": err.Error()})
+	}
+
+	if err := h.repo.Save(p); err != nil {
+		return "", fmt.Errorf("failed to save plan: %w", err)
+	}
+
+	return p.ID(), nil
+}
+
+// SetPlanStatusTimeouts is an admin command to change how long the pairs of a plan may stay in each status,
+// the statuses left out don't time out anymore
+type SetPlanStatusTimeouts struct {
+	PlanId         string                                `json:"plan_id" validate:"required,uuid4"`
+	StatusTimeouts map[domain.PairStatus]domain.Duration `json:"status_timeouts" validate:"dive,keys,timeout_status,endkeys,gt=0"`
+	Operator       string                                `json:"operator" validate:"required"`
+}
+
+// SetPlanStatusTimeoutsHandler is a command handler for SetPlanStatusTimeouts
+type SetPlanStatusTimeoutsHandler common.CommandHandler[SetPlanStatusTimeouts]
+
+type setPlanStatusTimeoutsHandler struct {
+	repo *eventsourcing.EventRepository
+}
+
+// NewSetPlanStatusTimeoutsHandler creates a new SetPlanStatusTimeoutsHandler
+func NewSetPlanStatusTimeoutsHandler(repo *eventsourcing.EventRepository) *setPlanStatusTimeoutsHandler {
+	return &setPlanStatusTimeoutsHandler{repo: repo}
+}
+
+// Handle implements the command handler interface
+func (h *setPlanStatusTimeoutsHandler) Handle(ctx context.Context, cmd SetPlanStatusTimeouts) (string, error) {
+	if err := common.Validate(cmd); err != nil {
+		return "", err
+	}
+
+	p, err := getPlan(ctx, h.repo, cmd.PlanId)
+	if err != nil {
+		return "", err
+	}
+
+	p.SetStatusTimeouts(cmd.StatusTimeouts, cmd.Operator)
+	if err := h.repo.Save(p); err != nil {
+		return "", fmt.Errorf("failed to save plan: %w", err)
+	}
+
+	return p.ID(), nil
+}
+
+// SetPlanAssurancePolicies is an admin command to change the assurances the participants of a plan pre-sign by chain,
+// the chains left out follow the default policy again. The assurances already signed aren't checked again.
+type SetPlanAssurancePolicies struct {
+	PlanId            string                            `json:"plan_id" validate:"required,uuid4"`
+	AssurancePolicies map[string]domain.AssurancePolicy `json:"assurance_policies" validate:"dive,keys,chain,endkeys"`
+	Operator          string                            `json:"operator" validate:"required"`
+}
+
+// SetPlanAssurancePoliciesHandler is a command handler for SetPlanAssurancePolicies
+type SetPlanAssurancePoliciesHandler common.CommandHandler[SetPlanAssurancePolicies]
+
+type setPlanAssurancePoliciesHandler struct {
+	repo *eventsourcing.EventRepository
+}
+
+// NewSetPlanAssurancePoliciesHandler creates a new SetPlanAssurancePoliciesHandler
+func NewSetPlanAssurancePoliciesHandler(repo *eventsourcing.EventRepository) *setPlanAssurancePoliciesHandler {
+	return &setPlanAssurancePoliciesHandler{repo: repo}
+}
+
+// Handle implements the command handler interface
+func (h *setPlanAssurancePoliciesHandler) Handle(ctx context.Context, cmd SetPlanAssurancePolicies) (string, error) {
+	if err := common.Validate(cmd); err != nil {
+		return "", err
+	}
+	if err := validateAssurancePolicies(cmd.AssurancePolicies); err != nil {
+		return "", err
+	}
+
+	p, err := getPlan(ctx, h.repo, cmd.PlanId)
+	if err != nil {
+		return "", err
+	}
+
+	p.SetAssurancePolicies(cmd.AssurancePolicies, cmd.Operator)
+	if err := h.repo.Save(p); err != nil {
+		return "", fmt.Errorf("failed to save plan: %w", err)
+	}
+
+	return p.ID(), nil
+}
+
+var ErrInvalidAssurancePolicy = common.NewError("invalid_assurance_policy", "assurance policy is not valid")
+
+// validateAssurancePolicies validates the policies of the chains, the maps values aren't validated by their tags
+func validateAssurancePolicies(policies map[string]domain.AssurancePolicy) error {
+	for chain, policy := range policies {
+		var invalid validator.ValidationErrors
+		if err := common.Validate(policy); errors.As(err, &invalid) {
+			meta := common.ErrorFromValidationErrors(invalid).Meta
+			meta["chain"] = chain
+			return ErrInvalidAssurancePolicy.IncludeMeta(meta)
+		}
+		seen := make(map[int]bool, len(policy.Nonces))
+		for _, nonce := range policy.Nonces {
+			if seen[nonce] {
+				return ErrInvalidAssurancePolicy.IncludeMeta(map[string]interface{}{"chain": chain, "reason": fmt.Sprintf("nonce %d is listed twice", nonce)})
+			}
+			seen[nonce] = true
+		}
+		if policy.MaxTxs > 0 && policy.MaxTxs < len(policy.Nonces) {
+			return ErrInvalidAssurancePolicy.IncludeMeta(map[string]interface{}{"chain": chain, "reason": "max_txs is less than the required nonces"})
+		}
+	}
+
+	return nil
+}
+
+func getPlan(ctx context.Context, repo *eventsourcing.EventRepository, id string) (*domain.Plan, error) {
+	p := domain.Plan{}
+	if err := repo.GetWithContext(ctx, id, &p); err != nil {
+		if err == eventsourcing.ErrAggregateNotFound {
+			return nil, ErrPlanNotFound
+		}
+		return nil, fmt.Errorf("failed to get plan: %w", err)
+	}
+
+	return &p, nil
+}
@@ -0,0 +1,294 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/co-defi/api-server/common"
+	"github.com/co-defi/api-server/domain"
+	"github.com/hallgren/eventsourcing"
+)
+
+// SignSaversWithdrawal is a command to pre-sign the transaction withdrawing an asset of a savers pair from its Savers vault
+type SignSaversWithdrawal struct {
+	PairId             string          `json:"pair_id" validate:"required,uuid4"`
+	ParticipantAddress domain.Address  `json:"participant_address" validate:"required"`
+	Asset              domain.Asset    `json:"asset" validate:"required,asset"`
+	Tx                 domain.SignedTx `json:"tx"`
+}
+
+// SignSaversWithdrawalHandler is a command handler for SignSaversWithdrawal
+type SignSaversWithdrawalHandler common.CommandHandler[SignSaversWithdrawal]
+
+type signSaversWithdrawalHandler struct {
+	repo       *eventsourcing.EventRepository
+	decoders   TxDecoders
+	nonces     *Nonces
+	signatures *Signatures
+}
+
+// NewSignSaversWithdrawalHandler creates a new SignSaversWithdrawalHandler, the nonces of the transactions are checked with nonces
+// and their signatures with signatures
+func NewSignSaversWithdrawalHandler(repo *eventsourcing.EventRepository, decoders TxDecoders, nonces *Nonces, signatures *Signatures) *signSaversWithdrawalHandler {
+	return &signSaversWithdrawalHandler{repo: repo, decoders: decoders, nonces: nonces, signatures: signatures}
+}
+
+var ErrAlreadySignedSaversWithdrawal = common.NewError("already_signed_savers_withdrawal", "savers withdrawal of this asset is already signed")
+
+// Handle implements the command handler interface
+func (h *signSaversWithdrawalHandler) Handle(ctx context.Context, cmd SignSaversWithdrawal) (string, error) {
+	if err := common.Validate(cmd); err != nil {
+		return "", err
+	}
+
+	p, err := getPair(ctx, h.repo, cmd.PairId)
+	if err != nil {
+		return "", err
+	}
+
+	if p.Status != domain.PairStatusPreSignSaversWithdrawal {
+		return "", ErrInvalidPairStatus
+	}
+
+	if !p.HasParticipant(cmd.ParticipantAddress) {
+		return "", ErrForbiddenPairForAddress
+	}
+
+	if !p.HasAsset(cmd.Asset) {
+		return "", ErrInvalidAssetForPair
+	}
+
+	if p.HasSaversWithdrawTxForAsset(cmd.Asset) {
+		return "", ErrAlreadySignedSaversWithdrawal
+	}
+
+	if err := h.validateSaversWithdrawalTx(ctx, *p, cmd.Asset, cmd.Tx); err != nil {
+		return "", err
+	}
+	if err := h.nonces.Check(ctx, *p, cmd.Asset, cmd.Tx); err != nil {
+		return "", err
+	}
+	if err := h.signatures.Verify(*p, cmd.Asset, cmd.Tx); err != nil {
+		return "", err
+	}
+
+	p.TrackChange(p, &domain.SaversWithdrawTxSigned{Asset: cmd.Asset, Tx: cmd.Tx})
+	// The assets go into the vaults once the withdrawals of both are secured
+	if p.HasSaversWithdrawTxForAsset(getSecondaryAsset(cmd.Asset, p.Assets)) {
+		if err := changePairStatus(p, domain.PairStatusSavers); err != nil {
+			return "", err
+		}
+	}
+
+	if err := h.repo.Save(p); err != nil {
+		return "", fmt.Errorf("failed to save pair: %w", err)
+	}
+
+	return p.ID(), nil
+}
+
+// validateSaversWithdrawalTx checks that the pre-signed transaction withdraws the whole position of the asset from its vault.
+// The vault pays the withdrawn funds back to the address that deposited them, which is the pair's wallet address of the asset,
+// so the transaction is only required to be sent from it with a withdraw memo for the vault. It has the withdrawal nonce of the
+// assurance policy of the asset's chain, like the withdrawal of RUNE in the LP pairs.
+func (h *signSaversWithdrawalHandler) validateSaversWithdrawalTx(ctx context.Context, p domain.Pair, asset domain.Asset, tx domain.SignedTx) error {
+	invalid := func(reason string) error {
+		return ErrInvalidWithdrawalTx.IncludeMeta(map[string]interface{}{"asset": asset, "reason": reason})
+	}
+
+	if err := validateWithdrawalNonce(ctx, h.repo, p, asset, tx, invalid); err != nil {
+		return err
+	}
+
+	decoder, ok := h.decoders.forAsset(asset)
+	if !ok {
+		return nil
+	}
+
+	decoded, err := decoder.DecodeTx(tx)
+	if errors.Is(err, ErrTxMismatch) {
+		return invalid(err.Error())
+	}
+	if err != nil {
+		return fmt.Errorf("failed to decode savers withdrawal transaction: %w", err)
+	}
+
+	if decoded.Nonce != tx.Nonce {
+		return invalid(fmt.Sprintf("transaction nonce %d doesn't match the declared nonce %d", decoded.Nonce, tx.Nonce))
+	}
+	if !strings.EqualFold(decoded.From, p.Wallet.Addresses[asset]) {
+		return invalid("transaction must be sent from the pair's wallet address of the asset")
+	}
+	vault := domain.SaversVault(asset)
+	if !isFullWithdrawalMemo(decoded.Memo, vault) {
+		return invalid(fmt.Sprintf("memo must withdraw the whole position from %s", vault))
+	}
+
+	return nil
+}
+
+// SubmitSavers is a command to update a savers pair with the transaction depositing one of its assets into its Savers vault
+type SubmitSavers struct {
+	PairId             string         `json:"pair_id" validate:"required,uuid4"`
+	ParticipantAddress domain.Address `json:"participant_address" validate:"required"`
+	Asset              domain.Asset   `json:"asset" validate:"required,asset"`
+	TxHash             domain.TxHash  `json:"tx_hash" validate:"required"`
+}
+
+// SubmitSaversHandler is a command handler for SubmitSavers
+type SubmitSaversHandler common.CommandHandler[SubmitSavers]
+
+type submitSaversHandler struct {
+	repo             *eventsourcing.EventRepository
+	verifiers        SaversVerifiers
+	acceptUnverified bool
+	clock            common.Clock
+}
+
+// NewSubmitSaversHandler creates a new SubmitSaversHandler, the deadline of the pair is computed from the time of the clock.
+// The transactions are accepted unverified while their chain can't be reached when acceptUnverified is set.
+func NewSubmitSaversHandler(repo *eventsourcing.EventRepository, verifiers SaversVerifiers, acceptUnverified bool, clock common.Clock) *submitSaversHandler {
+	return &submitSaversHandler{repo: repo, verifiers: verifiers, acceptUnverified: acceptUnverified, clock: clock}
+}
+
+var (
+	ErrAlreadyHasSavers = common.NewError("already_has_savers", "pair already deposited this asset into its Savers vault")
+	ErrInvalidSaversTx  = common.NewError("invalid_savers_tx", "transaction did not deposit the asset from the pair's wallet into its Savers vault")
+)
+
+// Handle implements the command handler interface
+func (h *submitSaversHandler) Handle(ctx context.Context, cmd SubmitSavers) (string, error) {
+	if err := common.Validate(cmd); err != nil {
+		return "", err
+	}
+
+	p, err := getPair(ctx, h.repo, cmd.PairId)
+	if err != nil {
+		return "", err
+	}
+
+	if p.Status != domain.PairStatusSavers {
+		return "", ErrInvalidPairStatus
+	}
+
+	if !p.HasParticipant(cmd.ParticipantAddress) {
+		return "", ErrForbiddenPairForAddress
+	}
+
+	if !p.HasAsset(cmd.Asset) {
+		return "", ErrInvalidAssetForPair
+	}
+
+	if p.HasSaversForAsset(cmd.Asset) {
+		return "", ErrAlreadyHasSavers
+	}
+
+	unverified, err := h.verifySavers(ctx, *p, cmd)
+	if err != nil {
+		return "", err
+	}
+
+	// The investing period starts once both assets are in their vaults
+	deposited := &domain.SaversDeposited{Asset: cmd.Asset, TxHash: cmd.TxHash, Unverified: unverified}
+	if p.HasSaversForAsset(getSecondaryAsset(cmd.Asset, p.Assets)) {
+		deposited.Deadline = p.DeadlineAfter(h.clock.Now())
+	}
+	p.TrackChange(p, deposited)
+
+	if err := h.repo.Save(p); err != nil {
+		return "", fmt.Errorf("failed to save pair: %w", err)
+	}
+
+	return p.ID(), nil
+}
+
+func (h *submitSaversHandler) verifySavers(ctx context.Context, p domain.Pair, cmd SubmitSavers) (bool, error) {
+	verifier, ok := h.verifiers.forAsset(cmd.Asset)
+	if !ok {
+		return unavailableChain(cmd.Asset, h.acceptUnverified)
+	}
+
+	err := verifier.VerifySavers(ctx, SaversTx{
+		Asset:  cmd.Asset,
+		TxHash: cmd.TxHash,
+		From:   p.Wallet.Addresses[cmd.Asset],
+		Vault:  domain.SaversVault(cmd.Asset),
+	})
+	if errors.Is(err, ErrTxMismatch) {
+		return false, ErrInvalidSaversTx.IncludeMeta(map[string]interface{}{"reason": err.Error()})
+	}
+	if errors.Is(err, ErrChainUnavailable) {
+		return unavailableChain(cmd.Asset, h.acceptUnverified)
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to verify savers transaction: %w", err)
+	}
+
+	return false, nil
+}
+
+// SubmitSaversWithdrawal is a command to submit the transaction withdrawing an asset of a savers pair from its Savers vault
+type SubmitSaversWithdrawal struct {
+	PairId             string          `json:"pair_id" validate:"required,uuid4"`
+	ParticipantAddress *domain.Address `json:"participant_address" validate:"-"`
+	Asset              domain.Asset    `json:"asset" validate:"required,asset"`
+	TxHash             domain.TxHash   `json:"tx_hash" validate:"required"`
+}
+
+// SubmitSaversWithdrawalHandler is a command handler for SubmitSaversWithdrawal
+type SubmitSaversWithdrawalHandler common.CommandHandler[SubmitSaversWithdrawal]
+
+type submitSaversWithdrawalHandler struct {
+	repo  *eventsourcing.EventRepository
+	clock common.Clock
+}
+
+// NewSubmitSaversWithdrawalHandler creates a new SubmitSaversWithdrawalHandler
+func NewSubmitSaversWithdrawalHandler(repo *eventsourcing.EventRepository, clock common.Clock) *submitSaversWithdrawalHandler {
+	return &submitSaversWithdrawalHandler{repo: repo, clock: clock}
+}
+
+var ErrAlreadyWithdrawnFromSavers = common.NewError("already_withdrawn_from_savers", "asset is already withdrawn from its Savers vault")
+
+// Handle implements the command handler interface
+func (h *submitSaversWithdrawalHandler) Handle(ctx context.Context, cmd SubmitSaversWithdrawal) (string, error) {
+	if err := common.Validate(cmd); err != nil {
+		return "", err
+	}
+
+	p, err := getPair(ctx, h.repo, cmd.PairId)
+	if err != nil {
+		return "", err
+	}
+
+	if p.Status != domain.PairStatusSavers || !p.HasSaversForAsset(cmd.Asset) {
+		return "", ErrInvalidPairStatus
+	}
+
+	if cmd.ParticipantAddress != nil && !p.HasParticipant(*cmd.ParticipantAddress) {
+		return "", ErrForbiddenPairForAddress
+	}
+
+	if p.HasSaversWithdrawnForAsset(cmd.Asset) {
+		return "", ErrAlreadyWithdrawnFromSavers
+	}
+
+	if !p.IsWithdrawalUnlocked(h.clock.Now()) {
+		return "", withdrawalLocked(*p)
+	}
+
+	p.TrackChange(p, &domain.SaversWithdrawn{Asset: cmd.Asset, TxHash: cmd.TxHash})
+	if p.HasSaversWithdrawnForAsset(getSecondaryAsset(cmd.Asset, p.Assets)) {
+		if err := changePairStatus(p, domain.PairStatusWithdrawn); err != nil {
+			return "", err
+		}
+	}
+
+	if err := h.repo.Save(p); err != nil {
+		return "", fmt.Errorf("failed to save pair: %w", err)
+	}
+
+	return p.ID(), nil
+}
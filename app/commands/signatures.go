@@ -0,0 +1,118 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/co-defi/api-server/common"
+	"github.com/co-defi/api-server/domain"
+)
+
+// SignatureVerifier verifies the signatures of a scheme made over the hash of a payload with the key of the public key
+type SignatureVerifier interface {
+	VerifySignature(publicKey, payloadHash, signature []byte) error
+}
+
+// SignatureVerifiers holds the signature verifiers by the scheme they verify
+type SignatureVerifiers map[domain.SignatureScheme]SignatureVerifier
+
+// WalletKeyDeriver derives the public key of a pair's wallet address on a chain, the key its transactions are signed with,
+// from the public key generated by the participants and the chain code of the pair. It's implemented by the wallet derivers
+// of the chains whose keys are derived, the transactions of the other chains are signed with the wallet public key as is.
+type WalletKeyDeriver interface {
+	DerivePublicKey(publicKey, hexChainCode string) ([]byte, error)
+}
+
+// TxSigningHasher computes the hash a transaction is signed by on its chain along with the scheme the chain signs with.
+// It's implemented by the decoders of the chains whose signatures are verified, the transactions of the other chains are
+// rejected unless the unverified transactions are accepted.
+type TxSigningHasher interface {
+	SigningHash(tx domain.SignedTx) (domain.SignatureScheme, []byte, error)
+}
+
+var ErrInvalidTxSignature = common.NewError("invalid_tx_signature", "transaction is not signed by the pair's wallet")
+
+// Signatures checks the transactions pre-signed by the participants are signed by the pair's wallet, with the verifier
+// of the signature scheme of their chain
+type Signatures struct {
+	verifiers        SignatureVerifiers
+	decoders         TxDecoders
+	derivers         WalletDerivers
+	acceptUnverified bool
+}
+
+// NewSignatures creates a new Signatures verifying the signatures with the verifiers of their scheme over the hash computed
+// by the decoders of their chain, the keys of the wallets are derived by the derivers of their chain. The transactions of
+// the chains whose signatures can't be verified are accepted when acceptUnverified is set, they are rejected otherwise.
+func NewSignatures(verifiers SignatureVerifiers, decoders TxDecoders, derivers WalletDerivers, acceptUnverified bool) *Signatures {
+	return &Signatures{verifiers: verifiers, decoders: decoders, derivers: derivers, acceptUnverified: acceptUnverified}
+}
+
+// Verify checks the transaction sent from the wallet address of the asset of the pair is signed by the wallet. The signature
+// is verified over the hash computed from the transaction, the chain, the scheme and the payload hash sent along must match
+// the ones of the chain. The transactions of the chains whose decoder doesn't compute the signing hash are rejected, unless
+// the unverified transactions are accepted.
+func (s *Signatures) Verify(p domain.Pair, asset domain.Asset, tx domain.SignedTx) error {
+	invalid := func(reason string) error {
+		return ErrInvalidTxSignature.IncludeMeta(map[string]interface{}{"asset": asset, "nonce": tx.Nonce, "reason": reason})
+	}
+
+	info, _ := domain.LookupAsset(asset)
+	if tx.Chain != info.Chain {
+		return invalid(fmt.Sprintf("transaction must be for chain %s", info.Chain))
+	}
+	var hasher TxSigningHasher
+	if decoder, ok := s.decoders.forAsset(asset); ok {
+		hasher, _ = decoder.(TxSigningHasher)
+	}
+	if hasher == nil {
+		if s.acceptUnverified {
+			return nil
+		}
+		return invalid(fmt.Sprintf("signatures of chain %s can't be verified", info.Chain))
+	}
+
+	scheme, hash, err := hasher.SigningHash(tx)
+	if err != nil {
+		return invalid(err.Error())
+	}
+	if tx.SignatureScheme != scheme {
+		return invalid(fmt.Sprintf("transactions of chain %s are signed with %s", info.Chain, scheme))
+	}
+	if !bytes.Equal(tx.PayloadHash, hash) {
+		return invalid("payload hash is not the signing hash of the transaction")
+	}
+	verifier, ok := s.verifiers[scheme]
+	if !ok {
+		return invalid(fmt.Sprintf("signature scheme %s is not supported", scheme))
+	}
+	if p.Wallet == nil || p.Wallet.PublicKeys[asset] == "" {
+		return invalid("pair's wallet has no public key")
+	}
+
+	publicKey, err := s.walletKey(p.Wallet, asset)
+	if err != nil {
+		return invalid(err.Error())
+	}
+	if err := verifier.VerifySignature(publicKey, hash, tx.Signature); err != nil {
+		return invalid(err.Error())
+	}
+
+	return nil
+}
+
+// walletKey returns the public key of the wallet address of the asset
+func (s *Signatures) walletKey(wallet *domain.MultisigWallet, asset domain.Asset) ([]byte, error) {
+	if deriver, ok := s.derivers.forAsset(asset); ok {
+		if keyDeriver, ok := deriver.(WalletKeyDeriver); ok {
+			return keyDeriver.DerivePublicKey(wallet.PublicKeys[asset], wallet.HexChainCode)
+		}
+	}
+
+	publicKey, err := hex.DecodeString(wallet.PublicKeys[asset])
+	if err != nil {
+		return nil, fmt.Errorf("invalid wallet public key: %w", err)
+	}
+	return publicKey, nil
+}
@@ -0,0 +1,199 @@
+package compliance
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/co-defi/api-server/common"
+	"github.com/co-defi/api-server/domain"
+)
+
+// Status is the outcome of the verification of a participant
+type Status string
+
+const (
+	StatusApproved Status = "approved"
+	StatusRejected Status = "rejected"
+	// StatusPending is the status of the participants whose verification isn't completed yet by the provider
+	StatusPending Status = "pending"
+)
+
+// Result is the verification of a participant address by a provider
+type Result struct {
+	Address  domain.Address `json:"address"`
+	Status   Status         `json:"status"`
+	Reason   string         `json:"reason,omitempty"`
+	Provider string         `json:"provider"`
+	// CheckedAt is when the provider was asked, ExpiresAt when it will be asked again
+	CheckedAt time.Time `json:"checked_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Checker verifies the participants with an external provider (KYC, attestations...)
+type Checker interface {
+	// Name is the name of the provider recorded along the results
+	Name() string
+	// Check returns the status of the address by the provider, and the reason of a rejection
+	Check(ctx context.Context, address domain.Address) (Status, string, error)
+}
+
+var (
+	ErrNotApproved = common.NewError("participant_not_verified", "participant isn't verified by the compliance provider")
+	ErrUnavailable = common.NewError("compliance_unavailable", "compliance provider is unavailable, try again later")
+)
+
+// defaultResultsLimit is how many results are returned when no limit is given
+const defaultResultsLimit = 100
+
+// pendingRecheck is how long a pending verification is cached before asking the provider again
+const pendingRecheck = 5 * time.Minute
+
+// Service verifies the participants with the checker and caches the results per address for ttl in the compliance_checks table.
+// Without a checker every participant is approved and nothing is cached.
+type Service struct {
+	db      *common.DB
+	checker Checker
+	ttl     time.Duration
+	clock   common.Clock
+}
+
+// NewService creates a new Service and its table
+func NewService(db *common.DB, checker Checker, ttl time.Duration, clock common.Clock) (*Service, error) {
+	_, err := db.Write.Exec(`create table if not exists compliance_checks (
+		address TEXT PRIMARY KEY,
+		status TEXT,
+		reason TEXT,
+		provider TEXT,
+		checked_at TEXT,
+		expires_at TEXT
+	);`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create compliance_checks table: %w", err)
+	}
+
+	return &Service{db: db, checker: checker, ttl: ttl, clock: clock}, nil
+}
+
+// Enabled tells whether the participants are verified by a provider
+func (s *Service) Enabled() bool {
+	return s.checker != nil
+}
+
+// Require fails with ErrNotApproved unless the address is approved by the provider
+func (s *Service) Require(ctx context.Context, address domain.Address) error {
+	if !s.Enabled() {
+		return nil
+	}
+
+	result, err := s.Check(ctx, address, false)
+	if err != nil {
+		return err
+	}
+	if result.Status != StatusApproved {
+		return ErrNotApproved.IncludeMeta(map[string]interface{}{"status": result.Status, "reason": result.Reason})
+	}
+
+	return nil
+}
+
+// Check returns the verification of the address, from the cache unless it expired or refresh is set
+func (s *Service) Check(ctx context.Context, address domain.Address, refresh bool) (*Result, error) {
+	now := s.clock.Now()
+	if !s.Enabled() {
+		return &Result{Address: address, Status: StatusApproved, CheckedAt: now, ExpiresAt: now}, nil
+	}
+
+	if !refresh {
+		cached, err := s.Get(ctx, address)
+		if err != nil {
+			return nil, err
+		}
+		if cached != nil && now.Before(cached.ExpiresAt) {
+			return cached, nil
+		}
+	}
+
+	status, reason, err := s.checker.Check(ctx, address)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	ttl := s.ttl
+	if status == StatusPending {
+		ttl = min(ttl, pendingRecheck)
+	}
+	result := Result{
+		Address:   address,
+		Status:    status,
+		Reason:    reason,
+		Provider:  s.checker.Name(),
+		CheckedAt: now,
+		ExpiresAt: now.Add(ttl),
+	}
+
+	if _, err := s.db.Write.ExecContext(ctx, `insert into compliance_checks (address, status, reason, provider, checked_at, expires_at) values (?, ?, ?, ?, ?, ?)
+		on conflict (address) do update set status = excluded.status, reason = excluded.reason, provider = excluded.provider,
+			checked_at = excluded.checked_at, expires_at = excluded.expires_at;`,
+		result.Address, result.Status, result.Reason, result.Provider, formatTime(result.CheckedAt), formatTime(result.ExpiresAt)); err != nil {
+		return nil, fmt.Errorf("failed to cache compliance check: %w", err)
+	}
+
+	return &result, nil
+}
+
+// Get returns the cached verification of the address, nil when it was never checked
+func (s *Service) Get(ctx context.Context, address domain.Address) (*Result, error) {
+	result, err := scanResult(s.db.Read.QueryRowContext(ctx, `select address, status, reason, provider, checked_at, expires_at
+		from compliance_checks where address = ?;`, address))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	return result, err
+}
+
+// Results returns the cached verifications, of the given status only when not empty, the latest checked first
+func (s *Service) Results(ctx context.Context, status Status, limit int) ([]Result, error) {
+	if limit <= 0 {
+		limit = defaultResultsLimit
+	}
+	rows, err := s.db.Read.QueryContext(ctx, `select address, status, reason, provider, checked_at, expires_at from compliance_checks
+		where ? = '' or status = ? order by datetime(checked_at) desc limit ?;`, status, status, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query compliance checks: %w", err)
+	}
+	defer rows.Close()
+
+	results := []Result{}
+	for rows.Next() {
+		result, err := scanResult(rows)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, *result)
+	}
+
+	return results, rows.Err()
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+func scanResult(row interface{ Scan(...any) error }) (*Result, error) {
+	var (
+		result               Result
+		checkedAt, expiresAt string
+	)
+	if err := row.Scan(&result.Address, &result.Status, &result.Reason, &result.Provider, &checkedAt, &expiresAt); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to scan compliance check: %w", err)
+	}
+	result.CheckedAt, _ = time.Parse(time.RFC3339, checkedAt)
+	result.ExpiresAt, _ = time.Parse(time.RFC3339, expiresAt)
+
+	return &result, nil
+}
@@ -0,0 +1,353 @@
+package app
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/co-defi/api-server/app/audit"
+	"github.com/co-defi/api-server/app/queries"
+	"github.com/co-defi/api-server/common"
+	"github.com/co-defi/api-server/domain"
+	"github.com/hallgren/eventsourcing/core"
+	"github.com/rs/zerolog"
+)
+
+const (
+	// dataExportRetention is how long the archives of the data exports are kept for the participants to download them
+	dataExportRetention = 24 * time.Hour
+	// dataExportAuditLimit bounds the audit entries of an export, far above what a participant produces
+	dataExportAuditLimit = 100_000
+	// dataExportTimeout is how long an export may stay pending, past it it's deemed lost (e.g. with a restart) and can be requested again
+	dataExportTimeout = 10 * time.Minute
+)
+
+// DataExportStatus is the progress of a data export
+type DataExportStatus string
+
+const (
+	DataExportPending DataExportStatus = "pending"
+	DataExportReady   DataExportStatus = "ready"
+	DataExportFailed  DataExportStatus = "failed"
+)
+
+var (
+	ErrDataExportNotFound = common.NewError("data_export_not_found", "no data export requested")
+	ErrDataExportNotReady = common.NewError("data_export_not_ready", "data export is not ready")
+)
+
+// DataExport is the export of the personal data of an address, see DataExporter
+type DataExport struct {
+	Address     domain.Address   `json:"address"`
+	Status      DataExportStatus `json:"status"`
+	Error       string           `json:"error,omitempty"`
+	Size        int              `json:"size,omitempty"`
+	RequestedAt time.Time        `json:"requested_at"`
+	CompletedAt *time.Time       `json:"completed_at,omitempty"`
+	ExpiresAt   *time.Time       `json:"expires_at,omitempty"`
+}
+
+// DataExporter packages the personal data of an address in a ZIP archive of JSON files: the profile of the participant,
+// their pairs and the events of those, their audit entries and their notification settings.
+// The archives are built in the background and kept for a day, encrypted at rest when the events are.
+// An address has a single export, requesting a new one replaces it.
+type DataExporter struct {
+	db       *common.DB
+	store    core.EventStore
+	cipher   *common.Cipher
+	subjects *common.SubjectKeys
+	blobs    *common.BlobStore
+	queries  Queries
+	audit    *audit.Log
+	clock    common.Clock
+	logger   zerolog.Logger
+}
+
+// NewDataExporter creates a new DataExporter and its table
+func NewDataExporter(db *common.DB, store core.EventStore, cipher *common.Cipher, subjects *common.SubjectKeys, blobs *common.BlobStore, queries Queries, auditLog *audit.Log, clock common.Clock, logger zerolog.Logger) (*DataExporter, error) {
+	_, err := db.Write.Exec(`create table if not exists data_exports (
+		address TEXT PRIMARY KEY,
+		status TEXT,
+		error TEXT,
+		archive BLOB,
+		size INTEGER,
+		requested_at TEXT,
+		completed_at TEXT
+	);`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create data_exports table: %w", err)
+	}
+
+	return &DataExporter{db: db, store: store, cipher: cipher, subjects: subjects, blobs: blobs, queries: queries, audit: auditLog, clock: clock, logger: logger}, nil
+}
+
+// Request starts exporting the data of the address in the background and returns the pending export,
+// the export in progress is returned as is unless it's lost
+func (e *DataExporter) Request(ctx context.Context, address domain.Address) (*DataExport, error) {
+	export, err := e.Get(ctx, address)
+	if err != nil && !errors.Is(err, ErrDataExportNotFound) {
+		return nil, err
+	}
+	if err == nil && export.Status == DataExportPending && e.clock.Now().Sub(export.RequestedAt) < dataExportTimeout {
+		return export, nil
+	}
+
+	export = &DataExport{Address: address, Status: DataExportPending, RequestedAt: e.clock.Now().UTC()}
+	_, err = e.db.Write.ExecContext(ctx, `insert into data_exports (address, status, error, archive, size, requested_at, completed_at)
+		values (?, ?, '', null, 0, ?, null)
+		on conflict (address) do update set
+			status = excluded.status,
+			error = excluded.error,
+			archive = excluded.archive,
+			size = excluded.size,
+			requested_at = excluded.requested_at,
+			completed_at = excluded.completed_at;`,
+		address, export.Status, export.RequestedAt.Format(time.RFC3339))
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert data export: %w", err)
+	}
+
+	// The export outlives the request which asked for it
+	go e.run(context.WithoutCancel(ctx), address)
+
+	return export, nil
+}
+
+// Get returns the export of the address
+func (e *DataExporter) Get(ctx context.Context, address domain.Address) (*DataExport, error) {
+	row := e.db.Read.QueryRowContext(ctx, `select status, error, size, requested_at, completed_at from data_exports where address = ?;`, address)
+
+	var (
+		export      = DataExport{Address: address}
+		requestedAt string
+		completedAt sql.NullString
+	)
+	if err := row.Scan(&export.Status, &export.Error, &export.Size, &requestedAt, &completedAt); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrDataExportNotFound
+		}
+		return nil, fmt.Errorf("failed to scan data export: %w", err)
+	}
+
+	var err error
+	if export.RequestedAt, err = time.Parse(time.RFC3339, requestedAt); err != nil {
+		return nil, fmt.Errorf("failed to parse data export request time: %w", err)
+	}
+	if completedAt.Valid {
+		t, err := time.Parse(time.RFC3339, completedAt.String)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse data export completion time: %w", err)
+		}
+		expiresAt := t.Add(dataExportRetention)
+		export.CompletedAt, export.ExpiresAt = &t, &expiresAt
+	}
+
+	return &export, nil
+}
+
+// Archive returns the ZIP archive of the export of the address once it's ready
+func (e *DataExporter) Archive(ctx context.Context, address domain.Address) ([]byte, error) {
+	var (
+		status  DataExportStatus
+		archive []byte
+	)
+	err := e.db.Read.QueryRowContext(ctx, `select status, archive from data_exports where address = ?;`, address).Scan(&status, &archive)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrDataExportNotFound
+		}
+		return nil, fmt.Errorf("failed to scan data export archive: %w", err)
+	}
+	if status != DataExportReady {
+		return nil, ErrDataExportNotReady.IncludeMeta(map[string]interface{}{"status": status})
+	}
+
+	opened, err := e.cipher.Decrypt(string(archive))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt data export archive: %w", err)
+	}
+
+	return []byte(opened), nil
+}
+
+// Prune deletes the exports completed before the given time and returns how many were deleted
+func (e *DataExporter) Prune(ctx context.Context, before time.Time) (int64, error) {
+	res, err := e.db.Write.ExecContext(ctx, `delete from data_exports where completed_at is not null and datetime(completed_at) < datetime(?);`, before.UTC().Format(time.RFC3339))
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune data exports: %w", err)
+	}
+
+	return res.RowsAffected()
+}
+
+// run builds the archive of the address and completes its export, the failures are logged and only reported as such
+func (e *DataExporter) run(ctx context.Context, address domain.Address) {
+	archive, err := e.build(ctx, address)
+	if err == nil {
+		var sealed string
+		if sealed, err = e.cipher.Encrypt(string(archive)); err == nil {
+			err = e.complete(ctx, address, DataExportReady, "", []byte(sealed), len(archive))
+		}
+	}
+	if err != nil {
+		e.logger.Error().Err(err).Str("address", string(address)).Msg("failed to export data")
+		if err := e.complete(ctx, address, DataExportFailed, "export failed, request a new one", nil, 0); err != nil {
+			e.logger.Error().Err(err).Str("address", string(address)).Msg("failed to record data export failure")
+		}
+	}
+}
+
+func (e *DataExporter) complete(ctx context.Context, address domain.Address, status DataExportStatus, reason string, archive []byte, size int) error {
+	_, err := e.db.Write.ExecContext(ctx, `update data_exports set status = ?, error = ?, archive = ?, size = ?, completed_at = ? where address = ?;`,
+		status, reason, archive, size, e.clock.Now().UTC().Format(time.RFC3339), address)
+	return err
+}
+
+// dataExportManifest describes the archive, it's its manifest.json file
+type dataExportManifest struct {
+	Address     domain.Address `json:"address"`
+	GeneratedAt time.Time      `json:"generated_at"`
+	Files       []string       `json:"files"`
+}
+
+// build collects the data of the address and packages it in a ZIP archive
+func (e *DataExporter) build(ctx context.Context, address domain.Address) ([]byte, error) {
+	files := make(map[string]interface{})
+
+	participant, err := e.queries.Participants.GetByAddress(ctx, address)
+	if err != nil && !errors.Is(err, queries.ErrParticipantNotFound) {
+		return nil, fmt.Errorf("failed to get participant: %w", err)
+	}
+	if participant != nil {
+		files["participant.json"] = participant
+	}
+
+	pairs, err := e.queries.Pairs.Find(ctx, queries.PairFilter{ParticipantAddresses: []domain.Address{address}, IncludeArchived: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to find pairs: %w", err)
+	}
+	files["pairs.json"] = pairs
+
+	type aggregate struct{ id, typ string }
+	aggregates := []aggregate{{string(address), "NotificationSettings"}}
+	if participant != nil {
+		aggregates = append(aggregates, aggregate{participant.Id, "Participant"})
+	}
+	for _, p := range pairs {
+		aggregates = append(aggregates, aggregate{p.Id, "Pair"})
+	}
+	events := []EventRecord{}
+	for _, a := range aggregates {
+		records, err := e.events(ctx, a.id, a.typ)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, records...)
+	}
+	files["events.json"] = events
+
+	entries, err := e.audit.Find(ctx, audit.Filter{Address: string(address), Limit: dataExportAuditLimit})
+	if err != nil {
+		return nil, fmt.Errorf("failed to find audit entries: %w", err)
+	}
+	files["audit.json"] = entries
+
+	settings, err := e.queries.NotificationSettings.Get(ctx, address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get notification settings: %w", err)
+	}
+	files["notifications.json"] = settings
+
+	manifest := dataExportManifest{Address: address, GeneratedAt: e.clock.Now().UTC()}
+	for _, name := range []string{"participant.json", "pairs.json", "events.json", "audit.json", "notifications.json"} {
+		if _, ok := files[name]; ok {
+			manifest.Files = append(manifest.Files, name)
+		}
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	if err := writeZipJSON(zw, "manifest.json", manifest); err != nil {
+		return nil, err
+	}
+	for _, name := range manifest.Files {
+		if err := writeZipJSON(zw, name, files[name]); err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to close archive: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// events returns the events of the aggregate with their offloaded fields loaded and their encrypted and personal fields decrypted,
+// they are the participant's data too
+func (e *DataExporter) events(ctx context.Context, id, aggregateType string) ([]EventRecord, error) {
+	it, err := e.store.Get(ctx, id, aggregateType, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get events of %s %s: %w", aggregateType, id, err)
+	}
+	defer it.Close()
+
+	records := []EventRecord{}
+	for it.Next() {
+		event, err := it.Value()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read event of %s %s: %w", aggregateType, id, err)
+		}
+		for t, paths := range offloadedEventFields {
+			if t.Name() == event.Reason {
+				if event.Data, err = e.blobs.LoadJSON(event.Data, paths); err != nil {
+					return nil, fmt.Errorf("failed to load event of %s %s: %w", aggregateType, id, err)
+				}
+			}
+		}
+		for t, paths := range encryptedEventFields {
+			if t.Name() == event.Reason {
+				if event.Data, err = e.cipher.OpenJSON(event.Data, paths); err != nil {
+					return nil, fmt.Errorf("failed to decrypt event of %s %s: %w", aggregateType, id, err)
+				}
+			}
+		}
+		for t, fields := range personalEventFields {
+			if t.Name() == event.Reason {
+				if event.Data, err = e.subjects.OpenJSON(event.Data, fields.Paths); err != nil {
+					return nil, fmt.Errorf("failed to decrypt event of %s %s: %w", aggregateType, id, err)
+				}
+			}
+		}
+		records = append(records, newEventRecord(event))
+	}
+
+	return records, nil
+}
+
+func writeZipJSON(zw *zip.Writer, name string, v interface{}) error {
+	w, err := zw.Create(name)
+	if err != nil {
+		return fmt.Errorf("failed to add %s to archive: %w", name, err)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return fmt.Errorf("failed to write %s to archive: %w", name, err)
+	}
+
+	return nil
+}
+
+// Delete deletes the export of the address, e.g. when the personal data of the address is redacted
+func (e *DataExporter) Delete(ctx context.Context, address domain.Address) error {
+	if _, err := e.db.Write.ExecContext(ctx, `delete from data_exports where address = ?;`, address); err != nil {
+		return fmt.Errorf("failed to delete data export: %w", err)
+	}
+
+	return nil
+}
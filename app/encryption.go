@@ -0,0 +1,25 @@
+package app
+
+import (
+	"reflect"
+
+	"github.com/co-defi/api-server/common"
+	"github.com/co-defi/api-server/domain"
+)
+
+// encryptedEventFields holds the fields of the events encrypted at rest by event type: the wallet secrets shared with the
+// participants and the transactions they pre-signed, see common.Cipher.OpenJSON for the paths
+var encryptedEventFields = map[reflect.Type][]string{
+	reflect.TypeOf(domain.PairMatched{}):           {"wallet_encryption_key", "wallet_hex_chain_code"},
+	reflect.TypeOf(domain.KeygenFailed{}):          {"wallet_encryption_key", "wallet_hex_chain_code"},
+	reflect.TypeOf(domain.AssetAssuranceSigned{}):  {"tx.tx", "tx.signature"},
+	reflect.TypeOf(domain.WithdrawTxSigned{}):      {"tx.tx", "tx.signature"},
+	reflect.TypeOf(domain.RefundIssued{}):          {"assurances.*.tx", "assurances.*.signature"},
+	reflect.TypeOf(domain.MediatorShareEscrowed{}): {"share"},
+}
+
+// personalEventFields holds the personal fields of the events by event type, sealed with the keys of their subjects
+// so they are crypto-shredded on the request of the participants, see common.SubjectKeys
+var personalEventFields = map[reflect.Type]common.SubjectFields{
+	reflect.TypeOf(domain.NotificationSettingsUpdated{}): {Subject: "address", Paths: []string{"email", "push_token"}},
+}
@@ -0,0 +1,53 @@
+package app
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/co-defi/api-server/common"
+	"github.com/co-defi/api-server/domain"
+	"github.com/hallgren/eventsourcing/core"
+)
+
+// eventBlobThreshold is the length of the values from which they are offloaded from the events to the event_blobs table,
+// the transactions pre-signed by the participants are commonly a few kilobytes while their other fields are short
+const eventBlobThreshold = 1 << 10
+
+// offloadedEventFields holds the fields of the events offloaded to the event_blobs table when they are large, by event type:
+// the raw transactions pre-signed by the participants, see common.Cipher.OpenJSON for the paths
+var offloadedEventFields = map[reflect.Type][]string{
+	reflect.TypeOf(domain.AssetAssuranceSigned{}):   {"tx.tx"},
+	reflect.TypeOf(domain.WithdrawTxSigned{}):       {"tx.tx"},
+	reflect.TypeOf(domain.SaversWithdrawTxSigned{}): {"tx.tx"},
+	reflect.TypeOf(domain.RefundIssued{}):           {"assurances.*.tx"},
+}
+
+// inlineEventBlobs loads the offloaded fields of the stored event back into its data, e.g. so the exported events hold them
+func inlineEventBlobs(blobs *common.BlobStore, e core.Event) (core.Event, error) {
+	for t, paths := range offloadedEventFields {
+		if t.Name() == e.Reason {
+			data, err := blobs.LoadJSON(e.Data, paths)
+			if err != nil {
+				return e, fmt.Errorf("failed to load blobs of event %d: %w", e.GlobalVersion, err)
+			}
+			e.Data = data
+		}
+	}
+
+	return e, nil
+}
+
+// offloadEventBlobs offloads the large fields of the event to store, as the event encoder does
+func offloadEventBlobs(blobs *common.BlobStore, e core.Event) (core.Event, error) {
+	for t, paths := range offloadedEventFields {
+		if t.Name() == e.Reason {
+			data, err := blobs.OffloadJSON(e.Data, paths)
+			if err != nil {
+				return e, fmt.Errorf("failed to offload blobs of event %s/%d: %w", e.AggregateID, e.Version, err)
+			}
+			e.Data = data
+		}
+	}
+
+	return e, nil
+}
@@ -0,0 +1,224 @@
+package app
+
+import (
+	"bufio"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"time"
+
+	"github.com/co-defi/api-server/common"
+	"github.com/hallgren/eventsourcing/core"
+)
+
+const (
+	exportBatchSize   = 1000
+	maxEventLineBytes = 16 * 1024 * 1024
+)
+
+// EventRecord is the portable representation of a stored event used for export and import
+type EventRecord struct {
+	AggregateID   string          `json:"aggregate_id"`
+	AggregateType string          `json:"aggregate_type"`
+	Version       uint64          `json:"version"`
+	GlobalVersion uint64          `json:"global_version"`
+	Type          string          `json:"type"`
+	Payload       json.RawMessage `json:"payload"`
+	Metadata      json.RawMessage `json:"metadata,omitempty"`
+	Timestamp     time.Time       `json:"timestamp"`
+}
+
+func newEventRecord(e core.Event) EventRecord {
+	return EventRecord{
+		AggregateID:   e.AggregateID,
+		AggregateType: e.AggregateType,
+		Version:       uint64(e.Version),
+		GlobalVersion: uint64(e.GlobalVersion),
+		Type:          e.Reason,
+		Payload:       e.Data,
+		Metadata:      e.Metadata,
+		Timestamp:     e.Timestamp,
+	}
+}
+
+func (r EventRecord) toCoreEvent() core.Event {
+	return core.Event{
+		AggregateID:   r.AggregateID,
+		AggregateType: r.AggregateType,
+		Version:       core.Version(r.Version),
+		Reason:        r.Type,
+		Data:          r.Payload,
+		Metadata:      r.Metadata,
+		Timestamp:     r.Timestamp,
+	}
+}
+
+// ExportEvents streams the full event store in global order to w as newline-delimited JSON
+// and returns the number of exported events. The offloaded fields of the events are exported inline.
+func ExportEvents(db *sql.DB, w io.Writer) (int, error) {
+	store, err := createEventStore(db)
+	if err != nil {
+		return 0, err
+	}
+	blobs, err := common.NewBlobStore(db, eventBlobThreshold)
+	if err != nil {
+		return 0, err
+	}
+
+	enc := json.NewEncoder(w)
+	start := core.Version(1)
+	count := 0
+	for {
+		it, err := store.All(start, exportBatchSize)
+		if err != nil {
+			return count, fmt.Errorf("failed to fetch events: %w", err)
+		}
+
+		fetched := 0
+		for it.Next() {
+			e, err := it.Value()
+			if err != nil {
+				it.Close()
+				return count, fmt.Errorf("failed to read event: %w", err)
+			}
+			if e, err = inlineEventBlobs(blobs, e); err != nil {
+				it.Close()
+				return count, err
+			}
+
+			if err := enc.Encode(newEventRecord(e)); err != nil {
+				it.Close()
+				return count, fmt.Errorf("failed to write event %d: %w", e.GlobalVersion, err)
+			}
+			start = e.GlobalVersion + 1
+			fetched++
+		}
+		it.Close()
+
+		count += fetched
+		if fetched == 0 {
+			return count, nil
+		}
+	}
+}
+
+const (
+	defaultFeedLimit = 100
+	maxFeedLimit     = 1000
+	// maxFeedScan is how many events a page of the feed scans at most looking for the filtered ones,
+	// the cursor of the page moves past them so the next page resumes the scan
+	maxFeedScan = 10 * exportBatchSize
+)
+
+// EventFeedFilter selects the events of a page of the event feed, the empty filters match every event
+type EventFeedFilter struct {
+	// AfterSeq is the cursor of the page, the global version of the last event already consumed
+	AfterSeq       uint64
+	Limit          int
+	Types          []string
+	AggregateTypes []string
+}
+
+func (f EventFeedFilter) matches(e core.Event) bool {
+	return (len(f.Types) == 0 || slices.Contains(f.Types, e.Reason)) &&
+		(len(f.AggregateTypes) == 0 || slices.Contains(f.AggregateTypes, e.AggregateType))
+}
+
+// EventFeedPage is a page of the event feed, NextAfterSeq is the cursor of the next page
+type EventFeedPage struct {
+	Events       []EventRecord
+	NextAfterSeq uint64
+}
+
+// EventFeed returns the stored events after the cursor of the filter in global order, for the internal consumers
+// following the event stream. The encrypted fields of the events are returned as stored, their offloaded fields inline.
+func (app *Application) EventFeed(f EventFeedFilter) (*EventFeedPage, error) {
+	if f.Limit <= 0 || f.Limit > maxFeedLimit {
+		f.Limit = defaultFeedLimit
+	}
+
+	page := EventFeedPage{Events: []EventRecord{}, NextAfterSeq: f.AfterSeq}
+	scanned := 0
+	for scanned < maxFeedScan {
+		it, err := app.store.All(core.Version(page.NextAfterSeq+1), exportBatchSize)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch events: %w", err)
+		}
+
+		fetched := 0
+		for it.Next() {
+			e, err := it.Value()
+			if err != nil {
+				it.Close()
+				return nil, fmt.Errorf("failed to read event: %w", err)
+			}
+			fetched++
+			scanned++
+			page.NextAfterSeq = uint64(e.GlobalVersion)
+
+			if f.matches(e) {
+				if e, err = inlineEventBlobs(app.blobs, e); err != nil {
+					it.Close()
+					return nil, err
+				}
+				page.Events = append(page.Events, newEventRecord(e))
+			}
+			if len(page.Events) == f.Limit || scanned == maxFeedScan {
+				break
+			}
+		}
+		it.Close()
+
+		if fetched == 0 || len(page.Events) == f.Limit {
+			break
+		}
+	}
+
+	return &page, nil
+}
+
+// ImportEvents reads newline-delimited JSON events from r and appends them to the event store
+// in the given order and returns the number of imported events.
+// Aggregate versions must continue the versions already in the store, so importing is meant for empty stores.
+// The large fields of the events are offloaded as the events saved by the server are.
+func ImportEvents(db *sql.DB, r io.Reader) (int, error) {
+	store, err := createEventStore(db)
+	if err != nil {
+		return 0, err
+	}
+	blobs, err := common.NewBlobStore(db, eventBlobThreshold)
+	if err != nil {
+		return 0, err
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxEventLineBytes)
+
+	count := 0
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var record EventRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return count, fmt.Errorf("failed to decode event on line %d: %w", count+1, err)
+		}
+
+		event, err := offloadEventBlobs(blobs, record.toCoreEvent())
+		if err != nil {
+			return count, err
+		}
+		if err := store.Save([]core.Event{event}); err != nil {
+			return count, fmt.Errorf("failed to save event %s/%d: %w", record.AggregateID, record.Version, err)
+		}
+		count++
+	}
+	if err := scanner.Err(); err != nil {
+		return count, fmt.Errorf("failed to read events: %w", err)
+	}
+
+	return count, nil
+}
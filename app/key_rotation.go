@@ -0,0 +1,244 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/co-defi/api-server/app/queries"
+	"github.com/co-defi/api-server/common"
+)
+
+const (
+	// keyRotationBatchSize is the number of rows wrapped again in a single transaction,
+	// kept small so the rotation doesn't hold up the writes of the server
+	keyRotationBatchSize = 200
+	// keyRotationInterval is the pause between two batches of a rotation run by the server
+	keyRotationInterval = 100 * time.Millisecond
+)
+
+// keyRotationTables are the tables holding encrypted values, in the order they are rotated
+var keyRotationTables = []string{"events", "event_blobs", "pairs_query", "pairs_query_archive", "subject_keys"}
+
+// KeyRotation is the progress of wrapping the encrypted values of a table under a master key
+type KeyRotation struct {
+	KeyId string `json:"key_id"`
+	Table string `json:"table"`
+	// Position is the key of the last row of the table gone through
+	Position    string     `json:"position"`
+	Rewrapped   int        `json:"rewrapped"`
+	StartedAt   time.Time  `json:"started_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+}
+
+// KeyRotator wraps the data keys of the values encrypted under the previous master keys of the cipher with its current one.
+// It goes through the tables in small batches whose progress is tracked in the key_rotations table, so the server keeps
+// running meanwhile and an interrupted rotation resumes where it stopped. The previous keys can be retired once every table is completed.
+type KeyRotator struct {
+	db     *common.DB
+	cipher *common.Cipher
+	clock  common.Clock
+}
+
+// NewKeyRotator creates a new KeyRotator and its table
+func NewKeyRotator(db *common.DB, cipher *common.Cipher, clock common.Clock) (*KeyRotator, error) {
+	_, err := db.Write.Exec(`create table if not exists key_rotations (
+		key_id TEXT,
+		table_name TEXT,
+		position TEXT,
+		rewrapped INTEGER,
+		started_at TEXT,
+		updated_at TEXT,
+		completed_at TEXT,
+		PRIMARY KEY (key_id, table_name)
+	);`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create key_rotations table: %w", err)
+	}
+
+	return &KeyRotator{db: db, cipher: cipher, clock: clock}, nil
+}
+
+// RotateBatch wraps the values of the next batch of the first table not completed yet under the current master key.
+// It returns the number of rows wrapped again and whether the rotation is completed.
+func (r *KeyRotator) RotateBatch(ctx context.Context) (int, bool, error) {
+	tx, err := r.db.Write.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, false, err
+	}
+	defer tx.Rollback()
+
+	for _, table := range keyRotationTables {
+		rotation, err := r.rotation(ctx, tx, table)
+		if err != nil {
+			return 0, false, err
+		}
+		if rotation.CompletedAt != nil {
+			continue
+		}
+
+		var last string
+		var rewrapped int
+		switch table {
+		case "events":
+			last, rewrapped, err = r.rewrapEvents(ctx, tx, rotation.Position)
+		case "event_blobs":
+			last, rewrapped, err = common.RewrapEventBlobs(ctx, tx, r.cipher, rotation.Position, keyRotationBatchSize)
+		case "subject_keys":
+			last, rewrapped, err = common.RewrapSubjectKeys(ctx, tx, r.cipher, rotation.Position, keyRotationBatchSize)
+		default:
+			last, rewrapped, err = queries.RewrapPairKeys(ctx, tx, r.cipher, table == "pairs_query_archive", rotation.Position, keyRotationBatchSize)
+		}
+		if err != nil {
+			return 0, false, err
+		}
+
+		now := r.clock.Now().Format(time.RFC3339)
+		var completedAt any
+		if last == "" {
+			last, completedAt = rotation.Position, now
+		}
+		if _, err := tx.ExecContext(ctx, `update key_rotations set position = ?, rewrapped = rewrapped + ?, updated_at = ?, completed_at = ?
+			where key_id = ? and table_name = ?;`, last, rewrapped, now, completedAt, r.cipher.KeyId(), table); err != nil {
+			return 0, false, fmt.Errorf("failed to update key rotation: %w", err)
+		}
+
+		return rewrapped, false, tx.Commit()
+	}
+
+	return 0, true, tx.Commit()
+}
+
+// Rotate runs the batches of the rotation until it's completed and returns the number of rows wrapped again
+func (r *KeyRotator) Rotate(ctx context.Context, interval time.Duration) (int, error) {
+	total := 0
+	for {
+		rewrapped, done, err := r.RotateBatch(ctx)
+		total += rewrapped
+		if err != nil || done {
+			return total, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return total, ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
+
+// Rotations returns the progress of the rotations of every table under every master key, the latest first
+func (r *KeyRotator) Rotations(ctx context.Context) ([]KeyRotation, error) {
+	rows, err := r.db.Read.QueryContext(ctx, `select key_id, table_name, position, rewrapped, started_at, updated_at, completed_at
+		from key_rotations order by datetime(started_at) desc, key_id, table_name;`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query key rotations: %w", err)
+	}
+	defer rows.Close()
+
+	rotations := make([]KeyRotation, 0)
+	for rows.Next() {
+		rotation, err := scanKeyRotation(rows)
+		if err != nil {
+			return nil, err
+		}
+		rotations = append(rotations, *rotation)
+	}
+
+	return rotations, rows.Err()
+}
+
+// rotation returns the progress of the rotation of the table under the current master key, starting it if needed
+func (r *KeyRotator) rotation(ctx context.Context, tx *sql.Tx, table string) (*KeyRotation, error) {
+	now := r.clock.Now().Format(time.RFC3339)
+	if _, err := tx.ExecContext(ctx, `insert into key_rotations (key_id, table_name, position, rewrapped, started_at, updated_at)
+		values (?, ?, '', 0, ?, ?) on conflict do nothing;`, r.cipher.KeyId(), table, now, now); err != nil {
+		return nil, fmt.Errorf("failed to start key rotation: %w", err)
+	}
+
+	return scanKeyRotation(tx.QueryRowContext(ctx, `select key_id, table_name, position, rewrapped, started_at, updated_at, completed_at
+		from key_rotations where key_id = ? and table_name = ?;`, r.cipher.KeyId(), table))
+}
+
+// rewrapEvents wraps the encrypted fields of the next batch of events after the global version, see RewrapPairKeys
+func (r *KeyRotator) rewrapEvents(ctx context.Context, tx *sql.Tx, after string) (string, int, error) {
+	var seq int64
+	if after != "" {
+		var err error
+		if seq, err = strconv.ParseInt(after, 10, 64); err != nil {
+			return "", 0, fmt.Errorf("invalid events position %q: %w", after, err)
+		}
+	}
+
+	fields := make(map[string][]string, len(encryptedEventFields))
+	reasons := make([]any, 0, len(encryptedEventFields))
+	for t, paths := range encryptedEventFields {
+		fields[t.Name()] = paths
+		reasons = append(reasons, t.Name())
+	}
+
+	rows, err := tx.QueryContext(ctx, `select seq, reason, data from events
+		where seq > ? and reason in (?`+strings.Repeat(", ?", len(reasons)-1)+`) order by seq limit ?;`,
+		append(append([]any{seq}, reasons...), keyRotationBatchSize)...)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to query events: %w", err)
+	}
+
+	type event struct {
+		seq    int64
+		reason string
+		data   []byte
+	}
+	events := make([]event, 0, keyRotationBatchSize)
+	for rows.Next() {
+		var e event
+		if err := rows.Scan(&e.seq, &e.reason, &e.data); err != nil {
+			rows.Close()
+			return "", 0, fmt.Errorf("failed to scan event: %w", err)
+		}
+		events = append(events, e)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return "", 0, err
+	}
+
+	last, rewrapped := "", 0
+	for _, e := range events {
+		last = strconv.FormatInt(e.seq, 10)
+		data, changed, err := r.cipher.RewrapJSON(e.data, fields[e.reason])
+		if err != nil {
+			return "", 0, fmt.Errorf("failed to rewrap event %d: %w", e.seq, err)
+		}
+		if !changed {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, `update events set data = ? where seq = ?;`, data, e.seq); err != nil {
+			return "", 0, fmt.Errorf("failed to update event %d: %w", e.seq, err)
+		}
+		rewrapped++
+	}
+
+	return last, rewrapped, nil
+}
+
+func scanKeyRotation(row interface{ Scan(...any) error }) (*KeyRotation, error) {
+	var rotation KeyRotation
+	var startedAt, updatedAt string
+	var completedAt sql.NullString
+	if err := row.Scan(&rotation.KeyId, &rotation.Table, &rotation.Position, &rotation.Rewrapped, &startedAt, &updatedAt, &completedAt); err != nil {
+		return nil, fmt.Errorf("failed to scan key rotation: %w", err)
+	}
+	rotation.StartedAt, _ = time.Parse(time.RFC3339, startedAt)
+	rotation.UpdatedAt, _ = time.Parse(time.RFC3339, updatedAt)
+	if completedAt.Valid {
+		at, _ := time.Parse(time.RFC3339, completedAt.String)
+		rotation.CompletedAt = &at
+	}
+
+	return &rotation, nil
+}
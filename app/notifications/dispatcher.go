@@ -0,0 +1,284 @@
+package notifications
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/co-defi/api-server/app/queries"
+	"github.com/co-defi/api-server/common"
+	"github.com/co-defi/api-server/domain"
+	"github.com/hallgren/eventsourcing"
+	"github.com/rs/zerolog"
+)
+
+const (
+	// staleEventAge is the age after which events are not notified anymore,
+	// so a fresh dispatcher doesn't notify the whole history of the event store
+	staleEventAge = time.Hour
+	// deadlineReminderWindow is how long before the deadline participants are reminded
+	deadlineReminderWindow = 24 * time.Hour
+)
+
+// Notification is a message about the progress of a pair
+type Notification struct {
+	Event  domain.NotificationEvent `json:"event"`
+	PairId string                   `json:"pair_id"`
+	Title  string                   `json:"title"`
+	Body   string                   `json:"body"`
+}
+
+// Channel delivers notifications to the participants through a medium like email or push
+type Channel interface {
+	// Name returns the name of the channel for logging
+	Name() string
+	// Send delivers the notification to the recipient, recipients without a contact on this channel are skipped
+	Send(ctx context.Context, recipient queries.NotificationSettings, n Notification) error
+}
+
+var _ common.Projection = (*Dispatcher)(nil)
+
+// Dispatcher is a projection that notifies the participants of pair events through the registered channels
+type Dispatcher struct {
+	*common.BaseProjection
+	repo          *eventsourcing.EventRepository
+	settingsQuery *queries.NotificationSettingsQuery
+	pairsQuery    *queries.PairsQuery
+	channels      []Channel
+	clock         common.Clock
+	logger        zerolog.Logger
+}
+
+// dispatcherSchemaVersion is bumped whenever the columns of the dispatcher tables change, so they are rebuilt
+const dispatcherSchemaVersion = 1
+
+// NewDispatcher creates a new Dispatcher
+func NewDispatcher(
+	db *common.DB,
+	store common.Store,
+	repo *eventsourcing.EventRepository,
+	settingsQuery *queries.NotificationSettingsQuery,
+	pairsQuery *queries.PairsQuery,
+	channels []Channel,
+	clock common.Clock,
+	logger zerolog.Logger,
+) (*Dispatcher, error) {
+	bp, err := common.NewBaseProjection(db, store, "notifications_dispatcher", dispatcherSchemaVersion, "notification_reminders")
+	if err != nil {
+		return nil, err
+	}
+
+	d := Dispatcher{
+		BaseProjection: bp,
+		repo:           repo,
+		settingsQuery:  settingsQuery,
+		pairsQuery:     pairsQuery,
+		channels:       channels,
+		clock:          clock,
+		logger:         logger,
+	}
+	if err := d.createTable(); err != nil {
+		return nil, fmt.Errorf("failed to create notification_reminders table: %w", err)
+	}
+
+	return &d, nil
+}
+
+func (d *Dispatcher) createTable() error {
+	_, err := d.Exec(`create table if not exists notification_reminders (
+		pair_id VARCHAR PRIMARY KEY,
+		sent_at TEXT
+	);`)
+	return err
+}
+
+// Callback implements the common.Projection.Callback
+func (d *Dispatcher) Callback(event eventsourcing.Event) error {
+	// Applying only advances the position of the dispatcher, the notifications are sent after the position is committed
+	// so they are sent at most once
+	return d.Apply(event, func(tx *sql.Tx) error {
+		// An extended deadline is reminded again
+		if _, ok := event.Data().(*domain.ExtensionAccepted); ok {
+			if _, err := tx.Exec(`delete from notification_reminders where pair_id = ?;`, event.AggregateID()); err != nil {
+				return fmt.Errorf("failed to reset reminder: %w", err)
+			}
+		}
+
+		if time.Since(event.Timestamp()) <= staleEventAge {
+			d.AfterCommit(func() {
+				if err := d.dispatch(context.Background(), event); err != nil {
+					d.logger.Error().Err(err).Str("pair_id", event.AggregateID()).Msg("failed to dispatch notifications")
+				}
+			})
+		}
+		return nil
+	})
+}
+
+// dispatch notifies the participants of the pair the event belongs to
+func (d *Dispatcher) dispatch(ctx context.Context, event eventsourcing.Event) error {
+	switch e := event.Data().(type) {
+	case *domain.PairMatched:
+		return d.notifyParticipants(ctx, event.AggregateID(), "", Notification{
+			Event:  domain.NotificationEventMatched,
+			PairId: event.AggregateID(),
+			Title:  "Your pair is matched",
+			Body:   "A counterparty joined your pair, confirm the shared wallet to continue.",
+		})
+	case *domain.MatchReverted:
+		// the counterparty already left the pair, only its creator is notified
+		n := Notification{Event: domain.NotificationEventMatchReverted, PairId: event.AggregateID(), Title: "Your pair is waiting again"}
+		switch e.CauseOrDefault() {
+		case domain.MatchRevertCauseKeygen:
+			n.Body = "The shared wallet failed to be generated too many times, your pair is back in the waiting pool."
+		default:
+			n.Body = "Your counterparty didn't confirm the shared wallet in time, your pair is back in the waiting pool."
+		}
+		return d.notifyParticipants(ctx, event.AggregateID(), "", n)
+	case *domain.KeygenFailed:
+		// the participant who reported the failure knows it already
+		return d.notifyParticipants(ctx, event.AggregateID(), e.ParticipantAsset, Notification{
+			Event:  domain.NotificationEventWalletRegenerated,
+			PairId: event.AggregateID(),
+			Title:  "Your shared wallet changed",
+			Body:   "Your counterparty failed to generate the shared wallet, generate it again with the new wallet secrets to continue.",
+		})
+	case *domain.AssurancesInvalidated:
+		// the participant who confirmed the new wallet knows it already
+		return d.notifyParticipants(ctx, event.AggregateID(), e.ParticipantAsset, Notification{
+			Event:  domain.NotificationEventWalletRegenerated,
+			PairId: event.AggregateID(),
+			Title:  "Your shared wallet changed",
+			Body:   "Your counterparty confirmed a new shared wallet, confirm it and sign your assurances again to continue.",
+		})
+	case *domain.PairStatusOverdue:
+		n := Notification{Event: domain.NotificationEventStatusOverdue, PairId: event.AggregateID()}
+		switch e.Action {
+		case domain.OverdueActionReminded:
+			n.Title = "Your pair is waiting on you"
+			n.Body = fmt.Sprintf("Your pair has been in the %s step for over %s, complete it before the pair expires.", e.Status, time.Duration(e.Timeout))
+		case domain.OverdueActionExpired:
+			n.Title = "Your pair expired"
+			n.Body = fmt.Sprintf("Your pair didn't complete the %s step in time and was cancelled.", e.Status)
+		default:
+			// the reverted matches are notified by MatchReverted and the escalations are for the operators
+			return nil
+		}
+		return d.notifyParticipants(ctx, event.AggregateID(), "", n)
+	case *domain.AssetDeposited:
+		return d.notifyParticipants(ctx, event.AggregateID(), e.Asset, Notification{
+			Event:  domain.NotificationEventCounterpartyDeposit,
+			PairId: event.AggregateID(),
+			Title:  "Your counterparty deposited",
+			Body:   fmt.Sprintf("Your counterparty deposited %s into the shared wallet.", e.Asset),
+		})
+	case *domain.Withdrawn:
+		return d.notifyParticipants(ctx, event.AggregateID(), "", Notification{
+			Event:  domain.NotificationEventWithdrawalCompleted,
+			PairId: event.AggregateID(),
+			Title:  "Withdrawal completed",
+			Body:   "The liquidity of your pair has been withdrawn.",
+		})
+	case *domain.TxStatusChanged:
+		if !e.Failure() {
+			return nil
+		}
+		return d.notifyParticipants(ctx, event.AggregateID(), "", Notification{
+			Event:  domain.NotificationEventTxFailed,
+			PairId: event.AggregateID(),
+			Title:  "A transaction of your pair failed",
+			Body:   fmt.Sprintf("Transaction %s is %s on chain: %s.", e.TxHash, e.Status, e.Reason),
+		})
+	}
+
+	return nil
+}
+
+// notifyParticipants notifies the participants of the pair except the one owning the excluded asset
+func (d *Dispatcher) notifyParticipants(ctx context.Context, pairId string, excludedAsset domain.Asset, n Notification) error {
+	p := domain.Pair{}
+	if err := d.repo.GetWithContext(ctx, pairId, &p); err != nil {
+		return fmt.Errorf("failed to get pair: %w", err)
+	}
+
+	for asset, address := range p.ParticipantsAddress {
+		if asset == excludedAsset {
+			continue
+		}
+		if err := d.notify(ctx, address, n); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (d *Dispatcher) notify(ctx context.Context, address domain.Address, n Notification) error {
+	settings, err := d.settingsQuery.Get(ctx, address)
+	if err != nil {
+		return fmt.Errorf("failed to get notification settings: %w", err)
+	}
+	if !settings.IsSubscribed(n.Event) {
+		return nil
+	}
+
+	for _, c := range d.channels {
+		if err := c.Send(ctx, *settings, n); err != nil {
+			// A failing channel must not prevent the others from delivering
+			d.logger.Error().Err(err).Str("channel", c.Name()).Str("pair_id", n.PairId).Msg("failed to send notification")
+		}
+	}
+
+	return nil
+}
+
+// RemindApproachingDeadlines notifies the participants of the pairs reaching their deadline soon, once per pair
+func (d *Dispatcher) RemindApproachingDeadlines(ctx context.Context) error {
+	now := d.clock.Now()
+	pairs, err := d.pairsQuery.WithDeadlineBefore(ctx, now.Add(deadlineReminderWindow))
+	if err != nil {
+		return fmt.Errorf("failed to find pairs with approaching deadline: %w", err)
+	}
+
+	for _, p := range pairs {
+		res, err := d.ExecContext(ctx, `insert into notification_reminders (pair_id, sent_at) values (?, ?) on conflict do nothing;`,
+			p.Id, now.Format(time.RFC3339))
+		if err != nil {
+			return fmt.Errorf("failed to record reminder: %w", err)
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			continue
+		}
+
+		for _, address := range p.ParticipantAddresses {
+			if err := d.notify(ctx, address, Notification{
+				Event:  domain.NotificationEventDeadlineApproaching,
+				PairId: p.Id,
+				Title:  "Deadline approaching",
+				Body:   fmt.Sprintf("The investing period of your pair ends on %s.", p.Deadline.Format(time.RFC1123)),
+			}); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// RunReminders checks for approaching deadlines periodically until the context is cancelled
+func (d *Dispatcher) RunReminders(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := d.RemindApproachingDeadlines(ctx); err != nil {
+				d.logger.Error().Err(err).Msg("failed to remind approaching deadlines")
+			}
+		}
+	}
+}
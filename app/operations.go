@@ -0,0 +1,101 @@
+package app
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/co-defi/api-server/app/queries"
+	"github.com/co-defi/api-server/domain"
+)
+
+// Features tells the optional subsystems the application runs with, for the operators to check the configuration
+type Features struct {
+	SelfMatching      bool `json:"self_matching"`
+	UnverifiedTxs     bool `json:"unverified_txs"`
+	Compliance        bool `json:"compliance"`
+	Encryption        bool `json:"encryption"`
+	PairArchiving     bool `json:"pair_archiving"`
+	ProjectionTrigger bool `json:"projection_trigger"`
+	Notifications     bool `json:"notifications"`
+	PriceOracle       bool `json:"price_oracle"`
+	PositionSource    bool `json:"position_source"`
+}
+
+// Features returns the optional subsystems the application was configured with
+func (app *Application) Features() Features {
+	return Features{
+		SelfMatching:      app.allowSelfMatch,
+		UnverifiedTxs:     app.acceptUnverifiedTxs,
+		Compliance:        app.complianceChecker != nil,
+		Encryption:        app.cipher != nil,
+		PairArchiving:     app.archiveRetention > 0,
+		ProjectionTrigger: app.projectionTrigger,
+		Notifications:     len(app.notificationChannels) > 0,
+		PriceOracle:       app.priceOracle != nil,
+		PositionSource:    app.positionSource != nil,
+	}
+}
+
+// StuckPair is a pair which stayed in its status longer than the timeout of its plan
+type StuckPair struct {
+	Id              string            `json:"id"`
+	PlanId          string            `json:"plan_id"`
+	Status          domain.PairStatus `json:"status"`
+	Network         domain.Network    `json:"network"`
+	StatusEnteredAt time.Time         `json:"status_entered_at"`
+	Timeout         domain.Duration   `json:"timeout"`
+	OverdueBy       domain.Duration   `json:"overdue_by"`
+}
+
+// StuckPairs returns the pairs overdue in their status, the longest overdue first. The pairs are listed as soon as their
+// timeout is over, whether or not they were escalated already.
+func (app *Application) StuckPairs(ctx context.Context) ([]StuckPair, error) {
+	now := app.Clock.Now()
+	plans := make(map[string]*queries.Plan)
+
+	stuck := make([]StuckPair, 0)
+	for _, status := range domain.TimeoutStatuses {
+		pairs, err := app.Queries.Pairs.Find(ctx, queries.PairFilter{Status: &status})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, p := range pairs {
+			if p.PlanId == "" {
+				continue
+			}
+			plan, ok := plans[p.PlanId]
+			if !ok {
+				// the plans which can't be found have no timeout to be overdue of
+				plan, _ = app.Queries.Plans.Get(ctx, p.PlanId)
+				plans[p.PlanId] = plan
+			}
+			if plan == nil || plan.StatusTimeouts[status] == 0 {
+				continue
+			}
+
+			enteredAt := p.UpdatedAt
+			if p.StatusEnteredAt != nil {
+				enteredAt = *p.StatusEnteredAt
+			}
+			timeout := time.Duration(plan.StatusTimeouts[status])
+			if elapsed := now.Sub(enteredAt); elapsed >= timeout {
+				stuck = append(stuck, StuckPair{
+					Id:              p.Id,
+					PlanId:          p.PlanId,
+					Status:          status,
+					Network:         p.Network,
+					StatusEnteredAt: enteredAt,
+					Timeout:         domain.Duration(timeout),
+					OverdueBy:       domain.Duration(elapsed - timeout),
+				})
+			}
+		}
+	}
+	sort.Slice(stuck, func(i, j int) bool {
+		return stuck[i].OverdueBy > stuck[j].OverdueBy
+	})
+
+	return stuck, nil
+}
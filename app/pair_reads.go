@@ -0,0 +1,108 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/co-defi/api-server/app/queries"
+	"github.com/co-defi/api-server/domain"
+	"github.com/hallgren/eventsourcing"
+)
+
+// savedPairsRetention is how long the version of a saved pair is kept, the pairs projection catches up well within it
+const savedPairsRetention = time.Minute
+
+// savedPairs tracks the latest version of the pairs saved by the server, to tell when the pairs projection
+// doesn't reflect them yet
+type savedPairs struct {
+	mu       sync.Mutex
+	versions map[string]savedPair
+	prunedAt time.Time
+}
+
+type savedPair struct {
+	version int
+	savedAt time.Time
+}
+
+func newSavedPairs() *savedPairs {
+	return &savedPairs{versions: make(map[string]savedPair)}
+}
+
+// saved records the version of the saved pair, and forgets the pairs saved longer than savedPairsRetention ago
+func (s *savedPairs) saved(id string, version int) {
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.versions[id] = savedPair{version: version, savedAt: now}
+	if now.Sub(s.prunedAt) < savedPairsRetention {
+		return
+	}
+	for id, pair := range s.versions {
+		if now.Sub(pair.savedAt) > savedPairsRetention {
+			delete(s.versions, id)
+		}
+	}
+	s.prunedAt = now
+}
+
+// ahead tells whether the pair was saved with a later version than the projected one
+func (s *savedPairs) ahead(id string, projected int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pair, ok := s.versions[id]
+	if ok && projected >= pair.version {
+		delete(s.versions, id)
+	}
+	return ok && projected < pair.version
+}
+
+// GetPair returns the pair from the pairs projection, or from its aggregate when the projection doesn't reflect it yet,
+// so a pair is readable right after it's created or changed
+func (app *Application) GetPair(ctx context.Context, id string) (*queries.Pair, error) {
+	pair, err := app.Queries.Pairs.Get(ctx, id)
+	switch {
+	case err == nil && !app.savedPairs.ahead(id, pair.Version):
+		return pair, nil
+	case err != nil && err != queries.ErrPairNotFound:
+		return nil, err
+	}
+
+	return app.pairFromAggregate(ctx, id)
+}
+
+// pairFromAggregate loads the pair from its events
+func (app *Application) pairFromAggregate(ctx context.Context, id string) (*queries.Pair, error) {
+	p := domain.Pair{}
+	if err := app.repo.GetWithContext(ctx, id, &p); err != nil {
+		if err == eventsourcing.ErrAggregateNotFound {
+			return nil, queries.ErrPairNotFound
+		}
+		return nil, fmt.Errorf("failed to get pair: %w", err)
+	}
+
+	it, err := app.store.Get(ctx, id, "Pair", 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get events of pair %s: %w", id, err)
+	}
+	defer it.Close()
+
+	var createdAt, updatedAt time.Time
+	for it.Next() {
+		event, err := it.Value()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read event of pair %s: %w", id, err)
+		}
+		if createdAt.IsZero() {
+			createdAt = event.Timestamp
+		}
+		updatedAt = event.Timestamp
+	}
+
+	return queries.PairFromAggregate(&p, createdAt, updatedAt), nil
+}
@@ -0,0 +1,198 @@
+package app
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/co-defi/api-server/app/queries"
+	"github.com/co-defi/api-server/common"
+	"github.com/co-defi/api-server/domain"
+)
+
+// pairsCSVFlushEvery is the number of rows written between flushes, so the exports reach the consumers as they are read
+const pairsCSVFlushEvery = 100
+
+// pairsCSVColumns formats the columns of the pairs export by name. The assets of a pair are exported as primary and secondary,
+// in the order of the pair, and the amounts are exact decimals in units of the asset.
+var pairsCSVColumns = map[string]func(queries.Pair) string{
+	"id":                    func(p queries.Pair) string { return p.Id },
+	"plan_id":               func(p queries.Pair) string { return p.PlanId },
+	"plan_type":             func(p queries.Pair) string { return string(p.PlanType) },
+	"network":               func(p queries.Pair) string { return string(p.Network) },
+	"status":                func(p queries.Pair) string { return string(p.Status) },
+	"archived":              func(p queries.Pair) string { return strconv.FormatBool(p.Archived) },
+	"created_at":            func(p queries.Pair) string { return formatCSVTime(&p.CreatedAt) },
+	"updated_at":            func(p queries.Pair) string { return formatCSVTime(&p.UpdatedAt) },
+	"deadline":              func(p queries.Pair) string { return formatCSVTime(p.Deadline) },
+	"share_value":           func(p queries.Pair) string { return strconv.Itoa(p.ShareValue) },
+	"investing_period":      func(p queries.Pair) string { return strconv.Itoa(p.InvestingPeriod) },
+	"investing_period_unit": func(p queries.Pair) string { return string(p.InvestingPeriodUnit) },
+	"grace_period_days":     func(p queries.Pair) string { return strconv.Itoa(p.GracePeriodDays) },
+	"loss_protection":       func(p queries.Pair) string { return strconv.FormatFloat(p.LossProtection, 'f', -1, 64) },
+	"profit_sharing":        func(p queries.Pair) string { return string(p.ProfitSharingStrategy) },
+	"primary_asset":         func(p queries.Pair) string { return pairAsset(p, 0) },
+	"secondary_asset":       func(p queries.Pair) string { return pairAsset(p, 1) },
+	"primary_address":       func(p queries.Pair) string { return pairParticipant(p, 0) },
+	"secondary_address":     func(p queries.Pair) string { return pairParticipant(p, 1) },
+	"primary_deposit_tx":    func(p queries.Pair) string { return p.Deposits[pairAsset(p, 0)] },
+	"secondary_deposit_tx":  func(p queries.Pair) string { return p.Deposits[pairAsset(p, 1)] },
+	"primary_deposit":       func(p queries.Pair) string { return formatCSVAmount(p.DepositAmounts, pairAsset(p, 0)) },
+	"secondary_deposit":     func(p queries.Pair) string { return formatCSVAmount(p.DepositAmounts, pairAsset(p, 1)) },
+	"withdrawn_tx": func(p queries.Pair) string {
+		if p.WithdrawnTx == nil {
+			return ""
+		}
+		return *p.WithdrawnTx
+	},
+	"settled_at": func(p queries.Pair) string {
+		if p.Settlement == nil || !p.Settlement.Confirmed {
+			return ""
+		}
+		return formatCSVTime(&p.Settlement.SettledAt)
+	},
+	"primary_withdrawn":   func(p queries.Pair) string { return formatCSVSettled(p, 0) },
+	"secondary_withdrawn": func(p queries.Pair) string { return formatCSVSettled(p, 1) },
+	"value_usd": func(p queries.Pair) string {
+		if p.Settlement == nil || !p.Settlement.Confirmed {
+			return ""
+		}
+		return strconv.FormatFloat(p.Settlement.ValueUSD, 'f', 2, 64)
+	},
+	"primary_pnl_usd":   func(p queries.Pair) string { return formatCSVPnL(p, 0) },
+	"secondary_pnl_usd": func(p queries.Pair) string { return formatCSVPnL(p, 1) },
+}
+
+// DefaultPairsCSVColumns are the columns of the pairs export unless selected otherwise, in order
+var DefaultPairsCSVColumns = []string{
+	"id", "plan_id", "plan_type", "network", "status", "created_at", "deadline",
+	"primary_asset", "primary_address", "primary_deposit", "secondary_asset", "secondary_address", "secondary_deposit",
+	"share_value", "withdrawn_tx", "settled_at", "primary_withdrawn", "secondary_withdrawn", "value_usd", "primary_pnl_usd", "secondary_pnl_usd",
+}
+
+var ErrInvalidExportColumns = common.NewError("invalid_export_columns", "unknown columns selected for the export")
+
+// ExportPairsCSV writes the pairs matching the filter as CSV to w, a header of the columns then a row by pair, and returns the
+// number of exported pairs. The pairs are streamed from the projection as they are read and w is flushed as it goes when it can be,
+// so the exports of any size are served without holding them in memory.
+func (app *Application) ExportPairsCSV(ctx context.Context, w io.Writer, filter queries.PairFilter, columns []string) (int, error) {
+	if len(columns) == 0 {
+		columns = DefaultPairsCSVColumns
+	}
+	formats := make([]func(queries.Pair) string, len(columns))
+	unknown := []string{}
+	for i, column := range columns {
+		format, ok := pairsCSVColumns[column]
+		if !ok {
+			unknown = append(unknown, column)
+			continue
+		}
+		formats[i] = format
+	}
+	if len(unknown) > 0 {
+		return 0, ErrInvalidExportColumns.IncludeMeta(map[string]interface{}{"columns": unknown})
+	}
+
+	flusher, _ := w.(interface{ Flush() })
+	csvw := csv.NewWriter(w)
+	flush := func() error {
+		csvw.Flush()
+		if err := csvw.Error(); err != nil {
+			return fmt.Errorf("failed to write pairs export: %w", err)
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		return nil
+	}
+
+	if err := csvw.Write(columns); err != nil {
+		return 0, fmt.Errorf("failed to write pairs export: %w", err)
+	}
+
+	count := 0
+	record := make([]string, len(columns))
+	err := app.Queries.Pairs.Each(ctx, filter, func(p queries.Pair) error {
+		for i, format := range formats {
+			record[i] = format(p)
+		}
+		if err := csvw.Write(record); err != nil {
+			return fmt.Errorf("failed to write pairs export: %w", err)
+		}
+		count++
+		if count%pairsCSVFlushEvery == 0 {
+			return flush()
+		}
+		return nil
+	})
+	if err != nil {
+		return count, err
+	}
+
+	return count, flush()
+}
+
+// ParseExportColumns splits the comma separated columns of the pairs export, empty for the default ones
+func ParseExportColumns(value string) []string {
+	columns := []string{}
+	for _, column := range strings.Split(value, ",") {
+		if column = strings.TrimSpace(column); column != "" {
+			columns = append(columns, column)
+		}
+	}
+	return columns
+}
+
+func pairAsset(p queries.Pair, i int) domain.Asset {
+	if i >= len(p.Assets) {
+		return ""
+	}
+	return p.Assets[i]
+}
+
+// pairParticipant returns the address of the participant of the asset of the pair, the addresses are ordered as the assets
+func pairParticipant(p queries.Pair, i int) domain.Address {
+	if i >= len(p.ParticipantAddresses) {
+		return ""
+	}
+	return p.ParticipantAddresses[i]
+}
+
+func formatCSVTime(t *time.Time) string {
+	if t == nil || t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+func formatCSVAmount(amounts map[domain.Asset]domain.TokenAmount, asset domain.Asset) string {
+	amount, ok := amounts[asset]
+	if !ok {
+		return ""
+	}
+	return amount.Decimal()
+}
+
+// formatCSVSettled formats the amount of the asset of the pair paid out by its confirmed withdrawal
+func formatCSVSettled(p queries.Pair, i int) string {
+	if p.Settlement == nil || !p.Settlement.Confirmed {
+		return ""
+	}
+	return formatCSVAmount(p.Settlement.Withdrawn, pairAsset(p, i))
+}
+
+// formatCSVPnL formats the profit or loss in $ of the participant of the asset of the pair once its withdrawal is confirmed
+func formatCSVPnL(p queries.Pair, i int) string {
+	if p.Settlement == nil || !p.Settlement.Confirmed {
+		return ""
+	}
+	result, ok := p.Settlement.Participants[pairParticipant(p, i)]
+	if !ok {
+		return ""
+	}
+	return strconv.FormatFloat(result.PnLUSD, 'f', 2, 64)
+}
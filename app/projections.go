@@ -0,0 +1,124 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/co-defi/api-server/app/queries"
+	"github.com/co-defi/api-server/common"
+	"github.com/co-defi/api-server/domain"
+	"github.com/hallgren/eventsourcing"
+)
+
+var (
+	ErrProjectionNotFound      = common.NewError("projection_not_found", "projection not found")
+	ErrProjectionNotResettable = common.NewError("projection_not_resettable", "projection can't be reset")
+)
+
+// ProjectionStatus is the progress and the health of a running projection
+type ProjectionStatus struct {
+	Name string `json:"name"`
+	// LastHandled is the global version of the last event handled by the projection
+	LastHandled uint64 `json:"last_handled"`
+	// Head is the global version of the last stored event
+	Head uint64 `json:"head"`
+	// Lag is the number of events the projection has still to handle
+	Lag uint64 `json:"lag"`
+	common.ProjectionHealth
+}
+
+// ProjectionStatuses returns the progress and the health of the projections ordered by name
+func (app *Application) ProjectionStatuses(ctx context.Context) ([]ProjectionStatus, error) {
+	seqs, err := common.LastHandledEventSeqs(ctx, app.db.Read)
+	if err != nil {
+		return nil, err
+	}
+
+	var head uint64
+	if err := app.db.Read.QueryRowContext(ctx, `select coalesce(max(seq), 0) from events;`).Scan(&head); err != nil {
+		return nil, fmt.Errorf("failed to query head of the events: %w", err)
+	}
+
+	statuses := make([]ProjectionStatus, 0, len(app.projections))
+	for _, p := range app.projections {
+		fsp := p.(*common.FailSafeProjection)
+		status := ProjectionStatus{
+			Name:             fsp.Name(),
+			LastHandled:      seqs[fsp.Name()],
+			Head:             head,
+			ProjectionHealth: fsp.Health(),
+		}
+		if head > status.LastHandled {
+			status.Lag = head - status.LastHandled
+		}
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Name < statuses[j].Name
+	})
+
+	return statuses, nil
+}
+
+// WaitForPair waits for the pairs projection to reflect all the events of the pair saved so far and returns its state,
+// so the clients can read their writes right after a command. The projections are woken up so the pair isn't delayed by their pace.
+func (app *Application) WaitForPair(ctx context.Context, id string) (*queries.Pair, error) {
+	pair := domain.Pair{}
+	if err := app.repo.GetWithContext(ctx, id, &pair); err != nil {
+		if err == eventsourcing.ErrAggregateNotFound {
+			return nil, queries.ErrPairNotFound
+		}
+		return nil, fmt.Errorf("failed to get pair: %w", err)
+	}
+
+	if app.projectionsGroup != nil {
+		app.projectionsGroup.Trigger()
+	}
+
+	return app.Queries.Pairs.WaitForVersion(ctx, id, int(pair.Version())-1)
+}
+
+// ResetProjection requests the named query to be rebuilt from the start, its tables are emptied on its next run.
+// The notification dispatcher can't be reset, as handling its events again would send the notifications again.
+func (app *Application) ResetProjection(name string) error {
+	if _, ok := app.Queries.projections()[name]; !ok {
+		if app.dispatcher != nil && name == app.dispatcher.Name() {
+			return ErrProjectionNotResettable
+		}
+		return ErrProjectionNotFound
+	}
+
+	for _, p := range app.projections {
+		fsp := p.(*common.FailSafeProjection)
+		if fsp.Name() != name {
+			continue
+		}
+		if err := fsp.RequestReset(); err != nil {
+			return ErrProjectionNotResettable
+		}
+		return nil
+	}
+
+	return ErrProjectionNotFound
+}
+
+const defaultDeadLettersLimit = 50
+
+// DeadLetters returns the latest events the projection gave up handling, or all projections when it's empty,
+// along with the number of dead letters parked by every projection
+func (app *Application) DeadLetters(ctx context.Context, projection string, limit int) ([]common.DeadLetter, map[string]int, error) {
+	if limit <= 0 {
+		limit = defaultDeadLettersLimit
+	}
+	letters, err := common.FindDeadLetters(ctx, app.db.Read, projection, limit)
+	if err != nil {
+		return nil, nil, err
+	}
+	counts, err := common.CountDeadLetters(ctx, app.db.Read)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return letters, counts, nil
+}
@@ -0,0 +1,164 @@
+package queries
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/co-defi/api-server/common"
+	"github.com/co-defi/api-server/domain"
+	"github.com/hallgren/eventsourcing"
+)
+
+var _ common.Projection = (*EscrowsQuery)(nil)
+
+// EscrowsQuery is a query that keeps track of the key shares of the mediators in escrow and of their recoveries, without the shares
+type EscrowsQuery struct {
+	*common.BaseProjection
+}
+
+// escrowsSchemaVersion is bumped whenever the columns of the escrows tables change, so they are rebuilt
+const escrowsSchemaVersion = 1
+
+// NewEscrowsQuery creates a new EscrowsQuery
+func NewEscrowsQuery(db *common.DB, store common.Store) (*EscrowsQuery, error) {
+	bp, err := common.NewBaseProjection(db, store, "escrows_query", escrowsSchemaVersion, "escrow_recoveries_query")
+	if err != nil {
+		return nil, err
+	}
+
+	eq := EscrowsQuery{bp}
+	if err := eq.createTables(); err != nil {
+		return nil, fmt.Errorf("failed to create escrows_query tables: %w", err)
+	}
+
+	return &eq, nil
+}
+
+func (eq *EscrowsQuery) createTables() error {
+	_, err := eq.Exec(`create table if not exists escrows_query (
+		pair_id VARCHAR PRIMARY KEY,
+		escrowed_by TEXT,
+		escrowed_at TEXT
+	);
+	create table if not exists escrow_recoveries_query (
+		id VARCHAR PRIMARY KEY,
+		pair_id VARCHAR,
+		status TEXT,
+		reason TEXT,
+		requested_by TEXT,
+		requested_at TEXT,
+		decided_by TEXT,
+		decided_at TEXT,
+		decision_reason TEXT,
+		retrieved_at TEXT
+	);
+	create index if not exists escrow_recoveries_query_pair on escrow_recoveries_query (pair_id, requested_at);`)
+	return err
+}
+
+// Callback implements the common.Projection.Callback
+func (eq *EscrowsQuery) Callback(event eventsourcing.Event) error {
+	return eq.Apply(event, func(tx *sql.Tx) error {
+		at := event.Timestamp().Format(time.RFC3339)
+		switch e := event.Data().(type) {
+		case *domain.MediatorShareEscrowed:
+			if _, err := tx.Exec(`insert into escrows_query (pair_id, escrowed_by, escrowed_at) values (?, ?, ?) on conflict do nothing;`,
+				event.AggregateID(), e.Operator, at); err != nil {
+				return fmt.Errorf("failed to insert escrow: %w", err)
+			}
+		case *domain.ShareRecoveryRequested:
+			if _, err := tx.Exec(`insert into escrow_recoveries_query (id, pair_id, status, reason, requested_by, requested_at, decided_by, decision_reason)
+				values (?, ?, ?, ?, ?, ?, '', '') on conflict do nothing;`,
+				e.RecoveryId, event.AggregateID(), domain.ShareRecoveryStatusPending, e.Reason, e.Operator, at); err != nil {
+				return fmt.Errorf("failed to insert share recovery: %w", err)
+			}
+		case *domain.ShareRecoveryApproved:
+			if _, err := tx.Exec(`update escrow_recoveries_query set status = ?, decided_by = ?, decided_at = ? where id = ?;`,
+				domain.ShareRecoveryStatusApproved, e.Operator, at, e.RecoveryId); err != nil {
+				return fmt.Errorf("failed to approve share recovery: %w", err)
+			}
+		case *domain.ShareRecoveryRejected:
+			if _, err := tx.Exec(`update escrow_recoveries_query set status = ?, decided_by = ?, decided_at = ?, decision_reason = ? where id = ?;`,
+				domain.ShareRecoveryStatusRejected, e.Operator, at, e.Reason, e.RecoveryId); err != nil {
+				return fmt.Errorf("failed to reject share recovery: %w", err)
+			}
+		case *domain.MediatorShareRetrieved:
+			if _, err := tx.Exec(`update escrow_recoveries_query set status = ?, retrieved_at = ? where id = ?;`,
+				domain.ShareRecoveryStatusRetrieved, at, e.RecoveryId); err != nil {
+				return fmt.Errorf("failed to update share recovery: %w", err)
+			}
+		}
+
+		return nil
+	})
+}
+
+// Escrow is the key share of the mediator of a pair in escrow, along with its recoveries
+type Escrow struct {
+	PairId     string          `json:"pair_id"`
+	EscrowedBy string          `json:"escrowed_by"`
+	EscrowedAt time.Time       `json:"escrowed_at"`
+	Recoveries []ShareRecovery `json:"recoveries"`
+}
+
+// ShareRecovery is a request of an operator to retrieve the key share of a mediator
+type ShareRecovery struct {
+	Id             string                     `json:"id"`
+	Status         domain.ShareRecoveryStatus `json:"status"`
+	Reason         string                     `json:"reason"`
+	RequestedBy    string                     `json:"requested_by"`
+	RequestedAt    time.Time                  `json:"requested_at"`
+	DecidedBy      string                     `json:"decided_by,omitempty"`
+	DecidedAt      *time.Time                 `json:"decided_at,omitempty"`
+	DecisionReason string                     `json:"decision_reason,omitempty"`
+	RetrievedAt    *time.Time                 `json:"retrieved_at,omitempty"`
+}
+
+var ErrEscrowNotFound = common.NewError("escrow_not_found", "no mediator key share in escrow for the pair")
+
+// Get returns the escrow of the pair with its recoveries, the latest first
+func (eq *EscrowsQuery) Get(ctx context.Context, pairId string) (*Escrow, error) {
+	var (
+		escrow     = Escrow{PairId: pairId, Recoveries: []ShareRecovery{}}
+		escrowedAt string
+	)
+	err := eq.Reader().QueryRowContext(ctx, `select escrowed_by, escrowed_at from escrows_query where pair_id = ?;`, pairId).
+		Scan(&escrow.EscrowedBy, &escrowedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrEscrowNotFound
+		}
+		return nil, fmt.Errorf("failed to scan escrow: %w", err)
+	}
+	escrow.EscrowedAt, _ = time.Parse(time.RFC3339, escrowedAt)
+
+	rows, err := eq.Reader().QueryContext(ctx, `select id, status, reason, requested_by, requested_at, decided_by, decided_at, decision_reason, retrieved_at
+		from escrow_recoveries_query where pair_id = ? order by requested_at desc, id;`, pairId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query share recoveries: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			r                      ShareRecovery
+			requestedAt            string
+			decidedAt, retrievedAt sql.NullString
+		)
+		if err := rows.Scan(&r.Id, &r.Status, &r.Reason, &r.RequestedBy, &requestedAt, &r.DecidedBy, &decidedAt, &r.DecisionReason, &retrievedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan share recovery: %w", err)
+		}
+		r.RequestedAt, _ = time.Parse(time.RFC3339, requestedAt)
+		d := newRowDecoder("escrow_recoveries_query", r.Id)
+		r.DecidedAt = d.nullTime("decided_at", decidedAt)
+		r.RetrievedAt = d.nullTime("retrieved_at", retrievedAt)
+		if err := d.Err(); err != nil {
+			return nil, err
+		}
+		escrow.Recoveries = append(escrow.Recoveries, r)
+	}
+
+	return &escrow, rows.Err()
+}
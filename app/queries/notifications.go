@@ -0,0 +1,143 @@
+package queries
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/co-defi/api-server/common"
+	"github.com/co-defi/api-server/domain"
+	"github.com/hallgren/eventsourcing"
+)
+
+var _ common.Projection = (*NotificationSettingsQuery)(nil)
+
+// NotificationSettingsQuery is a query that keeps track of the notification settings of the participants
+type NotificationSettingsQuery struct {
+	*common.BaseProjection
+}
+
+// notificationSettingsSchemaVersion is bumped whenever the columns of the notification settings table change, so they are rebuilt
+const notificationSettingsSchemaVersion = 1
+
+// NewNotificationSettingsQuery creates a new NotificationSettingsQuery
+func NewNotificationSettingsQuery(db *common.DB, store common.Store) (*NotificationSettingsQuery, error) {
+	bp, err := common.NewBaseProjection(db, store, "notification_settings_query", notificationSettingsSchemaVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	nq := NotificationSettingsQuery{bp}
+	if err := nq.createTable(); err != nil {
+		return nil, fmt.Errorf("failed to create notification_settings_query table: %w", err)
+	}
+
+	return &nq, nil
+}
+
+func (nq *NotificationSettingsQuery) createTable() error {
+	_, err := nq.Exec(`create table if not exists notification_settings_query (
+		address TEXT PRIMARY KEY,
+		email TEXT,
+		push_token TEXT,
+		events BLOB,
+		updated_at TEXT
+	);`)
+	return err
+}
+
+// Callback implements the common.Projection.Callback
+func (nq *NotificationSettingsQuery) Callback(event eventsourcing.Event) error {
+	return nq.Apply(event, func(tx *sql.Tx) error {
+		switch e := event.Data().(type) {
+		case *domain.NotificationSettingsUpdated:
+			if err := upsertNotificationSettings(tx, event, e); err != nil {
+				return fmt.Errorf("failed to upsert notification settings: %w", err)
+			}
+		case *domain.DataRedacted:
+			// The personal channels can't be read from the events anymore, the preferences are kept
+			for _, address := range e.Addresses {
+				if _, err := tx.Exec(`update notification_settings_query set email = '', push_token = '' where address = ?;`, address); err != nil {
+					return fmt.Errorf("failed to redact notification settings: %w", err)
+				}
+			}
+		}
+
+		return nil
+	})
+}
+
+func upsertNotificationSettings(tx executor, event eventsourcing.Event, e *domain.NotificationSettingsUpdated) error {
+	events := e.Events
+	if events == nil {
+		events = []domain.NotificationEvent{}
+	}
+
+	_, err := tx.Exec(`insert into notification_settings_query (address, email, push_token, events, updated_at)
+		values (?, ?, ?, jsonb(?), ?)
+		on conflict (address) do update set
+			email = excluded.email,
+			push_token = excluded.push_token,
+			events = excluded.events,
+			updated_at = excluded.updated_at;`,
+		e.Address,
+		e.Email,
+		e.PushToken,
+		mustMarshalJson(events),
+		event.Timestamp().Format(time.RFC3339),
+	)
+	return err
+}
+
+// NotificationSettings represents the notification channels and preferences of a participant
+type NotificationSettings struct {
+	Address   domain.Address             `json:"address"`
+	Email     string                     `json:"email"`
+	PushToken string                     `json:"push_token"`
+	Events    []domain.NotificationEvent `json:"events"`
+	UpdatedAt *time.Time                 `json:"updated_at"`
+}
+
+// IsSubscribed checks if the participant wants to be notified about the event
+func (s NotificationSettings) IsSubscribed(event domain.NotificationEvent) bool {
+	for _, e := range s.Events {
+		if e == event {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Get returns the notification settings of an address, addresses that never registered have no channels
+func (nq *NotificationSettingsQuery) Get(ctx context.Context, address domain.Address) (*NotificationSettings, error) {
+	row := nq.Reader().QueryRowContext(ctx, `select email, push_token, json(events), updated_at from notification_settings_query where address = ?;`, address)
+
+	var (
+		email     string
+		pushToken string
+		events    []byte
+		updatedAt sql.NullString
+	)
+	if err := row.Scan(&email, &pushToken, &events, &updatedAt); err != nil {
+		if err == sql.ErrNoRows {
+			return &NotificationSettings{Address: address, Events: []domain.NotificationEvent{}}, nil
+		}
+		return nil, fmt.Errorf("failed to scan notification settings: %w", err)
+	}
+
+	d := newRowDecoder("notification_settings_query", string(address))
+	s := NotificationSettings{
+		Address:   address,
+		Email:     email,
+		PushToken: pushToken,
+		Events:    decodeColumn[[]domain.NotificationEvent](d, "events", events),
+		UpdatedAt: d.nullTime("updated_at", updatedAt),
+	}
+	if err := d.Err(); err != nil {
+		return nil, err
+	}
+
+	return &s, nil
+}
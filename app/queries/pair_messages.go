@@ -0,0 +1,106 @@
+package queries
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/co-defi/api-server/common"
+	"github.com/co-defi/api-server/domain"
+	"github.com/hallgren/eventsourcing"
+)
+
+var _ common.Projection = (*PairMessagesQuery)(nil)
+
+// PairMessagesQuery is a query that keeps the messages the participants of the pairs exchanged
+type PairMessagesQuery struct {
+	*common.BaseProjection
+}
+
+// pairMessagesSchemaVersion is bumped whenever the columns of the pair messages table change, so they are rebuilt
+const pairMessagesSchemaVersion = 1
+
+// NewPairMessagesQuery creates a new PairMessagesQuery
+func NewPairMessagesQuery(db *common.DB, store common.Store) (*PairMessagesQuery, error) {
+	bp, err := common.NewBaseProjection(db, store, "pair_messages_query", pairMessagesSchemaVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	mq := PairMessagesQuery{bp}
+	if err := mq.createTable(); err != nil {
+		return nil, fmt.Errorf("failed to create pair_messages_query table: %w", err)
+	}
+
+	return &mq, nil
+}
+
+func (mq *PairMessagesQuery) createTable() error {
+	_, err := mq.Exec(`create table if not exists pair_messages_query (
+		pair_id VARCHAR,
+		seq INTEGER,
+		sender TEXT,
+		body TEXT,
+		posted_at TEXT,
+		PRIMARY KEY (pair_id, seq)
+	);`)
+	return err
+}
+
+// Callback implements the common.Projection.Callback
+func (mq *PairMessagesQuery) Callback(event eventsourcing.Event) error {
+	return mq.Apply(event, func(tx *sql.Tx) error {
+		switch e := event.Data().(type) {
+		case *domain.PairMessagePosted:
+			// The version of the event in the chat of the pair numbers its messages
+			if _, err := tx.Exec(`insert into pair_messages_query (pair_id, seq, sender, body, posted_at) values (?, ?, ?, ?, ?)
+				on conflict do nothing;`,
+				e.PairId, event.Version(), e.From, e.Body, event.Timestamp().Format(time.RFC3339)); err != nil {
+				return fmt.Errorf("failed to insert pair message: %w", err)
+			}
+		}
+
+		return nil
+	})
+}
+
+// PairMessage is a message posted by a participant to the counterparty of their pair
+type PairMessage struct {
+	Seq      int            `json:"seq"`
+	PairId   string         `json:"pair_id"`
+	From     domain.Address `json:"from"`
+	Body     string         `json:"body"`
+	PostedAt time.Time      `json:"posted_at"`
+}
+
+const defaultPairMessagesLimit = 100
+
+// Find returns the messages of the pair posted after the given sequence number, the oldest first
+func (mq *PairMessagesQuery) Find(ctx context.Context, pairId string, after, limit int) ([]PairMessage, error) {
+	if limit <= 0 {
+		limit = defaultPairMessagesLimit
+	}
+
+	rows, err := mq.Reader().QueryContext(ctx, `select seq, pair_id, sender, body, posted_at from pair_messages_query
+		where pair_id = ? and seq > ? order by seq limit ?;`, pairId, after, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query pair messages: %w", err)
+	}
+	defer rows.Close()
+
+	messages := []PairMessage{}
+	for rows.Next() {
+		var (
+			m        PairMessage
+			postedAt string
+		)
+		if err := rows.Scan(&m.Seq, &m.PairId, &m.From, &m.Body, &postedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan pair message: %w", err)
+		}
+		m.PostedAt, _ = time.Parse(time.RFC3339, postedAt)
+		messages = append(messages, m)
+	}
+
+	return messages, rows.Err()
+}
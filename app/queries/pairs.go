@@ -4,8 +4,9 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"strings"
+	"slices"
 	"time"
 
 	"github.com/co-defi/api-server/common"
@@ -19,16 +20,28 @@ var _ common.Projection = (*PairsQuery)(nil)
 // PairsQuery is a query that keeps track of all pairs
 type PairsQuery struct {
 	*common.BaseProjection
+	cache *common.Cache
+	// changes wakes up the waiters of a pair once its changes are committed
+	changes *common.Signal
+	// cipher encrypts the wallet secrets and the signed transactions of the pairs at rest
+	cipher *common.Cipher
+	// onCorruptRow reports the rows skipped by the listings as they can't be decoded
+	onCorruptRow func(*CorruptRowError)
 }
 
-// NewPairsQuery creates a new PairsQuery
-func NewPairsQuery(db *sql.DB, store common.Store) (*PairsQuery, error) {
-	bp, err := common.NewBaseProjection(db, store, "pairs_query")
+// pairsSchemaVersion is bumped whenever the columns of the pairs tables change, so they are rebuilt
+const pairsSchemaVersion = 2
+
+// NewPairsQuery creates a new PairsQuery, the wallet secrets and the signed transactions of the pairs are stored encrypted
+// with the cipher and decrypted when the pairs are read. They are stored in plaintext with a nil cipher.
+func NewPairsQuery(db *common.DB, store common.Store, cipher *common.Cipher) (*PairsQuery, error) {
+	bp, err := common.NewBaseProjection(db, store, "pairs_query", pairsSchemaVersion, "pairs_query_archive")
 	if err != nil {
 		return nil, err
 	}
 
-	pq := PairsQuery{bp}
+	pq := PairsQuery{bp, common.NewCache(), common.NewSignal(), cipher, func(*CorruptRowError) {}}
+	pq.OnReset(func() { pq.cache.InvalidatePrefix("") })
 	if err := pq.createTable(); err != nil {
 		return nil, fmt.Errorf("failed to create pairs_query table: %w", err)
 	}
@@ -36,12 +49,12 @@ func NewPairsQuery(db *sql.DB, store common.Store) (*PairsQuery, error) {
 	return &pq, nil
 }
 
-func (pq *PairsQuery) createTable() error {
-	_, err := pq.Exec(`create table if not exists pairs_query (
+// pairsTableColumns are the columns of both the live and the archived pairs, see pairsSchemaVersion
+const pairsTableColumns = `
 		id VARCHAR PRIMARY KEY,
 		status TEXT,
-		assets TEXT,
-		participant_addresses TEXT,
+		assets BLOB,
+		participant_addresses BLOB,
 		share_value INTEGER,
 		investing_period INTEGER,
 		wallet_security TEXT,
@@ -49,69 +62,257 @@ func (pq *PairsQuery) createTable() error {
 		loss_protection REAL,
 		wallet BLOB,
 		assurances BLOB,
+		assurance_confirmations BLOB,
 		deposits BLOB,
+		deposit_amounts BLOB,
 		withdraw_tx BLOB,
 		lp BLOB,
 		deadline TEXT,
 		withdrawn_tx TEXT,
 		created_at TEXT,
-		updated_at TEXT
-	);`)
-	return err
+		updated_at TEXT,
+		network TEXT,
+		investing_period_unit TEXT,
+		grace_period_days INTEGER,
+		plan_id VARCHAR,
+		primary_asset TEXT,
+		secondary_asset TEXT,
+		creator_address TEXT,
+		counterparty_address TEXT,
+		version INTEGER,
+		refund BLOB,
+		early_withdrawal BLOB,
+		pending_extension BLOB,
+		settlement BLOB,
+		txs BLOB,
+		plan_type TEXT,
+		savers BLOB,
+		status_entered_at TEXT,
+		status_durations BLOB`
+
+// The paths of the encrypted fields in the columns of the pairs, see common.Cipher.OpenJSON
+var (
+	walletEncryptedFields     = []string{"encryption_key", "hex_chain_code"}
+	assurancesEncryptedFields = []string{"*.*.tx", "*.*.signature"}
+	signedTxEncryptedFields   = []string{"tx", "signature"}
+	refundEncryptedFields     = []string{"assurances.*.tx", "assurances.*.signature"}
+	saversEncryptedFields     = []string{"*.withdraw_tx.tx", "*.withdraw_tx.signature"}
+)
+
+// pairsTableIndexes are the expressions both the live and the archived pairs are looked up by, keyed by the index name suffix
+var pairsTableIndexes = map[string]string{
+	"status":               "status",
+	"network":              "network",
+	"plan_id":              "plan_id",
+	"primary_asset":        "primary_asset",
+	"secondary_asset":      "secondary_asset",
+	"creator_address":      "creator_address",
+	"counterparty_address": "counterparty_address",
+	"created_at":           "datetime(created_at)",
+	"share_value":          "share_value",
+	"investing_period":     "investing_period, investing_period_unit",
+	"plan_type":            "coalesce(plan_type, 'lp')",
 }
 
-// Callback implements the common.Projection.Callback
-func (pq *PairsQuery) Callback(event eventsourcing.Event) error {
-	tx, err := pq.Begin()
-	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
+func (pq *PairsQuery) createTable() error {
+	if _, err := pq.Exec(`create table if not exists pairs_query (` + pairsTableColumns + `);`); err != nil {
+		return err
 	}
-	defer tx.Rollback()
 
-	switch e := event.Data().(type) {
-	case *domain.PairCreated:
-		if err := insertPair(tx, event, e); err != nil {
-			return fmt.Errorf("failed to insert pair: %w", err)
-		}
-	case *domain.PairStatusChanged:
-		if err := updateStatus(tx, event, e.Status); err != nil {
-			return fmt.Errorf("failed to update pair status: %w", err)
-		}
-	case *domain.PairMatched:
-		if err := setPairMatched(tx, event, e); err != nil {
-			return fmt.Errorf("failed to set pair matched: %w", err)
-		}
-	case *domain.WalletAddressConfirmed:
-		if err := updateMultisigWallet(tx, event, e); err != nil {
-			return fmt.Errorf("failed to update pair status: %w", err)
-		}
-	case *domain.AssetAssuranceSigned:
-		if err := updateAssurances(tx, event, e); err != nil {
-			return fmt.Errorf("failed to update assurances: %w", err)
-		}
-	case *domain.AssetDeposited:
-		if err := updateDeposits(tx, event, e); err != nil {
-			return fmt.Errorf("failed to update deposits: %w", err)
+	if _, err := pq.Exec(`create table if not exists pairs_query_archive (` + pairsTableColumns + `,
+		archived_at TEXT
+	);`); err != nil {
+		return err
+	}
+
+	for _, table := range []string{pairsTable, archivedPairsTable} {
+		for name, expression := range pairsTableIndexes {
+			if _, err := pq.Exec(fmt.Sprintf(`create index if not exists %s_by_%s on %s (%s);`, table, name, table, expression)); err != nil {
+				return fmt.Errorf("failed to index %s by %s: %w", table, name, err)
+			}
 		}
-	case *domain.WithdrawTxSigned:
-		if err := updateWithdrawTx(tx, event, e); err != nil {
-			return fmt.Errorf("failed to update withdraw tx: %w", err)
+	}
+
+	return nil
+}
+
+// Callback implements the common.Projection.Callback
+func (pq *PairsQuery) Callback(event eventsourcing.Event) error {
+	return pq.Apply(event, func(tx *sql.Tx) error {
+		// the participants are read before the event too, so the counterparty of a reverted match learns about it
+		var participants []domain.Address
+		if event.AggregateType() == "Pair" {
+			var err error
+			if participants, err = pairAddresses(tx, event.AggregateID(), participants); err != nil {
+				return err
+			}
 		}
-	case *domain.LPDone:
-		if err := updateLP(tx, event, e); err != nil {
-			return fmt.Errorf("failed to update LP: %w", err)
+
+		switch e := event.Data().(type) {
+		case *domain.PairCreated:
+			if err := insertPair(tx, event, e); err != nil {
+				return fmt.Errorf("failed to insert pair: %w", err)
+			}
+		case *domain.PairStatusChanged:
+			if err := updateStatus(tx, event, e.Status); err != nil {
+				return fmt.Errorf("failed to update pair status: %w", err)
+			}
+		case *domain.PairStatusForced:
+			if err := restoreArchivedPair(tx, event.AggregateID()); err != nil {
+				return err
+			}
+			if err := updateStatus(tx, event, e.Status); err != nil {
+				return fmt.Errorf("failed to force pair status: %w", err)
+			}
+		case *domain.PairMatched:
+			if err := setPairMatched(tx, event, e, pq.cipher); err != nil {
+				return fmt.Errorf("failed to set pair matched: %w", err)
+			}
+		case *domain.MatchReverted:
+			if err := revertPairMatch(tx, event); err != nil {
+				return fmt.Errorf("failed to revert pair match: %w", err)
+			}
+		case *domain.KeygenFailed:
+			if err := regenerateWallet(tx, event, e, pq.cipher); err != nil {
+				return fmt.Errorf("failed to regenerate wallet: %w", err)
+			}
+		case *domain.WalletAddressConfirmed:
+			if err := updateMultisigWallet(tx, event, e); err != nil {
+				return fmt.Errorf("failed to update pair status: %w", err)
+			}
+		case *domain.AssetAssuranceSigned:
+			if err := updateAssurances(tx, event, e, pq.cipher); err != nil {
+				return fmt.Errorf("failed to update assurances: %w", err)
+			}
+		case *domain.AssurancesConfirmed:
+			if err := updateAssuranceConfirmations(tx, event, e); err != nil {
+				return fmt.Errorf("failed to update assurance confirmations: %w", err)
+			}
+		case *domain.AssurancesInvalidated:
+			if err := invalidateAssurances(tx, event); err != nil {
+				return fmt.Errorf("failed to invalidate assurances: %w", err)
+			}
+		case *domain.AssetDeposited:
+			if err := updateDeposits(tx, event, e); err != nil {
+				return fmt.Errorf("failed to update deposits: %w", err)
+			}
+			if err := trackTx(tx, event, e.TxHash, domain.TxKindDeposit, e.Asset, e.Unverified); err != nil {
+				return fmt.Errorf("failed to track deposit: %w", err)
+			}
+		case *domain.WithdrawTxSigned:
+			if err := updateWithdrawTx(tx, event, e, pq.cipher); err != nil {
+				return fmt.Errorf("failed to update withdraw tx: %w", err)
+			}
+		case *domain.LPDone:
+			if err := updateLP(tx, event, e); err != nil {
+				return fmt.Errorf("failed to update LP: %w", err)
+			}
+			if err := trackTx(tx, event, e.TxHash, domain.TxKindLP, e.Asset, e.Unverified); err != nil {
+				return fmt.Errorf("failed to track LP: %w", err)
+			}
+		case *domain.Withdrawn:
+			if err := updateWithdrawnTx(tx, event, e.TxHash); err != nil {
+				return fmt.Errorf("failed to update withdrawn tx: %w", err)
+			}
+			if err := trackTx(tx, event, e.TxHash, domain.TxKindWithdrawal, domain.RuneAsset, false); err != nil {
+				return fmt.Errorf("failed to track withdrawal: %w", err)
+			}
+		case *domain.RefundIssued:
+			if err := updateRefund(tx, event, e, pq.cipher); err != nil {
+				return fmt.Errorf("failed to update refund: %w", err)
+			}
+			if err := trackTx(tx, event, e.TxHash, domain.TxKindRefund, e.Asset, false); err != nil {
+				return fmt.Errorf("failed to track refund: %w", err)
+			}
+		case *domain.EarlyWithdrawalProposed:
+			if err := proposeEarlyWithdrawal(tx, event, e); err != nil {
+				return fmt.Errorf("failed to update early withdrawal: %w", err)
+			}
+		case *domain.EarlyWithdrawalAccepted:
+			if err := acceptEarlyWithdrawal(tx, event, e); err != nil {
+				return fmt.Errorf("failed to update early withdrawal: %w", err)
+			}
+		case *domain.ExtensionProposed:
+			if err := proposeExtension(tx, event, e); err != nil {
+				return fmt.Errorf("failed to update extension: %w", err)
+			}
+		case *domain.ExtensionAccepted:
+			if err := acceptExtension(tx, event, e); err != nil {
+				return fmt.Errorf("failed to update extension: %w", err)
+			}
+		case *domain.WithdrawalSettled:
+			if err := updateSettlement(tx, event, e); err != nil {
+				return fmt.Errorf("failed to update settlement: %w", err)
+			}
+		case *domain.TxStatusChanged:
+			if err := updateTxStatus(tx, event, e); err != nil {
+				return fmt.Errorf("failed to update tx status: %w", err)
+			}
+		case *domain.SaversWithdrawTxSigned:
+			if err := updateSaversWithdrawTx(tx, event, e, pq.cipher); err != nil {
+				return fmt.Errorf("failed to update savers withdraw tx: %w", err)
+			}
+		case *domain.SaversDeposited:
+			if err := updateSavers(tx, event, e); err != nil {
+				return fmt.Errorf("failed to update savers: %w", err)
+			}
+			if err := trackTx(tx, event, e.TxHash, domain.TxKindSavers, e.Asset, e.Unverified); err != nil {
+				return fmt.Errorf("failed to track savers deposit: %w", err)
+			}
+		case *domain.SaversWithdrawn:
+			if err := updateSaversPosition(tx, event, e.Asset, "withdrawn_tx", mustMarshalJson(e.TxHash)); err != nil {
+				return fmt.Errorf("failed to update savers withdrawal: %w", err)
+			}
+			if err := trackTx(tx, event, e.TxHash, domain.TxKindWithdrawal, e.Asset, false); err != nil {
+				return fmt.Errorf("failed to track savers withdrawal: %w", err)
+			}
 		}
-	case *domain.Withdrawn:
-		if err := updateWithdrawnTx(tx, event, e.TxHash); err != nil {
-			return fmt.Errorf("failed to update withdrawn tx: %w", err)
+
+		if event.AggregateType() == "Pair" {
+			if _, err := tx.Exec(`update pairs_query set version = ? where id = ?;`, event.Version(), event.AggregateID()); err != nil {
+				return fmt.Errorf("failed to update pair version: %w", err)
+			}
+			participants, err := pairAddresses(tx, event.AggregateID(), participants)
+			if err != nil {
+				return err
+			}
+			pq.AfterCommit(func() {
+				pq.cache.Invalidate("pair:" + event.AggregateID())
+				pq.cache.InvalidatePrefix("find:")
+				pq.cache.InvalidatePrefix("active:")
+				pq.changes.Notify(event.AggregateID())
+				for _, address := range participants {
+					pq.changes.Notify(participantChangesKey(address))
+				}
+			})
 		}
+
+		return nil
+	})
+}
+
+// pairAddresses appends the addresses of the participants of the pair not in addresses yet
+func pairAddresses(tx *sql.Tx, id string, addresses []domain.Address) ([]domain.Address, error) {
+	var creator, counterparty sql.NullString
+	err := tx.QueryRow(`select creator_address, counterparty_address from pairs_query where id = ?;`, id).Scan(&creator, &counterparty)
+	if err == sql.ErrNoRows {
+		return addresses, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pair participants: %w", err)
 	}
 
-	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("failed to commit: %w", err)
+	for _, address := range []sql.NullString{creator, counterparty} {
+		if address.Valid && address.String != "" && !slices.Contains(addresses, address.String) {
+			addresses = append(addresses, address.String)
+		}
 	}
+	return addresses, nil
+}
 
-	return nil
+// participantChangesKey is the key the changes of the pairs of the participant are signaled with
+func participantChangesKey(address domain.Address) string {
+	return "participant:" + address
 }
 
 func insertPair(tx executor, event eventsourcing.Event, e *domain.PairCreated) error {
@@ -127,16 +328,28 @@ func insertPair(tx executor, event eventsourcing.Event, e *domain.PairCreated) e
 		loss_protection,
 		wallet,
 		assurances,
+		assurance_confirmations,
 		deposits,
+		deposit_amounts,
 		withdraw_tx,
 		lp,
 		deadline,
 		withdrawn_tx,
 		created_at,
-		updated_at) values (?, ?, ?, ?, ?, ?, ?, ?, ?, jsonb(?), jsonb(?), jsonb(?), jsonb(?), ?, jsonb(?), ?, ?);`,
+		updated_at,
+		network,
+		investing_period_unit,
+		grace_period_days,
+		plan_id,
+		primary_asset,
+		secondary_asset,
+		creator_address,
+		plan_type,
+		savers,
+		status_durations) values (?, jsonb(?), jsonb(?), ?, ?, ?, ?, ?, ?, jsonb(?), jsonb(?), jsonb(?), jsonb(?), jsonb(?), jsonb(?), ?, jsonb(?), ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, jsonb(?), jsonb(?));`,
 		event.AggregateID(),
-		strings.Join(assetsToStrings([]domain.Asset{e.ParticipantAsset, e.SecondaryAsset}), ","),
-		e.ParticipantAddress,
+		mustMarshalJson([]domain.Asset{e.ParticipantAsset, e.SecondaryAsset}),
+		mustMarshalJson([]domain.Address{e.ParticipantAddress}),
 		e.ShareValue,
 		e.InvestingPeriod,
 		e.WalletSecurity,
@@ -144,13 +357,25 @@ func insertPair(tx executor, event eventsourcing.Event, e *domain.PairCreated) e
 		e.LossProtection,
 		mustMarshalJson(domain.MultisigWallet{}),
 		mustMarshalJson(map[domain.Asset][]domain.SignedTx{}),
+		mustMarshalJson(map[domain.Asset]string{}),
 		mustMarshalJson(map[domain.Asset]domain.TxHash{}),
+		mustMarshalJson(map[domain.Asset]domain.TokenAmount{}),
 		mustMarshalJson(nil),
 		mustMarshalJson(map[domain.Asset]domain.TxHash{}),
 		nil,
 		nil,
 		ts,
 		ts,
+		domain.NetworkOrDefault(e.Network),
+		domain.PeriodUnitOrDefault(e.InvestingPeriodUnit),
+		e.GracePeriodDays,
+		e.PlanId,
+		e.ParticipantAsset,
+		e.SecondaryAsset,
+		e.ParticipantAddress,
+		domain.PlanTypeOrDefault(e.PlanType),
+		mustMarshalJson(map[domain.Asset]domain.SaversPosition{}),
+		mustMarshalJson(map[domain.PairStatus]int64{}),
 	)
 	return err
 }
@@ -175,21 +400,85 @@ func addressesToStrings(addresses []domain.Address) []string {
 	return strs
 }
 
+// updateStatus sets the status of the pair and adds the time spent in the status it leaves to the durations of the pair,
+// the statuses entered again add up. The pairs projected before the durations were tracked start tracking from their next status.
 func updateStatus(tx executor, event eventsourcing.Event, status domain.PairStatus) error {
-	_, err := tx.Exec(`update pairs_query set status = ?, updated_at = ? where id = ?;`,
-		status, event.Timestamp().Format(time.RFC3339), event.AggregateID())
+	ts := event.Timestamp().Format(time.RFC3339)
+	_, err := tx.Exec(`update pairs_query set
+		status_durations = case when status is null or status_entered_at is null then coalesce(status_durations, jsonb('{}'))
+			else jsonb_set(status_durations, format('$."%s"', status),
+				coalesce(json_extract(status_durations, format('$."%s"', status)), 0)
+				+ max(cast(round((julianday(?) - julianday(status_entered_at)) * 86400) as integer), 0))
+			end,
+		status = ?,
+		status_entered_at = ?,
+		updated_at = ?
+		where id = ?;`,
+		ts, status, ts, ts, event.AggregateID())
 	return err
 }
 
-func setPairMatched(tx executor, event eventsourcing.Event, e *domain.PairMatched) error {
-	_, err := tx.Exec(`update pairs_query set 
-	participant_addresses = format('%s,%s', participant_addresses, ?), 
+func setPairMatched(tx executor, event eventsourcing.Event, e *domain.PairMatched, cipher *common.Cipher) error {
+	encryptionKey, err := cipher.Encrypt(e.WalletEncryptionKey)
+	if err != nil {
+		return err
+	}
+	hexChainCode, err := cipher.Encrypt(e.WalletHexChainCode)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`update pairs_query set 
+	participant_addresses = jsonb_insert(participant_addresses, '$[#]', ?),
+	counterparty_address = ?,
 	wallet = jsonb_set(jsonb_set(wallet, '$.encryption_key', ?), '$.hex_chain_code', ?),
 	updated_at = ? 
 	where id = ?;`,
 		e.ParticipantAddress,
-		e.WalletEncryptionKey,
-		e.WalletHexChainCode,
+		e.ParticipantAddress,
+		encryptionKey,
+		hexChainCode,
+		event.Timestamp().Format(time.RFC3339),
+		event.AggregateID(),
+	)
+	return err
+}
+
+// revertPairMatch drops the counterparty and the wallet of the match, as the pair was before being matched
+func revertPairMatch(tx executor, event eventsourcing.Event) error {
+	_, err := tx.Exec(`update pairs_query set 
+	participant_addresses = jsonb_array(creator_address),
+	counterparty_address = null,
+	wallet = jsonb(?),
+	updated_at = ? 
+	where id = ?;`,
+		mustMarshalJson(domain.MultisigWallet{}),
+		event.Timestamp().Format(time.RFC3339),
+		event.AggregateID(),
+	)
+	return err
+}
+
+// regenerateWallet replaces the wallet the participants failed to generate with the one of fresh secrets
+func regenerateWallet(tx executor, event eventsourcing.Event, e *domain.KeygenFailed, cipher *common.Cipher) error {
+	encryptionKey, err := cipher.Encrypt(e.WalletEncryptionKey)
+	if err != nil {
+		return err
+	}
+	hexChainCode, err := cipher.Encrypt(e.WalletHexChainCode)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`update pairs_query set
+		wallet = jsonb(?),
+		updated_at = ?
+		where id = ?;`,
+		mustMarshalJson(domain.MultisigWallet{
+			EncryptionKey:  encryptionKey,
+			HexChainCode:   hexChainCode,
+			KeygenFailures: e.Failures,
+		}),
 		event.Timestamp().Format(time.RFC3339),
 		event.AggregateID(),
 	)
@@ -210,13 +499,47 @@ func updateMultisigWallet(tx executor, event eventsourcing.Event, e *domain.Wall
 	return err
 }
 
-func updateAssurances(tx executor, event eventsourcing.Event, e *domain.AssetAssuranceSigned) error {
-	_, err := tx.Exec(`update pairs_query set
+func updateAssurances(tx executor, event eventsourcing.Event, e *domain.AssetAssuranceSigned, cipher *common.Cipher) error {
+	signedTx, err := cipher.SealJSON(mustMarshalJson(e.Tx), signedTxEncryptedFields)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`update pairs_query set
 		assurances = jsonb_set(assurances, format('$."%s"[#]', ?), jsonb(?)),
 		updated_at = ?
 		where id = ?;`,
 		e.Asset,
-		mustMarshalJson(e.Tx),
+		signedTx,
+		event.Timestamp().Format(time.RFC3339),
+		event.AggregateID(),
+	)
+	return err
+}
+
+func updateAssuranceConfirmations(tx executor, event eventsourcing.Event, e *domain.AssurancesConfirmed) error {
+	_, err := tx.Exec(`update pairs_query set
+		assurance_confirmations = jsonb_set(assurance_confirmations, format('$."%s"', ?), ?),
+		updated_at = ?
+		where id = ?;`,
+		e.Asset,
+		e.Digest,
+		event.Timestamp().Format(time.RFC3339),
+		event.AggregateID(),
+	)
+	return err
+}
+
+// invalidateAssurances drops the assurances, their confirmations and the confirmations of the wallet generated again
+func invalidateAssurances(tx executor, event eventsourcing.Event) error {
+	_, err := tx.Exec(`update pairs_query set
+		assurances = jsonb(?),
+		assurance_confirmations = jsonb(?),
+		wallet = jsonb_remove(wallet, '$.public_keys', '$.addresses'),
+		updated_at = ?
+		where id = ?;`,
+		mustMarshalJson(map[domain.Asset][]domain.SignedTx{}),
+		mustMarshalJson(map[domain.Asset]string{}),
 		event.Timestamp().Format(time.RFC3339),
 		event.AggregateID(),
 	)
@@ -233,15 +556,31 @@ func updateDeposits(tx executor, event eventsourcing.Event, e *domain.AssetDepos
 		event.Timestamp().Format(time.RFC3339),
 		event.AggregateID(),
 	)
+	if err != nil || e.Amount == "" {
+		return err
+	}
+
+	_, err = tx.Exec(`update pairs_query set
+		deposit_amounts = jsonb_set(deposit_amounts, format('$."%s"', ?), jsonb(?))
+		where id = ?;`,
+		e.Asset,
+		mustMarshalJson(domain.TokenAmount{Amount: e.Amount, Decimals: e.Decimals}),
+		event.AggregateID(),
+	)
 	return err
 }
 
-func updateWithdrawTx(tx executor, event eventsourcing.Event, e *domain.WithdrawTxSigned) error {
-	_, err := tx.Exec(`update pairs_query set
+func updateWithdrawTx(tx executor, event eventsourcing.Event, e *domain.WithdrawTxSigned, cipher *common.Cipher) error {
+	signedTx, err := cipher.SealJSON(mustMarshalJson(e.Tx), signedTxEncryptedFields)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`update pairs_query set
 		withdraw_tx = jsonb(?),
 		updated_at = ?
 		where id = ?;`,
-		mustMarshalJson(e.Tx),
+		signedTx,
 		event.Timestamp().Format(time.RFC3339),
 		event.AggregateID(),
 	)
@@ -249,14 +588,56 @@ func updateWithdrawTx(tx executor, event eventsourcing.Event, e *domain.Withdraw
 }
 
 func updateLP(tx executor, event eventsourcing.Event, e *domain.LPDone) error {
+	// Only the later of both LPs starts the investing period
+	var deadline any
+	if !e.Deadline.IsZero() {
+		deadline = e.Deadline.Format(time.RFC3339)
+	}
 	_, err := tx.Exec(`update pairs_query set
 		lp = jsonb_set(lp, format('$."%s"', ?), ?),
-		deadline = ?,
+		deadline = coalesce(?, deadline),
 		updated_at = ?
 		where id = ?;`,
 		e.Asset,
 		e.TxHash,
-		e.Deadline.Format(time.RFC3339),
+		deadline,
+		event.Timestamp().Format(time.RFC3339),
+		event.AggregateID(),
+	)
+	return err
+}
+
+func updateSaversWithdrawTx(tx executor, event eventsourcing.Event, e *domain.SaversWithdrawTxSigned, cipher *common.Cipher) error {
+	signedTx, err := cipher.SealJSON(mustMarshalJson(e.Tx), signedTxEncryptedFields)
+	if err != nil {
+		return err
+	}
+
+	return updateSaversPosition(tx, event, e.Asset, "withdraw_tx", signedTx)
+}
+
+func updateSavers(tx executor, event eventsourcing.Event, e *domain.SaversDeposited) error {
+	if err := updateSaversPosition(tx, event, e.Asset, "tx_hash", mustMarshalJson(e.TxHash)); err != nil {
+		return err
+	}
+
+	// Only the later of both deposits starts the investing period
+	if e.Deadline.IsZero() {
+		return nil
+	}
+	_, err := tx.Exec(`update pairs_query set deadline = ? where id = ?;`, e.Deadline.Format(time.RFC3339), event.AggregateID())
+	return err
+}
+
+// updateSaversPosition sets the field of the position of the asset in its Savers vault to the JSON value
+func updateSaversPosition(tx executor, event eventsourcing.Event, asset domain.Asset, field string, value []byte) error {
+	_, err := tx.Exec(`update pairs_query set
+		savers = jsonb_set(coalesce(savers, jsonb('{}')), format('$."%s".%s', ?, ?), jsonb(?)),
+		updated_at = ?
+		where id = ?;`,
+		asset,
+		field,
+		value,
 		event.Timestamp().Format(time.RFC3339),
 		event.AggregateID(),
 	)
@@ -275,264 +656,677 @@ func updateWithdrawnTx(tx executor, event eventsourcing.Event, txHash domain.TxH
 	return err
 }
 
+func updateRefund(tx executor, event eventsourcing.Event, e *domain.RefundIssued, cipher *common.Cipher) error {
+	refund, err := cipher.SealJSON(mustMarshalJson(e), refundEncryptedFields)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`update pairs_query set
+		refund = jsonb(?),
+		updated_at = ?
+		where id = ?;`,
+		refund,
+		event.Timestamp().Format(time.RFC3339),
+		event.AggregateID(),
+	)
+	return err
+}
+
+func proposeEarlyWithdrawal(tx executor, event eventsourcing.Event, e *domain.EarlyWithdrawalProposed) error {
+	_, err := tx.Exec(`update pairs_query set
+		early_withdrawal = jsonb(?),
+		updated_at = ?
+		where id = ?;`,
+		mustMarshalJson(domain.EarlyWithdrawal{
+			ProposedBy: e.ParticipantAddress,
+			Reason:     e.Reason,
+			ProposedAt: event.Timestamp(),
+		}),
+		event.Timestamp().Format(time.RFC3339),
+		event.AggregateID(),
+	)
+	return err
+}
+
+func acceptEarlyWithdrawal(tx executor, event eventsourcing.Event, e *domain.EarlyWithdrawalAccepted) error {
+	_, err := tx.Exec(`update pairs_query set
+		early_withdrawal = jsonb_set(jsonb_set(early_withdrawal, '$.accepted_by', ?), '$.accepted_at', ?),
+		updated_at = ?
+		where id = ?;`,
+		e.ParticipantAddress,
+		event.Timestamp().Format(time.RFC3339Nano),
+		event.Timestamp().Format(time.RFC3339),
+		event.AggregateID(),
+	)
+	return err
+}
+
+func proposeExtension(tx executor, event eventsourcing.Event, e *domain.ExtensionProposed) error {
+	_, err := tx.Exec(`update pairs_query set
+		pending_extension = jsonb(?),
+		updated_at = ?
+		where id = ?;`,
+		mustMarshalJson(e),
+		event.Timestamp().Format(time.RFC3339),
+		event.AggregateID(),
+	)
+	return err
+}
+
+func acceptExtension(tx executor, event eventsourcing.Event, e *domain.ExtensionAccepted) error {
+	// A rollover also replaces the investing period of the pair
+	var period, unit any
+	if e.Rollover {
+		period, unit = e.InvestingPeriod, e.InvestingPeriodUnit
+	}
+	_, err := tx.Exec(`update pairs_query set
+		deadline = ?,
+		investing_period = coalesce(?, investing_period),
+		investing_period_unit = coalesce(?, investing_period_unit),
+		pending_extension = null,
+		updated_at = ?
+		where id = ?;`,
+		e.Deadline.Format(time.RFC3339),
+		period,
+		unit,
+		event.Timestamp().Format(time.RFC3339),
+		event.AggregateID(),
+	)
+	return err
+}
+
+func updateSettlement(tx executor, event eventsourcing.Event, e *domain.WithdrawalSettled) error {
+	_, err := tx.Exec(`update pairs_query set
+		settlement = jsonb(?),
+		updated_at = ?
+		where id = ?;`,
+		mustMarshalJson(e.Report),
+		event.Timestamp().Format(time.RFC3339),
+		event.AggregateID(),
+	)
+	return err
+}
+
+// trackTx records the transaction of the pair as pending, the transactions the server didn't get the hash of aren't tracked
+func trackTx(tx executor, event eventsourcing.Event, hash domain.TxHash, kind domain.TxKind, asset domain.Asset, unverified bool) error {
+	if hash == "" {
+		return nil
+	}
+	_, err := tx.Exec(`update pairs_query set
+		txs = jsonb_set(coalesce(txs, jsonb('{}')), format('$."%s"', ?), jsonb(?))
+		where id = ?;`,
+		hash,
+		mustMarshalJson(domain.TrackedTx{
+			Kind:       kind,
+			Asset:      asset,
+			Status:     domain.TxStatusPending,
+			Unverified: unverified,
+			RecordedAt: event.Timestamp(),
+			UpdatedAt:  event.Timestamp(),
+		}),
+		event.AggregateID(),
+	)
+	return err
+}
+
+func updateTxStatus(tx executor, event eventsourcing.Event, e *domain.TxStatusChanged) error {
+	// The status fields are merged into the tracked transaction, its kind and asset are kept
+	patch := map[domain.TxHash]map[string]any{
+		e.TxHash: {
+			"status":        e.Status,
+			"confirmations": e.Confirmations,
+			"block_hash":    e.BlockHash,
+			"reason":        e.Reason,
+			"updated_at":    event.Timestamp(),
+		},
+	}
+	_, err := tx.Exec(`update pairs_query set
+		txs = jsonb_patch(txs, jsonb(?)),
+		updated_at = ?
+		where id = ?;`,
+		mustMarshalJson(patch),
+		event.Timestamp().Format(time.RFC3339),
+		event.AggregateID(),
+	)
+	return err
+}
+
 // Pair represents a pair
 type Pair struct {
-	Id                    string                             `json:"id"`
-	Status                domain.PairStatus                  `json:"status"`
-	Assets                []domain.Asset                     `json:"assets"`
-	ParticipantAddresses  []domain.Address                   `json:"participant_addresses"`
-	ShareValue            int                                `json:"share_value"`
-	InvestingPeriod       int                                `json:"investing_period"`
-	WalletSecurity        domain.MultiSigWalletSecurity      `json:"wallet_security"`
-	ProfitSharingStrategy domain.ProfitSharingStrategy       `json:"profit_sharing_strategy"`
-	LossProtection        float64                            `json:"loss_protection"`
-	Wallet                *domain.MultisigWallet             `json:"wallet"`
-	Assurances            map[domain.Asset][]domain.SignedTx `json:"assurances"`
-	Deposits              map[domain.Asset]domain.TxHash     `json:"deposits"`
-	WithdrawTx            *domain.SignedTx                   `json:"withdraw_tx"`
-	LP                    map[domain.Asset]domain.TxHash     `json:"lp"`
-	Deadline              *time.Time                         `json:"deadline"`
-	WithdrawnTx           *domain.TxHash                     `json:"withdrawn_tx"`
-	CreatedAt             time.Time                          `json:"created_at"`
-	UpdatedAt             time.Time                          `json:"updated_at"`
-}
-
-// Find finds pairs by given conditions
-// TODO: Add pagination and order by
-func (pq *PairsQuery) Find(
-	ctx context.Context,
-	status *domain.PairStatus,
-	assets []domain.Asset,
-	assetsOrder bool,
-	participantAddresses []domain.Address,
-	shareValue *int,
-	investingPeriod *int,
-	walletSecurity *domain.MultiSigWalletSecurity,
-	profitSharingStrategy *domain.ProfitSharingStrategy,
-	lossProtection *float64,
-) ([]Pair, error) {
+	Id                     string                              `json:"id"`
+	PlanId                 string                              `json:"plan_id,omitempty"`
+	PlanType               domain.PlanType                     `json:"plan_type"`
+	Status                 domain.PairStatus                   `json:"status"`
+	Substatus              PairSubstatus                       `json:"substatus,omitempty"`
+	Assets                 []domain.Asset                      `json:"assets"`
+	ParticipantAddresses   []domain.Address                    `json:"participant_addresses"`
+	ShareValue             int                                 `json:"share_value"`
+	InvestingPeriod        int                                 `json:"investing_period"`
+	InvestingPeriodUnit    domain.PeriodUnit                   `json:"investing_period_unit"`
+	GracePeriodDays        int                                 `json:"grace_period_days"`
+	WalletSecurity         domain.MultiSigWalletSecurity       `json:"wallet_security"`
+	ProfitSharingStrategy  domain.ProfitSharingStrategy        `json:"profit_sharing_strategy"`
+	LossProtection         float64                             `json:"loss_protection"`
+	Wallet                 *domain.MultisigWallet              `json:"wallet"`
+	Assurances             map[domain.Asset][]domain.SignedTx  `json:"assurances"`
+	AssuranceConfirmations map[domain.Asset]string             `json:"assurance_confirmations"`
+	Deposits               map[domain.Asset]domain.TxHash      `json:"deposits"`
+	DepositAmounts         map[domain.Asset]domain.TokenAmount `json:"deposit_amounts"`
+	WithdrawTx             *domain.SignedTx                    `json:"withdraw_tx"`
+	LP                     map[domain.Asset]domain.TxHash      `json:"lp"`
+	Deadline               *time.Time                          `json:"deadline"`
+	// GraceDeadline is the time the participants have to withdraw by
+	GraceDeadline *time.Time     `json:"grace_deadline"`
+	WithdrawnTx   *domain.TxHash `json:"withdrawn_tx"`
+	CreatedAt     time.Time      `json:"created_at"`
+	UpdatedAt     time.Time      `json:"updated_at"`
+	Archived      bool           `json:"archived"`
+	Network       domain.Network `json:"network"`
+	// Version is the version of the pair aggregate the state reflects, it's bumped by every event of the pair
+	Version int `json:"version"`
+	// Refund holds the assurances released to the participant whose deposit was refunded
+	Refund *domain.RefundIssued `json:"refund,omitempty"`
+	// EarlyWithdrawal is the proposal to withdraw before the deadline and its acceptance
+	EarlyWithdrawal *domain.EarlyWithdrawal `json:"early_withdrawal,omitempty"`
+	// PendingExtension is the proposal to extend the investing period waiting for the counterparty
+	PendingExtension *domain.ExtensionProposed `json:"pending_extension,omitempty"`
+	// Settlement is the report of the withdrawal once it's confirmed on chain
+	Settlement *domain.SettlementReport `json:"settlement,omitempty"`
+	// Txs holds the status on chain of the transactions of the pair by hash
+	Txs map[domain.TxHash]domain.TrackedTx `json:"txs,omitempty"`
+	// Savers holds the positions of the assets of a savers pair in their Savers vaults
+	Savers map[domain.Asset]*domain.SaversPosition `json:"savers,omitempty"`
+	// StatusDurations holds how many seconds the pair spent in each status it left, the statuses entered again add up
+	StatusDurations map[domain.PairStatus]int64 `json:"status_durations,omitempty"`
+	// StatusEnteredAt is when the pair entered its current status
+	StatusEnteredAt *time.Time `json:"status_entered_at,omitempty"`
+	// Nonces holds the nonces of the wallet address of each asset taken by the pre-signed transactions
+	Nonces map[domain.Asset][]domain.NonceAllocation `json:"nonces,omitempty"`
+}
+
+// PairSubstatus tells which participants the pair is waiting for within its status
+type PairSubstatus string
+
+const (
+	PairSubstatusAwaitingAssurances    PairSubstatus = "awaiting_assurances"
+	PairSubstatusAwaitingConfirmations PairSubstatus = "awaiting_confirmations"
+)
+
+// substatusOf derives the substatus of the pair, the participants waited for are the assets missing from the related maps
+func substatusOf(p *Pair) PairSubstatus {
+	if p.Status != domain.PairStatusAssurance {
+		return ""
+	}
+	if len(p.Assurances) < 2 {
+		return PairSubstatusAwaitingAssurances
+	}
+	return PairSubstatusAwaitingConfirmations
+}
+
+// derive sets the fields derived from the state of the pair
+func (p *Pair) derive() {
+	p.Substatus = substatusOf(p)
+	if nonces := domain.AllocateNonces(p.Assurances, p.WithdrawTx, p.Savers); len(nonces) > 0 {
+		p.Nonces = nonces
+	}
+	if p.Deadline != nil {
+		grace := p.Deadline.AddDate(0, 0, p.GracePeriodDays)
+		p.GraceDeadline = &grace
+	}
+}
+
+// PairFromAggregate maps the state of the pair aggregate to the Pair served by the query, for the pairs not projected yet.
+// The aggregate doesn't keep when it was created and last changed, so they are given by the timestamps of its first and last events.
+func PairFromAggregate(p *domain.Pair, createdAt, updatedAt time.Time) *Pair {
+	pair := &Pair{
+		Id:                     p.ID(),
+		PlanId:                 p.PlanId,
+		PlanType:               domain.PlanTypeOrDefault(p.PlanType),
+		Status:                 p.Status,
+		Assets:                 p.Assets,
+		ParticipantAddresses:   []domain.Address{},
+		ShareValue:             p.ShareValue,
+		InvestingPeriod:        p.InvestingPeriod,
+		InvestingPeriodUnit:    domain.PeriodUnitOrDefault(p.InvestingPeriodUnit),
+		GracePeriodDays:        p.GracePeriodDays,
+		WalletSecurity:         p.WalletSecurity,
+		ProfitSharingStrategy:  p.ProfitSharingStrategy,
+		LossProtection:         p.LossProtection,
+		Wallet:                 p.Wallet,
+		Assurances:             mapOrEmpty(p.Assurances),
+		AssuranceConfirmations: mapOrEmpty(p.AssuranceConfirmations),
+		Deposits:               mapOrEmpty(p.Deposits),
+		DepositAmounts:         mapOrEmpty(p.DepositAmounts),
+		WithdrawTx:             p.WithdrawTx,
+		LP:                     mapOrEmpty(p.LP),
+		WithdrawnTx:            p.WithdrawnTx,
+		CreatedAt:              createdAt.UTC().Truncate(time.Second),
+		UpdatedAt:              updatedAt.UTC().Truncate(time.Second),
+		Network:                domain.NetworkOrDefault(p.Network),
+		Version:                int(p.Version()),
+		Refund:                 p.Refund,
+		EarlyWithdrawal:        p.EarlyWithdrawal,
+		PendingExtension:       p.PendingExtension,
+		Settlement:             p.Settlement,
+		Txs:                    p.Txs,
+		Savers:                 p.Savers,
+	}
+	// The participant addresses are ordered as the assets of the pair
+	for _, asset := range p.Assets {
+		if address, ok := p.ParticipantsAddress[asset]; ok {
+			pair.ParticipantAddresses = append(pair.ParticipantAddresses, address)
+		}
+	}
+	if !p.Deadline.IsZero() {
+		deadline := p.Deadline
+		pair.Deadline = &deadline
+	}
+	pair.derive()
+
+	return pair
+}
+
+// mapOrEmpty returns an empty map for nil, so the maps of the pairs are served as the projected ones
+func mapOrEmpty[K comparable, V any](m map[K]V) map[K]V {
+	if m == nil {
+		return make(map[K]V)
+	}
+	return m
+}
+
+// pairColumns are the columns selected to build a Pair
+var pairColumns = []string{
+	"id",
+	"status",
+	"json(assets)",
+	"json(participant_addresses)",
+	"share_value",
+	"investing_period",
+	"wallet_security",
+	"profit_sharing_strategy",
+	"loss_protection",
+	"json(wallet)",
+	"json(assurances)",
+	"json(assurance_confirmations)",
+	"json(deposits)",
+	"json(deposit_amounts)",
+	"json(withdraw_tx)",
+	"json(lp)",
+	"deadline",
+	"withdrawn_tx",
+	"created_at",
+	"updated_at",
+	"network",
+	"investing_period_unit",
+	"grace_period_days",
+	"plan_id",
+	"coalesce(version, 0)",
+	"coalesce(json(refund), 'null')",
+	"coalesce(json(early_withdrawal), 'null')",
+	"coalesce(json(pending_extension), 'null')",
+	"coalesce(json(settlement), 'null')",
+	"coalesce(json(txs), 'null')",
+	"coalesce(plan_type, 'lp')",
+	"coalesce(json(savers), 'null')",
+	"status_entered_at",
+	"coalesce(json(status_durations), 'null')",
+}
+
+const (
+	pairsTable         = "pairs_query"
+	archivedPairsTable = "pairs_query_archive"
+)
+
+func newPairsSelectBuilder(table string) *sqlbuilder.SelectBuilder {
 	b := sqlbuilder.NewSelectBuilder()
 	b.SetFlavor(sqlbuilder.SQLite)
-	b.Select(
-		"id",
-		"status",
-		"assets",
-		"participant_addresses",
-		"share_value",
-		"investing_period",
-		"wallet_security",
-		"profit_sharing_strategy",
-		"loss_protection",
-		"json(wallet)",
-		"json(assurances)",
-		"json(deposits)",
-		"json(withdraw_tx)",
-		"json(lp)",
-		"deadline",
-		"withdrawn_tx",
-		"created_at",
-		"updated_at",
-	).From("pairs_query")
-	if status != nil {
-		b.Where(b.Equal("status", string(*status)))
-	}
-	if len(assets) > 0 {
-		if len(assets) > 1 {
-			if assetsOrder {
-				b.Where(b.Equal("assets", strings.Join(assetsToStrings(assets), ",")))
-			} else {
-				b.Where(b.And(
-					b.Like("assets", fmt.Sprintf("%%%s%%", assets[0])),
-					b.Like("assets", fmt.Sprintf("%%%s%%", assets[1])),
-				))
-			}
-		} else {
-			b.Where(b.Like("assets", fmt.Sprintf("%%%s%%", assets[0])))
+	b.Select(pairColumns...).From(table)
+	return b
+}
+
+// PairFilter are the conditions to find the pairs with, nil and zero fields don't constrain the pairs
+type PairFilter struct {
+	Network *domain.Network
+	// PlanId matches the pairs linked to the plan, the pairs created before they were linked to their plans have none
+	PlanId   string
+	PlanType *domain.PlanType
+	Status   *domain.PairStatus
+	// Assets match the pairs containing all of them, in the same order when AssetsOrder is set
+	Assets      []domain.Asset
+	AssetsOrder bool
+	// ParticipantAddresses match the pairs every one of them participates in
+	ParticipantAddresses  []domain.Address
+	ShareValue            *int
+	InvestingPeriod       *int
+	InvestingPeriodUnit   *domain.PeriodUnit
+	GracePeriodDays       *int
+	WalletSecurity        *domain.MultiSigWalletSecurity
+	ProfitSharingStrategy *domain.ProfitSharingStrategy
+	LossProtection        *float64
+	CreatedAfter          time.Time
+	CreatedBefore         time.Time
+	IncludeArchived       bool
+}
+
+// Find finds pairs by given conditions, the archived pairs are included on demand
+// TODO: Add pagination and order by
+func (pq *PairsQuery) Find(ctx context.Context, f PairFilter) ([]Pair, error) {
+	b := newPairsFilterBuilder(f)
+	query, args := b.Build()
+	return common.Cached(pq.cache, fmt.Sprintf("find:%t:%s:%v", f.IncludeArchived, query, args), func() ([]Pair, error) {
+		pairs, err := pq.query(ctx, pairsTable, b)
+		if err != nil || !f.IncludeArchived {
+			return pairs, err
 		}
-	}
-	if len(participantAddresses) > 0 {
-		if len(participantAddresses) > 1 {
-			b.Where(b.And(
-				b.Like("participant_addresses", fmt.Sprintf("%%%s%%", participantAddresses[0])),
-				b.Like("participant_addresses", fmt.Sprintf("%%%s%%", participantAddresses[1])),
-			))
-		} else {
-			b.Where(b.Like("participant_addresses", fmt.Sprintf("%%%s%%", participantAddresses[0])))
+
+		// The archive has the same columns, so the same conditions apply to it
+		archived, err := pq.query(ctx, archivedPairsTable, b)
+		if err != nil {
+			return nil, err
+		}
+		for i := range archived {
+			archived[i].Archived = true
 		}
+
+		return append(pairs, archived...), nil
+	})
+}
+
+// Each calls fn with the pairs found by the conditions as they are read, the live ones then the archived ones on demand,
+// both ordered by creation. The pairs aren't held in memory nor cached, so it suits the exports of many pairs.
+func (pq *PairsQuery) Each(ctx context.Context, f PairFilter, fn func(Pair) error) error {
+	b := newPairsFilterBuilder(f)
+	b.OrderBy("created_at", "id")
+	if err := pq.each(ctx, pairsTable, b, fn); err != nil || !f.IncludeArchived {
+		return err
+	}
+
+	return pq.each(ctx, archivedPairsTable, b, func(p Pair) error {
+		p.Archived = true
+		return fn(p)
+	})
+}
+
+// newPairsFilterBuilder builds the select statement of the pairs matching the conditions, its table is set by the callers
+func newPairsFilterBuilder(f PairFilter) *sqlbuilder.SelectBuilder {
+	b := newPairsSelectBuilder(pairsTable)
+	if f.Network != nil {
+		b.Where(b.Equal("network", string(*f.Network)))
+	}
+	if f.PlanId != "" {
+		b.Where(b.Equal("plan_id", f.PlanId))
+	}
+	if f.PlanType != nil {
+		b.Where(b.Equal("coalesce(plan_type, 'lp')", string(domain.PlanTypeOrDefault(*f.PlanType))))
+	}
+	if f.Status != nil {
+		b.Where(b.Equal("status", string(*f.Status)))
+	}
+	switch {
+	case len(f.Assets) > 1 && f.AssetsOrder:
+		b.Where(b.Equal("primary_asset", string(f.Assets[0])), b.Equal("secondary_asset", string(f.Assets[1])))
+	case len(f.Assets) > 1:
+		b.Where(b.Or(
+			b.And(b.Equal("primary_asset", string(f.Assets[0])), b.Equal("secondary_asset", string(f.Assets[1]))),
+			b.And(b.Equal("primary_asset", string(f.Assets[1])), b.Equal("secondary_asset", string(f.Assets[0]))),
+		))
+	case len(f.Assets) == 1:
+		b.Where(b.Or(b.Equal("primary_asset", string(f.Assets[0])), b.Equal("secondary_asset", string(f.Assets[0]))))
+	}
+	for _, address := range f.ParticipantAddresses {
+		b.Where(b.Or(b.Equal("creator_address", string(address)), b.Equal("counterparty_address", string(address))))
+	}
+	if f.ShareValue != nil {
+		b.Where(b.Equal("share_value", *f.ShareValue))
 	}
-	if shareValue != nil {
-		b.Where(b.Equal("share_value", *shareValue))
+	if f.InvestingPeriod != nil {
+		b.Where(b.Equal("investing_period", *f.InvestingPeriod))
 	}
-	if investingPeriod != nil {
-		b.Where(b.Equal("investing_period", *investingPeriod))
+	if f.InvestingPeriodUnit != nil {
+		b.Where(b.Equal("investing_period_unit", string(*f.InvestingPeriodUnit)))
 	}
-	if walletSecurity != nil {
-		b.Where(b.Equal("wallet_security", string(*walletSecurity)))
+	if f.GracePeriodDays != nil {
+		b.Where(b.Equal("grace_period_days", *f.GracePeriodDays))
 	}
-	if profitSharingStrategy != nil {
-		b.Where(b.Equal("profit_sharing_strategy", string(*profitSharingStrategy)))
+	if f.WalletSecurity != nil {
+		b.Where(b.Equal("wallet_security", string(*f.WalletSecurity)))
 	}
-	if lossProtection != nil {
-		b.Where(b.Equal("loss_protection", *lossProtection))
+	if f.ProfitSharingStrategy != nil {
+		b.Where(b.Equal("profit_sharing_strategy", string(*f.ProfitSharingStrategy)))
 	}
+	if f.LossProtection != nil {
+		b.Where(b.Equal("loss_protection", *f.LossProtection))
+	}
+	if !f.CreatedAfter.IsZero() {
+		b.Where(fmt.Sprintf("datetime(created_at) >= datetime(%s)", b.Var(f.CreatedAfter.Format(time.RFC3339))))
+	}
+	if !f.CreatedBefore.IsZero() {
+		b.Where(fmt.Sprintf("datetime(created_at) < datetime(%s)", b.Var(f.CreatedBefore.Format(time.RFC3339))))
+	}
+
+	return b
+}
+
+// CountActive returns the number of pairs in progress by their plan id, i.e. the pairs that haven't reached a terminal status.
+// Pairs created before they were linked to their plans are not counted.
+func (pq *PairsQuery) CountActive(ctx context.Context) (map[string]int, error) {
+	return common.Cached(pq.cache, "active:", func() (map[string]int, error) {
+		b := sqlbuilder.NewSelectBuilder()
+		b.SetFlavor(sqlbuilder.SQLite)
+		b.Select("plan_id", "count(*)").From(pairsTable).Where(
+			b.NotEqual("plan_id", ""),
+			b.NotIn("status", archivedPairStatuses...),
+		).GroupBy("plan_id")
+
+		query, args := b.Build()
+		rows, err := pq.Reader().QueryContext(ctx, query, args...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to count active pairs: %w", err)
+		}
+		defer rows.Close()
+
+		counts := make(map[string]int)
+		for rows.Next() {
+			var (
+				planId string
+				count  int
+			)
+			if err := rows.Scan(&planId, &count); err != nil {
+				return nil, fmt.Errorf("failed to scan active pairs count: %w", err)
+			}
+			counts[planId] = count
+		}
+
+		return counts, rows.Err()
+	})
+}
+
+// ParticipantPairCounts are the numbers of pairs in progress of a participant address
+type ParticipantPairCounts struct {
+	// Active is the number of pairs that haven't reached a terminal status, including the waiting ones
+	Active int
+	// WaitingByPlan is the number of pairs waiting for a counterparty by plan id
+	WaitingByPlan map[string]int
+}
+
+// CountParticipantPairs counts the pairs in progress the address participates in, on every network.
+// They are counted as projected, so the pairs of the events not handled yet are missing.
+func (pq *PairsQuery) CountParticipantPairs(ctx context.Context, address domain.Address) (ParticipantPairCounts, error) {
+	b := sqlbuilder.NewSelectBuilder()
+	b.SetFlavor(sqlbuilder.SQLite)
+	b.Select("plan_id", "status", "count(*)").From(pairsTable).Where(
+		b.Or(b.Equal("creator_address", string(address)), b.Equal("counterparty_address", string(address))),
+		b.NotIn("status", archivedPairStatuses...),
+	).GroupBy("plan_id", "status")
 
 	query, args := b.Build()
-	rows, err := pq.QueryContext(ctx, query, args...)
+	rows, err := pq.Reader().QueryContext(ctx, query, args...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query pairs: %w", err)
+		return ParticipantPairCounts{}, fmt.Errorf("failed to count participant pairs: %w", err)
 	}
 	defer rows.Close()
 
-	pairs := []Pair{}
+	counts := ParticipantPairCounts{WaitingByPlan: make(map[string]int)}
 	for rows.Next() {
 		var (
-			id                    string
-			status                string
-			assets                string
-			participantAddresses  string
-			shareValue            int
-			investingPeriod       int
-			walletSecurity        string
-			profitSharingStrategy string
-			lossProtection        float64
-			wallet                []byte
-			assurances            []byte
-			deposits              []byte
-			withdrawTx            []byte
-			lp                    []byte
-			deadline              sql.NullString
-			withdrawnTx           sql.NullString
-			createdAt             string
-			updatedAt             string
+			planId string
+			status domain.PairStatus
+			count  int
 		)
-		if err := rows.Scan(
-			&id,
-			&status,
-			&assets,
-			&participantAddresses,
-			&shareValue,
-			&investingPeriod,
-			&walletSecurity,
-			&profitSharingStrategy,
-			&lossProtection,
-			&wallet,
-			&assurances,
-			&deposits,
-			&withdrawTx,
-			&lp,
-			&deadline,
-			&withdrawnTx,
-			&createdAt,
-			&updatedAt,
-		); err != nil {
-			return nil, fmt.Errorf("failed to scan pair: %w", err)
+		if err := rows.Scan(&planId, &status, &count); err != nil {
+			return ParticipantPairCounts{}, fmt.Errorf("failed to scan participant pairs count: %w", err)
 		}
-		p := Pair{
-			Id:                    id,
-			Status:                domain.PairStatus(status),
-			Assets:                stringsToAssets(strings.Split(assets, ",")),
-			ParticipantAddresses:  stringsToAddresses(strings.Split(participantAddresses, ",")),
-			ShareValue:            shareValue,
-			InvestingPeriod:       investingPeriod,
-			WalletSecurity:        domain.MultiSigWalletSecurity(walletSecurity),
-			ProfitSharingStrategy: domain.ProfitSharingStrategy(profitSharingStrategy),
-			LossProtection:        lossProtection,
-			Wallet:                mustUnmarshalToPointer[domain.MultisigWallet](wallet),
-			Assurances:            mustUnmarshalToType[map[domain.Asset][]domain.SignedTx](assurances),
-			Deposits:              mustUnmarshalToType[map[domain.Asset]domain.TxHash](deposits),
-			WithdrawTx:            mustUnmarshalToPointer[domain.SignedTx](withdrawTx),
-			LP:                    mustUnmarshalToType[map[domain.Asset]domain.TxHash](lp),
-			Deadline:              nullStringToTime(deadline),
-			WithdrawnTx:           (*domain.TxHash)(nullStringToPointer(withdrawnTx)),
-			CreatedAt:             mustParseTime(createdAt),
-			UpdatedAt:             mustParseTime(updatedAt),
+		counts.Active += count
+		if status == domain.PairStatusWaiting {
+			counts.WaitingByPlan[planId] += count
 		}
+	}
+
+	return counts, rows.Err()
+}
+
+// OnCorruptRow sets the function reporting the rows skipped by the listings as they can't be decoded
+func (pq *PairsQuery) OnCorruptRow(fn func(*CorruptRowError)) {
+	pq.onCorruptRow = fn
+}
+
+// query runs the select statement on the table and scans all the resulting pairs
+func (pq *PairsQuery) query(ctx context.Context, table string, b *sqlbuilder.SelectBuilder) ([]Pair, error) {
+	pairs := []Pair{}
+	err := pq.each(ctx, table, b, func(p Pair) error {
 		pairs = append(pairs, p)
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	return pairs, nil
 }
 
-func stringsToAddresses(strs []string) []domain.Address {
-	addresses := make([]domain.Address, len(strs))
-	for i, s := range strs {
-		addresses[i] = domain.Address(s)
+// each runs the select statement on the table and calls fn with the resulting pairs as they are scanned, it stops at the first error of fn.
+// The corrupt rows are skipped and reported, a single bad record doesn't fail the whole listing.
+func (pq *PairsQuery) each(ctx context.Context, table string, b *sqlbuilder.SelectBuilder, fn func(Pair) error) error {
+	query, args := b.From(table).Build()
+	rows, err := pq.Reader().QueryContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to query pairs: %w", err)
 	}
-	return addresses
-}
+	defer rows.Close()
 
-func mustUnmarshalToType[T any](b []byte) T {
-	var v T
-	if err := json.Unmarshal(b, &v); err != nil {
-		panic(err)
+	for rows.Next() {
+		p, err := pq.scanPair(rows, table)
+		var corrupt *CorruptRowError
+		if errors.As(err, &corrupt) {
+			pq.onCorruptRow(corrupt)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if err := fn(*p); err != nil {
+			return err
+		}
 	}
-	return v
+
+	return rows.Err()
 }
 
-func mustUnmarshalToPointer[T any](b []byte) *T {
-	var v *T
-	if err := json.Unmarshal(b, &v); err != nil {
-		panic(err)
+// Verify scans all the live and archived pairs and returns the corrupt ones, see CorruptRowError
+func (pq *PairsQuery) Verify(ctx context.Context) ([]CorruptRowError, error) {
+	corrupt := []CorruptRowError{}
+	for _, table := range []string{pairsTable, archivedPairsTable} {
+		query, args := newPairsSelectBuilder(table).Build()
+		rows, err := pq.Reader().QueryContext(ctx, query, args...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query %s: %w", table, err)
+		}
+		for rows.Next() {
+			_, err := pq.scanPair(rows, table)
+			var c *CorruptRowError
+			if errors.As(err, &c) {
+				corrupt = append(corrupt, *c)
+				continue
+			}
+			if err != nil {
+				rows.Close()
+				return nil, err
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
 	}
-	return v
+
+	return corrupt, nil
 }
 
-func nullStringToTime(ns sql.NullString) *time.Time {
-	if ns.Valid {
-		t := mustParseTime(ns.String)
-		return &t
-	}
-	return nil
+// QueryPlan is the plan SQLite picks for a query path of a projection, see common.ExplainQueryPlan
+type QueryPlan struct {
+	Name  string   `json:"name"`
+	Query string   `json:"query"`
+	Steps []string `json:"steps"`
 }
 
-func mustParseTime(value string) time.Time {
-	t, err := time.Parse(time.RFC3339, value)
-	if err != nil {
-		panic(err)
+// explainedPairFilters are the filters the pairs are commonly found by, keyed by the name of their query path
+func explainedPairFilters() map[string]PairFilter {
+	status, network := domain.PairStatusWaiting, domain.NetworkMainnet
+	shareValue, investingPeriod, unit := 100, 1, domain.PeriodUnitWeek
+	address := domain.Address("thor1explain")
+
+	return map[string]PairFilter{
+		"pairs_by_status":      {Status: &status},
+		"pairs_by_participant": {ParticipantAddresses: []domain.Address{address}},
+		"pairs_by_assets":      {Assets: []domain.Asset{domain.RuneAsset, "BTC.BTC"}},
+		"pairs_by_plan":        {PlanId: "plan"},
+		"pairs_by_created_at":  {CreatedAfter: time.Unix(0, 0), CreatedBefore: time.Unix(1, 0)},
+		"pairs_matching": {
+			Network:             &network,
+			Status:              &status,
+			Assets:              []domain.Asset{domain.RuneAsset, "BTC.BTC"},
+			ShareValue:          &shareValue,
+			InvestingPeriod:     &investingPeriod,
+			InvestingPeriodUnit: &unit,
+		},
 	}
-	return t
 }
 
-func nullStringToPointer(ns sql.NullString) *string {
-	if ns.Valid {
-		return &ns.String
+// ExplainQueries returns the plans SQLite picks for the common query paths of the live pairs, ordered by name,
+// so the paths still scanning the whole table are told apart from the ones using the indexes
+func (pq *PairsQuery) ExplainQueries(ctx context.Context) ([]QueryPlan, error) {
+	filters := explainedPairFilters()
+	names := make([]string, 0, len(filters))
+	for name := range filters {
+		names = append(names, name)
 	}
-	return nil
-}
+	slices.Sort(names)
 
-var ErrPairNotFound = common.NewError("pair_not_found", "pair not found")
+	plans := make([]QueryPlan, 0, len(names))
+	for _, name := range names {
+		query, args := newPairsFilterBuilder(filters[name]).Build()
+		steps, err := common.ExplainQueryPlan(ctx, pq.Reader(), query, args...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to explain %s: %w", name, err)
+		}
+		plans = append(plans, QueryPlan{Name: name, Query: query, Steps: steps})
+	}
 
-// Get gets a pair by id
-func (pq *PairsQuery) Get(ctx context.Context, id string) (*Pair, error) {
-	row := pq.QueryRowContext(
-		ctx,
-		`select
-			id,
-			status,
-			assets,
-			participant_addresses,
-			share_value,
-			investing_period,
-			wallet_security,
-			profit_sharing_strategy,
-			loss_protection,
-			json(wallet),
-			json(assurances),
-			json(deposits),
-			json(withdraw_tx),
-			json(lp),
-			deadline,
-			withdrawn_tx,
-			created_at,
-			updated_at
-		from pairs_query where id = ?;`,
-		id,
-	)
+	return plans, nil
+}
 
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// scanPair scans the pair of the row of the table, it returns a CorruptRowError when a column can't be decoded
+func (pq *PairsQuery) scanPair(row scanner, table string) (*Pair, error) {
 	var (
+		id                    string
 		status                string
-		assets                string
-		participantAddresses  string
+		assets                []byte
+		participantAddresses  []byte
 		shareValue            int
 		investingPeriod       int
 		walletSecurity        string
@@ -540,13 +1334,29 @@ func (pq *PairsQuery) Get(ctx context.Context, id string) (*Pair, error) {
 		lossProtection        float64
 		wallet                []byte
 		assurances            []byte
+		confirmations         []byte
 		deposits              []byte
+		depositAmounts        []byte
 		withdrawTx            []byte
 		lp                    []byte
 		deadline              sql.NullString
 		withdrawnTx           sql.NullString
 		createdAt             string
 		updatedAt             string
+		network               string
+		periodUnit            string
+		graceDays             int
+		planId                string
+		version               int
+		refund                []byte
+		earlyWithdrawal       []byte
+		pendingExtension      []byte
+		settlement            []byte
+		txs                   []byte
+		planType              string
+		savers                []byte
+		statusEnteredAt       sql.NullString
+		statusDurations       []byte
 	)
 	if err := row.Scan(
 		&id,
@@ -560,13 +1370,29 @@ func (pq *PairsQuery) Get(ctx context.Context, id string) (*Pair, error) {
 		&lossProtection,
 		&wallet,
 		&assurances,
+		&confirmations,
 		&deposits,
+		&depositAmounts,
 		&withdrawTx,
 		&lp,
 		&deadline,
 		&withdrawnTx,
 		&createdAt,
 		&updatedAt,
+		&network,
+		&periodUnit,
+		&graceDays,
+		&planId,
+		&version,
+		&refund,
+		&earlyWithdrawal,
+		&pendingExtension,
+		&settlement,
+		&txs,
+		&planType,
+		&savers,
+		&statusEnteredAt,
+		&statusDurations,
 	); err != nil {
 		if err == sql.ErrNoRows {
 			return nil, ErrPairNotFound
@@ -574,25 +1400,182 @@ func (pq *PairsQuery) Get(ctx context.Context, id string) (*Pair, error) {
 
 		return nil, fmt.Errorf("failed to scan pair: %w", err)
 	}
-	p := Pair{
-		Id:                    id,
-		Status:                domain.PairStatus(status),
-		Assets:                stringsToAssets(strings.Split(assets, ",")),
-		ParticipantAddresses:  stringsToAddresses(strings.Split(participantAddresses, ",")),
-		ShareValue:            shareValue,
-		InvestingPeriod:       investingPeriod,
-		WalletSecurity:        domain.MultiSigWalletSecurity(walletSecurity),
-		ProfitSharingStrategy: domain.ProfitSharingStrategy(profitSharingStrategy),
-		LossProtection:        lossProtection,
-		Wallet:                mustUnmarshalToPointer[domain.MultisigWallet](wallet),
-		Assurances:            mustUnmarshalToType[map[domain.Asset][]domain.SignedTx](assurances),
-		Deposits:              mustUnmarshalToType[map[domain.Asset]domain.TxHash](deposits),
-		WithdrawTx:            mustUnmarshalToPointer[domain.SignedTx](withdrawTx),
-		LP:                    mustUnmarshalToType[map[domain.Asset]domain.TxHash](lp),
-		Deadline:              nullStringToTime(deadline),
-		WithdrawnTx:           (*domain.TxHash)(nullStringToPointer(withdrawnTx)),
-		CreatedAt:             mustParseTime(createdAt),
-		UpdatedAt:             mustParseTime(updatedAt),
-	}
-	return &p, nil
+
+	// The secrets are served decrypted, the ports only serve the pairs to their participants
+	d := newRowDecoder(table, id)
+	for _, column := range []struct {
+		name   string
+		doc    *[]byte
+		fields []string
+	}{
+		{"wallet", &wallet, walletEncryptedFields},
+		{"assurances", &assurances, assurancesEncryptedFields},
+		{"withdraw_tx", &withdrawTx, signedTxEncryptedFields},
+		{"refund", &refund, refundEncryptedFields},
+		{"savers", &savers, saversEncryptedFields},
+	} {
+		doc, err := pq.cipher.OpenJSON(*column.doc, column.fields)
+		if err != nil {
+			d.fail(column.name, fmt.Errorf("failed to decrypt: %w", err))
+			break
+		}
+		*column.doc = doc
+	}
+
+	pair := &Pair{
+		Id:                     id,
+		PlanId:                 planId,
+		PlanType:               domain.PlanType(planType),
+		Version:                version,
+		Refund:                 decodeColumn[*domain.RefundIssued](d, "refund", refund),
+		EarlyWithdrawal:        decodeColumn[*domain.EarlyWithdrawal](d, "early_withdrawal", earlyWithdrawal),
+		PendingExtension:       decodeColumn[*domain.ExtensionProposed](d, "pending_extension", pendingExtension),
+		Settlement:             decodeColumn[*domain.SettlementReport](d, "settlement", settlement),
+		Txs:                    decodeColumn[map[domain.TxHash]domain.TrackedTx](d, "txs", txs),
+		Savers:                 decodeColumn[map[domain.Asset]*domain.SaversPosition](d, "savers", savers),
+		Status:                 domain.PairStatus(status),
+		Assets:                 decodeColumn[[]domain.Asset](d, "assets", assets),
+		ParticipantAddresses:   decodeColumn[[]domain.Address](d, "participant_addresses", participantAddresses),
+		ShareValue:             shareValue,
+		InvestingPeriod:        investingPeriod,
+		InvestingPeriodUnit:    domain.PeriodUnit(periodUnit),
+		GracePeriodDays:        graceDays,
+		WalletSecurity:         domain.MultiSigWalletSecurity(walletSecurity),
+		ProfitSharingStrategy:  domain.ProfitSharingStrategy(profitSharingStrategy),
+		LossProtection:         lossProtection,
+		Wallet:                 decodeColumn[*domain.MultisigWallet](d, "wallet", wallet),
+		Assurances:             decodeColumn[map[domain.Asset][]domain.SignedTx](d, "assurances", assurances),
+		AssuranceConfirmations: decodeColumn[map[domain.Asset]string](d, "assurance_confirmations", confirmations),
+		Deposits:               decodeColumn[map[domain.Asset]domain.TxHash](d, "deposits", deposits),
+		DepositAmounts:         decodeColumn[map[domain.Asset]domain.TokenAmount](d, "deposit_amounts", depositAmounts),
+		WithdrawTx:             decodeColumn[*domain.SignedTx](d, "withdraw_tx", withdrawTx),
+		LP:                     decodeColumn[map[domain.Asset]domain.TxHash](d, "lp", lp),
+		Deadline:               d.nullTime("deadline", deadline),
+		WithdrawnTx:            (*domain.TxHash)(nullStringToPointer(withdrawnTx)),
+		CreatedAt:              d.time("created_at", createdAt),
+		UpdatedAt:              d.time("updated_at", updatedAt),
+		Network:                domain.Network(network),
+		StatusDurations:        decodeColumn[map[domain.PairStatus]int64](d, "status_durations", statusDurations),
+		StatusEnteredAt:        d.nullTime("status_entered_at", statusEnteredAt),
+	}
+	if err := d.Err(); err != nil {
+		return nil, err
+	}
+	pair.derive()
+
+	return pair, nil
+}
+
+// WithDeadlineBefore returns the pairs providing liquidity or in Savers vaults whose deadline is before the given time
+func (pq *PairsQuery) WithDeadlineBefore(ctx context.Context, before time.Time) ([]Pair, error) {
+	b := newPairsSelectBuilder(pairsTable)
+	b.Where(
+		b.In("status", string(domain.PairStatusLP), string(domain.PairStatusSavers)),
+		b.IsNotNull("deadline"),
+		fmt.Sprintf("datetime(deadline) <= datetime(%s)", b.Var(before.Format(time.RFC3339))),
+	)
+
+	return pq.query(ctx, pairsTable, b)
+}
+
+const defaultLatestPairsLimit = 20
+
+// Latest returns the last limit pairs created, the latest first, the archived pairs aren't included
+func (pq *PairsQuery) Latest(ctx context.Context, limit int) ([]Pair, error) {
+	if limit <= 0 {
+		limit = defaultLatestPairsLimit
+	}
+	b := newPairsSelectBuilder(pairsTable)
+	b.OrderBy("datetime(created_at)").Desc().Limit(limit)
+
+	return pq.query(ctx, pairsTable, b)
+}
+
+// Tracking returns the pairs having transactions still tracked on chain, see domain.TrackedTx.Tracking
+func (pq *PairsQuery) Tracking(ctx context.Context) ([]Pair, error) {
+	b := newPairsSelectBuilder(pairsTable)
+	b.Where(fmt.Sprintf(`exists (select 1 from json_each(txs) where
+		json_extract(value, '$.status') in ('%s', '%s')
+		or (json_extract(value, '$.status') = '%s' and datetime(json_extract(value, '$.updated_at')) > datetime(%s)))`,
+		domain.TxStatusPending, domain.TxStatusReorged, domain.TxStatusConfirmed,
+		b.Var(time.Now().Add(-domain.TxFinalityWindow).UTC().Format(time.RFC3339)),
+	))
+
+	return pq.query(ctx, pairsTable, b)
+}
+
+// Unsettled returns the withdrawn pairs whose withdrawal isn't settled yet
+func (pq *PairsQuery) Unsettled(ctx context.Context) ([]Pair, error) {
+	b := newPairsSelectBuilder(pairsTable)
+	b.Where(
+		b.Equal("status", string(domain.PairStatusWithdrawn)),
+		b.IsNull("settlement"),
+	)
+
+	return pq.query(ctx, pairsTable, b)
+}
+
+func nullStringToPointer(ns sql.NullString) *string {
+	if ns.Valid {
+		return &ns.String
+	}
+	return nil
+}
+
+var ErrPairNotFound = common.NewError("pair_not_found", "pair not found")
+
+// Get gets a pair by id
+func (pq *PairsQuery) Get(ctx context.Context, id string) (*Pair, error) {
+	return common.Cached(pq.cache, "pair:"+id, func() (*Pair, error) {
+		return pq.get(ctx, id)
+	})
+}
+
+// WaitForVersion waits until the version of the pair is greater than since and returns its state, or until the context is done.
+// When the context is done first, the pair is returned as is along with the error of the context.
+// A pair not projected yet is waited for as well, e.g. right after it's created.
+func (pq *PairsQuery) WaitForVersion(ctx context.Context, id string, since int) (*Pair, error) {
+	for {
+		changed := pq.changes.Watch(id)
+		pair, err := pq.Get(ctx, id)
+		if err != nil && err != ErrPairNotFound {
+			return nil, err
+		}
+		if err == nil && pair.Version > since {
+			return pair, nil
+		}
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return pair, ctx.Err()
+		}
+	}
+}
+
+// WatchParticipant returns a channel closed once the changes of any pair the address participates in are committed.
+// The channel is to be obtained before the pairs are read, so no change between the read and the wait is missed.
+func (pq *PairsQuery) WatchParticipant(address domain.Address) <-chan struct{} {
+	return pq.changes.Watch(participantChangesKey(address))
+}
+
+func (pq *PairsQuery) get(ctx context.Context, id string) (*Pair, error) {
+	b := newPairsSelectBuilder(pairsTable)
+	b.Where(b.Equal("id", id))
+
+	query, args := b.Build()
+	p, err := pq.scanPair(pq.Reader().QueryRowContext(ctx, query, args...), pairsTable)
+	if err != ErrPairNotFound {
+		return p, err
+	}
+
+	b.From(archivedPairsTable)
+	query, args = b.Build()
+	p, err = pq.scanPair(pq.Reader().QueryRowContext(ctx, query, args...), archivedPairsTable)
+	if err != nil {
+		return nil, err
+	}
+	p.Archived = true
+
+	return p, nil
 }
@@ -0,0 +1,82 @@
+package queries
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/co-defi/api-server/domain"
+)
+
+// archivedPairStatuses are the terminal statuses of the pairs that are moved to the archive
+var archivedPairStatuses = []interface{}{
+	string(domain.PairStatusWithdrawn),
+	string(domain.PairStatusInvalid),
+	string(domain.PairStatusRefunded),
+}
+
+// Archive moves the pairs that reached a terminal status before the given time from pairs_query to the archive
+// and returns them. Archived pairs are still reachable through Get and through Find on demand.
+// Terminal pairs only receive further events when an operator forces their status, which restores them first.
+func (pq *PairsQuery) Archive(ctx context.Context, before time.Time) ([]Pair, error) {
+	b := newPairsSelectBuilder(pairsTable)
+	b.Where(
+		b.In("status", archivedPairStatuses...),
+		fmt.Sprintf("datetime(updated_at) <= datetime(%s)", b.Var(before.Format(time.RFC3339))),
+	)
+
+	pairs, err := pq.query(ctx, pairsTable, b)
+	if err != nil {
+		return nil, err
+	}
+	if len(pairs) == 0 {
+		return pairs, nil
+	}
+
+	ids := make([]interface{}, len(pairs))
+	for i, p := range pairs {
+		ids[i] = p.Id
+		pairs[i].Archived = true
+	}
+
+	// The archive goes through the write pool, so it is serialized with the projection batches
+	tx, err := pq.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", ")
+	archivedAt := time.Now().Format(time.RFC3339)
+	if _, err := tx.ExecContext(ctx, `insert into pairs_query_archive select *, ? from pairs_query where id in (`+placeholders+`);`,
+		append([]interface{}{archivedAt}, ids...)...); err != nil {
+		return nil, fmt.Errorf("failed to copy pairs to the archive: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `delete from pairs_query where id in (`+placeholders+`);`, ids...); err != nil {
+		return nil, fmt.Errorf("failed to delete archived pairs: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	pq.cache.InvalidatePrefix("")
+
+	return pairs, nil
+}
+
+// restoreArchivedPair moves the pair back from the archive to pairs_query, it's a no-op for the pairs that aren't archived
+func restoreArchivedPair(tx executor, id string) error {
+	columns := make([]string, 0)
+	for _, column := range strings.Split(pairsTableColumns, ",") {
+		columns = append(columns, strings.Fields(column)[0])
+	}
+
+	if _, err := tx.Exec(`insert into pairs_query select `+strings.Join(columns, ", ")+` from pairs_query_archive where id = ?;`, id); err != nil {
+		return fmt.Errorf("failed to copy pair from the archive: %w", err)
+	}
+	if _, err := tx.Exec(`delete from pairs_query_archive where id = ?;`, id); err != nil {
+		return fmt.Errorf("failed to delete pair from the archive: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package queries
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/co-defi/api-server/common"
+)
+
+// pairsEncryptedColumns holds the paths of the encrypted fields of the pairs by column
+var pairsEncryptedColumns = []struct {
+	name   string
+	fields []string
+}{
+	{"wallet", walletEncryptedFields},
+	{"assurances", assurancesEncryptedFields},
+	{"withdraw_tx", signedTxEncryptedFields},
+	{"refund", refundEncryptedFields},
+	{"savers", saversEncryptedFields},
+}
+
+// RewrapPairKeys wraps the data keys of the encrypted fields of the pairs sealed under a previous master key of the cipher
+// with its current one, see common.Cipher.Rewrap. It goes through up to limit pairs after the given id in id order, either live or archived,
+// and returns the id of the last one, empty once there are no more, and the number of pairs whose fields were wrapped again.
+// It runs in the transaction of the caller, so the pairs can't be changed by the projection in between.
+func RewrapPairKeys(ctx context.Context, tx *sql.Tx, cipher *common.Cipher, archived bool, after string, limit int) (string, int, error) {
+	table := pairsTable
+	if archived {
+		table = archivedPairsTable
+	}
+
+	selected := make([]string, len(pairsEncryptedColumns))
+	for i, column := range pairsEncryptedColumns {
+		selected[i] = "json(" + column.name + ")"
+	}
+	rows, err := tx.QueryContext(ctx, `select id, `+strings.Join(selected, ", ")+`
+		from `+table+` where id > ? order by id limit ?;`, after, limit)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to query pairs: %w", err)
+	}
+
+	type pairColumns struct {
+		id      string
+		columns [][]byte
+	}
+	pairs := make([]pairColumns, 0, limit)
+	for rows.Next() {
+		p := pairColumns{columns: make([][]byte, len(pairsEncryptedColumns))}
+		dest := []any{&p.id}
+		for i := range p.columns {
+			dest = append(dest, &p.columns[i])
+		}
+		if err := rows.Scan(dest...); err != nil {
+			rows.Close()
+			return "", 0, fmt.Errorf("failed to scan pair: %w", err)
+		}
+		pairs = append(pairs, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return "", 0, err
+	}
+
+	last, rewrapped := "", 0
+	for _, p := range pairs {
+		last = p.id
+		changed := false
+		for i, column := range pairsEncryptedColumns {
+			doc, ok, err := cipher.RewrapJSON(p.columns[i], column.fields)
+			if err != nil {
+				return "", 0, fmt.Errorf("failed to rewrap %s of pair %s: %w", column.name, p.id, err)
+			}
+			if !ok {
+				continue
+			}
+			if _, err := tx.ExecContext(ctx, `update `+table+` set `+column.name+` = jsonb(?) where id = ?;`, doc, p.id); err != nil {
+				return "", 0, fmt.Errorf("failed to update %s of pair %s: %w", column.name, p.id, err)
+			}
+			changed = true
+		}
+		if changed {
+			rewrapped++
+		}
+	}
+
+	return last, rewrapped, nil
+}
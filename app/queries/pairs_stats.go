@@ -0,0 +1,417 @@
+package queries
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/co-defi/api-server/common"
+	"github.com/co-defi/api-server/domain"
+	"github.com/hallgren/eventsourcing"
+)
+
+var _ common.Projection = (*PairsStatsQuery)(nil)
+
+// Granularity is the size of the buckets of a timeseries
+type Granularity string
+
+const (
+	GranularityDay   Granularity = "day"
+	GranularityWeek  Granularity = "week"
+	GranularityMonth Granularity = "month"
+)
+
+// statsDayLayout is the layout of the days the pairs are rolled up by, in UTC
+const statsDayLayout = "2006-01-02"
+
+// Columns of the daily rollups counting the pairs
+const (
+	pairsStatsCreated   = "created"
+	pairsStatsMatched   = "matched"
+	pairsStatsCompleted = "completed"
+	pairsStatsExpired   = "expired"
+)
+
+// PairsStatsQuery is a query that rolls the events of the pairs up by day, so the dashboards chart the history of the pairs
+// without scanning the pairs: the pairs created, matched, completed and expired and the volume deposited by asset
+type PairsStatsQuery struct {
+	*common.BaseProjection
+	oracle PriceOracle
+}
+
+// pairsStatsSchemaVersion is bumped whenever the columns of the pairs stats tables change, so they are rebuilt
+const pairsStatsSchemaVersion = 1
+
+// NewPairsStatsQuery creates a new PairsStatsQuery, the volume is valued with the oracle when it's not nil
+func NewPairsStatsQuery(db *common.DB, store common.Store, oracle PriceOracle) (*PairsStatsQuery, error) {
+	bp, err := common.NewBaseProjection(db, store, "pairs_stats", pairsStatsSchemaVersion, "pairs_stats_volume", "pairs_stats_pairs")
+	if err != nil {
+		return nil, err
+	}
+
+	psq := PairsStatsQuery{BaseProjection: bp, oracle: oracle}
+	if err := psq.createTables(); err != nil {
+		return nil, fmt.Errorf("failed to create pairs_stats tables: %w", err)
+	}
+
+	return &psq, nil
+}
+
+func (psq *PairsStatsQuery) createTables() error {
+	_, err := psq.Exec(`create table if not exists pairs_stats (
+		day TEXT,
+		network TEXT,
+		created INTEGER DEFAULT 0,
+		matched INTEGER DEFAULT 0,
+		completed INTEGER DEFAULT 0,
+		expired INTEGER DEFAULT 0,
+		PRIMARY KEY (day, network)
+	);
+	create table if not exists pairs_stats_volume (
+		day TEXT,
+		network TEXT,
+		asset TEXT,
+		amount TEXT,
+		decimals INTEGER,
+		PRIMARY KEY (day, network, asset)
+	);
+	create table if not exists pairs_stats_pairs (
+		pair_id VARCHAR PRIMARY KEY,
+		network TEXT,
+		matched_day TEXT,
+		completed INTEGER DEFAULT 0,
+		expired INTEGER DEFAULT 0
+	);`)
+	return err
+}
+
+// Callback implements the common.Projection.Callback
+func (psq *PairsStatsQuery) Callback(event eventsourcing.Event) error {
+	return psq.Apply(event, func(tx *sql.Tx) error {
+		day := event.Timestamp().UTC().Format(statsDayLayout)
+
+		switch e := event.Data().(type) {
+		case *domain.PairCreated:
+			network := domain.NetworkOrDefault(e.Network)
+			if _, err := tx.Exec(`insert into pairs_stats_pairs (pair_id, network) values (?, ?) on conflict do nothing;`,
+				event.AggregateID(), network); err != nil {
+				return fmt.Errorf("failed to insert pairs stats pair: %w", err)
+			}
+			if err := bumpPairsStats(tx, day, string(network), pairsStatsCreated, 1); err != nil {
+				return fmt.Errorf("failed to count created pair: %w", err)
+			}
+		case *domain.PairMatched:
+			network, _, err := pairsStatsPair(tx, event.AggregateID())
+			if err != nil {
+				return err
+			}
+			if _, err := tx.Exec(`update pairs_stats_pairs set matched_day = ? where pair_id = ?;`, day, event.AggregateID()); err != nil {
+				return fmt.Errorf("failed to update pair match day: %w", err)
+			}
+			if err := bumpPairsStats(tx, day, network, pairsStatsMatched, 1); err != nil {
+				return fmt.Errorf("failed to count matched pair: %w", err)
+			}
+		case *domain.MatchReverted:
+			// The reverted matches are uncounted from the day they were matched on
+			network, matchedDay, err := pairsStatsPair(tx, event.AggregateID())
+			if err != nil || matchedDay == "" {
+				return err
+			}
+			if _, err := tx.Exec(`update pairs_stats_pairs set matched_day = null where pair_id = ?;`, event.AggregateID()); err != nil {
+				return fmt.Errorf("failed to reset pair match day: %w", err)
+			}
+			if err := bumpPairsStats(tx, matchedDay, network, pairsStatsMatched, -1); err != nil {
+				return fmt.Errorf("failed to uncount reverted match: %w", err)
+			}
+		case *domain.PairStatusChanged:
+			if err := countPairsStatsStatus(tx, event.AggregateID(), day, e.Status); err != nil {
+				return fmt.Errorf("failed to count pair status: %w", err)
+			}
+		case *domain.PairStatusForced:
+			if err := countPairsStatsStatus(tx, event.AggregateID(), day, e.Status); err != nil {
+				return fmt.Errorf("failed to count pair status: %w", err)
+			}
+		case *domain.AssetDeposited:
+			// Deposits recorded before their amounts were tracked can't be valued
+			if e.Amount == "" {
+				return nil
+			}
+			network, _, err := pairsStatsPair(tx, event.AggregateID())
+			if err != nil {
+				return err
+			}
+			if err := addPairsStatsVolume(tx, day, network, e.Asset, e.Amount, e.Decimals); err != nil {
+				return fmt.Errorf("failed to add deposit volume: %w", err)
+			}
+		}
+
+		return nil
+	})
+}
+
+// bumpPairsStats adds delta to the column of the rollup of the day, the column is one of the pairsStats constants
+func bumpPairsStats(tx executor, day, network, column string, delta int) error {
+	_, err := tx.Exec(fmt.Sprintf(`insert into pairs_stats (day, network, %[1]s) values (?, ?, ?)
+		on conflict (day, network) do update set %[1]s = %[1]s + excluded.%[1]s;`, column),
+		day, network, delta)
+	return err
+}
+
+// pairsStatsPair returns the network of the pair and the day it was matched on, if matched
+func pairsStatsPair(tx *sql.Tx, pairId string) (string, string, error) {
+	var (
+		network    string
+		matchedDay sql.NullString
+	)
+	if err := tx.QueryRow(`select network, matched_day from pairs_stats_pairs where pair_id = ?;`, pairId).Scan(&network, &matchedDay); err != nil {
+		return "", "", fmt.Errorf("failed to get pairs stats pair: %w", err)
+	}
+
+	return network, matchedDay.String, nil
+}
+
+// countPairsStatsStatus counts the pair as completed the first time it's withdrawn and as expired the first time it's invalidated
+func countPairsStatsStatus(tx *sql.Tx, pairId, day string, status domain.PairStatus) error {
+	var column string
+	switch status {
+	case domain.PairStatusWithdrawn:
+		column = pairsStatsCompleted
+	case domain.PairStatusInvalid:
+		column = pairsStatsExpired
+	default:
+		return nil
+	}
+
+	res, err := tx.Exec(fmt.Sprintf(`update pairs_stats_pairs set %[1]s = 1 where pair_id = ? and %[1]s = 0;`, column), pairId)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil
+	}
+
+	network, _, err := pairsStatsPair(tx, pairId)
+	if err != nil {
+		return err
+	}
+
+	return bumpPairsStats(tx, day, network, column, 1)
+}
+
+// addPairsStatsVolume adds the amount to the volume of the asset of the day, summed in base units since they don't fit the SQLite integers
+func addPairsStatsVolume(tx *sql.Tx, day, network string, asset domain.Asset, amount string, decimals int) error {
+	value, ok := new(big.Int).SetString(amount, 10)
+	if !ok {
+		return nil
+	}
+
+	var total string
+	err := tx.QueryRow(`select amount from pairs_stats_volume where day = ? and network = ? and asset = ?;`, day, network, asset).Scan(&total)
+	switch {
+	case err == sql.ErrNoRows:
+	case err != nil:
+		return err
+	default:
+		if sum, ok := new(big.Int).SetString(total, 10); ok {
+			value.Add(value, sum)
+		}
+	}
+
+	_, err = tx.Exec(`insert into pairs_stats_volume (day, network, asset, amount, decimals) values (?, ?, ?, ?, ?)
+		on conflict (day, network, asset) do update set amount = excluded.amount, decimals = excluded.decimals;`,
+		day, network, asset, value.String(), decimals)
+	return err
+}
+
+// Timeseries is the history of the pairs of a network bucketed by day, week or month
+type Timeseries struct {
+	Network     domain.Network    `json:"network"`
+	Granularity Granularity       `json:"granularity"`
+	Points      []TimeseriesPoint `json:"points"`
+}
+
+// TimeseriesPoint is the rollup of the pairs of a bucket, starting on Start. The volume is valued at the current prices of the assets,
+// VolumeUSD is only set when every asset could be priced.
+type TimeseriesPoint struct {
+	Start     string       `json:"start"`
+	Created   int          `json:"created"`
+	Matched   int          `json:"matched"`
+	Completed int          `json:"completed"`
+	Expired   int          `json:"expired"`
+	Volume    []AssetValue `json:"volume"`
+	VolumeUSD *float64     `json:"volume_usd,omitempty"`
+}
+
+// Timeseries returns the rollups of the pairs of the network from the bucket of from to the bucket of to, every bucket is included even when empty
+func (psq *PairsStatsQuery) Timeseries(ctx context.Context, network domain.Network, from, to time.Time, granularity Granularity) (*Timeseries, error) {
+	ts := Timeseries{Network: network, Granularity: granularity, Points: []TimeseriesPoint{}}
+
+	points := make(map[string]*TimeseriesPoint)
+	for start := bucketStart(from, granularity); !start.After(to); start = nextBucket(start, granularity) {
+		ts.Points = append(ts.Points, TimeseriesPoint{Start: start.Format(statsDayLayout)})
+	}
+	for i := range ts.Points {
+		points[ts.Points[i].Start] = &ts.Points[i]
+	}
+
+	first, last := bucketStart(from, granularity).Format(statsDayLayout), to.UTC().Format(statsDayLayout)
+	if err := psq.countsInto(ctx, network, first, last, granularity, points); err != nil {
+		return nil, err
+	}
+	if err := psq.volumesInto(ctx, network, first, last, granularity, points); err != nil {
+		return nil, err
+	}
+
+	return &ts, nil
+}
+
+func (psq *PairsStatsQuery) countsInto(ctx context.Context, network domain.Network, first, last string, granularity Granularity, points map[string]*TimeseriesPoint) error {
+	rows, err := psq.Reader().QueryContext(ctx, `select day, created, matched, completed, expired from pairs_stats
+		where network = ? and day >= ? and day <= ?;`,
+		network, first, last,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to query pairs stats: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			day                                  string
+			created, matched, completed, expired int
+		)
+		if err := rows.Scan(&day, &created, &matched, &completed, &expired); err != nil {
+			return fmt.Errorf("failed to scan pairs stats: %w", err)
+		}
+		p, ok := points[bucketOfDay(day, granularity)]
+		if !ok {
+			continue
+		}
+		p.Created += created
+		p.Matched += matched
+		p.Completed += completed
+		p.Expired += expired
+	}
+
+	return rows.Err()
+}
+
+func (psq *PairsStatsQuery) volumesInto(ctx context.Context, network domain.Network, first, last string, granularity Granularity, points map[string]*TimeseriesPoint) error {
+	rows, err := psq.Reader().QueryContext(ctx, `select day, asset, amount, decimals from pairs_stats_volume
+		where network = ? and day >= ? and day <= ?;`,
+		network, first, last,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to query pairs stats volume: %w", err)
+	}
+	defer rows.Close()
+
+	totals := make(map[string]map[domain.Asset]*big.Int)
+	decimals := make(map[domain.Asset]int)
+	for rows.Next() {
+		var (
+			day    string
+			asset  domain.Asset
+			amount string
+			dec    int
+		)
+		if err := rows.Scan(&day, &asset, &amount, &dec); err != nil {
+			return fmt.Errorf("failed to scan pairs stats volume: %w", err)
+		}
+		value, ok := new(big.Int).SetString(amount, 10)
+		if !ok {
+			continue
+		}
+		bucket := bucketOfDay(day, granularity)
+		if totals[bucket] == nil {
+			totals[bucket] = make(map[domain.Asset]*big.Int)
+		}
+		if totals[bucket][asset] == nil {
+			totals[bucket][asset] = new(big.Int)
+		}
+		totals[bucket][asset].Add(totals[bucket][asset], value)
+		decimals[asset] = dec
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	// The assets are priced once for the whole timeseries
+	prices := make(map[domain.Asset]float64, len(decimals))
+	if psq.oracle != nil {
+		for asset := range decimals {
+			if price, err := psq.oracle.PriceUSD(ctx, asset); err == nil {
+				prices[asset] = price
+			}
+		}
+	}
+
+	for bucket, p := range points {
+		p.Volume = make([]AssetValue, 0, len(totals[bucket]))
+		total, priced := 0.0, psq.oracle != nil
+		for asset, amount := range totals[bucket] {
+			units := toUnits(amount, decimals[asset])
+			value := AssetValue{Asset: asset, Amount: units.Text('f', -1)}
+			if price, ok := prices[asset]; ok {
+				f, _ := units.Float64()
+				usd := f * price
+				value.ValueUSD = &usd
+				total += usd
+			} else {
+				priced = false
+			}
+			p.Volume = append(p.Volume, value)
+		}
+		sort.Slice(p.Volume, func(i, j int) bool {
+			return p.Volume[i].Asset < p.Volume[j].Asset
+		})
+		if priced {
+			p.VolumeUSD = &total
+		}
+	}
+
+	return nil
+}
+
+// toUnits converts an amount in base units to the display units of its asset, e.g. wei to ETH
+func toUnits(amount *big.Int, decimals int) *big.Float {
+	return new(big.Float).Quo(new(big.Float).SetInt(amount), new(big.Float).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimals)), nil)))
+}
+
+// bucketStart returns the first day of the bucket of t, the weeks start on Monday
+func bucketStart(t time.Time, granularity Granularity) time.Time {
+	t = t.UTC()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	switch granularity {
+	case GranularityWeek:
+		return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+	case GranularityMonth:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+
+	return day
+}
+
+func nextBucket(start time.Time, granularity Granularity) time.Time {
+	switch granularity {
+	case GranularityWeek:
+		return start.AddDate(0, 0, 7)
+	case GranularityMonth:
+		return start.AddDate(0, 1, 0)
+	}
+
+	return start.AddDate(0, 0, 1)
+}
+
+// bucketOfDay returns the first day of the bucket of a day of the rollups
+func bucketOfDay(day string, granularity Granularity) string {
+	t, err := time.Parse(statsDayLayout, day)
+	if err != nil {
+		return day
+	}
+
+	return bucketStart(t, granularity).Format(statsDayLayout)
+}
@@ -0,0 +1,217 @@
+package queries
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/co-defi/api-server/common"
+	"github.com/co-defi/api-server/domain"
+	"github.com/hallgren/eventsourcing"
+)
+
+var _ common.Projection = (*ParticipantsQuery)(nil)
+
+// ParticipantsQuery is a query that keeps track of the participants, their addresses and the pairs of their addresses
+type ParticipantsQuery struct {
+	*common.BaseProjection
+}
+
+// participantsSchemaVersion is bumped whenever the columns of the participants tables change, so they are rebuilt
+const participantsSchemaVersion = 1
+
+// NewParticipantsQuery creates a new ParticipantsQuery
+func NewParticipantsQuery(db *common.DB, store common.Store) (*ParticipantsQuery, error) {
+	bp, err := common.NewBaseProjection(db, store, "participants_query", participantsSchemaVersion, "participants_query_addresses", "participants_query_pairs")
+	if err != nil {
+		return nil, err
+	}
+
+	pq := ParticipantsQuery{bp}
+	if err := pq.createTables(); err != nil {
+		return nil, fmt.Errorf("failed to create participants_query tables: %w", err)
+	}
+
+	return &pq, nil
+}
+
+func (pq *ParticipantsQuery) createTables() error {
+	_, err := pq.Exec(`create table if not exists participants_query (
+		id VARCHAR PRIMARY KEY,
+		first_seen_at TEXT
+	);
+	create table if not exists participants_query_addresses (
+		address TEXT PRIMARY KEY,
+		participant_id VARCHAR,
+		chain TEXT,
+		linked_at TEXT,
+		blocked_reason TEXT
+	);
+	create index if not exists participants_query_addresses_participant on participants_query_addresses (participant_id);
+	create table if not exists participants_query_pairs (
+		pair_id VARCHAR,
+		address TEXT,
+		PRIMARY KEY (pair_id, address)
+	);
+	create index if not exists participants_query_pairs_address on participants_query_pairs (address);`)
+	return err
+}
+
+// Callback implements the common.Projection.Callback
+func (pq *ParticipantsQuery) Callback(event eventsourcing.Event) error {
+	return pq.Apply(event, func(tx *sql.Tx) error {
+		at := event.Timestamp().Format(time.RFC3339)
+
+		switch e := event.Data().(type) {
+		case *domain.ParticipantRegistered:
+			if _, err := tx.Exec(`insert into participants_query (id, first_seen_at) values (?, ?) on conflict do nothing;`,
+				event.AggregateID(), at); err != nil {
+				return fmt.Errorf("failed to insert participant: %w", err)
+			}
+			if err := linkParticipantAddress(tx, event.AggregateID(), e.Chain, e.Address, at); err != nil {
+				return fmt.Errorf("failed to insert participant address: %w", err)
+			}
+		case *domain.ParticipantAddressLinked:
+			if err := linkParticipantAddress(tx, event.AggregateID(), e.Chain, e.Address, at); err != nil {
+				return fmt.Errorf("failed to insert participant address: %w", err)
+			}
+		case *domain.ParticipantBlocked:
+			if _, err := tx.Exec(`update participants_query_addresses set blocked_reason = ? where address = ?;`, e.Reason, e.Address); err != nil {
+				return fmt.Errorf("failed to block participant address: %w", err)
+			}
+		case *domain.ParticipantUnblocked:
+			if _, err := tx.Exec(`update participants_query_addresses set blocked_reason = null where address = ?;`, e.Address); err != nil {
+				return fmt.Errorf("failed to unblock participant address: %w", err)
+			}
+		case *domain.PairCreated:
+			if err := insertParticipantPair(tx, event.AggregateID(), e.ParticipantAddress); err != nil {
+				return fmt.Errorf("failed to insert participant pair: %w", err)
+			}
+		case *domain.PairMatched:
+			if err := insertParticipantPair(tx, event.AggregateID(), e.ParticipantAddress); err != nil {
+				return fmt.Errorf("failed to insert participant pair: %w", err)
+			}
+		case *domain.MatchReverted:
+			if _, err := tx.Exec(`delete from participants_query_pairs where pair_id = ? and address = ?;`, event.AggregateID(), e.ParticipantAddress); err != nil {
+				return fmt.Errorf("failed to delete participant pair: %w", err)
+			}
+		}
+
+		return nil
+	})
+}
+
+func linkParticipantAddress(tx executor, participantId, chain string, address domain.Address, at string) error {
+	_, err := tx.Exec(`insert into participants_query_addresses (address, participant_id, chain, linked_at) values (?, ?, ?, ?)
+		on conflict do nothing;`, address, participantId, chain, at)
+	return err
+}
+
+// insertParticipantPair links the pair to the address, the pairs reach their participant through the addresses
+// so the pairs created before their participant registered are linked too
+func insertParticipantPair(tx executor, pairId string, address domain.Address) error {
+	_, err := tx.Exec(`insert into participants_query_pairs (pair_id, address) values (?, ?) on conflict do nothing;`, pairId, address)
+	return err
+}
+
+// Participant is a participant along with their addresses and the pairs of their addresses
+type Participant struct {
+	Id          string                      `json:"id"`
+	FirstSeenAt time.Time                   `json:"first_seen_at"`
+	Addresses   []domain.ParticipantAddress `json:"addresses"`
+	Blocked     bool                        `json:"blocked"`
+	// BlockedAddresses are the reasons the addresses of the participant are blocked for
+	BlockedAddresses map[domain.Address]string `json:"blocked_addresses,omitempty"`
+	PairIds          []string                  `json:"pair_ids"`
+}
+
+var ErrParticipantNotFound = common.NewError("participant_not_found", "participant not found")
+
+// IdOf returns the id of the participant the address belongs to, empty when the address isn't registered.
+// The EVM addresses are compared case-insensitively.
+func (pq *ParticipantsQuery) IdOf(ctx context.Context, address domain.Address) (string, error) {
+	var id string
+	err := pq.Reader().QueryRowContext(ctx, `select participant_id from participants_query_addresses where lower(address) = lower(?);`, address).Scan(&id)
+	if err != nil && err != sql.ErrNoRows {
+		return "", fmt.Errorf("failed to query participant of address: %w", err)
+	}
+
+	return id, nil
+}
+
+// GetByAddress returns the participant the address belongs to
+func (pq *ParticipantsQuery) GetByAddress(ctx context.Context, address domain.Address) (*Participant, error) {
+	id, err := pq.IdOf(ctx, address)
+	if err != nil {
+		return nil, err
+	}
+	if id == "" {
+		return nil, ErrParticipantNotFound
+	}
+
+	return pq.Get(ctx, id)
+}
+
+// Get returns the participant
+func (pq *ParticipantsQuery) Get(ctx context.Context, id string) (*Participant, error) {
+	var firstSeenAt string
+	if err := pq.Reader().QueryRowContext(ctx, `select first_seen_at from participants_query where id = ?;`, id).Scan(&firstSeenAt); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrParticipantNotFound
+		}
+		return nil, fmt.Errorf("failed to query participant: %w", err)
+	}
+	d := newRowDecoder("participants_query", id)
+	p := Participant{
+		Id:               id,
+		FirstSeenAt:      d.time("first_seen_at", firstSeenAt),
+		Addresses:        []domain.ParticipantAddress{},
+		BlockedAddresses: make(map[domain.Address]string),
+		PairIds:          []string{},
+	}
+	if err := d.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err := pq.Reader().QueryContext(ctx, `select address, chain, blocked_reason from participants_query_addresses
+		where participant_id = ? order by datetime(linked_at), address;`, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query participant addresses: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			a             domain.ParticipantAddress
+			blockedReason sql.NullString
+		)
+		if err := rows.Scan(&a.Address, &a.Chain, &blockedReason); err != nil {
+			return nil, fmt.Errorf("failed to scan participant address: %w", err)
+		}
+		p.Addresses = append(p.Addresses, a)
+		if blockedReason.Valid {
+			p.BlockedAddresses[a.Address] = blockedReason.String
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	p.Blocked = len(p.BlockedAddresses) > 0
+
+	pairs, err := pq.Reader().QueryContext(ctx, `select pp.pair_id from participants_query_pairs pp
+		join participants_query_addresses pa on pa.address = pp.address where pa.participant_id = ?
+		group by pp.pair_id order by min(pp.rowid);`, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query participant pairs: %w", err)
+	}
+	defer pairs.Close()
+	for pairs.Next() {
+		var pairId string
+		if err := pairs.Scan(&pairId); err != nil {
+			return nil, fmt.Errorf("failed to scan participant pair: %w", err)
+		}
+		p.PairIds = append(p.PairIds, pairId)
+	}
+
+	return &p, pairs.Err()
+}
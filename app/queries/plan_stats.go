@@ -0,0 +1,265 @@
+package queries
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/co-defi/api-server/common"
+	"github.com/co-defi/api-server/domain"
+	"github.com/hallgren/eventsourcing"
+)
+
+var _ common.Projection = (*PlanStatsQuery)(nil)
+
+// PlanStatsQuery is a query that aggregates how popular the plans are from the events of their pairs:
+// the pairs waiting for a counterparty, how long the pairs took to be matched and how many were completed
+type PlanStatsQuery struct {
+	*common.BaseProjection
+	cache *common.Cache
+}
+
+// planStatsSchemaVersion is bumped whenever the columns of the plan stats tables change, so they are rebuilt
+const planStatsSchemaVersion = 1
+
+// NewPlanStatsQuery creates a new PlanStatsQuery
+func NewPlanStatsQuery(db *common.DB, store common.Store) (*PlanStatsQuery, error) {
+	bp, err := common.NewBaseProjection(db, store, "plan_stats_query", planStatsSchemaVersion, "plan_stats_query_waiting", "plan_stats_query_pairs")
+	if err != nil {
+		return nil, err
+	}
+
+	psq := PlanStatsQuery{bp, common.NewCache()}
+	psq.OnReset(func() { psq.cache.InvalidatePrefix("") })
+	if err := psq.createTables(); err != nil {
+		return nil, fmt.Errorf("failed to create plan_stats_query tables: %w", err)
+	}
+
+	return &psq, nil
+}
+
+func (psq *PlanStatsQuery) createTables() error {
+	_, err := psq.Exec(`create table if not exists plan_stats_query (
+		plan_id VARCHAR PRIMARY KEY,
+		matched INTEGER,
+		match_seconds INTEGER,
+		completed INTEGER
+	);
+	create table if not exists plan_stats_query_waiting (
+		plan_id VARCHAR,
+		asset TEXT,
+		waiting INTEGER,
+		PRIMARY KEY (plan_id, asset)
+	);
+	create table if not exists plan_stats_query_pairs (
+		pair_id VARCHAR PRIMARY KEY,
+		plan_id VARCHAR,
+		asset TEXT,
+		created_at TEXT,
+		waiting INTEGER,
+		completed INTEGER
+	);`)
+	return err
+}
+
+// Callback implements the common.Projection.Callback
+func (psq *PlanStatsQuery) Callback(event eventsourcing.Event) error {
+	return psq.Apply(event, func(tx *sql.Tx) error {
+		switch e := event.Data().(type) {
+		case *domain.PairCreated:
+			// Pairs created before they were linked to their plans can't be counted
+			if e.PlanId == "" {
+				return nil
+			}
+			if err := insertPlanStatsPair(tx, event, e); err != nil {
+				return fmt.Errorf("failed to insert plan stats pair: %w", err)
+			}
+		case *domain.PairMatched:
+			if err := recordMatch(tx, event); err != nil {
+				return fmt.Errorf("failed to record pair match: %w", err)
+			}
+		case *domain.MatchReverted:
+			if err := resumeWaiting(tx, event.AggregateID()); err != nil {
+				return fmt.Errorf("failed to record reverted match: %w", err)
+			}
+		case *domain.PairStatusChanged:
+			if err := recordPlanStatsStatus(tx, event, e.Status); err != nil {
+				return fmt.Errorf("failed to record pair status: %w", err)
+			}
+		case *domain.PairStatusForced:
+			if err := recordPlanStatsStatus(tx, event, e.Status); err != nil {
+				return fmt.Errorf("failed to record pair status: %w", err)
+			}
+		default:
+			return nil
+		}
+
+		psq.AfterCommit(func() { psq.cache.InvalidatePrefix("all:") })
+		return nil
+	})
+}
+
+func insertPlanStatsPair(tx executor, event eventsourcing.Event, e *domain.PairCreated) error {
+	if _, err := tx.Exec(`insert into plan_stats_query_pairs (pair_id, plan_id, asset, created_at, waiting, completed)
+		values (?, ?, ?, ?, 1, 0) on conflict do nothing;`,
+		event.AggregateID(), e.PlanId, e.ParticipantAsset, event.Timestamp().Format(time.RFC3339)); err != nil {
+		return err
+	}
+
+	_, err := tx.Exec(`insert into plan_stats_query_waiting (plan_id, asset, waiting) values (?, ?, 1)
+		on conflict (plan_id, asset) do update set waiting = waiting + 1;`, e.PlanId, e.ParticipantAsset)
+	return err
+}
+
+// recordMatch counts the time the pair waited for its counterparty and takes it out of the waiting pairs
+func recordMatch(tx *sql.Tx, event eventsourcing.Event) error {
+	var planId, createdAt string
+	err := tx.QueryRow(`select plan_id, created_at from plan_stats_query_pairs where pair_id = ? and waiting = 1;`, event.AggregateID()).
+		Scan(&planId, &createdAt)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	created, _ := time.Parse(time.RFC3339, createdAt)
+	waited := max(int64(event.Timestamp().Sub(created).Seconds()), 0)
+	if _, err := tx.Exec(`insert into plan_stats_query (plan_id, matched, match_seconds, completed) values (?, 1, ?, 0)
+		on conflict (plan_id) do update set matched = matched + 1, match_seconds = match_seconds + excluded.match_seconds;`,
+		planId, waited); err != nil {
+		return err
+	}
+
+	return stopWaiting(tx, event.AggregateID())
+}
+
+// recordPlanStatsStatus takes the pairs leaving the waiting status without a match out of the waiting pairs,
+// and counts the pairs the first time they are withdrawn
+func recordPlanStatsStatus(tx executor, event eventsourcing.Event, status domain.PairStatus) error {
+	if status != domain.PairStatusWaiting {
+		if err := stopWaiting(tx, event.AggregateID()); err != nil {
+			return err
+		}
+	}
+	if status != domain.PairStatusWithdrawn {
+		return nil
+	}
+
+	res, err := tx.Exec(`update plan_stats_query_pairs set completed = 1 where pair_id = ? and completed = 0;`, event.AggregateID())
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil
+	}
+
+	_, err = tx.Exec(`insert into plan_stats_query (plan_id, matched, match_seconds, completed)
+		select plan_id, 0, 0, 1 from plan_stats_query_pairs where pair_id = ?
+		on conflict (plan_id) do update set completed = completed + 1;`, event.AggregateID())
+	return err
+}
+
+// stopWaiting takes the pair out of the waiting pairs of its plan, if it's still waiting
+func stopWaiting(tx executor, pairId string) error {
+	res, err := tx.Exec(`update plan_stats_query_pairs set waiting = 0 where pair_id = ? and waiting = 1;`, pairId)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil
+	}
+
+	_, err = tx.Exec(`update plan_stats_query_waiting set waiting = waiting - 1
+		where (plan_id, asset) = (select plan_id, asset from plan_stats_query_pairs where pair_id = ?);`, pairId)
+	return err
+}
+
+// resumeWaiting puts the pair back in the waiting pairs of its plan, its match stays counted
+func resumeWaiting(tx executor, pairId string) error {
+	res, err := tx.Exec(`update plan_stats_query_pairs set waiting = 1 where pair_id = ? and waiting = 0;`, pairId)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil
+	}
+
+	_, err = tx.Exec(`insert into plan_stats_query_waiting (plan_id, asset, waiting)
+		select plan_id, asset, 1 from plan_stats_query_pairs where pair_id = ?
+		on conflict (plan_id, asset) do update set waiting = waiting + 1;`, pairId)
+	return err
+}
+
+// PlanStats are the live numbers of a plan to choose it by
+type PlanStats struct {
+	// WaitingPairs are the pairs waiting for a counterparty by the asset of their creator
+	WaitingPairs map[domain.Asset]int `json:"waiting_pairs"`
+	// AverageMatchSeconds is the average time the matched pairs waited for their counterparty
+	AverageMatchSeconds float64 `json:"average_match_seconds"`
+	CompletedPairs      int     `json:"completed_pairs"`
+}
+
+// All returns the stats of the plans by plan id, the plans without any pair yet are missing
+func (psq *PlanStatsQuery) All(ctx context.Context) (map[string]PlanStats, error) {
+	return common.Cached(psq.cache, "all:", func() (map[string]PlanStats, error) {
+		return psq.all(ctx)
+	})
+}
+
+func (psq *PlanStatsQuery) all(ctx context.Context) (map[string]PlanStats, error) {
+	stats := make(map[string]PlanStats)
+	statsOf := func(planId string) PlanStats {
+		s, ok := stats[planId]
+		if !ok {
+			s = PlanStats{WaitingPairs: make(map[domain.Asset]int)}
+		}
+		return s
+	}
+
+	rows, err := psq.Reader().QueryContext(ctx, `select plan_id, matched, match_seconds, completed from plan_stats_query;`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query plan stats: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			planId                      string
+			matched, seconds, completed int64
+		)
+		if err := rows.Scan(&planId, &matched, &seconds, &completed); err != nil {
+			return nil, fmt.Errorf("failed to scan plan stats: %w", err)
+		}
+		s := statsOf(planId)
+		if matched > 0 {
+			s.AverageMatchSeconds = float64(seconds) / float64(matched)
+		}
+		s.CompletedPairs = int(completed)
+		stats[planId] = s
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	waiting, err := psq.Reader().QueryContext(ctx, `select plan_id, asset, waiting from plan_stats_query_waiting where waiting > 0;`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query waiting pairs: %w", err)
+	}
+	defer waiting.Close()
+	for waiting.Next() {
+		var (
+			planId string
+			asset  domain.Asset
+			count  int
+		)
+		if err := waiting.Scan(&planId, &asset, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan waiting pairs: %w", err)
+		}
+		s := statsOf(planId)
+		s.WaitingPairs[asset] = count
+		stats[planId] = s
+	}
+
+	return stats, waiting.Err()
+}
@@ -3,12 +3,16 @@ package queries
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/co-defi/api-server/common"
 	"github.com/co-defi/api-server/domain"
 	"github.com/hallgren/eventsourcing"
+	"github.com/huandu/go-sqlbuilder"
 )
 
 var _ common.Projection = (*PlansQuery)(nil)
@@ -16,16 +20,21 @@ var _ common.Projection = (*PlansQuery)(nil)
 // PlansQuery is a query that keeps track of all plans
 type PlansQuery struct {
 	*common.BaseProjection
+	cache *common.Cache
 }
 
+// plansSchemaVersion is bumped whenever the columns of the plans table change, so they are rebuilt
+const plansSchemaVersion = 2
+
 // NewPlansQuery creates a new PlansQuery
-func NewPlansQuery(db *sql.DB, store common.Store) (*PlansQuery, error) {
-	bp, err := common.NewBaseProjection(db, store, "plans_query")
+func NewPlansQuery(db *common.DB, store common.Store) (*PlansQuery, error) {
+	bp, err := common.NewBaseProjection(db, store, "plans_query", plansSchemaVersion)
 	if err != nil {
 		return nil, err
 	}
 
-	pq := PlansQuery{bp}
+	pq := PlansQuery{bp, common.NewCache()}
+	pq.OnReset(func() { pq.cache.InvalidatePrefix("") })
 	if err := pq.createTable(); err != nil {
 		return nil, fmt.Errorf("failed to create plans_query table: %w", err)
 	}
@@ -36,65 +45,250 @@ func NewPlansQuery(db *sql.DB, store common.Store) (*PlansQuery, error) {
 func (pq *PlansQuery) createTable() error {
 	_, err := pq.Exec(`create table if not exists plans_query (
 		id VARCHAR PRIMARY KEY,
-		assets TEXT,
+		assets BLOB,
 		security TEXT,
 		strategy TEXT,
 		quantum INTEGER,
 		loss_protection REAL,
-		investing_period INTEGER
+		investing_period INTEGER,
+		max_share_multiplier INTEGER,
+		network TEXT,
+		investing_period_unit TEXT,
+		grace_period_days INTEGER,
+		max_active_pairs INTEGER,
+		active_from TEXT,
+		active_until TEXT,
+		paused_at TEXT,
+		status_timeouts BLOB,
+		plan_type TEXT,
+		assurance_policies BLOB
 	);`)
 	return err
 }
 
 // Callback implements the common.Projection.Callback
 func (pq *PlansQuery) Callback(event eventsourcing.Event) error {
-	tx, err := pq.Begin()
-	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
-	}
-	defer tx.Rollback()
-
-	switch e := event.Data().(type) {
-	case *domain.PlanCreated:
-		if err := insertPlan(tx, event.AggregateID(), e); err != nil {
-			return fmt.Errorf("failed to insert plan: %w", err)
+	return pq.Apply(event, func(tx *sql.Tx) error {
+		switch e := event.Data().(type) {
+		case *domain.PlanCreated:
+			if err := insertPlan(tx, event.AggregateID(), e); err != nil {
+				return fmt.Errorf("failed to insert plan: %w", err)
+			}
+			pq.AfterCommit(func() { pq.cache.InvalidatePrefix("all:") })
+		case *domain.PlanPaused:
+			if err := updatePausedAt(tx, event.AggregateID(), event.Timestamp()); err != nil {
+				return fmt.Errorf("failed to pause plan: %w", err)
+			}
+			pq.invalidatePlan(event.AggregateID())
+		case *domain.PlanResumed:
+			if err := updatePausedAt(tx, event.AggregateID(), time.Time{}); err != nil {
+				return fmt.Errorf("failed to resume plan: %w", err)
+			}
+			pq.invalidatePlan(event.AggregateID())
+		case *domain.PlanStatusTimeoutsSet:
+			if _, err := tx.Exec(`update plans_query set status_timeouts = jsonb(?) where id = ?;`,
+				mustMarshalJson(statusTimeoutsOrEmpty(e.StatusTimeouts)), event.AggregateID()); err != nil {
+				return fmt.Errorf("failed to set plan status timeouts: %w", err)
+			}
+			pq.invalidatePlan(event.AggregateID())
+		case *domain.PlanAssurancePoliciesSet:
+			if _, err := tx.Exec(`update plans_query set assurance_policies = jsonb(?) where id = ?;`,
+				mustMarshalJson(assurancePoliciesOrEmpty(e.AssurancePolicies)), event.AggregateID()); err != nil {
+				return fmt.Errorf("failed to set plan assurance policies: %w", err)
+			}
+			pq.invalidatePlan(event.AggregateID())
 		}
-	}
 
-	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("failed to commit transaction: %w", err)
+		return nil
+	})
+}
+
+func insertPlan(tx executor, id string, e *domain.PlanCreated) error {
+	// Plans created before share multipliers were introduced allow a single quantum only
+	_, err := tx.Exec(`insert into plans_query (id, assets, security, strategy, quantum, loss_protection, investing_period, max_share_multiplier, network, investing_period_unit, grace_period_days, max_active_pairs, active_from, active_until, paused_at, status_timeouts, plan_type, assurance_policies) values (?, jsonb(?), ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, jsonb(?), ?, jsonb(?));`,
+		id, mustMarshalJson(e.Assets), e.Security, e.Strategy, e.Quantum, e.LossProtection, e.InvestingPeriod, max(e.MaxShareMultiplier, 1), domain.NetworkOrDefault(e.Network),
+		domain.PeriodUnitOrDefault(e.InvestingPeriodUnit), e.GracePeriodDays, e.MaxActivePairs, timeOrNull(e.ActiveFrom), timeOrNull(e.ActiveUntil), nil,
+		mustMarshalJson(statusTimeoutsOrEmpty(e.StatusTimeouts)), domain.PlanTypeOrDefault(e.Type), mustMarshalJson(assurancePoliciesOrEmpty(e.AssurancePolicies)))
+	return err
+}
+
+// statusTimeoutsOrEmpty returns the timeouts to be stored, the plans without timeouts store an empty object
+func statusTimeoutsOrEmpty(timeouts map[domain.PairStatus]domain.Duration) map[domain.PairStatus]domain.Duration {
+	if timeouts == nil {
+		return map[domain.PairStatus]domain.Duration{}
 	}
+	return timeouts
+}
 
-	return nil
+// assurancePoliciesOrEmpty returns the policies to be stored, the plans following the default policies store an empty object
+func assurancePoliciesOrEmpty(policies map[string]domain.AssurancePolicy) map[string]domain.AssurancePolicy {
+	if policies == nil {
+		return map[string]domain.AssurancePolicy{}
+	}
+	return policies
 }
 
-func insertPlan(tx executor, id string, e *domain.PlanCreated) error {
-	_, err := tx.Exec(`insert into plans_query (id, assets, security, strategy, quantum, loss_protection, investing_period) values (?, ?, ?, ?, ?, ?, ?);`,
-		id, strings.Join(assetsToStrings(e.Assets), ","), e.Security, e.Strategy, e.Quantum, e.LossProtection, e.InvestingPeriod)
+func updatePausedAt(tx executor, id string, pausedAt time.Time) error {
+	_, err := tx.Exec(`update plans_query set paused_at = ? where id = ?;`, timeOrNull(pausedAt), id)
 	return err
 }
 
-func assetsToStrings(assets []domain.Asset) []string {
-	strs := make([]string, len(assets))
-	for i, a := range assets {
-		strs[i] = string(a)
+// invalidatePlan drops the cached plan and the cached lists including it once the change is committed
+func (pq *PlansQuery) invalidatePlan(id string) {
+	pq.AfterCommit(func() {
+		pq.cache.Invalidate("plan:" + id)
+		pq.cache.InvalidatePrefix("all:")
+	})
+}
+
+// timeOrNull formats the time to be stored, the zero time is stored as null
+func timeOrNull(t time.Time) any {
+	if t.IsZero() {
+		return nil
 	}
-	return strs
+	return t.Format(time.RFC3339)
 }
 
 type Plan struct {
-	Id              string                        `json:"id"`
-	Assets          []domain.Asset                `json:"assets"`
-	Security        domain.MultiSigWalletSecurity `json:"security"`
-	Strategy        domain.ProfitSharingStrategy  `json:"strategy"`
-	Quantum         int                           `json:"quantum"`
-	LossProtection  float64                       `json:"loss_protection"`
-	InvestingPeriod int                           `json:"investing_period"`
-}
-
-// All returns all plans
-func (pq *PlansQuery) All(ctx context.Context) ([]Plan, error) {
-	rows, err := pq.QueryContext(ctx, `select * from plans_query;`)
+	Id                  string                        `json:"id"`
+	Type                domain.PlanType               `json:"type"`
+	Assets              []domain.Asset                `json:"assets"`
+	Security            domain.MultiSigWalletSecurity `json:"security"`
+	Strategy            domain.ProfitSharingStrategy  `json:"strategy"`
+	Quantum             int                           `json:"quantum"`
+	LossProtection      float64                       `json:"loss_protection"`
+	InvestingPeriod     int                           `json:"investing_period"`
+	InvestingPeriodUnit domain.PeriodUnit             `json:"investing_period_unit"`
+	GracePeriodDays     int                           `json:"grace_period_days"`
+	MaxShareMultiplier  int                           `json:"max_share_multiplier"`
+	Network             domain.Network                `json:"network"`
+	MaxActivePairs      int                           `json:"max_active_pairs"`
+	ActiveFrom          *time.Time                    `json:"active_from"`
+	ActiveUntil         *time.Time                    `json:"active_until"`
+	PausedAt            *time.Time                    `json:"paused_at"`
+	APR                 float64                       `json:"apr"`
+	// StatusTimeouts are how long the pairs of the plan may stay in each status before they are escalated
+	StatusTimeouts map[domain.PairStatus]domain.Duration `json:"status_timeouts"`
+	// AssurancePolicies are the assurances the participants pre-sign by asset of the plan, as its chain's policy or the default one
+	AssurancePolicies map[domain.Asset]domain.AssurancePolicy `json:"assurance_policies"`
+}
+
+// AllowsShareMultiplier checks if the given multiplier of the quantum is within the bounds of the plan
+func (p Plan) AllowsShareMultiplier(multiplier int) bool {
+	return multiplier >= 1 && multiplier <= p.MaxShareMultiplier
+}
+
+// PlanAvailability tells whether a plan accepts new pairs or why it doesn't
+type PlanAvailability string
+
+const (
+	PlanAvailabilityAvailable  PlanAvailability = "available"
+	PlanAvailabilityPaused     PlanAvailability = "paused"
+	PlanAvailabilityNotStarted PlanAvailability = "not_started"
+	PlanAvailabilityEnded      PlanAvailability = "ended"
+	PlanAvailabilityAtCapacity PlanAvailability = "at_capacity"
+)
+
+// Availability returns the availability of the plan at the given time with the given number of active pairs
+func (p Plan) Availability(now time.Time, activePairs int) PlanAvailability {
+	switch {
+	case p.PausedAt != nil:
+		return PlanAvailabilityPaused
+	case p.ActiveFrom != nil && now.Before(*p.ActiveFrom):
+		return PlanAvailabilityNotStarted
+	case p.ActiveUntil != nil && !now.Before(*p.ActiveUntil):
+		return PlanAvailabilityEnded
+	case p.MaxActivePairs > 0 && activePairs >= p.MaxActivePairs:
+		return PlanAvailabilityAtCapacity
+	default:
+		return PlanAvailabilityAvailable
+	}
+}
+
+// estimatedPlanAPR is the APR advertised for every plan until the plans carry their own
+const estimatedPlanAPR = 0.15
+
+// PlanSort is the order the plans are listed in
+type PlanSort string
+
+const (
+	PlanSortAPR     PlanSort = "apr"
+	PlanSortQuantum PlanSort = "quantum"
+)
+
+// PlanFilter are the conditions to find the plans of a network with, zero fields don't constrain the plans
+type PlanFilter struct {
+	Network domain.Network
+	Type    domain.PlanType
+	// Asset matches the plans accepting it
+	Asset               domain.Asset
+	MinQuantum          int
+	MaxQuantum          int
+	InvestingPeriod     int
+	InvestingPeriodUnit domain.PeriodUnit
+	Security            domain.MultiSigWalletSecurity
+	// SortBy orders the plans, ascending unless Descending is set, they are ordered by id when empty
+	SortBy     PlanSort
+	Descending bool
+}
+
+// All returns the plans of the network matching the filter
+func (pq *PlansQuery) All(ctx context.Context, f PlanFilter) ([]Plan, error) {
+	b := sqlbuilder.NewSelectBuilder()
+	b.SetFlavor(sqlbuilder.SQLite)
+	b.Select(planColumns...).From("plans_query").Where(b.Equal("network", string(f.Network)))
+	if f.Asset != "" {
+		b.Where(fmt.Sprintf("exists (select 1 from json_each(assets) where value = %s)", b.Var(string(f.Asset))))
+	}
+	if f.MinQuantum > 0 {
+		b.Where(b.GreaterEqualThan("quantum", f.MinQuantum))
+	}
+	if f.MaxQuantum > 0 {
+		b.Where(b.LessEqualThan("quantum", f.MaxQuantum))
+	}
+	if f.InvestingPeriod > 0 {
+		b.Where(b.Equal("investing_period", f.InvestingPeriod))
+	}
+	if f.InvestingPeriodUnit != "" {
+		b.Where(b.Equal("investing_period_unit", string(f.InvestingPeriodUnit)))
+	}
+	if f.Security != "" {
+		b.Where(b.Equal("security", string(f.Security)))
+	}
+	if f.Type != "" {
+		b.Where(b.Equal("coalesce(plan_type, 'lp')", string(f.Type)))
+	}
+
+	order := "asc"
+	if f.Descending {
+		order = "desc"
+	}
+	if f.SortBy == PlanSortQuantum {
+		b.OrderBy("quantum "+order, "id")
+	} else {
+		b.OrderBy("id")
+	}
+
+	query, args := b.Build()
+	return common.Cached(pq.cache, fmt.Sprintf("all:%s:%v:%s:%t", query, args, f.SortBy, f.Descending), func() ([]Plan, error) {
+		plans, err := pq.all(ctx, query, args)
+		if err != nil || f.SortBy != PlanSortAPR {
+			return plans, err
+		}
+
+		// The APR isn't stored with the plans, they are sorted by it once fetched
+		sort.SliceStable(plans, func(i, j int) bool {
+			if f.Descending {
+				return plans[i].APR > plans[j].APR
+			}
+			return plans[i].APR < plans[j].APR
+		})
+		return plans, nil
+	})
+}
+
+func (pq *PlansQuery) all(ctx context.Context, query string, args []any) ([]Plan, error) {
+	rows, err := pq.Reader().QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query plans: %w", err)
 	}
@@ -102,68 +296,159 @@ func (pq *PlansQuery) All(ctx context.Context) ([]Plan, error) {
 
 	plans := []Plan{}
 	for rows.Next() {
-		var (
-			id              string
-			assets          string
-			security        string
-			strategy        string
-			quantum         int
-			LossProtection  float64
-			investingPeriod int
-		)
-		if err := rows.Scan(&id, &assets, &security, &strategy, &quantum, &LossProtection, &investingPeriod); err != nil {
-			return nil, fmt.Errorf("failed to scan plan: %w", err)
+		p, err := scanPlan(rows)
+		if err != nil {
+			return nil, err
 		}
-		plans = append(plans, Plan{
-			Id:              id,
-			Assets:          stringsToAssets(strings.Split(assets, ",")),
-			Security:        domain.MultiSigWalletSecurity(security),
-			Strategy:        domain.ProfitSharingStrategy(strategy),
-			Quantum:         quantum,
-			LossProtection:  LossProtection,
-			InvestingPeriod: investingPeriod,
-		})
+		plans = append(plans, *p)
 	}
 
 	return plans, nil
 }
 
-func stringsToAssets(strs []string) []domain.Asset {
-	assets := make([]domain.Asset, len(strs))
-	for i, s := range strs {
-		assets[i] = domain.Asset(s)
+// Verify scans all the plans and returns the corrupt ones, see CorruptRowError
+func (pq *PlansQuery) Verify(ctx context.Context) ([]CorruptRowError, error) {
+	rows, err := pq.Reader().QueryContext(ctx, `select `+strings.Join(planColumns, ", ")+` from plans_query;`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query plans: %w", err)
 	}
-	return assets
+	defer rows.Close()
+
+	corrupt := []CorruptRowError{}
+	for rows.Next() {
+		_, err := scanPlan(rows)
+		var c *CorruptRowError
+		if errors.As(err, &c) {
+			corrupt = append(corrupt, *c)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return corrupt, rows.Err()
 }
 
 var ErrPlanNotFound = common.NewError("plan_not_found", "plan not found")
 
 // Get returns a plan by id
 func (pq *PlansQuery) Get(ctx context.Context, id string) (*Plan, error) {
-	row := pq.QueryRowContext(ctx, `select * from plans_query where id = ?;`, id)
+	return common.Cached(pq.cache, "plan:"+id, func() (*Plan, error) {
+		return pq.get(ctx, id)
+	})
+}
+
+func (pq *PlansQuery) get(ctx context.Context, id string) (*Plan, error) {
+	return scanPlan(pq.Reader().QueryRowContext(ctx, `select `+strings.Join(planColumns, ", ")+` from plans_query where id = ?;`, id))
+}
+
+// planColumns are the columns selected to build a Plan
+var planColumns = []string{
+	"id",
+	"json(assets)",
+	"security",
+	"strategy",
+	"quantum",
+	"loss_protection",
+	"investing_period",
+	"max_share_multiplier",
+	"network",
+	"investing_period_unit",
+	"grace_period_days",
+	"max_active_pairs",
+	"active_from",
+	"active_until",
+	"paused_at",
+	"json(status_timeouts)",
+	"coalesce(plan_type, 'lp')",
+	"coalesce(json(assurance_policies), '{}')",
+}
 
+func scanPlan(row scanner) (*Plan, error) {
 	var (
-		assets          string
+		id              string
+		assets          []byte
 		security        string
 		strategy        string
 		quantum         int
 		lossProtection  float64
 		investingPeriod int
+		maxMultiplier   int
+		network         string
+		periodUnit      string
+		graceDays       int
+		maxActivePairs  int
+		activeFrom      sql.NullString
+		activeUntil     sql.NullString
+		pausedAt        sql.NullString
+		statusTimeouts  []byte
+		planType        string
+		policies        []byte
 	)
-	if err := row.Scan(&id, &assets, &security, &strategy, &quantum, &lossProtection, &investingPeriod); err != nil {
+	if err := row.Scan(
+		&id,
+		&assets,
+		&security,
+		&strategy,
+		&quantum,
+		&lossProtection,
+		&investingPeriod,
+		&maxMultiplier,
+		&network,
+		&periodUnit,
+		&graceDays,
+		&maxActivePairs,
+		&activeFrom,
+		&activeUntil,
+		&pausedAt,
+		&statusTimeouts,
+		&planType,
+		&policies,
+	); err != nil {
 		if err == sql.ErrNoRows {
 			return nil, ErrPlanNotFound
 		}
 		return nil, fmt.Errorf("failed to scan plan: %w", err)
 	}
 
-	return &Plan{
-		Id:              id,
-		Assets:          stringsToAssets(strings.Split(assets, ",")),
-		Security:        domain.MultiSigWalletSecurity(security),
-		Strategy:        domain.ProfitSharingStrategy(strategy),
-		Quantum:         quantum,
-		LossProtection:  lossProtection,
-		InvestingPeriod: investingPeriod,
-	}, nil
+	d := newRowDecoder("plans_query", id)
+	plan := Plan{
+		Id:                  id,
+		Type:                domain.PlanType(planType),
+		Assets:              decodeColumn[[]domain.Asset](d, "assets", assets),
+		Security:            domain.MultiSigWalletSecurity(security),
+		Strategy:            domain.ProfitSharingStrategy(strategy),
+		Quantum:             quantum,
+		LossProtection:      lossProtection,
+		InvestingPeriod:     investingPeriod,
+		InvestingPeriodUnit: domain.PeriodUnit(periodUnit),
+		GracePeriodDays:     graceDays,
+		MaxShareMultiplier:  maxMultiplier,
+		Network:             domain.Network(network),
+		MaxActivePairs:      maxActivePairs,
+		ActiveFrom:          d.nullTime("active_from", activeFrom),
+		ActiveUntil:         d.nullTime("active_until", activeUntil),
+		PausedAt:            d.nullTime("paused_at", pausedAt),
+		APR:                 estimatedPlanAPR,
+		StatusTimeouts:      decodeColumn[map[domain.PairStatus]domain.Duration](d, "status_timeouts", statusTimeouts),
+	}
+	plan.AssurancePolicies = assetAssurancePolicies(plan.Type, plan.Assets, decodeColumn[map[string]domain.AssurancePolicy](d, "assurance_policies", policies))
+	if err := d.Err(); err != nil {
+		return nil, err
+	}
+
+	return &plan, nil
+}
+
+// assetAssurancePolicies resolves the policy of every asset of the plan, the one of its chain or the default one
+func assetAssurancePolicies(planType domain.PlanType, assets []domain.Asset, byChain map[string]domain.AssurancePolicy) map[domain.Asset]domain.AssurancePolicy {
+	plan := domain.Plan{Type: domain.PlanTypeOrDefault(planType), AssurancePolicies: byChain}
+	policies := make(map[domain.Asset]domain.AssurancePolicy, len(assets))
+	for _, asset := range assets {
+		chain, _ := domain.ChainOf(asset)
+		policies[asset] = plan.AssurancePolicy(chain)
+	}
+
+	return policies
 }
@@ -0,0 +1,167 @@
+package queries
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/co-defi/api-server/common"
+	"github.com/co-defi/api-server/domain"
+)
+
+// LPPosition is the liquidity a member provides to a pool along with the state of the pool, as reported by the chain.
+// The amounts are in units of the assets, e.g. BTC rather than satoshis.
+type LPPosition struct {
+	Units      float64
+	PoolUnits  float64
+	RuneDepth  float64
+	AssetDepth float64
+	RuneAdded  float64
+	AssetAdded float64
+}
+
+// PositionSource provides the liquidity the address provides to the pool
+type PositionSource interface {
+	Position(ctx context.Context, pool domain.Asset, address domain.Address) (LPPosition, error)
+}
+
+// positionTTL is how long a valuation is served before the position is fetched again
+const positionTTL = 30 * time.Second
+
+// PositionsQuery values the liquidity the pairs provide during their investing period
+type PositionsQuery struct {
+	source PositionSource
+	oracle PriceOracle
+
+	mu    sync.Mutex
+	cache map[string]cachedPosition
+}
+
+type cachedPosition struct {
+	position  *Position
+	expiresAt time.Time
+}
+
+// NewPositionsQuery creates a new PositionsQuery, the positions can only be valued when both the source and the oracle are set
+func NewPositionsQuery(source PositionSource, oracle PriceOracle) *PositionsQuery {
+	return &PositionsQuery{source: source, oracle: oracle, cache: make(map[string]cachedPosition)}
+}
+
+var (
+	ErrPositionUnavailable = common.NewError("position_unavailable", "positions can't be valued")
+	ErrNoPosition          = common.NewError("no_position", "pair doesn't provide liquidity")
+)
+
+// Position is the valuation of the liquidity a pair provides
+type Position struct {
+	PairId string       `json:"pair_id"`
+	Pool   domain.Asset `json:"pool"`
+	// PoolShare is the share of the pool the pair owns, in percent
+	PoolShare float64 `json:"pool_share"`
+	// Redeemable holds the amounts the pair would get by withdrawing now
+	Redeemable map[domain.Asset]float64 `json:"redeemable"`
+	// Added holds the amounts the pair added to the pool
+	Added     map[domain.Asset]float64 `json:"added"`
+	PricesUSD map[domain.Asset]float64 `json:"prices_usd"`
+	ValueUSD  float64                  `json:"value_usd"`
+	// HeldValueUSD is what the added amounts would be worth now if they were held instead
+	HeldValueUSD float64 `json:"held_value_usd"`
+	// ImpermanentLoss is the loss due to the price divergence since the liquidity was added, in percent of the held value
+	ImpermanentLoss float64 `json:"impermanent_loss"`
+	// FeesUSD estimates the fees and rewards accumulated by the position, i.e. its value on top of the one explained by the price divergence
+	FeesUSD  float64   `json:"fees_usd"`
+	ValuedAt time.Time `json:"valued_at"`
+}
+
+// Get values the position of the pair with the current state of its pool
+func (q *PositionsQuery) Get(ctx context.Context, pair *Pair) (*Position, error) {
+	if q.source == nil || q.oracle == nil {
+		return nil, ErrPositionUnavailable
+	}
+	if pair.Status != domain.PairStatusLP || pair.Wallet == nil || len(pair.LP) == 0 {
+		return nil, ErrNoPosition
+	}
+
+	q.mu.Lock()
+	cached, ok := q.cache[pair.Id]
+	q.mu.Unlock()
+	if ok && time.Now().Before(cached.expiresAt) {
+		return cached.position, nil
+	}
+
+	position, err := q.value(ctx, pair)
+	if err != nil {
+		return nil, err
+	}
+
+	q.mu.Lock()
+	q.cache[pair.Id] = cachedPosition{position: position, expiresAt: time.Now().Add(positionTTL)}
+	q.mu.Unlock()
+
+	return position, nil
+}
+
+func (q *PositionsQuery) value(ctx context.Context, pair *Pair) (*Position, error) {
+	pool := poolOf(pair.Assets)
+	lp, err := q.source.Position(ctx, pool, pair.Wallet.Addresses[domain.RuneAsset])
+	if err != nil {
+		return nil, err
+	}
+	if lp.Units == 0 || lp.PoolUnits == 0 {
+		return nil, ErrNoPosition
+	}
+
+	prices := make(map[domain.Asset]float64, 2)
+	for _, asset := range []domain.Asset{domain.RuneAsset, pool} {
+		price, err := q.oracle.PriceUSD(ctx, asset)
+		if err != nil {
+			return nil, err
+		}
+		prices[asset] = price
+	}
+
+	share := lp.Units / lp.PoolUnits
+	position := &Position{
+		PairId:    pair.Id,
+		Pool:      pool,
+		PoolShare: share * 100,
+		Redeemable: map[domain.Asset]float64{
+			domain.RuneAsset: share * lp.RuneDepth,
+			pool:             share * lp.AssetDepth,
+		},
+		Added: map[domain.Asset]float64{
+			domain.RuneAsset: lp.RuneAdded,
+			pool:             lp.AssetAdded,
+		},
+		PricesUSD: prices,
+		ValuedAt:  time.Now(),
+	}
+	for asset, amount := range position.Redeemable {
+		position.ValueUSD += amount * prices[asset]
+	}
+	for asset, amount := range position.Added {
+		position.HeldValueUSD += amount * prices[asset]
+	}
+
+	// The impermanent loss of a constant product pool only depends on how the price of the asset in RUNE changed
+	if lp.RuneAdded > 0 && lp.AssetAdded > 0 && lp.AssetDepth > 0 {
+		ratio := (lp.RuneDepth / lp.AssetDepth) / (lp.RuneAdded / lp.AssetAdded)
+		il := 2*math.Sqrt(ratio)/(1+ratio) - 1
+		position.ImpermanentLoss = il * 100
+		position.FeesUSD = position.ValueUSD - position.HeldValueUSD*(1+il)
+	}
+
+	return position, nil
+}
+
+// poolOf returns the pool the assets provide liquidity to, which is named after the non-RUNE asset
+func poolOf(assets []domain.Asset) domain.Asset {
+	for _, a := range assets {
+		if a != domain.RuneAsset {
+			return a
+		}
+	}
+
+	return ""
+}
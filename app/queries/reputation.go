@@ -0,0 +1,227 @@
+package queries
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/co-defi/api-server/common"
+	"github.com/co-defi/api-server/domain"
+	"github.com/hallgren/eventsourcing"
+)
+
+var _ common.Projection = (*ReputationQuery)(nil)
+
+// ReputationQuery is a query that scores participant addresses based on how their pairs ended
+type ReputationQuery struct {
+	*common.BaseProjection
+}
+
+// reputationSchemaVersion is bumped whenever the columns of the reputation tables change, so they are rebuilt
+const reputationSchemaVersion = 1
+
+// NewReputationQuery creates a new ReputationQuery
+func NewReputationQuery(db *common.DB, store common.Store) (*ReputationQuery, error) {
+	bp, err := common.NewBaseProjection(db, store, "reputation_query", reputationSchemaVersion, "reputation_query_pairs")
+	if err != nil {
+		return nil, err
+	}
+
+	rq := ReputationQuery{bp}
+	if err := rq.createTables(); err != nil {
+		return nil, fmt.Errorf("failed to create reputation_query tables: %w", err)
+	}
+
+	return &rq, nil
+}
+
+func (rq *ReputationQuery) createTables() error {
+	_, err := rq.Exec(`create table if not exists reputation_query (
+		address TEXT PRIMARY KEY,
+		completed INTEGER,
+		failed INTEGER,
+		updated_at TEXT
+	);
+	create table if not exists reputation_query_pairs (
+		pair_id VARCHAR,
+		address TEXT,
+		PRIMARY KEY (pair_id, address)
+	);`)
+	return err
+}
+
+// Callback implements the common.Projection.Callback
+func (rq *ReputationQuery) Callback(event eventsourcing.Event) error {
+	return rq.Apply(event, func(tx *sql.Tx) error {
+		switch e := event.Data().(type) {
+		case *domain.PairCreated:
+			if err := insertPairParticipant(tx, event.AggregateID(), e.ParticipantAddress); err != nil {
+				return fmt.Errorf("failed to insert pair participant: %w", err)
+			}
+		case *domain.PairMatched:
+			if err := insertPairParticipant(tx, event.AggregateID(), e.ParticipantAddress); err != nil {
+				return fmt.Errorf("failed to insert pair participant: %w", err)
+			}
+		case *domain.MatchReverted:
+			// the counterparty who never confirmed the wallet fails the match and leaves the pair, the failed keygens
+			// are joint failures so the counterparty leaves the pair without failing it
+			if err := dropPairParticipant(tx, event, e.ParticipantAddress, e.CauseOrDefault() == domain.MatchRevertCauseUnconfirmed); err != nil {
+				return fmt.Errorf("failed to drop pair participant: %w", err)
+			}
+		case *domain.PairStatusChanged:
+			if err := recordOutcome(tx, event, e.Status); err != nil {
+				return err
+			}
+		case *domain.PairStatusForced:
+			// the outcome of a pair forced out of a terminal status is already recorded
+			if isTerminalStatus(e.From) {
+				return nil
+			}
+			if err := recordOutcome(tx, event, e.Status); err != nil {
+				return err
+			}
+		case *domain.RefundIssued:
+			// only the participant who never deposited failed the pair
+			if err := incrementFailedExcept(tx, event, e.ParticipantAddress); err != nil {
+				return fmt.Errorf("failed to increment failed pairs: %w", err)
+			}
+		}
+
+		return nil
+	})
+}
+
+// recordOutcome counts the pair for its participants when it reaches a terminal status
+func recordOutcome(tx executor, event eventsourcing.Event, status domain.PairStatus) error {
+	switch status {
+	case domain.PairStatusWithdrawn:
+		if err := incrementReputation(tx, event, 1, 0); err != nil {
+			return fmt.Errorf("failed to increment completed pairs: %w", err)
+		}
+	case domain.PairStatusInvalid:
+		if err := incrementReputation(tx, event, 0, 1); err != nil {
+			return fmt.Errorf("failed to increment failed pairs: %w", err)
+		}
+	}
+	return nil
+}
+
+func isTerminalStatus(status domain.PairStatus) bool {
+	return status == domain.PairStatusWithdrawn || status == domain.PairStatusInvalid || status == domain.PairStatusRefunded
+}
+
+func insertPairParticipant(tx executor, pairId string, address domain.Address) error {
+	_, err := tx.Exec(`insert into reputation_query_pairs (pair_id, address) values (?, ?) on conflict do nothing;`, pairId, address)
+	return err
+}
+
+// dropPairParticipant removes the address from the participants of the pair, counting a failed pair for it when failed is set
+func dropPairParticipant(tx executor, event eventsourcing.Event, address domain.Address, failed bool) error {
+	if !failed {
+		_, err := tx.Exec(`delete from reputation_query_pairs where pair_id = ? and address = ?;`, event.AggregateID(), address)
+		return err
+	}
+
+	if _, err := tx.Exec(`insert into reputation_query (address, completed, failed, updated_at) values (?, 0, 1, ?)
+		on conflict (address) do update set
+			failed = failed + excluded.failed,
+			updated_at = excluded.updated_at;`,
+		address,
+		event.Timestamp().Format(time.RFC3339),
+	); err != nil {
+		return err
+	}
+
+	_, err := tx.Exec(`delete from reputation_query_pairs where pair_id = ? and address = ?;`, event.AggregateID(), address)
+	return err
+}
+
+// incrementReputation adds the outcome of the pair to the record of all its participants
+func incrementReputation(tx executor, event eventsourcing.Event, completed, failed int) error {
+	_, err := tx.Exec(`insert into reputation_query (address, completed, failed, updated_at)
+		select address, ?, ?, ? from reputation_query_pairs where pair_id = ?
+		on conflict (address) do update set
+			completed = completed + excluded.completed,
+			failed = failed + excluded.failed,
+			updated_at = excluded.updated_at;`,
+		completed,
+		failed,
+		event.Timestamp().Format(time.RFC3339),
+		event.AggregateID(),
+	)
+	return err
+}
+
+// incrementFailedExcept counts the pair as failed for all its participants but the given address
+func incrementFailedExcept(tx executor, event eventsourcing.Event, address domain.Address) error {
+	_, err := tx.Exec(`insert into reputation_query (address, completed, failed, updated_at)
+		select address, 0, 1, ? from reputation_query_pairs where pair_id = ? and address != ?
+		on conflict (address) do update set
+			failed = failed + excluded.failed,
+			updated_at = excluded.updated_at;`,
+		event.Timestamp().Format(time.RFC3339),
+		event.AggregateID(),
+		address,
+	)
+	return err
+}
+
+// Reputation represents the track record of a participant address
+type Reputation struct {
+	Address   domain.Address `json:"address"`
+	Completed int            `json:"completed"`
+	Failed    int            `json:"failed"`
+	Score     float64        `json:"score"`
+	UpdatedAt *time.Time     `json:"updated_at"`
+}
+
+// LowReputationScore is the score below which an address is considered unreliable
+const LowReputationScore = 0.3
+
+// reputationScore is the ratio of completed pairs smoothed towards 0.5 so a single outcome doesn't dominate
+func reputationScore(completed, failed int) float64 {
+	return float64(completed+1) / float64(completed+failed+2)
+}
+
+// Get returns the reputation of an address, addresses without any finished pair have a neutral score
+func (rq *ReputationQuery) Get(ctx context.Context, address domain.Address) (*Reputation, error) {
+	row := rq.Reader().QueryRowContext(ctx, `select completed, failed, updated_at from reputation_query where address = ?;`, address)
+
+	var (
+		completed int
+		failed    int
+		updatedAt sql.NullString
+	)
+	if err := row.Scan(&completed, &failed, &updatedAt); err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to scan reputation: %w", err)
+	}
+
+	d := newRowDecoder("reputation_query", string(address))
+	r := Reputation{
+		Address:   address,
+		Completed: completed,
+		Failed:    failed,
+		Score:     reputationScore(completed, failed),
+		UpdatedAt: d.nullTime("updated_at", updatedAt),
+	}
+	if err := d.Err(); err != nil {
+		return nil, err
+	}
+
+	return &r, nil
+}
+
+// Scores returns the reputation scores of the given addresses
+func (rq *ReputationQuery) Scores(ctx context.Context, addresses []domain.Address) (map[domain.Address]float64, error) {
+	scores := make(map[domain.Address]float64, len(addresses))
+	for _, address := range addresses {
+		r, err := rq.Get(ctx, address)
+		if err != nil {
+			return nil, err
+		}
+		scores[address] = r.Score
+	}
+
+	return scores, nil
+}
@@ -0,0 +1,86 @@
+package queries
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// CorruptRowError is the error of a row of a query table with a column that can't be decoded
+type CorruptRowError struct {
+	Table  string `json:"table"`
+	Id     string `json:"id"`
+	Column string `json:"column"`
+	Err    error  `json:"-"`
+}
+
+// Error implements the error interface
+func (e *CorruptRowError) Error() string {
+	return fmt.Sprintf("corrupt %s row %s: column %s: %v", e.Table, e.Id, e.Column, e.Err)
+}
+
+// Unwrap returns the error decoding the column
+func (e *CorruptRowError) Unwrap() error {
+	return e.Err
+}
+
+// rowDecoder decodes the columns of a row, it keeps the error of the first corrupt column
+// and returns the zero values from then on
+type rowDecoder struct {
+	table string
+	id    string
+	err   *CorruptRowError
+}
+
+func newRowDecoder(table, id string) *rowDecoder {
+	return &rowDecoder{table: table, id: id}
+}
+
+// fail records the column as corrupt, unless another one was already
+func (d *rowDecoder) fail(column string, err error) {
+	if d.err == nil {
+		d.err = &CorruptRowError{Table: d.table, Id: d.id, Column: column, Err: err}
+	}
+}
+
+// Err returns the error of the first corrupt column, if any
+func (d *rowDecoder) Err() error {
+	if d.err == nil {
+		return nil
+	}
+	return d.err
+}
+
+// time parses the RFC3339 time of the column
+func (d *rowDecoder) time(column, value string) time.Time {
+	if d.err != nil {
+		return time.Time{}
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		d.fail(column, err)
+	}
+	return t
+}
+
+// nullTime parses the nullable RFC3339 time of the column
+func (d *rowDecoder) nullTime(column string, ns sql.NullString) *time.Time {
+	if !ns.Valid || d.err != nil {
+		return nil
+	}
+	t := d.time(column, ns.String)
+	return &t
+}
+
+// decodeColumn unmarshals the JSON document of the column
+func decodeColumn[T any](d *rowDecoder, column string, b []byte) T {
+	var v T
+	if d.err != nil {
+		return v
+	}
+	if err := json.Unmarshal(b, &v); err != nil {
+		d.fail(column, err)
+	}
+	return v
+}
@@ -0,0 +1,408 @@
+package queries
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/co-defi/api-server/common"
+	"github.com/co-defi/api-server/domain"
+	"github.com/hallgren/eventsourcing"
+)
+
+var _ common.Projection = (*StatsQuery)(nil)
+
+// PriceOracle provides the current price of the assets in $
+type PriceOracle interface {
+	PriceUSD(ctx context.Context, asset domain.Asset) (float64, error)
+}
+
+// statsTTL is how long the computed statistics are served before they are computed again,
+// the prices of the oracle change regardless of the events so the statistics can't be invalidated by the callbacks only
+const statsTTL = time.Minute
+
+// StatsQuery is a query that aggregates the platform wide statistics of the pairs
+type StatsQuery struct {
+	*common.BaseProjection
+	oracle PriceOracle
+
+	mu    sync.Mutex
+	cache map[string]cachedStats
+}
+
+type cachedStats struct {
+	stats     *Stats
+	expiresAt time.Time
+}
+
+// statsSchemaVersion is bumped whenever the columns of the stats tables change, so they are rebuilt
+const statsSchemaVersion = 2
+
+// NewStatsQuery creates a new StatsQuery, the value locked is priced with the oracle when it's not nil
+func NewStatsQuery(db *common.DB, store common.Store, oracle PriceOracle) (*StatsQuery, error) {
+	bp, err := common.NewBaseProjection(db, store, "stats_query_pairs", statsSchemaVersion, "stats_query_deposits", "stats_query_status_durations")
+	if err != nil {
+		return nil, err
+	}
+
+	sq := StatsQuery{BaseProjection: bp, oracle: oracle, cache: make(map[string]cachedStats)}
+	if err := sq.createTables(); err != nil {
+		return nil, fmt.Errorf("failed to create stats_query tables: %w", err)
+	}
+
+	return &sq, nil
+}
+
+func (sq *StatsQuery) createTables() error {
+	_, err := sq.Exec(`create table if not exists stats_query_pairs (
+		pair_id VARCHAR PRIMARY KEY,
+		plan_id VARCHAR,
+		network TEXT,
+		status TEXT,
+		created_at TEXT,
+		matched_at TEXT,
+		completed_at TEXT,
+		status_entered_at TEXT
+	);
+	create table if not exists stats_query_deposits (
+		pair_id VARCHAR,
+		asset TEXT,
+		amount TEXT,
+		decimals INTEGER,
+		PRIMARY KEY (pair_id, asset)
+	);
+	create table if not exists stats_query_status_durations (
+		pair_id VARCHAR,
+		status TEXT,
+		seconds INTEGER,
+		PRIMARY KEY (pair_id, status)
+	);`)
+	return err
+}
+
+// Callback implements the common.Projection.Callback
+func (sq *StatsQuery) Callback(event eventsourcing.Event) error {
+	return sq.Apply(event, func(tx *sql.Tx) error {
+		switch e := event.Data().(type) {
+		case *domain.PairCreated:
+			if _, err := tx.Exec(`insert into stats_query_pairs (pair_id, plan_id, network, created_at) values (?, ?, ?, ?);`,
+				event.AggregateID(), e.PlanId, domain.NetworkOrDefault(e.Network), event.Timestamp().Format(time.RFC3339)); err != nil {
+				return fmt.Errorf("failed to insert pair stats: %w", err)
+			}
+		case *domain.PairMatched:
+			if _, err := tx.Exec(`update stats_query_pairs set matched_at = ? where pair_id = ?;`,
+				event.Timestamp().Format(time.RFC3339), event.AggregateID()); err != nil {
+				return fmt.Errorf("failed to update pair match time: %w", err)
+			}
+		case *domain.MatchReverted:
+			if _, err := tx.Exec(`update stats_query_pairs set matched_at = null where pair_id = ?;`, event.AggregateID()); err != nil {
+				return fmt.Errorf("failed to reset pair match time: %w", err)
+			}
+		case *domain.PairStatusChanged:
+			if err := updateStatsStatus(tx, event, e.Status); err != nil {
+				return fmt.Errorf("failed to update pair stats status: %w", err)
+			}
+		case *domain.PairStatusForced:
+			if err := updateStatsStatus(tx, event, e.Status); err != nil {
+				return fmt.Errorf("failed to update pair stats status: %w", err)
+			}
+		case *domain.AssetDeposited:
+			// Deposits recorded before their amounts were tracked can't be valued
+			if e.Amount == "" {
+				return nil
+			}
+			if _, err := tx.Exec(`insert into stats_query_deposits (pair_id, asset, amount, decimals) values (?, ?, ?, ?) on conflict do nothing;`,
+				event.AggregateID(), e.Asset, e.Amount, e.Decimals); err != nil {
+				return fmt.Errorf("failed to insert deposit stats: %w", err)
+			}
+		}
+
+		return nil
+	})
+}
+
+// updateStatsStatus sets the status of the pair, a pair is counted as completed the first time it's withdrawn.
+// The time spent in the status it leaves is added to the durations of the pair, the statuses entered again add up.
+func updateStatsStatus(tx executor, event eventsourcing.Event, status domain.PairStatus) error {
+	ts := event.Timestamp().Format(time.RFC3339)
+	if _, err := tx.Exec(`insert into stats_query_status_durations (pair_id, status, seconds)
+		select pair_id, status, max(cast(round((julianday(?) - julianday(status_entered_at)) * 86400) as integer), 0)
+		from stats_query_pairs where pair_id = ? and status is not null and status_entered_at is not null
+		on conflict (pair_id, status) do update set seconds = seconds + excluded.seconds;`,
+		ts, event.AggregateID()); err != nil {
+		return err
+	}
+
+	var completedAt any
+	if status == domain.PairStatusWithdrawn {
+		completedAt = ts
+	}
+
+	_, err := tx.Exec(`update stats_query_pairs set status = ?, status_entered_at = ?, completed_at = coalesce(completed_at, ?) where pair_id = ?;`,
+		status, ts, completedAt, event.AggregateID())
+	return err
+}
+
+// Stats are the platform wide statistics of the pairs of a network
+type Stats struct {
+	Network domain.Network `json:"network"`
+	// ActivePairs is the number of pairs that haven't reached a terminal status, including the waiting ones
+	ActivePairs int `json:"active_pairs"`
+	// TVL is the value locked in the active pairs by asset, TVLUSD is only set when every asset could be priced
+	TVL                 []AssetValue   `json:"tvl"`
+	TVLUSD              *float64       `json:"tvl_usd,omitempty"`
+	WaitingPairsByPlan  map[string]int `json:"waiting_pairs_by_plan"`
+	AverageMatchSeconds float64        `json:"average_match_seconds"`
+	CompletedPairs      []DailyCount   `json:"completed_pairs"`
+	// StatusDurations are the percentiles of the time the pairs spent in each status, e.g. to find the steps the participants abandon
+	StatusDurations map[domain.PairStatus]StatusDuration `json:"status_durations"`
+}
+
+// StatusDuration are the percentiles of the seconds the pairs spent in a status, over the pairs that left it
+type StatusDuration struct {
+	Pairs      int   `json:"pairs"`
+	P50Seconds int64 `json:"p50_seconds"`
+	P90Seconds int64 `json:"p90_seconds"`
+	P99Seconds int64 `json:"p99_seconds"`
+}
+
+// AssetValue is an amount of an asset in its display units along with its value in $ when known
+type AssetValue struct {
+	Asset    domain.Asset `json:"asset"`
+	Amount   string       `json:"amount"`
+	ValueUSD *float64     `json:"value_usd,omitempty"`
+}
+
+// DailyCount is the number of pairs counted on a day
+type DailyCount struct {
+	Date  string `json:"date"`
+	Count int    `json:"count"`
+}
+
+// Stats returns the statistics of the network, the completed pairs are counted by day for the given number of days
+func (sq *StatsQuery) Stats(ctx context.Context, network domain.Network, days int) (*Stats, error) {
+	key := fmt.Sprintf("%s:%d", network, days)
+
+	sq.mu.Lock()
+	cached, ok := sq.cache[key]
+	sq.mu.Unlock()
+	if ok && time.Now().Before(cached.expiresAt) {
+		return cached.stats, nil
+	}
+
+	stats, err := sq.stats(ctx, network, days)
+	if err != nil {
+		return nil, err
+	}
+
+	sq.mu.Lock()
+	sq.cache[key] = cachedStats{stats: stats, expiresAt: time.Now().Add(statsTTL)}
+	sq.mu.Unlock()
+
+	return stats, nil
+}
+
+func (sq *StatsQuery) stats(ctx context.Context, network domain.Network, days int) (*Stats, error) {
+	stats := Stats{Network: network}
+
+	if err := sq.Reader().QueryRowContext(ctx, `select count(*) from stats_query_pairs
+		where network = ? and status not in (?, ?, ?);`,
+		network, domain.PairStatusWithdrawn, domain.PairStatusInvalid, domain.PairStatusRefunded,
+	).Scan(&stats.ActivePairs); err != nil {
+		return nil, fmt.Errorf("failed to count active pairs: %w", err)
+	}
+
+	var avg sql.NullFloat64
+	if err := sq.Reader().QueryRowContext(ctx, `select avg((julianday(matched_at) - julianday(created_at)) * 86400) from stats_query_pairs
+		where network = ? and matched_at is not null;`,
+		network,
+	).Scan(&avg); err != nil {
+		return nil, fmt.Errorf("failed to average match time: %w", err)
+	}
+	stats.AverageMatchSeconds = avg.Float64
+
+	var err error
+	if stats.WaitingPairsByPlan, err = sq.waitingPairsByPlan(ctx, network); err != nil {
+		return nil, err
+	}
+	if stats.CompletedPairs, err = sq.completedPairs(ctx, network, time.Now().AddDate(0, 0, -days)); err != nil {
+		return nil, err
+	}
+	if stats.TVL, stats.TVLUSD, err = sq.valueLocked(ctx, network); err != nil {
+		return nil, err
+	}
+	if stats.StatusDurations, err = sq.statusDurations(ctx, network); err != nil {
+		return nil, err
+	}
+
+	return &stats, nil
+}
+
+func (sq *StatsQuery) waitingPairsByPlan(ctx context.Context, network domain.Network) (map[string]int, error) {
+	rows, err := sq.Reader().QueryContext(ctx, `select plan_id, count(*) from stats_query_pairs
+		where network = ? and status = ? and plan_id != ''
+		group by plan_id;`,
+		network, domain.PairStatusWaiting,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count waiting pairs: %w", err)
+	}
+	defer rows.Close()
+
+	waiting := make(map[string]int)
+	for rows.Next() {
+		var (
+			planId string
+			count  int
+		)
+		if err := rows.Scan(&planId, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan waiting pairs: %w", err)
+		}
+		waiting[planId] = count
+	}
+
+	return waiting, rows.Err()
+}
+
+func (sq *StatsQuery) completedPairs(ctx context.Context, network domain.Network, since time.Time) ([]DailyCount, error) {
+	rows, err := sq.Reader().QueryContext(ctx, `select date(completed_at) as day, count(*) from stats_query_pairs
+		where network = ? and completed_at is not null and datetime(completed_at) >= datetime(?)
+		group by day order by day;`,
+		network, since.Format(time.RFC3339),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count completed pairs: %w", err)
+	}
+	defer rows.Close()
+
+	completed := []DailyCount{}
+	for rows.Next() {
+		var c DailyCount
+		if err := rows.Scan(&c.Date, &c.Count); err != nil {
+			return nil, fmt.Errorf("failed to scan completed pairs: %w", err)
+		}
+		completed = append(completed, c)
+	}
+
+	return completed, rows.Err()
+}
+
+// statusDurations computes the percentiles of the time spent in each status by the pairs of the network, by nearest rank
+func (sq *StatsQuery) statusDurations(ctx context.Context, network domain.Network) (map[domain.PairStatus]StatusDuration, error) {
+	rows, err := sq.Reader().QueryContext(ctx, `select d.status, d.seconds from stats_query_status_durations d
+		join stats_query_pairs p on p.pair_id = d.pair_id
+		where p.network = ?
+		order by d.status, d.seconds;`,
+		network,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query status durations: %w", err)
+	}
+	defer rows.Close()
+
+	seconds := make(map[domain.PairStatus][]int64)
+	for rows.Next() {
+		var (
+			status domain.PairStatus
+			s      int64
+		)
+		if err := rows.Scan(&status, &s); err != nil {
+			return nil, fmt.Errorf("failed to scan status duration: %w", err)
+		}
+		seconds[status] = append(seconds[status], s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	durations := make(map[domain.PairStatus]StatusDuration, len(seconds))
+	for status, sorted := range seconds {
+		durations[status] = StatusDuration{
+			Pairs:      len(sorted),
+			P50Seconds: percentile(sorted, 50),
+			P90Seconds: percentile(sorted, 90),
+			P99Seconds: percentile(sorted, 99),
+		}
+	}
+
+	return durations, nil
+}
+
+// percentile returns the nearest rank percentile of the sorted values, which must not be empty
+func percentile(sorted []int64, p int) int64 {
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// valueLocked sums the deposits of the active pairs by asset and prices them with the oracle.
+// The amounts are summed in base units since they don't fit the SQLite integers.
+func (sq *StatsQuery) valueLocked(ctx context.Context, network domain.Network) ([]AssetValue, *float64, error) {
+	rows, err := sq.Reader().QueryContext(ctx, `select d.asset, d.amount, d.decimals from stats_query_deposits d
+		join stats_query_pairs p on p.pair_id = d.pair_id
+		where p.network = ? and p.status not in (?, ?, ?);`,
+		network, domain.PairStatusWithdrawn, domain.PairStatusInvalid, domain.PairStatusRefunded,
+	)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to query deposits: %w", err)
+	}
+	defer rows.Close()
+
+	totals := make(map[domain.Asset]*big.Int)
+	decimals := make(map[domain.Asset]int)
+	for rows.Next() {
+		var (
+			asset  domain.Asset
+			amount string
+			dec    int
+		)
+		if err := rows.Scan(&asset, &amount, &dec); err != nil {
+			return nil, nil, fmt.Errorf("failed to scan deposit: %w", err)
+		}
+		value, ok := new(big.Int).SetString(amount, 10)
+		if !ok {
+			continue
+		}
+		if totals[asset] == nil {
+			totals[asset] = new(big.Int)
+		}
+		totals[asset].Add(totals[asset], value)
+		decimals[asset] = dec
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	values := make([]AssetValue, 0, len(totals))
+	total, priced := 0.0, sq.oracle != nil
+	for asset, amount := range totals {
+		units := toUnits(amount, decimals[asset])
+		value := AssetValue{Asset: asset, Amount: units.Text('f', -1)}
+
+		if sq.oracle != nil {
+			if price, err := sq.oracle.PriceUSD(ctx, asset); err == nil {
+				f, _ := units.Float64()
+				usd := f * price
+				value.ValueUSD = &usd
+				total += usd
+			} else {
+				priced = false
+			}
+		}
+		values = append(values, value)
+	}
+	sort.Slice(values, func(i, j int) bool {
+		return values[i].Asset < values[j].Asset
+	})
+
+	if !priced {
+		return values, nil, nil
+	}
+	return values, &total, nil
+}
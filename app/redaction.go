@@ -0,0 +1,102 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/co-defi/api-server/common"
+	"github.com/co-defi/api-server/domain"
+)
+
+// personalDataShredder crypto-shreds the personal data of the addresses by shredding the keys of their subjects,
+// and deletes the copies of the data kept in clear, see commands.PersonalDataShredder
+type personalDataShredder struct {
+	db       *common.DB
+	subjects *common.SubjectKeys
+	exports  *DataExporter
+}
+
+// Shred implements commands.PersonalDataShredder
+func (s *personalDataShredder) Shred(ctx context.Context, address domain.Address) ([]string, error) {
+	if err := s.sealStoredEvents(ctx, address); err != nil {
+		return nil, err
+	}
+	if _, err := s.subjects.Shred(ctx, string(address)); err != nil {
+		return nil, err
+	}
+	if err := s.exports.Delete(ctx, address); err != nil {
+		return nil, err
+	}
+
+	var fields []string
+	for _, personal := range personalEventFields {
+		for _, path := range personal.Paths {
+			if !slices.Contains(fields, path) {
+				fields = append(fields, path)
+			}
+		}
+	}
+	slices.Sort(fields)
+
+	return fields, nil
+}
+
+// sealStoredEvents seals the personal fields of the address stored in clear, i.e. before they were sealed with the keys
+// of their subjects, so shredding the keys covers them too. The events are sealed before the transaction updating them,
+// as sealing may store the key of the subject on the single write connection.
+func (s *personalDataShredder) sealStoredEvents(ctx context.Context, address domain.Address) error {
+	type event struct {
+		seq  int64
+		data []byte
+	}
+	var sealed []event
+	for t, fields := range personalEventFields {
+		rows, err := s.db.Read.QueryContext(ctx, `select seq, data from events
+			where reason = ? and json_extract(cast(data as text), '$.' || ?) = ? order by seq;`, t.Name(), fields.Subject, address)
+		if err != nil {
+			return fmt.Errorf("failed to query events: %w", err)
+		}
+
+		var events []event
+		for rows.Next() {
+			var e event
+			if err := rows.Scan(&e.seq, &e.data); err != nil {
+				rows.Close()
+				return fmt.Errorf("failed to scan event: %w", err)
+			}
+			events = append(events, e)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for _, e := range events {
+			data, err := s.subjects.SealJSON(e.data, fields)
+			if err != nil {
+				return fmt.Errorf("failed to seal event %d: %w", e.seq, err)
+			}
+			if !bytes.Equal(data, e.data) {
+				sealed = append(sealed, event{seq: e.seq, data: data})
+			}
+		}
+	}
+	if len(sealed) == 0 {
+		return nil
+	}
+
+	tx, err := s.db.Write.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, e := range sealed {
+		if _, err := tx.ExecContext(ctx, `update events set data = ? where seq = ?;`, e.data, e.seq); err != nil {
+			return fmt.Errorf("failed to update event %d: %w", e.seq, err)
+		}
+	}
+
+	return tx.Commit()
+}
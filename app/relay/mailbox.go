@@ -0,0 +1,117 @@
+package relay
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/co-defi/api-server/common"
+	"github.com/co-defi/api-server/domain"
+)
+
+// MessageKind tells the TSS ceremony a relayed message belongs to
+type MessageKind string
+
+const (
+	MessageKindKeygen  MessageKind = "keygen"
+	MessageKindKeysign MessageKind = "keysign"
+)
+
+// Message is a TSS message relayed from a participant of a pair to its counterparty.
+// The payload is opaque to the server, the parties encrypt it end to end with the wallet encryption key.
+type Message struct {
+	Id        int64          `json:"id"`
+	PairId    string         `json:"pair_id"`
+	From      domain.Address `json:"from"`
+	To        domain.Address `json:"to"`
+	Kind      MessageKind    `json:"kind"`
+	Round     string         `json:"round"`
+	Payload   []byte         `json:"payload"`
+	CreatedAt time.Time      `json:"created_at"`
+}
+
+// Mailbox relays the keygen and keysign rounds between the participants of the pairs, so they can create and use
+// their shared wallet without a third service. Messages are kept until they are pruned, the receivers poll them by id.
+type Mailbox struct {
+	db *common.DB
+}
+
+// NewMailbox creates a new Mailbox and its table
+func NewMailbox(db *common.DB) (*Mailbox, error) {
+	m := Mailbox{db: db}
+	if err := m.createTable(); err != nil {
+		return nil, fmt.Errorf("failed to create relay_messages table: %w", err)
+	}
+
+	return &m, nil
+}
+
+func (m *Mailbox) createTable() error {
+	_, err := m.db.Write.Exec(`create table if not exists relay_messages (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		pair_id VARCHAR,
+		sender TEXT,
+		recipient TEXT,
+		kind TEXT,
+		round TEXT,
+		payload BLOB,
+		created_at TEXT
+	);
+	create index if not exists relay_messages_recipient on relay_messages (pair_id, recipient, id);`)
+	return err
+}
+
+// Post stores the message for its recipient and returns its id
+func (m *Mailbox) Post(ctx context.Context, msg Message) (int64, error) {
+	res, err := m.db.Write.ExecContext(ctx, `insert into relay_messages (pair_id, sender, recipient, kind, round, payload, created_at) values (?, ?, ?, ?, ?, ?, ?);`,
+		msg.PairId, msg.From, msg.To, msg.Kind, msg.Round, msg.Payload, time.Now().UTC().Format(time.RFC3339))
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert relay message: %w", err)
+	}
+
+	return res.LastInsertId()
+}
+
+const defaultReceiveLimit = 100
+
+// Receive returns the messages of the pair addressed to the recipient after the given message id, the oldest first
+func (m *Mailbox) Receive(ctx context.Context, pairId string, recipient domain.Address, after int64, limit int) ([]Message, error) {
+	if limit <= 0 {
+		limit = defaultReceiveLimit
+	}
+
+	rows, err := m.db.Read.QueryContext(ctx, `select id, pair_id, sender, recipient, kind, round, payload, created_at from relay_messages
+		where pair_id = ? and recipient = ? and id > ? order by id limit ?;`, pairId, recipient, after, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query relay messages: %w", err)
+	}
+	defer rows.Close()
+
+	messages := []Message{}
+	for rows.Next() {
+		var (
+			msg       Message
+			createdAt string
+		)
+		if err := rows.Scan(&msg.Id, &msg.PairId, &msg.From, &msg.To, &msg.Kind, &msg.Round, &msg.Payload, &createdAt); err != nil {
+			return nil, fmt.Errorf("failed to scan relay message: %w", err)
+		}
+		msg.CreatedAt, err = time.Parse(time.RFC3339, createdAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse relay message timestamp: %w", err)
+		}
+		messages = append(messages, msg)
+	}
+
+	return messages, rows.Err()
+}
+
+// Prune deletes the messages posted before the given time and returns how many were deleted
+func (m *Mailbox) Prune(ctx context.Context, before time.Time) (int64, error) {
+	res, err := m.db.Write.ExecContext(ctx, `delete from relay_messages where datetime(created_at) < datetime(?);`, before.UTC().Format(time.RFC3339))
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune relay messages: %w", err)
+	}
+
+	return res.RowsAffected()
+}
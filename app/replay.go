@@ -0,0 +1,136 @@
+package app
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/co-defi/api-server/common"
+	"github.com/hallgren/eventsourcing"
+	"github.com/hallgren/eventsourcing/core"
+)
+
+// ReplayOptions tune how the events are replayed into a projection
+type ReplayOptions struct {
+	// From is the global version of the first event to replay, 0 replays the whole store and rebuilds the projection
+	From uint64
+	// Rate is the maximum number of events replayed per second, 0 doesn't limit it
+	Rate int
+	// DryRun only counts the events that would be replayed, the projection is left untouched
+	DryRun bool
+	// Progress is called after each replayed event with the number of events replayed so far and the total
+	Progress func(done, total int)
+	// Cipher is the cipher the server encrypts at rest with, see WithEncryption
+	Cipher *common.Cipher
+}
+
+// ReplayReport is the outcome of a replay
+type ReplayReport struct {
+	// Applied is the number of events replayed into the projection, none for a dry run
+	Applied int
+	// EventTypes is the number of events to replay by event type
+	EventTypes map[string]int
+}
+
+// ReplayProjection replays the events of the store into the named projection from the given global version on.
+// The notification dispatcher isn't a replay target, as replaying it would send the notifications again.
+// The server must be stopped during the replay so the projection isn't updated by both at once.
+func ReplayProjection(db *common.DB, name string, opts ReplayOptions) (ReplayReport, error) {
+	repo, store, _, _, err := createEventRepository(db.Write, opts.Cipher)
+	if err != nil {
+		return ReplayReport{}, fmt.Errorf("failed to create event repository: %w", err)
+	}
+
+	queries, err := newQueries(db, store, nil, nil, opts.Cipher)
+	if err != nil {
+		return ReplayReport{}, fmt.Errorf("failed to prepare queries: %w", err)
+	}
+	if _, ok := queries.projections()[name]; !ok {
+		names := make([]string, 0)
+		for n := range queries.projections() {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return ReplayReport{}, fmt.Errorf("unknown projection %q, one of %v is expected", name, names)
+	}
+
+	report := ReplayReport{EventTypes: make(map[string]int)}
+	total, err := countEvents(store, opts.From, report.EventTypes)
+	if err != nil {
+		return report, err
+	}
+	if opts.DryRun {
+		return report, nil
+	}
+
+	if err := common.RewindProjection(db.Write, name, opts.From); err != nil {
+		return report, fmt.Errorf("failed to rewind projection: %w", err)
+	}
+	// The queries are created again after the rewind, so a projection rewound to the start drops and recreates its tables
+	queries, err = newQueries(db, store, nil, nil, opts.Cipher)
+	if err != nil {
+		return report, fmt.Errorf("failed to prepare queries: %w", err)
+	}
+	projection := queries.projections()[name]
+
+	var interval time.Duration
+	if opts.Rate > 0 {
+		interval = time.Second / time.Duration(opts.Rate)
+	}
+	p := repo.Projections.Projection(projection.Fetch, func(event eventsourcing.Event) error {
+		if err := projection.Callback(event); err != nil {
+			return fmt.Errorf("failed to apply event %d (%s): %w", event.GlobalVersion(), event.Reason(), err)
+		}
+		report.Applied++
+		if opts.Progress != nil {
+			opts.Progress(report.Applied, total)
+		}
+		time.Sleep(interval)
+		return nil
+	})
+	p.Name = name
+
+	for {
+		ran, result := p.RunOnce()
+		if result.Error != nil {
+			return report, result.Error
+		}
+		if !ran {
+			return report, nil
+		}
+	}
+}
+
+// countEvents counts the events of the store from the given global version on by event type
+func countEvents(store common.Store, from uint64, byType map[string]int) (int, error) {
+	start := core.Version(from)
+	if start == 0 {
+		start = 1
+	}
+
+	total := 0
+	for {
+		it, err := store.All(start, exportBatchSize)
+		if err != nil {
+			return total, fmt.Errorf("failed to fetch events: %w", err)
+		}
+
+		fetched := 0
+		for it.Next() {
+			e, err := it.Value()
+			if err != nil {
+				it.Close()
+				return total, fmt.Errorf("failed to read event: %w", err)
+			}
+			byType[e.AggregateType+"."+e.Reason]++
+			start = e.GlobalVersion + 1
+			fetched++
+		}
+		it.Close()
+
+		total += fetched
+		if fetched == 0 {
+			return total, nil
+		}
+	}
+}
@@ -0,0 +1,485 @@
+package app
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+
+	"github.com/co-defi/api-server/app/commands"
+	"github.com/co-defi/api-server/app/queries"
+	"github.com/co-defi/api-server/common"
+	"github.com/co-defi/api-server/domain"
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+)
+
+// SeedScenario describes the plans to create and the pairs to drive on each of them, e.g. for the staging environments
+type SeedScenario struct {
+	Plans []SeedPlan `yaml:"plans"`
+}
+
+// SeedPlan is a plan of a seed scenario, the security and the strategy of the plans aren't configurable as they have a single option
+type SeedPlan struct {
+	Type                domain.PlanType   `yaml:"type"`
+	Assets              []domain.Asset    `yaml:"assets"`
+	Quantum             int               `yaml:"quantum"`
+	LossProtection      float64           `yaml:"loss_protection"`
+	InvestingPeriod     int               `yaml:"investing_period"`
+	InvestingPeriodUnit domain.PeriodUnit `yaml:"investing_period_unit"`
+	GracePeriodDays     int               `yaml:"grace_period_days"`
+	MaxShareMultiplier  int               `yaml:"max_share_multiplier"`
+	Network             domain.Network    `yaml:"network"`
+	MaxActivePairs      int               `yaml:"max_active_pairs"`
+	// Pairs is the number of pairs to drive to each status, from waiting to withdrawn along the lifecycle of the plan type
+	Pairs map[domain.PairStatus]int `yaml:"pairs"`
+}
+
+// SeededPlan is a plan created by a seed scenario along with the ids of its pairs by status
+type SeededPlan struct {
+	Id    string
+	Pairs map[domain.PairStatus][]string
+}
+
+// seedLifecycles hold the statuses the seeded pairs are driven through by plan type, in order
+var seedLifecycles = map[domain.PlanType][]domain.PairStatus{
+	domain.PlanTypeLP: {
+		domain.PairStatusWalletConformation,
+		domain.PairStatusAssurance,
+		domain.PairStatusDeposit,
+		domain.PairStatusPreSignWithdrawal,
+		domain.PairStatusLP,
+		domain.PairStatusWithdrawn,
+	},
+	domain.PlanTypeSavers: {
+		domain.PairStatusWalletConformation,
+		domain.PairStatusAssurance,
+		domain.PairStatusDeposit,
+		domain.PairStatusPreSignSaversWithdrawal,
+		domain.PairStatusSavers,
+		domain.PairStatusWithdrawn,
+	},
+}
+
+// seedAssurancePolicy returns the policy the assurances and the withdrawal of the asset follow in the seeded pairs,
+// their plans don't set any so it's the default one of the plan type
+func seedAssurancePolicy(planType domain.PlanType, asset domain.Asset) domain.AssurancePolicy {
+	chain, _ := domain.ChainOf(asset)
+	return domain.DefaultAssurancePolicy(planType, chain)
+}
+
+// seedWithdrawalNonce returns the nonce of the withdrawal of the asset pre-signed by the participants
+func seedWithdrawalNonce(planType domain.PlanType, asset domain.Asset) int {
+	nonce, _ := seedAssurancePolicy(planType, asset).WithdrawalNonce()
+	return nonce
+}
+
+// Seed creates the plans of the scenario and drives their pairs by replaying the commands of the participants, from new
+// participants of their own. The transactions of the participants are made up, so the chains of the application must trust
+// them as declared, as they are in the development mode: without tx decoders, wallet derivers nor verifiers rejecting them.
+// The pairs are driven to the furthest statuses first and the waiting ones last, so none of them is matched by another.
+func (app *Application) Seed(ctx context.Context, scenario SeedScenario) ([]SeededPlan, error) {
+	s := &seeder{app: app, ctx: ctx}
+	seeded := make([]SeededPlan, 0, len(scenario.Plans))
+	for _, plan := range scenario.Plans {
+		id, err := app.Commands.CreateNewPlan.Handle(ctx, commands.CreateNewPlan{
+			Type:                plan.Type,
+			Assets:              plan.Assets,
+			Security:            domain.MultiSigWalletSecurity2Of2,
+			Strategy:            domain.ProfitSharingStrategyEqualShare,
+			Quantum:             plan.Quantum,
+			LossProtection:      plan.LossProtection,
+			InvestingPeriod:     plan.InvestingPeriod,
+			InvestingPeriodUnit: plan.InvestingPeriodUnit,
+			GracePeriodDays:     plan.GracePeriodDays,
+			MaxShareMultiplier:  plan.MaxShareMultiplier,
+			Network:             plan.Network,
+			MaxActivePairs:      plan.MaxActivePairs,
+		})
+		if err != nil {
+			return seeded, fmt.Errorf("failed to create plan of %v: %w", plan.Assets, err)
+		}
+		if err := app.CatchUpProjections(ctx); err != nil {
+			return seeded, err
+		}
+
+		pairs, err := s.seedPairs(ctx, id, plan)
+		seeded = append(seeded, SeededPlan{Id: id, Pairs: pairs})
+		if err != nil {
+			return seeded, fmt.Errorf("failed to seed pairs of plan %s: %w", id, err)
+		}
+	}
+
+	return seeded, nil
+}
+
+// seeder drives the seeded pairs through their lifecycle with made up participants, wallets and transactions
+type seeder struct {
+	app *Application
+	// ctx is the context the projections are caught up with after each command
+	ctx context.Context
+}
+
+// seedParticipant is a made up participant of a seeded pair
+type seedParticipant struct {
+	asset   domain.Asset
+	address domain.Address
+	sign    func(message string) ([]byte, error)
+}
+
+func (s *seeder) seedPairs(ctx context.Context, planId string, plan SeedPlan) (map[domain.PairStatus][]string, error) {
+	planType := domain.PlanTypeOrDefault(plan.Type)
+	statuses := seedLifecycles[planType]
+
+	for status := range plan.Pairs {
+		if status != domain.PairStatusWaiting && indexOfStatus(statuses, status) < 0 {
+			return nil, fmt.Errorf("pairs of %s plans can't be seeded in %s", planType, status)
+		}
+	}
+
+	pairs := make(map[domain.PairStatus][]string, len(plan.Pairs))
+	for i := len(statuses) - 1; i >= 0; i-- {
+		for n := 0; n < plan.Pairs[statuses[i]]; n++ {
+			id, err := s.drive(ctx, planId, plan, statuses[:i+1])
+			if err != nil {
+				return pairs, err
+			}
+			pairs[statuses[i]] = append(pairs[statuses[i]], id)
+		}
+	}
+	for n := 0; n < plan.Pairs[domain.PairStatusWaiting]; n++ {
+		creator, err := s.newParticipant(plan.Assets[0])
+		if err != nil {
+			return pairs, err
+		}
+		id, err := s.createPair(ctx, planId, plan.Network, creator)
+		if err != nil {
+			return pairs, err
+		}
+		pairs[domain.PairStatusWaiting] = append(pairs[domain.PairStatusWaiting], id)
+	}
+
+	return pairs, nil
+}
+
+// drive creates a pair of new participants on the plan and drives it through the statuses, the last one is the status it's left in
+func (s *seeder) drive(ctx context.Context, planId string, plan SeedPlan, statuses []domain.PairStatus) (string, error) {
+	var participants [2]seedParticipant
+	for i, asset := range plan.Assets {
+		participant, err := s.newParticipant(asset)
+		if err != nil {
+			return "", err
+		}
+		participants[i] = participant
+	}
+
+	id, err := s.createPair(ctx, planId, plan.Network, participants[0])
+	if err != nil {
+		return "", err
+	}
+
+	steps := map[domain.PairStatus]func(context.Context, string, [2]seedParticipant) error{
+		domain.PairStatusWalletConformation: func(ctx context.Context, id string, participants [2]seedParticipant) error {
+			return s.matchPair(ctx, planId, plan.Network, id, participants[1])
+		},
+		domain.PairStatusAssurance:               s.confirmWallet,
+		domain.PairStatusDeposit:                 s.assure,
+		domain.PairStatusPreSignWithdrawal:       s.deposit,
+		domain.PairStatusPreSignSaversWithdrawal: s.deposit,
+		domain.PairStatusLP:                      s.provideLiquidity,
+		domain.PairStatusSavers:                  s.depositIntoSavers,
+		domain.PairStatusWithdrawn:               s.withdraw,
+	}
+	for _, status := range statuses {
+		if err := steps[status](ctx, id, participants); err != nil {
+			return id, fmt.Errorf("failed to drive pair %s to %s: %w", id, status, err)
+		}
+	}
+
+	return id, nil
+}
+
+// newParticipant makes up a participant investing the asset, with a key of their own so their signatures verify
+func (s *seeder) newParticipant(asset domain.Asset) (seedParticipant, error) {
+	chain, _ := domain.ChainOf(asset)
+	key, err := ethcrypto.GenerateKey()
+	if err != nil {
+		return seedParticipant{}, fmt.Errorf("failed to generate key: %w", err)
+	}
+	address, err := common.AddressFromPublicKey(chain, ethcrypto.CompressPubkey(&key.PublicKey))
+	if err != nil {
+		return seedParticipant{}, fmt.Errorf("failed to derive the address on %s: %w", chain, err)
+	}
+	return seedParticipant{
+		asset:   asset,
+		address: address,
+		sign: func(message string) ([]byte, error) {
+			return common.SignMessage(chain, key, message)
+		},
+	}, nil
+}
+
+// seedAddress makes up an address on the chain from the seed, shaped as the addresses of the chain
+func seedAddress(chain, seed string) domain.Address {
+	sum := sha256.Sum256([]byte(chain + seed))
+	digest := hex.EncodeToString(sum[:])
+	switch chain {
+	case "ETH":
+		return "0x" + digest[:40]
+	case "BTC":
+		return "bc1q" + digest[:38]
+	case "THOR":
+		return "thor1" + digest[:38]
+	default:
+		return chain + "-" + digest[:40]
+	}
+}
+
+// seedTx makes up a pre-signed transaction with the nonce, unique to the pair and the asset
+func seedTx(pairId string, asset domain.Asset, nonce int) domain.SignedTx {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s:%s:%d", pairId, asset, nonce)))
+	chain, _ := domain.ChainOf(asset)
+	return domain.SignedTx{
+		Nonce:           nonce,
+		Tx:              sum[:],
+		Signature:       sum[:],
+		Chain:           chain,
+		SignatureScheme: domain.SignatureSchemeECDSASecp256k1,
+		PayloadHash:     sum[:],
+	}
+}
+
+// seedTxHash makes up the hash of a transaction of the pair
+func seedTxHash(pairId string, asset domain.Asset, step string) domain.TxHash {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s:%s:%s", pairId, asset, step)))
+	return fmt.Sprintf("%X", sum)
+}
+
+// run runs a command and catches the projections up with its events, so the next steps see its changes
+func (s *seeder) run(_ string, err error) error {
+	if err != nil {
+		return err
+	}
+	return s.app.CatchUpProjections(s.ctx)
+}
+
+func (s *seeder) pair(ctx context.Context, id string) (*queries.Pair, error) {
+	return s.app.Queries.Pairs.Get(ctx, id)
+}
+
+func (s *seeder) createPair(ctx context.Context, planId string, network domain.Network, creator seedParticipant) (string, error) {
+	id, err := s.app.Commands.CreateOrMatchPair.Handle(ctx, commands.CreateOrMatchPair{
+		PlanId:             planId,
+		ParticipantAsset:   creator.asset,
+		ParticipantAddress: creator.address,
+		Network:            network,
+	})
+	return id, s.run(id, err)
+}
+
+func (s *seeder) matchPair(ctx context.Context, planId string, network domain.Network, id string, matcher seedParticipant) error {
+	matched, err := s.app.Commands.CreateOrMatchPair.Handle(ctx, commands.CreateOrMatchPair{
+		PlanId:             planId,
+		ParticipantAsset:   matcher.asset,
+		ParticipantAddress: matcher.address,
+		Network:            network,
+	})
+	if err := s.run(matched, err); err != nil {
+		return err
+	}
+	if matched != id {
+		return fmt.Errorf("pair %s was matched instead", matched)
+	}
+	return nil
+}
+
+func (s *seeder) confirmWallet(ctx context.Context, id string, participants [2]seedParticipant) error {
+	sum := sha256.Sum256([]byte(id))
+	publicKey := hex.EncodeToString(sum[:])
+
+	addresses := make(map[domain.Asset]domain.Address, 2)
+	for _, participant := range participants {
+		chain, _ := domain.ChainOf(participant.asset)
+		addresses[participant.asset] = seedAddress(chain, publicKey)
+	}
+
+	for _, participant := range participants {
+		err := s.run(s.app.Commands.ConfirmPairWallet.Handle(ctx, commands.ConfirmPairWallet{
+			PairId:               id,
+			ParticipantAddress:   participant.address,
+			ParticipantPublicKey: publicKey,
+			WalletAddresses:      addresses,
+		}))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *seeder) assure(ctx context.Context, id string, participants [2]seedParticipant) error {
+	p, err := s.pair(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	for i, participant := range participants {
+		nonces := seedAssurancePolicy(p.PlanType, participant.asset).Nonces
+		assurances := make([]domain.SignedTx, 0, len(nonces))
+		for _, nonce := range nonces {
+			assurances = append(assurances, seedTx(id, participant.asset, nonce))
+		}
+
+		err := s.run(s.app.Commands.SetPairAssurances.Handle(ctx, commands.SetPairAssurances{
+			PairId:             id,
+			ParticipantAddress: participants[1-i].address,
+			Asset:              participant.asset,
+			Assurances:         assurances,
+		}))
+		if err != nil {
+			return err
+		}
+	}
+
+	if p, err = s.pair(ctx, id); err != nil {
+		return err
+	}
+	for _, participant := range participants {
+		signature, err := participant.sign(domain.AssurancesAcknowledgement(id, participant.asset, p.Assurances[participant.asset]))
+		if err != nil {
+			return fmt.Errorf("failed to sign assurances acknowledgement: %w", err)
+		}
+		err = s.run(s.app.Commands.ConfirmAssurances.Handle(ctx, commands.ConfirmAssurances{
+			PairId:             id,
+			ParticipantAddress: participant.address,
+			Signature:          signature,
+		}))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deposit adds the deposits of both participants, a single unit of their asset
+func (s *seeder) deposit(ctx context.Context, id string, participants [2]seedParticipant) error {
+	for _, participant := range participants {
+		info, _ := domain.LookupAsset(participant.asset)
+		amount := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(info.Decimals)), nil)
+		err := s.run(s.app.Commands.AddDeposit.Handle(ctx, commands.AddDeposit{
+			PairId:             id,
+			ParticipantAddress: participant.address,
+			Asset:              participant.asset,
+			TxHash:             seedTxHash(id, participant.asset, "deposit"),
+			Amount:             amount.String(),
+			Decimals:           info.Decimals,
+		}))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// provideLiquidity pre-signs the withdrawal of the pair then adds the liquidity of both assets, starting the investing period
+func (s *seeder) provideLiquidity(ctx context.Context, id string, participants [2]seedParticipant) error {
+	err := s.run(s.app.Commands.SignWithdrawal.Handle(ctx, commands.SignWithdrawal{
+		PairId:             id,
+		ParticipantAddress: participants[0].address,
+		Tx:                 seedTx(id, domain.RuneAsset, seedWithdrawalNonce(domain.PlanTypeLP, domain.RuneAsset)),
+	}))
+	if err != nil {
+		return err
+	}
+
+	for _, participant := range participants {
+		err := s.run(s.app.Commands.SubmitLP.Handle(ctx, commands.SubmitLP{
+			PairId:             id,
+			ParticipantAddress: participant.address,
+			Asset:              participant.asset,
+			TxHash:             seedTxHash(id, participant.asset, "lp"),
+		}))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// depositIntoSavers pre-signs the withdrawals of both assets then deposits them into their vaults, starting the investing period
+func (s *seeder) depositIntoSavers(ctx context.Context, id string, participants [2]seedParticipant) error {
+	for _, participant := range participants {
+		err := s.run(s.app.Commands.SignSaversWithdrawal.Handle(ctx, commands.SignSaversWithdrawal{
+			PairId:             id,
+			ParticipantAddress: participant.address,
+			Asset:              participant.asset,
+			Tx:                 seedTx(id, participant.asset, seedWithdrawalNonce(domain.PlanTypeSavers, participant.asset)),
+		}))
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, participant := range participants {
+		err := s.run(s.app.Commands.SubmitSavers.Handle(ctx, commands.SubmitSavers{
+			PairId:             id,
+			ParticipantAddress: participant.address,
+			Asset:              participant.asset,
+			TxHash:             seedTxHash(id, participant.asset, "savers"),
+		}))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// withdraw has the participants agree to withdraw early, as the investing period just started, and submits the withdrawals
+func (s *seeder) withdraw(ctx context.Context, id string, participants [2]seedParticipant) error {
+	err := s.run(s.app.Commands.ProposeEarlyWithdrawal.Handle(ctx, commands.ProposeEarlyWithdrawal{
+		PairId:             id,
+		ParticipantAddress: participants[0].address,
+	}))
+	if err != nil {
+		return err
+	}
+	err = s.run(s.app.Commands.AcceptEarlyWithdrawal.Handle(ctx, commands.AcceptEarlyWithdrawal{
+		PairId:             id,
+		ParticipantAddress: participants[1].address,
+	}))
+	if err != nil {
+		return err
+	}
+
+	p, err := s.pair(ctx, id)
+	if err != nil {
+		return err
+	}
+	if p.PlanType != domain.PlanTypeSavers {
+		return s.run(s.app.Commands.SubmitWithdrawal.Handle(ctx, commands.SubmitWithdrawal{
+			PairId: id,
+			TxHash: seedTxHash(id, domain.RuneAsset, "withdrawal"),
+		}))
+	}
+
+	for _, participant := range participants {
+		err := s.run(s.app.Commands.SubmitSaversWithdrawal.Handle(ctx, commands.SubmitSaversWithdrawal{
+			PairId: id,
+			Asset:  participant.asset,
+			TxHash: seedTxHash(id, participant.asset, "withdrawal"),
+		}))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func indexOfStatus(statuses []domain.PairStatus, status domain.PairStatus) int {
+	for i, s := range statuses {
+		if s == status {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,529 @@
+package client
+
+import (
+	"context"
+	"crypto/ed25519"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/co-defi/api-server/app/commands"
+	"github.com/co-defi/api-server/app/queries"
+	"github.com/co-defi/api-server/app/relay"
+	"github.com/co-defi/api-server/domain"
+)
+
+// Plan is a plan as served by the API
+type Plan struct {
+	Id                  string                   `json:"id"`
+	Name                string                   `json:"name"`
+	Assets              []domain.Asset           `json:"assets"`
+	Security            string                   `json:"security"`
+	Strategy            string                   `json:"strategy"`
+	Quantum             int                      `json:"quantum"`
+	LossProtection      float64                  `json:"loss_protection"`
+	InvestingPeriod     int                      `json:"time_frame"`
+	InvestingPeriodUnit domain.PeriodUnit        `json:"time_frame_unit"`
+	GracePeriodDays     int                      `json:"grace_period_days"`
+	MaxShareMultiplier  int                      `json:"max_share_multiplier"`
+	Network             domain.Network           `json:"network"`
+	MaxActivePairs      int                      `json:"max_active_pairs,omitempty"`
+	ActivePairs         int                      `json:"active_pairs"`
+	ActiveFrom          *time.Time               `json:"active_from,omitempty"`
+	ActiveUntil         *time.Time               `json:"active_until,omitempty"`
+	Availability        queries.PlanAvailability `json:"availability"`
+	APR                 float64                  `json:"APR"`
+	WaitingPairs        map[domain.Asset]int     `json:"waiting_pairs"`
+	AverageMatchSeconds float64                  `json:"average_match_seconds"`
+	CompletedPairs      int                      `json:"completed_pairs"`
+}
+
+// ServerKey is the public key the server signs the responses of the pair state routes with
+type ServerKey struct {
+	KeyId         string            `json:"key_id"`
+	Algorithm     string            `json:"algorithm"`
+	PublicKey     ed25519.PublicKey `json:"public_key"`
+	SignedHeaders []string          `json:"signed_headers"`
+}
+
+// ServerKey returns the public key of the server to verify its responses with, see WithServerKey.
+// It should be pinned out of band rather than trusted as fetched over the connection it guards.
+func (c *Client) ServerKey(ctx context.Context) (*ServerKey, error) {
+	return get[*ServerKey](ctx, c, "/server-key", nil)
+}
+
+// Assets returns the assets supported in the plans and pairs
+func (c *Client) Assets(ctx context.Context) ([]domain.AssetInfo, error) {
+	return get[[]domain.AssetInfo](ctx, c, "/assets", nil)
+}
+
+// Fees returns the current fee estimate of the chain, e.g. ETH
+func (c *Client) Fees(ctx context.Context, chain string) (*commands.FeeEstimate, error) {
+	return get[*commands.FeeEstimate](ctx, c, "/fees", url.Values{"chain": {chain}})
+}
+
+// PlansFilter narrows down and orders the plans, zero fields don't constrain them
+type PlansFilter struct {
+	// Network is the network of the plans, the default one when empty
+	Network             domain.Network
+	Asset               domain.Asset
+	MinQuantum          int
+	MaxQuantum          int
+	InvestingPeriod     int
+	InvestingPeriodUnit domain.PeriodUnit
+	Security            domain.MultiSigWalletSecurity
+	SortBy              queries.PlanSort
+	Descending          bool
+}
+
+// Plans returns the plans matching the filter
+func (c *Client) Plans(ctx context.Context, f PlansFilter) ([]Plan, error) {
+	query := url.Values{}
+	if f.Network != "" {
+		query.Set("network", string(f.Network))
+	}
+	if f.Asset != "" {
+		query.Set("asset", string(f.Asset))
+	}
+	if f.MinQuantum > 0 {
+		query.Set("min_quantum", strconv.Itoa(f.MinQuantum))
+	}
+	if f.MaxQuantum > 0 {
+		query.Set("max_quantum", strconv.Itoa(f.MaxQuantum))
+	}
+	if f.InvestingPeriod > 0 {
+		query.Set("time_frame", strconv.Itoa(f.InvestingPeriod))
+	}
+	if f.InvestingPeriodUnit != "" {
+		query.Set("time_frame_unit", string(f.InvestingPeriodUnit))
+	}
+	if f.Security != "" {
+		query.Set("security", string(f.Security))
+	}
+	if f.SortBy != "" {
+		query.Set("sort", string(f.SortBy))
+	}
+	if f.Descending {
+		query.Set("order", "desc")
+	}
+
+	return get[[]Plan](ctx, c, "/plans", query)
+}
+
+// Plan returns the plan
+func (c *Client) Plan(ctx context.Context, planId string) (*Plan, error) {
+	return get[*Plan](ctx, c, "/plan/"+url.PathEscape(planId), nil)
+}
+
+// CreateOrMatchPairRequest is the request of a participant to join a plan with their asset
+type CreateOrMatchPairRequest struct {
+	PlanId           string       `json:"plan_id"`
+	ParticipantAsset domain.Asset `json:"participant_asset"`
+	ShareMultiplier  int          `json:"share_multiplier,omitempty"`
+}
+
+type createOrMatchPairResponse struct {
+	Id string `json:"id"`
+}
+
+// CreateOrMatchPair matches the participant with a waiting pair of the plan, or creates a new one, and returns the id of the pair
+func (c *Client) CreateOrMatchPair(ctx context.Context, req CreateOrMatchPairRequest) (string, error) {
+	var res createOrMatchPairResponse
+	if err := c.do(ctx, http.MethodPost, "/pairs", nil, req, &res); err != nil {
+		return "", err
+	}
+
+	return res.Id, nil
+}
+
+// Pair returns the pair
+func (c *Client) Pair(ctx context.Context, pairId string) (*queries.Pair, error) {
+	return getSigned[*queries.Pair](ctx, c, pairPath(pairId, ""), nil)
+}
+
+// WaitForPair long-polls the pair until its version advances past sinceVersion and returns its new state,
+// it returns ErrNotModified when timeout elapses first. The server waits 60 seconds at most.
+func (c *Client) WaitForPair(ctx context.Context, pairId string, sinceVersion int, timeout time.Duration) (*queries.Pair, error) {
+	query := url.Values{"since_version": {strconv.Itoa(sinceVersion)}}
+	if timeout > 0 {
+		query.Set("timeout", strconv.Itoa(int(timeout.Seconds())))
+	}
+
+	return getSigned[*queries.Pair](ctx, c, pairPath(pairId, "/wait"), query)
+}
+
+// PairsFilter narrows down the pairs of the participant, zero fields don't constrain them
+type PairsFilter struct {
+	PlanId          string
+	Status          domain.PairStatus
+	Asset           domain.Asset
+	CreatedAfter    time.Time
+	CreatedBefore   time.Time
+	IncludeArchived bool
+}
+
+// Pairs returns the pairs of the participant matching the filter
+func (c *Client) Pairs(ctx context.Context, f PairsFilter) ([]queries.Pair, error) {
+	query := url.Values{}
+	if f.PlanId != "" {
+		query.Set("plan_id", f.PlanId)
+	}
+	if f.Status != "" {
+		query.Set("status", string(f.Status))
+	}
+	if f.Asset != "" {
+		query.Set("asset", string(f.Asset))
+	}
+	if !f.CreatedAfter.IsZero() {
+		query.Set("created_after", f.CreatedAfter.Format(time.RFC3339))
+	}
+	if !f.CreatedBefore.IsZero() {
+		query.Set("created_before", f.CreatedBefore.Format(time.RFC3339))
+	}
+	if f.IncludeArchived {
+		query.Set("include_archived", "true")
+	}
+
+	return get[[]queries.Pair](ctx, c, "/pairs", query)
+}
+
+// Settlement returns the settlement report of the pair's withdrawal
+func (c *Client) Settlement(ctx context.Context, pairId string) (*domain.SettlementReport, error) {
+	return getSigned[*domain.SettlementReport](ctx, c, pairPath(pairId, "/settlement"), nil)
+}
+
+// Position returns the valuation of the liquidity the pair provides
+func (c *Client) Position(ctx context.Context, pairId string) (*queries.Position, error) {
+	return get[*queries.Position](ctx, c, pairPath(pairId, "/position"), nil)
+}
+
+type confirmWalletRequest struct {
+	ParticipantPublicKey string                          `json:"participant_public_key"`
+	WalletAddresses      map[domain.Asset]domain.Address `json:"wallet_addresses"`
+}
+
+// ConfirmWallet confirms the addresses of the shared wallet the participants generated, along with the public key of the participant
+func (c *Client) ConfirmWallet(ctx context.Context, pairId, publicKey string, addresses map[domain.Asset]domain.Address) error {
+	return c.do(ctx, http.MethodPost, pairPath(pairId, "/confirm-wallet"), nil, confirmWalletRequest{
+		ParticipantPublicKey: publicKey,
+		WalletAddresses:      addresses,
+	}, nil)
+}
+
+type setAssurancesRequest struct {
+	Asset      domain.Asset      `json:"asset"`
+	Assurances []domain.SignedTx `json:"assurances"`
+}
+
+// SetAssurances sets the transactions pre-signed by the participant refunding the counterparty's asset
+func (c *Client) SetAssurances(ctx context.Context, pairId string, asset domain.Asset, assurances []domain.SignedTx) error {
+	return c.do(ctx, http.MethodPost, pairPath(pairId, "/assurances"), nil, setAssurancesRequest{Asset: asset, Assurances: assurances}, nil)
+}
+
+// AssurancesAcknowledgement is the message the participant signs to acknowledge the assurances refunding their asset
+type AssurancesAcknowledgement struct {
+	Asset   domain.Asset `json:"asset"`
+	Digest  string       `json:"digest"`
+	Message string       `json:"message"`
+}
+
+// AssurancesAcknowledgement returns the message the participant signs to confirm the assurances refunding their asset
+func (c *Client) AssurancesAcknowledgement(ctx context.Context, pairId string) (*AssurancesAcknowledgement, error) {
+	return get[*AssurancesAcknowledgement](ctx, c, pairPath(pairId, "/assurances/acknowledgement"), nil)
+}
+
+type confirmAssurancesRequest struct {
+	Signature []byte `json:"signature"`
+}
+
+// ConfirmAssurances confirms the assurances with the participant's signature of their acknowledgement message
+func (c *Client) ConfirmAssurances(ctx context.Context, pairId string, signature []byte) error {
+	return c.do(ctx, http.MethodPost, pairPath(pairId, "/confirm-assurances"), nil, confirmAssurancesRequest{Signature: signature}, nil)
+}
+
+// BatchCommand is a command executed on a pair within a batch, see Batch
+type BatchCommand struct {
+	Type    string `json:"type"`
+	Payload any    `json:"payload"`
+}
+
+// ConfirmWalletCommand is the batch command of ConfirmWallet
+func ConfirmWalletCommand(publicKey string, addresses map[domain.Asset]domain.Address) BatchCommand {
+	return BatchCommand{Type: "confirm_wallet", Payload: confirmWalletRequest{ParticipantPublicKey: publicKey, WalletAddresses: addresses}}
+}
+
+// SetAssurancesCommand is the batch command of SetAssurances
+func SetAssurancesCommand(asset domain.Asset, assurances []domain.SignedTx) BatchCommand {
+	return BatchCommand{Type: "set_assurances", Payload: setAssurancesRequest{Asset: asset, Assurances: assurances}}
+}
+
+// ConfirmAssurancesCommand is the batch command of ConfirmAssurances
+func ConfirmAssurancesCommand(signature []byte) BatchCommand {
+	return BatchCommand{Type: "confirm_assurances", Payload: confirmAssurancesRequest{Signature: signature}}
+}
+
+// BatchResult is the result of a command of a batch
+type BatchResult struct {
+	Index  int    `json:"index"`
+	Type   string `json:"type"`
+	Status string `json:"status"`
+}
+
+type batchRequest struct {
+	Commands []BatchCommand `json:"commands"`
+}
+
+type batchResponse struct {
+	PairId  string        `json:"pair_id"`
+	Results []BatchResult `json:"results"`
+}
+
+// Batch executes the commands on the pair in a single round trip, either all of them are applied or none is.
+// The error of the failing command tells its index in the command_index of its meta.
+func (c *Client) Batch(ctx context.Context, pairId string, commands ...BatchCommand) ([]BatchResult, error) {
+	var resp batchResponse
+	if err := c.do(ctx, http.MethodPost, pairPath(pairId, "/batch"), nil, batchRequest{Commands: commands}, &resp); err != nil {
+		return nil, err
+	}
+
+	return resp.Results, nil
+}
+
+// Deposit is the transfer of the participant's asset into the shared wallet
+type Deposit struct {
+	Asset  domain.Asset  `json:"asset"`
+	TxHash domain.TxHash `json:"tx_hash"`
+	// Amount is the deposited amount in the base units of the asset, e.g. wei
+	Amount   string `json:"amount"`
+	Decimals int    `json:"decimals"`
+}
+
+// AddDeposit records the deposit of the participant
+func (c *Client) AddDeposit(ctx context.Context, pairId string, deposit Deposit) error {
+	return c.do(ctx, http.MethodPost, pairPath(pairId, "/deposits"), nil, deposit, nil)
+}
+
+type signWithdrawalRequest struct {
+	Tx domain.SignedTx `json:"tx"`
+}
+
+// SignWithdrawal records the withdrawal transaction signed by the participants
+func (c *Client) SignWithdrawal(ctx context.Context, pairId string, tx domain.SignedTx) error {
+	return c.do(ctx, http.MethodPost, pairPath(pairId, "/sign-withdraw"), nil, signWithdrawalRequest{Tx: tx}, nil)
+}
+
+type submitLPRequest struct {
+	Asset  domain.Asset  `json:"asset"`
+	TxHash domain.TxHash `json:"tx_hash"`
+}
+
+// SubmitLP records the transaction adding the asset to the pool
+func (c *Client) SubmitLP(ctx context.Context, pairId string, asset domain.Asset, txHash domain.TxHash) error {
+	return c.do(ctx, http.MethodPost, pairPath(pairId, "/submit-lp"), nil, submitLPRequest{Asset: asset, TxHash: txHash}, nil)
+}
+
+type submitWithdrawalRequest struct {
+	TxHash domain.TxHash `json:"tx_hash"`
+}
+
+// SubmitWithdrawal records the transaction withdrawing the liquidity of the pair
+func (c *Client) SubmitWithdrawal(ctx context.Context, pairId string, txHash domain.TxHash) error {
+	return c.do(ctx, http.MethodPost, pairPath(pairId, "/submit-withdrawal"), nil, submitWithdrawalRequest{TxHash: txHash}, nil)
+}
+
+type requestRefundRequest struct {
+	Broadcast bool `json:"broadcast,omitempty"`
+}
+
+// RequestRefund releases the assurances refunding the participant's deposit when the counterparty never deposited,
+// with broadcast the server broadcasts the refund itself
+func (c *Client) RequestRefund(ctx context.Context, pairId string, broadcast bool) error {
+	return c.do(ctx, http.MethodPost, pairPath(pairId, "/refund"), nil, requestRefundRequest{Broadcast: broadcast}, nil)
+}
+
+type proposeEarlyWithdrawalRequest struct {
+	Reason string `json:"reason,omitempty"`
+}
+
+// ProposeEarlyWithdrawal proposes the counterparty to withdraw before the deadline
+func (c *Client) ProposeEarlyWithdrawal(ctx context.Context, pairId, reason string) error {
+	return c.do(ctx, http.MethodPost, pairPath(pairId, "/early-withdrawal"), nil, proposeEarlyWithdrawalRequest{Reason: reason}, nil)
+}
+
+// AcceptEarlyWithdrawal accepts the counterparty's proposal to withdraw before the deadline
+func (c *Client) AcceptEarlyWithdrawal(ctx context.Context, pairId string) error {
+	return c.do(ctx, http.MethodPost, pairPath(pairId, "/early-withdrawal/accept"), nil, struct{}{}, nil)
+}
+
+// Extension is the proposal to extend the investing period of a pair
+type Extension struct {
+	InvestingPeriod     int               `json:"investing_period"`
+	InvestingPeriodUnit domain.PeriodUnit `json:"investing_period_unit,omitempty"`
+	// Rollover starts a new investing period of that length instead of adding it to the deadline
+	Rollover bool `json:"rollover,omitempty"`
+}
+
+// ProposeExtension proposes the counterparty to extend the investing period
+func (c *Client) ProposeExtension(ctx context.Context, pairId string, extension Extension) error {
+	return c.do(ctx, http.MethodPost, pairPath(pairId, "/extension"), nil, extension, nil)
+}
+
+// AcceptExtension accepts the counterparty's proposal to extend the investing period
+func (c *Client) AcceptExtension(ctx context.Context, pairId string) error {
+	return c.do(ctx, http.MethodPost, pairPath(pairId, "/extension/accept"), nil, struct{}{}, nil)
+}
+
+type postRelayMessageRequest struct {
+	Kind    relay.MessageKind `json:"kind"`
+	Round   string            `json:"round"`
+	Payload []byte            `json:"payload"`
+}
+
+type postRelayMessageResponse struct {
+	Id int64 `json:"id"`
+}
+
+// PostRelayMessage relays a keygen or keysign round to the counterparty and returns the id of the message
+func (c *Client) PostRelayMessage(ctx context.Context, pairId string, kind relay.MessageKind, round string, payload []byte) (int64, error) {
+	var res postRelayMessageResponse
+	if err := c.do(ctx, http.MethodPost, pairPath(pairId, "/relay"), nil, postRelayMessageRequest{
+		Kind:    kind,
+		Round:   round,
+		Payload: payload,
+	}, &res); err != nil {
+		return 0, err
+	}
+
+	return res.Id, nil
+}
+
+// RelayMessages returns the messages the counterparty relayed after the message id, up to limit when positive
+func (c *Client) RelayMessages(ctx context.Context, pairId string, after int64, limit int) ([]relay.Message, error) {
+	query := url.Values{"after": {strconv.FormatInt(after, 10)}}
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+
+	return get[[]relay.Message](ctx, c, pairPath(pairId, "/relay"), query)
+}
+
+type postPairMessageRequest struct {
+	Body string `json:"body"`
+}
+
+type postPairMessageResponse struct {
+	Seq int `json:"seq"`
+}
+
+// PostPairMessage posts a message to the counterparty of the pair and returns its sequence number in the chat of the pair
+func (c *Client) PostPairMessage(ctx context.Context, pairId, body string) (int, error) {
+	var res postPairMessageResponse
+	if err := c.do(ctx, http.MethodPost, pairPath(pairId, "/messages"), nil, postPairMessageRequest{Body: body}, &res); err != nil {
+		return 0, err
+	}
+
+	return res.Seq, nil
+}
+
+// PairMessages returns the messages of the chat of the pair after the sequence number, up to limit when positive
+func (c *Client) PairMessages(ctx context.Context, pairId string, after, limit int) ([]queries.PairMessage, error) {
+	query := url.Values{"after": {strconv.Itoa(after)}}
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+
+	return get[[]queries.PairMessage](ctx, c, pairPath(pairId, "/messages"), query)
+}
+
+// Reputation returns the reputation of the participant
+func (c *Client) Reputation(ctx context.Context, address domain.Address) (*queries.Reputation, error) {
+	return get[*queries.Reputation](ctx, c, "/participants/"+url.PathEscape(address)+"/reputation", nil)
+}
+
+// Stats returns the statistics of the platform on the network over the last days, the defaults of the API apply to the zero values
+func (c *Client) Stats(ctx context.Context, network domain.Network, days int) (*queries.Stats, error) {
+	query := url.Values{}
+	if network != "" {
+		query.Set("network", string(network))
+	}
+	if days > 0 {
+		query.Set("days", strconv.Itoa(days))
+	}
+
+	return get[*queries.Stats](ctx, c, "/stats", query)
+}
+
+// Me is the profile of the participant, with the reputation and notification settings of the address they authenticated with
+type Me struct {
+	queries.Participant
+	Reputation    *queries.Reputation           `json:"reputation"`
+	Notifications *queries.NotificationSettings `json:"notifications"`
+}
+
+// Me returns the profile of the participant
+func (c *Client) Me(ctx context.Context) (*Me, error) {
+	return get[*Me](ctx, c, "/me", nil)
+}
+
+type linkAddressRequest struct {
+	Token string `json:"token"`
+}
+
+type linkAddressResponse struct {
+	ParticipantId string `json:"participant_id"`
+}
+
+// LinkAddress adds the address of the token, authenticated separately, to the addresses of the participant
+// and returns the id of the participant
+func (c *Client) LinkAddress(ctx context.Context, token string) (string, error) {
+	var res linkAddressResponse
+	if err := c.do(ctx, http.MethodPost, "/me/addresses", nil, linkAddressRequest{Token: token}, &res); err != nil {
+		return "", err
+	}
+
+	return res.ParticipantId, nil
+}
+
+// NotificationSettings returns the notification settings of the participant
+func (c *Client) NotificationSettings(ctx context.Context) (*queries.NotificationSettings, error) {
+	return get[*queries.NotificationSettings](ctx, c, "/me/notifications", nil)
+}
+
+// NotificationSettingsUpdate is the channels and events the participant is notified through and about
+type NotificationSettingsUpdate struct {
+	Email     string                     `json:"email,omitempty"`
+	PushToken string                     `json:"push_token,omitempty"`
+	Events    []domain.NotificationEvent `json:"events,omitempty"`
+}
+
+// UpdateNotificationSettings replaces the notification settings of the participant
+func (c *Client) UpdateNotificationSettings(ctx context.Context, settings NotificationSettingsUpdate) error {
+	return c.do(ctx, http.MethodPut, "/me/notifications", nil, settings, nil)
+}
+
+// get queries the resource at path and decodes it as T
+func get[T any](ctx context.Context, c *Client, path string, query url.Values) (T, error) {
+	var out T
+	if err := c.do(ctx, http.MethodGet, path, query, nil, &out); err != nil {
+		var zero T
+		return zero, err
+	}
+
+	return out, nil
+}
+
+// getSigned queries the resource at path as get does, verifying the signature of the response when the client has the server key
+func getSigned[T any](ctx context.Context, c *Client, path string, query url.Values) (T, error) {
+	var out T
+	if err := c.request(ctx, http.MethodGet, path, query, nil, &out, true); err != nil {
+		var zero T
+		return zero, err
+	}
+
+	return out, nil
+}
+
+func pairPath(pairId, suffix string) string {
+	return "/pairs/" + url.PathEscape(pairId) + suffix
+}
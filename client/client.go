@@ -0,0 +1,381 @@
+// Package client is a Go client of the co-defi API, for the wallet backends and the integration tests to drive pairs
+// through their lifecycle without hand-rolling the HTTP calls.
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/co-defi/api-server/common"
+	"github.com/co-defi/api-server/domain"
+)
+
+// Client calls the co-defi API on behalf of a participant. It's safe for concurrent use once authenticated.
+type Client struct {
+	baseURL    string
+	version    string
+	httpClient *http.Client
+	retries    int
+	backoff    time.Duration
+	serverKey  ed25519.PublicKey
+	apiKey     string
+	msgpack    bool
+
+	mu    sync.RWMutex
+	token string
+}
+
+// Option configures the Client
+type Option func(*Client)
+
+// WithHTTPClient sends the requests with the given HTTP client
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithRetries retries the idempotent requests failing with a network error, 429 or a 5xx status up to retries times,
+// waiting backoff before the first retry and doubling it after each one unless the server tells how long to wait
+func WithRetries(retries int, backoff time.Duration) Option {
+	return func(c *Client) {
+		c.retries = retries
+		c.backoff = backoff
+	}
+}
+
+// WithToken authenticates the requests with a token obtained beforehand, either a token id or a JWT
+func WithToken(token string) Option {
+	return func(c *Client) {
+		c.token = token
+	}
+}
+
+// WithAPIKey authenticates the requests with an API key issued by the operators in place of a token,
+// for the bots which can't sign the authentication challenges
+func WithAPIKey(key string) Option {
+	return func(c *Client) {
+		c.apiKey = key
+	}
+}
+
+// WithMsgpack asks for the responses encoded with MessagePack, smaller than JSON for the clients polling the pairs often.
+// The routes only serving JSON keep responding with JSON.
+func WithMsgpack() Option {
+	return func(c *Client) {
+		c.msgpack = true
+	}
+}
+
+// WithServerKey verifies the signed responses of the pair state routes with the public key of the server, see Client.ServerKey.
+// Their unsigned or tampered responses then fail with common.ErrInvalidSignature.
+func WithServerKey(pub ed25519.PublicKey) Option {
+	return func(c *Client) {
+		c.serverKey = pub
+	}
+}
+
+// WithAPIVersion calls the given version of the API, e.g. v1
+func WithAPIVersion(version string) Option {
+	return func(c *Client) {
+		c.version = version
+	}
+}
+
+const (
+	defaultAPIVersion = "v1"
+	defaultRetries    = 3
+	defaultBackoff    = 500 * time.Millisecond
+	// errorBodyLimit is the most of an error response read to decode it
+	errorBodyLimit = 64 << 10
+)
+
+// New creates a new Client for the API served at baseURL, e.g. https://api.co-defi.finance
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		version:    defaultAPIVersion,
+		httpClient: &http.Client{Timeout: 90 * time.Second},
+		retries:    defaultRetries,
+		backoff:    defaultBackoff,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+// Token returns the token the requests are authenticated with, empty until authenticated
+func (c *Client) Token() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.token
+}
+
+// SetToken sets the token the requests are authenticated with
+func (c *Client) SetToken(token string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.token = token
+}
+
+// SignFunc signs the authentication challenge with the key of the participant, as their wallet signs messages on the chain
+type SignFunc func(challenge string) ([]byte, error)
+
+type initAuthRequest struct {
+	Chain   common.Chain   `json:"chain"`
+	PubKey  []byte         `json:"pub_key"`
+	Network domain.Network `json:"network,omitempty"`
+}
+
+type verifyAuthRequest struct {
+	Id        string `json:"id"`
+	Signature []byte `json:"signature"`
+}
+
+type verifyAuthResponse struct {
+	AccessToken string `json:"access_token"`
+}
+
+// Authenticate proves the participant owns the key by signing the challenge issued by the API, the following requests are
+// authenticated with the resulting token. network restricts the token to a network, the default one when empty.
+func (c *Client) Authenticate(ctx context.Context, chain common.Chain, pubKey []byte, network domain.Network, sign SignFunc) error {
+	var token common.Token
+	if err := c.do(ctx, http.MethodPost, "/auth/init", nil, initAuthRequest{Chain: chain, PubKey: pubKey, Network: network}, &token); err != nil {
+		return err
+	}
+
+	signature, err := sign(token.Challenge)
+	if err != nil {
+		return fmt.Errorf("failed to sign challenge: %w", err)
+	}
+
+	// The server responds with a JWT in the stateless mode, the token id is used otherwise
+	var verified verifyAuthResponse
+	if err := c.do(ctx, http.MethodPost, "/auth/verify", nil, verifyAuthRequest{Id: token.Id.String(), Signature: signature}, &verified); err != nil {
+		return err
+	}
+	if verified.AccessToken != "" {
+		c.SetToken(verified.AccessToken)
+	} else {
+		c.SetToken(token.Id.String())
+	}
+
+	return nil
+}
+
+// ErrNotModified is returned by the long-polls when the resource didn't change within the timeout
+var ErrNotModified = errors.New("not modified")
+
+// do sends the request with the body encoded as JSON and decodes the response into out, when not nil.
+// The API errors are returned as *common.Error.
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body, out any) error {
+	return c.request(ctx, method, path, query, body, out, false)
+}
+
+// request sends the request as do does, verifying the signature of the response with the server key when signed is set
+func (c *Client) request(ctx context.Context, method, path string, query url.Values, body, out any, signed bool) error {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+	}
+
+	target := c.baseURL + "/" + c.version + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	backoff := c.backoff
+	for attempt := 0; ; attempt++ {
+		res, err := c.send(ctx, method, target, payload)
+		retry, wait := c.shouldRetry(method, attempt, res, err)
+		if !retry {
+			if err != nil {
+				return err
+			}
+			var verify func(*http.Response, []byte) error
+			if signed && c.serverKey != nil {
+				verify = c.verifySignature
+			}
+			return decodeResponse(res, out, verify)
+		}
+		if res != nil {
+			res.Body.Close()
+		}
+
+		if wait == 0 {
+			wait = backoff
+			backoff *= 2
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+func (c *Client) send(ctx context.Context, method, target string, payload []byte) (*http.Response, error) {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.msgpack {
+		req.Header.Set("Accept", common.MIMEApplicationMsgpack+", application/json;q=0.9")
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token := c.Token(); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	if c.apiKey != "" {
+		req.Header.Set(common.HeaderAPIKey, c.apiKey)
+	}
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call %s %s: %w", method, req.URL.Path, err)
+	}
+
+	return res, nil
+}
+
+// shouldRetry tells if the attempt is retried and how long to wait before, as told by the Retry-After header if any.
+// The failures of the commands are only retried when they were rate limited, they could otherwise be applied twice.
+func (c *Client) shouldRetry(method string, attempt int, res *http.Response, err error) (bool, time.Duration) {
+	if attempt >= c.retries {
+		return false, 0
+	}
+	limited := err == nil && res.StatusCode == http.StatusTooManyRequests
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete:
+	default:
+		if !limited {
+			return false, 0
+		}
+	}
+
+	if err != nil {
+		return true, 0
+	}
+	if !limited && res.StatusCode < http.StatusInternalServerError {
+		return false, 0
+	}
+	if seconds, err := strconv.Atoi(res.Header.Get("Retry-After")); err == nil {
+		return true, time.Duration(seconds) * time.Second
+	}
+
+	return true, 0
+}
+
+// verifySignature verifies the signature of the response body with the server key
+func (c *Client) verifySignature(res *http.Response, body []byte) error {
+	signature := res.Header.Get(common.HeaderSignature)
+	if signature == "" {
+		return common.ErrInvalidSignature
+	}
+
+	return common.VerifyResponse(c.serverKey, res.Request.URL.Path, res.Header.Get(common.HeaderSignatureTimestamp), body, signature)
+}
+
+// decodeResponse decodes the successful responses into out and the failed ones into a *common.Error,
+// the body of the successful ones is checked with verify first when not nil
+func decodeResponse(res *http.Response, out any, verify func(*http.Response, []byte) error) error {
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotModified {
+		return ErrNotModified
+	}
+	if res.StatusCode >= http.StatusBadRequest {
+		return decodeError(res)
+	}
+	if out == nil || res.StatusCode == http.StatusNoContent {
+		return nil
+	}
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+	if verify != nil {
+		if err := verify(res, body); err != nil {
+			return err
+		}
+	}
+	// The commands respond without a body
+	if len(bytes.TrimSpace(body)) == 0 {
+		return nil
+	}
+	unmarshal := json.Unmarshal
+	if strings.HasPrefix(res.Header.Get("Content-Type"), common.MIMEApplicationMsgpack) {
+		unmarshal = common.UnmarshalMsgpack
+	}
+	if err := unmarshal(body, out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return nil
+}
+
+// decodeError decodes the error body of the API, the responses without one (e.g. from a proxy) get a generic error of their status
+func decodeError(res *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(res.Body, errorBodyLimit))
+
+	var apiErr common.Error
+	if err := json.Unmarshal(body, &apiErr); err != nil || apiErr.Code == "" {
+		apiErr = common.Error{Code: codeOfStatus(res.StatusCode), Message: strings.TrimSpace(res.Status)}
+	}
+
+	return &apiErr
+}
+
+// codeOfStatus returns the generic error code of the API for an HTTP status
+func codeOfStatus(status int) string {
+	switch {
+	case status == http.StatusUnauthorized:
+		return "auth_failed"
+	case status == http.StatusForbidden:
+		return "forbidden"
+	case status == http.StatusNotFound:
+		return "route_not_found"
+	case status == http.StatusRequestEntityTooLarge:
+		return "request_too_large"
+	case status < http.StatusInternalServerError:
+		return "invalid_request"
+	}
+
+	return "internal_error"
+}
+
+// ErrorCode returns the code of the API error, empty when err isn't one
+func ErrorCode(err error) string {
+	var apiErr *common.Error
+	if errors.As(err, &apiErr) {
+		return apiErr.Code
+	}
+
+	return ""
+}
@@ -1,7 +1,10 @@
 package cmd
 
 import (
+	"fmt"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/co-defi/api-server/app"
 	"github.com/co-defi/api-server/app/commands"
@@ -26,24 +29,63 @@ to quickly create a Cobra application.`,
 		}
 		defer db.Close()
 
-		app, err := app.NewApplication(db, logger)
+		cipher, err := prepareCipher(cmd.Context(), cmd.Flags())
+		if err != nil {
+			logger.Fatal().Err(err).Msg("failed to prepare encryption")
+		}
+
+		app, err := app.NewApplication(db, logger, app.WithEncryption(cipher))
 		if err != nil {
 			logger.Fatal().Err(err).Msg("failed to create application instance")
 		}
 
+		planType, _ := cmd.Flags().GetString("type")
 		assets, _ := cmd.Flags().GetString("assets")
 		security, _ := cmd.Flags().GetString("security")
 		strategy, _ := cmd.Flags().GetString("strategy")
 		quantum, _ := cmd.Flags().GetInt("quantum")
 		LossProtection, _ := cmd.Flags().GetFloat64("loss-limit")
 		investingPeriod, _ := cmd.Flags().GetInt("investing-period")
+		periodUnit, _ := cmd.Flags().GetString("period-unit")
+		graceDays, _ := cmd.Flags().GetInt("grace-days")
+		maxShareMultiplier, _ := cmd.Flags().GetInt("max-share-multiplier")
+		network, _ := cmd.Flags().GetString("network")
+		maxActivePairs, _ := cmd.Flags().GetInt("max-active-pairs")
+		activeFrom, err := parseOptionalTime(cmd.Flags().GetString("active-from"))
+		if err != nil {
+			logger.Fatal().Err(err).Msg("invalid active-from time")
+		}
+		activeUntil, err := parseOptionalTime(cmd.Flags().GetString("active-until"))
+		if err != nil {
+			logger.Fatal().Err(err).Msg("invalid active-until time")
+		}
+		statusTimeouts, err := parseStatusTimeouts(cmd.Flags().GetStringToString("status-timeouts"))
+		if err != nil {
+			logger.Fatal().Err(err).Msg("invalid status timeouts")
+		}
+		assuranceNonces, _ := cmd.Flags().GetStringToString("assurance-nonces")
+		assuranceMaxTxs, _ := cmd.Flags().GetStringToInt("assurance-max-txs")
+		assurancePolicies, err := parseAssurancePolicies(domain.PlanType(planType), assuranceNonces, assuranceMaxTxs)
+		if err != nil {
+			logger.Fatal().Err(err).Msg("invalid assurance policies")
+		}
 		id, err := app.Commands.CreateNewPlan.Handle(cmd.Context(), commands.CreateNewPlan{
-			Assets:          stringsToAssets(strings.Split(assets, ",")),
-			Security:        domain.MultiSigWalletSecurity(security),
-			Strategy:        domain.ProfitSharingStrategy(strategy),
-			Quantum:         quantum,
-			LossProtection:  LossProtection,
-			InvestingPeriod: investingPeriod,
+			Type:                domain.PlanType(planType),
+			Assets:              stringsToAssets(strings.Split(assets, ",")),
+			Security:            domain.MultiSigWalletSecurity(security),
+			Strategy:            domain.ProfitSharingStrategy(strategy),
+			Quantum:             quantum,
+			LossProtection:      LossProtection,
+			InvestingPeriod:     investingPeriod,
+			InvestingPeriodUnit: domain.PeriodUnit(periodUnit),
+			GracePeriodDays:     graceDays,
+			MaxShareMultiplier:  maxShareMultiplier,
+			Network:             domain.Network(network),
+			MaxActivePairs:      maxActivePairs,
+			ActiveFrom:          activeFrom,
+			ActiveUntil:         activeUntil,
+			StatusTimeouts:      statusTimeouts,
+			AssurancePolicies:   assurancePolicies,
 		})
 		if err != nil {
 			logger.Fatal().Err(err).Msg("failed to create new plan")
@@ -53,6 +95,61 @@ to quickly create a Cobra application.`,
 	},
 }
 
+// parseOptionalTime parses the RFC3339 time of a flag, an empty flag is the zero time
+func parseOptionalTime(value string, err error) (time.Time, error) {
+	if err != nil || value == "" {
+		return time.Time{}, err
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// parseStatusTimeouts parses the timeouts of a flag by status, e.g. deposit=48h
+func parseStatusTimeouts(values map[string]string, err error) (map[domain.PairStatus]domain.Duration, error) {
+	if err != nil || len(values) == 0 {
+		return nil, err
+	}
+
+	timeouts := make(map[domain.PairStatus]domain.Duration, len(values))
+	for status, value := range values {
+		timeout, err := time.ParseDuration(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timeout of %s: %w", status, err)
+		}
+		timeouts[domain.PairStatus(status)] = domain.Duration(timeout)
+	}
+	return timeouts, nil
+}
+
+// parseAssurancePolicies parses the policies of the flags by chain, the nonces are separated by colons, e.g. THOR=0:2:4.
+// The chains only given a maximum number of transactions keep the default nonces.
+func parseAssurancePolicies(planType domain.PlanType, nonces map[string]string, maxTxs map[string]int) (map[string]domain.AssurancePolicy, error) {
+	if len(nonces) == 0 && len(maxTxs) == 0 {
+		return nil, nil
+	}
+
+	policies := make(map[string]domain.AssurancePolicy, len(nonces))
+	for chain, value := range nonces {
+		policy := domain.AssurancePolicy{}
+		for _, s := range strings.Split(value, ":") {
+			nonce, err := strconv.Atoi(s)
+			if err != nil {
+				return nil, fmt.Errorf("invalid assurance nonce of %s: %w", chain, err)
+			}
+			policy.Nonces = append(policy.Nonces, nonce)
+		}
+		policies[chain] = policy
+	}
+	for chain, limit := range maxTxs {
+		policy, ok := policies[chain]
+		if !ok {
+			policy = domain.DefaultAssurancePolicy(planType, chain)
+		}
+		policy.MaxTxs = limit
+		policies[chain] = policy
+	}
+	return policies, nil
+}
+
 func stringsToAssets(strs []string) []domain.Asset {
 	assets := make([]domain.Asset, len(strs))
 	for i, s := range strs {
@@ -64,10 +161,21 @@ func stringsToAssets(strs []string) []domain.Asset {
 func init() {
 	rootCmd.AddCommand(addPlanCmd)
 
+	addPlanCmd.Flags().String("type", string(domain.PlanTypeLP), "Type of the plan (lp, savers)")
 	addPlanCmd.Flags().StringP("assets", "a", "", "Comma separated list of assets to include in the plan (e.g. THOR.RUNE,BTC.BTC)")
 	addPlanCmd.Flags().StringP("security", "s", "2-2", "Security model to use (2of2, 2of3)")
 	addPlanCmd.Flags().StringP("strategy", "t", "equal_share", "Strategy to use (equal-share, custom)")
 	addPlanCmd.Flags().IntP("quantum", "q", 100, "Quantum value of each share measured in $")
 	addPlanCmd.Flags().Float64P("loss-limit", "l", 0.1, "Loss limit")
-	addPlanCmd.Flags().IntP("investing-period", "i", 1, "Investing period in weeks")
+	addPlanCmd.Flags().IntP("investing-period", "i", 1, "Investing period measured in the period unit")
+	addPlanCmd.Flags().String("period-unit", string(domain.PeriodUnitWeek), "Unit of the investing period (day, week, month)")
+	addPlanCmd.Flags().Int("grace-days", 0, "Days the participants are given to withdraw after the investing period ends")
+	addPlanCmd.Flags().IntP("max-share-multiplier", "m", 1, "Maximum multiple of the quantum a participant can invest in a pair")
+	addPlanCmd.Flags().StringP("network", "n", string(domain.NetworkMainnet), "Network of the plan (mainnet, testnet)")
+	addPlanCmd.Flags().Int("max-active-pairs", 0, "Maximum number of pairs in progress the plan allows at once, 0 for no limit")
+	addPlanCmd.Flags().String("active-from", "", "RFC3339 time the plan starts accepting pairs at, empty to start immediately")
+	addPlanCmd.Flags().String("active-until", "", "RFC3339 time the plan stops accepting pairs at, empty for no end")
+	addPlanCmd.Flags().StringToString("status-timeouts", nil, "How long the pairs may stay in each status before they are escalated (e.g. wallet_conformation=24h,deposit=48h)")
+	addPlanCmd.Flags().StringToString("assurance-nonces", nil, "Nonces of the assurances the participants pre-sign by chain, the other chains keep the default ones (e.g. THOR=0:2:4,BTC=0:2)")
+	addPlanCmd.Flags().StringToInt("assurance-max-txs", nil, "Maximum number of assurances the participants may pre-sign by chain (e.g. THOR=6)")
 }
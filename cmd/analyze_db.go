@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/co-defi/api-server/app"
+	"github.com/spf13/cobra"
+)
+
+// analyzeDBCmd represents the analyze-db command
+var analyzeDBCmd = &cobra.Command{
+	Use:   "analyze-db",
+	Short: "Gather the query planner statistics and report the query plans",
+	Long: `This command gathers the statistics SQLite plans the queries with, then prints the plans it picks for the common
+query paths of the projections as newline-delimited JSON. The paths scanning a whole table are logged as warnings.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		db, err := prepareDB(cmd.Flags())
+		if err != nil {
+			logger.Fatal().Err(err).Msg("failed to open database")
+		}
+		defer db.Close()
+
+		// The application creates the projections along with their indexes
+		app, err := app.NewApplication(db, logger)
+		if err != nil {
+			logger.Fatal().Err(err).Msg("failed to create application instance")
+		}
+
+		plans, err := app.AnalyzeDB(cmd.Context())
+		if err != nil {
+			logger.Fatal().Err(err).Msg("failed to analyze database")
+		}
+
+		enc := json.NewEncoder(os.Stdout)
+		for _, plan := range plans {
+			if err := enc.Encode(plan); err != nil {
+				logger.Fatal().Err(err).Msg("failed to print query plan")
+			}
+			scans := slices.ContainsFunc(plan.Steps, func(step string) bool {
+				return strings.HasPrefix(strings.TrimSpace(step), "SCAN ")
+			})
+			if scans {
+				logger.Warn().Str("query", plan.Name).Msg("query scans a whole table")
+			}
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(analyzeDBCmd)
+}
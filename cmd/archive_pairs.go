@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"time"
+
+	"github.com/co-defi/api-server/app"
+	"github.com/spf13/cobra"
+)
+
+// archivePairsCmd represents the archive-pairs command
+var archivePairsCmd = &cobra.Command{
+	Use:   "archive-pairs",
+	Short: "Archive the pairs in a terminal status",
+	Long: `This command moves the withdrawn and invalid pairs that haven't changed for longer than the retention window
+to the archive table. The archived pairs can optionally be exported as newline-delimited JSON.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		db, err := prepareDB(cmd.Flags())
+		if err != nil {
+			logger.Fatal().Err(err).Msg("failed to open database")
+		}
+		defer db.Close()
+
+		cipher, err := prepareCipher(cmd.Context(), cmd.Flags())
+		if err != nil {
+			logger.Fatal().Err(err).Msg("failed to prepare encryption")
+		}
+
+		app, err := app.NewApplication(db, logger, app.WithEncryption(cipher))
+		if err != nil {
+			logger.Fatal().Err(err).Msg("failed to create application instance")
+		}
+
+		retention, _ := cmd.Flags().GetDuration("retention")
+		archived, err := app.Queries.Pairs.Archive(cmd.Context(), time.Now().Add(-retention))
+		if err != nil {
+			logger.Fatal().Err(err).Msg("failed to archive pairs")
+		}
+
+		export, _ := cmd.Flags().GetString("export")
+		if export != "" {
+			var w io.Writer = os.Stdout
+			if export != "-" {
+				f, err := os.Create(export)
+				if err != nil {
+					logger.Fatal().Err(err).Msg("failed to create export file")
+				}
+				defer f.Close()
+				w = f
+			}
+
+			enc := json.NewEncoder(w)
+			for _, p := range archived {
+				if err := enc.Encode(p); err != nil {
+					logger.Fatal().Err(err).Msg("failed to export archived pair")
+				}
+			}
+		}
+
+		logger.Info().Int("count", len(archived)).Msg("pairs archived")
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(archivePairsCmd)
+
+	archivePairsCmd.Flags().Duration("retention", 30*24*time.Hour, "Archive the pairs that reached a terminal status longer than this ago")
+	archivePairsCmd.Flags().StringP("export", "e", "", "Export the archived pairs as newline-delimited JSON to this file, - for stdout")
+}
@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+
+	"github.com/co-defi/api-server/app"
+	"github.com/co-defi/api-server/app/audit"
+	"github.com/spf13/cobra"
+)
+
+// auditLogCmd represents the audit-log command
+var auditLogCmd = &cobra.Command{
+	Use:   "audit-log",
+	Short: "Query the audit log of the authenticated actions",
+	Long: `This command prints the audit log entries matching the given filters as newline-delimited JSON, the latest first.
+Every authenticated mutating request is recorded with its address, route, payload hash, result, IP and timestamp.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		db, err := prepareDB(cmd.Flags())
+		if err != nil {
+			logger.Fatal().Err(err).Msg("failed to open database")
+		}
+		defer db.Close()
+
+		cipher, err := prepareCipher(cmd.Context(), cmd.Flags())
+		if err != nil {
+			logger.Fatal().Err(err).Msg("failed to prepare encryption")
+		}
+
+		app, err := app.NewApplication(db, logger, app.WithEncryption(cipher))
+		if err != nil {
+			logger.Fatal().Err(err).Msg("failed to create application instance")
+		}
+
+		address, _ := cmd.Flags().GetString("address")
+		route, _ := cmd.Flags().GetString("route")
+		since, _ := cmd.Flags().GetDuration("since")
+		limit, _ := cmd.Flags().GetInt("limit")
+
+		filter := audit.Filter{Address: address, Route: route, Limit: limit}
+		if since > 0 {
+			filter.Since = time.Now().Add(-since)
+		}
+
+		entries, err := app.AuditLog.Find(cmd.Context(), filter)
+		if err != nil {
+			logger.Fatal().Err(err).Msg("failed to query audit log")
+		}
+
+		enc := json.NewEncoder(os.Stdout)
+		for _, e := range entries {
+			if err := enc.Encode(e); err != nil {
+				logger.Fatal().Err(err).Msg("failed to print audit log entry")
+			}
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(auditLogCmd)
+
+	auditLogCmd.Flags().StringP("address", "a", "", "Only show the entries of this address, admin:<operator> for operators")
+	auditLogCmd.Flags().StringP("route", "r", "", "Only show the entries of this route (e.g. /pairs/:id/deposits)")
+	auditLogCmd.Flags().Duration("since", 0, "Only show the entries recorded within this duration")
+	auditLogCmd.Flags().IntP("limit", "l", 100, "Maximum number of entries to show")
+}
@@ -0,0 +1,239 @@
+package cmd
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"time"
+
+	"github.com/co-defi/api-server/adapters"
+	"github.com/co-defi/api-server/app"
+	"github.com/co-defi/api-server/common"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// backupCmd represents the backup command
+var backupCmd = &cobra.Command{
+	Use:   "backup",
+	Short: "Back up the database",
+	Long: `This command snapshots the SQLite database into --out-dir. The event store and the projections are snapshotted
+together in a single transaction, so the backup can be taken while the server is running. Every backup is verified
+by checking its integrity and replaying its events before it is kept, and uploaded to an S3-compatible bucket
+when --s3-bucket is set. With --every, backups are taken on that schedule until the command is interrupted.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		db, err := prepareDB(cmd.Flags())
+		if err != nil {
+			logger.Fatal().Err(err).Msg("failed to open database")
+		}
+		defer db.Close()
+
+		outDir, _ := cmd.Flags().GetString("out-dir")
+		if err := os.MkdirAll(outDir, 0o700); err != nil {
+			logger.Fatal().Err(err).Msg("failed to create output directory")
+		}
+		uploader, err := s3Uploader(cmd.Flags())
+		if err != nil {
+			logger.Fatal().Err(err).Msg("invalid S3 configuration")
+		}
+		prefix, _ := cmd.Flags().GetString("s3-prefix")
+		cipher, err := prepareCipher(cmd.Context(), cmd.Flags())
+		if err != nil {
+			logger.Fatal().Err(err).Msg("failed to prepare encryption")
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+
+		every, _ := cmd.Flags().GetDuration("every")
+		for {
+			path, err := backup(ctx, db.Write, outDir, uploader, prefix, cipher)
+			if err != nil {
+				if every == 0 {
+					logger.Fatal().Err(err).Msg("failed to back up database")
+				}
+				logger.Error().Err(err).Msg("failed to back up database")
+			} else {
+				logger.Info().Str("path", path).Msg("database backed up")
+			}
+
+			if every == 0 {
+				return
+			}
+			select {
+			case <-time.After(every):
+			case <-ctx.Done():
+				return
+			}
+		}
+	},
+}
+
+// backup snapshots the database into the directory, verifies the snapshot and uploads it if an uploader is set
+func backup(ctx context.Context, db *sql.DB, dir string, uploader *adapters.S3Uploader, prefix string, cipher *common.Cipher) (string, error) {
+	name := fmt.Sprintf("co-defi-%s.db", time.Now().UTC().Format("20060102T150405Z"))
+	path := filepath.Join(dir, name)
+	if err := common.BackupSQLite(ctx, db, path); err != nil {
+		return "", err
+	}
+
+	if _, err := verifyBackup(ctx, path, cipher); err != nil {
+		os.Remove(path)
+		return "", fmt.Errorf("backup %s is invalid: %w", path, err)
+	}
+
+	if uploader != nil {
+		if err := uploader.Upload(ctx, path, prefix+name); err != nil {
+			return path, err
+		}
+	}
+
+	return path, nil
+}
+
+// verifyBackup checks the integrity of the backup and replays its events without changing it, and returns the number of events.
+// The encrypted events are decoded with the cipher.
+func verifyBackup(ctx context.Context, path string, cipher *common.Cipher) (int, error) {
+	db, err := sql.Open("sqlite3", "file:"+path+"?mode=ro")
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+
+	if err := common.CheckSQLiteIntegrity(ctx, db); err != nil {
+		return 0, err
+	}
+
+	return app.VerifyEvents(db, cipher)
+}
+
+func s3Uploader(flags *pflag.FlagSet) (*adapters.S3Uploader, error) {
+	bucket, _ := flags.GetString("s3-bucket")
+	if bucket == "" {
+		return nil, nil
+	}
+
+	endpoint, _ := flags.GetString("s3-endpoint")
+	region, _ := flags.GetString("s3-region")
+	accessKey, _ := flags.GetString("s3-access-key")
+	secretKey, _ := flags.GetString("s3-secret-key")
+
+	return adapters.NewS3Uploader(endpoint, region, bucket, accessKey, secretKey)
+}
+
+// restoreCmd represents the restore command
+var restoreCmd = &cobra.Command{
+	Use:   "restore",
+	Short: "Restore the database from a backup",
+	Long: `This command verifies a backup and restores it as the database file of --db, the server must be stopped.
+With --until, the events stored after that time are dropped to restore the state of that point in time,
+the projections are then rebuilt from the remaining events when the server starts.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		connStr, _ := cmd.Flags().GetString("db")
+		target := common.SQLiteFilePath(connStr)
+		if target == "" {
+			logger.Fatal().Msg("restoring requires a database file, not an in-memory database")
+		}
+		from, _ := cmd.Flags().GetString("from")
+		force, _ := cmd.Flags().GetBool("force")
+		if _, err := os.Stat(target); err == nil && !force {
+			logger.Fatal().Str("db", target).Msg("the database already exists, use --force to overwrite it")
+		}
+
+		cipher, err := prepareCipher(cmd.Context(), cmd.Flags())
+		if err != nil {
+			logger.Fatal().Err(err).Msg("failed to prepare encryption")
+		}
+
+		count, err := verifyBackup(cmd.Context(), from, cipher)
+		if err != nil {
+			logger.Fatal().Err(err).Str("from", from).Msg("backup is invalid")
+		}
+
+		// The backup is restored into a temporary file first so a failure leaves the current database untouched
+		tmp := target + ".restore"
+		if err := copyFile(from, tmp); err != nil {
+			logger.Fatal().Err(err).Msg("failed to copy backup")
+		}
+		defer os.Remove(tmp)
+
+		if until, _ := cmd.Flags().GetString("until"); until != "" {
+			at, err := time.Parse(time.RFC3339, until)
+			if err != nil {
+				logger.Fatal().Err(err).Msg("invalid --until, RFC3339 time expected")
+			}
+			if err := truncateBackup(cmd.Context(), tmp, at); err != nil {
+				logger.Fatal().Err(err).Msg("failed to restore point in time")
+			}
+		}
+
+		for _, suffix := range []string{"-wal", "-shm"} {
+			if err := os.Remove(target + suffix); err != nil && !os.IsNotExist(err) {
+				logger.Fatal().Err(err).Msg("failed to remove database journal")
+			}
+		}
+		if err := os.Rename(tmp, target); err != nil {
+			logger.Fatal().Err(err).Msg("failed to restore database")
+		}
+
+		logger.Info().Str("from", from).Str("db", target).Int("events", count).Msg("database restored")
+	},
+}
+
+func truncateBackup(ctx context.Context, path string, until time.Time) error {
+	db, err := sql.Open("sqlite3", "file:"+path)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	deleted, err := app.TruncateEvents(ctx, db, until)
+	if err != nil {
+		return err
+	}
+	logger.Info().Int64("deleted", deleted).Time("until", until).Msg("events after the point in time dropped")
+
+	return nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+
+	return out.Close()
+}
+
+func init() {
+	rootCmd.AddCommand(backupCmd)
+	rootCmd.AddCommand(restoreCmd)
+
+	backupCmd.Flags().String("out-dir", "backups", "Directory to write the backups to")
+	backupCmd.Flags().Duration("every", 0, "Take a backup on this schedule until interrupted, 0 takes a single backup")
+	backupCmd.Flags().String("s3-endpoint", "https://s3.amazonaws.com", "Endpoint of the S3-compatible storage to upload the backups to")
+	backupCmd.Flags().String("s3-region", "us-east-1", "Region of the S3 bucket")
+	backupCmd.Flags().String("s3-bucket", "", "Bucket to upload the backups to, they are only kept locally when empty")
+	backupCmd.Flags().String("s3-prefix", "", "Prefix of the keys of the uploaded backups (e.g. backups/)")
+	backupCmd.Flags().String("s3-access-key", "", "Access key of the S3 storage")
+	backupCmd.Flags().String("s3-secret-key", "", "Secret key of the S3 storage")
+
+	restoreCmd.Flags().String("from", "", "Path of the backup to restore")
+	restoreCmd.Flags().String("until", "", "Restore the state at this RFC3339 time by dropping the events stored after it")
+	restoreCmd.Flags().Bool("force", false, "Overwrite the existing database")
+	restoreCmd.MarkFlagRequired("from")
+}
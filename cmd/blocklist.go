@@ -0,0 +1,131 @@
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+
+	"github.com/co-defi/api-server/app"
+	"github.com/co-defi/api-server/app/blocklist"
+	"github.com/co-defi/api-server/app/commands"
+	"github.com/co-defi/api-server/common"
+	"github.com/co-defi/api-server/domain"
+	"github.com/spf13/cobra"
+)
+
+// blocklistCmd represents the blocklist command
+var blocklistCmd = &cobra.Command{
+	Use:   "blocklist",
+	Short: "Manage the blocked addresses",
+	Long: `This command prints the blocked addresses as newline-delimited JSON, the latest blocked first, or the history of the blocklist with --history.
+The blocked addresses can't authenticate nor act on their pairs, see the block and unblock subcommands.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		db, list := openBlocklist(cmd)
+		defer db.Close()
+
+		enc := json.NewEncoder(os.Stdout)
+		if history, _ := cmd.Flags().GetBool("history"); history {
+			address, _ := cmd.Flags().GetString("address")
+			changes, err := list.Changes(cmd.Context(), domain.Address(address))
+			if err != nil {
+				logger.Fatal().Err(err).Msg("failed to query blocklist history")
+			}
+			for _, c := range changes {
+				if err := enc.Encode(c); err != nil {
+					logger.Fatal().Err(err).Msg("failed to print blocklist change")
+				}
+			}
+			return
+		}
+
+		entries, err := list.All(cmd.Context())
+		if err != nil {
+			logger.Fatal().Err(err).Msg("failed to query blocklist")
+		}
+		for _, e := range entries {
+			if err := enc.Encode(e); err != nil {
+				logger.Fatal().Err(err).Msg("failed to print blocklist entry")
+			}
+		}
+	},
+}
+
+// blockCmd represents the blocklist block command
+var blockCmd = &cobra.Command{
+	Use:   "block <address>",
+	Short: "Block an address",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		changeBlocklist(cmd, domain.Address(args[0]), false)
+		logger.Info().Str("address", args[0]).Msg("address blocked")
+	},
+}
+
+// unblockCmd represents the blocklist unblock command
+var unblockCmd = &cobra.Command{
+	Use:   "unblock <address>",
+	Short: "Unblock an address",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		changeBlocklist(cmd, domain.Address(args[0]), true)
+		logger.Info().Str("address", args[0]).Msg("address unblocked")
+	},
+}
+
+// openBlocklist opens the blocklist of the database, it doesn't need the rest of the application
+func openBlocklist(cmd *cobra.Command) (*common.DB, *blocklist.Blocklist) {
+	db, err := prepareDB(cmd.Flags())
+	if err != nil {
+		logger.Fatal().Err(err).Msg("failed to open database")
+	}
+
+	list, err := blocklist.NewBlocklist(db, common.SystemClock)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("failed to prepare blocklist")
+	}
+
+	return db, list
+}
+
+// changeBlocklist blocks or unblocks the address through the application, so the participant of the address mirrors the change
+func changeBlocklist(cmd *cobra.Command, address domain.Address, unblock bool) {
+	db, err := prepareDB(cmd.Flags())
+	if err != nil {
+		logger.Fatal().Err(err).Msg("failed to open database")
+	}
+	defer db.Close()
+
+	cipher, err := prepareCipher(cmd.Context(), cmd.Flags())
+	if err != nil {
+		logger.Fatal().Err(err).Msg("failed to prepare encryption")
+	}
+
+	app, err := app.NewApplication(db, logger, app.WithEncryption(cipher))
+	if err != nil {
+		logger.Fatal().Err(err).Msg("failed to create application instance")
+	}
+
+	reason, _ := cmd.Flags().GetString("reason")
+	operator, _ := cmd.Flags().GetString("operator")
+	if _, err := app.Commands.BlockAddress.Handle(cmd.Context(), commands.BlockAddress{
+		Address:  address,
+		Reason:   reason,
+		Operator: operator,
+		Unblock:  unblock,
+	}); err != nil {
+		logger.Fatal().Err(err).Msg("failed to change blocklist")
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(blocklistCmd)
+	blocklistCmd.AddCommand(blockCmd, unblockCmd)
+
+	blocklistCmd.Flags().Bool("history", false, "Show the blocks and unblocks recorded instead of the blocked addresses")
+	blocklistCmd.Flags().StringP("address", "a", "", "Only show the history of this address")
+
+	for _, c := range []*cobra.Command{blockCmd, unblockCmd} {
+		c.Flags().StringP("reason", "r", "", "Why the address is blocked or unblocked, recorded in the history of the blocklist")
+		c.Flags().String("operator", "cli:"+os.Getenv("USER"), "Operator recorded in the history of the blocklist")
+		c.MarkFlagRequired("reason")
+	}
+}
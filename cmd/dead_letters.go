@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+
+	"github.com/co-defi/api-server/app"
+	"github.com/co-defi/api-server/common"
+	"github.com/spf13/cobra"
+)
+
+// deadLettersCmd represents the dead-letters command
+var deadLettersCmd = &cobra.Command{
+	Use:   "dead-letters",
+	Short: "Show the events the projections failed to handle",
+	Long: `This command prints the events parked by the projections after failing to handle them as newline-delimited JSON, the latest first.
+With --summary it prints the number of parked events of every projection instead.
+The parked events are handled again once the projections are reset (see reset-projections).`,
+	Run: func(cmd *cobra.Command, args []string) {
+		db, err := prepareDB(cmd.Flags())
+		if err != nil {
+			logger.Fatal().Err(err).Msg("failed to open database")
+		}
+		defer db.Close()
+
+		// The application registers the projections along with their dead letters
+		if _, err := app.NewApplication(db, logger); err != nil {
+			logger.Fatal().Err(err).Msg("failed to create application instance")
+		}
+
+		enc := json.NewEncoder(os.Stdout)
+
+		if summary, _ := cmd.Flags().GetBool("summary"); summary {
+			counts, err := common.CountDeadLetters(cmd.Context(), db.Read)
+			if err != nil {
+				logger.Fatal().Err(err).Msg("failed to count dead letters")
+			}
+			if err := enc.Encode(counts); err != nil {
+				logger.Fatal().Err(err).Msg("failed to print dead letters summary")
+			}
+			return
+		}
+
+		projection, _ := cmd.Flags().GetString("projection")
+		limit, _ := cmd.Flags().GetInt("limit")
+
+		letters, err := common.FindDeadLetters(cmd.Context(), db.Read, projection, limit)
+		if err != nil {
+			logger.Fatal().Err(err).Msg("failed to query dead letters")
+		}
+
+		for _, dl := range letters {
+			if err := enc.Encode(dl); err != nil {
+				logger.Fatal().Err(err).Msg("failed to print dead letter")
+			}
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(deadLettersCmd)
+
+	deadLettersCmd.Flags().StringP("projection", "p", "", "Only show the dead letters of this projection (e.g. pairs_query)")
+	deadLettersCmd.Flags().IntP("limit", "l", 100, "Maximum number of dead letters to show")
+	deadLettersCmd.Flags().BoolP("summary", "s", false, "Show the number of dead letters of every projection")
+}
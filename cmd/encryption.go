@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+
+	"github.com/co-defi/api-server/adapters"
+	"github.com/co-defi/api-server/common"
+	"github.com/spf13/pflag"
+)
+
+// prepareCipher creates the cipher encrypting the pair secrets at rest with the master key of the key file or of KMS,
+// the secrets are stored in plaintext when neither is set. The previous master keys are kept to read the values encrypted
+// under them until they are rotated.
+func prepareCipher(ctx context.Context, flags *pflag.FlagSet) (*common.Cipher, error) {
+	keyFile, _ := flags.GetString("encryption-key-file")
+	kmsKeyId, _ := flags.GetString("kms-key-id")
+
+	var master common.KeyWrapper
+	var err error
+	switch {
+	case keyFile != "" && kmsKeyId != "":
+		return nil, errors.New("--encryption-key-file and --kms-key-id are mutually exclusive")
+	case keyFile != "":
+		master, err = common.ReadKeyFile(keyFile)
+	case kmsKeyId != "":
+		master, err = kmsKeyWrapper(flags, kmsKeyId)
+	default:
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var previous []common.KeyWrapper
+	previousFiles, _ := flags.GetStringSlice("previous-encryption-key-files")
+	for _, path := range previousFiles {
+		wrapper, err := common.ReadKeyFile(path)
+		if err != nil {
+			return nil, err
+		}
+		previous = append(previous, wrapper)
+	}
+	previousKMSKeyIds, _ := flags.GetStringSlice("previous-kms-key-ids")
+	for _, keyId := range previousKMSKeyIds {
+		wrapper, err := kmsKeyWrapper(flags, keyId)
+		if err != nil {
+			return nil, err
+		}
+		previous = append(previous, wrapper)
+	}
+
+	return common.NewCipher(ctx, master, previous...)
+}
+
+func kmsKeyWrapper(flags *pflag.FlagSet, keyId string) (*adapters.KMSKeyWrapper, error) {
+	endpoint, _ := flags.GetString("kms-endpoint")
+	region, _ := flags.GetString("kms-region")
+	accessKey, _ := flags.GetString("kms-access-key")
+	secretKey, _ := flags.GetString("kms-secret-key")
+
+	return adapters.NewKMSKeyWrapper(endpoint, region, keyId, accessKey, secretKey)
+}
@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"io"
+	"os"
+
+	"github.com/co-defi/api-server/app"
+	"github.com/spf13/cobra"
+)
+
+// eventsCmd represents the events command
+var eventsCmd = &cobra.Command{
+	Use:   "events",
+	Short: "Manage the event store",
+	Long:  `This command groups the tools to back up, clone and migrate the event store.`,
+}
+
+// eventsExportCmd represents the events export command
+var eventsExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export all events as newline-delimited JSON",
+	Long: `This command streams the full event store in global order as newline-delimited JSON.
+Each line contains the aggregate id, version, type, payload and timestamp of an event.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		db, err := prepareDB(cmd.Flags())
+		if err != nil {
+			logger.Fatal().Err(err).Msg("failed to open database")
+		}
+		defer db.Close()
+
+		out, _ := cmd.Flags().GetString("out")
+		var w io.Writer = os.Stdout
+		if out != "-" {
+			f, err := os.Create(out)
+			if err != nil {
+				logger.Fatal().Err(err).Msg("failed to create output file")
+			}
+			defer f.Close()
+			w = f
+		}
+
+		count, err := app.ExportEvents(db.Write, w)
+		if err != nil {
+			logger.Fatal().Err(err).Int("exported", count).Msg("failed to export events")
+		}
+
+		logger.Info().Int("count", count).Str("out", out).Msg("events exported")
+	},
+}
+
+// eventsImportCmd represents the events import command
+var eventsImportCmd = &cobra.Command{
+	Use:   "import",
+	Short: "Import events from newline-delimited JSON",
+	Long: `This command appends the events of an export to the event store in their original order.
+It is meant to be run against an empty database, projections are rebuilt from the imported events.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		db, err := prepareDB(cmd.Flags())
+		if err != nil {
+			logger.Fatal().Err(err).Msg("failed to open database")
+		}
+		defer db.Close()
+
+		in, _ := cmd.Flags().GetString("in")
+		var r io.Reader = os.Stdin
+		if in != "-" {
+			f, err := os.Open(in)
+			if err != nil {
+				logger.Fatal().Err(err).Msg("failed to open input file")
+			}
+			defer f.Close()
+			r = f
+		}
+
+		count, err := app.ImportEvents(db.Write, r)
+		if err != nil {
+			logger.Fatal().Err(err).Int("imported", count).Msg("failed to import events")
+		}
+
+		logger.Info().Int("count", count).Str("in", in).Msg("events imported")
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(eventsCmd)
+	eventsCmd.AddCommand(eventsExportCmd)
+	eventsCmd.AddCommand(eventsImportCmd)
+
+	eventsExportCmd.Flags().StringP("out", "o", "-", "Output file, - for stdout")
+	eventsImportCmd.Flags().StringP("in", "i", "-", "Input file, - for stdin")
+}
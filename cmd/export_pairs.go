@@ -0,0 +1,117 @@
+package cmd
+
+import (
+	"io"
+	"os"
+	"strings"
+
+	"github.com/co-defi/api-server/app"
+	"github.com/co-defi/api-server/app/queries"
+	"github.com/co-defi/api-server/domain"
+	"github.com/spf13/cobra"
+)
+
+// exportPairsCmd represents the export-pairs command
+var exportPairsCmd = &cobra.Command{
+	Use:   "export-pairs",
+	Short: "Export the pairs as CSV",
+	Long: `This command streams the pairs matching the filters as CSV, a header of the columns then a row by pair ordered by creation.
+The amounts are exact decimals in units of their asset, the columns are selected with --columns among:
+` + strings.Join(app.DefaultPairsCSVColumns, ", ") + `, archived, updated_at, investing_period,
+investing_period_unit, grace_period_days, loss_protection, profit_sharing, primary_deposit_tx, secondary_deposit_tx.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		db, err := prepareDB(cmd.Flags())
+		if err != nil {
+			logger.Fatal().Err(err).Msg("failed to open database")
+		}
+		defer db.Close()
+
+		cipher, err := prepareCipher(cmd.Context(), cmd.Flags())
+		if err != nil {
+			logger.Fatal().Err(err).Msg("failed to prepare encryption")
+		}
+
+		columns, _ := cmd.Flags().GetString("columns")
+		selected := app.ParseExportColumns(columns)
+
+		app, err := app.NewApplication(db, logger, app.WithEncryption(cipher))
+		if err != nil {
+			logger.Fatal().Err(err).Msg("failed to create application instance")
+		}
+
+		filter, err := pairFilterFromFlags(cmd)
+		if err != nil {
+			logger.Fatal().Err(err).Msg("invalid filters")
+		}
+
+		out, _ := cmd.Flags().GetString("out")
+		var w io.Writer = os.Stdout
+		if out != "-" {
+			f, err := os.Create(out)
+			if err != nil {
+				logger.Fatal().Err(err).Msg("failed to create output file")
+			}
+			defer f.Close()
+			w = f
+		}
+
+		count, err := app.ExportPairsCSV(cmd.Context(), w, filter, selected)
+		if err != nil {
+			logger.Fatal().Err(err).Int("exported", count).Msg("failed to export pairs")
+		}
+
+		logger.Info().Int("count", count).Str("out", out).Msg("pairs exported")
+	},
+}
+
+// pairFilterFromFlags builds the filter of the pairs from the flags of the command, the flags not set don't constrain the pairs
+func pairFilterFromFlags(cmd *cobra.Command) (queries.PairFilter, error) {
+	network, _ := cmd.Flags().GetString("network")
+	planId, _ := cmd.Flags().GetString("plan-id")
+	planType, _ := cmd.Flags().GetString("plan-type")
+	status, _ := cmd.Flags().GetString("status")
+	asset, _ := cmd.Flags().GetString("asset")
+	includeArchived, _ := cmd.Flags().GetBool("include-archived")
+
+	filter := queries.PairFilter{PlanId: planId, IncludeArchived: includeArchived}
+	if network != "" {
+		n := domain.Network(network)
+		filter.Network = &n
+	}
+	if planType != "" {
+		t := domain.PlanType(planType)
+		filter.PlanType = &t
+	}
+	if status != "" {
+		s := domain.PairStatus(status)
+		filter.Status = &s
+	}
+	if asset != "" {
+		filter.Assets = []domain.Asset{asset}
+	}
+
+	var err error
+	if filter.CreatedAfter, err = parseOptionalTime(cmd.Flags().GetString("created-after")); err != nil {
+		return queries.PairFilter{}, err
+	}
+	if filter.CreatedBefore, err = parseOptionalTime(cmd.Flags().GetString("created-before")); err != nil {
+		return queries.PairFilter{}, err
+	}
+
+	return filter, nil
+}
+
+func init() {
+	rootCmd.AddCommand(exportPairsCmd)
+
+	exportPairsCmd.Flags().StringP("out", "o", "-", "Output file, - for stdout")
+	exportPairsCmd.Flags().String("columns", "", "Comma separated columns of the export, empty for the default ones")
+	exportPairsCmd.Flags().StringP("network", "n", "", "Network of the pairs (mainnet, testnet), empty for every network")
+	exportPairsCmd.Flags().String("plan-id", "", "Id of the plan of the pairs")
+	exportPairsCmd.Flags().String("plan-type", "", "Type of the plan of the pairs (lp, savers)")
+	exportPairsCmd.Flags().String("status", "", "Status of the pairs")
+	exportPairsCmd.Flags().String("asset", "", "Asset the pairs contain")
+	exportPairsCmd.Flags().String("created-after", "", "RFC3339 time the pairs are created at or after")
+	exportPairsCmd.Flags().String("created-before", "", "RFC3339 time the pairs are created before")
+	exportPairsCmd.Flags().Bool("include-archived", false, "Include the archived pairs")
+}
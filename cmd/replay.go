@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/co-defi/api-server/app"
+	"github.com/spf13/cobra"
+)
+
+// replayCmd represents the replay command
+var replayCmd = &cobra.Command{
+	Use:   "replay",
+	Short: "Replay the event store into a projection",
+	Long: `This command replays the events of the store into a single projection, e.g. after fixing one of its callbacks.
+Replaying from the start (--from-seq 0) drops and rebuilds the projection, replaying from a later event applies
+the events again on top of the current state of the projection. The server must be stopped during the replay.
+With --dry-run, it only prints how many events of each type would be applied as JSON.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		db, err := prepareDB(cmd.Flags())
+		if err != nil {
+			logger.Fatal().Err(err).Msg("failed to open database")
+		}
+		defer db.Close()
+
+		projection, _ := cmd.Flags().GetString("projection")
+		from, _ := cmd.Flags().GetUint64("from-seq")
+		rate, _ := cmd.Flags().GetInt("rate")
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+
+		cipher, err := prepareCipher(cmd.Context(), cmd.Flags())
+		if err != nil {
+			logger.Fatal().Err(err).Msg("failed to prepare encryption")
+		}
+
+		started := time.Now()
+		report, err := app.ReplayProjection(db, projection, app.ReplayOptions{
+			From:     from,
+			Rate:     rate,
+			DryRun:   dryRun,
+			Progress: printProgress,
+			Cipher:   cipher,
+		})
+		if report.Applied > 0 {
+			fmt.Fprintln(os.Stderr)
+		}
+		if err != nil {
+			logger.Fatal().Err(err).Int("applied", report.Applied).Msg("failed to replay events")
+		}
+
+		if dryRun {
+			enc := json.NewEncoder(os.Stdout)
+			if err := enc.Encode(report.EventTypes); err != nil {
+				logger.Fatal().Err(err).Msg("failed to write report")
+			}
+			return
+		}
+
+		logger.Info().Str("projection", projection).Int("applied", report.Applied).Dur("took", time.Since(started)).Msg("events replayed")
+	},
+}
+
+// progressBarWidth is the number of characters of the progress bar
+const progressBarWidth = 40
+
+// printProgress redraws the progress bar of the replay on stderr
+func printProgress(done, total int) {
+	if total == 0 {
+		return
+	}
+
+	filled := done * progressBarWidth / total
+	fmt.Fprintf(os.Stderr, "\r[%s%s] %3d%% %d/%d events",
+		strings.Repeat("=", filled), strings.Repeat(" ", progressBarWidth-filled), done*100/total, done, total)
+}
+
+func init() {
+	rootCmd.AddCommand(replayCmd)
+
+	replayCmd.Flags().String("projection", "", "Name of the projection to replay the events into (e.g. pairs_query)")
+	replayCmd.Flags().Uint64("from-seq", 0, "Global sequence of the first event to replay, 0 rebuilds the projection from the start")
+	replayCmd.Flags().Int("rate", 0, "Maximum number of events replayed per second, 0 doesn't limit it")
+	replayCmd.Flags().Bool("dry-run", false, "Only report how many events of each type would be applied")
+	replayCmd.MarkFlagRequired("projection")
+}
@@ -17,7 +17,7 @@ var resetProjectionsCmd = &cobra.Command{
 		}
 		defer db.Close()
 
-		if err := common.ResetAllProjections(db); err != nil {
+		if err := common.ResetAllProjections(db.Write); err != nil {
 			logger.Fatal().Err(err).Msg("failed to reset projections")
 		}
 	},
@@ -1,14 +1,21 @@
 package cmd
 
 import (
+	"fmt"
 	"os"
+	"time"
 
+	"github.com/co-defi/api-server/common"
 	"github.com/rs/zerolog"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
 var logger = zerolog.New(os.Stdout).With().Timestamp().Logger()
 
+// logLevels are the levels of the logs by module, set by the flags before the commands run
+var logLevels *common.LogLevels
+
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
 	Use:   "api-server",
@@ -17,6 +24,37 @@ var rootCmd = &cobra.Command{
 	// Uncomment the following line if your bare application
 	// has an action associated with it:
 	// Run: func(cmd *cobra.Command, args []string) { },
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		return prepareLogger(cmd.Flags())
+	},
+}
+
+// prepareLogger sets up the logger with the format and the levels of the flags
+func prepareLogger(flags *pflag.FlagSet) error {
+	format, _ := flags.GetString("log-format")
+	value, _ := flags.GetString("log-level")
+	level, err := common.ParseLogLevel(value)
+	if err != nil {
+		return fmt.Errorf("invalid log level %q", value)
+	}
+
+	root, levels, err := common.NewLogger(format, level)
+	if err != nil {
+		return err
+	}
+	moduleLevels, _ := flags.GetStringToString("log-module-levels")
+	for module, value := range moduleLevels {
+		level, err := common.ParseLogLevel(value)
+		if err != nil {
+			return fmt.Errorf("invalid log level %q of module %s", value, module)
+		}
+		if err := levels.Set(module, level); err != nil {
+			return fmt.Errorf("invalid log module %q, must be one of %v", module, common.LogModules)
+		}
+	}
+
+	logger, logLevels = root, levels
+	return nil
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
@@ -29,5 +67,18 @@ func Execute() {
 }
 
 func init() {
+	rootCmd.PersistentFlags().String("log-level", "info", "Level of the logs: trace, debug, info, warn or error")
+	rootCmd.PersistentFlags().String("log-format", common.LogFormatJSON, "Format of the logs: json or console")
+	rootCmd.PersistentFlags().StringToString("log-module-levels", nil, "Levels of the logs of the modules set apart from --log-level, e.g. http=warn,projections=debug, the modules are http, projections and chains")
 	rootCmd.PersistentFlags().StringP("db", "d", "file::memory:?cache=shared", "Database connection string")
+	rootCmd.PersistentFlags().Int("db-read-conns", 4, "Maximum number of connections serving reads")
+	rootCmd.PersistentFlags().Duration("db-busy-timeout", 5*time.Second, "How long to wait for a locked database before failing")
+	rootCmd.PersistentFlags().String("encryption-key-file", "", "File holding the hex or base64 encoded 32 bytes master key encrypting the wallet secrets and signed transactions at rest")
+	rootCmd.PersistentFlags().String("kms-key-id", "", "Id, ARN or alias of the AWS KMS key encrypting the wallet secrets and signed transactions at rest, instead of a key file")
+	rootCmd.PersistentFlags().StringSlice("previous-encryption-key-files", nil, "Files of the master keys rotated from, the values encrypted under them are read and wrapped again under the current key")
+	rootCmd.PersistentFlags().StringSlice("previous-kms-key-ids", nil, "AWS KMS keys rotated from, the values encrypted under them are read and wrapped again under the current key")
+	rootCmd.PersistentFlags().String("kms-region", "us-east-1", "Region of the AWS KMS key")
+	rootCmd.PersistentFlags().String("kms-endpoint", "", "Endpoint of AWS KMS, the one of the region when empty")
+	rootCmd.PersistentFlags().String("kms-access-key", "", "Access key of AWS KMS")
+	rootCmd.PersistentFlags().String("kms-secret-key", "", "Secret key of AWS KMS")
 }
@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+
+	"github.com/co-defi/api-server/app"
+	"github.com/spf13/cobra"
+)
+
+// rotateKeysCmd represents the rotate-keys command
+var rotateKeysCmd = &cobra.Command{
+	Use:   "rotate-keys",
+	Short: "Wrap the values encrypted at rest under the current master key",
+	Long: `This command wraps the data keys of the values encrypted under the previous master keys, given with
+--previous-encryption-key-files or --previous-kms-key-ids, with the current one. The values aren't encrypted again.
+The server runs the same rotation in the background when started with previous keys, the command can run alongside it
+and both resume where the rotation stopped. The previous keys can be retired once every table is completed.
+With --status, it only prints the progress of the rotations as JSON.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		db, err := prepareDB(cmd.Flags())
+		if err != nil {
+			logger.Fatal().Err(err).Msg("failed to open database")
+		}
+		defer db.Close()
+
+		cipher, err := prepareCipher(cmd.Context(), cmd.Flags())
+		if err != nil {
+			logger.Fatal().Err(err).Msg("failed to prepare encryption")
+		}
+		if cipher == nil {
+			logger.Fatal().Msg("rotating keys requires --encryption-key-file or --kms-key-id")
+		}
+
+		app, err := app.NewApplication(db, logger, app.WithEncryption(cipher))
+		if err != nil {
+			logger.Fatal().Err(err).Msg("failed to create application instance")
+		}
+
+		if status, _ := cmd.Flags().GetBool("status"); status {
+			rotations, err := app.KeyRotator.Rotations(cmd.Context())
+			if err != nil {
+				logger.Fatal().Err(err).Msg("failed to query key rotations")
+			}
+			if err := json.NewEncoder(os.Stdout).Encode(rotations); err != nil {
+				logger.Fatal().Err(err).Msg("failed to print key rotations")
+			}
+			return
+		}
+
+		interval, _ := cmd.Flags().GetDuration("interval")
+		rewrapped, err := app.KeyRotator.Rotate(cmd.Context(), interval)
+		if err != nil {
+			logger.Fatal().Err(err).Int("rewrapped", rewrapped).Msg("failed to rotate keys")
+		}
+
+		logger.Info().Int("rewrapped", rewrapped).Str("key_id", cipher.KeyId()).Msg("keys rotated")
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(rotateKeysCmd)
+
+	rotateKeysCmd.Flags().Bool("status", false, "Only print the progress of the rotations")
+	rotateKeysCmd.Flags().Duration("interval", 0, "Pause between two batches, to leave room for the writes of a running server")
+}
@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/co-defi/api-server/app"
+	"github.com/co-defi/api-server/domain"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// seedCmd represents the seed command
+var seedCmd = &cobra.Command{
+	Use:   "seed",
+	Short: "Seed plans and pairs from a scenario",
+	Long: `This command creates the plans of a YAML scenario file and drives the given number of pairs to each status on them,
+by replaying the commands of made up participants through the application, e.g. for the staging environments or the UI development.
+The transactions of the seeded pairs are made up too and are trusted as declared, as in the development mode of serve.
+
+  plans:
+    - assets: [THOR.RUNE, BTC.BTC]
+      quantum: 100
+      loss_protection: 0.2
+      investing_period: 30
+      investing_period_unit: day
+      pairs: {waiting: 2, deposit: 1, lp: 3, withdrawn: 1}
+    - type: savers
+      assets: [BTC.BTC, ETH.ETH]
+      quantum: 500
+      loss_protection: 0.1
+      investing_period: 2
+      investing_period_unit: week
+      network: testnet
+      pairs: {savers: 2}`,
+	Run: func(cmd *cobra.Command, args []string) {
+		path, _ := cmd.Flags().GetString("scenario")
+		f, err := os.Open(path)
+		if err != nil {
+			logger.Fatal().Err(err).Msg("failed to open scenario")
+		}
+		var scenario app.SeedScenario
+		dec := yaml.NewDecoder(f)
+		dec.KnownFields(true)
+		err = dec.Decode(&scenario)
+		f.Close()
+		if err != nil {
+			logger.Fatal().Err(err).Msg("invalid scenario")
+		}
+
+		db, err := prepareDB(cmd.Flags())
+		if err != nil {
+			logger.Fatal().Err(err).Msg("failed to open database")
+		}
+		defer db.Close()
+
+		cipher, err := prepareCipher(cmd.Context(), cmd.Flags())
+		if err != nil {
+			logger.Fatal().Err(err).Msg("failed to prepare encryption")
+		}
+
+		app, err := app.NewApplication(db, logger, append(devChainOptions(), app.WithEncryption(cipher))...)
+		if err != nil {
+			logger.Fatal().Err(err).Msg("failed to create application instance")
+		}
+
+		seeded, err := app.Seed(cmd.Context(), scenario)
+		for _, plan := range seeded {
+			counts := make(map[domain.PairStatus]int, len(plan.Pairs))
+			for status, ids := range plan.Pairs {
+				counts[status] = len(ids)
+			}
+			logger.Info().Str("plan_id", plan.Id).Interface("pairs", counts).Msg("plan seeded")
+		}
+		if err != nil {
+			logger.Fatal().Err(err).Msg("failed to seed scenario")
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(seedCmd)
+
+	seedCmd.Flags().StringP("scenario", "s", "", "YAML file of the scenario to seed")
+	seedCmd.MarkFlagRequired("scenario")
+}
@@ -1,10 +1,20 @@
 package cmd
 
 import (
-	"database/sql"
+	"fmt"
+	"net"
+	"strings"
+	"time"
 
+	"github.com/co-defi/api-server/adapters"
 	"github.com/co-defi/api-server/app"
+	"github.com/co-defi/api-server/app/commands"
+	"github.com/co-defi/api-server/app/notifications"
+	"github.com/co-defi/api-server/common"
+	"github.com/co-defi/api-server/common/httpclient"
+	"github.com/co-defi/api-server/domain"
 	"github.com/co-defi/api-server/ports"
+	"github.com/labstack/gommon/bytes"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
 )
@@ -14,46 +24,449 @@ var serveCmd = &cobra.Command{
 	Use:   "serve",
 	Short: "Serve the HTTP APIs",
 	Long: `This command starts the HTTP server that serves the APIs.
-It listens on the specified port and connects to the database using the provided connection string.`,
+It listens on the specified port and connects to the database using the provided connection string.
+TLS is enabled either with a certificate and key pair (--tls-cert, --tls-key)
+or with certificates issued by Let's Encrypt for the given domains (--autocert-domains).
+The development mode (--dev) serves an in-memory database seeded with demo plans instead, and stands in for the chains:
+every transaction is accepted as declared and final at once and the assets have fixed prices. It must never face real funds.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		port, _ := cmd.Flags().GetString("port")
+		dev, _ := cmd.Flags().GetBool("dev")
 
-		db, err := prepareDB(cmd.Flags())
+		var db *common.DB
+		var err error
+		if dev {
+			busyTimeout, _ := cmd.Flags().GetDuration("db-busy-timeout")
+			db, err = common.OpenMemorySQLite(devDBName(), busyTimeout)
+		} else {
+			db, err = prepareDB(cmd.Flags())
+		}
 		if err != nil {
 			logger.Fatal().Err(err).Msg("failed to open database")
 		}
 		defer db.Close()
 
-		app, err := app.NewApplication(db, logger)
+		cipher, err := prepareCipher(cmd.Context(), cmd.Flags())
+		if err != nil {
+			logger.Fatal().Err(err).Msg("failed to prepare encryption")
+		}
+
+		opts := notificationOptions(cmd.Flags())
+		if dev {
+			logger.Warn().Msg("serving in development mode, the data is lost on exit and the chain transactions are not verified")
+			opts = append(opts, devChainOptions()...)
+		} else {
+			chainOpts, err := chainOptions(cmd.Flags())
+			if err != nil {
+				logger.Fatal().Err(err).Msg("failed to prepare chain clients")
+			}
+			opts = append(opts, chainOpts...)
+		}
+		opts = append(opts, app.WithEncryption(cipher))
+		paceOpts, err := projectionOptions(cmd.Flags())
+		if err != nil {
+			logger.Fatal().Err(err).Msg("invalid projection pace")
+		}
+		opts = append(opts, paceOpts...)
+		if timeout, _ := cmd.Flags().GetDuration("refund-timeout"); timeout > 0 {
+			opts = append(opts, app.WithRefundTimeout(timeout))
+		}
+		matchTimeout, _ := cmd.Flags().GetDuration("match-confirmation-timeout")
+		opts = append(opts, app.WithMatchConfirmationTimeout(matchTimeout))
+		keygenRetries, _ := cmd.Flags().GetInt("keygen-retries")
+		opts = append(opts, app.WithKeygenRetries(keygenRetries))
+		maxWaiting, _ := cmd.Flags().GetInt("max-waiting-pairs-per-plan")
+		maxActive, _ := cmd.Flags().GetInt("max-active-pairs-per-address")
+		opts = append(opts, app.WithPairQuotas(commands.PairQuotas{MaxWaitingPerPlan: maxWaiting, MaxActive: maxActive}))
+		// The developers match their own pairs from a single wallet in the development mode
+		if selfMatching, _ := cmd.Flags().GetBool("allow-self-matching"); selfMatching || dev {
+			opts = append(opts, app.WithSelfMatching())
+		}
+		if unverified, _ := cmd.Flags().GetBool("accept-unverified-txs"); unverified {
+			opts = append(opts, app.WithUnverifiedTxs())
+		}
+		if retention, _ := cmd.Flags().GetDuration("archive-after"); retention > 0 {
+			opts = append(opts, app.WithPairArchiving(retention))
+		}
+		if kycURL, _ := cmd.Flags().GetString("kyc-url"); kycURL != "" {
+			apiKey, _ := cmd.Flags().GetString("kyc-api-key")
+			ttl, _ := cmd.Flags().GetDuration("kyc-cache-ttl")
+			opts = append(opts, app.WithComplianceChecker(adapters.NewKYCProvider(kycURL, apiKey), ttl))
+		}
+		opts = append(opts, app.WithLogLevels(logLevels))
+		app, err := app.NewApplication(db, logger, opts...)
 		if err != nil {
 			logger.Fatal().Err(err).Msg("failed to create application instance")
 		}
 		app.StartProjections()
 		defer app.StopProjections()
+		if dev {
+			if err := seedDevPlans(cmd.Context(), app); err != nil {
+				logger.Fatal().Err(err).Msg("failed to seed demo plans")
+			}
+		}
 
 		server := ports.NewHttpServer(app)
-		server.WithLogger(logger)
+		server.WithLogger(logLevels.Logger(logger, common.LogModuleHTTP))
+		server.WithLogLevels(logLevels)
+		adminTokens, _ := cmd.Flags().GetStringToString("admin-tokens")
+		if dev && len(adminTokens) == 0 {
+			adminTokens = map[string]string{devAdminToken: devAdminToken}
+			logger.Warn().Str("token", devAdminToken).Msg("the admin APIs accept the development token")
+		}
+		server.WithAdminTokens(adminTokens)
+		origins, _ := cmd.Flags().GetStringSlice("cors-origins")
+		methods, _ := cmd.Flags().GetStringSlice("cors-methods")
+		headers, _ := cmd.Flags().GetStringSlice("cors-headers")
+		server.WithCORS(origins, methods, headers)
+		hstsMaxAge, _ := cmd.Flags().GetDuration("hsts-max-age")
+		server.WithHSTS(int(hstsMaxAge.Seconds()))
+		bodyLimit, routeBodyLimits, err := bodyLimits(cmd.Flags())
+		if err != nil {
+			logger.Fatal().Err(err).Msg("invalid body limits")
+		}
+		server.WithBodyLimits(bodyLimit, routeBodyLimits)
+		requestTimeout, routeTimeouts, err := requestTimeouts(cmd.Flags())
+		if err != nil {
+			logger.Fatal().Err(err).Msg("invalid request timeouts")
+		}
+		server.WithRequestTimeouts(requestTimeout, routeTimeouts)
+		compression, err := responseCompression(cmd.Flags())
+		if err != nil {
+			logger.Fatal().Err(err).Msg("invalid response compression")
+		}
+		if err := server.WithCompression(compression); err != nil {
+			logger.Fatal().Err(err).Msg("invalid response compression")
+		}
+		if err := configureRoutePolicies(server, cmd.Flags()); err != nil {
+			logger.Fatal().Err(err).Msg("invalid route policies")
+		}
+		bindIP, _ := cmd.Flags().GetBool("auth-bind-ip")
+		bindUserAgent, _ := cmd.Flags().GetBool("auth-bind-user-agent")
+		server.WithChallengeBinding(bindIP, bindUserAgent)
+		if secret, _ := cmd.Flags().GetString("jwt-secret"); secret != "" {
+			ttl, _ := cmd.Flags().GetDuration("jwt-ttl")
+			server.WithJWT([]byte(secret), ttl)
+		}
+		if kycAtAuth, _ := cmd.Flags().GetBool("kyc-at-auth"); kycAtAuth {
+			server.WithComplianceAtAuth()
+		}
 
-		if err := server.Start(port); err != nil {
+		if path, _ := cmd.Flags().GetString("response-signing-key-file"); path != "" {
+			signer, err := common.ReadSigningKeyFile(path)
+			if err != nil {
+				logger.Fatal().Err(err).Msg("failed to read response signing key")
+			}
+			server.WithResponseSigning(signer)
+			logger.Info().Str("key_id", signer.KeyId()).Msg("signing the pair responses")
+		}
+
+		if err := startServer(server, port, cmd.Flags()); err != nil {
 			logger.Fatal().Err(err).Msg("failed to start server")
 		}
 	},
 }
 
-func prepareDB(flags *pflag.FlagSet) (*sql.DB, error) {
-	connStr, _ := flags.GetString("db")
+func startServer(server *ports.HttpServer, port string, flags *pflag.FlagSet) error {
+	certFile, _ := flags.GetString("tls-cert")
+	keyFile, _ := flags.GetString("tls-key")
+	domains, _ := flags.GetStringSlice("autocert-domains")
+	cacheDir, _ := flags.GetString("autocert-cache-dir")
+
+	switch {
+	case certFile != "" || keyFile != "":
+		if certFile == "" || keyFile == "" {
+			return fmt.Errorf("both --tls-cert and --tls-key are required to enable TLS")
+		}
+		return server.StartTLS(port, certFile, keyFile)
+	case len(domains) > 0:
+		return server.StartAutoTLS(port, domains, cacheDir)
+	default:
+		logger.Warn().Msg("serving plaintext HTTP, use --tls-cert/--tls-key or --autocert-domains to enable TLS")
+		return server.Start(port)
+	}
+}
+
+// bodyLimits parses the default and per route body limits, given in bytes or with a unit (e.g. 64K, 1M)
+func bodyLimits(flags *pflag.FlagSet) (int64, map[string]int64, error) {
+	defaultLimit, _ := flags.GetString("body-limit")
+	limit, err := bytes.Parse(defaultLimit)
+	if err != nil {
+		return 0, nil, fmt.Errorf("invalid --body-limit: %w", err)
+	}
+
+	routes, _ := flags.GetStringToString("route-body-limits")
+	routeLimits := make(map[string]int64, len(routes))
+	for route, value := range routes {
+		if routeLimits[route], err = bytes.Parse(value); err != nil {
+			return 0, nil, fmt.Errorf("invalid --route-body-limits for %s: %w", route, err)
+		}
+	}
 
-	db, err := sql.Open("sqlite3", connStr)
+	return limit, routeLimits, nil
+}
+
+// responseCompression parses the compression of the responses, the minimum size is given in bytes or with a unit (e.g. 1K)
+func responseCompression(flags *pflag.FlagSet) (ports.Compression, error) {
+	compression := ports.DefaultCompression
+	if enabled, _ := flags.GetBool("compression"); !enabled {
+		compression.MinSize = -1
+		return compression, nil
+	}
+
+	minSize, _ := flags.GetString("compression-min-size")
+	size, err := bytes.Parse(minSize)
 	if err != nil {
-		return nil, err
+		return compression, fmt.Errorf("invalid --compression-min-size: %w", err)
+	}
+	compression.MinSize = int(size)
+	compression.GzipLevel, _ = flags.GetInt("gzip-level")
+	compression.BrotliLevel, _ = flags.GetInt("brotli-level")
+	compression.UncompressedRoutes, _ = flags.GetStringSlice("uncompressed-routes")
+
+	return compression, nil
+}
+
+// requestTimeouts parses the default and per route request timeouts
+func requestTimeouts(flags *pflag.FlagSet) (time.Duration, map[string]time.Duration, error) {
+	defaultTimeout, _ := flags.GetDuration("request-timeout")
+
+	routes, _ := flags.GetStringToString("route-timeouts")
+	routeTimeouts := make(map[string]time.Duration, len(routes))
+	for route, value := range routes {
+		timeout, err := time.ParseDuration(value)
+		if err != nil {
+			return 0, nil, fmt.Errorf("invalid --route-timeouts for %s: %w", route, err)
+		}
+		routeTimeouts[route] = timeout
 	}
-	db.SetMaxOpenConns(1)
 
-	return db, nil
+	return defaultTimeout, routeTimeouts, nil
+}
+
+// configureRoutePolicies applies the authentication and the allowlists of the routes and the trusted proxies to the server
+func configureRoutePolicies(server *ports.HttpServer, flags *pflag.FlagSet) error {
+	proxies, _ := flags.GetStringSlice("trusted-proxies")
+	if len(proxies) > 0 {
+		networks, err := ports.ParseNetworks(proxies)
+		if err != nil {
+			return fmt.Errorf("invalid --trusted-proxies: %w", err)
+		}
+		server.WithTrustedProxies(networks)
+	}
+
+	routes, _ := flags.GetStringToString("route-auth")
+	routeAuth := make(map[string]ports.AuthRequirement, len(routes))
+	for route, value := range routes {
+		auth, err := ports.ParseAuthRequirement(value)
+		if err != nil {
+			return fmt.Errorf("invalid --route-auth for %s: %w", route, err)
+		}
+		routeAuth[route] = auth
+	}
+	server.WithRouteAuth(routeAuth)
+
+	routes, _ = flags.GetStringToString("route-allowed-ips")
+	routeAllowedIPs := make(map[string][]*net.IPNet, len(routes))
+	for route, value := range routes {
+		networks, err := ports.ParseNetworks(strings.FieldsFunc(value, func(r rune) bool { return r == ';' || r == ' ' }))
+		if err != nil {
+			return fmt.Errorf("invalid --route-allowed-ips for %s: %w", route, err)
+		}
+		routeAllowedIPs[route] = networks
+	}
+	server.WithRouteAllowedIPs(routeAllowedIPs)
+
+	return nil
+}
+
+// projectionOptions returns the options pacing the projections, the pace of a projection is either a single delay
+// or the minimum and the maximum delays separated by a colon (e.g. pairs_stats=1s:30s)
+func projectionOptions(flags *pflag.FlagSet) ([]app.Option, error) {
+	minPace, _ := flags.GetDuration("projection-min-pace")
+	maxPace, _ := flags.GetDuration("projection-max-pace")
+	opts := []app.Option{app.WithProjectionPace(common.ProjectionPace{Min: minPace, Max: maxPace})}
+
+	paces, _ := flags.GetStringToString("projection-paces")
+	for name, value := range paces {
+		minValue, maxValue, ok := strings.Cut(value, ":")
+		if !ok {
+			maxValue = minValue
+		}
+		var pace common.ProjectionPace
+		var err error
+		if pace.Min, err = time.ParseDuration(minValue); err != nil {
+			return nil, fmt.Errorf("invalid --projection-paces for %s: %w", name, err)
+		}
+		if pace.Max, err = time.ParseDuration(maxValue); err != nil {
+			return nil, fmt.Errorf("invalid --projection-paces for %s: %w", name, err)
+		}
+		opts = append(opts, app.WithProjectionPace(pace, name))
+	}
+
+	if trigger, _ := flags.GetBool("projection-trigger"); trigger {
+		opts = append(opts, app.WithProjectionTrigger())
+	}
+
+	return opts, nil
+}
+
+func notificationOptions(flags *pflag.FlagSet) []app.Option {
+	var channels []notifications.Channel
+
+	smtpAddr, _ := flags.GetString("smtp-addr")
+	if smtpAddr != "" {
+		from, _ := flags.GetString("smtp-from")
+		username, _ := flags.GetString("smtp-username")
+		password, _ := flags.GetString("smtp-password")
+		channels = append(channels, adapters.NewEmailChannel(smtpAddr, from, username, password))
+	}
+
+	pushURL, _ := flags.GetString("push-url")
+	if pushURL != "" {
+		apiKey, _ := flags.GetString("push-api-key")
+		channels = append(channels, adapters.NewPushChannel(pushURL, apiKey))
+	}
+
+	if len(channels) == 0 {
+		return nil
+	}
+
+	return []app.Option{app.WithNotificationChannels(channels...)}
+}
+
+func chainOptions(flags *pflag.FlagSet) ([]app.Option, error) {
+	thorChainId, _ := flags.GetString("thorchain-chain-id")
+	ethChainId, _ := flags.GetInt64("eth-chain-id")
+	thorPrefix, _ := flags.GetString("thorchain-bech32-prefix")
+	btcHRP, _ := flags.GetString("btc-bech32-hrp")
+	ethRouter, _ := flags.GetString("eth-router-address")
+	breakerFailures, _ := flags.GetInt("chain-breaker-failures")
+	breakerCooldown, _ := flags.GetDuration("chain-breaker-cooldown")
+	breaker := httpclient.WithCircuitBreaker(breakerFailures, breakerCooldown)
+	chainLogger := httpclient.WithLogger(logLevels.Logger(logger, common.LogModuleChains))
+	opts := []app.Option{
+		app.WithTxDecoder("THOR", adapters.NewThorchainTxDecoder(thorChainId)),
+		app.WithTxDecoder("ETH", adapters.NewEthereumTxDecoder(ethChainId, ethRouter)),
+		app.WithWalletDeriver("THOR", adapters.NewThorchainWalletDeriver(thorPrefix)),
+		app.WithWalletDeriver("ETH", adapters.NewEthereumWalletDeriver()),
+		app.WithWalletDeriver("BTC", adapters.NewBitcoinWalletDeriver(btcHRP)),
+		app.WithSignatureVerifier(domain.SignatureSchemeECDSASecp256k1, adapters.NewSecp256k1Verifier()),
+		app.WithSignatureVerifier(domain.SignatureSchemeEdDSAEd25519, adapters.NewEd25519Verifier()),
+	}
+
+	midgardURLs, _ := flags.GetStringSlice("midgard-url")
+	if len(midgardURLs) > 0 {
+		midgard, err := adapters.NewMidgardClient(midgardURLs, breaker, chainLogger)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts,
+			// Midgard indexes the inbound transactions of every chain, the Ethereum node replaces it for ETH when it's given
+			app.WithLPVerifier("THOR", midgard),
+			app.WithLPVerifier("BTC", midgard),
+			app.WithLPVerifier("ETH", midgard),
+			app.WithSaversVerifier("BTC", midgard),
+			app.WithSaversVerifier("ETH", midgard),
+			app.WithWithdrawalVerifier("THOR", midgard),
+			app.WithPriceOracle(midgard),
+			app.WithPositionSource(midgard),
+			app.WithFeeEstimator("THOR", midgard),
+			app.WithTxStatusChecker("THOR", midgard),
+		)
+	} else {
+		logger.Warn().Msg("LP and savers transactions can't be verified, the value locked is not priced and withdrawals are not settled, use --midgard-url to enable them")
+	}
+
+	ethRPCURLs, _ := flags.GetStringSlice("eth-rpc-url")
+	if len(ethRPCURLs) > 0 {
+		client, err := adapters.NewEthereumClient(ethRPCURLs, ethRouter, breaker, chainLogger)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts,
+			app.WithLPVerifier("ETH", client),
+			app.WithDepositVerifier("ETH", client),
+			app.WithTxBroadcaster("ETH", adapters.NewEthereumTxBroadcaster(client, ethChainId)),
+			app.WithFeeEstimator("ETH", client),
+			app.WithTxStatusChecker("ETH", client),
+			app.WithNonceSource("ETH", client),
+		)
+	} else {
+		logger.Warn().Msg("Ethereum deposits can't be verified nor refunds broadcasted, use --eth-rpc-url to enable them")
+	}
+
+	return opts, nil
+}
+
+func prepareDB(flags *pflag.FlagSet) (*common.DB, error) {
+	connStr, _ := flags.GetString("db")
+	readConns, _ := flags.GetInt("db-read-conns")
+	busyTimeout, _ := flags.GetDuration("db-busy-timeout")
+
+	return common.OpenSQLite(connStr, readConns, busyTimeout)
 }
 
 func init() {
 	rootCmd.AddCommand(serveCmd)
 
 	serveCmd.Flags().StringP("port", "p", ":8080", "Port to listen on")
+	serveCmd.Flags().Bool("dev", false, "Serve an in-memory database seeded with demo plans and fake chains, for development only")
+	serveCmd.Flags().String("tls-cert", "", "Path to the TLS certificate file")
+	serveCmd.Flags().String("tls-key", "", "Path to the TLS private key file")
+	serveCmd.Flags().StringSlice("autocert-domains", nil, "Comma separated list of domains to issue Let's Encrypt certificates for")
+	serveCmd.Flags().String("autocert-cache-dir", ".autocert", "Directory to cache Let's Encrypt certificates in")
+	serveCmd.Flags().String("smtp-addr", "", "SMTP server address (host:port) to send email notifications through")
+	serveCmd.Flags().String("smtp-from", "no-reply@co-defi.app", "Sender address of the email notifications")
+	serveCmd.Flags().String("smtp-username", "", "SMTP username")
+	serveCmd.Flags().String("smtp-password", "", "SMTP password")
+	serveCmd.Flags().String("push-url", "", "Push gateway URL to send push notifications through")
+	serveCmd.Flags().String("push-api-key", "", "Push gateway API key")
+	serveCmd.Flags().StringToString("admin-tokens", nil, "Tokens of the operators allowed to use the admin APIs (e.g. alice=secret1,bob=secret2)")
+	serveCmd.Flags().StringSlice("cors-origins", []string{"*"}, "Comma separated list of the origins allowed to make cross-origin requests")
+	serveCmd.Flags().StringSlice("cors-methods", nil, "Comma separated list of the methods allowed in cross-origin requests, echo's defaults when empty")
+	serveCmd.Flags().StringSlice("cors-headers", nil, "Comma separated list of the headers allowed in cross-origin requests, the requested ones when empty")
+	serveCmd.Flags().Duration("hsts-max-age", 365*24*time.Hour, "How long the browsers must only reach the server over HTTPS, 0 disables HSTS")
+	serveCmd.Flags().String("body-limit", "64K", "Maximum size of the request bodies, unless set otherwise for their route")
+	serveCmd.Flags().StringToString("route-body-limits", nil, "Maximum size of the request bodies by route (e.g. /pairs/:id/assurances=1M), the routes carrying signed transactions allow larger bodies by default")
+	serveCmd.Flags().Duration("request-timeout", 15*time.Second, "How long the requests may take before failing with a request timeout error, unless set otherwise for their route")
+	serveCmd.Flags().StringToString("route-timeouts", nil, "How long the requests may take by route (e.g. /pairs/:id/wait=2m), the long-polls allow longer requests by default")
+	serveCmd.Flags().Bool("compression", true, "Compress the responses with brotli or gzip, as accepted by the clients")
+	serveCmd.Flags().String("compression-min-size", "1K", "Size from which the responses are compressed, the smaller ones are sent as is")
+	serveCmd.Flags().Int("gzip-level", -1, "Compression level of the gzip responses, from -2 (Huffman only) to 9, -1 is the default level")
+	serveCmd.Flags().Int("brotli-level", 4, "Compression level of the brotli responses, from 0 to 11")
+	serveCmd.Flags().StringSlice("uncompressed-routes", nil, "Comma separated list of the routes whose responses are never compressed (e.g. /pairs/:id/wait)")
+	serveCmd.Flags().StringToString("route-auth", nil, "Authentication required by route, none, participant or admin (e.g. /admin/metrics=none), a route ending with /* applies to the routes under it")
+	serveCmd.Flags().StringToString("route-allowed-ips", nil, "Networks allowed to call the routes, separated by semicolons (e.g. /admin/*=10.0.0.0/8;192.168.1.10), any network when not set")
+	serveCmd.Flags().StringSlice("trusted-proxies", nil, "Networks of the proxies whose X-Forwarded-For header tells the address of the clients, the remote address of the connection is used when empty")
+	serveCmd.Flags().Bool("auth-bind-ip", false, "Reject the authentication challenges verified from another IP address than the one that initialized them")
+	serveCmd.Flags().Bool("auth-bind-user-agent", false, "Reject the authentication challenges verified from another user agent than the one that initialized them")
+	serveCmd.Flags().String("jwt-secret", "", "Secret signing the JWTs of the stateless authentication mode, the mode is disabled when empty")
+	serveCmd.Flags().String("response-signing-key-file", "", "File holding the Ed25519 seed (hex or base64) signing the responses of the pair state routes, they are unsigned when empty")
+	serveCmd.Flags().Duration("jwt-ttl", time.Hour, "How long the JWTs of the stateless authentication mode are valid")
+	serveCmd.Flags().Duration("refund-timeout", 72*time.Hour, "How long a deposit waits for the counterparty's before its depositor can request a refund")
+	serveCmd.Flags().Duration("match-confirmation-timeout", 24*time.Hour, "How long a counterparty has to confirm the wallet before the pair goes back to waiting, 0 never reverts the matches")
+	serveCmd.Flags().Int("keygen-retries", 3, "How many times the wallet of a match is regenerated after a failed keygen before the pair goes back to waiting")
+	serveCmd.Flags().Int("max-waiting-pairs-per-plan", 3, "Number of pairs an address may have waiting for a counterparty in a plan, 0 disables the quota")
+	serveCmd.Flags().Int("max-active-pairs-per-address", 20, "Number of pairs an address may have in progress overall, 0 disables the quota")
+	serveCmd.Flags().Bool("allow-self-matching", false, "Let the participants match the pairs created from their own addresses, for testing only")
+	serveCmd.Flags().String("kyc-url", "", "URL of the KYC provider the participants must be approved by to create or match pairs, they aren't verified when empty")
+	serveCmd.Flags().String("kyc-api-key", "", "API key of the KYC provider")
+	serveCmd.Flags().Duration("kyc-cache-ttl", 24*time.Hour, "How long the verifications of the KYC provider are cached per address, the pending ones are checked again sooner")
+	serveCmd.Flags().Bool("kyc-at-auth", false, "Require the participants to be approved by the KYC provider to authenticate too")
+	serveCmd.Flags().Duration("projection-min-pace", common.DefaultProjectionPace.Min, "How soon the projections look for new events after handling some")
+	serveCmd.Flags().Duration("projection-max-pace", common.DefaultProjectionPace.Max, "How long the idle projections back off to between looking for new events")
+	serveCmd.Flags().StringToString("projection-paces", nil, "Pace by projection, a delay or the minimum and maximum delays (e.g. pairs_stats=1s:30s)")
+	serveCmd.Flags().Bool("projection-trigger", false, "Wake up the projections as soon as events are saved, instead of waiting for their next look")
+	serveCmd.Flags().Duration("archive-after", 30*24*time.Hour, "Archive the withdrawn and invalid pairs after this long, 0 disables archiving")
+	serveCmd.Flags().String("thorchain-chain-id", "thorchain-1", "THORChain network the pre-signed transactions must belong to")
+	serveCmd.Flags().String("thorchain-bech32-prefix", "thor", "Bech32 prefix of the THORChain addresses of the pairs' wallets")
+	serveCmd.Flags().String("btc-bech32-hrp", "bc", "Bech32 human readable part of the Bitcoin addresses of the pairs' wallets")
+	serveCmd.Flags().Int64("eth-chain-id", 1, "Ethereum network the pre-signed transactions must belong to")
+	serveCmd.Flags().StringSlice("midgard-url", nil, "THORChain Midgard URLs to verify THORChain transactions and price the assets with (e.g. https://midgard.ninerealms.com), repeated or comma separated to fail over between them")
+	serveCmd.Flags().StringSlice("eth-rpc-url", nil, "Ethereum JSON-RPC URLs to verify Ethereum transactions with, repeated or comma separated to fail over between them")
+	serveCmd.Flags().String("eth-router-address", "", "Address of the THORChain router contract on Ethereum")
+	serveCmd.Flags().Int("chain-breaker-failures", 5, "Number of failures in a row after which a chain is considered unavailable, 0 disables the circuit breakers")
+	serveCmd.Flags().Duration("chain-breaker-cooldown", 30*time.Second, "How long the requests to an unavailable chain are rejected before it's tried again")
+	serveCmd.Flags().Bool("accept-unverified-txs", false, "Accept the transactions of the chains which are unavailable or have no verifier, e.g. the THORChain and Bitcoin deposits, flagged as unverified instead of failing with chain_unavailable")
 }
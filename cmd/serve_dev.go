@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/co-defi/api-server/adapters"
+	"github.com/co-defi/api-server/app"
+	"github.com/co-defi/api-server/app/commands"
+	"github.com/co-defi/api-server/domain"
+)
+
+// devAdminToken is the token of the dev operator of the admin routes in the development mode, unless tokens are given
+const devAdminToken = "dev"
+
+// devPrices are the fixed prices in USD the development mode values the assets with
+var devPrices = map[domain.Asset]float64{
+	domain.RuneAsset: 5,
+	"BTC.BTC":        60000,
+	"ETH.ETH":        3000,
+	"ETH.USDC-0XA0B86991C6218B36C1D19D4A2E9EB0CE3606EB48": 1,
+	"ETH.USDT-0XDAC17F958D2EE523A2206206994597C13D831EC7": 1,
+}
+
+// devPlans are the plans the development mode starts with
+var devPlans = []commands.CreateNewPlan{
+	{Assets: []domain.Asset{domain.RuneAsset, "BTC.BTC"}, InvestingPeriod: 1, InvestingPeriodUnit: domain.PeriodUnitWeek},
+	{Assets: []domain.Asset{domain.RuneAsset, "ETH.ETH"}, InvestingPeriod: 1, InvestingPeriodUnit: domain.PeriodUnitMonth, MaxShareMultiplier: 5},
+	{Assets: []domain.Asset{domain.RuneAsset, "ETH.USDC-0XA0B86991C6218B36C1D19D4A2E9EB0CE3606EB48"}, InvestingPeriod: 1, InvestingPeriodUnit: domain.PeriodUnitDay},
+	{Type: domain.PlanTypeSavers, Assets: []domain.Asset{"BTC.BTC", "ETH.ETH"}, InvestingPeriod: 2, InvestingPeriodUnit: domain.PeriodUnitWeek},
+	{Assets: []domain.Asset{domain.RuneAsset, "BTC.BTC"}, InvestingPeriod: 1, InvestingPeriodUnit: domain.PeriodUnitDay, Network: domain.NetworkTestnet},
+}
+
+// devChainOptions wires the chains of the development mode: every transaction is accepted as declared and final at once,
+// the wallet addresses and the pre-signed transactions are trusted as is, their signatures being accepted unverified, and
+// the assets are valued with fixed prices.
+// The withdrawals aren't settled, as the amounts they paid out can't be made up.
+func devChainOptions() []app.Option {
+	prices := adapters.NewDevPrices(devPrices)
+	opts := []app.Option{app.WithPriceOracle(prices), app.WithPositionSource(prices), app.WithUnverifiedTxs()}
+	chains := map[string]*adapters.DevChain{
+		"THOR": adapters.NewDevChain("THOR", domain.RuneAsset, "2000000"),
+		"BTC":  adapters.NewDevChain("BTC", "BTC.BTC", "2000"),
+		"ETH":  adapters.NewDevChain("ETH", "ETH.ETH", "420000000000000"),
+	}
+	for name, chain := range chains {
+		opts = append(opts,
+			app.WithLPVerifier(name, chain),
+			app.WithSaversVerifier(name, chain),
+			app.WithDepositVerifier(name, chain),
+			app.WithTxBroadcaster(name, chain),
+			app.WithFeeEstimator(name, chain),
+			app.WithTxStatusChecker(name, chain),
+		)
+	}
+
+	return append(opts, app.WithProjectionTrigger())
+}
+
+// seedDevPlans creates the plans the development mode starts with, on the empty in-memory database
+func seedDevPlans(ctx context.Context, application *app.Application) error {
+	for _, plan := range devPlans {
+		plan.Security = domain.MultiSigWalletSecurity2Of2
+		plan.Strategy = domain.ProfitSharingStrategyEqualShare
+		plan.Quantum = 100
+		plan.LossProtection = 0.1
+		if _, err := application.Commands.CreateNewPlan.Handle(ctx, plan); err != nil {
+			return fmt.Errorf("failed to seed plan of %v: %w", plan.Assets, err)
+		}
+	}
+
+	return nil
+}
+
+// devDBName names the in-memory database of the development mode after the process, so the servers running side by side don't share it
+func devDBName() string {
+	return fmt.Sprintf("co-defi-dev-%d", os.Getpid())
+}
@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"github.com/co-defi/api-server/app"
+	"github.com/spf13/cobra"
+)
+
+// verifyProjectionsCmd represents the verify-projections command
+var verifyProjectionsCmd = &cobra.Command{
+	Use:   "verify-projections",
+	Short: "Detect the corrupt rows of the projections",
+	Long: `This command scans the rows of the pairs and plans projections and reports the ones that can't be decoded.
+It exits with an error when any is found, the projections can then be rebuilt with reset-projections.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		db, err := prepareDB(cmd.Flags())
+		if err != nil {
+			logger.Fatal().Err(err).Msg("failed to open database")
+		}
+		defer db.Close()
+
+		cipher, err := prepareCipher(cmd.Context(), cmd.Flags())
+		if err != nil {
+			logger.Fatal().Err(err).Msg("failed to prepare encryption")
+		}
+
+		app, err := app.NewApplication(db, logger, app.WithEncryption(cipher))
+		if err != nil {
+			logger.Fatal().Err(err).Msg("failed to create application instance")
+		}
+
+		corrupt, err := app.VerifyProjections(cmd.Context())
+		if err != nil {
+			logger.Fatal().Err(err).Msg("failed to verify projections")
+		}
+		for _, c := range corrupt {
+			logger.Warn().Str("table", c.Table).Str("id", c.Id).Str("column", c.Column).Err(c.Err).Msg("corrupt row")
+		}
+		if len(corrupt) > 0 {
+			logger.Fatal().Int("count", len(corrupt)).Msg("corrupt rows found")
+		}
+
+		logger.Info().Msg("no corrupt rows found")
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(verifyProjectionsCmd)
+}
@@ -1,77 +1,263 @@
 package common
 
 import (
+	"bytes"
 	"context"
+	"crypto/ecdsa"
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/base64"
+	"encoding/binary"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/allegro/bigcache/v3"
+	"github.com/co-defi/api-server/domain"
+	"github.com/cosmos/btcutil/base58"
 	"github.com/cosmos/btcutil/bech32"
 	ethaccounts "github.com/ethereum/go-ethereum/accounts"
 	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+	"github.com/golang-jwt/jwt"
 	"github.com/google/uuid"
 	"golang.org/x/crypto/ripemd160"
 )
 
 const tokensTTL = 1 * time.Hour
 
-// AuthenticationDB is a cache for storing authentication tokens
+// AuthenticationDB is a cache for storing authentication tokens.
+// Besides the tokens by id, it keeps the ids of the tokens issued to each address to list their sessions
+// and the ids of the revoked tokens, which all expire along with the tokens.
 type AuthenticationDB struct {
 	cache *bigcache.BigCache
+	// sessionsMu serializes the updates of the session indexes
+	sessionsMu sync.Mutex
+	// jwt issues the verified tokens as JWTs when the stateless mode is enabled
+	jwt *JWTIssuer
+	// verifyMu serializes the verifications so a challenge can't be verified twice concurrently
+	verifyMu sync.Mutex
+	binding  ClientBinding
+	clock    Clock
+	// apiKeys authenticates the requests bearing an API key in place of a token, when enabled
+	apiKeys APIKeyAuthenticator
 }
 
-// NewAuthenticationDB creates a new AuthenticationDB
-func NewAuthenticationDB() *AuthenticationDB {
+// HeaderAPIKey is the header the machine integrations authenticate with in place of a Bearer token
+const HeaderAPIKey = "X-API-Key"
+
+// APIKeyAuthenticator authenticates the API keys of the bots and the internal services acting as participants
+type APIKeyAuthenticator interface {
+	// AuthenticateParticipant returns the token of the participant the API key acts as
+	AuthenticateParticipant(ctx context.Context, key string) (Token, error)
+}
+
+// Client identifies the client an authentication request is made from
+type Client struct {
+	IP        string
+	UserAgent string
+}
+
+// ClientBinding tells which properties of the client initializing a token must match the client verifying its challenge
+type ClientBinding struct {
+	IP        bool
+	UserAgent bool
+}
+
+// NewAuthenticationDB creates a new AuthenticationDB expiring the tokens by the clock
+func NewAuthenticationDB(clock Clock) *AuthenticationDB {
 	cache, _ := bigcache.New(context.Background(), bigcache.DefaultConfig(tokensTTL))
 
-	return &AuthenticationDB{cache: cache}
+	return &AuthenticationDB{cache: cache, clock: clock}
+}
+
+// EnableJWT enables the stateless mode, the verified tokens are issued as JWTs by the issuer
+// and the requests bearing them are authenticated without the token store
+func (a *AuthenticationDB) EnableJWT(issuer *JWTIssuer) {
+	a.jwt = issuer
+}
+
+// EnableAPIKeys accepts the API keys authenticated by keys in the X-API-Key header of the requests,
+// in place of the Bearer tokens of the wallet-signature challenges
+func (a *AuthenticationDB) EnableAPIKeys(keys APIKeyAuthenticator) {
+	a.apiKeys = keys
 }
 
-// Init initializes an authentication token
-func (a *AuthenticationDB) Init(chain Chain, address string) (Token, error) {
-	token, err := newToken(chain, address)
+// BindChallenges binds the challenges to the properties of the client initializing them,
+// a challenge verified from a different client is rejected
+func (a *AuthenticationDB) BindChallenges(binding ClientBinding) {
+	a.binding = binding
+}
+
+// JWTIssuer returns the issuer of the JWTs, nil when the stateless mode isn't enabled
+func (a *AuthenticationDB) JWTIssuer() *JWTIssuer {
+	return a.jwt
+}
+
+// Init initializes an authentication token scoped to the network for the client. The address of the token is derived
+// from the public key on the chain, so a token can only be initialized for the address of the key the challenge must be signed with.
+func (a *AuthenticationDB) Init(chain Chain, pubKey []byte, network domain.Network, client Client) (Token, error) {
+	address, err := AddressFromPublicKey(chain, pubKey)
 	if err != nil {
 		return Token{}, err
 	}
 
+	token, err := newToken(chain, address, domain.NetworkOrDefault(network), a.clock.Now())
+	if err != nil {
+		return Token{}, err
+	}
+	if a.binding.IP {
+		token.ClientIP = client.IP
+	}
+	if a.binding.UserAgent {
+		token.UserAgent = client.UserAgent
+	}
+
 	err = a.cache.Set(token.Id.String(), token.Bytes())
 	if err != nil {
 		return Token{}, err
 	}
 
+	if err := a.updateSessions(chain, address, func(ids []uuid.UUID) []uuid.UUID {
+		return append(ids, token.Id)
+	}); err != nil {
+		return Token{}, err
+	}
+
 	return token, nil
 }
 
+var (
+	ErrSessionNotFound       = NewError("session_not_found", "session not found")
+	ErrAuthenticationRevoked = NewError("auth_revoked", "authentication revoked")
+)
+
+// Sessions returns the unexpired tokens issued to the address on the chain
+func (a *AuthenticationDB) Sessions(chain Chain, address string) ([]Token, error) {
+	tokens := make([]Token, 0)
+	err := a.updateSessions(chain, address, func(ids []uuid.UUID) []uuid.UUID {
+		active := ids[:0]
+		for _, id := range ids {
+			token, err := a.Get(id)
+			if err != nil || token.ExpiresAt < a.clock.Now().Unix() {
+				continue
+			}
+			tokens = append(tokens, token)
+			active = append(active, id)
+		}
+		return active
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+// Revoke revokes the token issued to the address on the chain, the token is rejected from then on
+func (a *AuthenticationDB) Revoke(chain Chain, address string, id uuid.UUID) error {
+	token, err := a.Get(id)
+	if err != nil || token.Chain != chain || token.Address != address {
+		return ErrSessionNotFound
+	}
+
+	if err := a.cache.Set(revokedKey(id), []byte{1}); err != nil {
+		return err
+	}
+	if err := a.cache.Delete(id.String()); err != nil && err != bigcache.ErrEntryNotFound {
+		return err
+	}
+
+	return a.updateSessions(chain, address, func(ids []uuid.UUID) []uuid.UUID {
+		remaining := ids[:0]
+		for _, i := range ids {
+			if i != id {
+				remaining = append(remaining, i)
+			}
+		}
+		return remaining
+	})
+}
+
+// IsRevoked checks if the token is in the revocation list
+func (a *AuthenticationDB) IsRevoked(id uuid.UUID) bool {
+	_, err := a.cache.Get(revokedKey(id))
+	return err == nil
+}
+
+func revokedKey(id uuid.UUID) string {
+	return "revoked:" + id.String()
+}
+
+func sessionsKey(chain Chain, address string) string {
+	return "sessions:" + chain + ":" + address
+}
+
+// updateSessions replaces the ids of the tokens issued to the address with the result of update
+func (a *AuthenticationDB) updateSessions(chain Chain, address string, update func([]uuid.UUID) []uuid.UUID) error {
+	a.sessionsMu.Lock()
+	defer a.sessionsMu.Unlock()
+
+	key := sessionsKey(chain, address)
+	var ids []uuid.UUID
+	if buf, err := a.cache.Get(key); err == nil {
+		if err := json.Unmarshal(buf, &ids); err != nil {
+			return err
+		}
+	}
+
+	buf, err := json.Marshal(update(ids))
+	if err != nil {
+		return err
+	}
+	return a.cache.Set(key, buf)
+}
+
 var (
 	ErrAuthenticationExpired            = NewError("auth_expired", "authentication expired or not found")
 	ErrAuthenticationVerificationFailed = NewError("auth_verification_failed", "authentication verification failed")
+	ErrChallengeAlreadyUsed             = NewError("auth_challenge_used", "authentication challenge already used")
+	ErrAuthenticationClientMismatch     = NewError("auth_client_mismatch", "authentication challenge initialized by another client")
 )
 
-// Verify verifies an authentication token
-func (a *AuthenticationDB) Verify(id uuid.UUID, signature []byte) error {
+// Verify verifies the challenge of an authentication token signed by the client and returns the verified token.
+// Challenges are single-use, once verified the token can't be verified again even with the same signature.
+func (a *AuthenticationDB) Verify(id uuid.UUID, signature []byte, client Client) (Token, error) {
+	a.verifyMu.Lock()
+	defer a.verifyMu.Unlock()
+
 	token, err := a.Get(id)
 	if err != nil {
-		return ErrAuthenticationExpired
+		if a.IsRevoked(id) {
+			return Token{}, ErrAuthenticationRevoked
+		}
+		return Token{}, ErrAuthenticationExpired
+	}
+	if token.Verified {
+		return Token{}, ErrChallengeAlreadyUsed
+	}
+	if token.ExpiresAt < a.clock.Now().Unix() {
+		return Token{}, ErrAuthenticationExpired
+	}
+	if (token.ClientIP != "" && token.ClientIP != client.IP) || (token.UserAgent != "" && token.UserAgent != client.UserAgent) {
+		return Token{}, ErrAuthenticationClientMismatch
 	}
 
 	err = token.VerifyChallenge(signature)
 	if err != nil {
-		return ErrAuthenticationVerificationFailed.IncludeMeta(map[string]interface{}{"error": err.Error()})
+		return Token{}, ErrAuthenticationVerificationFailed.IncludeMeta(map[string]interface{}{"error": err.Error()})
 	}
 
 	token.Verified = true
 	err = a.cache.Set(token.Id.String(), token.Bytes())
 	if err != nil {
-		return err
+		return Token{}, err
 	}
 
-	return nil
+	return token, nil
 }
 
 // Get retrieves an authentication token
@@ -96,17 +282,23 @@ type Chain = string
 const (
 	ChainEthereum  Chain = "ETH"
 	ChainThorchain Chain = "THOR"
+	ChainBitcoin   Chain = "BTC"
 )
 
 // Token represents an authentication token
 type Token struct {
-	Id        uuid.UUID `json:"id,omitempty"`
-	Chain     Chain     `json:"chain,omitempty"`
-	Address   string    `json:"address,omitempty"`
-	IssuedAt  int64     `json:"issued_at,omitempty"`
-	ExpiresAt int64     `json:"expires_at,omitempty"`
-	Challenge string    `json:"challenge,omitempty"`
-	Verified  bool      `json:"verified,omitempty"`
+	Id      uuid.UUID `json:"id,omitempty"`
+	Chain   Chain     `json:"chain,omitempty"`
+	Address string    `json:"address,omitempty"`
+	// Network is the only network the token grants access to
+	Network   domain.Network `json:"network,omitempty"`
+	IssuedAt  int64          `json:"issued_at,omitempty"`
+	ExpiresAt int64          `json:"expires_at,omitempty"`
+	Challenge string         `json:"challenge,omitempty"`
+	Verified  bool           `json:"verified,omitempty"`
+	// ClientIP and UserAgent are the properties of the client the challenge is bound to, if any
+	ClientIP  string `json:"client_ip,omitempty"`
+	UserAgent string `json:"user_agent,omitempty"`
 }
 
 var (
@@ -114,8 +306,14 @@ var (
 	ErrAuthenticationNotVerified = NewError("auth_not_verified", "authentication not verified")
 )
 
-// ExtractTokenFromHttp extracts an authentication token from the HTTP request Authorization header as a Bearer token
+// ExtractTokenFromHttp extracts an authentication token from the HTTP request Authorization header as a Bearer token,
+// either the id of a token in the store or a JWT when the stateless mode is enabled.
+// When API keys are enabled, the token of the participant the X-API-Key header acts as is returned instead.
 func (db *AuthenticationDB) ExtractTokenFromHttp(r *http.Request) (Token, error) {
+	if key := r.Header.Get(HeaderAPIKey); key != "" && db.apiKeys != nil {
+		return db.apiKeys.AuthenticateParticipant(r.Context(), key)
+	}
+
 	h := r.Header.Get("Authorization")
 	if h == "" {
 		return Token{}, ErrAuthenticationFailed
@@ -126,17 +324,30 @@ func (db *AuthenticationDB) ExtractTokenFromHttp(r *http.Request) (Token, error)
 		return Token{}, ErrAuthenticationFailed
 	}
 
-	tokenId, err := uuid.Parse(hParts[1])
+	return db.Authenticate(hParts[1])
+}
+
+// Authenticate returns the verified token of the bearer credential, either the id of a token in the store or a JWT
+// when the stateless mode is enabled
+func (db *AuthenticationDB) Authenticate(bearer string) (Token, error) {
+	tokenId, err := uuid.Parse(bearer)
 	if err != nil {
+		if db.jwt != nil {
+			return db.extractJWT(bearer)
+		}
 		return Token{}, ErrAuthenticationFailed
 	}
 
+	if db.IsRevoked(tokenId) {
+		return Token{}, ErrAuthenticationRevoked
+	}
+
 	token, err := db.Get(tokenId)
 	if err != nil {
 		return Token{}, ErrAuthenticationExpired
 	}
 
-	if token.ExpiresAt < time.Now().Unix() {
+	if token.ExpiresAt < db.clock.Now().Unix() {
 		return Token{}, ErrAuthenticationExpired
 	}
 
@@ -147,15 +358,35 @@ func (db *AuthenticationDB) ExtractTokenFromHttp(r *http.Request) (Token, error)
 	return token, nil
 }
 
+// extractJWT authenticates the JWT by its signature and claims only, the revocations are checked locally
+// so a token revoked on one replica is still accepted by the others until it expires
+func (db *AuthenticationDB) extractJWT(raw string) (Token, error) {
+	token, err := db.jwt.Parse(raw)
+	if err != nil {
+		var verr *jwt.ValidationError
+		if errors.As(err, &verr) && verr.Errors&jwt.ValidationErrorExpired != 0 {
+			return Token{}, ErrAuthenticationExpired
+		}
+		return Token{}, ErrAuthenticationFailed
+	}
+
+	if db.IsRevoked(token.Id) {
+		return Token{}, ErrAuthenticationRevoked
+	}
+
+	return token, nil
+}
+
 var ErrInvalidPublicKey = NewError("invalid_public_key", "failed to generate address for this pair of chain and public key")
 
-func newToken(chain Chain, address string) (Token, error) {
+func newToken(chain Chain, address string, network domain.Network, now time.Time) (Token, error) {
 	return Token{
 		Id:        uuid.New(),
 		Chain:     chain,
 		Address:   address,
-		IssuedAt:  time.Now().Unix(),
-		ExpiresAt: time.Now().Add(tokensTTL).Unix(),
+		Network:   network,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(tokensTTL).Unix(),
 		Challenge: fmt.Sprintf("Authentication Challenge: %s", base64.StdEncoding.EncodeToString(getRandomChallenge())),
 		Verified:  false,
 	}, nil
@@ -197,19 +428,66 @@ func (t Token) Bytes() []byte {
 
 // VerifyChallenge verifies the challenge
 func (t Token) VerifyChallenge(signature []byte) error {
-	switch t.Chain {
+	return VerifySignature(t.Chain, t.Address, t.Challenge, signature)
+}
+
+// VerifySignature verifies that the message is signed by the address on the chain the way the wallets of the chain sign
+// messages: personal_sign on Ethereum, ADR-036 on THORChain and BIP-137 on Bitcoin. The other chains are rejected.
+func VerifySignature(chain Chain, address, message string, signature []byte) error {
+	switch chain {
 	case ChainEthereum:
-		return verifyEthereumChallenge(t.Address, t.Challenge, signature)
+		return verifyEthereumSignature(address, message, signature)
 	case ChainThorchain:
-		// TODO: implement thorchain signature verification
-		return nil
+		return verifyThorchainSignature(address, message, signature)
+	case ChainBitcoin:
+		return verifyBitcoinSignature(address, message, signature)
 	}
 
-	return nil
+	return fmt.Errorf("unsupported chain %s", chain)
+}
+
+// SignMessage signs the message with the key the way the wallets of the chain do, so that VerifySignature verifies it
+// for the address of the key. It's meant for the tools and the tests acting as participants.
+func SignMessage(chain Chain, key *ecdsa.PrivateKey, message string) ([]byte, error) {
+	switch chain {
+	case ChainEthereum:
+		signature, err := ethcrypto.Sign(ethaccounts.TextHash([]byte(message)), key)
+		if err != nil {
+			return nil, err
+		}
+		signature[ethcrypto.RecoveryIDOffset] += 27 // wallets sign with V as 27/28
+		return signature, nil
+	case ChainThorchain:
+		pubKey := ethcrypto.CompressPubkey(&key.PublicKey)
+		signer, err := generateThorchainAddress(pubKey)
+		if err != nil {
+			return nil, err
+		}
+		hash := sha256.Sum256(thorchainSignDoc(signer, message))
+		signature, err := ethcrypto.Sign(hash[:], key)
+		if err != nil {
+			return nil, err
+		}
+		return append(signature[:64], pubKey...), nil
+	case ChainBitcoin:
+		signature, err := ethcrypto.Sign(bitcoinMessageHash(message), key)
+		if err != nil {
+			return nil, err
+		}
+		// the header of the native segwit addresses is 39 plus the recovery id
+		return append([]byte{39 + signature[ethcrypto.RecoveryIDOffset]}, signature[:64]...), nil
+	}
+
+	return nil, fmt.Errorf("unsupported chain %s", chain)
 }
 
-func verifyEthereumChallenge(address, challenge string, signature []byte) error {
-	hash := ethaccounts.TextHash([]byte(challenge))
+func verifyEthereumSignature(address, message string, signature []byte) error {
+	if len(signature) != ethcrypto.SignatureLength {
+		return fmt.Errorf("invalid signature length")
+	}
+
+	hash := ethaccounts.TextHash([]byte(message))
+	signature = append([]byte(nil), signature...)
 	signature[ethcrypto.RecoveryIDOffset] -= 27 // transform V from 27/28 to 0/1
 	pub, err := ethcrypto.SigToPub(hash, signature)
 	if err != nil {
@@ -228,24 +506,174 @@ func verifyEthereumChallenge(address, challenge string, signature []byte) error
 	return nil
 }
 
-const thorchainBech32Prefix = "thor"
+// verifyThorchainSignature verifies an ADR-036 signature of the message, as Keplr's signArbitrary makes them. The signature
+// is the 64 bytes r || s of the signature followed by the 33 bytes compressed public key of the signer.
+func verifyThorchainSignature(address, message string, signature []byte) error {
+	if len(signature) != 64+33 {
+		return fmt.Errorf("invalid signature length")
+	}
+	pubKey := signature[64:]
+
+	hrp, _, err := bech32.Decode(address, bech32.MaxLengthBIP173)
+	if err != nil {
+		return fmt.Errorf("invalid address: %w", err)
+	}
+	signer, err := generateBech32Address(hrp, pubKey)
+	if err != nil {
+		return fmt.Errorf("invalid public key: %w", err)
+	}
+	if address != signer {
+		return fmt.Errorf("invalid public key address")
+	}
+
+	hash := sha256.Sum256(thorchainSignDoc(address, message))
+	if !ethcrypto.VerifySignature(pubKey, hash[:], signature[:64]) {
+		return fmt.Errorf("invalid signature")
+	}
+
+	return nil
+}
+
+// thorchainSignDoc returns the amino JSON sign document of ADR-036 for the message of the signer, with its keys sorted
+func thorchainSignDoc(signer, message string) []byte {
+	return []byte(`{"account_number":"0","chain_id":"","fee":{"amount":[],"gas":"0"},"memo":"","msgs":[{"type":"sign/MsgSignData",` +
+		`"value":{"data":"` + base64.StdEncoding.EncodeToString([]byte(message)) + `","signer":"` + signer + `"}}],"sequence":"0"}`)
+}
+
+// verifyBitcoinSignature verifies a BIP-137 signature of the message, the 65 bytes signature whose header byte holds the
+// recovery id and the type of the address. The P2PKH, P2SH-P2WPKH and P2WPKH addresses are supported.
+func verifyBitcoinSignature(address, message string, signature []byte) error {
+	if len(signature) != ethcrypto.SignatureLength {
+		return fmt.Errorf("invalid signature length")
+	}
+	header := signature[0]
+	if header < 27 || header > 42 {
+		return fmt.Errorf("invalid signature header")
+	}
+
+	hash := bitcoinMessageHash(message)
+	pub, err := ethcrypto.SigToPub(hash, append(append([]byte(nil), signature[1:]...), (header-27)%4))
+	if err != nil {
+		return fmt.Errorf("failed to recover public key: %w", err)
+	}
+	pubKey := ethcrypto.CompressPubkey(pub)
+	if header < 31 {
+		pubKey = ethcrypto.FromECDSAPub(pub)
+	}
+
+	keyHash := hash160(pubKey)
+	addressHash, err := bitcoinAddressHash(address, keyHash)
+	if err != nil {
+		return fmt.Errorf("invalid address: %w", err)
+	}
+	if !bytes.Equal(keyHash, addressHash) {
+		return fmt.Errorf("invalid public key address")
+	}
+
+	if !ethcrypto.VerifySignature(pubKey, hash, signature[1:]) {
+		return fmt.Errorf("invalid signature")
+	}
+
+	return nil
+}
+
+// bitcoinMessageHash returns the double SHA-256 of the message prefixed the way the Bitcoin wallets sign messages
+func bitcoinMessageHash(message string) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("\x18Bitcoin Signed Message:\n")
+	switch n := uint64(len(message)); {
+	case n < 0xfd:
+		buf.WriteByte(byte(n))
+	case n <= 0xffff:
+		buf.WriteByte(0xfd)
+		buf.Write(binary.LittleEndian.AppendUint16(nil, uint16(n)))
+	default:
+		buf.WriteByte(0xfe)
+		buf.Write(binary.LittleEndian.AppendUint32(nil, uint32(n)))
+	}
+	buf.WriteString(message)
+
+	first := sha256.Sum256(buf.Bytes())
+	second := sha256.Sum256(first[:])
+	return second[:]
+}
+
+// bitcoinAddressHash returns the hash the address pays to, mapped back to the hash of the public key for the P2SH-P2WPKH
+// addresses: a P2SH address whose script is the P2WPKH script of the key hash is the address of the key.
+func bitcoinAddressHash(address string, keyHash []byte) ([]byte, error) {
+	if _, data, err := bech32.Decode(address, bech32.MaxLengthBIP173); err == nil {
+		if len(data) == 0 || data[0] != 0 {
+			return nil, fmt.Errorf("unsupported witness version")
+		}
+		return bech32.ConvertBits(data[1:], 5, 8, false)
+	}
+
+	hash, version, err := base58.CheckDecode(address)
+	if err != nil {
+		return nil, err
+	}
+	switch version {
+	case 0x00, 0x6f: // P2PKH on the mainnet and the testnet
+		return hash, nil
+	case 0x05, 0xc4: // P2SH on the mainnet and the testnet
+		if bytes.Equal(hash, hash160(append([]byte{0x00, 0x14}, keyHash...))) {
+			return keyHash, nil
+		}
+		return hash, nil
+	}
+
+	return nil, fmt.Errorf("unsupported address version %d", version)
+}
+
+// AddressFromPublicKey derives the address of the compressed or uncompressed secp256k1 public key on the chain
+func AddressFromPublicKey(chain Chain, pubKey []byte) (string, error) {
+	var (
+		address string
+		err     error
+	)
+	switch chain {
+	case ChainEthereum:
+		var pk *ecdsa.PublicKey
+		if pk, err = parsePublicKey(pubKey); err == nil {
+			address = ethcrypto.PubkeyToAddress(*pk).Hex()
+		}
+	case ChainThorchain:
+		address, err = generateThorchainAddress(pubKey)
+	case ChainBitcoin:
+		address, err = generateSegwitAddress(bitcoinBech32Prefix, pubKey)
+	default:
+		err = fmt.Errorf("unsupported chain %s", chain)
+	}
+	if err != nil {
+		return "", ErrInvalidPublicKey.IncludeMeta(map[string]interface{}{"error": err.Error()})
+	}
+
+	return address, nil
+}
+
+// parsePublicKey parses the secp256k1 public key in its compressed (33 bytes) or uncompressed (65 bytes) form
+func parsePublicKey(pubKey []byte) (*ecdsa.PublicKey, error) {
+	if len(pubKey) == 33 {
+		return ethcrypto.DecompressPubkey(pubKey)
+	}
+	return ethcrypto.UnmarshalPubkey(pubKey)
+}
+
+const (
+	thorchainBech32Prefix = "thor"
+	bitcoinBech32Prefix   = "bc"
+)
 
 func generateThorchainAddress(pubkey []byte) (string, error) {
 	return generateBech32Address(thorchainBech32Prefix, pubkey)
 }
 
 func generateBech32Address(hrp string, pubkey []byte) (string, error) {
-	pk, err := ethcrypto.UnmarshalPubkey(pubkey)
+	pk, err := parsePublicKey(pubkey)
 	if err != nil {
 		return "", err
 	}
-	compressedPubKey := ethcrypto.CompressPubkey(pk)
-
-	// Hash pubKeyBytes as: RIPEMD160(SHA256(public_key_bytes))
-	sha256Hash := sha256.Sum256(compressedPubKey)
-	ripemd160hash := ripemd160.New()
-	ripemd160hash.Write(sha256Hash[:])
-	addressBytes := ripemd160hash.Sum(nil)
+	addressBytes := hash160(ethcrypto.CompressPubkey(pk))
 
 	// Convert addressBytes into a bech32 string
 	address, err := toBech32(hrp, addressBytes)
@@ -255,3 +683,27 @@ func generateBech32Address(hrp string, pubkey []byte) (string, error) {
 
 	return address, nil
 }
+
+// generateSegwitAddress generates the native segwit (P2WPKH) address of the public key
+func generateSegwitAddress(hrp string, pubkey []byte) (string, error) {
+	pk, err := parsePublicKey(pubkey)
+	if err != nil {
+		return "", err
+	}
+
+	converted, err := bech32.ConvertBits(hash160(ethcrypto.CompressPubkey(pk)), 8, 5, true)
+	if err != nil {
+		return "", err
+	}
+
+	// the witness version 0 comes first
+	return bech32.Encode(hrp, append([]byte{0}, converted...))
+}
+
+// hash160 hashes the public key as RIPEMD160(SHA256(public_key_bytes))
+func hash160(pubKey []byte) []byte {
+	sha256Hash := sha256.Sum256(pubKey)
+	ripemd160hash := ripemd160.New()
+	ripemd160hash.Write(sha256Hash[:])
+	return ripemd160hash.Sum(nil)
+}
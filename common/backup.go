@@ -0,0 +1,41 @@
+package common
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// BackupSQLite writes a consistent snapshot of the database to path with VACUUM INTO.
+// The snapshot is taken in a single read transaction, so the event store and the projections are copied at the same point.
+func BackupSQLite(ctx context.Context, db *sql.DB, path string) error {
+	if _, err := db.ExecContext(ctx, `vacuum into ?;`, path); err != nil {
+		return fmt.Errorf("failed to snapshot database: %w", err)
+	}
+
+	return nil
+}
+
+// CheckSQLiteIntegrity runs the integrity check of SQLite on the database
+func CheckSQLiteIntegrity(ctx context.Context, db *sql.DB) error {
+	var result string
+	if err := db.QueryRowContext(ctx, `pragma integrity_check;`).Scan(&result); err != nil {
+		return fmt.Errorf("failed to check integrity: %w", err)
+	}
+	if result != "ok" {
+		return fmt.Errorf("integrity check failed: %s", result)
+	}
+
+	return nil
+}
+
+// SQLiteFilePath returns the path of the database file of the connection string, empty for in-memory databases
+func SQLiteFilePath(connStr string) string {
+	if strings.Contains(connStr, ":memory:") || strings.Contains(connStr, "mode=memory") {
+		return ""
+	}
+
+	path, _, _ := strings.Cut(strings.TrimPrefix(connStr, "file:"), "?")
+	return path
+}
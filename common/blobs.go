@@ -0,0 +1,185 @@
+package common
+
+import (
+	"bytes"
+	"compress/flate"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// blobRefPrefix marks the values offloaded by a BlobStore, it's followed by the hash addressing them
+const blobRefPrefix = "blob:v1:"
+
+// BlobStore offloads the large values of the events, e.g. the raw transactions pre-signed by the participants, to the
+// event_blobs table where they are stored compressed, and leaves a reference to them in the events instead.
+// The values are addressed by the SHA-256 of the value offloaded, so offloading a value twice stores it once.
+// The offloaded values are loaded back as the events are read, the values stored in the events are read as is.
+type BlobStore struct {
+	db *sql.DB
+	// threshold is the length from which the values are offloaded
+	threshold int
+}
+
+// NewBlobStore creates a new BlobStore offloading the values of threshold bytes or more, and its table
+func NewBlobStore(db *sql.DB, threshold int) (*BlobStore, error) {
+	_, err := db.Exec(`create table if not exists event_blobs (
+		hash TEXT PRIMARY KEY,
+		data BLOB,
+		size INTEGER,
+		created_at TEXT
+	);`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create event_blobs table: %w", err)
+	}
+
+	return &BlobStore{db: db, threshold: threshold}, nil
+}
+
+// IsBlobRef tells whether the value is a reference to a value offloaded by a BlobStore
+func IsBlobRef(value string) bool {
+	return strings.HasPrefix(value, blobRefPrefix)
+}
+
+// Offload stores the value and returns its reference, the values shorter than the threshold are returned as is
+func (b *BlobStore) Offload(value string) (string, error) {
+	if b == nil || len(value) < b.threshold || IsBlobRef(value) {
+		return value, nil
+	}
+
+	sum := sha256.Sum256([]byte(value))
+	hash := hex.EncodeToString(sum[:])
+	data, err := deflate([]byte(value))
+	if err != nil {
+		return "", fmt.Errorf("failed to compress blob: %w", err)
+	}
+	if _, err := b.db.Exec(`insert into event_blobs (hash, data, size, created_at) values (?, ?, ?, ?) on conflict do nothing;`,
+		hash, data, len(value), time.Now().UTC().Format(time.RFC3339)); err != nil {
+		return "", fmt.Errorf("failed to store blob: %w", err)
+	}
+
+	return blobRefPrefix + hash, nil
+}
+
+// Load returns the value of the reference, the values which aren't references are returned as is
+func (b *BlobStore) Load(value string) (string, error) {
+	if !IsBlobRef(value) {
+		return value, nil
+	}
+	if b == nil {
+		return "", errors.New("value is offloaded but no blob store is configured")
+	}
+
+	hash := strings.TrimPrefix(value, blobRefPrefix)
+	var data []byte
+	err := b.db.QueryRow(`select data from event_blobs where hash = ?;`, hash).Scan(&data)
+	if err == sql.ErrNoRows {
+		return "", fmt.Errorf("blob %s not found", hash)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get blob %s: %w", hash, err)
+	}
+	inflated, err := inflate(data)
+	if err != nil {
+		return "", fmt.Errorf("failed to decompress blob %s: %w", hash, err)
+	}
+
+	return string(inflated), nil
+}
+
+// OffloadJSON offloads the strings of the JSON document at the paths, see Cipher.OpenJSON for the paths
+func (b *BlobStore) OffloadJSON(doc []byte, paths []string) ([]byte, error) {
+	if b == nil {
+		return doc, nil
+	}
+
+	return transformJSON(doc, paths, b.Offload)
+}
+
+// LoadJSON loads the strings of the JSON document at the paths offloaded by OffloadJSON
+func (b *BlobStore) LoadJSON(doc []byte, paths []string) ([]byte, error) {
+	if !bytes.Contains(doc, []byte(blobRefPrefix)) {
+		return doc, nil
+	}
+
+	return transformJSON(doc, paths, b.Load)
+}
+
+// RewrapEventBlobs wraps the data keys of the blobs sealed under a previous master key of the cipher with the current one,
+// see RewrapPairKeys in the queries for the batches. The blobs keep the hash they were offloaded with.
+func RewrapEventBlobs(ctx context.Context, tx *sql.Tx, c *Cipher, after string, limit int) (string, int, error) {
+	rows, err := tx.QueryContext(ctx, `select hash, data from event_blobs where hash > ? order by hash limit ?;`, after, limit)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to query event blobs: %w", err)
+	}
+
+	type blob struct {
+		hash string
+		data []byte
+	}
+	blobs := make([]blob, 0, limit)
+	for rows.Next() {
+		var b blob
+		if err := rows.Scan(&b.hash, &b.data); err != nil {
+			rows.Close()
+			return "", 0, fmt.Errorf("failed to scan event blob: %w", err)
+		}
+		blobs = append(blobs, b)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return "", 0, err
+	}
+
+	last, rewrapped := "", 0
+	for _, b := range blobs {
+		last = b.hash
+		value, err := inflate(b.data)
+		if err != nil {
+			return "", 0, fmt.Errorf("failed to decompress blob %s: %w", b.hash, err)
+		}
+		wrapped, err := c.Rewrap(string(value))
+		if err != nil {
+			return "", 0, fmt.Errorf("failed to rewrap blob %s: %w", b.hash, err)
+		}
+		if wrapped == string(value) {
+			continue
+		}
+		data, err := deflate([]byte(wrapped))
+		if err != nil {
+			return "", 0, fmt.Errorf("failed to compress blob %s: %w", b.hash, err)
+		}
+		if _, err := tx.ExecContext(ctx, `update event_blobs set data = ?, size = ? where hash = ?;`, data, len(wrapped), b.hash); err != nil {
+			return "", 0, fmt.Errorf("failed to update blob %s: %w", b.hash, err)
+		}
+		rewrapped++
+	}
+
+	return last, rewrapped, nil
+}
+
+func deflate(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := flate.NewWriter(&buf, flate.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func inflate(data []byte) ([]byte, error) {
+	return io.ReadAll(flate.NewReader(bytes.NewReader(data)))
+}
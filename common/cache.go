@@ -0,0 +1,77 @@
+package common
+
+import (
+	"strings"
+	"sync"
+)
+
+// Cache is an in-process cache for the results of hot queries.
+// Entries don't expire, they are invalidated by the projection callbacks that change the underlying tables.
+// Cached values are shared between the callers, so they must be treated as read-only.
+type Cache struct {
+	mu      sync.RWMutex
+	entries map[string]any
+	// generation is bumped on every invalidation so loads racing with it don't store stale results
+	generation uint64
+}
+
+// NewCache creates a new empty Cache
+func NewCache() *Cache {
+	return &Cache{entries: make(map[string]any)}
+}
+
+// Invalidate removes the entry of the key
+func (c *Cache) Invalidate(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.entries, key)
+	c.generation++
+}
+
+// InvalidatePrefix removes the entries of all the keys starting with prefix
+func (c *Cache) InvalidatePrefix(prefix string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key := range c.entries {
+		if strings.HasPrefix(key, prefix) {
+			delete(c.entries, key)
+		}
+	}
+	c.generation++
+}
+
+func (c *Cache) get(key string) (any, uint64, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	value, ok := c.entries[key]
+	return value, c.generation, ok
+}
+
+func (c *Cache) setIfUnchanged(key string, value any, generation uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.generation == generation {
+		c.entries[key] = value
+	}
+}
+
+// Cached returns the cached value of the key or loads and caches it.
+// Failed loads are not cached.
+func Cached[T any](c *Cache, key string, load func() (T, error)) (T, error) {
+	value, generation, ok := c.get(key)
+	if ok {
+		return value.(T), nil
+	}
+
+	loaded, err := load()
+	if err != nil {
+		return loaded, err
+	}
+	c.setIfUnchanged(key, loaded, generation)
+
+	return loaded, nil
+}
@@ -0,0 +1,18 @@
+package common
+
+import "time"
+
+// Clock tells the current time. It's injected wherever the time drives the logic, e.g. the deadlines of the pairs
+// and the expiry of the tokens, so the tests can control it.
+type Clock interface {
+	Now() time.Time
+}
+
+// SystemClock is the Clock of the system
+var SystemClock Clock = systemClock{}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
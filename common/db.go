@@ -0,0 +1,101 @@
+package common
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DB holds separate connection pools to write to and to read from the database.
+// SQLite allows a single writer at a time, so the writes of the event store and the projections are serialized on one connection
+// while the reads of the HTTP handlers run concurrently on the read pool without waiting for them in WAL mode.
+type DB struct {
+	Write *sql.DB
+	Read  *sql.DB
+}
+
+// OpenSQLite opens the SQLite database at connStr with WAL journaling and a busy timeout on both pools.
+// In-memory databases can't be shared between pools safely, so a single connection is used for both.
+func OpenSQLite(connStr string, readConns int, busyTimeout time.Duration) (*DB, error) {
+	busy := fmt.Sprintf("_busy_timeout=%d", busyTimeout.Milliseconds())
+
+	write, err := sql.Open("sqlite3", withConnParams(connStr, "_journal_mode=WAL", "_txlock=immediate", busy))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open write pool: %w", err)
+	}
+	write.SetMaxOpenConns(1)
+
+	if strings.Contains(connStr, ":memory:") || strings.Contains(connStr, "mode=memory") {
+		return &DB{Write: write, Read: write}, nil
+	}
+
+	read, err := sql.Open("sqlite3", withConnParams(connStr, "_query_only=true", busy))
+	if err != nil {
+		write.Close()
+		return nil, fmt.Errorf("failed to open read pool: %w", err)
+	}
+	read.SetMaxOpenConns(readConns)
+
+	return &DB{Write: write, Read: read}, nil
+}
+
+// OpenMemorySQLite opens a database held in memory under the name, e.g. for the development mode. SQLite drops such a database
+// along with its last connection, so the single connection of the pool is kept open for as long as the DB even when idle.
+func OpenMemorySQLite(name string, busyTimeout time.Duration) (*DB, error) {
+	db, err := OpenSQLite(fmt.Sprintf("file:%s?mode=memory&cache=shared", name), 1, busyTimeout)
+	if err != nil {
+		return nil, err
+	}
+	db.Write.SetMaxIdleConns(1)
+	db.Write.SetConnMaxIdleTime(0)
+	db.Write.SetConnMaxLifetime(0)
+
+	return db, nil
+}
+
+func withConnParams(connStr string, params ...string) string {
+	sep := "?"
+	if strings.Contains(connStr, "?") {
+		sep = "&"
+	}
+
+	return connStr + sep + strings.Join(params, "&")
+}
+
+// Close closes both pools
+func (db *DB) Close() error {
+	if db.Read == db.Write {
+		return db.Write.Close()
+	}
+
+	return errors.Join(db.Read.Close(), db.Write.Close())
+}
+
+// ExplainQueryPlan returns the steps of the plan SQLite picks for the query, one line per step as reported by
+// EXPLAIN QUERY PLAN and indented under their parent step, e.g. "SEARCH pairs_query USING INDEX ..." or "SCAN pairs_query"
+func ExplainQueryPlan(ctx context.Context, db *sql.DB, query string, args ...any) ([]string, error) {
+	rows, err := db.QueryContext(ctx, "explain query plan "+query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to explain query: %w", err)
+	}
+	defer rows.Close()
+
+	depths := map[int]int{}
+	steps := []string{}
+	for rows.Next() {
+		var (
+			id, parent, unused int
+			detail             string
+		)
+		if err := rows.Scan(&id, &parent, &unused, &detail); err != nil {
+			return nil, fmt.Errorf("failed to scan query plan: %w", err)
+		}
+		depths[id] = depths[parent] + 1
+		steps = append(steps, strings.Repeat("  ", depths[id]-1)+detail)
+	}
+
+	return steps, rows.Err()
+}
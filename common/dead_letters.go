@@ -0,0 +1,130 @@
+package common
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hallgren/eventsourcing"
+	"github.com/hallgren/eventsourcing/core"
+)
+
+// DeadLetter is an event a projection gave up handling after retrying it
+type DeadLetter struct {
+	Id            int64     `json:"id"`
+	Projection    string    `json:"projection"`
+	GlobalVersion uint64    `json:"global_version"`
+	AggregateType string    `json:"aggregate_type"`
+	AggregateId   string    `json:"aggregate_id"`
+	Reason        string    `json:"reason"`
+	Data          string    `json:"data"`
+	Error         string    `json:"error"`
+	Attempts      int       `json:"attempts"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+func createDeadLettersTable(db *sql.DB) error {
+	_, err := db.Exec(`create table if not exists projection_dead_letters (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		projection VARCHAR,
+		global_version INTEGER,
+		aggregate_type TEXT,
+		aggregate_id TEXT,
+		reason TEXT,
+		data TEXT,
+		error TEXT,
+		attempts INTEGER,
+		created_at TEXT
+	);`)
+	return err
+}
+
+// Park records the event the projection failed to handle in the dead letters and moves the projection past it.
+// The dead letter is committed along with the current batch, so the event is parked exactly once.
+func (bp *BaseProjection) Park(event eventsourcing.Event, cause error, attempts int) error {
+	if err := bp.begin(); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(event.Data())
+	if err != nil {
+		return fmt.Errorf("failed to marshal event data: %w", err)
+	}
+
+	if _, err := bp.batch.Exec(`insert into projection_dead_letters (
+		projection, global_version, aggregate_type, aggregate_id, reason, data, error, attempts, created_at
+	) values (?, ?, ?, ?, ?, ?, ?, ?, ?);`,
+		bp.name,
+		uint64(event.GlobalVersion()),
+		event.AggregateType(),
+		event.AggregateID(),
+		event.Reason(),
+		string(data),
+		cause.Error(),
+		attempts,
+		time.Now().Format(time.RFC3339),
+	); err != nil {
+		return fmt.Errorf("failed to insert dead letter: %w", err)
+	}
+	bp.lastHandled = core.Version(event.GlobalVersion())
+
+	return nil
+}
+
+// Abort rolls back the current batch, the events of the fetch are handled again from the last committed one.
+// The next Fetch fails with the cause, so the projection backs off before handling them again.
+func (bp *BaseProjection) Abort(cause error) {
+	if bp.batch != nil {
+		bp.batch.Rollback()
+	}
+	bp.batch, bp.afterCommit = nil, nil
+	bp.lastHandled = 0
+	bp.commitErr = cause
+}
+
+// FindDeadLetters returns the latest dead letters of the projection, or of all projections when it's empty
+func FindDeadLetters(ctx context.Context, db *sql.DB, projection string, limit int) ([]DeadLetter, error) {
+	rows, err := db.QueryContext(ctx, `select id, projection, global_version, aggregate_type, aggregate_id, reason, data, error, attempts, created_at
+		from projection_dead_letters where ? = '' or projection = ? order by id desc limit ?;`, projection, projection, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query dead letters: %w", err)
+	}
+	defer rows.Close()
+
+	letters := make([]DeadLetter, 0)
+	for rows.Next() {
+		var dl DeadLetter
+		var createdAt string
+		if err := rows.Scan(&dl.Id, &dl.Projection, &dl.GlobalVersion, &dl.AggregateType, &dl.AggregateId,
+			&dl.Reason, &dl.Data, &dl.Error, &dl.Attempts, &createdAt); err != nil {
+			return nil, fmt.Errorf("failed to scan dead letter: %w", err)
+		}
+		dl.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+		letters = append(letters, dl)
+	}
+
+	return letters, rows.Err()
+}
+
+// CountDeadLetters returns the number of dead letters parked by every projection
+func CountDeadLetters(ctx context.Context, db *sql.DB) (map[string]int, error) {
+	rows, err := db.QueryContext(ctx, `select projection, count(*) from projection_dead_letters group by projection;`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count dead letters: %w", err)
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var projection string
+		var count int
+		if err := rows.Scan(&projection, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan dead letters count: %w", err)
+		}
+		counts[projection] = count
+	}
+
+	return counts, rows.Err()
+}
@@ -0,0 +1,116 @@
+package common
+
+import (
+	"encoding/json"
+	"reflect"
+)
+
+// EventEncoder encodes the events in JSON like the default encoder of the event repository,
+// and encrypts the fields of the events holding secrets with the cipher and the personal fields with the keys of their subjects.
+// The large fields are offloaded to the blob store once encrypted.
+type EventEncoder struct {
+	cipher *Cipher
+	// fields holds the paths of the encrypted fields by event type, see Cipher.OpenJSON for the paths
+	fields   map[reflect.Type][]string
+	subjects *SubjectKeys
+	// personal holds the personal fields by event type, sealed with the subjects keys
+	personal map[reflect.Type]SubjectFields
+	blobs    *BlobStore
+	// offloaded holds the paths of the fields offloaded to the blob store by event type
+	offloaded map[reflect.Type][]string
+}
+
+// NewEventEncoder creates a new EventEncoder encrypting the fields with the cipher, the events are stored in plaintext with a nil cipher
+func NewEventEncoder(cipher *Cipher, fields map[reflect.Type][]string) *EventEncoder {
+	return &EventEncoder{cipher: cipher, fields: fields}
+}
+
+// WithSubjectKeys seals the personal fields of the events with the keys of their subjects, so they can be crypto-shredded
+func (e *EventEncoder) WithSubjectKeys(subjects *SubjectKeys, personal map[reflect.Type]SubjectFields) *EventEncoder {
+	e.subjects = subjects
+	e.personal = personal
+	return e
+}
+
+// WithBlobs offloads the large values of the fields at the paths to the blob store, so they don't inflate the events
+func (e *EventEncoder) WithBlobs(blobs *BlobStore, offloaded map[reflect.Type][]string) *EventEncoder {
+	e.blobs = blobs
+	e.offloaded = offloaded
+	return e
+}
+
+// Serialize implements the encoder of the event repository
+func (e *EventEncoder) Serialize(v interface{}) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	t := eventType(v)
+	if fields, ok := e.personal[t]; ok && e.subjects != nil {
+		if data, err = e.subjects.SealJSON(data, fields); err != nil {
+			return nil, err
+		}
+	}
+	if paths, ok := e.fields[t]; ok {
+		if data, err = e.cipher.SealJSON(data, paths); err != nil {
+			return nil, err
+		}
+	}
+	if paths, ok := e.offloaded[t]; ok {
+		return e.blobs.OffloadJSON(data, paths)
+	}
+	return data, nil
+}
+
+// Deserialize implements the encoder of the event repository
+func (e *EventEncoder) Deserialize(data []byte, v interface{}) error {
+	t := eventType(v)
+	var err error
+	if paths, ok := e.offloaded[t]; ok {
+		if data, err = e.blobs.LoadJSON(data, paths); err != nil {
+			return err
+		}
+	}
+	if paths, ok := e.fields[t]; ok {
+		if data, err = e.cipher.OpenJSON(data, paths); err != nil {
+			return err
+		}
+	}
+	if fields, ok := e.personal[t]; ok && e.subjects != nil {
+		if data, err = e.subjects.OpenJSON(data, fields.Paths); err != nil {
+			return err
+		}
+	}
+
+	return json.Unmarshal(data, fresh(v))
+}
+
+// fresh points the interface at a new zero event when it holds a pointer to one. The event repository hands the same
+// registered event to every Deserialize, the slices and maps of the events applied before would be overwritten otherwise.
+func fresh(v interface{}) interface{} {
+	p, ok := v.(*interface{})
+	if !ok || *p == nil {
+		return v
+	}
+	rv := reflect.ValueOf(*p)
+	if rv.Kind() != reflect.Pointer {
+		return v
+	}
+
+	*p = reflect.New(rv.Type().Elem()).Interface()
+	return v
+}
+
+// eventType returns the type of the event behind the pointers and interfaces holding it
+func eventType(v interface{}) reflect.Type {
+	rv := reflect.ValueOf(v)
+	for (rv.Kind() == reflect.Pointer || rv.Kind() == reflect.Interface) && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if !rv.IsValid() {
+		return nil
+	}
+
+	return rv.Type()
+}
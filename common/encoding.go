@@ -0,0 +1,35 @@
+package common
+
+import (
+	"bytes"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// MIMEApplicationMsgpack is the content type of the bodies encoded with MessagePack
+const MIMEApplicationMsgpack = "application/msgpack"
+
+// msgpackStructTag names the fields by their JSON tags, so both encodings are served from the same structs with the same field names
+const msgpackStructTag = "json"
+
+// MarshalMsgpack encodes v with MessagePack. The map keys are sorted so the same value always gets the same bytes, e.g. for its ETag.
+func MarshalMsgpack(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetCustomStructTag(msgpackStructTag)
+	enc.SetSortMapKeys(true)
+	enc.UseCompactInts(true)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// UnmarshalMsgpack decodes the MessagePack data encoded by MarshalMsgpack into v
+func UnmarshalMsgpack(data []byte, v interface{}) error {
+	dec := msgpack.NewDecoder(bytes.NewReader(data))
+	dec.SetCustomStructTag(msgpackStructTag)
+
+	return dec.Decode(v)
+}
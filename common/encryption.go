@@ -0,0 +1,428 @@
+package common
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// sealedPrefix marks the values sealed by a Cipher, the values without it are stored in plaintext
+const sealedPrefix = "enc:v1:"
+
+// dataKeySize is the size of the AES-256 keys sealing the values and wrapping the data keys
+const dataKeySize = 32
+
+// ErrNoEncryptionKey is returned when opening a sealed value without a cipher
+var ErrNoEncryptionKey = errors.New("value is encrypted but no encryption key is configured")
+
+// KeyWrapper wraps the data keys of a Cipher under a master key, e.g. read from a key file or held by a KMS
+type KeyWrapper interface {
+	// KeyId identifies the master key, it's stored along the wrapped data keys to tell which key unwraps them
+	KeyId() string
+	WrapKey(ctx context.Context, dataKey []byte) ([]byte, error)
+	UnwrapKey(ctx context.Context, wrapped []byte) ([]byte, error)
+}
+
+// StaticKeyWrapper wraps the data keys with AES-GCM under a master key held in memory
+type StaticKeyWrapper struct {
+	id   string
+	aead cipher.AEAD
+}
+
+// NewStaticKeyWrapper creates a new StaticKeyWrapper with the 32 bytes master key, its id is derived from the key
+func NewStaticKeyWrapper(key []byte) (*StaticKeyWrapper, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid master key: %w", err)
+	}
+	sum := sha256.Sum256(key)
+
+	return &StaticKeyWrapper{id: "static:" + hex.EncodeToString(sum[:8]), aead: aead}, nil
+}
+
+// ReadKeyFile reads the master key of a StaticKeyWrapper from the file, holding the 32 bytes of the key hex or base64 encoded
+func ReadKeyFile(path string) (*StaticKeyWrapper, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+
+	encoded := strings.TrimSpace(string(b))
+	key, err := hex.DecodeString(encoded)
+	if err != nil {
+		if key, err = base64.StdEncoding.DecodeString(encoded); err != nil {
+			return nil, fmt.Errorf("key file %s is neither hex nor base64 encoded", path)
+		}
+	}
+
+	return NewStaticKeyWrapper(key)
+}
+
+// KeyId implements the KeyWrapper interface
+func (w *StaticKeyWrapper) KeyId() string {
+	return w.id
+}
+
+// WrapKey implements the KeyWrapper interface
+func (w *StaticKeyWrapper) WrapKey(ctx context.Context, dataKey []byte) ([]byte, error) {
+	return seal(w.aead, dataKey)
+}
+
+// UnwrapKey implements the KeyWrapper interface
+func (w *StaticKeyWrapper) UnwrapKey(ctx context.Context, wrapped []byte) ([]byte, error) {
+	return open(w.aead, wrapped)
+}
+
+// Cipher encrypts the sensitive values stored at rest with envelope encryption: the values are sealed with AES-GCM under a data key,
+// which is stored along them wrapped by the master key. The cipher generates its data key once, so the master key is only reached
+// to unwrap the data keys of the values sealed by other ciphers, e.g. before a restart.
+// A nil Cipher leaves the values in plaintext and fails to open the sealed ones.
+type Cipher struct {
+	master  KeyWrapper
+	dataKey cipher.AEAD
+	// envelope is the encoded id of the master key and wrapped data key, the sealed values start with it
+	envelope string
+	// keys holds the master keys the values may be sealed under by id, the current one and the ones it's rotated from
+	keys map[string]KeyWrapper
+
+	mu sync.Mutex
+	// dataKeys holds the data keys unwrapped so far by envelope
+	dataKeys map[string]cipher.AEAD
+	// rewrapped holds the envelopes of the data keys wrapped again by the current master key by their previous envelope
+	rewrapped map[string]string
+}
+
+// NewCipher creates a new Cipher with a fresh data key wrapped by the master key.
+// The values sealed under the previous master keys are still opened, until they are wrapped again with Rewrap.
+func NewCipher(ctx context.Context, master KeyWrapper, previous ...KeyWrapper) (*Cipher, error) {
+	key := make([]byte, dataKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("failed to generate data key: %w", err)
+	}
+	wrapped, err := master.WrapKey(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to wrap data key: %w", err)
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := map[string]KeyWrapper{master.KeyId(): master}
+	for _, key := range previous {
+		keys[key.KeyId()] = key
+	}
+
+	envelope := encodeSegment([]byte(master.KeyId())) + "." + encodeSegment(wrapped)
+	return &Cipher{
+		master:    master,
+		dataKey:   aead,
+		envelope:  envelope,
+		keys:      keys,
+		dataKeys:  map[string]cipher.AEAD{envelope: aead},
+		rewrapped: make(map[string]string),
+	}, nil
+}
+
+// KeyId returns the id of the current master key
+func (c *Cipher) KeyId() string {
+	return c.master.KeyId()
+}
+
+// Rotating tells whether the cipher still opens values sealed under previous master keys
+func (c *Cipher) Rotating() bool {
+	return c != nil && len(c.keys) > 1
+}
+
+// IsSealed tells whether the value was sealed by a Cipher
+func IsSealed(value string) bool {
+	return strings.HasPrefix(value, sealedPrefix)
+}
+
+// Encrypt seals the value, the empty values are left empty so the optional fields stay unset
+func (c *Cipher) Encrypt(value string) (string, error) {
+	if c == nil || value == "" || IsSealed(value) {
+		return value, nil
+	}
+
+	sealed, err := seal(c.dataKey, []byte(value))
+	if err != nil {
+		return "", err
+	}
+
+	return sealedPrefix + c.envelope + "." + encodeSegment(sealed), nil
+}
+
+// Decrypt opens the sealed value, the values in plaintext are returned as is
+func (c *Cipher) Decrypt(value string) (string, error) {
+	if !IsSealed(value) {
+		return value, nil
+	}
+	if c == nil {
+		return "", ErrNoEncryptionKey
+	}
+
+	segments, err := sealedSegments(value)
+	if err != nil {
+		return "", err
+	}
+	aead, err := c.dataKeyOf(segments[0], segments[1])
+	if err != nil {
+		return "", err
+	}
+	sealed, err := decodeSegment(segments[2])
+	if err != nil {
+		return "", fmt.Errorf("malformed encrypted value: %w", err)
+	}
+	plaintext, err := open(aead, sealed)
+	if err != nil {
+		return "", err
+	}
+
+	return string(plaintext), nil
+}
+
+// Rewrap wraps the data key of the value sealed under a previous master key with the current one, the value itself isn't
+// encrypted again. The values in plaintext or sealed under the current master key are returned as is.
+func (c *Cipher) Rewrap(value string) (string, error) {
+	if !IsSealed(value) {
+		return value, nil
+	}
+	if c == nil {
+		return "", ErrNoEncryptionKey
+	}
+
+	segments, err := sealedSegments(value)
+	if err != nil {
+		return "", err
+	}
+	envelope, err := c.rewrapEnvelope(segments[0], segments[1])
+	if err != nil {
+		return "", err
+	}
+
+	return sealedPrefix + envelope + "." + segments[2], nil
+}
+
+// dataKeyOf returns the data key wrapped in the envelope, unwrapping it with its master key on first use
+func (c *Cipher) dataKeyOf(keyId, wrapped string) (cipher.AEAD, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	envelope := keyId + "." + wrapped
+	if aead, ok := c.dataKeys[envelope]; ok {
+		return aead, nil
+	}
+
+	key, err := c.unwrap(keyId, wrapped)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	c.dataKeys[envelope] = aead
+
+	return aead, nil
+}
+
+// rewrapEnvelope returns the envelope of the data key wrapped in the envelope once wrapped by the current master key
+func (c *Cipher) rewrapEnvelope(keyId, wrapped string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	envelope := keyId + "." + wrapped
+	if id, err := decodeSegment(keyId); err == nil && string(id) == c.master.KeyId() {
+		return envelope, nil
+	}
+	if rewrapped, ok := c.rewrapped[envelope]; ok {
+		return rewrapped, nil
+	}
+
+	key, err := c.unwrap(keyId, wrapped)
+	if err != nil {
+		return "", err
+	}
+	wrappedKey, err := c.master.WrapKey(context.Background(), key)
+	if err != nil {
+		return "", fmt.Errorf("failed to wrap data key: %w", err)
+	}
+	rewrapped := encodeSegment([]byte(c.master.KeyId())) + "." + encodeSegment(wrappedKey)
+	c.rewrapped[envelope] = rewrapped
+
+	return rewrapped, nil
+}
+
+// unwrap unwraps the data key with the master key of the id, it must be called with the lock held
+func (c *Cipher) unwrap(keyId, wrapped string) ([]byte, error) {
+	id, err := decodeSegment(keyId)
+	if err != nil {
+		return nil, fmt.Errorf("malformed encrypted value: %w", err)
+	}
+	master, ok := c.keys[string(id)]
+	if !ok {
+		return nil, fmt.Errorf("value is encrypted under the unknown master key %s", id)
+	}
+	wrappedKey, err := decodeSegment(wrapped)
+	if err != nil {
+		return nil, fmt.Errorf("malformed encrypted value: %w", err)
+	}
+	key, err := master.UnwrapKey(context.Background(), wrappedKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap data key: %w", err)
+	}
+
+	return key, nil
+}
+
+// SealJSON encrypts the strings of the JSON document at the paths, see OpenJSON for the paths
+func (c *Cipher) SealJSON(doc []byte, paths []string) ([]byte, error) {
+	if c == nil {
+		return doc, nil
+	}
+
+	return transformJSON(doc, paths, c.Encrypt)
+}
+
+// OpenJSON decrypts the strings of the JSON document at the paths. A path is made of the keys of the nested objects joined
+// by dots, where * stands for every key of an object or every element of an array, e.g. assurances.*.tx
+func (c *Cipher) OpenJSON(doc []byte, paths []string) ([]byte, error) {
+	if !bytes.Contains(doc, []byte(sealedPrefix)) {
+		return doc, nil
+	}
+
+	return transformJSON(doc, paths, c.Decrypt)
+}
+
+// RewrapJSON wraps the data keys of the strings of the JSON document at the paths sealed under a previous master key with
+// the current one, see Rewrap, and tells whether any was
+func (c *Cipher) RewrapJSON(doc []byte, paths []string) ([]byte, bool, error) {
+	if !bytes.Contains(doc, []byte(sealedPrefix)) {
+		return doc, false, nil
+	}
+
+	changed := false
+	rewrapped, err := transformJSON(doc, paths, func(value string) (string, error) {
+		rewrapped, err := c.Rewrap(value)
+		changed = changed || rewrapped != value
+		return rewrapped, err
+	})
+
+	return rewrapped, changed, err
+}
+
+// transformJSON replaces the strings of the JSON document at the paths with their transformation
+func transformJSON(doc []byte, paths []string, transform func(string) (string, error)) ([]byte, error) {
+	var v interface{}
+	d := json.NewDecoder(bytes.NewReader(doc))
+	d.UseNumber()
+	if err := d.Decode(&v); err != nil {
+		return nil, fmt.Errorf("failed to decode document: %w", err)
+	}
+
+	for _, path := range paths {
+		var err error
+		if v, err = transformPath(v, strings.Split(path, "."), transform); err != nil {
+			return nil, err
+		}
+	}
+
+	return json.Marshal(v)
+}
+
+func transformPath(v interface{}, keys []string, transform func(string) (string, error)) (interface{}, error) {
+	if len(keys) == 0 {
+		if s, ok := v.(string); ok {
+			return transform(s)
+		}
+		return v, nil
+	}
+
+	var err error
+	switch node := v.(type) {
+	case map[string]interface{}:
+		for key, child := range node {
+			if keys[0] != "*" && keys[0] != key {
+				continue
+			}
+			if node[key], err = transformPath(child, keys[1:], transform); err != nil {
+				return nil, err
+			}
+		}
+	case []interface{}:
+		if keys[0] != "*" {
+			return v, nil
+		}
+		for i, child := range node {
+			if node[i], err = transformPath(child, keys[1:], transform); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return v, nil
+}
+
+// sealedSegments splits the sealed value into the id of its master key, its wrapped data key and its ciphertext
+func sealedSegments(value string) ([]string, error) {
+	segments := strings.Split(strings.TrimPrefix(value, sealedPrefix), ".")
+	if len(segments) != 3 {
+		return nil, errors.New("malformed encrypted value")
+	}
+
+	return segments, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) != dataKeySize {
+		return nil, fmt.Errorf("key must be %d bytes, got %d", dataKeySize, len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// seal encrypts the plaintext with a random nonce, which is prepended to the ciphertext
+func seal(aead cipher.AEAD, plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	return aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func open(aead cipher.AEAD, sealed []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("malformed encrypted value")
+	}
+	plaintext, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt value: %w", err)
+	}
+
+	return plaintext, nil
+}
+
+func encodeSegment(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeSegment(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(s)
+}
@@ -2,27 +2,175 @@ package common
 
 import (
 	"fmt"
+	"net/http"
+	"strings"
 
 	"github.com/go-playground/validator/v10"
-	"github.com/iancoleman/strcase"
 )
 
-// Error is a domain error that includes a code, message, meta
+// Error is a domain error that includes a code, message, meta.
+//
+// Errors are returned to the API clients as machine-readable JSON bodies:
+//
+//	{
+//		"code": "pair_not_found",        // stable identifier registered in the error catalog
+//		"message": "pair not found",     // human-readable description, may change over time
+//		"meta": {"field": "details"},    // optional details about the failure
+//		"trace_id": "..."                // request identifier to correlate the failure with server logs
+//	}
 type Error struct {
 	Code     string                 `json:"code,omitempty"`
 	Message  string                 `json:"message,omitempty"`
 	Internal error                  `json:"internal,omitempty"`
 	Meta     map[string]interface{} `json:"meta,omitempty"`
+	TraceId  string                 `json:"trace_id,omitempty"`
 }
 
-// NewError creates a new domain error
+// errorCatalog maps every error code returned by the API to its HTTP status.
+// A code must be registered here before an error can be created with it.
+var errorCatalog = map[string]int{
+	// Generic errors
+	"invalid_request":         http.StatusBadRequest,
+	"forbidden":               http.StatusForbidden,
+	"ip_not_allowed":          http.StatusForbidden,
+	"route_not_found":         http.StatusNotFound,
+	"method_not_allowed":      http.StatusMethodNotAllowed,
+	"internal_error":          http.StatusInternalServerError,
+	"request_too_large":       http.StatusRequestEntityTooLarge,
+	"unsupported_api_version": http.StatusBadRequest,
+	"request_timeout":         http.StatusGatewayTimeout,
+	"server_key_not_found":    http.StatusNotFound,
+
+	// Authentication errors
+	"auth_expired":                 http.StatusUnauthorized,
+	"auth_failed":                  http.StatusUnauthorized,
+	"auth_not_verified":            http.StatusUnauthorized,
+	"auth_verification_failed":     http.StatusUnauthorized,
+	"invalid_public_key":           http.StatusBadRequest,
+	"admin_auth_failed":            http.StatusUnauthorized,
+	"auth_revoked":                 http.StatusUnauthorized,
+	"session_not_found":            http.StatusNotFound,
+	"auth_challenge_used":          http.StatusConflict,
+	"auth_client_mismatch":         http.StatusUnauthorized,
+	"address_blocked":              http.StatusForbidden,
+	"participant_not_verified":     http.StatusForbidden,
+	"compliance_unavailable":       http.StatusServiceUnavailable,
+	"api_key_invalid":              http.StatusUnauthorized,
+	"api_key_scope_missing":        http.StatusForbidden,
+	"api_key_not_found":            http.StatusNotFound,
+	"api_key_participant_required": http.StatusBadRequest,
+
+	// Participant errors
+	"participant_not_found":  http.StatusNotFound,
+	"address_already_linked": http.StatusConflict,
+	"data_export_not_found":  http.StatusNotFound,
+	"data_export_not_ready":  http.StatusConflict,
+
+	// Plan errors
+	"plan_not_found":      http.StatusNotFound,
+	"invalid_plan_id":     http.StatusBadRequest,
+	"invalid_plan_status": http.StatusBadRequest,
+	"invalid_plan_assets": http.StatusBadRequest,
+	"plan_unavailable":    http.StatusConflict,
+	"plan_at_capacity":    http.StatusConflict,
+	"pair_quota_exceeded": http.StatusConflict,
+	"self_match":          http.StatusConflict,
+
+	// Pair errors
+	"pair_not_found":                    http.StatusNotFound,
+	"invalid_address":                   http.StatusBadRequest,
+	"invalid_asset_for_pair":            http.StatusBadRequest,
+	"invalid_share_multiplier":          http.StatusBadRequest,
+	"plan_not_in_network":               http.StatusForbidden,
+	"invalid_pair_status":               http.StatusBadRequest,
+	"invalid_wallet_addresses":          http.StatusBadRequest,
+	"invalid_assurances":                http.StatusBadRequest,
+	"invalid_assurance_policy":          http.StatusBadRequest,
+	"forbidden_pair_for_address":        http.StatusForbidden,
+	"invalid_wallet_public_key":         http.StatusBadRequest,
+	"wallet_address_mismatch":           http.StatusBadRequest,
+	"wallet_already_confirmed":          http.StatusBadRequest,
+	"keygen_failure_reported":           http.StatusConflict,
+	"already_set_assurances":            http.StatusBadRequest,
+	"assurances_not_set":                http.StatusBadRequest,
+	"already_confirmed_assurances":      http.StatusBadRequest,
+	"invalid_assurance_acknowledgement": http.StatusBadRequest,
+	"already_has_deposit":               http.StatusBadRequest,
+	"invalid_deposit_amount":            http.StatusBadRequest,
+	"invalid_deposit_tx":                http.StatusBadRequest,
+	"already_has_lp":                    http.StatusBadRequest,
+	"invalid_lp_tx":                     http.StatusBadRequest,
+	"chain_unavailable":                 http.StatusServiceUnavailable,
+	"nonce_already_allocated":           http.StatusBadRequest,
+	"nonce_already_used":                http.StatusBadRequest,
+	"invalid_tx_signature":              http.StatusBadRequest,
+	"invalid_log_level":                 http.StatusBadRequest,
+	"invalid_withdrawal_tx":             http.StatusBadRequest,
+	"already_signed_savers_withdrawal":  http.StatusBadRequest,
+	"already_has_savers":                http.StatusBadRequest,
+	"invalid_savers_tx":                 http.StatusBadRequest,
+	"already_withdrawn_from_savers":     http.StatusBadRequest,
+	"refund_not_available":              http.StatusBadRequest,
+	"refund_not_due":                    http.StatusConflict,
+	"refund_broadcast_not_supported":    http.StatusBadRequest,
+	"withdrawal_locked":                 http.StatusConflict,
+	"early_withdrawal_already_proposed": http.StatusConflict,
+	"early_withdrawal_not_proposed":     http.StatusBadRequest,
+	"own_early_withdrawal_proposal":     http.StatusForbidden,
+	"early_withdrawal_not_needed":       http.StatusBadRequest,
+	"extension_already_proposed":        http.StatusConflict,
+	"extension_not_proposed":            http.StatusBadRequest,
+	"own_extension_proposal":            http.StatusForbidden,
+	"extension_not_available":           http.StatusBadRequest,
+	"already_settled":                   http.StatusConflict,
+	"settlement_pending":                http.StatusConflict,
+	"settlement_unavailable":            http.StatusServiceUnavailable,
+	"settlement_not_found":              http.StatusNotFound,
+	"position_unavailable":              http.StatusServiceUnavailable,
+	"no_position":                       http.StatusNotFound,
+	"fees_unavailable":                  http.StatusServiceUnavailable,
+	"pair_chat_full":                    http.StatusConflict,
+	"message_rate_limited":              http.StatusTooManyRequests,
+	"invalid_export_columns":            http.StatusBadRequest,
+
+	// Escrow errors
+	"escrow_not_found":                 http.StatusNotFound,
+	"share_already_escrowed":           http.StatusConflict,
+	"escrow_unavailable":               http.StatusServiceUnavailable,
+	"share_recovery_not_found":         http.StatusNotFound,
+	"share_recovery_not_pending":       http.StatusConflict,
+	"share_recovery_not_approved":      http.StatusConflict,
+	"share_recovery_expired":           http.StatusConflict,
+	"share_recovery_self_approval":     http.StatusForbidden,
+	"share_recovery_operator_mismatch": http.StatusForbidden,
+
+	// Projection errors
+	"projection_not_found":      http.StatusNotFound,
+	"projection_not_resettable": http.StatusConflict,
+}
+
+// NewError creates a new domain error.
+// It panics if the code is not registered in the error catalog so unmapped codes are caught at startup.
 func NewError(code, message string) *Error {
+	if _, ok := errorCatalog[code]; !ok {
+		panic(fmt.Sprintf("error code %q is not registered in the error catalog", code))
+	}
+
 	return &Error{
 		Code:    code,
 		Message: message,
 	}
 }
 
+// HttpStatus returns the HTTP status registered for the error code
+func (e *Error) HttpStatus() int {
+	if status, ok := errorCatalog[e.Code]; ok {
+		return status
+	}
+
+	return http.StatusInternalServerError
+}
+
 // IncludeMeta includes meta data in the error
 func (e *Error) IncludeMeta(meta map[string]interface{}) *Error {
 	return &Error{
@@ -30,6 +178,18 @@ func (e *Error) IncludeMeta(meta map[string]interface{}) *Error {
 		Message:  e.Message,
 		Internal: e.Internal,
 		Meta:     meta,
+		TraceId:  e.TraceId,
+	}
+}
+
+// WithTraceId returns a copy of the error that carries the given trace id
+func (e *Error) WithTraceId(traceId string) *Error {
+	return &Error{
+		Code:     e.Code,
+		Message:  e.Message,
+		Internal: e.Internal,
+		Meta:     e.Meta,
+		TraceId:  traceId,
 	}
 }
 
@@ -43,11 +203,52 @@ func (e *Error) Error() string {
 }
 
 // ErrorFromValidationErrors creates a domain error from a list of validation errors
+// with a message per invalid field, e.g. {"assurances[0].tx": "is required"}
 func ErrorFromValidationErrors(errs validator.ValidationErrors) *Error {
 	meta := make(map[string]interface{})
 	for _, err := range errs {
-		meta[strcase.ToSnake(err.Field())] = err.ActualTag()
+		meta[validationField(err)] = validationMessage(err)
 	}
 
 	return NewError("invalid_request", "validation error").IncludeMeta(meta)
 }
+
+// validationField returns the path of the invalid field without the name of the validated struct
+func validationField(err validator.FieldError) string {
+	_, field, found := strings.Cut(err.Namespace(), ".")
+	if !found {
+		field = err.Field()
+	}
+
+	return field
+}
+
+var (
+	ErrInternal          = NewError("internal_error", "internal server error")
+	ErrRouteNotFound     = NewError("route_not_found", "route not found")
+	ErrMethodNotAllowed  = NewError("method_not_allowed", "method not allowed")
+	ErrRequestTooLarge   = NewError("request_too_large", "request body too large")
+	ErrRequestTimeout    = NewError("request_timeout", "request took too long to complete")
+	ErrServerKeyNotFound = NewError("server_key_not_found", "responses are not signed by this server")
+)
+
+// ErrorFromHttpStatus creates a domain error for failures that are only known by their HTTP status
+func ErrorFromHttpStatus(status int, message string) *Error {
+	switch status {
+	case http.StatusNotFound:
+		return ErrRouteNotFound
+	case http.StatusMethodNotAllowed:
+		return ErrMethodNotAllowed
+	case http.StatusRequestEntityTooLarge:
+		return ErrRequestTooLarge
+	case http.StatusUnauthorized:
+		return NewError("auth_failed", message)
+	case http.StatusForbidden:
+		return NewError("forbidden", message)
+	}
+	if status >= http.StatusBadRequest && status < http.StatusInternalServerError {
+		return NewError("invalid_request", message)
+	}
+
+	return ErrInternal
+}
@@ -0,0 +1,289 @@
+package httpclient
+
+import (
+	"errors"
+	"expvar"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// metrics exposes the outbound requests by "<client>.<metric>"
+var metrics = expvar.NewMap("outbound_http")
+
+// ErrCircuitOpen is returned without sending the request while the circuit of the client is open
+var ErrCircuitOpen = errors.New("circuit open, the service failed too many times in a row")
+
+const (
+	defaultTimeout     = 10 * time.Second
+	defaultRetries     = 2
+	defaultBackoff     = 200 * time.Millisecond
+	defaultFailures    = 5
+	defaultCooldown    = 30 * time.Second
+	maxRetryAfter      = 10 * time.Second
+	maxRetryBackoff    = 5 * time.Second
+	errorResponseDrain = 4 << 10
+)
+
+// Client sends the outbound requests to a service with the same policies for every integration: each attempt is timed out,
+// the failed attempts are retried with an exponential backoff when the request can be sent again safely, the calls are
+// rejected for a while once the service failed too many times in a row and the requests are counted in the metrics.
+// A failed attempt is a transport error or a response with a 429 or 5xx status.
+type Client struct {
+	name       string
+	client     *http.Client
+	retries    int
+	backoff    time.Duration
+	idempotent map[string]bool
+	breaker    *breaker
+	logger     zerolog.Logger
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithTimeout sets how long an attempt may take, including reading the response body
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		c.client.Timeout = timeout
+	}
+}
+
+// WithRetries sets how many times a failed attempt is retried, waiting backoff before the first retry and doubling it
+// after each one unless the service tells how long to wait. Zero disables the retries.
+func WithRetries(retries int, backoff time.Duration) Option {
+	return func(c *Client) {
+		c.retries = retries
+		c.backoff = backoff
+	}
+}
+
+// WithIdempotentMethods sets the methods retried on top of GET, HEAD, OPTIONS, PUT and DELETE, e.g. POST for the
+// JSON-RPC APIs whose calls can be applied twice
+func WithIdempotentMethods(methods ...string) Option {
+	return func(c *Client) {
+		for _, method := range methods {
+			c.idempotent[method] = true
+		}
+	}
+}
+
+// WithCircuitBreaker opens the circuit once the service failed failures times in a row, the requests are rejected with
+// ErrCircuitOpen until cooldown elapses and a single request probes the service again. Zero failures disables the breaker.
+func WithCircuitBreaker(failures int, cooldown time.Duration) Option {
+	return func(c *Client) {
+		c.breaker = nil
+		if failures > 0 {
+			c.breaker = &breaker{failures: failures, cooldown: cooldown}
+		}
+	}
+}
+
+// WithLogger logs the failed attempts and the circuit opening with the logger
+func WithLogger(logger zerolog.Logger) Option {
+	return func(c *Client) {
+		c.logger = logger
+	}
+}
+
+// WithTransport sets the transport the requests are sent through
+func WithTransport(transport http.RoundTripper) Option {
+	return func(c *Client) {
+		c.client.Transport = transport
+	}
+}
+
+// New creates a new Client for the service, named after it in the metrics and the errors
+func New(name string, opts ...Option) *Client {
+	c := &Client{
+		name:    name,
+		client:  &http.Client{Timeout: defaultTimeout},
+		retries: defaultRetries,
+		backoff: defaultBackoff,
+		idempotent: map[string]bool{
+			http.MethodGet:     true,
+			http.MethodHead:    true,
+			http.MethodOptions: true,
+			http.MethodPut:     true,
+			http.MethodDelete:  true,
+		},
+		breaker: &breaker{failures: defaultFailures, cooldown: defaultCooldown},
+		logger:  zerolog.Nop(),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+// Do sends the request as http.Client.Do does, the response of the last attempt is returned whatever its status.
+// The request is only retried when its method is idempotent and its body can be read again, as the ones created by
+// http.NewRequest from a bytes.Reader, a bytes.Buffer or a strings.Reader.
+func (c *Client) Do(req *http.Request) (*http.Response, error) {
+	replayable := c.idempotent[req.Method] && (req.Body == nil || req.Body == http.NoBody || req.GetBody != nil)
+	backoff := c.backoff
+
+	for attempt := 0; ; attempt++ {
+		if !c.breaker.allow() {
+			metrics.Add(c.name+".rejected", 1)
+			return nil, fmt.Errorf("%s: %w", c.name, ErrCircuitOpen)
+		}
+		if attempt > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, fmt.Errorf("failed to replay %s request: %w", c.name, err)
+			}
+			req.Body = body
+		}
+
+		start := time.Now()
+		res, err := c.client.Do(req)
+		metrics.Add(c.name+".requests", 1)
+		metrics.Add(c.name+".duration_ms", time.Since(start).Milliseconds())
+
+		failed := err != nil || Failed(res)
+		// the request cancelled by the caller says nothing about the health of the service
+		if err != nil && req.Context().Err() != nil {
+			c.breaker.abort()
+			return nil, err
+		}
+		if !failed {
+			c.breaker.succeed()
+			return res, nil
+		}
+
+		metrics.Add(c.name+".failures", 1)
+		c.logFailure(req, res, err, attempt)
+		if c.breaker.fail() {
+			metrics.Add(c.name+".circuit_opened", 1)
+			c.logger.Warn().Str("client", c.name).Dur("cooldown", c.breaker.cooldown).Msg("circuit opened, the service failed too many times in a row")
+		}
+		if !replayable || attempt >= c.retries {
+			return res, err
+		}
+
+		wait := retryAfter(res)
+		if wait == 0 {
+			wait = backoff
+			backoff = min(2*backoff, maxRetryBackoff)
+		}
+		if res != nil {
+			drain(res)
+		}
+		metrics.Add(c.name+".retries", 1)
+
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// logFailure logs the failed attempt, without the URL of the request as it may carry an API key
+func (c *Client) logFailure(req *http.Request, res *http.Response, err error, attempt int) {
+	event := c.logger.Debug().Str("client", c.name).Str("method", req.Method).Int("attempt", attempt+1)
+	if res != nil {
+		event = event.Int("status", res.StatusCode)
+	}
+	event.Err(err).Msg("request failed")
+}
+
+// Failed tells if the response reports a failure of the service rather than of the request, i.e. a 429 or 5xx status
+func Failed(res *http.Response) bool {
+	return res.StatusCode == http.StatusTooManyRequests || res.StatusCode >= http.StatusInternalServerError
+}
+
+// retryAfter returns how long the service told to wait before retrying, in seconds by the Retry-After header, capped
+func retryAfter(res *http.Response) time.Duration {
+	if res == nil {
+		return 0
+	}
+	seconds, err := strconv.Atoi(res.Header.Get("Retry-After"))
+	if err != nil || seconds <= 0 {
+		return 0
+	}
+
+	return min(time.Duration(seconds)*time.Second, maxRetryAfter)
+}
+
+// drain reads the start of the body of the discarded response before closing it, so its connection can be reused
+func drain(res *http.Response) {
+	buf := make([]byte, errorResponseDrain)
+	_, _ = res.Body.Read(buf)
+	res.Body.Close()
+}
+
+// breaker counts the consecutive failures of a service, a nil breaker allows every request
+type breaker struct {
+	failures int
+	cooldown time.Duration
+
+	mu        sync.Mutex
+	failed    int
+	openUntil time.Time
+	probing   bool
+}
+
+// allow tells if a request may be sent, a single request probes the service once the cooldown of the open circuit elapsed
+func (b *breaker) allow() bool {
+	if b == nil {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.failed < b.failures {
+		return true
+	}
+	if time.Now().Before(b.openUntil) || b.probing {
+		return false
+	}
+	b.probing = true
+	return true
+}
+
+func (b *breaker) succeed() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failed = 0
+	b.probing = false
+}
+
+// abort releases the probe of the request which neither succeeded nor failed
+func (b *breaker) abort() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+}
+
+// fail records a failure and tells if it opened the circuit
+func (b *breaker) fail() bool {
+	if b == nil {
+		return false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failed++
+	probed := b.probing
+	b.probing = false
+	if b.failed < b.failures {
+		return false
+	}
+	b.openUntil = time.Now().Add(b.cooldown)
+	return b.failed == b.failures || probed
+}
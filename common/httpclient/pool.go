@@ -0,0 +1,86 @@
+package httpclient
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+)
+
+// Pool spreads the requests to a service across the endpoints serving it, e.g. the RPC nodes of a chain. The requests go
+// to the endpoints in turn and fail over to the next endpoint when one fails. Every endpoint is sent its requests through
+// a Client of its own, so an endpoint failing too many times in a row is left out until its circuit closes again.
+type Pool struct {
+	name      string
+	endpoints []poolEndpoint
+	next      atomic.Uint64
+}
+
+type poolEndpoint struct {
+	url    string
+	client *Client
+}
+
+// NewPool creates a new Pool for the endpoints of the service at the base URLs, the options apply to every endpoint.
+// The endpoints of a pool of several aren't retried by default, the requests fail over to the next endpoint instead.
+func NewPool(name string, urls []string, opts ...Option) (*Pool, error) {
+	if len(urls) == 0 {
+		return nil, fmt.Errorf("no endpoint for %s", name)
+	}
+	if len(urls) > 1 {
+		opts = append([]Option{WithRetries(0, 0)}, opts...)
+	}
+
+	p := &Pool{name: name}
+	for _, raw := range urls {
+		u, err := url.Parse(raw)
+		if err != nil || u.Host == "" {
+			return nil, fmt.Errorf("invalid %s endpoint %q", name, raw)
+		}
+		// the endpoints are named after their host only, their paths may carry API keys
+		p.endpoints = append(p.endpoints, poolEndpoint{
+			url:    strings.TrimSuffix(raw, "/"),
+			client: New(name+"@"+u.Host, opts...),
+		})
+	}
+
+	return p, nil
+}
+
+// Do sends the request built by newRequest for the base URL of an endpoint, without trailing slash. When the endpoint
+// fails or its circuit is open, the request is built and sent again to the next endpoint as long as its method is
+// idempotent. The response of the last endpoint tried is returned whatever its status.
+func (p *Pool) Do(newRequest func(baseURL string) (*http.Request, error)) (*http.Response, error) {
+	start := int(p.next.Add(1) - 1)
+
+	var (
+		res *http.Response
+		err error
+	)
+	for i := range p.endpoints {
+		endpoint := p.endpoints[(start+i)%len(p.endpoints)]
+		req, reqErr := newRequest(endpoint.url)
+		if reqErr != nil {
+			return nil, reqErr
+		}
+
+		res, err = endpoint.client.Do(req)
+		if err == nil && !Failed(res) {
+			return res, nil
+		}
+		if req.Context().Err() != nil || !endpoint.client.idempotent[req.Method] && !errors.Is(err, ErrCircuitOpen) {
+			return res, err
+		}
+		if i < len(p.endpoints)-1 {
+			if res != nil {
+				drain(res)
+			}
+			metrics.Add(p.name+".failovers", 1)
+			endpoint.client.logger.Info().Str("client", endpoint.client.name).Msg("failing over to the next endpoint")
+		}
+	}
+
+	return res, err
+}
@@ -0,0 +1,97 @@
+package common
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/co-defi/api-server/domain"
+	"github.com/golang-jwt/jwt"
+	"github.com/google/uuid"
+)
+
+// RoleParticipant is the role of the tokens issued to the participants after verifying their challenge
+const RoleParticipant = "participant"
+
+// JWTIssuer issues the verified tokens as signed JWTs, so the replicas of the server can verify them without sharing the token store
+type JWTIssuer struct {
+	secret []byte
+	ttl    time.Duration
+	clock  Clock
+}
+
+// NewJWTIssuer creates a new JWTIssuer signing the JWTs with the secret (HS256), valid for ttl by the clock
+func NewJWTIssuer(secret []byte, ttl time.Duration, clock Clock) *JWTIssuer {
+	return &JWTIssuer{secret: secret, ttl: ttl, clock: clock}
+}
+
+type jwtClaims struct {
+	jwt.StandardClaims
+	Chain   Chain          `json:"chain"`
+	Network domain.Network `json:"network,omitempty"`
+	Role    string         `json:"role"`
+}
+
+// Issue returns the JWT of the verified token and the time it expires at
+func (i *JWTIssuer) Issue(token Token) (string, time.Time, error) {
+	now := i.clock.Now()
+	expiresAt := now.Add(i.ttl)
+	claims := jwtClaims{
+		StandardClaims: jwt.StandardClaims{
+			Id:        token.Id.String(),
+			Subject:   token.Address,
+			IssuedAt:  now.Unix(),
+			ExpiresAt: expiresAt.Unix(),
+		},
+		Chain:   token.Chain,
+		Network: token.Network,
+		Role:    RoleParticipant,
+	}
+
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(i.secret)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to sign jwt: %w", err)
+	}
+
+	return signed, expiresAt, nil
+}
+
+// Parse verifies the signature and expiry of the JWT and returns the verified token it was issued for
+func (i *JWTIssuer) Parse(raw string) (Token, error) {
+	var claims jwtClaims
+	// The claims are validated by the clock of the issuer rather than by the one of the jwt package
+	parser := jwt.Parser{SkipClaimsValidation: true}
+	_, err := parser.ParseWithClaims(raw, &claims, func(t *jwt.Token) (interface{}, error) {
+		if t.Method != jwt.SigningMethodHS256 {
+			return nil, fmt.Errorf("unexpected signing method %s", t.Header["alg"])
+		}
+		return i.secret, nil
+	})
+	if err != nil {
+		return Token{}, err
+	}
+	now := i.clock.Now().Unix()
+	if !claims.VerifyExpiresAt(now, true) {
+		return Token{}, jwt.NewValidationError("token is expired", jwt.ValidationErrorExpired)
+	}
+	if !claims.VerifyIssuedAt(now, false) {
+		return Token{}, jwt.NewValidationError("token used before issued", jwt.ValidationErrorIssuedAt)
+	}
+	if claims.Role != RoleParticipant {
+		return Token{}, fmt.Errorf("unexpected role %q", claims.Role)
+	}
+
+	id, err := uuid.Parse(claims.Id)
+	if err != nil {
+		return Token{}, err
+	}
+
+	return Token{
+		Id:        id,
+		Chain:     claims.Chain,
+		Address:   claims.Subject,
+		Network:   claims.Network,
+		IssuedAt:  claims.IssuedAt,
+		ExpiresAt: claims.ExpiresAt,
+		Verified:  true,
+	}, nil
+}
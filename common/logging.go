@@ -0,0 +1,158 @@
+package common
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// The modules whose log level can be set apart from the default one
+const (
+	LogModuleHTTP        = "http"
+	LogModuleProjections = "projections"
+	LogModuleChains      = "chains"
+)
+
+// LogModules are the modules whose log level can be set apart from the default one
+var LogModules = []string{LogModuleHTTP, LogModuleProjections, LogModuleChains}
+
+// Log formats, the console one is meant for the terminals
+const (
+	LogFormatJSON    = "json"
+	LogFormatConsole = "console"
+)
+
+var ErrInvalidLogLevel = NewError("invalid_log_level", "log level or module is not valid")
+
+// LogLevels holds the levels of the logs by module, changed at runtime. The loggers of the modules write through it, so
+// their entries below the level of their module are dropped, the modules without a level of their own follow the default one.
+type LogLevels struct {
+	out io.Writer
+
+	mu       sync.RWMutex
+	level    zerolog.Level
+	override map[string]zerolog.Level
+}
+
+// NewLogLevels creates a new LogLevels writing the logs to out at the default level
+func NewLogLevels(out io.Writer, level zerolog.Level) *LogLevels {
+	// the levels are enforced by the writers, the loggers don't drop any entry themselves
+	zerolog.SetGlobalLevel(zerolog.TraceLevel)
+
+	return &LogLevels{out: out, level: level, override: make(map[string]zerolog.Level)}
+}
+
+// NewLogger creates the root logger writing to stdout in the format and the LogLevels of its modules at the default level
+func NewLogger(format string, level zerolog.Level) (zerolog.Logger, *LogLevels, error) {
+	var out io.Writer = os.Stdout
+	switch format {
+	case LogFormatJSON:
+	case LogFormatConsole:
+		out = zerolog.ConsoleWriter{Out: os.Stdout, TimeFormat: time.RFC3339}
+	default:
+		return zerolog.Nop(), nil, fmt.Errorf("invalid log format %q, must be %s or %s", format, LogFormatJSON, LogFormatConsole)
+	}
+
+	levels := NewLogLevels(out, level)
+	return zerolog.New(levels.writer("")).With().Timestamp().Logger(), levels, nil
+}
+
+// Logger returns the logger of the module, logger with its entries tagged with the module and leveled by it
+func (l *LogLevels) Logger(logger zerolog.Logger, module string) zerolog.Logger {
+	if l == nil {
+		return logger
+	}
+
+	return logger.Output(l.writer(module)).With().Str("module", module).Logger()
+}
+
+// Level returns the level of the module, the default level for the empty module
+func (l *LogLevels) Level(module string) zerolog.Level {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	if level, ok := l.override[module]; ok {
+		return level
+	}
+	return l.level
+}
+
+// Set sets the level of the module, or the default level for the empty module
+func (l *LogLevels) Set(module string, level zerolog.Level) error {
+	if module != "" && !slices.Contains(LogModules, module) {
+		return ErrInvalidLogLevel.IncludeMeta(map[string]interface{}{"module": module, "modules": LogModules})
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if module == "" {
+		l.level = level
+	} else {
+		l.override[module] = level
+	}
+	return nil
+}
+
+// Reset makes the module follow the default level again
+func (l *LogLevels) Reset(module string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.override, module)
+}
+
+// Levels returns the default level and the level of every module
+func (l *LogLevels) Levels() (string, map[string]string) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	modules := make(map[string]string, len(LogModules))
+	for _, module := range LogModules {
+		level, ok := l.override[module]
+		if !ok {
+			level = l.level
+		}
+		modules[module] = level.String()
+	}
+
+	return l.level.String(), modules
+}
+
+// ParseLogLevel parses the name of a level, e.g. debug, info or warn
+func ParseLogLevel(value string) (zerolog.Level, error) {
+	level, err := zerolog.ParseLevel(strings.ToLower(value))
+	if err != nil || value == "" {
+		return zerolog.NoLevel, ErrInvalidLogLevel.IncludeMeta(map[string]interface{}{"level": value})
+	}
+
+	return level, nil
+}
+
+func (l *LogLevels) writer(module string) zerolog.LevelWriter {
+	return &moduleWriter{levels: l, module: module}
+}
+
+// moduleWriter writes the entries of a module at its level or above
+type moduleWriter struct {
+	levels *LogLevels
+	module string
+}
+
+func (w *moduleWriter) Write(p []byte) (int, error) {
+	return w.levels.out.Write(p)
+}
+
+func (w *moduleWriter) WriteLevel(level zerolog.Level, p []byte) (int, error) {
+	if level < w.levels.Level(w.module) && level != zerolog.NoLevel {
+		return len(p), nil
+	}
+
+	return w.levels.out.Write(p)
+}
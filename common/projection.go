@@ -1,8 +1,12 @@
 package common
 
 import (
+	"context"
 	"database/sql"
+	"errors"
+	"expvar"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/hallgren/eventsourcing"
@@ -15,20 +19,38 @@ const projectionCount = 100
 // BaseProjection is a base struct for all projections and queries
 type BaseProjection struct {
 	*sql.DB
-	store Store
-	name  string
+	reader    *sql.DB
+	store     Store
+	name      string
+	auxTables []string
+
+	// batch is the transaction the events of the current fetch are applied in
+	batch       *sql.Tx
+	lastHandled core.Version
+	afterCommit []func()
+	commitErr   error
+	// onReset are called once the projection is reset, e.g. to clear caches
+	onReset []func()
 }
 
-// NewBaseProjection creates a new BaseProjection
-func NewBaseProjection(db *sql.DB, store Store, name string) (*BaseProjection, error) {
-	if err := registerProjection(db, name); err != nil {
+// NewBaseProjection creates a new BaseProjection that writes through the write pool of db.
+// The table named after the projection and the auxiliary tables are dropped when the projection runs for the first time.
+// The schema version must be bumped whenever the columns of the tables change, the projection is rebuilt from the start then.
+func NewBaseProjection(db *DB, store Store, name string, schemaVersion int, auxTables ...string) (*BaseProjection, error) {
+	if err := registerProjection(db.Write, name, schemaVersion); err != nil {
 		return nil, err
 	}
 
 	bp := BaseProjection{
-		db,
-		store,
-		name,
+		DB:        db.Write,
+		reader:    db.Read,
+		store:     store,
+		name:      name,
+		auxTables: auxTables,
+	}
+
+	if err := bp.rewindIfOutdated(schemaVersion); err != nil {
+		return nil, err
 	}
 
 	if err := bp.dropTableIfFirstRun(); err != nil {
@@ -38,12 +60,16 @@ func NewBaseProjection(db *sql.DB, store Store, name string) (*BaseProjection, e
 	return &bp, nil
 }
 
-func registerProjection(db *sql.DB, name string) error {
+func registerProjection(db *sql.DB, name string, schemaVersion int) error {
 	if err := createProjectionsTable(db); err != nil {
 		return fmt.Errorf("failed to create projections table: %w", err)
 	}
 
-	if err := insertProjectionRecord(db, name); err != nil {
+	if err := createDeadLettersTable(db); err != nil {
+		return fmt.Errorf("failed to create dead letters table: %w", err)
+	}
+
+	if err := insertProjectionRecord(db, name, schemaVersion); err != nil {
 		return fmt.Errorf("failed to insert projection record: %w", err)
 	}
 
@@ -51,15 +77,58 @@ func registerProjection(db *sql.DB, name string) error {
 }
 
 func createProjectionsTable(db *sql.DB) error {
-	_, err := db.Exec(`create table if not exists projections (id VARCHAR PRIMARY KEY, last_handled_event_seq INTEGER);`)
-	return err
+	if _, err := db.Exec(`create table if not exists projections (id VARCHAR PRIMARY KEY, last_handled_event_seq INTEGER, schema_version INTEGER NOT NULL DEFAULT 1);`); err != nil {
+		return err
+	}
+
+	// the projections recorded before the schema versions were introduced are at the first version
+	var versioned bool
+	if err := db.QueryRow(`select count(*) > 0 from pragma_table_info('projections') where name = 'schema_version';`).Scan(&versioned); err != nil {
+		return err
+	}
+	if !versioned {
+		_, err := db.Exec(`alter table projections add column schema_version INTEGER NOT NULL DEFAULT 1;`)
+		return err
+	}
+
+	return nil
 }
 
-func insertProjectionRecord(db *sql.DB, name string) error {
-	_, err := db.Exec(`insert into projections (id, last_handled_event_seq) values (?, ?) on conflict do nothing;`, name, 0)
+func insertProjectionRecord(db *sql.DB, name string, schemaVersion int) error {
+	_, err := db.Exec(`insert into projections (id, last_handled_event_seq, schema_version) values (?, ?, ?) on conflict do nothing;`, name, 0, schemaVersion)
 	return err
 }
 
+// rewindIfOutdated rewinds the projection to the start and drops its dead letters when its tables were created by another schema version,
+// so the tables are dropped and rebuilt the same way as on its first run.
+func (bp *BaseProjection) rewindIfOutdated(schemaVersion int) error {
+	var current int
+	if err := bp.QueryRow(`select schema_version from projections where id = ?;`, bp.name).Scan(&current); err != nil {
+		return fmt.Errorf("failed to get schema version: %w", err)
+	}
+	if current == schemaVersion {
+		return nil
+	}
+
+	tx, err := bp.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin rewind: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`delete from projection_dead_letters where projection = ?;`, bp.name); err != nil {
+		return fmt.Errorf("failed to drop dead letters: %w", err)
+	}
+	if _, err := tx.Exec(`update projections set last_handled_event_seq = 0, schema_version = ? where id = ?;`, schemaVersion, bp.name); err != nil {
+		return fmt.Errorf("failed to rewind projection: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit rewind: %w", err)
+	}
+
+	return nil
+}
+
 func (bp *BaseProjection) dropTableIfFirstRun() error {
 	ok, err := bp.isFirstRun()
 	if err != nil {
@@ -90,12 +159,81 @@ func (bp *BaseProjection) getLastHandledEventSeq() (int, error) {
 }
 
 func (bp *BaseProjection) dropTable() error {
-	_, err := bp.Exec(`drop table if exists ` + bp.name + `;`)
-	return err
+	for _, table := range append([]string{bp.name}, bp.auxTables...) {
+		if _, err := bp.Exec(`drop table if exists ` + table + `;`); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Name returns the name of the projection
+func (bp *BaseProjection) Name() string {
+	return bp.name
+}
+
+// Reader returns the read pool which the queries serving the clients should go through
+func (bp *BaseProjection) Reader() *sql.DB {
+	return bp.reader
+}
+
+// OnReset registers f to run once the projection is reset, e.g. to clear the caches of its query
+func (bp *BaseProjection) OnReset(f func()) {
+	bp.onReset = append(bp.onReset, f)
+}
+
+// Reset empties the tables of the projection and drops its dead letters, so it handles all the events again from the start.
+// It must be called from the goroutine running the projection, see FailSafeProjection.RequestReset.
+func (bp *BaseProjection) Reset() error {
+	if bp.batch != nil {
+		return fmt.Errorf("failed to reset projection %s: a batch is in progress", bp.name)
+	}
+
+	tx, err := bp.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin reset: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, table := range append([]string{bp.name}, bp.auxTables...) {
+		var exists bool
+		if err := tx.QueryRow(`select count(*) > 0 from sqlite_master where type = 'table' and name = ?;`, table).Scan(&exists); err != nil {
+			return fmt.Errorf("failed to check table %s: %w", table, err)
+		}
+		if !exists {
+			continue
+		}
+		if _, err := tx.Exec(`delete from ` + table + `;`); err != nil {
+			return fmt.Errorf("failed to empty table %s: %w", table, err)
+		}
+	}
+	if _, err := tx.Exec(`delete from projection_dead_letters where projection = ?;`, bp.name); err != nil {
+		return fmt.Errorf("failed to drop dead letters: %w", err)
+	}
+	if _, err := tx.Exec(`update projections set last_handled_event_seq = 0 where id = ?;`, bp.name); err != nil {
+		return fmt.Errorf("failed to rewind projection: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit reset: %w", err)
+	}
+
+	bp.lastHandled = 0
+	for _, hook := range bp.onReset {
+		hook()
+	}
+
+	return nil
 }
 
-// Fetch fetches events from the store
+// Fetch fetches the next batch of events from the store.
+// The events handled through Apply are committed at once when the batch iterator is closed.
 func (bp *BaseProjection) Fetch() (core.Iterator, error) {
+	if err := bp.commitErr; err != nil {
+		bp.commitErr = nil
+		return nil, fmt.Errorf("previous batch is not committed: %w", err)
+	}
+
 	lastStart, err := bp.getLastHandledEventSeq()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get last processed event seq: %w", err)
@@ -106,15 +244,18 @@ func (bp *BaseProjection) Fetch() (core.Iterator, error) {
 		return nil, fmt.Errorf("failed to create events iterator: %w", err)
 	}
 
-	return newCacheIterator(it)
+	return newCacheIterator(it, bp.commitBatch)
 }
 
 type cacheIterator struct {
-	events []core.Event
-	pos    int
+	events  []core.Event
+	pos     int
+	onClose func()
 }
 
-func newCacheIterator(it core.Iterator) (*cacheIterator, error) {
+func newCacheIterator(it core.Iterator, onClose func()) (*cacheIterator, error) {
+	defer it.Close()
+
 	var events []core.Event
 	for it.Next() {
 		e, err := it.Value()
@@ -125,7 +266,7 @@ func newCacheIterator(it core.Iterator) (*cacheIterator, error) {
 		events = append(events, e)
 	}
 
-	return &cacheIterator{events: events, pos: -1}, nil
+	return &cacheIterator{events: events, pos: -1, onClose: onClose}, nil
 }
 
 func (ci *cacheIterator) Next() bool {
@@ -137,26 +278,86 @@ func (ci *cacheIterator) Value() (core.Event, error) {
 	return ci.events[ci.pos], nil
 }
 
-func (ci *cacheIterator) Close() {}
+func (ci *cacheIterator) Close() {
+	ci.onClose()
+}
 
-// Begin starts a new transaction for the projection
-func (bp *BaseProjection) Begin() (*sql.Tx, error) {
-	tx, err := bp.DB.Begin()
-	if err != nil {
-		return nil, err
+// Apply applies the changes of the event to the projection in the transaction of the current batch.
+// Every event runs in its own savepoint, so a failing event is rolled back without discarding the rest of the batch.
+func (bp *BaseProjection) Apply(event eventsourcing.Event, apply func(tx *sql.Tx) error) error {
+	if err := bp.begin(); err != nil {
+		return err
+	}
+
+	if _, err := bp.batch.Exec(`savepoint event;`); err != nil {
+		return fmt.Errorf("failed to create savepoint: %w", err)
+	}
+
+	if err := apply(bp.batch); err != nil {
+		if _, rollbackErr := bp.batch.Exec(`rollback to event;`); rollbackErr != nil {
+			return fmt.Errorf("failed to roll back event: %w", rollbackErr)
+		}
+		if _, releaseErr := bp.batch.Exec(`release event;`); releaseErr != nil {
+			return fmt.Errorf("failed to release savepoint: %w", releaseErr)
+		}
+		return err
+	}
+
+	if _, err := bp.batch.Exec(`release event;`); err != nil {
+		return fmt.Errorf("failed to release savepoint: %w", err)
+	}
+	bp.lastHandled = core.Version(event.GlobalVersion())
+
+	return nil
+}
+
+// begin starts the transaction of the current batch unless it's already started
+func (bp *BaseProjection) begin() error {
+	if bp.batch != nil {
+		return nil
 	}
 
-	err = incrementProjection(tx, bp.name)
+	tx, err := bp.DB.Begin()
 	if err != nil {
-		return nil, err
+		return fmt.Errorf("failed to begin batch: %w", err)
 	}
+	bp.batch = tx
 
-	return tx, nil
+	return nil
 }
 
-func incrementProjection(tx *sql.Tx, name string) error {
-	_, err := tx.Exec(`update projections set last_handled_event_seq = last_handled_event_seq + 1 where id = ?;`, name)
-	return err
+// AfterCommit registers f to run once the current batch is committed, e.g. to invalidate caches or to cause side effects
+func (bp *BaseProjection) AfterCommit(f func()) {
+	bp.afterCommit = append(bp.afterCommit, f)
+}
+
+// commitBatch records the last handled event and commits the batch along with it
+func (bp *BaseProjection) commitBatch() {
+	if bp.batch == nil {
+		return
+	}
+
+	tx, hooks := bp.batch, bp.afterCommit
+	bp.batch, bp.afterCommit = nil, nil
+
+	if bp.lastHandled > 0 {
+		if _, err := tx.Exec(`update projections set last_handled_event_seq = ? where id = ?;`, bp.lastHandled, bp.name); err != nil {
+			tx.Rollback()
+			bp.commitErr = err
+			bp.lastHandled = 0
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		bp.commitErr = err
+		bp.lastHandled = 0
+		return
+	}
+
+	for _, hook := range hooks {
+		hook()
+	}
 }
 
 // Store is an interface that limits the methods that can be called on a store to All method only
@@ -164,26 +365,151 @@ type Store interface {
 	All(start core.Version, count uint64) (core.Iterator, error)
 }
 
+const (
+	// callbackAttempts is how many times an event is handled before it's parked in the dead letters
+	callbackAttempts = 3
+	callbackBackoff  = 100 * time.Millisecond
+	fetchBackoff     = 2 * time.Second
+	maxFetchBackoff  = time.Minute
+)
+
+// projectionMetrics exposes the failures of the projections by "<projection>.<metric>"
+var projectionMetrics = expvar.NewMap("projections")
+
+// FailSafeProjection keeps a projection running through failures. Fetching is retried with an exponential backoff,
+// handling an event is retried a few times before the event is parked in the dead letters so the projection can move on.
 type FailSafeProjection struct {
-	base             Projection
-	logger           zerolog.Logger
-	fetchFailures    int
-	callbackFailures int
+	base          Projection
+	name          string
+	logger        zerolog.Logger
+	fetchFailures int
+	fetchRetryAt  time.Time
+	// aborted is set once the batch in progress is rolled back, the rest of its events are skipped until the next fetch
+	aborted bool
+
+	// mu guards the health of the projection and the reset requests, they are read and requested from other goroutines
+	mu             sync.Mutex
+	health         ProjectionHealth
+	resetRequested bool
+}
+
+// ProjectionHealth is the last run and the last failure of a projection
+type ProjectionHealth struct {
+	LastRunAt   *time.Time `json:"last_run_at,omitempty"`
+	LastError   string     `json:"last_error,omitempty"`
+	LastErrorAt *time.Time `json:"last_error_at,omitempty"`
+	// Resetting tells the projection is reset on its next run
+	Resetting bool `json:"resetting"`
+}
+
+// resetter is implemented by the projections that can be reset while running, e.g. through BaseProjection
+type resetter interface {
+	Reset() error
+}
+
+// parker is implemented by the projections that can park the events they fail to handle, e.g. through BaseProjection
+type parker interface {
+	Park(event eventsourcing.Event, cause error, attempts int) error
+}
+
+// aborter is implemented by the projections that can roll back the batch in progress, e.g. through BaseProjection
+type aborter interface {
+	Abort(cause error)
+}
+
+// named is implemented by the projections that have a name, e.g. through BaseProjection
+type named interface {
+	Name() string
 }
 
 func NewFailSafeProjection(base Projection, logger zerolog.Logger) *FailSafeProjection {
-	return &FailSafeProjection{
+	fsp := &FailSafeProjection{
 		base:   base,
 		logger: logger,
 	}
+	if n, ok := base.(named); ok {
+		fsp.name = n.Name()
+		fsp.logger = logger.With().Str("projection", fsp.name).Logger()
+	}
+
+	return fsp
+}
+
+// Name returns the name of the underlying projection
+func (fsp *FailSafeProjection) Name() string {
+	return fsp.name
+}
+
+// Health returns the last run and the last failure of the projection
+func (fsp *FailSafeProjection) Health() ProjectionHealth {
+	fsp.mu.Lock()
+	defer fsp.mu.Unlock()
+
+	health := fsp.health
+	health.Resetting = fsp.resetRequested
+	return health
+}
+
+// Resettable tells whether the projection can be reset while running, see RequestReset
+func (fsp *FailSafeProjection) Resettable() bool {
+	_, ok := fsp.base.(resetter)
+	return ok
+}
+
+// RequestReset requests the projection to be reset on its next run, it handles all the events again from the start then.
+// The reset runs along the projection instead of the caller, so it never races with a batch in progress.
+func (fsp *FailSafeProjection) RequestReset() error {
+	if !fsp.Resettable() {
+		return fmt.Errorf("projection %s can't be reset", fsp.name)
+	}
+
+	fsp.mu.Lock()
+	fsp.resetRequested = true
+	fsp.mu.Unlock()
+
+	return nil
+}
+
+// recordError records the failure in the health of the projection
+func (fsp *FailSafeProjection) recordError(err error) {
+	now := time.Now()
+
+	fsp.mu.Lock()
+	fsp.health.LastError, fsp.health.LastErrorAt = err.Error(), &now
+	fsp.mu.Unlock()
 }
 
 // Fetch implements the Fetch method of the Projection interface
 func (fsp *FailSafeProjection) Fetch() (core.Iterator, error) {
+	fsp.aborted = false
+	now := time.Now()
+	fsp.mu.Lock()
+	fsp.health.LastRunAt = &now
+	reset := fsp.resetRequested
+	fsp.resetRequested = false
+	fsp.mu.Unlock()
+
+	if reset {
+		if err := fsp.base.(resetter).Reset(); err != nil {
+			fsp.recordError(err)
+			fsp.logger.Error().Err(err).Msg("failed to reset projection")
+		} else {
+			fsp.fetchFailures = 0
+			fsp.logger.Info().Msg("projection reset")
+		}
+	}
+
+	if fsp.fetchFailures > 0 && time.Now().Before(fsp.fetchRetryAt) {
+		return &nopIterator{}, nil
+	}
+
 	it, err := fsp.base.Fetch()
 	if err != nil {
+		fsp.recordError(err)
 		fsp.fetchFailures++
-		fsp.logger.Error().Int("fetch_failures", fsp.fetchFailures).Err(err).Msg("failed to fetch events")
+		fsp.fetchRetryAt = time.Now().Add(backoff(fetchBackoff, maxFetchBackoff, fsp.fetchFailures))
+		projectionMetrics.Add(fsp.name+".fetch_failures", 1)
+		fsp.logger.Error().Int("fetch_failures", fsp.fetchFailures).Time("retry_at", fsp.fetchRetryAt).Err(err).Msg("failed to fetch events")
 
 		return &nopIterator{}, nil
 	}
@@ -207,37 +533,228 @@ func (*nopIterator) Close() {}
 
 // Callback implements the Callback method of the Projection interface
 func (fsp *FailSafeProjection) Callback(event eventsourcing.Event) error {
-	err := fsp.base.Callback(event)
-	if err != nil {
-		fsp.callbackFailures++
-		fsp.logger.Error().Int("callback_failures", fsp.callbackFailures).Err(err).Msg("failed to handle event")
+	if fsp.aborted {
+		return nil
+	}
+
+	var err error
+	for attempt := 1; attempt <= callbackAttempts; attempt++ {
+		if err = fsp.base.Callback(event); err == nil {
+			return nil
+		}
+		if attempt == callbackAttempts {
+			break
+		}
+
+		projectionMetrics.Add(fsp.name+".callback_retries", 1)
+		fsp.logger.Warn().Int("attempt", attempt).Uint64("global_version", uint64(event.GlobalVersion())).Err(err).Msg("failed to handle event, retrying")
+		time.Sleep(backoff(callbackBackoff, time.Second, attempt))
+	}
 
+	projectionMetrics.Add(fsp.name+".dead_letters", 1)
+	fsp.recordError(err)
+	fsp.logger.Error().
+		Uint64("global_version", uint64(event.GlobalVersion())).
+		Str("aggregate_id", event.AggregateID()).
+		Str("reason", event.Reason()).
+		Err(err).
+		Msg("failed to handle event, parking it in the dead letters")
+
+	p, ok := fsp.base.(parker)
+	if !ok {
+		return err
+	}
+	parkErr := p.Park(event, err, callbackAttempts)
+	if parkErr == nil {
+		// The error isn't returned as it stops the projection for good
 		return nil
 	}
 
-	fsp.callbackFailures = 0
+	// The batch mustn't be committed past an event neither handled nor parked, it's rolled back and fetched again
+	fsp.recordError(parkErr)
+	a, ok := fsp.base.(aborter)
+	if !ok {
+		return fmt.Errorf("failed to park event: %w", parkErr)
+	}
+	projectionMetrics.Add(fsp.name+".aborted_batches", 1)
+	fsp.logger.Error().Uint64("global_version", uint64(event.GlobalVersion())).Err(parkErr).Msg("failed to park event, rolling back the batch")
+	a.Abort(fmt.Errorf("failed to park event %d: %w", uint64(event.GlobalVersion()), parkErr))
+	fsp.aborted = true
 
 	return nil
 }
 
+// backoff returns the exponential delay before the given attempt, capped at max
+func backoff(base, max time.Duration, attempt int) time.Duration {
+	d := base
+	for i := 1; i < attempt && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+	return d
+}
+
 type Projection interface {
 	Fetch() (core.Iterator, error)
 	Callback(eventsourcing.Event) error
 }
 
-// RegisterProjectionsAsGroup registers a group of projections
-func RegisterProjectionsAsGroup(repo *eventsourcing.EventRepository, ps ...Projection) *eventsourcing.Group {
-	esps := make([]*eventsourcing.Projection, len(ps))
-	for i, p := range ps {
-		esps[i] = repo.Projections.Projection(p.Fetch, p.Callback)
-		esps[i].Pace = time.Second * 2
+// ProjectionPace tunes how often a projection looks for new events once it handled all the stored ones.
+// The delay is Min after a run that handled events, as more are likely to follow, and doubles up to Max while none are stored.
+type ProjectionPace struct {
+	Min time.Duration
+	Max time.Duration
+}
+
+// DefaultProjectionPace is the pace of the projections unless set otherwise
+var DefaultProjectionPace = ProjectionPace{Min: 250 * time.Millisecond, Max: 2 * time.Second}
+
+// next returns the delay before the next run after one that waited delay
+func (p ProjectionPace) next(delay time.Duration, handled bool) time.Duration {
+	if handled || delay < p.Min {
+		return p.Min
+	}
+	return min(delay*2, p.Max)
+}
+
+// ProjectionGroup runs a group of projections concurrently, each at its own pace.
+// Trigger wakes them all up at once, e.g. once events are saved, instead of waiting for their next run.
+type ProjectionGroup struct {
+	projections []*eventsourcing.Projection
+	paces       []ProjectionPace
+	wakes       []chan struct{}
+	cancel      context.CancelFunc
+	wg          sync.WaitGroup
+	// ErrChan receives the results of the projections stopped by an error
+	ErrChan chan eventsourcing.ProjectionResult
+}
+
+// NewProjectionGroup creates a group of projections, the projections are paced by name with pace for the others
+func NewProjectionGroup(repo *eventsourcing.EventRepository, pace ProjectionPace, paces map[string]ProjectionPace, ps ...Projection) *ProjectionGroup {
+	g := &ProjectionGroup{cancel: func() {}, ErrChan: make(chan eventsourcing.ProjectionResult)}
+	for _, p := range ps {
+		esp := repo.Projections.Projection(p.Fetch, p.Callback)
+		own := pace
+		if n, ok := p.(named); ok && n.Name() != "" {
+			esp.Name = n.Name()
+			if custom, ok := paces[n.Name()]; ok {
+				own = custom
+			}
+		}
+		own.Max = max(own.Min, own.Max)
+
+		g.projections = append(g.projections, esp)
+		g.paces = append(g.paces, own)
+		g.wakes = append(g.wakes, make(chan struct{}, 1))
+	}
+
+	return g
+}
+
+// Start starts all the projections of the group, the results of the projections stopped by an error are sent to ErrChan
+func (g *ProjectionGroup) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	g.cancel = cancel
+
+	g.wg.Add(len(g.projections))
+	for i := range g.projections {
+		go func(i int) {
+			defer g.wg.Done()
+			if result := g.run(ctx, i); !errors.Is(result.Error, context.Canceled) {
+				g.ErrChan <- result
+			}
+		}(i)
+	}
+}
+
+// run runs the projection until the context is cancelled or the projection fails
+func (g *ProjectionGroup) run(ctx context.Context, i int) eventsourcing.ProjectionResult {
+	p, pace, wake := g.projections[i], g.paces[i], g.wakes[i]
+
+	delay := pace.Min
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return eventsourcing.ProjectionResult{Error: ctx.Err(), Name: p.Name}
+		case <-timer.C:
+		case <-wake:
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+		}
+
+		result := p.RunToEnd(ctx)
+		if result.Error != nil {
+			return result
+		}
+		delay = pace.next(delay, result.LastHandledEvent.GlobalVersion() > 0)
+		timer.Reset(delay)
+	}
+}
+
+// Trigger wakes up the projections of the group waiting for their next run
+func (g *ProjectionGroup) Trigger() {
+	for _, wake := range g.wakes {
+		select {
+		case wake <- struct{}{}:
+		default:
+		}
 	}
+}
+
+// Stop stops all the projections of the group and waits for them to return
+func (g *ProjectionGroup) Stop() {
+	g.cancel()
+	g.wg.Wait()
+	close(g.ErrChan)
+}
 
-	return repo.Projections.Group(esps...)
+// LastHandledEventSeqs returns the global version of the last event handled by every projection
+func LastHandledEventSeqs(ctx context.Context, db *sql.DB) (map[string]uint64, error) {
+	rows, err := db.QueryContext(ctx, `select id, last_handled_event_seq from projections;`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query projections: %w", err)
+	}
+	defer rows.Close()
+
+	seqs := make(map[string]uint64)
+	for rows.Next() {
+		var name string
+		var seq uint64
+		if err := rows.Scan(&name, &seq); err != nil {
+			return nil, fmt.Errorf("failed to scan projection: %w", err)
+		}
+		seqs[name] = seq
+	}
+
+	return seqs, rows.Err()
+}
+
+// RewindProjection sets the projection to handle the events again from the given global version on.
+// Rewinding a projection to the start drops its tables when it's created next, the same way as on its first run.
+func RewindProjection(db *sql.DB, name string, from uint64) error {
+	if err := createProjectionsTable(db); err != nil {
+		return fmt.Errorf("failed to create projections table: %w", err)
+	}
+
+	var last uint64
+	if from > 0 {
+		last = from - 1
+	}
+	_, err := db.Exec(`update projections set last_handled_event_seq = ? where id = ?;`, last, name)
+	return err
 }
 
-// ResetAllProjections resets all projections by dropping the projections table
+// ResetAllProjections resets all projections by dropping the projections table.
+// The dead letters are dropped along, as the parked events are handled again by the rebuilt projections.
 func ResetAllProjections(db *sql.DB) error {
-	_, err := db.Exec(`drop table if exists projections;`)
+	_, err := db.Exec(`drop table if exists projections; drop table if exists projection_dead_letters;`)
 	return err
 }
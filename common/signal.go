@@ -0,0 +1,40 @@
+package common
+
+import "sync"
+
+// Signal wakes up the goroutines waiting for a key to change.
+// A waiter gets the channel of the key before checking the current state, so a change notified in between isn't missed.
+type Signal struct {
+	mu      sync.Mutex
+	waiters map[string]chan struct{}
+}
+
+// NewSignal creates a new Signal
+func NewSignal() *Signal {
+	return &Signal{waiters: make(map[string]chan struct{})}
+}
+
+// Watch returns a channel closed on the next change of the key
+func (s *Signal) Watch(key string) <-chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ch, ok := s.waiters[key]
+	if !ok {
+		ch = make(chan struct{})
+		s.waiters[key] = ch
+	}
+
+	return ch
+}
+
+// Notify wakes up every waiter of the key
+func (s *Signal) Notify(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if ch, ok := s.waiters[key]; ok {
+		close(ch)
+		delete(s.waiters, key)
+	}
+}
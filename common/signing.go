@@ -0,0 +1,102 @@
+package common
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// HeaderSignature is the header of the base64 Ed25519 signature of a signed response
+	HeaderSignature = "X-Signature"
+	// HeaderSignatureKeyId is the header of the id of the key which signed the response, see SigningKeyId
+	HeaderSignatureKeyId = "X-Signature-Key-Id"
+	// HeaderSignatureTimestamp is the header of the unix time the response was signed at
+	HeaderSignatureTimestamp = "X-Signature-Timestamp"
+)
+
+// ErrInvalidSignature is returned when the signature of a response doesn't match its body
+var ErrInvalidSignature = errors.New("invalid response signature")
+
+// ResponseSigner signs the responses of the server with an Ed25519 key, so the clients holding its public key can verify
+// the state they receive wasn't tampered with on the way
+type ResponseSigner struct {
+	key   ed25519.PrivateKey
+	keyId string
+}
+
+// NewResponseSigner creates a new ResponseSigner with the Ed25519 key
+func NewResponseSigner(key ed25519.PrivateKey) *ResponseSigner {
+	return &ResponseSigner{key: key, keyId: SigningKeyId(key.Public().(ed25519.PublicKey))}
+}
+
+// ReadSigningKeyFile creates a ResponseSigner with the 32 bytes Ed25519 seed of the file, hex or base64 encoded
+func ReadSigningKeyFile(path string) (*ResponseSigner, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signing key file: %w", err)
+	}
+
+	encoded := strings.TrimSpace(string(b))
+	seed, err := hex.DecodeString(encoded)
+	if err != nil {
+		if seed, err = base64.StdEncoding.DecodeString(encoded); err != nil {
+			return nil, fmt.Errorf("signing key file %s is neither hex nor base64 encoded", path)
+		}
+	}
+	if len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("signing key must be a %d bytes Ed25519 seed, got %d bytes", ed25519.SeedSize, len(seed))
+	}
+
+	return NewResponseSigner(ed25519.NewKeyFromSeed(seed)), nil
+}
+
+// SigningKeyId returns the id of the public key, the hex of the first 8 bytes of its SHA-256
+func SigningKeyId(pub ed25519.PublicKey) string {
+	sum := sha256.Sum256(pub)
+	return "ed25519:" + hex.EncodeToString(sum[:8])
+}
+
+// KeyId returns the id of the signing key
+func (s *ResponseSigner) KeyId() string {
+	return s.keyId
+}
+
+// PublicKey returns the public key verifying the signatures
+func (s *ResponseSigner) PublicKey() ed25519.PublicKey {
+	return s.key.Public().(ed25519.PublicKey)
+}
+
+// Sign returns the base64 signature of the response body to the request path at the given time, see signedPayload
+func (s *ResponseSigner) Sign(path string, at time.Time, body []byte) string {
+	return base64.StdEncoding.EncodeToString(ed25519.Sign(s.key, signedPayload(path, strconv.FormatInt(at.Unix(), 10), body)))
+}
+
+// VerifyResponse verifies the base64 signature of the response body to the request path signed at timestamp, as sent
+// in the HeaderSignature and HeaderSignatureTimestamp headers
+func VerifyResponse(pub ed25519.PublicKey, path, timestamp string, body []byte, signature string) error {
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil || !ed25519.Verify(pub, signedPayload(path, timestamp, body), sig) {
+		return ErrInvalidSignature
+	}
+
+	return nil
+}
+
+// signedPayload is what the signature of a response covers: the request path binds the body to the resource
+// it was served for, and the timestamp lets the clients reject stale responses replayed later
+func signedPayload(path, timestamp string, body []byte) []byte {
+	payload := make([]byte, 0, len(path)+len(timestamp)+len(body)+2)
+	payload = append(payload, path...)
+	payload = append(payload, '\n')
+	payload = append(payload, timestamp...)
+	payload = append(payload, '\n')
+	return append(payload, body...)
+}
@@ -0,0 +1,294 @@
+package common
+
+import (
+	"bytes"
+	"context"
+	"crypto/cipher"
+	"crypto/rand"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// subjectSealedPrefix marks the personal values sealed by SubjectKeys, it's followed by the id of the key sealing them
+const subjectSealedPrefix = "subj:v1:"
+
+// SubjectFields are the personal fields of an event: the path of the field naming their data subject (i.e. the address)
+// and the paths of the personal values, see Cipher.OpenJSON for the paths
+type SubjectFields struct {
+	Subject string
+	Paths   []string
+}
+
+// SubjectKeys holds a data key per data subject, sealing their personal values in the events.
+// Shredding the keys of a subject makes their personal values unreadable, they are opened as empty strings from then on,
+// without changing the events otherwise. The keys are wrapped by the cipher when set, and all loaded in memory
+// as they are needed while the events are read. The events exported alone can't be read without the subject_keys table.
+type SubjectKeys struct {
+	db     *sql.DB
+	cipher *Cipher
+
+	mu sync.RWMutex
+	// keys holds the keys not shredded by id
+	keys map[int64]cipher.AEAD
+	// current holds the id of the key sealing the values of each subject
+	current map[string]int64
+}
+
+// NewSubjectKeys creates a new SubjectKeys and its table, and loads the keys not shredded
+func NewSubjectKeys(db *sql.DB, c *Cipher) (*SubjectKeys, error) {
+	_, err := db.Exec(`create table if not exists subject_keys (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		subject TEXT,
+		key TEXT,
+		created_at TEXT,
+		shredded_at TEXT
+	);
+	create index if not exists subject_keys_subject on subject_keys (subject);`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create subject_keys table: %w", err)
+	}
+
+	k := SubjectKeys{db: db, cipher: c, keys: make(map[int64]cipher.AEAD), current: make(map[string]int64)}
+	rows, err := db.Query(`select id, subject, key from subject_keys where key is not null order by id;`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query subject keys: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			id      int64
+			subject string
+			wrapped string
+		)
+		if err := rows.Scan(&id, &subject, &wrapped); err != nil {
+			return nil, fmt.Errorf("failed to scan subject key: %w", err)
+		}
+		aead, err := k.unwrap(wrapped)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unwrap key %d: %w", id, err)
+		}
+		k.keys[id], k.current[subject] = aead, id
+	}
+
+	return &k, rows.Err()
+}
+
+func (k *SubjectKeys) unwrap(wrapped string) (cipher.AEAD, error) {
+	encoded, err := k.cipher.Decrypt(wrapped)
+	if err != nil {
+		return nil, err
+	}
+	key, err := decodeSegment(encoded)
+	if err != nil {
+		return nil, err
+	}
+
+	return newAEAD(key)
+}
+
+// keyOf returns the key sealing the values of the subject, a new one is created for the subjects without one
+func (k *SubjectKeys) keyOf(subject string) (int64, cipher.AEAD, error) {
+	k.mu.RLock()
+	id, ok := k.current[subject]
+	aead := k.keys[id]
+	k.mu.RUnlock()
+	if ok {
+		return id, aead, nil
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if id, ok := k.current[subject]; ok {
+		return id, k.keys[id], nil
+	}
+
+	key := make([]byte, dataKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return 0, nil, fmt.Errorf("failed to generate subject key: %w", err)
+	}
+	wrapped, err := k.cipher.Encrypt(encodeSegment(key))
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to wrap subject key: %w", err)
+	}
+	aead, err = newAEAD(key)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	res, err := k.db.Exec(`insert into subject_keys (subject, key, created_at) values (?, ?, ?);`, subject, wrapped, time.Now().UTC().Format(time.RFC3339))
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to insert subject key: %w", err)
+	}
+	if id, err = res.LastInsertId(); err != nil {
+		return 0, nil, err
+	}
+	k.keys[id], k.current[subject] = aead, id
+
+	return id, aead, nil
+}
+
+// Seal encrypts the personal value of the subject with its key, the empty and sealed values are returned as is
+func (k *SubjectKeys) Seal(subject, value string) (string, error) {
+	if value == "" || strings.HasPrefix(value, subjectSealedPrefix) {
+		return value, nil
+	}
+
+	id, aead, err := k.keyOf(subject)
+	if err != nil {
+		return "", err
+	}
+	sealed, err := seal(aead, []byte(value))
+	if err != nil {
+		return "", err
+	}
+
+	return subjectSealedPrefix + strconv.FormatInt(id, 10) + "." + encodeSegment(sealed), nil
+}
+
+// Open decrypts the sealed personal value, it's empty once the key sealing it is shredded. The values in plaintext are returned as is.
+func (k *SubjectKeys) Open(value string) (string, error) {
+	rest, ok := strings.CutPrefix(value, subjectSealedPrefix)
+	if !ok {
+		return value, nil
+	}
+
+	idSegment, sealed, ok := strings.Cut(rest, ".")
+	id, err := strconv.ParseInt(idSegment, 10, 64)
+	if !ok || err != nil {
+		return "", errors.New("malformed personal value")
+	}
+
+	k.mu.RLock()
+	aead, ok := k.keys[id]
+	k.mu.RUnlock()
+	if !ok {
+		return "", nil
+	}
+
+	b, err := decodeSegment(sealed)
+	if err != nil {
+		return "", fmt.Errorf("malformed personal value: %w", err)
+	}
+	plaintext, err := open(aead, b)
+	if err != nil {
+		return "", err
+	}
+
+	return string(plaintext), nil
+}
+
+// SealJSON encrypts the personal values of the JSON document with the key of its subject, the documents without subject are returned as is
+func (k *SubjectKeys) SealJSON(doc []byte, fields SubjectFields) ([]byte, error) {
+	var v interface{}
+	if err := json.Unmarshal(doc, &v); err != nil {
+		return nil, fmt.Errorf("failed to decode document: %w", err)
+	}
+	for _, key := range strings.Split(fields.Subject, ".") {
+		node, _ := v.(map[string]interface{})
+		v = node[key]
+	}
+	subject, _ := v.(string)
+	if subject == "" {
+		return doc, nil
+	}
+
+	return transformJSON(doc, fields.Paths, func(value string) (string, error) {
+		return k.Seal(subject, value)
+	})
+}
+
+// OpenJSON decrypts the personal values of the JSON document at the paths, see Open
+func (k *SubjectKeys) OpenJSON(doc []byte, paths []string) ([]byte, error) {
+	if !bytes.Contains(doc, []byte(subjectSealedPrefix)) {
+		return doc, nil
+	}
+
+	return transformJSON(doc, paths, k.Open)
+}
+
+// Shred deletes the keys of the subject, so the values they sealed can't be read anymore, and returns how many were deleted.
+// The next values of the subject are sealed with a new key.
+func (k *SubjectKeys) Shred(ctx context.Context, subject string) (int64, error) {
+	conn, err := k.db.Conn(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	// The deleted keys are overwritten in the database file too
+	if _, err := conn.ExecContext(ctx, `pragma secure_delete = on;`); err != nil {
+		return 0, fmt.Errorf("failed to enable secure delete: %w", err)
+	}
+	res, err := conn.ExecContext(ctx, `update subject_keys set key = null, shredded_at = ? where subject = ? and key is not null;`,
+		time.Now().UTC().Format(time.RFC3339), subject)
+	if err != nil {
+		return 0, fmt.Errorf("failed to shred subject keys: %w", err)
+	}
+
+	k.mu.Lock()
+	delete(k.keys, k.current[subject])
+	delete(k.current, subject)
+	k.mu.Unlock()
+
+	return res.RowsAffected()
+}
+
+// RewrapSubjectKeys wraps the subject keys wrapped under a previous master key of the cipher with its current one,
+// see RewrapPairKeys in the queries for the batches
+func RewrapSubjectKeys(ctx context.Context, tx *sql.Tx, c *Cipher, after string, limit int) (string, int, error) {
+	var id int64
+	if after != "" {
+		var err error
+		if id, err = strconv.ParseInt(after, 10, 64); err != nil {
+			return "", 0, fmt.Errorf("invalid subject keys position %q: %w", after, err)
+		}
+	}
+
+	rows, err := tx.QueryContext(ctx, `select id, key from subject_keys where id > ? and key is not null order by id limit ?;`, id, limit)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to query subject keys: %w", err)
+	}
+
+	type subjectKey struct {
+		id      int64
+		wrapped string
+	}
+	keys := make([]subjectKey, 0, limit)
+	for rows.Next() {
+		var key subjectKey
+		if err := rows.Scan(&key.id, &key.wrapped); err != nil {
+			rows.Close()
+			return "", 0, fmt.Errorf("failed to scan subject key: %w", err)
+		}
+		keys = append(keys, key)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return "", 0, err
+	}
+
+	last, rewrapped := "", 0
+	for _, key := range keys {
+		last = strconv.FormatInt(key.id, 10)
+		wrapped, err := c.Rewrap(key.wrapped)
+		if err != nil {
+			return "", 0, fmt.Errorf("failed to rewrap subject key %d: %w", key.id, err)
+		}
+		if wrapped == key.wrapped {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, `update subject_keys set key = ? where id = ?;`, wrapped, key.id); err != nil {
+			return "", 0, fmt.Errorf("failed to update subject key %d: %w", key.id, err)
+		}
+		rewrapped++
+	}
+
+	return last, rewrapped, nil
+}
@@ -1,15 +1,135 @@
 package common
 
 import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/co-defi/api-server/domain"
 	"github.com/go-playground/validator/v10"
 )
 
 var validate *validator.Validate
 
+var (
+	txHashRegexp  = regexp.MustCompile(`^(0x)?[0-9a-fA-F]{64}$`)
+	addressRegexp = regexp.MustCompile(`^(0x[0-9a-fA-F]{40}|[a-z]{1,83}1[02-9ac-hj-np-z]{6,})$`)
+)
+
 func init() {
 	validate = validator.New()
+	validate.RegisterTagNameFunc(jsonFieldName)
+	validate.RegisterValidation("asset", isSupportedAsset)
+	validate.RegisterValidation("tx_hash", matchesRegexp(txHashRegexp))
+	validate.RegisterValidation("address", matchesRegexp(addressRegexp))
+	validate.RegisterValidation("network", isSupportedNetwork)
+	validate.RegisterValidation("chain", isSupportedChain)
+	validate.RegisterValidation("timeout_status", isTimeoutStatus)
 }
 
 func Validate(i interface{}) error {
 	return validate.Struct(i)
 }
+
+// jsonFieldName reports the fields by their JSON names so validation errors match the request payloads
+func jsonFieldName(field reflect.StructField) string {
+	name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+	if name == "-" || name == "" {
+		return field.Name
+	}
+
+	return name
+}
+
+func isSupportedAsset(fl validator.FieldLevel) bool {
+	return domain.IsSupportedAsset(fl.Field().String())
+}
+
+func isSupportedNetwork(fl validator.FieldLevel) bool {
+	return domain.IsSupportedNetwork(domain.Network(fl.Field().String()))
+}
+
+func isSupportedChain(fl validator.FieldLevel) bool {
+	return domain.IsSupportedChain(fl.Field().String())
+}
+
+func isTimeoutStatus(fl validator.FieldLevel) bool {
+	return slices.Contains(domain.TimeoutStatuses, domain.PairStatus(fl.Field().String()))
+}
+
+func matchesRegexp(re *regexp.Regexp) validator.Func {
+	return func(fl validator.FieldLevel) bool {
+		return re.MatchString(fl.Field().String())
+	}
+}
+
+// validationMessage describes a failed validation rule in a human-readable form
+func validationMessage(err validator.FieldError) string {
+	switch err.ActualTag() {
+	case "required":
+		return "is required"
+	case "uuid4":
+		return "must be a valid uuid"
+	case "asset":
+		return "must be a supported asset identifier, see GET /assets"
+	case "tx_hash":
+		return "must be a hex encoded transaction hash"
+	case "address":
+		return "must be a valid address"
+	case "network":
+		return fmt.Sprintf("must be one of: %s %s", domain.NetworkMainnet, domain.NetworkTestnet)
+	case "number":
+		return "must be a non-negative integer"
+	case "oneof":
+		return fmt.Sprintf("must be one of: %s", err.Param())
+	case "len":
+		return fmt.Sprintf("must have a length of %s", err.Param())
+	case "min":
+		if unit := lengthUnit(err); unit != "" {
+			return fmt.Sprintf("must have at least %s %s", err.Param(), unit)
+		}
+		return fmt.Sprintf("must be at least %s", err.Param())
+	case "max":
+		if unit := lengthUnit(err); unit != "" {
+			return fmt.Sprintf("must have at most %s %s", err.Param(), unit)
+		}
+		return fmt.Sprintf("must be at most %s", err.Param())
+	case "timeout_status":
+		return fmt.Sprintf("must be one of: %s", timeoutStatuses())
+	case "chain":
+		return "must be a supported chain, e.g. THOR or BTC"
+	case "gt":
+		return fmt.Sprintf("must be greater than %s", err.Param())
+	case "gtfield":
+		return fmt.Sprintf("must be after %s", err.Param())
+	}
+
+	return fmt.Sprintf("failed on the %s rule", err.ActualTag())
+}
+
+func timeoutStatuses() string {
+	statuses := make([]string, len(domain.TimeoutStatuses))
+	for i, s := range domain.TimeoutStatuses {
+		statuses[i] = string(s)
+	}
+	return strings.Join(statuses, " ")
+}
+
+// lengthUnit returns what the min and max rules count on the field, nothing for the numbers they compare
+func lengthUnit(err validator.FieldError) string {
+	switch err.Kind() {
+	case reflect.String:
+		return "characters"
+	case reflect.Slice, reflect.Array:
+		if err.Type().Elem().Kind() == reflect.Uint8 {
+			return "bytes"
+		}
+		return "items"
+	case reflect.Map:
+		return "entries"
+	}
+
+	return ""
+}
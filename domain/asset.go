@@ -0,0 +1,185 @@
+package domain
+
+import (
+	"math/big"
+	"sort"
+	"strings"
+)
+
+// AssetInfo describes an asset supported by the platform using the THORChain notation CHAIN.TICKER[-CONTRACT]
+type AssetInfo struct {
+	Asset           Asset   `json:"asset"`
+	Chain           string  `json:"chain"`
+	Ticker          string  `json:"ticker"`
+	Decimals        int     `json:"decimals"`
+	ContractAddress Address `json:"contract_address,omitempty"`
+}
+
+// IsToken checks if the asset is a token issued by a contract rather than the native asset of its chain
+func (a AssetInfo) IsToken() bool {
+	return a.ContractAddress != EmptyAddress
+}
+
+// TokenAmount is an amount of an asset in its base units, e.g. wei, along with the decimals of the asset
+type TokenAmount struct {
+	Amount   string `json:"amount"`
+	Decimals int    `json:"decimals"`
+}
+
+// Float returns the amount in units of the asset, e.g. ETH rather than wei
+func (a TokenAmount) Float() float64 {
+	amount, ok := new(big.Float).SetString(a.Amount)
+	if !ok {
+		return 0
+	}
+
+	scale := new(big.Float).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(a.Decimals)), nil))
+	value, _ := amount.Quo(amount, scale).Float64()
+	return value
+}
+
+// Decimal returns the amount in units of the asset as an exact decimal, e.g. 1.5 for 1500000000000000000 wei.
+// Invalid amounts are returned as is.
+func (a TokenAmount) Decimal() string {
+	amount, ok := new(big.Int).SetString(a.Amount, 10)
+	if !ok || a.Decimals <= 0 {
+		return a.Amount
+	}
+
+	digits := new(big.Int).Abs(amount).String()
+	if len(digits) <= a.Decimals {
+		digits = strings.Repeat("0", a.Decimals-len(digits)+1) + digits
+	}
+	integer, fraction := digits[:len(digits)-a.Decimals], strings.TrimRight(digits[len(digits)-a.Decimals:], "0")
+
+	decimal := integer
+	if fraction != "" {
+		decimal += "." + fraction
+	}
+	if amount.Sign() < 0 {
+		decimal = "-" + decimal
+	}
+	return decimal
+}
+
+// RuneAsset is the native asset of THORChain which every pool is paired with
+const RuneAsset Asset = "THOR.RUNE"
+
+// assetRegistry holds all the assets that can be used in plans and pairs
+var assetRegistry = map[Asset]AssetInfo{
+	"THOR.RUNE": {
+		Asset:    "THOR.RUNE",
+		Chain:    "THOR",
+		Ticker:   "RUNE",
+		Decimals: 8,
+	},
+	"BTC.BTC": {
+		Asset:    "BTC.BTC",
+		Chain:    "BTC",
+		Ticker:   "BTC",
+		Decimals: 8,
+	},
+	"ETH.ETH": {
+		Asset:    "ETH.ETH",
+		Chain:    "ETH",
+		Ticker:   "ETH",
+		Decimals: 18,
+	},
+	"ETH.USDC-0XA0B86991C6218B36C1D19D4A2E9EB0CE3606EB48": {
+		Asset:           "ETH.USDC-0XA0B86991C6218B36C1D19D4A2E9EB0CE3606EB48",
+		Chain:           "ETH",
+		Ticker:          "USDC",
+		Decimals:        6,
+		ContractAddress: "0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48",
+	},
+	"ETH.USDT-0XDAC17F958D2EE523A2206206994597C13D831EC7": {
+		Asset:           "ETH.USDT-0XDAC17F958D2EE523A2206206994597C13D831EC7",
+		Chain:           "ETH",
+		Ticker:          "USDT",
+		Decimals:        6,
+		ContractAddress: "0xdAC17F958D2ee523a2206206994597C13D831ec7",
+	},
+}
+
+// LookupAsset returns the registered information of the asset
+func LookupAsset(asset Asset) (AssetInfo, bool) {
+	info, ok := assetRegistry[asset]
+	return info, ok
+}
+
+// LookupAssetByContract returns the registered token issued by the contract on the chain
+func LookupAssetByContract(chain string, contract Address) (AssetInfo, bool) {
+	for _, info := range assetRegistry {
+		if info.Chain == chain && info.IsToken() && strings.EqualFold(info.ContractAddress, contract) {
+			return info, true
+		}
+	}
+
+	return AssetInfo{}, false
+}
+
+// ChainOf returns the chain of the registered asset, the chain isn't guessed from the notation of unregistered assets
+func ChainOf(asset Asset) (string, bool) {
+	info, ok := assetRegistry[asset]
+	return info.Chain, ok
+}
+
+// IsAssetOnChain checks if the registered asset belongs to the chain. The chains are compared as a whole,
+// so an asset doesn't belong to a chain whose identifier merely prefixes its own.
+func IsAssetOnChain(asset Asset, chain string) bool {
+	assetChain, ok := ChainOf(asset)
+	return ok && assetChain == chain
+}
+
+// SaversVault returns the THORChain notation of the Savers vault of the asset, e.g. BTC/BTC for BTC.BTC
+func SaversVault(asset Asset) Asset {
+	return strings.Replace(asset, ".", "/", 1)
+}
+
+// IsMemoAsset checks if the asset written in a THORChain memo designates the asset. THORChain accepts the contract address
+// of a token to be shortened to its last characters, e.g. ETH.USDC-EB48 for ETH.USDC-0XA0B86991C6218B36C1D19D4A2E9EB0CE3606EB48.
+func IsMemoAsset(notation string, asset Asset) bool {
+	if strings.EqualFold(notation, asset) {
+		return true
+	}
+
+	info, ok := assetRegistry[asset]
+	if !ok || !info.IsToken() {
+		return false
+	}
+	prefix := info.Chain + "." + info.Ticker + "-"
+	if len(notation) <= len(prefix) || !strings.EqualFold(notation[:len(prefix)], prefix) {
+		return false
+	}
+
+	return strings.HasSuffix(strings.ToUpper(info.ContractAddress), strings.ToUpper(notation[len(prefix):]))
+}
+
+// IsSupportedAsset checks if the asset is registered
+func IsSupportedAsset(asset Asset) bool {
+	_, ok := assetRegistry[asset]
+	return ok
+}
+
+// IsSupportedChain checks if any registered asset belongs to the chain
+func IsSupportedChain(chain string) bool {
+	for _, info := range assetRegistry {
+		if info.Chain == chain {
+			return true
+		}
+	}
+	return false
+}
+
+// SupportedAssets returns all the registered assets ordered by their identifier
+func SupportedAssets() []AssetInfo {
+	assets := make([]AssetInfo, 0, len(assetRegistry))
+	for _, info := range assetRegistry {
+		assets = append(assets, info)
+	}
+	sort.Slice(assets, func(i, j int) bool {
+		return assets[i].Asset < assets[j].Asset
+	})
+
+	return assets
+}
@@ -0,0 +1,104 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/hallgren/eventsourcing"
+)
+
+// MediatorKeyEscrow is the aggregate root for the key share of the mediator of a pair kept in escrow, for the 2 of 3 wallets
+// whose mediator is gone. The share is only released under a recovery requested by an operator and approved by another.
+// The aggregate is identified by the id of its pair.
+type MediatorKeyEscrow struct {
+	eventsourcing.AggregateRoot
+	PairId     string                    `json:"pair_id,omitempty"`
+	Share      string                    `json:"share,omitempty"`
+	Recoveries map[string]*ShareRecovery `json:"recoveries,omitempty"`
+}
+
+// ShareRecoveryStatus is the progress of a recovery of the key share of a mediator
+type ShareRecoveryStatus string
+
+const (
+	ShareRecoveryStatusPending   ShareRecoveryStatus = "pending"
+	ShareRecoveryStatusApproved  ShareRecoveryStatus = "approved"
+	ShareRecoveryStatusRejected  ShareRecoveryStatus = "rejected"
+	ShareRecoveryStatusRetrieved ShareRecoveryStatus = "retrieved"
+)
+
+// ShareRecovery is a request of an operator to retrieve the key share of a mediator
+type ShareRecovery struct {
+	Status      ShareRecoveryStatus `json:"status,omitempty"`
+	RequestedBy string              `json:"requested_by,omitempty"`
+	DecidedBy   string              `json:"decided_by,omitempty"`
+	DecidedAt   time.Time           `json:"decided_at,omitempty"`
+}
+
+// Register implements aggregate.Register
+func (e *MediatorKeyEscrow) Register(r eventsourcing.RegisterFunc) {
+	r(
+		&MediatorShareEscrowed{},
+		&ShareRecoveryRequested{},
+		&ShareRecoveryApproved{},
+		&ShareRecoveryRejected{},
+		&MediatorShareRetrieved{},
+	)
+}
+
+// Transition implements aggregate.Transition
+func (e *MediatorKeyEscrow) Transition(event eventsourcing.Event) {
+	switch ev := event.Data().(type) {
+	case *MediatorShareEscrowed:
+		e.PairId = ev.PairId
+		e.Share = ev.Share
+		e.Recoveries = make(map[string]*ShareRecovery)
+	case *ShareRecoveryRequested:
+		e.Recoveries[ev.RecoveryId] = &ShareRecovery{Status: ShareRecoveryStatusPending, RequestedBy: ev.Operator}
+	case *ShareRecoveryApproved:
+		if r, ok := e.Recoveries[ev.RecoveryId]; ok {
+			r.Status, r.DecidedBy, r.DecidedAt = ShareRecoveryStatusApproved, ev.Operator, event.Timestamp()
+		}
+	case *ShareRecoveryRejected:
+		if r, ok := e.Recoveries[ev.RecoveryId]; ok {
+			r.Status, r.DecidedBy, r.DecidedAt = ShareRecoveryStatusRejected, ev.Operator, event.Timestamp()
+		}
+	case *MediatorShareRetrieved:
+		if r, ok := e.Recoveries[ev.RecoveryId]; ok {
+			r.Status = ShareRecoveryStatusRetrieved
+		}
+	}
+}
+
+// MediatorShareEscrowed is the event for an operator putting the key share of the mediator of a pair in escrow,
+// the share is encrypted at rest
+type MediatorShareEscrowed struct {
+	PairId   string `json:"pair_id,omitempty"`
+	Share    string `json:"share,omitempty"`
+	Operator string `json:"operator,omitempty"`
+}
+
+// ShareRecoveryRequested is the event for an operator requesting to retrieve the key share of the mediator of a pair
+type ShareRecoveryRequested struct {
+	RecoveryId string `json:"recovery_id,omitempty"`
+	Reason     string `json:"reason,omitempty"`
+	Operator   string `json:"operator,omitempty"`
+}
+
+// ShareRecoveryApproved is the event for another operator approving a recovery of the key share
+type ShareRecoveryApproved struct {
+	RecoveryId string `json:"recovery_id,omitempty"`
+	Operator   string `json:"operator,omitempty"`
+}
+
+// ShareRecoveryRejected is the event for another operator rejecting a recovery of the key share
+type ShareRecoveryRejected struct {
+	RecoveryId string `json:"recovery_id,omitempty"`
+	Reason     string `json:"reason,omitempty"`
+	Operator   string `json:"operator,omitempty"`
+}
+
+// MediatorShareRetrieved is the event for the operator of an approved recovery retrieving the key share, once
+type MediatorShareRetrieved struct {
+	RecoveryId string `json:"recovery_id,omitempty"`
+	Operator   string `json:"operator,omitempty"`
+}
@@ -0,0 +1,22 @@
+package domain
+
+// Network is the environment the plans and pairs live in, pairs are only matched within the network of their plan
+type Network string
+
+const (
+	NetworkMainnet Network = "mainnet"
+	NetworkTestnet Network = "testnet"
+)
+
+// IsSupportedNetwork checks if the network is known
+func IsSupportedNetwork(network Network) bool {
+	return network == NetworkMainnet || network == NetworkTestnet
+}
+
+// NetworkOrDefault returns the network, or mainnet for the plans and pairs created before networks were introduced
+func NetworkOrDefault(network Network) Network {
+	if network == "" {
+		return NetworkMainnet
+	}
+	return network
+}
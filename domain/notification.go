@@ -0,0 +1,56 @@
+package domain
+
+import (
+	"github.com/hallgren/eventsourcing"
+)
+
+// NotificationSettings is the aggregate root for the notification channels and preferences of a participant address.
+// The aggregate is identified by the address of the participant.
+type NotificationSettings struct {
+	eventsourcing.AggregateRoot
+	Address   Address                    `json:"address,omitempty"`
+	Email     string                     `json:"email,omitempty"`
+	PushToken string                     `json:"push_token,omitempty"`
+	Events    map[NotificationEvent]bool `json:"events,omitempty"`
+}
+
+// Register implements aggregate.Register
+func (s *NotificationSettings) Register(r eventsourcing.RegisterFunc) {
+	r(&NotificationSettingsUpdated{})
+}
+
+// Transition implements aggregate.Transition
+func (s *NotificationSettings) Transition(event eventsourcing.Event) {
+	switch e := event.Data().(type) {
+	case *NotificationSettingsUpdated:
+		s.Address = e.Address
+		s.Email = e.Email
+		s.PushToken = e.PushToken
+		s.Events = make(map[NotificationEvent]bool, len(e.Events))
+		for _, ne := range e.Events {
+			s.Events[ne] = true
+		}
+	}
+}
+
+// NotificationEvent is the type of pair progress a participant can be notified about
+type NotificationEvent string
+
+const (
+	NotificationEventMatched             NotificationEvent = "matched"
+	NotificationEventCounterpartyDeposit NotificationEvent = "counterparty_deposit"
+	NotificationEventDeadlineApproaching NotificationEvent = "deadline_approaching"
+	NotificationEventWithdrawalCompleted NotificationEvent = "withdrawal_completed"
+	NotificationEventTxFailed            NotificationEvent = "tx_failed"
+	NotificationEventMatchReverted       NotificationEvent = "match_reverted"
+	NotificationEventStatusOverdue       NotificationEvent = "status_overdue"
+	NotificationEventWalletRegenerated   NotificationEvent = "wallet_regenerated"
+)
+
+// NotificationSettingsUpdated is the event for registering or changing the notification channels and preferences.
+type NotificationSettingsUpdated struct {
+	Address   Address             `json:"address,omitempty"`
+	Email     string              `json:"email,omitempty"`
+	PushToken string              `json:"push_token,omitempty"`
+	Events    []NotificationEvent `json:"events,omitempty"`
+}
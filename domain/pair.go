@@ -1,6 +1,12 @@
 package domain
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
 	"time"
 
 	"github.com/hallgren/eventsourcing"
@@ -16,20 +22,70 @@ const EmptyAddress = ""
 type Pair struct {
 	eventsourcing.AggregateRoot
 	Status                PairStatus             `json:"status,omitempty"`
+	PlanId                string                 `json:"plan_id,omitempty"`
+	PlanType              PlanType               `json:"plan_type,omitempty"`
+	Network               Network                `json:"network,omitempty"`
 	Assets                []Asset                `json:"assets,omitempty"`
 	ParticipantsAddress   map[Asset]Address      `json:"participants_address,omitempty"`
 	ShareValue            int                    `json:"share_value,omitempty"`
 	InvestingPeriod       int                    `json:"investing_period,omitempty"`
+	InvestingPeriodUnit   PeriodUnit             `json:"investing_period_unit,omitempty"`
+	GracePeriodDays       int                    `json:"grace_period_days,omitempty"`
 	WalletSecurity        MultiSigWalletSecurity `json:"wallet_security,omitempty"`
 	ProfitSharingStrategy ProfitSharingStrategy  `json:"profit_sharing_strategy,omitempty"`
 	LossProtection        float64                `json:"loss_protection,omitempty"`
 	Wallet                *MultisigWallet        `json:"wallet,omitempty"`
-	Assurances            map[Asset][]SignedTx   `json:"assurances,omitempty"`
-	Deposits              map[Asset]TxHash       `json:"deposits,omitempty"`
-	WithdrawTx            *SignedTx              `json:"withdraw_tx,omitempty"`
-	LP                    map[Asset]TxHash       `json:"lp,omitempty"`
-	Deadline              time.Time              `json:"deadline,omitempty"`
-	WithdrawnTx           *TxHash                `json:"withdrawn_tx,omitempty"`
+	// MatchedAt is when the counterparty matched the pair, the counterparty must confirm the wallet within a timeout from it
+	MatchedAt  time.Time            `json:"matched_at,omitempty"`
+	Assurances map[Asset][]SignedTx `json:"assurances,omitempty"`
+	// AssuranceConfirmations holds the digests of the assurances acknowledged by the participant of each asset
+	AssuranceConfirmations map[Asset]string      `json:"assurance_confirmations,omitempty"`
+	Deposits               map[Asset]TxHash      `json:"deposits,omitempty"`
+	DepositAmounts         map[Asset]TokenAmount `json:"deposit_amounts,omitempty"`
+	WithdrawTx             *SignedTx             `json:"withdraw_tx,omitempty"`
+	LP                     map[Asset]TxHash      `json:"lp,omitempty"`
+	Deadline               time.Time             `json:"deadline,omitempty"`
+	WithdrawnTx            *TxHash               `json:"withdrawn_tx,omitempty"`
+	// Savers holds the positions of the assets of a savers pair in their Savers vaults, they replace the LP and the withdrawal
+	Savers map[Asset]*SaversPosition `json:"savers,omitempty"`
+	// DepositedAt holds the time each asset was deposited at, the refund timeout of a deposit starts from it
+	DepositedAt map[Asset]time.Time `json:"deposited_at,omitempty"`
+	Refund      *RefundIssued       `json:"refund,omitempty"`
+	// EarlyWithdrawal is the agreement of the participants to withdraw before the deadline
+	EarlyWithdrawal *EarlyWithdrawal `json:"early_withdrawal,omitempty"`
+	// PendingExtension is the proposal of a participant to extend the investing period, waiting for the counterparty
+	PendingExtension *ExtensionProposed `json:"pending_extension,omitempty"`
+	Settlement       *SettlementReport  `json:"settlement,omitempty"`
+	// Txs holds the status on chain of the deposit, LP, withdrawal and refund transactions by hash
+	Txs map[TxHash]TrackedTx `json:"txs,omitempty"`
+	// StatusChangedAt is when the pair moved to its current status, the timeout of the status starts from it
+	StatusChangedAt time.Time `json:"status_changed_at,omitempty"`
+	// Escalation is the last action taken on the pair for being overdue in its current status
+	Escalation OverdueAction `json:"escalation,omitempty"`
+}
+
+// EarlyWithdrawal is the proposal of a participant to withdraw before the deadline, agreed once the counterparty accepts it
+type EarlyWithdrawal struct {
+	ProposedBy Address   `json:"proposed_by"`
+	Reason     string    `json:"reason,omitempty"`
+	ProposedAt time.Time `json:"proposed_at"`
+	AcceptedBy Address   `json:"accepted_by,omitempty"`
+	AcceptedAt time.Time `json:"accepted_at,omitempty"`
+}
+
+// SaversPosition is the progress of an asset of a savers pair through its Savers vault
+type SaversPosition struct {
+	// WithdrawTx is the pre-signed transaction withdrawing the whole position of the wallet from the vault
+	WithdrawTx *SignedTx `json:"withdraw_tx,omitempty"`
+	// TxHash is the transaction depositing the asset from the wallet into the vault
+	TxHash TxHash `json:"tx_hash,omitempty"`
+	// WithdrawnTx is the transaction withdrawing the position from the vault
+	WithdrawnTx TxHash `json:"withdrawn_tx,omitempty"`
+}
+
+// Agreed checks if both participants agreed to withdraw early
+func (e EarlyWithdrawal) Agreed() bool {
+	return e.AcceptedBy != EmptyAddress
 }
 
 // Register implements aggregate.Register
@@ -40,10 +96,26 @@ func (p *Pair) Register(r eventsourcing.RegisterFunc) {
 		&PairMatched{},
 		&WalletAddressConfirmed{},
 		&AssetAssuranceSigned{},
+		&AssurancesConfirmed{},
+		&AssurancesInvalidated{},
 		&AssetDeposited{},
 		&WithdrawTxSigned{},
 		&LPDone{},
 		&Withdrawn{},
+		&PairStatusForced{},
+		&RefundIssued{},
+		&EarlyWithdrawalProposed{},
+		&EarlyWithdrawalAccepted{},
+		&ExtensionProposed{},
+		&ExtensionAccepted{},
+		&WithdrawalSettled{},
+		&TxStatusChanged{},
+		&MatchReverted{},
+		&KeygenFailed{},
+		&PairStatusOverdue{},
+		&SaversWithdrawTxSigned{},
+		&SaversDeposited{},
+		&SaversWithdrawn{},
 	)
 }
 
@@ -53,45 +125,103 @@ func (p *Pair) Transition(event eventsourcing.Event) {
 	case *PairCreated:
 		p.applyPairCreated(e)
 	case *PairStatusChanged:
-		p.applyPairStatusChanged(e)
+		p.applyPairStatusChanged(e, event.Timestamp())
 	case *PairMatched:
-		p.applyPairMatched(e)
+		p.applyPairMatched(e, event.Timestamp())
 	case *WalletAddressConfirmed:
 		p.applyWalletAddressConfirmed(e)
 	case *AssetAssuranceSigned:
 		p.applyAssetAssuranceSigned(e)
+	case *AssurancesConfirmed:
+		p.applyAssurancesConfirmed(e)
+	case *AssurancesInvalidated:
+		p.applyAssurancesInvalidated()
 	case *AssetDeposited:
-		p.applyAssetDeposited(e)
+		p.applyAssetDeposited(e, event.Timestamp())
 	case *WithdrawTxSigned:
 		p.applyWithdrawTxSigned(e)
 	case *LPDone:
-		p.applyLPDone(e)
+		p.applyLPDone(e, event.Timestamp())
 	case *Withdrawn:
-		p.applyWithdrawn(e)
+		p.applyWithdrawn(e, event.Timestamp())
+	case *PairStatusForced:
+		p.applyPairStatusForced(e, event.Timestamp())
+	case *RefundIssued:
+		p.applyRefundIssued(e, event.Timestamp())
+	case *EarlyWithdrawalProposed:
+		p.applyEarlyWithdrawalProposed(e, event.Timestamp())
+	case *EarlyWithdrawalAccepted:
+		p.applyEarlyWithdrawalAccepted(e, event.Timestamp())
+	case *ExtensionProposed:
+		p.applyExtensionProposed(e)
+	case *ExtensionAccepted:
+		p.applyExtensionAccepted(e)
+	case *WithdrawalSettled:
+		p.applyWithdrawalSettled(e)
+	case *TxStatusChanged:
+		p.applyTxStatusChanged(e, event.Timestamp())
+	case *MatchReverted:
+		p.applyMatchReverted()
+	case *KeygenFailed:
+		p.applyKeygenFailed(e)
+	case *PairStatusOverdue:
+		p.Escalation = e.Action
+	case *SaversWithdrawTxSigned:
+		p.saversPosition(e.Asset).WithdrawTx = &e.Tx
+	case *SaversDeposited:
+		p.applySaversDeposited(e, event.Timestamp())
+	case *SaversWithdrawn:
+		p.saversPosition(e.Asset).WithdrawnTx = e.TxHash
+		p.trackTx(e.TxHash, TxKindWithdrawal, e.Asset, event.Timestamp())
 	}
 }
 
 func (p *Pair) applyPairCreated(e *PairCreated) {
+	p.PlanId = e.PlanId
+	p.PlanType = PlanTypeOrDefault(e.PlanType)
 	p.Assets = []Asset{e.ParticipantAsset, e.SecondaryAsset}
 	p.ParticipantsAddress = map[Asset]Address{e.ParticipantAsset: e.ParticipantAddress}
 	p.ShareValue = e.ShareValue
 	p.InvestingPeriod = e.InvestingPeriod
+	p.InvestingPeriodUnit = PeriodUnitOrDefault(e.InvestingPeriodUnit)
+	p.GracePeriodDays = e.GracePeriodDays
 	p.WalletSecurity = e.WalletSecurity
 	p.ProfitSharingStrategy = e.ProfitSharingStrategy
 	p.LossProtection = e.LossProtection
+	p.Network = NetworkOrDefault(e.Network)
 }
 
-func (p *Pair) applyPairStatusChanged(e *PairStatusChanged) {
+func (p *Pair) applyPairStatusChanged(e *PairStatusChanged, at time.Time) {
 	p.Status = e.Status
+	p.StatusChangedAt = at
+	p.Escalation = ""
 }
 
-func (p *Pair) applyPairMatched(e *PairMatched) {
+func (p *Pair) applyPairMatched(e *PairMatched, at time.Time) {
 	p.Wallet = &MultisigWallet{
 		PublicKeys:    make(map[Asset]string),
 		EncryptionKey: e.WalletEncryptionKey,
 		HexChainCode:  e.WalletHexChainCode,
 	}
 	p.ParticipantsAddress[p.Assets[1]] = e.ParticipantAddress
+	p.MatchedAt = at
+}
+
+// applyMatchReverted drops the counterparty along with the wallet of the match, the next match gets new wallet secrets
+func (p *Pair) applyMatchReverted() {
+	delete(p.ParticipantsAddress, p.Assets[1])
+	p.Wallet = nil
+	p.MatchedAt = time.Time{}
+}
+
+// applyKeygenFailed replaces the wallet the participants failed to generate with one of fresh secrets
+func (p *Pair) applyKeygenFailed(e *KeygenFailed) {
+	p.Wallet = &MultisigWallet{
+		PublicKeys:     make(map[Asset]string),
+		EncryptionKey:  e.WalletEncryptionKey,
+		HexChainCode:   e.WalletHexChainCode,
+		KeygenFailures: e.Failures,
+	}
 }
 
 func (p *Pair) applyWalletAddressConfirmed(e *WalletAddressConfirmed) {
@@ -110,30 +240,129 @@ func (p *Pair) applyAssetAssuranceSigned(e *AssetAssuranceSigned) {
 	p.Assurances[e.Asset] = append(p.Assurances[e.Asset], e.Tx)
 }
 
-func (p *Pair) applyAssetDeposited(e *AssetDeposited) {
+func (p *Pair) applyAssurancesConfirmed(e *AssurancesConfirmed) {
+	if p.AssuranceConfirmations == nil {
+		p.AssuranceConfirmations = make(map[Asset]string)
+	}
+
+	p.AssuranceConfirmations[e.Asset] = e.Digest
+}
+
+// applyAssurancesInvalidated drops the assurances signed for the previous wallet along with its confirmations,
+// both participants confirm the regenerated wallet before signing the assurances again
+func (p *Pair) applyAssurancesInvalidated() {
+	p.Assurances = nil
+	p.AssuranceConfirmations = nil
+	p.Wallet.PublicKeys = make(map[Asset]string)
+	p.Wallet.Addresses = nil
+}
+
+func (p *Pair) applyAssetDeposited(e *AssetDeposited, at time.Time) {
 	if p.Deposits == nil {
 		p.Deposits = make(map[Asset]TxHash)
 	}
 
 	p.Deposits[e.Asset] = e.TxHash
+
+	if p.DepositedAt == nil {
+		p.DepositedAt = make(map[Asset]time.Time)
+	}
+	p.DepositedAt[e.Asset] = at
+	p.trackTx(e.TxHash, TxKindDeposit, e.Asset, at)
+
+	// Deposits made before the amounts were tracked only have a hash
+	if e.Amount != "" {
+		if p.DepositAmounts == nil {
+			p.DepositAmounts = make(map[Asset]TokenAmount)
+		}
+		p.DepositAmounts[e.Asset] = TokenAmount{Amount: e.Amount, Decimals: e.Decimals}
+	}
 }
 
 func (p *Pair) applyWithdrawTxSigned(e *WithdrawTxSigned) {
 	p.WithdrawTx = &e.Tx
 }
 
-func (p *Pair) applyLPDone(e *LPDone) {
+func (p *Pair) applyLPDone(e *LPDone, at time.Time) {
 	if p.LP == nil {
 		p.LP = make(map[Asset]TxHash)
 	}
 
 	p.LP[e.Asset] = e.TxHash
+	p.trackTx(e.TxHash, TxKindLP, e.Asset, at)
 
-	p.Deadline = e.Deadline
+	// Only the later LP of the pair sets the deadline, except for the pairs created before that which set it on both
+	if !e.Deadline.IsZero() {
+		p.Deadline = e.Deadline
+	}
+}
+
+// saversPosition returns the position of the asset in its Savers vault, it's created on the first event of the asset
+func (p *Pair) saversPosition(asset Asset) *SaversPosition {
+	if p.Savers == nil {
+		p.Savers = make(map[Asset]*SaversPosition)
+	}
+	if p.Savers[asset] == nil {
+		p.Savers[asset] = &SaversPosition{}
+	}
+	return p.Savers[asset]
 }
 
-func (p *Pair) applyWithdrawn(e *Withdrawn) {
+func (p *Pair) applySaversDeposited(e *SaversDeposited, at time.Time) {
+	p.saversPosition(e.Asset).TxHash = e.TxHash
+	p.trackTx(e.TxHash, TxKindSavers, e.Asset, at)
+
+	// Only the later deposit into the vaults sets the deadline
+	if !e.Deadline.IsZero() {
+		p.Deadline = e.Deadline
+	}
+}
+
+func (p *Pair) applyWithdrawn(e *Withdrawn, at time.Time) {
 	p.WithdrawnTx = &e.TxHash
+	// The liquidity is always withdrawn with a THORChain transaction
+	p.trackTx(e.TxHash, TxKindWithdrawal, RuneAsset, at)
+}
+
+func (p *Pair) applyPairStatusForced(e *PairStatusForced, at time.Time) {
+	p.Status = e.Status
+	p.StatusChangedAt = at
+	p.Escalation = ""
+}
+
+func (p *Pair) applyRefundIssued(e *RefundIssued, at time.Time) {
+	p.Refund = e
+	p.trackTx(e.TxHash, TxKindRefund, e.Asset, at)
+}
+
+func (p *Pair) applyEarlyWithdrawalProposed(e *EarlyWithdrawalProposed, at time.Time) {
+	p.EarlyWithdrawal = &EarlyWithdrawal{
+		ProposedBy: e.ParticipantAddress,
+		Reason:     e.Reason,
+		ProposedAt: at,
+	}
+}
+
+func (p *Pair) applyEarlyWithdrawalAccepted(e *EarlyWithdrawalAccepted, at time.Time) {
+	p.EarlyWithdrawal.AcceptedBy = e.ParticipantAddress
+	p.EarlyWithdrawal.AcceptedAt = at
+}
+
+func (p *Pair) applyExtensionProposed(e *ExtensionProposed) {
+	p.PendingExtension = e
+}
+
+func (p *Pair) applyWithdrawalSettled(e *WithdrawalSettled) {
+	p.Settlement = &e.Report
+}
+
+func (p *Pair) applyExtensionAccepted(e *ExtensionAccepted) {
+	p.Deadline = e.Deadline
+	if e.Rollover {
+		p.InvestingPeriod = e.InvestingPeriod
+		p.InvestingPeriodUnit = e.InvestingPeriodUnit
+	}
+	p.PendingExtension = nil
 }
 
 // HasAsset checks if the pair has the asset
@@ -175,18 +404,113 @@ func (p Pair) HasAssurancesForAsset(asset Asset) bool {
 	return ok
 }
 
+// HasConfirmedAssurancesForAsset checks if the participant of the asset has acknowledged the assurances refunding it
+func (p Pair) HasConfirmedAssurancesForAsset(asset Asset) bool {
+	_, ok := p.AssuranceConfirmations[asset]
+	return ok
+}
+
 // HasDepositForAsset checks if the pair has deposits for the asset
 func (p Pair) HasDepositForAsset(asset Asset) bool {
 	_, ok := p.Deposits[asset]
 	return ok
 }
 
+// DeadlineAfter returns the end of the investing period of the pair starting at the given time
+func (p Pair) DeadlineAfter(start time.Time) time.Time {
+	return p.InvestingPeriodUnit.AddTo(start, p.InvestingPeriod)
+}
+
+// GraceDeadline returns the time the participants have to withdraw by, i.e. the deadline extended by the grace period
+func (p Pair) GraceDeadline() time.Time {
+	if p.Deadline.IsZero() {
+		return time.Time{}
+	}
+	return p.Deadline.AddDate(0, 0, p.GracePeriodDays)
+}
+
+// IsWithdrawalUnlocked checks if the liquidity of the pair can be withdrawn at the given time,
+// i.e. the deadline has passed or both participants agreed to withdraw early. The liquidity is locked until
+// the deadline is set by the last LP, unless both participants agreed to withdraw early.
+func (p Pair) IsWithdrawalUnlocked(at time.Time) bool {
+	if !p.Deadline.IsZero() && !at.Before(p.Deadline) {
+		return true
+	}
+
+	return p.EarlyWithdrawal != nil && p.EarlyWithdrawal.Agreed()
+}
+
+// ExtendedDeadline returns the deadline of the pair once the proposed extension is accepted at the given time.
+// An extension pushes the current deadline out, a rollover starts a new investing period from the current deadline,
+// or from the given time when the deadline already passed.
+func (p Pair) ExtendedDeadline(proposal ExtensionProposed, at time.Time) time.Time {
+	if !proposal.Rollover {
+		return proposal.InvestingPeriodUnit.AddTo(p.Deadline, proposal.InvestingPeriod)
+	}
+
+	start := p.Deadline
+	if at.After(start) {
+		start = at
+	}
+	return proposal.InvestingPeriodUnit.AddTo(start, proposal.InvestingPeriod)
+}
+
 // HasLPForAsset checks if the pair has liquidity providing for the asset
 func (p Pair) HasLPForAsset(asset Asset) bool {
 	_, ok := p.LP[asset]
 	return ok
 }
 
+// IsSavers checks if the pair deposits its assets into the Savers vaults rather than providing liquidity
+func (p Pair) IsSavers() bool {
+	return p.PlanType == PlanTypeSavers
+}
+
+// IsInvested checks if the assets of the pair are put to work on THORChain, either in the pool or in the Savers vaults
+func (p Pair) IsInvested() bool {
+	return p.Status == PairStatusLP || p.Status == PairStatusSavers
+}
+
+// HasSaversWithdrawTxForAsset checks if the transaction withdrawing the asset from its Savers vault is pre-signed
+func (p Pair) HasSaversWithdrawTxForAsset(asset Asset) bool {
+	position, ok := p.Savers[asset]
+	return ok && position.WithdrawTx != nil
+}
+
+// HasSaversForAsset checks if the asset is deposited into its Savers vault
+func (p Pair) HasSaversForAsset(asset Asset) bool {
+	position, ok := p.Savers[asset]
+	return ok && position.TxHash != ""
+}
+
+// HasSaversWithdrawnForAsset checks if the asset is withdrawn from its Savers vault
+func (p Pair) HasSaversWithdrawnForAsset(asset Asset) bool {
+	position, ok := p.Savers[asset]
+	return ok && position.WithdrawnTx != ""
+}
+
+// countSavers counts the assets whose position satisfies the predicate
+func (p Pair) countSavers(has func(Asset) bool) int {
+	n := 0
+	for _, asset := range p.Assets {
+		if has(asset) {
+			n++
+		}
+	}
+	return n
+}
+
+// Pool returns the THORChain pool the pair provides liquidity to, which is named after its non-RUNE asset
+func (p Pair) Pool() Asset {
+	for _, a := range p.Assets {
+		if a != RuneAsset {
+			return a
+		}
+	}
+
+	return ""
+}
+
 // PairStatus is the type for the status of the pair
 type PairStatus string
 
@@ -197,10 +521,211 @@ const (
 	PairStatusDeposit            PairStatus = "deposit"
 	PairStatusPreSignWithdrawal  PairStatus = "pre_sign_withdrawal"
 	PairStatusLP                 PairStatus = "lp"
-	PairStatusWithdrawn          PairStatus = "withdrawn"
-	PairStatusInvalid            PairStatus = "invalid"
+	// PairStatusPreSignSaversWithdrawal and PairStatusSavers replace PairStatusPreSignWithdrawal and PairStatusLP for the savers pairs
+	PairStatusPreSignSaversWithdrawal PairStatus = "pre_sign_savers_withdrawal"
+	PairStatusSavers                  PairStatus = "savers"
+	PairStatusWithdrawn               PairStatus = "withdrawn"
+	PairStatusInvalid                 PairStatus = "invalid"
+	PairStatusRefunded                PairStatus = "refunded"
+)
+
+var (
+	// ErrIllegalTransition is returned when the pair is moved to a status that isn't reachable from its current status
+	ErrIllegalTransition = errors.New("illegal pair status transition")
+	// ErrTransitionPrecondition is returned when the pair doesn't satisfy the preconditions of moving to a status
+	ErrTransitionPrecondition = errors.New("pair status transition precondition is not met")
 )
 
+// pairGuard checks the preconditions of a status transition and explains the unmet one
+type pairGuard func(p Pair) string
+
+// pairTransitions is the state machine of the pair. It maps every status to the statuses reachable from it
+// along with the guards that must pass before the move. The empty status is the state of a pair that isn't created yet.
+var pairTransitions = map[PairStatus]map[PairStatus]pairGuard{
+	"": {
+		PairStatusWaiting: requireCreated,
+	},
+	PairStatusWaiting: {
+		PairStatusWalletConformation: requireMatched,
+		PairStatusInvalid:            nil,
+	},
+	PairStatusWalletConformation: {
+		PairStatusAssurance: requireConfirmedWallet,
+		PairStatusWaiting:   requireMatchReverted,
+		PairStatusInvalid:   nil,
+	},
+	PairStatusAssurance: {
+		PairStatusDeposit:            requireAllAssurances,
+		PairStatusWalletConformation: requireAssurancesInvalidated,
+		PairStatusInvalid:            nil,
+	},
+	PairStatusDeposit: {
+		PairStatusPreSignWithdrawal:       requireLPDeposits,
+		PairStatusPreSignSaversWithdrawal: requireSaversDeposits,
+		PairStatusRefunded:                requireRefundIssued,
+		PairStatusInvalid:                 nil,
+	},
+	PairStatusPreSignWithdrawal: {
+		PairStatusLP:      requireWithdrawTx,
+		PairStatusInvalid: nil,
+	},
+	PairStatusLP: {
+		PairStatusWithdrawn: requireWithdrawnTx,
+		PairStatusInvalid:   nil,
+	},
+	PairStatusPreSignSaversWithdrawal: {
+		PairStatusSavers:  requireSaversWithdrawTxs,
+		PairStatusInvalid: nil,
+	},
+	PairStatusSavers: {
+		PairStatusWithdrawn: requireSaversWithdrawn,
+		PairStatusInvalid:   nil,
+	},
+}
+
+// CanTransitionTo checks if the pair is allowed to move from its current status to the status
+func (p Pair) CanTransitionTo(status PairStatus) error {
+	guard, ok := pairTransitions[p.Status][status]
+	if !ok {
+		return fmt.Errorf("%w from %q to %q", ErrIllegalTransition, p.Status, status)
+	}
+
+	if guard != nil {
+		if unmet := guard(p); unmet != "" {
+			return fmt.Errorf("%w for %q: %s", ErrTransitionPrecondition, status, unmet)
+		}
+	}
+
+	return nil
+}
+
+// ChangeStatus moves the pair to the status, it's the only way the status of a pair should be changed
+func (p *Pair) ChangeStatus(status PairStatus) error {
+	if err := p.CanTransitionTo(status); err != nil {
+		return err
+	}
+
+	p.TrackChange(p, &PairStatusChanged{Status: status})
+	return nil
+}
+
+// ForceStatus moves the pair to the status bypassing the state machine. It's reserved to the operators
+// for unsticking the pairs whose real-world state diverged, so the operator and the reason are recorded along.
+func (p *Pair) ForceStatus(status PairStatus, operator, reason string) error {
+	if p.Status == "" || status == "" || status == p.Status {
+		return fmt.Errorf("%w from %q to %q", ErrIllegalTransition, p.Status, status)
+	}
+
+	p.TrackChange(p, &PairStatusForced{
+		From:     p.Status,
+		Status:   status,
+		Operator: operator,
+		Reason:   reason,
+	})
+	return nil
+}
+
+func requireCreated(p Pair) string {
+	if len(p.Assets) != 2 || len(p.ParticipantsAddress) != 1 {
+		return "pair must be created by a participant"
+	}
+	return ""
+}
+
+func requireMatched(p Pair) string {
+	if len(p.ParticipantsAddress) != 2 || p.Wallet == nil {
+		return "pair must be matched with a counterparty"
+	}
+	return ""
+}
+
+func requireMatchReverted(p Pair) string {
+	if len(p.ParticipantsAddress) != 1 || p.Wallet != nil {
+		return "match with the counterparty must be reverted"
+	}
+	return ""
+}
+
+func requireConfirmedWallet(p Pair) string {
+	if len(p.Wallet.PublicKeys) != 2 || len(p.Wallet.Addresses) != 2 {
+		return "both participants must confirm the wallet"
+	}
+	return ""
+}
+
+func requireAllAssurances(p Pair) string {
+	if len(p.Assurances) != 2 {
+		return "assurances of both assets must be set"
+	}
+	if len(p.AssuranceConfirmations) != 2 {
+		return "both participants must confirm the assurances of their assets"
+	}
+	return ""
+}
+
+func requireAssurancesInvalidated(p Pair) string {
+	if len(p.Assurances) != 0 || len(p.AssuranceConfirmations) != 0 || len(p.Wallet.PublicKeys) != 0 {
+		return "assurances of the previous wallet must be invalidated"
+	}
+	return ""
+}
+
+func requireAllDeposits(p Pair) string {
+	if len(p.Deposits) != 2 {
+		return "both assets must be deposited"
+	}
+	return ""
+}
+
+func requireLPDeposits(p Pair) string {
+	if p.IsSavers() {
+		return "savers pairs don't provide liquidity"
+	}
+	return requireAllDeposits(p)
+}
+
+func requireSaversDeposits(p Pair) string {
+	if !p.IsSavers() {
+		return "pair must belong to a savers plan"
+	}
+	return requireAllDeposits(p)
+}
+
+func requireSaversWithdrawTxs(p Pair) string {
+	if p.countSavers(p.HasSaversWithdrawTxForAsset) != len(p.Assets) {
+		return "withdrawal transactions of both assets must be signed"
+	}
+	return ""
+}
+
+func requireSaversWithdrawn(p Pair) string {
+	if p.countSavers(p.HasSaversWithdrawnForAsset) != len(p.Assets) {
+		return "both assets must be withdrawn from their vaults"
+	}
+	return ""
+}
+
+func requireRefundIssued(p Pair) string {
+	if p.Refund == nil {
+		return "refund must be issued to the depositor"
+	}
+	return ""
+}
+
+func requireWithdrawTx(p Pair) string {
+	if p.WithdrawTx == nil {
+		return "withdrawal transaction must be signed"
+	}
+	return ""
+}
+
+func requireWithdrawnTx(p Pair) string {
+	if p.WithdrawnTx == nil {
+		return "withdrawal transaction must be submitted"
+	}
+	return ""
+}
+
 // Asset is the type for the assets in the pair
 type Asset = string
 
@@ -213,6 +738,8 @@ type MultisigWallet struct {
 	Addresses     map[Asset]Address `json:"addresses,omitempty"`
 	EncryptionKey string            `json:"encryption_key,omitempty"`
 	HexChainCode  string            `json:"hex_chain_code,omitempty"`
+	// KeygenFailures counts the wallets of the match the participants failed to generate before this one
+	KeygenFailures int `json:"keygen_failures,omitempty"`
 }
 
 func (w *MultisigWallet) AreAddressesEqual(addresses map[Asset]Address) bool {
@@ -225,11 +752,46 @@ func (w *MultisigWallet) AreAddressesEqual(addresses map[Asset]Address) bool {
 	return true
 }
 
-// SignedTx is the type for the transactions that are signed by the participants
+// SignatureScheme is the scheme the signature of a transaction is made with
+type SignatureScheme string
+
+const (
+	SignatureSchemeECDSASecp256k1 SignatureScheme = "ecdsa_secp256k1"
+	SignatureSchemeEdDSAEd25519   SignatureScheme = "eddsa_ed25519"
+)
+
+// SignedTx is the type for the transactions that are signed by the participants.
+// The signature is checked to be made with the key of the pair's wallet over the hash the chain signs the transaction by,
+// computed by the server from the transaction. The chain, the scheme and the payload hash must match the ones of the chain.
 type SignedTx struct {
-	Nonce     int    `json:"nonce"`
-	Tx        []byte `json:"tx"`
-	Signature []byte `json:"signature"`
+	Nonce           int             `json:"nonce" validate:"min=0"`
+	Tx              []byte          `json:"tx" validate:"required,max=65536"`
+	Signature       []byte          `json:"signature" validate:"required,max=512"`
+	Chain           string          `json:"chain,omitempty" validate:"required,chain"`
+	SignatureScheme SignatureScheme `json:"signature_scheme,omitempty" validate:"required"`
+	PayloadHash     []byte          `json:"payload_hash,omitempty" validate:"required,max=64"`
+}
+
+// AssurancesDigest returns the hex encoded SHA-256 of the assurances ordered by nonce, so both parties compute the same digest
+func AssurancesDigest(assurances []SignedTx) string {
+	ordered := make([]SignedTx, len(assurances))
+	copy(ordered, assurances)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return ordered[i].Nonce < ordered[j].Nonce
+	})
+
+	b, err := json.Marshal(ordered)
+	if err != nil {
+		panic(err)
+	}
+	sum := sha256.Sum256(b)
+
+	return hex.EncodeToString(sum[:])
+}
+
+// AssurancesAcknowledgement returns the message the participant of the asset signs to acknowledge the assurances refunding it
+func AssurancesAcknowledgement(pairId string, asset Asset, assurances []SignedTx) string {
+	return fmt.Sprintf("Co-DeFi assurances acknowledgement\nPair: %s\nAsset: %s\nDigest: %s", pairId, asset, AssurancesDigest(assurances))
 }
 
 // TxHash is the type for the transaction hash
@@ -237,14 +799,19 @@ type TxHash = string
 
 // PairCreated is the event for creating a new pair for the first time.
 type PairCreated struct {
+	PlanId                string                 `json:"plan_id,omitempty"`
+	PlanType              PlanType               `json:"plan_type,omitempty"`
 	ParticipantAsset      Asset                  `json:"participant_asset,omitempty"`
 	ParticipantAddress    Address                `json:"participant_address,omitempty"`
 	SecondaryAsset        Asset                  `json:"secondary_asset,omitempty"`
 	ShareValue            int                    `json:"share_value,omitempty"`
 	InvestingPeriod       int                    `json:"investing_period,omitempty"`
+	InvestingPeriodUnit   PeriodUnit             `json:"investing_period_unit,omitempty"`
+	GracePeriodDays       int                    `json:"grace_period_days,omitempty"`
 	WalletSecurity        MultiSigWalletSecurity `json:"wallet_security,omitempty"`
 	ProfitSharingStrategy ProfitSharingStrategy  `json:"profit_sharing_strategy,omitempty"`
 	LossProtection        float64                `json:"loss_protection,omitempty"`
+	Network               Network                `json:"network,omitempty"`
 }
 
 // PairStatusChanged is the event for changing the status of the pair.
@@ -252,6 +819,14 @@ type PairStatusChanged struct {
 	Status PairStatus `json:"status,omitempty"`
 }
 
+// PairStatusForced is the event for an operator overriding the status of the pair outside its state machine.
+type PairStatusForced struct {
+	From     PairStatus `json:"from,omitempty"`
+	Status   PairStatus `json:"status,omitempty"`
+	Operator string     `json:"operator,omitempty"`
+	Reason   string     `json:"reason,omitempty"`
+}
+
 // PairMatched is the event for matching a pair with another participant.
 type PairMatched struct {
 	ParticipantAddress  Address `json:"participant_address,omitempty"`
@@ -259,6 +834,65 @@ type PairMatched struct {
 	WalletHexChainCode  string  `json:"wallet_hex_chain_code,omitempty"`
 }
 
+// MatchReverted is the event for dropping the counterparty of the match, the creator of the pair goes back to waiting
+// for another counterparty.
+type MatchReverted struct {
+	ParticipantAddress Address          `json:"participant_address,omitempty"`
+	Reason             string           `json:"reason,omitempty"`
+	Cause              MatchRevertCause `json:"cause,omitempty"`
+}
+
+// MatchRevertCause tells why the match was reverted
+type MatchRevertCause string
+
+const (
+	// MatchRevertCauseUnconfirmed is the counterparty not confirming the wallet in time, it's also the cause of the
+	// matches reverted before the causes were recorded
+	MatchRevertCauseUnconfirmed MatchRevertCause = "unconfirmed"
+	// MatchRevertCauseKeygen is the participants failing to generate the wallet too many times, a joint failure
+	// neither participant is held responsible for
+	MatchRevertCauseKeygen MatchRevertCause = "keygen"
+)
+
+// CauseOrDefault returns the cause of the reverted match, the matches reverted before the causes were recorded weren't confirmed
+func (e MatchReverted) CauseOrDefault() MatchRevertCause {
+	if e.Cause == "" {
+		return MatchRevertCauseUnconfirmed
+	}
+	return e.Cause
+}
+
+// KeygenFailed is the event for a participant reporting the participants failed to generate the wallet of the match,
+// the wallet is regenerated with fresh secrets. Failures counts the failed wallets of the match, this one included.
+type KeygenFailed struct {
+	ParticipantAsset    Asset  `json:"participant,omitempty"`
+	Reason              string `json:"reason,omitempty"`
+	Failures            int    `json:"failures,omitempty"`
+	WalletEncryptionKey string `json:"wallet_encryption_key,omitempty"`
+	WalletHexChainCode  string `json:"wallet_hex_chain_code,omitempty"`
+}
+
+// OverdueAction is the escalation taken on a pair that stayed in its status longer than the timeout of its plan
+type OverdueAction string
+
+const (
+	// OverdueActionReminded is the first escalation, the participants are reminded to move the pair forward
+	OverdueActionReminded OverdueAction = "reminded"
+	// OverdueActionReverted puts the pair back to waiting when its counterparty never confirmed the wallet
+	OverdueActionReverted OverdueAction = "reverted"
+	// OverdueActionExpired invalidates the pair, it's only taken before any asset is deposited
+	OverdueActionExpired OverdueAction = "expired"
+	// OverdueActionEscalated hands the pair over to the operators, the assets deposited can't be left behind automatically
+	OverdueActionEscalated OverdueAction = "escalated"
+)
+
+// PairStatusOverdue is the event for escalating a pair that stayed in its status longer than the timeout of its plan.
+type PairStatusOverdue struct {
+	Status  PairStatus    `json:"status,omitempty"`
+	Action  OverdueAction `json:"action,omitempty"`
+	Timeout Duration      `json:"timeout,omitempty"`
+}
+
 // WalletAddressConfirmed is the event for confirming the shared wallet's addresses by the participants.
 type WalletAddressConfirmed struct {
 	ParticipantAsset Asset             `json:"participant,omitempty"`
@@ -272,10 +906,29 @@ type AssetAssuranceSigned struct {
 	Tx    SignedTx `json:"tx,omitempty"`
 }
 
+// AssurancesConfirmed is the event for the participant of the asset acknowledging the assurances refunding it.
+type AssurancesConfirmed struct {
+	Asset     Asset  `json:"asset,omitempty"`
+	Digest    string `json:"digest,omitempty"`
+	Signature []byte `json:"signature,omitempty"`
+}
+
+// AssurancesInvalidated is the event for a participant confirming a wallet generated again, e.g. after a failed keygen.
+// The assurances signed for the previous wallet and the wallet confirmations are dropped, so both participants confirm
+// the new wallet and sign their assurances again.
+type AssurancesInvalidated struct {
+	ParticipantAsset Asset  `json:"participant,omitempty"`
+	Reason           string `json:"reason,omitempty"`
+}
+
 // AssetDeposited is the event for signing the transfer transaction for the asset.
+// Unverified is set when the transaction was accepted without being verified, as its chain couldn't be reached.
 type AssetDeposited struct {
-	Asset  Asset  `json:"asset,omitempty"`
-	TxHash TxHash `json:"tx_hash,omitempty"`
+	Asset      Asset  `json:"asset,omitempty"`
+	TxHash     TxHash `json:"tx_hash,omitempty"`
+	Amount     string `json:"amount,omitempty"`
+	Decimals   int    `json:"decimals,omitempty"`
+	Unverified bool   `json:"unverified,omitempty"`
 }
 
 // WithdrawTxSigned is the event for signing the withdrawal transaction.
@@ -284,13 +937,79 @@ type WithdrawTxSigned struct {
 }
 
 // LPDone is the event for when the liquidity providing is done.
+// The deadline is only set by the later LP of the pair, Unverified as AssetDeposited's.
 type LPDone struct {
-	Asset    Asset     `json:"asset,omitempty"`
-	TxHash   TxHash    `json:"tx_hash,omitempty"`
-	Deadline time.Time `json:"deadline,omitempty"`
+	Asset      Asset     `json:"asset,omitempty"`
+	TxHash     TxHash    `json:"tx_hash,omitempty"`
+	Deadline   time.Time `json:"deadline,omitempty"`
+	Unverified bool      `json:"unverified,omitempty"`
+}
+
+// RefundIssued is the event for releasing the assurances refunding the participant who deposited the asset
+// when the counterparty never deposited theirs. TxHash is set when the server broadcasted the refund itself.
+type RefundIssued struct {
+	Asset              Asset      `json:"asset,omitempty"`
+	ParticipantAddress Address    `json:"participant_address,omitempty"`
+	Assurances         []SignedTx `json:"assurances,omitempty"`
+	TxHash             TxHash     `json:"tx_hash,omitempty"`
+}
+
+// EarlyWithdrawalProposed is the event for a participant proposing to withdraw before the deadline.
+type EarlyWithdrawalProposed struct {
+	ParticipantAddress Address `json:"participant_address,omitempty"`
+	Reason             string  `json:"reason,omitempty"`
+}
+
+// EarlyWithdrawalAccepted is the event for the counterparty accepting to withdraw before the deadline.
+type EarlyWithdrawalAccepted struct {
+	ParticipantAddress Address `json:"participant_address,omitempty"`
+}
+
+// ExtensionProposed is the event for a participant proposing to extend the investing period of the pair.
+// With Rollover the pair starts a new investing period of that length instead of adding it to the deadline.
+type ExtensionProposed struct {
+	ParticipantAddress  Address    `json:"participant_address,omitempty"`
+	InvestingPeriod     int        `json:"investing_period,omitempty"`
+	InvestingPeriodUnit PeriodUnit `json:"investing_period_unit,omitempty"`
+	Rollover            bool       `json:"rollover,omitempty"`
+}
+
+// ExtensionAccepted is the event for the counterparty accepting the extension, it carries the resulting deadline.
+type ExtensionAccepted struct {
+	ParticipantAddress  Address    `json:"participant_address,omitempty"`
+	Deadline            time.Time  `json:"deadline,omitempty"`
+	Rollover            bool       `json:"rollover,omitempty"`
+	InvestingPeriod     int        `json:"investing_period,omitempty"`
+	InvestingPeriodUnit PeriodUnit `json:"investing_period_unit,omitempty"`
+}
+
+// WithdrawalSettled is the event for confirming the withdrawal on chain and reporting the results of the pair.
+type WithdrawalSettled struct {
+	Report SettlementReport `json:"report"`
 }
 
 // Withdrawn is the event for when the withdrawal is done.
 type Withdrawn struct {
 	TxHash TxHash `json:"tx_hash,omitempty"`
 }
+
+// SaversWithdrawTxSigned is the event for pre-signing the transaction withdrawing the asset from its Savers vault.
+type SaversWithdrawTxSigned struct {
+	Asset Asset    `json:"asset,omitempty"`
+	Tx    SignedTx `json:"tx,omitempty"`
+}
+
+// SaversDeposited is the event for depositing the asset from the pair's wallet into its Savers vault.
+// The deadline is only set by the later deposit of the pair, Unverified as AssetDeposited's.
+type SaversDeposited struct {
+	Asset      Asset     `json:"asset,omitempty"`
+	TxHash     TxHash    `json:"tx_hash,omitempty"`
+	Deadline   time.Time `json:"deadline,omitempty"`
+	Unverified bool      `json:"unverified,omitempty"`
+}
+
+// SaversWithdrawn is the event for withdrawing the asset from its Savers vault.
+type SaversWithdrawn struct {
+	Asset  Asset  `json:"asset,omitempty"`
+	TxHash TxHash `json:"tx_hash,omitempty"`
+}
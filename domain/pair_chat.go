@@ -0,0 +1,53 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/hallgren/eventsourcing"
+)
+
+// PairChat is the aggregate root for the messages the participants of a pair exchange to coordinate, e.g. when to deposit.
+// The aggregate is identified by the id of its pair, it's kept apart from the pair so the messages don't weigh on loading it.
+type PairChat struct {
+	eventsourcing.AggregateRoot
+	PairId   string `json:"pair_id,omitempty"`
+	Messages int    `json:"messages,omitempty"`
+	// PostedAt holds when each participant posted their messages, the oldest first
+	PostedAt map[Address][]time.Time `json:"posted_at,omitempty"`
+}
+
+// Register implements aggregate.Register
+func (c *PairChat) Register(r eventsourcing.RegisterFunc) {
+	r(&PairMessagePosted{})
+}
+
+// Transition implements aggregate.Transition
+func (c *PairChat) Transition(event eventsourcing.Event) {
+	switch e := event.Data().(type) {
+	case *PairMessagePosted:
+		if c.PostedAt == nil {
+			c.PostedAt = make(map[Address][]time.Time)
+		}
+		c.PairId = e.PairId
+		c.Messages++
+		c.PostedAt[e.From] = append(c.PostedAt[e.From], event.Timestamp())
+	}
+}
+
+// PostedSince counts the messages the participant posted after the given time
+func (c PairChat) PostedSince(address Address, since time.Time) int {
+	count := 0
+	for _, at := range c.PostedAt[address] {
+		if at.After(since) {
+			count++
+		}
+	}
+	return count
+}
+
+// PairMessagePosted is the event for a participant posting a message to the counterparty of their pair
+type PairMessagePosted struct {
+	PairId string  `json:"pair_id,omitempty"`
+	From   Address `json:"from,omitempty"`
+	Body   string  `json:"body,omitempty"`
+}
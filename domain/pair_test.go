@@ -0,0 +1,237 @@
+package domain
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+var allPairStatuses = []PairStatus{
+	PairStatusWaiting,
+	PairStatusWalletConformation,
+	PairStatusAssurance,
+	PairStatusDeposit,
+	PairStatusPreSignWithdrawal,
+	PairStatusLP,
+	PairStatusPreSignSaversWithdrawal,
+	PairStatusSavers,
+	PairStatusWithdrawn,
+	PairStatusInvalid,
+	PairStatusRefunded,
+}
+
+type transitionCase struct {
+	// ready satisfies the guard of the transition
+	ready Pair
+	// unready fails the guard of the transition, it's nil for the transitions without a guard
+	unready *Pair
+}
+
+func testWallet(publicKeys int) *MultisigWallet {
+	wallet := &MultisigWallet{PublicKeys: map[Asset]string{}, Addresses: map[Asset]Address{}}
+	for _, asset := range []Asset{RuneAsset, "ETH.ETH"}[:publicKeys] {
+		wallet.PublicKeys[asset] = "key"
+		wallet.Addresses[asset] = "address"
+	}
+	return wallet
+}
+
+func testSavers(withdrawTxs, withdrawn int) map[Asset]*SaversPosition {
+	savers := map[Asset]*SaversPosition{RuneAsset: {}, "ETH.ETH": {}}
+	for i, asset := range []Asset{RuneAsset, "ETH.ETH"} {
+		if i < withdrawTxs {
+			savers[asset].WithdrawTx = &SignedTx{}
+		}
+		if i < withdrawn {
+			savers[asset].WithdrawnTx = "hash"
+		}
+	}
+	return savers
+}
+
+// legalTransitions is the expected state machine of the pair, every move missing from it must be illegal
+func legalTransitions() map[PairStatus]map[PairStatus]transitionCase {
+	assets := []Asset{RuneAsset, "ETH.ETH"}
+	creator := map[Asset]Address{RuneAsset: "thor1creator"}
+	matched := map[Asset]Address{RuneAsset: "thor1creator", "ETH.ETH": "0xcounterparty"}
+	assurances := map[Asset][]SignedTx{RuneAsset: {{}}, "ETH.ETH": {{}}}
+	deposits := map[Asset]TxHash{RuneAsset: "hash", "ETH.ETH": "hash"}
+	withdrawnTx := TxHash("hash")
+	invalid := transitionCase{}
+
+	return map[PairStatus]map[PairStatus]transitionCase{
+		"": {
+			PairStatusWaiting: {
+				ready:   Pair{Assets: assets, ParticipantsAddress: creator},
+				unready: &Pair{},
+			},
+		},
+		PairStatusWaiting: {
+			PairStatusWalletConformation: {
+				ready:   Pair{Assets: assets, ParticipantsAddress: matched, Wallet: testWallet(0)},
+				unready: &Pair{Assets: assets, ParticipantsAddress: creator},
+			},
+			PairStatusInvalid: invalid,
+		},
+		PairStatusWalletConformation: {
+			PairStatusAssurance: {
+				ready:   Pair{Wallet: testWallet(2)},
+				unready: &Pair{Wallet: testWallet(1)},
+			},
+			// the match is reverted
+			PairStatusWaiting: {
+				ready:   Pair{Assets: assets, ParticipantsAddress: creator},
+				unready: &Pair{Assets: assets, ParticipantsAddress: matched, Wallet: testWallet(0)},
+			},
+			PairStatusInvalid: invalid,
+		},
+		PairStatusAssurance: {
+			PairStatusDeposit: {
+				ready:   Pair{Assurances: assurances, AssuranceConfirmations: map[Asset]string{RuneAsset: "digest", "ETH.ETH": "digest"}},
+				unready: &Pair{Assurances: assurances, AssuranceConfirmations: map[Asset]string{RuneAsset: "digest"}},
+			},
+			// the assurances are invalidated by a wallet generated again
+			PairStatusWalletConformation: {
+				ready:   Pair{Wallet: testWallet(0)},
+				unready: &Pair{Wallet: testWallet(2), Assurances: assurances},
+			},
+			PairStatusInvalid: invalid,
+		},
+		PairStatusDeposit: {
+			PairStatusPreSignWithdrawal: {
+				ready:   Pair{Assets: assets, Deposits: deposits},
+				unready: &Pair{Assets: assets, PlanType: PlanTypeSavers, Deposits: deposits},
+			},
+			PairStatusPreSignSaversWithdrawal: {
+				ready:   Pair{Assets: assets, PlanType: PlanTypeSavers, Deposits: deposits},
+				unready: &Pair{Assets: assets, PlanType: PlanTypeSavers, Deposits: map[Asset]TxHash{RuneAsset: "hash"}},
+			},
+			PairStatusRefunded: {
+				ready:   Pair{Refund: &RefundIssued{Asset: RuneAsset}},
+				unready: &Pair{},
+			},
+			PairStatusInvalid: invalid,
+		},
+		PairStatusPreSignWithdrawal: {
+			PairStatusLP: {
+				ready:   Pair{WithdrawTx: &SignedTx{}},
+				unready: &Pair{},
+			},
+			PairStatusInvalid: invalid,
+		},
+		PairStatusLP: {
+			PairStatusWithdrawn: {
+				ready:   Pair{WithdrawnTx: &withdrawnTx},
+				unready: &Pair{},
+			},
+			PairStatusInvalid: invalid,
+		},
+		PairStatusPreSignSaversWithdrawal: {
+			PairStatusSavers: {
+				ready:   Pair{Assets: assets, Savers: testSavers(2, 0)},
+				unready: &Pair{Assets: assets, Savers: testSavers(1, 0)},
+			},
+			PairStatusInvalid: invalid,
+		},
+		PairStatusSavers: {
+			PairStatusWithdrawn: {
+				ready:   Pair{Assets: assets, Savers: testSavers(2, 2)},
+				unready: &Pair{Assets: assets, Savers: testSavers(2, 1)},
+			},
+			PairStatusInvalid: invalid,
+		},
+	}
+}
+
+func TestPairCanTransitionTo(t *testing.T) {
+	legal := legalTransitions()
+
+	for _, from := range append([]PairStatus{""}, allPairStatuses...) {
+		for _, to := range allPairStatuses {
+			from, to := from, to
+			t.Run(string(from)+"->"+string(to), func(t *testing.T) {
+				c, ok := legal[from][to]
+				if !ok {
+					p := Pair{Status: from, Wallet: testWallet(2)}
+					if err := p.CanTransitionTo(to); !errors.Is(err, ErrIllegalTransition) {
+						t.Fatalf("expected ErrIllegalTransition, got %v", err)
+					}
+					return
+				}
+
+				ready := c.ready
+				ready.Status = from
+				if err := ready.CanTransitionTo(to); err != nil {
+					t.Fatalf("expected the guard to pass, got %v", err)
+				}
+
+				if c.unready == nil {
+					return
+				}
+				unready := *c.unready
+				unready.Status = from
+				if err := unready.CanTransitionTo(to); !errors.Is(err, ErrTransitionPrecondition) {
+					t.Fatalf("expected ErrTransitionPrecondition, got %v", err)
+				}
+			})
+		}
+	}
+}
+
+func TestPairTransitionsAreExpected(t *testing.T) {
+	legal := legalTransitions()
+
+	for from, targets := range pairTransitions {
+		for to := range targets {
+			if _, ok := legal[from][to]; !ok {
+				t.Errorf("transition from %q to %q is not expected", from, to)
+			}
+		}
+	}
+}
+
+func TestPairChangeStatus(t *testing.T) {
+	p := Pair{Status: PairStatusLP}
+	if err := p.ChangeStatus(PairStatusWaiting); !errors.Is(err, ErrIllegalTransition) {
+		t.Fatalf("expected ErrIllegalTransition, got %v", err)
+	}
+	if p.Status != PairStatusLP {
+		t.Fatalf("expected the status to stay %q, got %q", PairStatusLP, p.Status)
+	}
+
+	if err := p.ChangeStatus(PairStatusWithdrawn); !errors.Is(err, ErrTransitionPrecondition) {
+		t.Fatalf("expected ErrTransitionPrecondition, got %v", err)
+	}
+
+	withdrawnTx := TxHash("hash")
+	p.WithdrawnTx = &withdrawnTx
+	if err := p.ChangeStatus(PairStatusWithdrawn); err != nil {
+		t.Fatalf("expected the pair to be withdrawn, got %v", err)
+	}
+	if p.Status != PairStatusWithdrawn {
+		t.Fatalf("expected the status %q, got %q", PairStatusWithdrawn, p.Status)
+	}
+}
+
+func TestPairIsWithdrawalUnlocked(t *testing.T) {
+	now := time.Now()
+	proposed := &EarlyWithdrawal{ProposedBy: "thor1creator"}
+	agreed := &EarlyWithdrawal{ProposedBy: "thor1creator", AcceptedBy: "0xcounterparty"}
+
+	for name, c := range map[string]struct {
+		pair     Pair
+		unlocked bool
+	}{
+		"deadline not set":                 {pair: Pair{}, unlocked: false},
+		"deadline not set, early proposed": {pair: Pair{EarlyWithdrawal: proposed}, unlocked: false},
+		"deadline not set, early agreed":   {pair: Pair{EarlyWithdrawal: agreed}, unlocked: true},
+		"deadline ahead":                   {pair: Pair{Deadline: now.Add(time.Hour)}, unlocked: false},
+		"deadline ahead, early agreed":     {pair: Pair{Deadline: now.Add(time.Hour), EarlyWithdrawal: agreed}, unlocked: true},
+		"deadline reached":                 {pair: Pair{Deadline: now}, unlocked: true},
+		"deadline passed":                  {pair: Pair{Deadline: now.Add(-time.Hour)}, unlocked: true},
+	} {
+		if got := c.pair.IsWithdrawalUnlocked(now); got != c.unlocked {
+			t.Errorf("%s: expected unlocked %v, got %v", name, c.unlocked, got)
+		}
+	}
+}
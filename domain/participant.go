@@ -0,0 +1,103 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/hallgren/eventsourcing"
+)
+
+// Participant is the aggregate root for a person or entity investing through the platform with the addresses they proved to own.
+// The aggregate is identified by the first address the participant authenticated with.
+type Participant struct {
+	eventsourcing.AggregateRoot
+	Addresses   []ParticipantAddress `json:"addresses,omitempty"`
+	FirstSeenAt time.Time            `json:"first_seen_at,omitempty"`
+	// BlockedAddresses mirrors the blocklist with the reasons the addresses of the participant are blocked for
+	BlockedAddresses map[Address]string `json:"blocked_addresses,omitempty"`
+}
+
+// ParticipantAddress is an address of a participant along with the chain it was proved on
+type ParticipantAddress struct {
+	Chain   string  `json:"chain"`
+	Address Address `json:"address"`
+}
+
+// Register implements aggregate.Register
+func (p *Participant) Register(r eventsourcing.RegisterFunc) {
+	r(
+		&ParticipantRegistered{},
+		&ParticipantAddressLinked{},
+		&ParticipantBlocked{},
+		&ParticipantUnblocked{},
+		&DataRedacted{},
+	)
+}
+
+// Transition implements aggregate.Transition
+func (p *Participant) Transition(event eventsourcing.Event) {
+	switch e := event.Data().(type) {
+	case *ParticipantRegistered:
+		p.Addresses = []ParticipantAddress{{Chain: e.Chain, Address: e.Address}}
+		p.FirstSeenAt = event.Timestamp()
+	case *ParticipantAddressLinked:
+		p.Addresses = append(p.Addresses, ParticipantAddress{Chain: e.Chain, Address: e.Address})
+	case *ParticipantBlocked:
+		if p.BlockedAddresses == nil {
+			p.BlockedAddresses = make(map[Address]string)
+		}
+		p.BlockedAddresses[e.Address] = e.Reason
+	case *ParticipantUnblocked:
+		delete(p.BlockedAddresses, e.Address)
+	}
+}
+
+// IsBlocked checks if any address of the participant is blocked
+func (p Participant) IsBlocked() bool {
+	return len(p.BlockedAddresses) > 0
+}
+
+// HasAddress checks if the address belongs to the participant
+func (p Participant) HasAddress(address Address) bool {
+	for _, a := range p.Addresses {
+		if a.Address == address {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ParticipantRegistered is the event for an address authenticating for the first time
+type ParticipantRegistered struct {
+	Chain   string  `json:"chain,omitempty"`
+	Address Address `json:"address,omitempty"`
+}
+
+// ParticipantAddressLinked is the event for a participant proving they own another address
+type ParticipantAddressLinked struct {
+	Chain   string  `json:"chain,omitempty"`
+	Address Address `json:"address,omitempty"`
+}
+
+// ParticipantBlocked is the event for an operator adding an address of the participant to the blocklist
+type ParticipantBlocked struct {
+	Address  Address `json:"address,omitempty"`
+	Reason   string  `json:"reason,omitempty"`
+	Operator string  `json:"operator,omitempty"`
+}
+
+// ParticipantUnblocked is the event for an operator removing an address of the participant from the blocklist
+type ParticipantUnblocked struct {
+	Address  Address `json:"address,omitempty"`
+	Reason   string  `json:"reason,omitempty"`
+	Operator string  `json:"operator,omitempty"`
+}
+
+// DataRedacted is the event for an operator crypto-shredding the personal data of the addresses of the participant
+// on their request, the fields are the personal fields which can't be read anymore
+type DataRedacted struct {
+	Addresses []Address `json:"addresses,omitempty"`
+	Fields    []string  `json:"fields,omitempty"`
+	Reason    string    `json:"reason,omitempty"`
+	Operator  string    `json:"operator,omitempty"`
+}
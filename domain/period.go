@@ -0,0 +1,32 @@
+package domain
+
+import "time"
+
+// PeriodUnit is the unit the investing period of a plan is expressed in
+type PeriodUnit string
+
+const (
+	PeriodUnitDay   PeriodUnit = "day"
+	PeriodUnitWeek  PeriodUnit = "week"
+	PeriodUnitMonth PeriodUnit = "month"
+)
+
+// PeriodUnitOrDefault returns the unit, or weeks for the plans and pairs created before the units were introduced
+func PeriodUnitOrDefault(unit PeriodUnit) PeriodUnit {
+	if unit == "" {
+		return PeriodUnitWeek
+	}
+	return unit
+}
+
+// AddTo returns the time n units after t, months are added by the calendar
+func (u PeriodUnit) AddTo(t time.Time, n int) time.Time {
+	switch PeriodUnitOrDefault(u) {
+	case PeriodUnitDay:
+		return t.AddDate(0, 0, n)
+	case PeriodUnitMonth:
+		return t.AddDate(0, n, 0)
+	default:
+		return t.AddDate(0, 0, 7*n)
+	}
+}
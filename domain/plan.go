@@ -1,38 +1,220 @@
 package domain
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
 	"github.com/hallgren/eventsourcing"
 )
 
 // Plan is the aggregate root for a plan that bases around a pair of crypto assets
 // for liquidity providing, a security method for shared wallet authority between the parties (2 of 2) or (2 of 3 including mediator),
-// a strategy for profit splitting, quantum of each asset's share in $, agreed loss limit and a time frame (in weeks) for the plan.
+// a strategy for profit splitting, quantum of each asset's share in $, agreed loss limit and a time frame (in days, weeks or months) for the plan.
+// The participants are given a grace period after the time frame to withdraw.
+// Participants may invest a multiple of the quantum up to MaxShareMultiplier.
+// Plans are scoped to a Network, so pairs of a testnet plan never match mainnet ones.
+// A plan only accepts new pairs within its activation window (ActiveFrom, ActiveUntil), while it's not paused
+// and as long as it has less than MaxActivePairs pairs in progress, zero values mean no limits.
+// The pairs of the plan are escalated when they stay in a status longer than its timeout in StatusTimeouts.
+// The assurances the participants pre-sign for their assets follow the policy of the asset's chain in AssurancePolicies,
+// the chains without one follow DefaultAssurancePolicy.
+// The Type of the plan tells whether its pairs provide liquidity to a pool or deposit into the Savers vaults.
 type Plan struct {
 	eventsourcing.AggregateRoot
-	Assets          []Asset                `json:"assets,omitempty"`
-	Security        MultiSigWalletSecurity `json:"security,omitempty"`
-	Strategy        ProfitSharingStrategy  `json:"strategy,omitempty"`
-	Quantum         int                    `json:"quantum,omitempty"`
-	LossProtection  float64                `json:"loss_protection,omitempty"`
-	InvestingPeriod int                    `json:"investing_period,omitempty"`
+	Type                PlanType                   `json:"type,omitempty"`
+	Network             Network                    `json:"network,omitempty"`
+	Assets              []Asset                    `json:"assets,omitempty"`
+	Security            MultiSigWalletSecurity     `json:"security,omitempty"`
+	Strategy            ProfitSharingStrategy      `json:"strategy,omitempty"`
+	Quantum             int                        `json:"quantum,omitempty"`
+	LossProtection      float64                    `json:"loss_protection,omitempty"`
+	InvestingPeriod     int                        `json:"investing_period,omitempty"`
+	InvestingPeriodUnit PeriodUnit                 `json:"investing_period_unit,omitempty"`
+	GracePeriodDays     int                        `json:"grace_period_days,omitempty"`
+	MaxShareMultiplier  int                        `json:"max_share_multiplier,omitempty"`
+	MaxActivePairs      int                        `json:"max_active_pairs,omitempty"`
+	ActiveFrom          time.Time                  `json:"active_from,omitempty"`
+	ActiveUntil         time.Time                  `json:"active_until,omitempty"`
+	PausedAt            time.Time                  `json:"paused_at,omitempty"`
+	StatusTimeouts      map[PairStatus]Duration    `json:"status_timeouts,omitempty"`
+	AssurancePolicies   map[string]AssurancePolicy `json:"assurance_policies,omitempty"`
 }
 
 // Register implements aggregate.Register
 func (p *Plan) Register(r eventsourcing.RegisterFunc) {
-	r(&PlanCreated{})
+	r(
+		&PlanCreated{},
+		&PlanPaused{},
+		&PlanResumed{},
+		&PlanStatusTimeoutsSet{},
+		&PlanAssurancePoliciesSet{},
+	)
 }
 
 // Transition implements aggregate.Transition
 func (p *Plan) Transition(event eventsourcing.Event) {
 	switch e := event.Data().(type) {
 	case *PlanCreated:
+		p.Type = PlanTypeOrDefault(e.Type)
 		p.Assets = e.Assets
 		p.Security = e.Security
 		p.Strategy = e.Strategy
 		p.Quantum = e.Quantum
 		p.LossProtection = e.LossProtection
 		p.InvestingPeriod = e.InvestingPeriod
+		p.InvestingPeriodUnit = PeriodUnitOrDefault(e.InvestingPeriodUnit)
+		p.GracePeriodDays = e.GracePeriodDays
+		p.MaxShareMultiplier = e.MaxShareMultiplier
+		p.Network = NetworkOrDefault(e.Network)
+		p.MaxActivePairs = e.MaxActivePairs
+		p.ActiveFrom = e.ActiveFrom
+		p.ActiveUntil = e.ActiveUntil
+		p.StatusTimeouts = e.StatusTimeouts
+		p.AssurancePolicies = e.AssurancePolicies
+	case *PlanPaused:
+		p.PausedAt = event.Timestamp()
+	case *PlanResumed:
+		p.PausedAt = time.Time{}
+	case *PlanStatusTimeoutsSet:
+		p.StatusTimeouts = e.StatusTimeouts
+	case *PlanAssurancePoliciesSet:
+		p.AssurancePolicies = e.AssurancePolicies
+	}
+}
+
+var (
+	// ErrPlanAlreadyPaused is returned when pausing a plan that is already paused
+	ErrPlanAlreadyPaused = errors.New("plan is already paused")
+	// ErrPlanNotPaused is returned when resuming a plan that isn't paused
+	ErrPlanNotPaused = errors.New("plan is not paused")
+)
+
+// Pause stops the plan from accepting new pairs, the pairs in progress are not affected
+func (p *Plan) Pause(operator, reason string) error {
+	if !p.PausedAt.IsZero() {
+		return ErrPlanAlreadyPaused
+	}
+
+	p.TrackChange(p, &PlanPaused{Operator: operator, Reason: reason})
+	return nil
+}
+
+// Resume lets a paused plan accept new pairs again
+func (p *Plan) Resume(operator string) error {
+	if p.PausedAt.IsZero() {
+		return ErrPlanNotPaused
 	}
+
+	p.TrackChange(p, &PlanResumed{Operator: operator})
+	return nil
+}
+
+// TimeoutStatuses are the statuses a plan can set a timeout for, the participants are expected to move the pair out of them
+var TimeoutStatuses = []PairStatus{
+	PairStatusWalletConformation,
+	PairStatusAssurance,
+	PairStatusDeposit,
+	PairStatusPreSignWithdrawal,
+	PairStatusLP,
+	PairStatusPreSignSaversWithdrawal,
+	PairStatusSavers,
+}
+
+// SetStatusTimeouts replaces the timeouts of the statuses of the plan, the statuses without a timeout never get overdue
+func (p *Plan) SetStatusTimeouts(timeouts map[PairStatus]Duration, operator string) {
+	p.TrackChange(p, &PlanStatusTimeoutsSet{StatusTimeouts: timeouts, Operator: operator})
+}
+
+// StatusTimeout returns how long the pairs of the plan may stay in the status, 0 when they may stay forever
+func (p Plan) StatusTimeout(status PairStatus) time.Duration {
+	return time.Duration(p.StatusTimeouts[status])
+}
+
+// AssurancePolicy is which transactions refunding their deposit the participants pre-sign for the assets of a chain,
+// by the nonces the pair's wallet sends its transactions of the asset with
+type AssurancePolicy struct {
+	// Nonces are the nonces of the assurances the participants must sign
+	Nonces []int `json:"nonces" validate:"required,min=1,max=64,dive,min=0"`
+	// MaxTxs is how many assurances the participants may sign for an asset, zero when they sign as many as they like
+	MaxTxs int `json:"max_txs,omitempty" validate:"omitempty,max=64"`
+}
+
+// DefaultAssurancePolicy returns the policy of the chains a plan doesn't set one for. The assurances with nonce 0 and 2 guard
+// the deposit and the LP transactions, the one with nonce 4 guards the withdrawal sent from the RUNE address of the wallet,
+// or from the address of every asset for the savers pairs.
+func DefaultAssurancePolicy(planType PlanType, chain string) AssurancePolicy {
+	if runeChain, _ := ChainOf(RuneAsset); chain == runeChain || PlanTypeOrDefault(planType) == PlanTypeSavers {
+		return AssurancePolicy{Nonces: []int{0, 2, 4}}
+	}
+	return AssurancePolicy{Nonces: []int{0, 2}}
+}
+
+// WithdrawalNonce returns the nonce of the withdrawal pre-signed for the assets of the chain, which comes right before the
+// last assurance so it's guarded by it. It's false when the policy has no assurance after the deposit to guard a withdrawal.
+func (p AssurancePolicy) WithdrawalNonce() (int, bool) {
+	last := 0
+	for _, nonce := range p.Nonces {
+		last = max(last, nonce)
+	}
+	if last == 0 {
+		return 0, false
+	}
+	return last - 1, true
+}
+
+// SetAssurancePolicies replaces the assurance policies of the plan by chain, the chains without one follow the default policy
+func (p *Plan) SetAssurancePolicies(policies map[string]AssurancePolicy, operator string) {
+	p.TrackChange(p, &PlanAssurancePoliciesSet{AssurancePolicies: policies, Operator: operator})
+}
+
+// AssurancePolicy returns the policy the assurances of the assets of the chain follow
+func (p Plan) AssurancePolicy(chain string) AssurancePolicy {
+	if policy, ok := p.AssurancePolicies[chain]; ok {
+		return policy
+	}
+	return DefaultAssurancePolicy(p.Type, chain)
+}
+
+// Duration is a time.Duration encoded in JSON as its string representation, e.g. "36h"
+type Duration time.Duration
+
+// MarshalJSON implements json.Marshaler
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duration must be a string like \"36h\": %w", err)
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// PlanType is how the pairs of a plan invest their assets on THORChain
+type PlanType string
+
+const (
+	// PlanTypeLP pairs provide dual-sided liquidity to the pool of their assets
+	PlanTypeLP PlanType = "lp"
+	// PlanTypeSavers pairs deposit each of their assets single-sided into its Savers vault, RUNE has no vault
+	PlanTypeSavers PlanType = "savers"
+)
+
+// PlanTypeOrDefault returns the type, or LP for the plans and pairs created before savers plans were introduced
+func PlanTypeOrDefault(t PlanType) PlanType {
+	if t == "" {
+		return PlanTypeLP
+	}
+	return t
 }
 
 // MultiSigWalletSecurity is the type of security method used for threshold signature wallet
@@ -57,10 +239,43 @@ const (
 
 // PlanCreated is the event for creating a new plan for the first time.
 type PlanCreated struct {
-	Assets          []Asset                `json:"assets,omitempty"`
-	Security        MultiSigWalletSecurity `json:"security,omitempty"`
-	Strategy        ProfitSharingStrategy  `json:"strategy,omitempty"`
-	Quantum         int                    `json:"quantum,omitempty"`
-	LossProtection  float64                `json:"loss_protection,omitempty"`
-	InvestingPeriod int                    `json:"investing_period,omitempty"`
+	Type                PlanType                   `json:"type,omitempty"`
+	Assets              []Asset                    `json:"assets,omitempty"`
+	Security            MultiSigWalletSecurity     `json:"security,omitempty"`
+	Strategy            ProfitSharingStrategy      `json:"strategy,omitempty"`
+	Quantum             int                        `json:"quantum,omitempty"`
+	LossProtection      float64                    `json:"loss_protection,omitempty"`
+	InvestingPeriod     int                        `json:"investing_period,omitempty"`
+	InvestingPeriodUnit PeriodUnit                 `json:"investing_period_unit,omitempty"`
+	GracePeriodDays     int                        `json:"grace_period_days,omitempty"`
+	MaxShareMultiplier  int                        `json:"max_share_multiplier,omitempty"`
+	Network             Network                    `json:"network,omitempty"`
+	MaxActivePairs      int                        `json:"max_active_pairs,omitempty"`
+	ActiveFrom          time.Time                  `json:"active_from,omitempty"`
+	ActiveUntil         time.Time                  `json:"active_until,omitempty"`
+	StatusTimeouts      map[PairStatus]Duration    `json:"status_timeouts,omitempty"`
+	AssurancePolicies   map[string]AssurancePolicy `json:"assurance_policies,omitempty"`
+}
+
+// PlanPaused is the event for an operator pausing the plan
+type PlanPaused struct {
+	Operator string `json:"operator,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// PlanResumed is the event for an operator resuming a paused plan
+type PlanResumed struct {
+	Operator string `json:"operator,omitempty"`
+}
+
+// PlanStatusTimeoutsSet is the event for an operator changing the timeouts of the statuses of the plan's pairs
+type PlanStatusTimeoutsSet struct {
+	StatusTimeouts map[PairStatus]Duration `json:"status_timeouts,omitempty"`
+	Operator       string                  `json:"operator,omitempty"`
+}
+
+// PlanAssurancePoliciesSet is the event for an operator changing the assurance policies of the plan's chains
+type PlanAssurancePoliciesSet struct {
+	AssurancePolicies map[string]AssurancePolicy `json:"assurance_policies,omitempty"`
+	Operator          string                     `json:"operator,omitempty"`
 }
@@ -0,0 +1,66 @@
+package domain
+
+import "time"
+
+// SettlementReport is the outcome of the withdrawal of a pair and the realized profit and loss of its participants.
+// A withdrawal that failed on chain is reported unconfirmed along with the reason, without any result.
+type SettlementReport struct {
+	TxHash    TxHash `json:"tx_hash"`
+	Confirmed bool   `json:"confirmed"`
+	Reason    string `json:"reason,omitempty"`
+	// Withdrawn holds the amounts the withdrawal paid out to the pair's wallet by asset
+	Withdrawn map[Asset]TokenAmount `json:"withdrawn,omitempty"`
+	// PricesUSD holds the prices the withdrawn amounts are valued with
+	PricesUSD    map[Asset]float64                 `json:"prices_usd,omitempty"`
+	ValueUSD     float64                           `json:"value_usd"`
+	Participants map[Address]ParticipantSettlement `json:"participants,omitempty"`
+	SettledAt    time.Time                         `json:"settled_at"`
+}
+
+// ParticipantSettlement is the result of the pair for one of its participants
+type ParticipantSettlement struct {
+	Asset Asset `json:"asset"`
+	// InvestedUSD is the value the participant deposited, i.e. the share value of the pair
+	InvestedUSD float64 `json:"invested_usd"`
+	// ShareUSD is the part of the withdrawn value the participant is entitled to by the profit sharing strategy
+	ShareUSD float64 `json:"share_usd"`
+	PnLUSD   float64 `json:"pnl_usd"`
+	// PnLPercent is the profit or loss relative to the invested value
+	PnLPercent float64 `json:"pnl_percent"`
+}
+
+// Settle values the amounts paid out by the withdrawal with the prices and splits the value between the participants
+// according to the profit sharing strategy of the pair
+func (p Pair) Settle(txHash TxHash, withdrawn map[Asset]TokenAmount, prices map[Asset]float64, at time.Time) SettlementReport {
+	report := SettlementReport{
+		TxHash:       txHash,
+		Confirmed:    true,
+		Withdrawn:    withdrawn,
+		PricesUSD:    prices,
+		Participants: make(map[Address]ParticipantSettlement, len(p.ParticipantsAddress)),
+		SettledAt:    at,
+	}
+
+	for asset, amount := range withdrawn {
+		report.ValueUSD += amount.Float() * prices[asset]
+	}
+
+	// Both participants deposit the share value, so the equal share strategy splits the withdrawn value in halves.
+	// It's the only strategy so far, the plans without one are treated alike.
+	invested := float64(p.ShareValue)
+	share := report.ValueUSD / float64(len(p.ParticipantsAddress))
+	for asset, address := range p.ParticipantsAddress {
+		settlement := ParticipantSettlement{
+			Asset:       asset,
+			InvestedUSD: invested,
+			ShareUSD:    share,
+			PnLUSD:      share - invested,
+		}
+		if invested > 0 {
+			settlement.PnLPercent = settlement.PnLUSD / invested * 100
+		}
+		report.Participants[address] = settlement
+	}
+
+	return report
+}
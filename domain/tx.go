@@ -0,0 +1,155 @@
+package domain
+
+import (
+	"sort"
+	"time"
+)
+
+// TxStatus is the status of a transaction of the pair on its chain
+type TxStatus string
+
+const (
+	// TxStatusPending is the status of the transactions not mined deep enough yet
+	TxStatusPending TxStatus = "pending"
+	// TxStatusConfirmed is the status of the transactions mined under the required number of blocks
+	TxStatusConfirmed TxStatus = "confirmed"
+	// TxStatusFailed is the status of the transactions reverted on chain or dropped before being mined
+	TxStatusFailed TxStatus = "failed"
+	// TxStatusReorged is the status of the confirmed transactions a reorganization of the chain took out of their block
+	TxStatusReorged TxStatus = "reorged"
+)
+
+// TxKind tells what a transaction of the pair is for
+type TxKind string
+
+const (
+	TxKindDeposit    TxKind = "deposit"
+	TxKindLP         TxKind = "lp"
+	TxKindSavers     TxKind = "savers"
+	TxKindWithdrawal TxKind = "withdrawal"
+	TxKindRefund     TxKind = "refund"
+)
+
+// TxFinalityWindow is how long the confirmed transactions are still checked for reorganizations
+const TxFinalityWindow = time.Hour
+
+// TrackedTx is the status of a transaction the pair recorded, as last seen on its chain.
+// Unverified tells the transaction was accepted without being verified, as its chain couldn't be reached.
+type TrackedTx struct {
+	Kind          TxKind    `json:"kind"`
+	Asset         Asset     `json:"asset"`
+	Status        TxStatus  `json:"status"`
+	Confirmations int       `json:"confirmations"`
+	BlockHash     string    `json:"block_hash,omitempty"`
+	Reason        string    `json:"reason,omitempty"`
+	Unverified    bool      `json:"unverified,omitempty"`
+	RecordedAt    time.Time `json:"recorded_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// Tracking checks if the transaction is still checked on chain, the failed ones and the ones confirmed for
+// longer than TxFinalityWindow are final
+func (t TrackedTx) Tracking(at time.Time) bool {
+	switch t.Status {
+	case TxStatusPending, TxStatusReorged:
+		return true
+	case TxStatusConfirmed:
+		return at.Sub(t.UpdatedAt) < TxFinalityWindow
+	}
+
+	return false
+}
+
+// TxStatusChanged is the event for a transaction of the pair changing status on its chain.
+type TxStatusChanged struct {
+	TxHash        TxHash   `json:"tx_hash,omitempty"`
+	Status        TxStatus `json:"status,omitempty"`
+	Confirmations int      `json:"confirmations,omitempty"`
+	BlockHash     string   `json:"block_hash,omitempty"`
+	Reason        string   `json:"reason,omitempty"`
+}
+
+// Failure checks if the transaction failed or was reorganized out of the chain
+func (e TxStatusChanged) Failure() bool {
+	return e.Status == TxStatusFailed || e.Status == TxStatusReorged
+}
+
+func (p *Pair) trackTx(hash TxHash, kind TxKind, asset Asset, at time.Time) {
+	if hash == "" {
+		return
+	}
+	if p.Txs == nil {
+		p.Txs = make(map[TxHash]TrackedTx)
+	}
+
+	p.Txs[hash] = TrackedTx{
+		Kind:       kind,
+		Asset:      asset,
+		Status:     TxStatusPending,
+		RecordedAt: at,
+		UpdatedAt:  at,
+	}
+}
+
+func (p *Pair) applyTxStatusChanged(e *TxStatusChanged, at time.Time) {
+	tracked, ok := p.Txs[e.TxHash]
+	if !ok {
+		return
+	}
+
+	tracked.Status = e.Status
+	tracked.Confirmations = e.Confirmations
+	tracked.BlockHash = e.BlockHash
+	tracked.Reason = e.Reason
+	tracked.UpdatedAt = at
+	p.Txs[e.TxHash] = tracked
+}
+
+// NonceAllocation is a nonce of the wallet address of an asset of the pair taken by a pre-signed transaction
+type NonceAllocation struct {
+	Nonce int    `json:"nonce"`
+	Kind  TxKind `json:"kind"`
+}
+
+// AllocateNonces lists the nonces of the wallet address of each asset taken by the pre-signed transactions in nonce order:
+// the assurances refunding the deposits and the withdrawals. The withdrawal of a LP pair is sent from the RUNE address,
+// the ones of a savers pair from the address of each asset.
+func AllocateNonces(assurances map[Asset][]SignedTx, withdrawTx *SignedTx, savers map[Asset]*SaversPosition) map[Asset][]NonceAllocation {
+	allocations := make(map[Asset][]NonceAllocation)
+	for asset, txs := range assurances {
+		for _, tx := range txs {
+			allocations[asset] = append(allocations[asset], NonceAllocation{Nonce: tx.Nonce, Kind: TxKindRefund})
+		}
+	}
+	if withdrawTx != nil {
+		allocations[RuneAsset] = append(allocations[RuneAsset], NonceAllocation{Nonce: withdrawTx.Nonce, Kind: TxKindWithdrawal})
+	}
+	for asset, position := range savers {
+		if position != nil && position.WithdrawTx != nil {
+			allocations[asset] = append(allocations[asset], NonceAllocation{Nonce: position.WithdrawTx.Nonce, Kind: TxKindWithdrawal})
+		}
+	}
+	for _, nonces := range allocations {
+		sort.SliceStable(nonces, func(i, j int) bool {
+			return nonces[i].Nonce < nonces[j].Nonce
+		})
+	}
+
+	return allocations
+}
+
+// NonceAllocations lists the nonces of the wallet addresses of the pair taken by its pre-signed transactions, by asset
+func (p Pair) NonceAllocations() map[Asset][]NonceAllocation {
+	return AllocateNonces(p.Assurances, p.WithdrawTx, p.Savers)
+}
+
+// NonceAllocated tells what the nonce of the wallet address of the asset is taken by, if any
+func (p Pair) NonceAllocated(asset Asset, nonce int) (TxKind, bool) {
+	for _, allocation := range p.NonceAllocations()[asset] {
+		if allocation.Nonce == nonce {
+			return allocation.Kind, true
+		}
+	}
+
+	return "", false
+}
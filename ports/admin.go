@@ -0,0 +1,410 @@
+package ports
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"time"
+
+	"github.com/co-defi/api-server/app"
+	"github.com/co-defi/api-server/app/audit"
+	"github.com/co-defi/api-server/app/commands"
+	"github.com/co-defi/api-server/app/compliance"
+	"github.com/co-defi/api-server/app/queries"
+	"github.com/co-defi/api-server/common"
+	"github.com/co-defi/api-server/domain"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// headerAdminToken is the header the operators authenticate the admin routes with
+	headerAdminToken = "X-Admin-Token"
+	// adminOperatorKey is the context key of the operator authenticated for the request
+	adminOperatorKey = "admin_operator"
+)
+
+var ErrAdminAuthenticationFailed = common.NewError("admin_auth_failed", "admin authentication failed")
+
+// WithAdminTokens sets the tokens of the operators allowed to use the admin routes by the operator name,
+// admin routes reject every request when no tokens are set
+func (s *HttpServer) WithAdminTokens(tokens map[string]string) {
+	s.adminTokens = tokens
+}
+
+// authenticateAdmin returns the operator owning the admin token of the request
+func (s *HttpServer) authenticateAdmin(r *http.Request) (string, bool) {
+	token := r.Header.Get(headerAdminToken)
+	if token == "" {
+		return "", false
+	}
+
+	for operator, t := range s.adminTokens {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(t)) == 1 {
+			return operator, true
+		}
+	}
+
+	return "", false
+}
+
+type getAuditLogRequest struct {
+	Address string    `query:"address"`
+	Route   string    `query:"route"`
+	Since   time.Time `query:"since"`
+	Until   time.Time `query:"until"`
+	Limit   int       `query:"limit" validate:"omitempty,min=1,max=1000"`
+}
+
+func (s *HttpServer) getAuditLog(c echo.Context) error {
+	var req getAuditLogRequest
+	if err := bindRequest(c, &req); err != nil {
+		return err
+	}
+
+	entries, err := s.app.AuditLog.Find(c.Request().Context(), audit.Filter{
+		Address: req.Address,
+		Route:   req.Route,
+		Since:   req.Since,
+		Until:   req.Until,
+		Limit:   req.Limit,
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, entries)
+}
+
+type forcePairStatusRequest struct {
+	PairId string            `param:"id" json:"-" validate:"required,uuid4"`
+	Status domain.PairStatus `json:"status,omitempty" validate:"required"`
+	Reason string            `json:"reason,omitempty" validate:"required,max=1000"`
+}
+
+func (s *HttpServer) forcePairStatus(c echo.Context) error {
+	var req forcePairStatusRequest
+	if err := bindRequest(c, &req); err != nil {
+		return err
+	}
+
+	_, err := s.app.Commands.ForcePairStatus.Handle(c.Request().Context(), commands.ForcePairStatus{
+		PairId:   req.PairId,
+		Status:   req.Status,
+		Operator: c.Get(adminOperatorKey).(string),
+		Reason:   req.Reason,
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+type pausePlanRequest struct {
+	PlanId string `param:"id" json:"-" validate:"required,uuid4"`
+	Reason string `json:"reason,omitempty" validate:"required,max=1000"`
+}
+
+func (s *HttpServer) pausePlan(c echo.Context) error {
+	var req pausePlanRequest
+	if err := bindRequest(c, &req); err != nil {
+		return err
+	}
+
+	_, err := s.app.Commands.PausePlan.Handle(c.Request().Context(), commands.PausePlan{
+		PlanId:   req.PlanId,
+		Operator: c.Get(adminOperatorKey).(string),
+		Reason:   req.Reason,
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+type resumePlanRequest struct {
+	PlanId string `param:"id" json:"-" validate:"required,uuid4"`
+}
+
+func (s *HttpServer) resumePlan(c echo.Context) error {
+	var req resumePlanRequest
+	if err := bindRequest(c, &req); err != nil {
+		return err
+	}
+
+	_, err := s.app.Commands.ResumePlan.Handle(c.Request().Context(), commands.ResumePlan{
+		PlanId:   req.PlanId,
+		Operator: c.Get(adminOperatorKey).(string),
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+type setPlanStatusTimeoutsRequest struct {
+	PlanId         string                                `param:"id" json:"-" validate:"required,uuid4"`
+	StatusTimeouts map[domain.PairStatus]domain.Duration `json:"status_timeouts" validate:"dive,keys,timeout_status,endkeys,gt=0"`
+}
+
+func (s *HttpServer) setPlanStatusTimeouts(c echo.Context) error {
+	var req setPlanStatusTimeoutsRequest
+	if err := bindRequest(c, &req); err != nil {
+		return err
+	}
+
+	_, err := s.app.Commands.SetPlanTimeouts.Handle(c.Request().Context(), commands.SetPlanStatusTimeouts{
+		PlanId:         req.PlanId,
+		StatusTimeouts: req.StatusTimeouts,
+		Operator:       c.Get(adminOperatorKey).(string),
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+type setPlanAssurancePoliciesRequest struct {
+	PlanId            string                            `param:"id" json:"-" validate:"required,uuid4"`
+	AssurancePolicies map[string]domain.AssurancePolicy `json:"assurance_policies" validate:"dive,keys,chain,endkeys"`
+}
+
+func (s *HttpServer) setPlanAssurancePolicies(c echo.Context) error {
+	var req setPlanAssurancePoliciesRequest
+	if err := bindRequest(c, &req); err != nil {
+		return err
+	}
+
+	_, err := s.app.Commands.SetPlanAssurances.Handle(c.Request().Context(), commands.SetPlanAssurancePolicies{
+		PlanId:            req.PlanId,
+		AssurancePolicies: req.AssurancePolicies,
+		Operator:          c.Get(adminOperatorKey).(string),
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+type blockAddressRequest struct {
+	Address domain.Address `json:"address" validate:"required"`
+	Reason  string         `json:"reason" validate:"required,max=1000"`
+}
+
+func (s *HttpServer) getBlocklist(c echo.Context) error {
+	entries, err := s.app.Blocklist.All(c.Request().Context())
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, entries)
+}
+
+func (s *HttpServer) blockAddress(c echo.Context) error {
+	var req blockAddressRequest
+	if err := bindRequest(c, &req); err != nil {
+		return err
+	}
+
+	if _, err := s.app.Commands.BlockAddress.Handle(c.Request().Context(), commands.BlockAddress{
+		Address:  req.Address,
+		Reason:   req.Reason,
+		Operator: c.Get(adminOperatorKey).(string),
+	}); err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+type unblockAddressRequest struct {
+	Address domain.Address `param:"address" json:"-" validate:"required"`
+	Reason  string         `json:"reason" validate:"required,max=1000"`
+}
+
+func (s *HttpServer) unblockAddress(c echo.Context) error {
+	var req unblockAddressRequest
+	if err := bindRequest(c, &req); err != nil {
+		return err
+	}
+
+	if _, err := s.app.Commands.BlockAddress.Handle(c.Request().Context(), commands.BlockAddress{
+		Address:  req.Address,
+		Reason:   req.Reason,
+		Operator: c.Get(adminOperatorKey).(string),
+		Unblock:  true,
+	}); err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+type getBlocklistChangesRequest struct {
+	Address domain.Address `query:"address"`
+}
+
+func (s *HttpServer) getBlocklistChanges(c echo.Context) error {
+	var req getBlocklistChangesRequest
+	if err := bindRequest(c, &req); err != nil {
+		return err
+	}
+
+	changes, err := s.app.Blocklist.Changes(c.Request().Context(), req.Address)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, changes)
+}
+
+type getComplianceResultsRequest struct {
+	Status compliance.Status `query:"status" validate:"omitempty,oneof=approved rejected pending"`
+	Limit  int               `query:"limit" validate:"omitempty,min=1,max=1000"`
+}
+
+func (s *HttpServer) getComplianceResults(c echo.Context) error {
+	var req getComplianceResultsRequest
+	if err := bindRequest(c, &req); err != nil {
+		return err
+	}
+
+	results, err := s.app.Compliance.Results(c.Request().Context(), req.Status, req.Limit)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, results)
+}
+
+type getComplianceResultRequest struct {
+	Address domain.Address `param:"address" validate:"required"`
+	// Refresh asks the provider again instead of returning the cached result
+	Refresh bool `query:"refresh"`
+}
+
+func (s *HttpServer) getComplianceResult(c echo.Context) error {
+	var req getComplianceResultRequest
+	if err := bindRequest(c, &req); err != nil {
+		return err
+	}
+
+	result, err := s.app.Compliance.Check(c.Request().Context(), req.Address, req.Refresh)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, result)
+}
+
+type redactParticipantDataRequest struct {
+	Address domain.Address `param:"address" json:"-" validate:"required"`
+	Reason  string         `json:"reason" validate:"required,max=1000"`
+}
+
+type redactParticipantDataResponse struct {
+	ParticipantId string `json:"participant_id,omitempty"`
+}
+
+// redactParticipantData crypto-shreds the personal data of the participant of the address on their request
+func (s *HttpServer) redactParticipantData(c echo.Context) error {
+	var req redactParticipantDataRequest
+	if err := bindRequest(c, &req); err != nil {
+		return err
+	}
+
+	id, err := s.app.Commands.RedactParticipantData.Handle(c.Request().Context(), commands.RedactParticipantData{
+		Address:  req.Address,
+		Reason:   req.Reason,
+		Operator: c.Get(adminOperatorKey).(string),
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, redactParticipantDataResponse{ParticipantId: id})
+}
+
+type exportPairsRequest struct {
+	// Columns are the comma separated columns of the export, see app.DefaultPairsCSVColumns for the default ones
+	Columns         string            `query:"columns"`
+	Network         domain.Network    `query:"network" validate:"omitempty,network"`
+	PlanId          string            `query:"plan_id" validate:"omitempty,uuid4"`
+	PlanType        domain.PlanType   `query:"plan_type" validate:"omitempty,oneof=lp savers"`
+	Status          domain.PairStatus `query:"status" validate:"omitempty,oneof=waiting wallet_conformation assurance deposit pre_sign_withdrawal lp pre_sign_savers_withdrawal savers withdrawn invalid refunded"`
+	Asset           domain.Asset      `query:"asset" validate:"omitempty,asset"`
+	CreatedAfter    time.Time         `query:"created_after"`
+	CreatedBefore   time.Time         `query:"created_before"`
+	IncludeArchived bool              `query:"include_archived"`
+}
+
+// exportPairs streams the pairs of every participant matching the filters as CSV, for the reconciliation of the finance team.
+// The status is sent with the first rows, so a failure midway truncates the export and is only logged.
+func (s *HttpServer) exportPairs(c echo.Context) error {
+	var req exportPairsRequest
+	if err := bindRequest(c, &req); err != nil {
+		return err
+	}
+
+	filter := queries.PairFilter{
+		PlanId:          req.PlanId,
+		CreatedAfter:    req.CreatedAfter,
+		CreatedBefore:   req.CreatedBefore,
+		IncludeArchived: req.IncludeArchived,
+	}
+	if req.Network != "" {
+		filter.Network = &req.Network
+	}
+	if req.PlanType != "" {
+		filter.PlanType = &req.PlanType
+	}
+	if req.Status != "" {
+		filter.Status = &req.Status
+	}
+	if req.Asset != "" {
+		filter.Assets = []domain.Asset{req.Asset}
+	}
+
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, "text/csv; charset=utf-8")
+	res.Header().Set(echo.HeaderContentDisposition, `attachment; filename="pairs.csv"`)
+	_, err := s.app.ExportPairsCSV(c.Request().Context(), res, filter, app.ParseExportColumns(req.Columns))
+	return err
+}
+
+type getLatestPairsRequest struct {
+	Limit int `query:"limit" validate:"omitempty,min=1,max=100"`
+}
+
+// getLatestPairs returns the pairs of every participant created last, the latest first
+func (s *HttpServer) getLatestPairs(c echo.Context) error {
+	var req getLatestPairsRequest
+	if err := bindRequest(c, &req); err != nil {
+		return err
+	}
+
+	pairs, err := s.app.Queries.Pairs.Latest(c.Request().Context(), req.Limit)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, pairs)
+}
+
+// getStuckPairs returns the pairs overdue in their status, the longest overdue first
+func (s *HttpServer) getStuckPairs(c echo.Context) error {
+	pairs, err := s.app.StuckPairs(c.Request().Context())
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, pairs)
+}
+
+// getFeatures returns the optional subsystems the server runs with
+func (s *HttpServer) getFeatures(c echo.Context) error {
+	return c.JSON(http.StatusOK, s.app.Features())
+}
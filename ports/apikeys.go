@@ -0,0 +1,136 @@
+package ports
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/co-defi/api-server/app/apikeys"
+	"github.com/co-defi/api-server/common"
+	"github.com/co-defi/api-server/domain"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// adminRouteScopes are the scopes granting the admin routes to the API keys besides apikeys.ScopeAdmin,
+// by path without the API version
+var adminRouteScopes = map[string]apikeys.Scope{
+	"/admin/events": apikeys.ScopeEvents,
+}
+
+// authenticateAdminAPIKey returns the operator of the API key of the request, the key must grant the admin route
+func (s *HttpServer) authenticateAdminAPIKey(c echo.Context) (string, error) {
+	secret := c.Request().Header.Get(common.HeaderAPIKey)
+	if secret == "" {
+		return "", ErrAdminAuthenticationFailed
+	}
+
+	key, err := s.app.APIKeys.Authenticate(c.Request().Context(), secret)
+	if err != nil {
+		return "", err
+	}
+	scope, ok := adminRouteScopes[unversionedPath(c.Path())]
+	if !key.HasScope(apikeys.ScopeAdmin) && !(ok && key.HasScope(scope)) {
+		return "", apikeys.ErrScopeNotGranted
+	}
+
+	return "api-key:" + key.Name, nil
+}
+
+type apiKeyRequest struct {
+	Name      string          `json:"name"`
+	Scopes    []apikeys.Scope `json:"scopes"`
+	Chain     common.Chain    `json:"chain,omitempty"`
+	Address   domain.Address  `json:"address,omitempty"`
+	Network   domain.Network  `json:"network,omitempty"`
+	ExpiresAt time.Time       `json:"expires_at,omitempty"`
+}
+
+func (r apiKeyRequest) spec() apikeys.Spec {
+	return apikeys.Spec{
+		Name:      r.Name,
+		Scopes:    r.Scopes,
+		Chain:     r.Chain,
+		Address:   r.Address,
+		Network:   r.Network,
+		ExpiresAt: r.ExpiresAt,
+	}
+}
+
+// issueAPIKeyResponse is the issued key along with its secret, which is never returned again
+type issueAPIKeyResponse struct {
+	*apikeys.Key
+	Secret string `json:"secret"`
+}
+
+type apiKeyIdRequest struct {
+	Id string `param:"id" json:"-" validate:"required,uuid4"`
+}
+
+type updateAPIKeyRequest struct {
+	Id string `param:"id" json:"-" validate:"required,uuid4"`
+	apiKeyRequest
+}
+
+func (s *HttpServer) getAPIKeys(c echo.Context) error {
+	keys, err := s.app.APIKeys.All(c.Request().Context())
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, keys)
+}
+
+func (s *HttpServer) issueAPIKey(c echo.Context) error {
+	var req apiKeyRequest
+	if err := bindRequest(c, &req); err != nil {
+		return err
+	}
+
+	key, secret, err := s.app.APIKeys.Issue(c.Request().Context(), req.spec(), c.Get(adminOperatorKey).(string))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusCreated, issueAPIKeyResponse{Key: key, Secret: secret})
+}
+
+func (s *HttpServer) getAPIKey(c echo.Context) error {
+	var req apiKeyIdRequest
+	if err := bindRequest(c, &req); err != nil {
+		return err
+	}
+
+	key, err := s.app.APIKeys.Get(c.Request().Context(), uuid.MustParse(req.Id))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, key)
+}
+
+func (s *HttpServer) updateAPIKey(c echo.Context) error {
+	var req updateAPIKeyRequest
+	if err := bindRequest(c, &req); err != nil {
+		return err
+	}
+
+	key, err := s.app.APIKeys.Update(c.Request().Context(), uuid.MustParse(req.Id), req.spec())
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, key)
+}
+
+func (s *HttpServer) revokeAPIKey(c echo.Context) error {
+	var req apiKeyIdRequest
+	if err := bindRequest(c, &req); err != nil {
+		return err
+	}
+
+	if err := s.app.APIKeys.Revoke(c.Request().Context(), uuid.MustParse(req.Id)); err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,73 @@
+package ports
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+
+	"github.com/co-defi/api-server/app/audit"
+	"github.com/labstack/echo/v4"
+)
+
+// auditMutations is a middleware recording the authenticated mutating requests in the audit log.
+// Only the hash of the payload is recorded, so the log doesn't keep the signed transactions of the participants.
+func (s *HttpServer) auditMutations(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		req := c.Request()
+		if req.Method == http.MethodGet || req.Method == http.MethodHead || req.Method == http.MethodOptions {
+			return next(c)
+		}
+
+		actor, ok := s.auditActor(req)
+		if !ok {
+			return next(c)
+		}
+
+		payload, err := io.ReadAll(req.Body)
+		if err != nil {
+			return err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(payload))
+		hash := sha256.Sum256(payload)
+
+		err = next(c)
+
+		entry := audit.Entry{
+			Address:     actor,
+			Method:      req.Method,
+			Route:       c.Path(),
+			PayloadHash: hex.EncodeToString(hash[:]),
+			Status:      c.Response().Status,
+			IP:          c.RealIP(),
+			Timestamp:   s.app.Clock.Now(),
+		}
+		if err != nil {
+			body := toCommonError(err)
+			entry.Status = body.HttpStatus()
+			entry.ErrorCode = body.Code
+		}
+		// The request context may already be canceled, the action happened regardless
+		if recordErr := s.app.AuditLog.Record(context.Background(), entry); recordErr != nil {
+			s.logger.Error().Err(recordErr).Str("route", entry.Route).Msg("failed to record audit log entry")
+		}
+
+		return err
+	}
+}
+
+// auditActor identifies who is making the request, operators are prefixed to tell them apart from participants
+func (s *HttpServer) auditActor(req *http.Request) (string, bool) {
+	if operator, ok := s.authenticateAdmin(req); ok {
+		return "admin:" + operator, true
+	}
+
+	token, err := s.authDB.ExtractTokenFromHttp(req)
+	if err != nil {
+		return "", false
+	}
+
+	return token.Address, true
+}
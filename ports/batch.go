@@ -0,0 +1,132 @@
+package ports
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/co-defi/api-server/app/commands"
+	"github.com/co-defi/api-server/common"
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+)
+
+type pairBatchRequest struct {
+	PairId   string                    `param:"id" json:"-" validate:"required,uuid4"`
+	Commands []pairBatchCommandRequest `json:"commands,omitempty" validate:"required,min=1,max=8,dive"`
+}
+
+// pairBatchCommandRequest is a command of a batch, its payload is the body of the endpoint of the command, e.g. POST /pairs/:id/confirm-wallet
+type pairBatchCommandRequest struct {
+	Type    string          `json:"type,omitempty" validate:"required,oneof=confirm_wallet set_assurances confirm_assurances"`
+	Payload json.RawMessage `json:"payload,omitempty" validate:"required"`
+}
+
+type pairBatchResponse struct {
+	PairId  string            `json:"pair_id"`
+	Results []pairBatchResult `json:"results"`
+}
+
+type pairBatchResult struct {
+	Index  int    `json:"index"`
+	Type   string `json:"type"`
+	Status string `json:"status"`
+}
+
+// batchPair executes the commands on the pair in order and all-or-nothing, so the mobile clients confirm the wallet and the assurances
+// in a single round trip. The error of the failing command tells its index and type in command_index and command_type of its meta.
+func (s *HttpServer) batchPair(c echo.Context) error {
+	var req pairBatchRequest
+	if err := bindRequest(c, &req); err != nil {
+		return err
+	}
+
+	auth, err := s.authDB.ExtractTokenFromHttp(c.Request())
+	if err != nil {
+		return err
+	}
+
+	batch := commands.PairBatch{PairId: req.PairId, ParticipantAddress: auth.Address}
+	for i, command := range req.Commands {
+		cmd, err := s.pairBatchCommand(c, req.PairId, auth, command)
+		if err != nil {
+			return batchPayloadError(i, err)
+		}
+		batch.Commands = append(batch.Commands, cmd)
+	}
+
+	if _, err := s.app.Commands.PairBatch.Handle(c.Request().Context(), batch); err != nil {
+		return err
+	}
+
+	results := make([]pairBatchResult, len(req.Commands))
+	for i, command := range req.Commands {
+		results[i] = pairBatchResult{Index: i, Type: command.Type, Status: "applied"}
+	}
+
+	return s.respondToPairCommand(c, req.PairId, http.StatusOK, pairBatchResponse{PairId: req.PairId, Results: results})
+}
+
+// pairBatchCommand decodes and validates the payload of the command as the endpoint of the command does
+func (s *HttpServer) pairBatchCommand(c echo.Context, pairId string, auth common.Token, command pairBatchCommandRequest) (commands.PairBatchCommand, error) {
+	switch command.Type {
+	case commands.PairBatchConfirmWallet:
+		req := confirmPairWalletRequest{PairId: pairId}
+		if err := decodeBatchPayload(c, command.Payload, &req); err != nil {
+			return commands.PairBatchCommand{}, err
+		}
+		return commands.PairBatchCommand{ConfirmPairWallet: &commands.ConfirmPairWallet{
+			PairId:               req.PairId,
+			ParticipantAddress:   auth.Address,
+			ParticipantPublicKey: req.ParticipantPublicKey,
+			WalletAddresses:      req.WalletAddresses,
+		}}, nil
+	case commands.PairBatchSetAssurances:
+		req := setPairAssurancesRequest{PairId: pairId}
+		if err := decodeBatchPayload(c, command.Payload, &req); err != nil {
+			return commands.PairBatchCommand{}, err
+		}
+		return commands.PairBatchCommand{SetPairAssurances: &commands.SetPairAssurances{
+			PairId:             req.PairId,
+			ParticipantAddress: auth.Address,
+			Asset:              req.Asset,
+			Assurances:         req.Assurances,
+		}}, nil
+	case commands.PairBatchConfirmAssurances:
+		req := confirmAssurancesRequest{PairId: pairId}
+		if err := decodeBatchPayload(c, command.Payload, &req); err != nil {
+			return commands.PairBatchCommand{}, err
+		}
+		return commands.PairBatchCommand{ConfirmAssurances: &commands.ConfirmAssurances{
+			PairId:             req.PairId,
+			ParticipantAddress: auth.Address,
+			Signature:          req.Signature,
+		}}, nil
+	}
+
+	return commands.PairBatchCommand{}, echo.NewHTTPError(http.StatusBadRequest, "unsupported command type "+command.Type)
+}
+
+func decodeBatchPayload(c echo.Context, payload json.RawMessage, req interface{}) error {
+	if err := json.Unmarshal(payload, req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	return c.Validate(req)
+}
+
+// batchPayloadError reports the invalid payloads by their path in the batch, e.g. {"commands[1].payload.asset": "is required"}
+func batchPayloadError(index int, err error) error {
+	var validationErr validator.ValidationErrors
+	if !errors.As(err, &validationErr) {
+		return err
+	}
+
+	meta := make(map[string]interface{})
+	for field, message := range common.ErrorFromValidationErrors(validationErr).Meta {
+		meta[fmt.Sprintf("commands[%d].payload.%s", index, field)] = message
+	}
+
+	return common.NewError("invalid_request", "validation error").IncludeMeta(meta)
+}
@@ -0,0 +1,76 @@
+package ports
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/co-defi/api-server/app/commands"
+	"github.com/labstack/echo/v4"
+)
+
+type postPairMessageRequest struct {
+	PairId string `param:"id" json:"-" validate:"required,uuid4"`
+	Body   string `json:"body,omitempty" validate:"required,max=2000"`
+}
+
+type postPairMessageResponse struct {
+	Seq int `json:"seq"`
+}
+
+func (s *HttpServer) postPairMessage(c echo.Context) error {
+	var req postPairMessageRequest
+	if err := bindRequest(c, &req); err != nil {
+		return err
+	}
+
+	auth, err := s.authDB.ExtractTokenFromHttp(c.Request())
+	if err != nil {
+		return err
+	}
+
+	seq, err := s.app.Commands.PostPairMessage.Handle(c.Request().Context(), commands.PostPairMessage{
+		PairId:             req.PairId,
+		ParticipantAddress: auth.Address,
+		Body:               req.Body,
+	})
+	if err != nil {
+		return err
+	}
+	n, _ := strconv.Atoi(seq)
+
+	return c.JSON(http.StatusOK, postPairMessageResponse{Seq: n})
+}
+
+type getPairMessagesRequest struct {
+	PairId string `param:"id" validate:"required,uuid4"`
+	After  int    `query:"after" validate:"min=0"`
+	Limit  int    `query:"limit" validate:"omitempty,min=1,max=1000"`
+}
+
+// getPairMessages returns the chat of the pair to its participants, the messages are polled by sequence number
+func (s *HttpServer) getPairMessages(c echo.Context) error {
+	var req getPairMessagesRequest
+	if err := bindRequest(c, &req); err != nil {
+		return err
+	}
+
+	auth, err := s.authDB.ExtractTokenFromHttp(c.Request())
+	if err != nil {
+		return err
+	}
+
+	pair, err := s.app.Queries.Pairs.Get(c.Request().Context(), req.PairId)
+	if err != nil {
+		return err
+	}
+	if !pairHasAddress(pair, auth.Address) {
+		return commands.ErrForbiddenPairForAddress
+	}
+
+	messages, err := s.app.Queries.PairMessages.Find(c.Request().Context(), req.PairId, req.After, req.Limit)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, messages)
+}
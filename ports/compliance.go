@@ -0,0 +1,30 @@
+package ports
+
+import (
+	"github.com/co-defi/api-server/common"
+	"github.com/co-defi/api-server/domain"
+	"github.com/labstack/echo/v4"
+)
+
+// WithComplianceAtAuth requires the participants to pass the compliance checks of the application to authenticate,
+// by default they are only checked when they create or match pairs
+func (s *HttpServer) WithComplianceAtAuth() {
+	s.complianceAtAuth = true
+}
+
+// requireCompliance revokes the verified token and fails when its participant doesn't pass the compliance checks.
+// The participants are checked once they proved they own their address, so the provider isn't queried for any address.
+func (s *HttpServer) requireCompliance(c echo.Context, token common.Token) error {
+	if !s.complianceAtAuth {
+		return nil
+	}
+
+	if err := s.app.Compliance.Require(c.Request().Context(), domain.Address(token.Address)); err != nil {
+		if revokeErr := s.authDB.Revoke(token.Chain, token.Address, token.Id); revokeErr != nil {
+			return revokeErr
+		}
+		return err
+	}
+
+	return nil
+}
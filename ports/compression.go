@@ -0,0 +1,232 @@
+package ports
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// defaultCompressionMinSize is the size in bytes from which the responses are compressed, the smaller ones aren't worth it
+	defaultCompressionMinSize = 1 << 10
+	// defaultGzipLevel and defaultBrotliLevel trade some compression for speed, as the responses are compressed on the fly
+	defaultGzipLevel   = gzip.DefaultCompression
+	defaultBrotliLevel = 4
+)
+
+// Compression configures the compression of the responses
+type Compression struct {
+	// MinSize is the size in bytes from which the responses are compressed, the compression is disabled when negative
+	MinSize     int
+	GzipLevel   int
+	BrotliLevel int
+	// UncompressedRoutes are the route paths without the API version whose responses are never compressed (e.g. /pairs/:id/wait)
+	UncompressedRoutes []string
+}
+
+// DefaultCompression is the compression of the responses unless configured otherwise
+var DefaultCompression = Compression{
+	MinSize:     defaultCompressionMinSize,
+	GzipLevel:   defaultGzipLevel,
+	BrotliLevel: defaultBrotliLevel,
+}
+
+// WithCompression sets how the responses are compressed
+func (s *HttpServer) WithCompression(compression Compression) error {
+	if compression.GzipLevel < gzip.HuffmanOnly || compression.GzipLevel > gzip.BestCompression {
+		return errors.New("gzip level must be between -2 and 9")
+	}
+	if compression.BrotliLevel < brotli.BestSpeed || compression.BrotliLevel > brotli.BestCompression {
+		return errors.New("brotli level must be between 0 and 11")
+	}
+
+	s.compression = compression
+	s.uncompressedRoutes = make(map[string]bool, len(compression.UncompressedRoutes))
+	for _, route := range compression.UncompressedRoutes {
+		s.uncompressedRoutes[route] = true
+	}
+
+	return nil
+}
+
+// compressResponses is a middleware compressing the responses with brotli or gzip, as accepted by the client.
+// The responses are buffered up to the minimum size, so the smaller ones are sent as is.
+func (s *HttpServer) compressResponses(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if s.compression.MinSize < 0 || c.Request().Method == http.MethodHead || s.uncompressedRoutes[unversionedPath(c.Path())] {
+			return next(c)
+		}
+
+		res := c.Response()
+		res.Header().Add(echo.HeaderVary, echo.HeaderAcceptEncoding)
+		encoding := negotiateContentEncoding(c.Request().Header.Get(echo.HeaderAcceptEncoding))
+		if encoding == "" {
+			return next(c)
+		}
+
+		original := res.Writer
+		w := &compressedResponse{ResponseWriter: original, encoding: encoding, compression: s.compression, status: http.StatusOK}
+		res.Writer = w
+		defer func() {
+			res.Writer = original
+		}()
+
+		err := next(c)
+		if cerr := w.finish(); cerr != nil && err == nil {
+			err = cerr
+		}
+
+		return err
+	}
+}
+
+// negotiateContentEncoding picks brotli or gzip by the Accept-Encoding header, brotli is preferred at the same quality
+func negotiateContentEncoding(acceptEncoding string) string {
+	var best string
+	var bestQ float64
+	for _, coding := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(coding, ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+				q = parsed
+			}
+		}
+		if q <= 0 || (name != "br" && name != "gzip") {
+			continue
+		}
+		if q > bestQ || (q == bestQ && name == "br") {
+			best, bestQ = name, q
+		}
+	}
+
+	return best
+}
+
+// compressedResponse buffers the response until it reaches the minimum size, then compresses it through the encoder
+type compressedResponse struct {
+	http.ResponseWriter
+	encoding    string
+	compression Compression
+
+	status      int
+	wroteHeader bool
+	buf         bytes.Buffer
+	encoder     io.WriteCloser
+	// started is set once the header is written, the response is compressed from then on when encoder is set
+	started bool
+}
+
+func (w *compressedResponse) WriteHeader(status int) {
+	w.status = status
+	w.wroteHeader = true
+}
+
+func (w *compressedResponse) Write(b []byte) (int, error) {
+	if w.started {
+		if w.encoder != nil {
+			return w.encoder.Write(b)
+		}
+		return w.ResponseWriter.Write(b)
+	}
+
+	w.buf.Write(b)
+	if w.buf.Len() >= w.compression.MinSize {
+		if err := w.start(true); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(b), nil
+}
+
+// start writes the header of the response and the bytes buffered so far, compressed or not
+func (w *compressedResponse) start(compress bool) error {
+	w.started = true
+	h := w.Header()
+	if h.Get(echo.HeaderContentEncoding) != "" || w.status == http.StatusNoContent || w.status == http.StatusNotModified {
+		compress = false
+	}
+
+	if compress {
+		h.Set(echo.HeaderContentEncoding, w.encoding)
+		h.Del(echo.HeaderContentLength)
+		switch w.encoding {
+		case "br":
+			w.encoder = brotli.NewWriterLevel(w.ResponseWriter, w.compression.BrotliLevel)
+		default:
+			// The level is checked by WithCompression
+			w.encoder, _ = gzip.NewWriterLevel(w.ResponseWriter, w.compression.GzipLevel)
+		}
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+
+	if w.buf.Len() == 0 {
+		return nil
+	}
+	var err error
+	if w.encoder != nil {
+		_, err = w.encoder.Write(w.buf.Bytes())
+	} else {
+		_, err = w.ResponseWriter.Write(w.buf.Bytes())
+	}
+	w.buf.Reset()
+
+	return err
+}
+
+// finish sends the responses smaller than the minimum size as is and flushes the compressed ones
+func (w *compressedResponse) finish() error {
+	if !w.started {
+		// Nothing was written, the error handler writes the response itself
+		if w.buf.Len() == 0 && !w.wroteHeader {
+			return nil
+		}
+		return w.start(false)
+	}
+	if w.encoder != nil {
+		return w.encoder.Close()
+	}
+
+	return nil
+}
+
+// Flush sends what's buffered so far, compressed from then on if it's worth it
+func (w *compressedResponse) Flush() {
+	if !w.started {
+		if err := w.start(w.buf.Len() >= w.compression.MinSize); err != nil {
+			return
+		}
+	}
+	if flusher, ok := w.encoder.(interface{ Flush() error }); ok {
+		flusher.Flush()
+	}
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack hands the connection over, e.g. for websockets, which are never compressed
+func (w *compressedResponse) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response writer doesn't support hijacking")
+	}
+
+	return hijacker.Hijack()
+}
+
+func (w *compressedResponse) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
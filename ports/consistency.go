@@ -0,0 +1,43 @@
+package ports
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// readYourWritesTimeout is how long a command waits for the pairs projection with wait_for_projection=true
+const readYourWritesTimeout = 5 * time.Second
+
+// respondToPairCommand responds to a command that changed the pair. When the client asks for wait_for_projection=true,
+// the response waits for the pairs projection to reflect the command and serves the resulting state of the pair instead,
+// so the pair can be read right after it's changed. The command's own response is served when the projection doesn't catch up in time.
+func (s *HttpServer) respondToPairCommand(c echo.Context, pairId string, status int, own interface{}) error {
+	respondOwn := func() error {
+		if own == nil {
+			return c.NoContent(status)
+		}
+		return c.JSON(status, own)
+	}
+	if wait, _ := strconv.ParseBool(c.QueryParam("wait_for_projection")); !wait {
+		return respondOwn()
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), readYourWritesTimeout)
+	defer cancel()
+
+	pair, err := s.app.WaitForPair(ctx, pairId)
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		s.logger.Warn().Str("pair_id", pairId).Msg("pairs projection didn't catch up with the command in time")
+		return respondOwn()
+	case err != nil:
+		return err
+	}
+
+	return respond(c, http.StatusOK, pair)
+}
@@ -0,0 +1,43 @@
+package ports
+
+import (
+	"embed"
+	"io"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// dashboardFiles are the static files of the admin dashboard, a page calling the admin routes with the token of the operator
+//
+//go:embed dashboard
+var dashboardFiles embed.FS
+
+var dashboardFS = echo.MustSubFS(dashboardFiles, "dashboard")
+
+// redirectDashboard redirects to the index of the dashboard, so the page loads its files relatively to its own path
+func (s *HttpServer) redirectDashboard(c echo.Context) error {
+	return c.Redirect(http.StatusFound, c.Request().URL.Path+"/")
+}
+
+// serveDashboard serves the static files of the dashboard, the index for the dashboard path itself
+func (s *HttpServer) serveDashboard(c echo.Context) error {
+	file := c.Param("*")
+	if file == "" {
+		file = "index.html"
+	}
+
+	f, err := dashboardFS.Open(file)
+	if err != nil {
+		return echo.ErrNotFound
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		return echo.ErrNotFound
+	}
+
+	c.Response().Header().Set(echo.HeaderCacheControl, "no-cache")
+	http.ServeContent(c.Response(), c.Request(), info.Name(), info.ModTime(), f.(io.ReadSeeker))
+	return nil
+}
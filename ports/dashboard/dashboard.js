@@ -0,0 +1,169 @@
+"use strict";
+
+// The admin routes are served next to the dashboard, under the same version of the API
+const adminPath = location.pathname.replace(/\/ui\/.*$/, "");
+const tokenKey = "co-defi-admin-token";
+const refreshInterval = 15000;
+
+let refreshTimer;
+
+async function getJSON(path) {
+  const res = await fetch(adminPath + path, {
+    headers: { "X-Admin-Token": sessionStorage.getItem(tokenKey) || "" },
+  });
+  const body = await res.json().catch(() => ({}));
+  if (res.status === 401 || res.status === 403) {
+    signOut();
+  }
+  if (!res.ok) {
+    throw new Error(`${path}: ${body.message || res.statusText}`);
+  }
+  return body;
+}
+
+// renderTable fills the table with a row per item, columns are [header, value of the item, warn of the item]
+function renderTable(id, columns, items) {
+  const table = document.getElementById(id);
+  table.replaceChildren();
+
+  const head = table.createTHead().insertRow();
+  for (const [header] of columns) {
+    const th = document.createElement("th");
+    th.textContent = header;
+    head.appendChild(th);
+  }
+
+  const body = table.createTBody();
+  if (items.length === 0) {
+    const cell = body.insertRow().insertCell();
+    cell.colSpan = columns.length;
+    cell.className = "empty";
+    cell.textContent = "None";
+    return;
+  }
+  for (const item of items) {
+    const row = body.insertRow();
+    for (const [, value, warn] of columns) {
+      const cell = row.insertCell();
+      const text = value(item);
+      cell.textContent = text === undefined || text === null || text === "" ? "-" : String(text);
+      if (warn && warn(item)) {
+        cell.className = "warn";
+      }
+    }
+  }
+}
+
+function formatTime(value) {
+  return value ? new Date(value).toLocaleString() : "";
+}
+
+async function loadProjections() {
+  const projections = await getJSON("/projections");
+  renderTable("projections", [
+    ["Name", (p) => p.name],
+    ["Last handled", (p) => p.last_handled],
+    ["Head", (p) => p.head],
+    ["Lag", (p) => p.lag, (p) => p.lag > 0],
+    ["Last run", (p) => formatTime(p.last_run_at)],
+    ["Resetting", (p) => (p.resetting ? "yes" : "no")],
+    ["Last error", (p) => p.last_error, (p) => Boolean(p.last_error)],
+  ], projections);
+}
+
+async function loadStuckPairs() {
+  const pairs = await getJSON("/pairs/stuck");
+  renderTable("stuck-pairs", [
+    ["Pair", (p) => p.id],
+    ["Status", (p) => p.status],
+    ["Network", (p) => p.network],
+    ["In status since", (p) => formatTime(p.status_entered_at)],
+    ["Timeout", (p) => p.timeout],
+    ["Overdue by", (p) => p.overdue_by, () => true],
+  ], pairs);
+}
+
+async function loadLatestPairs() {
+  const pairs = await getJSON("/pairs/latest?limit=20");
+  renderTable("latest-pairs", [
+    ["Pair", (p) => p.id],
+    ["Type", (p) => p.plan_type],
+    ["Assets", (p) => (p.assets || []).join(" / ")],
+    ["Status", (p) => p.status],
+    ["Network", (p) => p.network],
+    ["Created", (p) => formatTime(p.created_at)],
+    ["Updated", (p) => formatTime(p.updated_at)],
+  ], pairs);
+}
+
+async function loadDeadLetters() {
+  const { counts, letters } = await getJSON("/dead-letters?limit=20");
+  const summary = Object.entries(counts || {}).map(([projection, count]) => `${projection}: ${count}`);
+  document.getElementById("dead-letter-counts").textContent =
+    summary.length ? summary.join(", ") : "No events were parked.";
+  renderTable("dead-letters", [
+    ["Projection", (l) => l.projection],
+    ["Global version", (l) => l.global_version],
+    ["Aggregate", (l) => `${l.aggregate_type} ${l.aggregate_id}`],
+    ["Reason", (l) => l.reason],
+    ["Error", (l) => l.error],
+    ["Attempts", (l) => l.attempts],
+    ["Parked", (l) => formatTime(l.created_at)],
+  ], letters || []);
+}
+
+async function loadFeatures() {
+  const features = await getJSON("/features");
+  renderTable("features", [
+    ["Feature", ([name]) => name.replaceAll("_", " ")],
+    ["Enabled", ([, enabled]) => (enabled ? "yes" : "no")],
+  ], Object.entries(features));
+}
+
+async function refresh() {
+  const status = document.getElementById("status");
+  const results = await Promise.allSettled([
+    loadProjections(), loadStuckPairs(), loadLatestPairs(), loadDeadLetters(), loadFeatures(),
+  ]);
+  const failures = results.filter((r) => r.status === "rejected").map((r) => r.reason.message);
+  status.className = failures.length ? "error" : "";
+  status.textContent = failures.length
+    ? failures.join("; ")
+    : `Updated ${new Date().toLocaleTimeString()}`;
+}
+
+function show(signedIn) {
+  document.getElementById("sign-in").hidden = signedIn;
+  document.getElementById("dashboard").hidden = !signedIn;
+  document.getElementById("refresh").hidden = !signedIn;
+  document.getElementById("sign-out").hidden = !signedIn;
+}
+
+function signIn() {
+  show(true);
+  refresh();
+  clearInterval(refreshTimer);
+  refreshTimer = setInterval(refresh, refreshInterval);
+}
+
+function signOut() {
+  sessionStorage.removeItem(tokenKey);
+  clearInterval(refreshTimer);
+  show(false);
+}
+
+document.getElementById("sign-in").addEventListener("submit", (event) => {
+  event.preventDefault();
+  const input = document.getElementById("token");
+  sessionStorage.setItem(tokenKey, input.value);
+  input.value = "";
+  signIn();
+});
+document.getElementById("refresh").addEventListener("click", refresh);
+document.getElementById("sign-out").addEventListener("click", signOut);
+
+if (sessionStorage.getItem(tokenKey)) {
+  signIn();
+} else {
+  show(false);
+}
@@ -0,0 +1,67 @@
+package ports
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/co-defi/api-server/common"
+	"github.com/labstack/echo/v4"
+)
+
+// msgpackMediaTypes are the media types of the Accept header asking for MessagePack, application/x-msgpack is still sent by older libraries
+var msgpackMediaTypes = map[string]bool{
+	common.MIMEApplicationMsgpack: true,
+	"application/x-msgpack":       true,
+}
+
+// acceptsMsgpack tells whether the client prefers MessagePack over JSON by the Accept header,
+// JSON is kept for the clients accepting both with the same quality
+func acceptsMsgpack(accept string) bool {
+	var msgpackQ, jsonQ float64
+	for _, mediaRange := range strings.Split(accept, ",") {
+		mediaType, params, _ := strings.Cut(mediaRange, ";")
+		mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if name == "q" {
+				if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+
+		switch {
+		case msgpackMediaTypes[mediaType]:
+			msgpackQ = max(msgpackQ, q)
+		case mediaType == echo.MIMEApplicationJSON, mediaType == "application/*", mediaType == "*/*":
+			jsonQ = max(jsonQ, q)
+		}
+	}
+
+	return msgpackQ > 0 && msgpackQ > jsonQ
+}
+
+// encodeResponse encodes v in the encoding negotiated with the Accept header of the request, MessagePack or JSON by default
+func encodeResponse(c echo.Context, v interface{}) (string, []byte, error) {
+	c.Response().Header().Add(echo.HeaderVary, echo.HeaderAccept)
+	if acceptsMsgpack(c.Request().Header.Get(echo.HeaderAccept)) {
+		body, err := common.MarshalMsgpack(v)
+		return common.MIMEApplicationMsgpack, body, err
+	}
+
+	body, err := json.Marshal(v)
+	return echo.MIMEApplicationJSON, body, err
+}
+
+// respond sends v with the status in the encoding negotiated with the Accept header of the request
+func respond(c echo.Context, status int, v interface{}) error {
+	contentType, body, err := encodeResponse(c, v)
+	if err != nil {
+		return err
+	}
+
+	return c.Blob(status, contentType, body)
+}
@@ -0,0 +1,140 @@
+package ports
+
+import (
+	"net/http"
+
+	"github.com/co-defi/api-server/app/commands"
+	"github.com/labstack/echo/v4"
+)
+
+// The admin routes of the escrow are all mutating but getEscrow, so every access to a key share is in the audit log
+// along with the events of its escrow
+
+type escrowRequest struct {
+	PairId string `param:"pair_id" validate:"required,uuid4"`
+}
+
+type escrowResponse struct {
+	PairId string `json:"pair_id"`
+}
+
+func (s *HttpServer) escrowMediatorShare(c echo.Context) error {
+	var req escrowRequest
+	if err := bindRequest(c, &req); err != nil {
+		return err
+	}
+
+	id, err := s.app.Commands.EscrowMediatorShare.Handle(c.Request().Context(), commands.EscrowMediatorShare{
+		PairId:   req.PairId,
+		Operator: c.Get(adminOperatorKey).(string),
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusCreated, escrowResponse{PairId: id})
+}
+
+func (s *HttpServer) getEscrow(c echo.Context) error {
+	var req escrowRequest
+	if err := bindRequest(c, &req); err != nil {
+		return err
+	}
+
+	escrow, err := s.app.Queries.Escrows.Get(c.Request().Context(), req.PairId)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, escrow)
+}
+
+type requestShareRecoveryRequest struct {
+	PairId string `param:"pair_id" json:"-" validate:"required,uuid4"`
+	Reason string `json:"reason" validate:"required,max=1000"`
+}
+
+type shareRecoveryResponse struct {
+	RecoveryId string `json:"recovery_id"`
+}
+
+func (s *HttpServer) requestShareRecovery(c echo.Context) error {
+	var req requestShareRecoveryRequest
+	if err := bindRequest(c, &req); err != nil {
+		return err
+	}
+
+	id, err := s.app.Commands.RequestShareRecovery.Handle(c.Request().Context(), commands.RequestShareRecovery{
+		PairId:   req.PairId,
+		Reason:   req.Reason,
+		Operator: c.Get(adminOperatorKey).(string),
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusCreated, shareRecoveryResponse{RecoveryId: id})
+}
+
+type decideShareRecoveryRequest struct {
+	PairId     string `param:"pair_id" json:"-" validate:"required,uuid4"`
+	RecoveryId string `param:"id" json:"-" validate:"required,uuid4"`
+	Reason     string `json:"reason" validate:"max=1000"`
+}
+
+func (s *HttpServer) approveShareRecovery(c echo.Context) error {
+	return s.decideShareRecovery(c, false)
+}
+
+func (s *HttpServer) rejectShareRecovery(c echo.Context) error {
+	return s.decideShareRecovery(c, true)
+}
+
+func (s *HttpServer) decideShareRecovery(c echo.Context, reject bool) error {
+	var req decideShareRecoveryRequest
+	if err := bindRequest(c, &req); err != nil {
+		return err
+	}
+
+	id, err := s.app.Commands.DecideShareRecovery.Handle(c.Request().Context(), commands.DecideShareRecovery{
+		PairId:     req.PairId,
+		RecoveryId: req.RecoveryId,
+		Reject:     reject,
+		Reason:     req.Reason,
+		Operator:   c.Get(adminOperatorKey).(string),
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, shareRecoveryResponse{RecoveryId: id})
+}
+
+type retrieveMediatorShareRequest struct {
+	PairId     string `param:"pair_id" validate:"required,uuid4"`
+	RecoveryId string `param:"id" validate:"required,uuid4"`
+}
+
+type retrieveMediatorShareResponse struct {
+	PairId string `json:"pair_id"`
+	Share  string `json:"share"`
+}
+
+func (s *HttpServer) retrieveMediatorShare(c echo.Context) error {
+	var req retrieveMediatorShareRequest
+	if err := bindRequest(c, &req); err != nil {
+		return err
+	}
+
+	share, err := s.app.Commands.RetrieveMediatorShare.Handle(c.Request().Context(), commands.RetrieveMediatorShare{
+		PairId:     req.PairId,
+		RecoveryId: req.RecoveryId,
+		Operator:   c.Get(adminOperatorKey).(string),
+	})
+	if err != nil {
+		return err
+	}
+
+	c.Response().Header().Set(echo.HeaderCacheControl, "no-store")
+	return c.JSON(http.StatusOK, retrieveMediatorShareResponse{PairId: req.PairId, Share: share})
+}
@@ -0,0 +1,68 @@
+package ports
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/co-defi/api-server/app"
+	"github.com/labstack/echo/v4"
+)
+
+// headerNextAfterSeq is the cursor of the next page of the event feed, to be sent back as after_seq
+const headerNextAfterSeq = "X-Next-After-Seq"
+
+type getEventsRequest struct {
+	AfterSeq uint64 `query:"after_seq"`
+	Limit    int    `query:"limit" validate:"omitempty,min=1,max=1000"`
+	// Types and AggregateTypes are repeated or comma separated, e.g. type=PairMatched,PairStatusChanged
+	Types          []string `query:"type"`
+	AggregateTypes []string `query:"aggregate_type"`
+}
+
+// getEvents serves a page of the stored events in global order as newline-delimited JSON.
+// The events filtered out are skipped by the cursor too, so the consumers always resume from X-Next-After-Seq.
+func (s *HttpServer) getEvents(c echo.Context) error {
+	var req getEventsRequest
+	if err := bindRequest(c, &req); err != nil {
+		return err
+	}
+
+	page, err := s.app.EventFeed(app.EventFeedFilter{
+		AfterSeq:       req.AfterSeq,
+		Limit:          req.Limit,
+		Types:          splitValues(req.Types),
+		AggregateTypes: splitValues(req.AggregateTypes),
+	})
+	if err != nil {
+		return err
+	}
+
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, "application/x-ndjson")
+	res.Header().Set(headerNextAfterSeq, strconv.FormatUint(page.NextAfterSeq, 10))
+	res.WriteHeader(http.StatusOK)
+
+	enc := json.NewEncoder(res)
+	for _, e := range page.Events {
+		if err := enc.Encode(e); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// splitValues splits the comma separated values of a repeated query parameter
+func splitValues(values []string) []string {
+	var split []string
+	for _, v := range values {
+		for _, s := range strings.Split(v, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				split = append(split, s)
+			}
+		}
+	}
+	return split
+}
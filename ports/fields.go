@@ -0,0 +1,117 @@
+package ports
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/co-defi/api-server/common"
+	"github.com/labstack/echo/v4"
+)
+
+// fieldsParam is the query parameter selecting the fields of the responses, e.g. ?fields=id,status,deadline
+const fieldsParam = "fields"
+
+// ErrUnknownField is returned when the fields selected aren't fields of the response
+var ErrUnknownField = common.NewError("invalid_request", "unknown field selected")
+
+// sparseFields keeps the top-level fields of v selected by the fields query parameter, named as in the JSON responses.
+// v is a struct, a pointer to one or a slice of them, it's returned as is without the parameter.
+func sparseFields(c echo.Context, v interface{}) (interface{}, error) {
+	param := c.QueryParam(fieldsParam)
+	if param == "" {
+		return v, nil
+	}
+
+	selected := make(map[string]bool)
+	for _, field := range strings.Split(param, ",") {
+		if field = strings.TrimSpace(field); field != "" {
+			selected[field] = true
+		}
+	}
+
+	return selectFields(reflect.ValueOf(v), selected)
+}
+
+func selectFields(v reflect.Value, selected map[string]bool) (interface{}, error) {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return nil, nil
+		}
+		return selectFields(v.Elem(), selected)
+	case reflect.Slice, reflect.Array:
+		items := make([]interface{}, v.Len())
+		for i := range items {
+			item, err := selectFields(v.Index(i), selected)
+			if err != nil {
+				return nil, err
+			}
+			items[i] = item
+		}
+		return items, nil
+	case reflect.Struct:
+		fields := make(map[string]interface{}, len(selected))
+		known := make(map[string]bool)
+		collectFields(v, selected, fields, known)
+		for field := range selected {
+			if !known[field] {
+				return nil, ErrUnknownField.IncludeMeta(map[string]interface{}{fieldsParam: fmt.Sprintf("%s is not a field of the response", field)})
+			}
+		}
+		return fields, nil
+	}
+
+	return nil, fmt.Errorf("can't select fields of %s", v.Type())
+}
+
+// collectFields collects the selected fields of the struct by their JSON names, the fields omitted when empty in JSON are omitted too
+func collectFields(v reflect.Value, selected map[string]bool, fields map[string]interface{}, known map[string]bool) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			collectFields(v.Field(i), selected, fields, known)
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		known[name] = true
+		value := v.Field(i)
+		if !selected[name] || (strings.Contains(opts, "omitempty") && isEmptyValue(value)) {
+			continue
+		}
+		fields[name] = value.Interface()
+	}
+}
+
+// isEmptyValue tells whether encoding/json omits the value of a field tagged with omitempty
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Pointer:
+		return v.IsNil()
+	}
+
+	return false
+}
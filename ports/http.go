@@ -1,9 +1,15 @@
 package ports
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
+	"expvar"
+	"fmt"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/google/uuid"
@@ -16,166 +22,1162 @@ import (
 	"github.com/co-defi/api-server/domain"
 	"github.com/labstack/echo/v4"
 	"github.com/rs/zerolog"
+	"golang.org/x/crypto/acme/autocert"
 )
 
 // HttpServer is a HTTP server that listens for incoming REST requests
 // and routes them to the appropriate command and query handlers.
 type HttpServer struct {
-	app    *app.Application
-	authDB *common.AuthenticationDB
-	echo   *echo.Echo
-	logger zerolog.Logger
+	app         *app.Application
+	authDB      *common.AuthenticationDB
+	adminTokens map[string]string
+	cors        echo.MiddlewareFunc
+	hstsMaxAge  int
+	echo        *echo.Echo
+	logger      zerolog.Logger
+	// logLevels are the levels of the logs the operators change at runtime, the routes are disabled when nil
+	logLevels *common.LogLevels
+
+	// bodyLimit is the maximum size of the request bodies, unless routeBodyLimits sets another one for their route
+	bodyLimit       int64
+	routeBodyLimits map[string]int64
+	// requestTimeout is how long the requests may take, unless routeTimeouts sets another timeout for their route
+	requestTimeout time.Duration
+	routeTimeouts  map[string]time.Duration
+	// routePolicies are who may call the routes by route path without the API version, see routePolicy
+	routePolicies map[string]RoutePolicy
+	// signer signs the responses of the pair state routes, they are sent unsigned when nil
+	signer *common.ResponseSigner
+	// compression is how the responses are compressed, except for the responses of uncompressedRoutes
+	compression        Compression
+	uncompressedRoutes map[string]bool
+	// complianceAtAuth requires the participants to pass the compliance checks to authenticate, not only to invest
+	complianceAtAuth bool
 }
 
 // NewHttpServer creates a new HTTP server
 func NewHttpServer(a *app.Application) *HttpServer {
 	e := echo.New()
-	e.Use(middleware.Recover())
-	e.Use(middleware.Logger())
-	e.Use(middleware.CORS())
-
 	s := HttpServer{
 		app:    a,
-		authDB: common.NewAuthenticationDB(),
+		authDB: common.NewAuthenticationDB(a.Clock),
+		cors:   middleware.CORS(),
 		echo:   e,
 		logger: zerolog.Nop(),
+
+		bodyLimit:       defaultBodyLimit,
+		routeBodyLimits: make(map[string]int64, len(defaultRouteBodyLimits)),
+		requestTimeout:  defaultRequestTimeout,
+		routeTimeouts:   make(map[string]time.Duration, len(defaultRouteTimeouts)),
+		routePolicies:   make(map[string]RoutePolicy, len(defaultRoutePolicies)),
+		compression:     DefaultCompression,
+	}
+	for route, limit := range defaultRouteBodyLimits {
+		s.routeBodyLimits[route] = limit
 	}
+	for route, timeout := range defaultRouteTimeouts {
+		s.routeTimeouts[route] = timeout
+	}
+	for route, policy := range defaultRoutePolicies {
+		s.routePolicies[route] = policy
+	}
+	s.authDB.EnableAPIKeys(a.APIKeys)
+
+	e.Pre(s.negotiateVersion)
+	e.Use(middleware.RequestID())
+	e.Use(middleware.Recover())
+	e.Use(middleware.RequestLoggerWithConfig(middleware.RequestLoggerConfig{
+		LogRequestID:     true,
+		LogRemoteIP:      true,
+		LogHost:          true,
+		LogMethod:        true,
+		LogURI:           true,
+		LogUserAgent:     true,
+		LogStatus:        true,
+		LogError:         true,
+		LogLatency:       true,
+		LogContentLength: true,
+		LogResponseSize:  true,
+		LogValuesFunc:    s.logRequest,
+	}))
+	e.Use(s.compressResponses)
+	e.Use(s.handleCORS)
+	e.Use(s.hardenResponses)
+	e.Use(s.enforcePolicies)
+	e.Use(s.limitBodies)
+	e.Use(s.timeoutRequests)
+	e.Use(s.auditMutations)
 	s.registerRoutes()
 	s.echo.HTTPErrorHandler = s.handleError
+	s.echo.Validator = requestValidator{}
+
+	return &s
+}
+
+func (s *HttpServer) registerRoutes() {
+	for version, register := range apiVersions {
+		register(s, s.echo.Group("/"+version))
+	}
+}
+
+// registerV1Routes registers the routes of the first version of the API
+func (s *HttpServer) registerV1Routes(g *echo.Group) {
+	g.POST("/auth/init", s.initAuth)
+	g.POST("/auth/verify", s.verifyAuth)
+	g.GET("/auth/sessions", s.getSessions)
+	g.DELETE("/auth/sessions/:id", s.revokeSession)
+
+	g.GET("/server-key", s.getServerKey)
+	g.GET("/assets", s.getAssets)
+	g.GET("/fees", s.getFees)
+
+	g.GET("/plans", s.getPlans)
+	g.GET("/plan/:id", s.getPlan)
+
+	g.POST(("/pairs"), s.createOrMatchPair)
+	g.GET("/pairs/:id", s.getPair, s.signResponses)
+	g.GET("/pairs/:id/wait", s.waitForPair, s.signResponses)
+	g.GET("/pairs/:id/settlement", s.getSettlement, s.signResponses)
+	g.GET("/pairs/:id/position", s.getPosition)
+	g.GET("/pairs", s.getPairs)
+	g.POST("/pairs/:id/confirm-wallet", s.confirmPairWallet, s.requirePairNetwork)
+	g.POST("/pairs/:id/keygen-failure", s.reportKeygenFailure, s.requirePairNetwork)
+	g.POST("/pairs/:id/assurances", s.setPairAssurances, s.requirePairNetwork)
+	g.GET("/pairs/:id/assurances/acknowledgement", s.getAssurancesAcknowledgement, s.requirePairNetwork)
+	g.POST("/pairs/:id/confirm-assurances", s.confirmAssurances, s.requirePairNetwork)
+	g.POST("/pairs/:id/batch", s.batchPair, s.requirePairNetwork)
+	g.POST("/pairs/:id/deposits", s.addDeposit, s.requirePairNetwork)
+	g.POST("/pairs/:id/sign-withdraw", s.signWithdrawal, s.requirePairNetwork)
+	g.POST("/pairs/:id/submit-lp", s.submitLP, s.requirePairNetwork)
+	g.POST("/pairs/:id/submit-withdrawal", s.submitWithdrawal, s.requirePairNetwork)
+	g.POST("/pairs/:id/sign-savers-withdraw", s.signSaversWithdrawal, s.requirePairNetwork)
+	g.POST("/pairs/:id/submit-savers", s.submitSavers, s.requirePairNetwork)
+	g.POST("/pairs/:id/submit-savers-withdrawal", s.submitSaversWithdrawal, s.requirePairNetwork)
+	g.POST("/pairs/:id/refund", s.requestRefund, s.requirePairNetwork)
+	g.POST("/pairs/:id/early-withdrawal", s.proposeEarlyWithdrawal, s.requirePairNetwork)
+	g.POST("/pairs/:id/early-withdrawal/accept", s.acceptEarlyWithdrawal, s.requirePairNetwork)
+	g.POST("/pairs/:id/extension", s.proposeExtension, s.requirePairNetwork)
+	g.POST("/pairs/:id/extension/accept", s.acceptExtension, s.requirePairNetwork)
+	g.POST("/pairs/:id/relay", s.postRelayMessage, s.requirePairNetwork)
+	g.GET("/pairs/:id/relay", s.getRelayMessages, s.requirePairNetwork)
+	g.POST("/pairs/:id/messages", s.postPairMessage, s.requirePairNetwork)
+	g.GET("/pairs/:id/messages", s.getPairMessages, s.requirePairNetwork)
+
+	g.GET("/participants/:address/reputation", s.getReputation)
+
+	g.GET("/stats", s.getStats)
+	g.GET("/stats/timeseries", s.getStatsTimeseries)
+
+	g.GET("/me", s.getMe)
+	g.GET("/me/stream", s.streamMyPairs)
+	g.POST("/me/addresses", s.linkAddress)
+	g.GET("/me/notifications", s.getNotificationSettings)
+	g.PUT("/me/notifications", s.updateNotificationSettings)
+	g.POST("/me/export", s.requestDataExport)
+	g.GET("/me/export", s.getDataExport)
+	g.GET("/me/export/archive", s.downloadDataExport)
+
+	admin := g.Group("/admin")
+	admin.GET("/audit-log", s.getAuditLog)
+	admin.GET("/events", s.getEvents)
+	admin.GET("/api-keys", s.getAPIKeys)
+	admin.POST("/api-keys", s.issueAPIKey)
+	admin.GET("/api-keys/:id", s.getAPIKey)
+	admin.PATCH("/api-keys/:id", s.updateAPIKey)
+	admin.DELETE("/api-keys/:id", s.revokeAPIKey)
+	admin.GET("/pairs/export.csv", s.exportPairs)
+	admin.GET("/pairs/latest", s.getLatestPairs)
+	admin.GET("/pairs/stuck", s.getStuckPairs)
+	admin.POST("/pairs/:id/force-status", s.forcePairStatus)
+	admin.POST("/plans/:id/pause", s.pausePlan)
+	admin.POST("/plans/:id/resume", s.resumePlan)
+	admin.PUT("/plans/:id/status-timeouts", s.setPlanStatusTimeouts)
+	admin.PUT("/plans/:id/assurance-policies", s.setPlanAssurancePolicies)
+	admin.GET("/blocklist", s.getBlocklist)
+	admin.POST("/blocklist", s.blockAddress)
+	admin.DELETE("/blocklist/:address", s.unblockAddress)
+	admin.GET("/blocklist/changes", s.getBlocklistChanges)
+	admin.GET("/compliance", s.getComplianceResults)
+	admin.GET("/compliance/:address", s.getComplianceResult)
+	admin.GET("/participants/:address", s.getParticipant)
+	admin.POST("/participants/:address/redact", s.redactParticipantData)
+	admin.POST("/escrows/:pair_id", s.escrowMediatorShare)
+	admin.GET("/escrows/:pair_id", s.getEscrow)
+	admin.POST("/escrows/:pair_id/recoveries", s.requestShareRecovery)
+	admin.POST("/escrows/:pair_id/recoveries/:id/approve", s.approveShareRecovery)
+	admin.POST("/escrows/:pair_id/recoveries/:id/reject", s.rejectShareRecovery)
+	admin.POST("/escrows/:pair_id/recoveries/:id/retrieve", s.retrieveMediatorShare)
+	admin.GET("/projections", s.getProjections)
+	admin.POST("/projections/:name/reset", s.resetProjection)
+	admin.GET("/dead-letters", s.getDeadLetters)
+	admin.GET("/features", s.getFeatures)
+	admin.GET("/metrics", echo.WrapHandler(expvar.Handler()))
+	admin.GET("/log-levels", s.getLogLevels)
+	admin.PUT("/log-levels", s.setLogLevel)
+	admin.DELETE("/log-levels/:module", s.resetLogLevel)
+	admin.GET("/ui", s.redirectDashboard)
+	admin.GET("/ui/*", s.serveDashboard)
+}
+
+// requestValidator validates the request payloads at bind time with the common validation rules
+type requestValidator struct{}
+
+// Validate implements echo.Validator
+func (requestValidator) Validate(i interface{}) error {
+	return common.Validate(i)
+}
+
+// bindRequest binds the path params and body of the request to req and validates it
+func bindRequest(c echo.Context, req interface{}) error {
+	if err := c.Bind(req); err != nil {
+		return withRequestSchema(c, req, err)
+	}
+	if err := c.Validate(req); err != nil {
+		return withRequestSchema(c, req, err)
+	}
+
+	return nil
+}
+
+// respondWithETag sends v in the negotiated encoding tagged with the hash of the body,
+// the body is omitted with 304 Not Modified when the client already has the same representation
+func respondWithETag(c echo.Context, v interface{}) error {
+	contentType, body, err := encodeResponse(c, v)
+	if err != nil {
+		return err
+	}
+
+	sum := sha256.Sum256(body)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	c.Response().Header().Set(echo.HeaderCacheControl, "private, no-cache")
+	c.Response().Header().Set("ETag", etag)
+	if matchesETag(c.Request().Header.Get("If-None-Match"), etag) {
+		return c.NoContent(http.StatusNotModified)
+	}
+
+	return c.Blob(http.StatusOK, contentType, body)
+}
+
+// matchesETag checks if the If-None-Match header contains the etag, weak validators match their strong counterparts
+func matchesETag(ifNoneMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+
+	return false
+}
+
+type initAuthRequest struct {
+	Chain   common.Chain   `json:"chain" validate:"required,oneof=ETH THOR"`
+	PubKey  []byte         `json:"pub_key" validate:"required,max=65"`
+	Network domain.Network `json:"network,omitempty" validate:"omitempty,network"`
+}
+
+func (s *HttpServer) initAuth(c echo.Context) error {
+	var req initAuthRequest
+	if err := bindRequest(c, &req); err != nil {
+		return err
+	}
+
+	token, err := s.authDB.Init(req.Chain, req.PubKey, req.Network, authClient(c))
+	if err != nil {
+		return err
+	}
+	if err := s.rejectBlocked(c, token.Address); err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, token)
+}
+
+type verifyAuthRequest struct {
+	Id        uuid.UUID `json:"id" validate:"required"`
+	Signature []byte    `json:"signature" validate:"required,max=512"`
+}
+
+func (s *HttpServer) verifyAuth(c echo.Context) error {
+	var req verifyAuthRequest
+	if err := bindRequest(c, &req); err != nil {
+		return err
+	}
+
+	token, err := s.authDB.Verify(req.Id, req.Signature, authClient(c))
+	if err != nil {
+		return err
+	}
+	// The address may have been blocked since its challenge was issued
+	if err := s.rejectBlocked(c, token.Address); err != nil {
+		if revokeErr := s.authDB.Revoke(token.Chain, token.Address, token.Id); revokeErr != nil {
+			return revokeErr
+		}
+		return err
+	}
+	if err := s.requireCompliance(c, token); err != nil {
+		return err
+	}
+	if _, err := s.app.Commands.RegisterParticipant.Handle(c.Request().Context(), commands.RegisterParticipant{
+		Chain:   token.Chain,
+		Address: token.Address,
+	}); err != nil {
+		return err
+	}
+
+	issuer := s.authDB.JWTIssuer()
+	if issuer == nil {
+		return c.NoContent(http.StatusOK)
+	}
+
+	accessToken, expiresAt, err := issuer.Issue(token)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, verifyAuthResponse{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresAt:   expiresAt.Unix(),
+	})
+}
+
+// rejectBlocked fails with an address blocked error when the address is in the blocklist
+func (s *HttpServer) rejectBlocked(c echo.Context, address string) error {
+	blocked, err := s.app.Blocklist.IsBlocked(c.Request().Context(), domain.Address(address))
+	if err != nil {
+		return err
+	}
+	if blocked {
+		return commands.ErrAddressBlocked
+	}
+
+	return nil
+}
+
+// authClient returns the client of the authentication request the challenges are bound to
+func authClient(c echo.Context) common.Client {
+	return common.Client{IP: c.RealIP(), UserAgent: c.Request().UserAgent()}
+}
+
+// verifyAuthResponse is the JWT issued in the stateless mode, to be used as the Bearer token instead of the token id
+type verifyAuthResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresAt   int64  `json:"expires_at"`
+}
+
+// WithChallengeBinding binds the authentication challenges to the IP address and/or user agent of the client initializing them
+func (s *HttpServer) WithChallengeBinding(ip, userAgent bool) {
+	s.authDB.BindChallenges(common.ClientBinding{IP: ip, UserAgent: userAgent})
+}
+
+// WithJWT enables the stateless authentication mode, the verified tokens are issued as JWTs signed with the secret
+// and valid for ttl, any replica sharing the secret accepts them without the token store
+func (s *HttpServer) WithJWT(secret []byte, ttl time.Duration) {
+	s.authDB.EnableJWT(common.NewJWTIssuer(secret, ttl, s.app.Clock))
+}
+
+// session is a token issued to the address of the participant, without its challenge
+type session struct {
+	Id        uuid.UUID      `json:"id"`
+	Chain     common.Chain   `json:"chain"`
+	Network   domain.Network `json:"network"`
+	IssuedAt  int64          `json:"issued_at"`
+	ExpiresAt int64          `json:"expires_at"`
+	Verified  bool           `json:"verified"`
+	// Current tells if the session is the one the request is authenticated with
+	Current bool `json:"current"`
+}
+
+func (s *HttpServer) getSessions(c echo.Context) error {
+	auth, err := s.authDB.ExtractTokenFromHttp(c.Request())
+	if err != nil {
+		return err
+	}
+
+	tokens, err := s.authDB.Sessions(auth.Chain, auth.Address)
+	if err != nil {
+		return err
+	}
+
+	sessions := make([]session, len(tokens))
+	for i, t := range tokens {
+		sessions[i] = session{
+			Id:        t.Id,
+			Chain:     t.Chain,
+			Network:   t.Network,
+			IssuedAt:  t.IssuedAt,
+			ExpiresAt: t.ExpiresAt,
+			Verified:  t.Verified,
+			Current:   t.Id == auth.Id,
+		}
+	}
+
+	return c.JSON(http.StatusOK, sessions)
+}
+
+type revokeSessionRequest struct {
+	Id uuid.UUID `param:"id" validate:"required"`
+}
+
+func (s *HttpServer) revokeSession(c echo.Context) error {
+	var req revokeSessionRequest
+	if err := bindRequest(c, &req); err != nil {
+		return err
+	}
+
+	auth, err := s.authDB.ExtractTokenFromHttp(c.Request())
+	if err != nil {
+		return err
+	}
+
+	if err := s.authDB.Revoke(auth.Chain, auth.Address, req.Id); err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (s *HttpServer) getAssets(c echo.Context) error {
+	return c.JSON(http.StatusOK, domain.SupportedAssets())
+}
+
+type getFeesRequest struct {
+	Chain string `query:"chain" validate:"required,oneof=ETH THOR"`
+}
+
+func (s *HttpServer) getFees(c echo.Context) error {
+	var req getFeesRequest
+	if err := bindRequest(c, &req); err != nil {
+		return err
+	}
+
+	estimate, err := s.app.Fees.Estimate(c.Request().Context(), req.Chain)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, estimate)
+}
+
+type plan struct {
+	Id                  string                   `json:"id"`
+	Name                string                   `json:"name"`
+	Type                domain.PlanType          `json:"type"`
+	Assets              []domain.Asset           `json:"assets"`
+	Security            string                   `json:"security"`
+	Strategy            string                   `json:"strategy"`
+	Quantum             int                      `json:"quantum"`
+	LossProtection      float64                  `json:"loss_protection"`
+	InvestingPeriod     int                      `json:"time_frame"`
+	InvestingPeriodUnit domain.PeriodUnit        `json:"time_frame_unit"`
+	GracePeriodDays     int                      `json:"grace_period_days"`
+	MaxShareMultiplier  int                      `json:"max_share_multiplier"`
+	Network             domain.Network           `json:"network"`
+	MaxActivePairs      int                      `json:"max_active_pairs,omitempty"`
+	ActivePairs         int                      `json:"active_pairs"`
+	ActiveFrom          *time.Time               `json:"active_from,omitempty"`
+	ActiveUntil         *time.Time               `json:"active_until,omitempty"`
+	Availability        queries.PlanAvailability `json:"availability"`
+	APR                 float64                  `json:"APR"`
+	// WaitingPairs are the pairs waiting for a counterparty by the asset of their creator, a participant of the other asset is matched at once
+	WaitingPairs        map[domain.Asset]int `json:"waiting_pairs"`
+	AverageMatchSeconds float64              `json:"average_match_seconds"`
+	CompletedPairs      int                  `json:"completed_pairs"`
+}
+
+func newPlanResponse(p queries.Plan, activePairs int, stats queries.PlanStats, now time.Time) plan {
+	if stats.WaitingPairs == nil {
+		stats.WaitingPairs = map[domain.Asset]int{}
+	}
+
+	return plan{
+		Id:                  p.Id,
+		Name:                "Basic Low Risk Plan", // TODO: "Basic Low Risk Plan" is a hardcoded value, it should be fetched from the database
+		Type:                p.Type,
+		Assets:              p.Assets,
+		Security:            string(p.Security),
+		Strategy:            string(p.Strategy),
+		Quantum:             p.Quantum,
+		LossProtection:      p.LossProtection,
+		InvestingPeriod:     p.InvestingPeriod,
+		InvestingPeriodUnit: p.InvestingPeriodUnit,
+		GracePeriodDays:     p.GracePeriodDays,
+		MaxShareMultiplier:  p.MaxShareMultiplier,
+		Network:             p.Network,
+		MaxActivePairs:      p.MaxActivePairs,
+		ActivePairs:         activePairs,
+		ActiveFrom:          p.ActiveFrom,
+		ActiveUntil:         p.ActiveUntil,
+		Availability:        p.Availability(now, activePairs),
+		APR:                 p.APR,
+		WaitingPairs:        stats.WaitingPairs,
+		AverageMatchSeconds: stats.AverageMatchSeconds,
+		CompletedPairs:      stats.CompletedPairs,
+	}
+}
+
+type getPlansRequest struct {
+	Network             domain.Network                `query:"network" validate:"omitempty,network"`
+	Type                domain.PlanType               `query:"type" validate:"omitempty,oneof=lp savers"`
+	Asset               domain.Asset                  `query:"asset" validate:"omitempty,asset"`
+	MinQuantum          int                           `query:"min_quantum" validate:"omitempty,min=1"`
+	MaxQuantum          int                           `query:"max_quantum" validate:"omitempty,min=1,gtefield=MinQuantum"`
+	InvestingPeriod     int                           `query:"time_frame" validate:"omitempty,min=1"`
+	InvestingPeriodUnit domain.PeriodUnit             `query:"time_frame_unit" validate:"omitempty,oneof=day week month"`
+	Security            domain.MultiSigWalletSecurity `query:"security" validate:"omitempty,oneof=2-2"`
+	Sort                queries.PlanSort              `query:"sort" validate:"omitempty,oneof=apr quantum"`
+	Order               string                        `query:"order" validate:"omitempty,oneof=asc desc"`
+}
+
+func (s *HttpServer) getPlans(c echo.Context) error {
+	var req getPlansRequest
+	if err := bindRequest(c, &req); err != nil {
+		return err
+	}
+
+	plans, err := s.app.Queries.Plans.All(c.Request().Context(), queries.PlanFilter{
+		Network:             domain.NetworkOrDefault(req.Network),
+		Type:                req.Type,
+		Asset:               req.Asset,
+		MinQuantum:          req.MinQuantum,
+		MaxQuantum:          req.MaxQuantum,
+		InvestingPeriod:     req.InvestingPeriod,
+		InvestingPeriodUnit: req.InvestingPeriodUnit,
+		Security:            req.Security,
+		SortBy:              req.Sort,
+		Descending:          req.Order == "desc",
+	})
+	if err != nil {
+		return err
+	}
+	activePairs, err := s.app.Queries.Pairs.CountActive(c.Request().Context())
+	if err != nil {
+		return err
+	}
+	stats, err := s.app.Queries.PlanStats.All(c.Request().Context())
+	if err != nil {
+		return err
+	}
+
+	now := s.app.Clock.Now()
+	response := make([]plan, len(plans))
+	for i, p := range plans {
+		response[i] = newPlanResponse(p, activePairs[p.Id], stats[p.Id], now)
+	}
+
+	return respondWithETag(c, response)
+}
+
+var ErrInvalidPlanId = common.NewError("invalid_plan_id", "plan id is required")
+
+func (s *HttpServer) getPlan(c echo.Context) error {
+	id := c.Param("id")
+	if id == "" {
+		return ErrInvalidPlanId
+	}
+
+	p, err := s.app.Queries.Plans.Get(c.Request().Context(), id)
+	if err != nil {
+		return err
+	}
+	activePairs, err := s.app.Queries.Pairs.CountActive(c.Request().Context())
+	if err != nil {
+		return err
+	}
+	stats, err := s.app.Queries.PlanStats.All(c.Request().Context())
+	if err != nil {
+		return err
+	}
+
+	return respondWithETag(c, newPlanResponse(*p, activePairs[p.Id], stats[p.Id], s.app.Clock.Now()))
+}
+
+var ErrForbidden = common.NewError("forbidden", "forbidden content access")
+
+type createOrMatchPairRequest struct {
+	PlanId           string       `json:"plan_id" validate:"required,uuid4"`
+	ParticipantAsset domain.Asset `json:"participant_asset" validate:"required,asset"`
+	ShareMultiplier  int          `json:"share_multiplier" validate:"omitempty,min=1"`
+}
+
+type createOrMatchPairResponse struct {
+	Id string `json:"id"`
+}
+
+func (s *HttpServer) createOrMatchPair(c echo.Context) error {
+	var req createOrMatchPairRequest
+	if err := bindRequest(c, &req); err != nil {
+		return err
+	}
+
+	auth, err := s.authDB.ExtractTokenFromHttp(c.Request())
+	if err != nil {
+		return err
+	}
+	if !domain.IsAssetOnChain(req.ParticipantAsset, auth.Chain) {
+		return ErrForbidden
+	}
+
+	pairId, err := s.app.Commands.CreateOrMatchPair.Handle(c.Request().Context(), commands.CreateOrMatchPair{
+		PlanId:             req.PlanId,
+		ParticipantAsset:   req.ParticipantAsset,
+		ParticipantAddress: auth.Address,
+		ShareMultiplier:    req.ShareMultiplier,
+		Network:            auth.Network,
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, createOrMatchPairResponse{Id: pairId})
+}
+
+// getPair serves the pair, a pair the projection doesn't reflect yet is loaded from its events so it's readable right after it's changed
+func (s *HttpServer) getPair(c echo.Context) error {
+	pair, err := s.app.GetPair(c.Request().Context(), c.Param("id"))
+	if err != nil {
+		return err
+	}
+
+	auth, err := s.authDB.ExtractTokenFromHttp(c.Request())
+	if err != nil {
+		return err
+	}
+	if !pairHasAddress(pair, auth.Address) || pair.Network != domain.NetworkOrDefault(auth.Network) {
+		return ErrForbidden
+	}
+
+	response, err := sparseFields(c, pair)
+	if err != nil {
+		return err
+	}
+
+	return respondWithETag(c, response)
+}
+
+// ErrSettlementNotFound is returned when the withdrawal of the pair isn't settled yet
+var ErrSettlementNotFound = common.NewError("settlement_not_found", "withdrawal of the pair is not settled yet")
+
+func (s *HttpServer) getSettlement(c echo.Context) error {
+	pair, err := s.app.Queries.Pairs.Get(c.Request().Context(), c.Param("id"))
+	if err != nil {
+		return err
+	}
+
+	auth, err := s.authDB.ExtractTokenFromHttp(c.Request())
+	if err != nil {
+		return err
+	}
+	if !pairHasAddress(pair, auth.Address) || pair.Network != domain.NetworkOrDefault(auth.Network) {
+		return ErrForbidden
+	}
+
+	if pair.Settlement == nil {
+		return ErrSettlementNotFound
+	}
+
+	return respond(c, http.StatusOK, pair.Settlement)
+}
+
+func (s *HttpServer) getPosition(c echo.Context) error {
+	pair, err := s.app.Queries.Pairs.Get(c.Request().Context(), c.Param("id"))
+	if err != nil {
+		return err
+	}
+
+	auth, err := s.authDB.ExtractTokenFromHttp(c.Request())
+	if err != nil {
+		return err
+	}
+	if !pairHasAddress(pair, auth.Address) || pair.Network != domain.NetworkOrDefault(auth.Network) {
+		return ErrForbidden
+	}
+
+	position, err := s.app.Queries.Positions.Get(c.Request().Context(), pair)
+	if err != nil {
+		return err
+	}
+
+	return respond(c, http.StatusOK, position)
+}
+
+type waitForPairRequest struct {
+	PairId       string `param:"id" validate:"required,uuid4"`
+	SinceVersion int    `query:"since_version" validate:"min=0"`
+	// Timeout is how long to wait for in seconds
+	Timeout int `query:"timeout" validate:"omitempty,min=1,max=60"`
+}
+
+// defaultWaitTimeout is how long a long-poll waits for the pair to change when the client doesn't tell
+const defaultWaitTimeout = 30 * time.Second
+
+// waitForPair long-polls the pair until its version advances past since_version and returns its new state,
+// it responds with 304 Not Modified when the timeout elapses first
+func (s *HttpServer) waitForPair(c echo.Context) error {
+	var req waitForPairRequest
+	if err := bindRequest(c, &req); err != nil {
+		return err
+	}
+
+	auth, err := s.authDB.ExtractTokenFromHttp(c.Request())
+	if err != nil {
+		return err
+	}
+	pair, err := s.app.Queries.Pairs.Get(c.Request().Context(), req.PairId)
+	if err != nil {
+		return err
+	}
+	if !pairHasAddress(pair, auth.Address) || pair.Network != domain.NetworkOrDefault(auth.Network) {
+		return ErrForbidden
+	}
+
+	timeout := defaultWaitTimeout
+	if req.Timeout > 0 {
+		timeout = time.Duration(req.Timeout) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(c.Request().Context(), timeout)
+	defer cancel()
+
+	pair, err = s.app.Queries.Pairs.WaitForVersion(ctx, req.PairId, req.SinceVersion)
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return c.NoContent(http.StatusNotModified)
+	case err != nil:
+		return err
+	}
+
+	return respond(c, http.StatusOK, pair)
+}
+
+// requirePairNetwork is a middleware rejecting the requests on the pairs outside the network of the authentication token,
+// requests whose token or pair can't be resolved are left to the handlers to reject
+func (s *HttpServer) requirePairNetwork(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		auth, err := s.authDB.ExtractTokenFromHttp(c.Request())
+		if err != nil {
+			return next(c)
+		}
+
+		pair, err := s.app.Queries.Pairs.Get(c.Request().Context(), c.Param("id"))
+		if err != nil {
+			return next(c)
+		}
+
+		if pair.Network != domain.NetworkOrDefault(auth.Network) {
+			return ErrForbidden
+		}
+
+		return next(c)
+	}
+}
+
+func pairHasAddress(pair *queries.Pair, address string) bool {
+	for _, p := range pair.ParticipantAddresses {
+		if p == address {
+			return true
+		}
+	}
+
+	return false
+}
+
+var ErrInvalidAddress = common.NewError("invalid_address", "address is required")
+
+type getPairsRequest struct {
+	PlanId          string            `query:"plan_id" validate:"omitempty,uuid4"`
+	Status          domain.PairStatus `query:"status" validate:"omitempty,oneof=waiting wallet_conformation assurance deposit pre_sign_withdrawal lp pre_sign_savers_withdrawal savers withdrawn invalid refunded"`
+	Asset           domain.Asset      `query:"asset" validate:"omitempty,asset"`
+	CreatedAfter    time.Time         `query:"created_after"`
+	CreatedBefore   time.Time         `query:"created_before"`
+	IncludeArchived bool              `query:"include_archived"`
+}
+
+// getPairs lists the pairs of the participant, optionally narrowed down to the pairs of a plan
+func (s *HttpServer) getPairs(c echo.Context) error {
+	var req getPairsRequest
+	if err := bindRequest(c, &req); err != nil {
+		return err
+	}
+
+	auth, err := s.authDB.ExtractTokenFromHttp(c.Request())
+	if err != nil {
+		return err
+	}
+
+	network := domain.NetworkOrDefault(auth.Network)
+	filter := queries.PairFilter{
+		Network:              &network,
+		ParticipantAddresses: []domain.Address{auth.Address},
+		CreatedAfter:         req.CreatedAfter,
+		CreatedBefore:        req.CreatedBefore,
+		IncludeArchived:      req.IncludeArchived,
+	}
+	if req.Status != "" {
+		filter.Status = &req.Status
+	}
+	if req.Asset != "" {
+		filter.Assets = []domain.Asset{req.Asset}
+	}
+
+	if req.PlanId == "" {
+		pairs, err := s.app.Queries.Pairs.Find(c.Request().Context(), filter)
+		if err != nil {
+			return err
+		}
+		return respondWithPairs(c, pairs)
+	}
+
+	plan, err := s.app.Queries.Plans.Get(c.Request().Context(), req.PlanId)
+	if err != nil {
+		return err
+	}
+	if plan.Network != network {
+		return commands.ErrPlanNotInNetwork
+	}
+
+	// The pairs are matched to the plan by its terms, as the pairs created before they were linked to their plans have no plan id
+	if req.Asset != "" && !containsAsset(plan.Assets, req.Asset) {
+		return respondWithPairs(c, []queries.Pair{})
+	}
+	filter.Assets = plan.Assets
+	filter.InvestingPeriod = &plan.InvestingPeriod
+	filter.InvestingPeriodUnit = &plan.InvestingPeriodUnit
+	filter.GracePeriodDays = &plan.GracePeriodDays
+	filter.WalletSecurity = &plan.Security
+	filter.ProfitSharingStrategy = &plan.Strategy
+	filter.LossProtection = &plan.LossProtection
+
+	pairs, err := s.app.Queries.Pairs.Find(c.Request().Context(), filter)
+	if err != nil {
+		return err
+	}
+
+	return respondWithPairs(c, filterPairsByPlanShareValue(pairs, plan))
+}
+
+// respondWithPairs sends the pairs with the fields selected by the request
+func respondWithPairs(c echo.Context, pairs []queries.Pair) error {
+	response, err := sparseFields(c, pairs)
+	if err != nil {
+		return err
+	}
+
+	return respondWithETag(c, response)
+}
+
+func containsAsset(assets []domain.Asset, asset domain.Asset) bool {
+	for _, a := range assets {
+		if a == asset {
+			return true
+		}
+	}
+	return false
+}
+
+// filterPairsByPlanShareValue keeps the pairs whose share value is an allowed multiple of the plan quantum
+func filterPairsByPlanShareValue(pairs []queries.Pair, plan *queries.Plan) []queries.Pair {
+	filtered := make([]queries.Pair, 0, len(pairs))
+	for _, p := range pairs {
+		if p.ShareValue%plan.Quantum == 0 && plan.AllowsShareMultiplier(p.ShareValue/plan.Quantum) {
+			filtered = append(filtered, p)
+		}
+	}
+
+	return filtered
+}
+
+type confirmPairWalletRequest struct {
+	PairId               string                          `param:"id" json:"-" validate:"required,uuid4"`
+	ParticipantPublicKey string                          `json:"participant_public_key,omitempty" validate:"required,max=256"`
+	WalletAddresses      map[domain.Asset]domain.Address `json:"wallet_addresses,omitempty" validate:"required,len=2,dive,keys,asset,endkeys,required"`
+}
+
+func (s *HttpServer) confirmPairWallet(c echo.Context) error {
+	var req confirmPairWalletRequest
+	if err := bindRequest(c, &req); err != nil {
+		return err
+	}
+
+	auth, err := s.authDB.ExtractTokenFromHttp(c.Request())
+	if err != nil {
+		return err
+	}
+
+	_, err = s.app.Commands.ConfirmPairWallet.Handle(c.Request().Context(), commands.ConfirmPairWallet{
+		PairId:               req.PairId,
+		ParticipantAddress:   auth.Address,
+		ParticipantPublicKey: req.ParticipantPublicKey,
+		WalletAddresses:      req.WalletAddresses,
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.respondToPairCommand(c, req.PairId, http.StatusOK, nil)
+}
+
+type reportKeygenFailureRequest struct {
+	PairId         string `param:"id" json:"-" validate:"required,uuid4"`
+	KeygenFailures int    `json:"keygen_failures" validate:"min=0"`
+	Reason         string `json:"reason,omitempty" validate:"max=256"`
+}
+
+// reportKeygenFailure regenerates the wallet of the pair the participants failed to generate, keygen_failures is the
+// count of failures of the wallet they tried to generate as served with the pair
+func (s *HttpServer) reportKeygenFailure(c echo.Context) error {
+	var req reportKeygenFailureRequest
+	if err := bindRequest(c, &req); err != nil {
+		return err
+	}
+
+	auth, err := s.authDB.ExtractTokenFromHttp(c.Request())
+	if err != nil {
+		return err
+	}
+
+	_, err = s.app.Commands.ReportKeygenFailure.Handle(c.Request().Context(), commands.ReportKeygenFailure{
+		PairId:             req.PairId,
+		ParticipantAddress: auth.Address,
+		KeygenFailures:     req.KeygenFailures,
+		Reason:             req.Reason,
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.respondToPairCommand(c, req.PairId, http.StatusOK, nil)
+}
+
+type setPairAssurancesRequest struct {
+	PairId     string            `param:"id" json:"-" validate:"required,uuid4"`
+	Asset      domain.Asset      `json:"asset,omitempty" validate:"required,asset"`
+	Assurances []domain.SignedTx `json:"assurances,omitempty" validate:"required,min=1,max=64,dive"`
+}
+
+func (s *HttpServer) setPairAssurances(c echo.Context) error {
+	var req setPairAssurancesRequest
+	if err := bindRequest(c, &req); err != nil {
+		return err
+	}
+
+	auth, err := s.authDB.ExtractTokenFromHttp(c.Request())
+	if err != nil {
+		return err
+	}
+
+	_, err = s.app.Commands.SetPairAssurances.Handle(c.Request().Context(), commands.SetPairAssurances{
+		PairId:             req.PairId,
+		ParticipantAddress: auth.Address,
+		Asset:              req.Asset,
+		Assurances:         req.Assurances,
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.respondToPairCommand(c, req.PairId, http.StatusOK, nil)
+}
+
+type assurancesAcknowledgementResponse struct {
+	Asset   domain.Asset `json:"asset"`
+	Digest  string       `json:"digest"`
+	Message string       `json:"message"`
+}
+
+// getAssurancesAcknowledgement returns the message the participant signs to confirm the assurances refunding their asset
+func (s *HttpServer) getAssurancesAcknowledgement(c echo.Context) error {
+	pair, err := s.app.Queries.Pairs.Get(c.Request().Context(), c.Param("id"))
+	if err != nil {
+		return err
+	}
+
+	auth, err := s.authDB.ExtractTokenFromHttp(c.Request())
+	if err != nil {
+		return err
+	}
+	if !pairHasAddress(pair, auth.Address) {
+		return ErrForbidden
+	}
+
+	// The participant addresses are ordered as the assets of the pair
+	var asset domain.Asset
+	for i, address := range pair.ParticipantAddresses {
+		if address == auth.Address {
+			asset = pair.Assets[i]
+		}
+	}
+	assurances, ok := pair.Assurances[asset]
+	if !ok {
+		return commands.ErrAssurancesNotSet
+	}
+
+	return c.JSON(http.StatusOK, assurancesAcknowledgementResponse{
+		Asset:   asset,
+		Digest:  domain.AssurancesDigest(assurances),
+		Message: domain.AssurancesAcknowledgement(pair.Id, asset, assurances),
+	})
+}
 
-	return &s
+type confirmAssurancesRequest struct {
+	PairId    string `param:"id" json:"-" validate:"required,uuid4"`
+	Signature []byte `json:"signature,omitempty" validate:"required"`
 }
 
-func (s *HttpServer) registerRoutes() {
-	s.echo.POST("/auth/init", s.initAuth)
-	s.echo.POST("/auth/verify", s.verifyAuth)
+func (s *HttpServer) confirmAssurances(c echo.Context) error {
+	var req confirmAssurancesRequest
+	if err := bindRequest(c, &req); err != nil {
+		return err
+	}
+
+	auth, err := s.authDB.ExtractTokenFromHttp(c.Request())
+	if err != nil {
+		return err
+	}
 
-	s.echo.GET("/plans", s.getPlans)
-	s.echo.GET("/plan/:id", s.getPlan)
+	_, err = s.app.Commands.ConfirmAssurances.Handle(c.Request().Context(), commands.ConfirmAssurances{
+		PairId:             req.PairId,
+		ParticipantAddress: auth.Address,
+		Signature:          req.Signature,
+	})
+	if err != nil {
+		return err
+	}
 
-	s.echo.POST(("/pairs"), s.createOrMatchPair)
-	s.echo.GET("/pairs/:id", s.getPair)
-	s.echo.GET("/pairs", s.getPairs)
-	s.echo.POST("/pairs/:id/confirm-wallet", s.confirmPairWallet)
-	s.echo.POST("/pairs/:id/assurances", s.setPairAssurances)
-	s.echo.POST("/pairs/:id/deposits", s.addDeposit)
-	s.echo.POST("/pairs/:id/sign-withdraw", s.signWithdrawal)
-	s.echo.POST("/pairs/:id/submit-lp", s.submitLP)
-	s.echo.POST("/pairs/:id/submit-withdrawal", s.submitWithdrawal)
+	return s.respondToPairCommand(c, req.PairId, http.StatusOK, nil)
 }
 
-type initAuthRequest struct {
-	Chain  common.Chain `json:"chain"`
-	PubKey []byte       `json:"pub_key"`
+type addDepositRequest struct {
+	PairId string        `param:"id" json:"-" validate:"required,uuid4"`
+	Asset  domain.Asset  `json:"asset,omitempty" validate:"required,asset"`
+	TxHash domain.TxHash `json:"tx_hash,omitempty" validate:"required,tx_hash"`
+	// Amount is the deposited amount in the base units of the asset, e.g. wei, as a string so it isn't rounded
+	Amount   string `json:"amount,omitempty" validate:"required,number"`
+	Decimals int    `json:"decimals" validate:"min=0"`
 }
 
-func (s *HttpServer) initAuth(c echo.Context) error {
-	var req initAuthRequest
-	if err := c.Bind(&req); err != nil {
+func (s *HttpServer) addDeposit(c echo.Context) error {
+	var req addDepositRequest
+	if err := bindRequest(c, &req); err != nil {
 		return err
 	}
 
-	token, err := s.authDB.Init(req.Chain, []byte(req.PubKey))
+	auth, err := s.authDB.ExtractTokenFromHttp(c.Request())
 	if err != nil {
 		return err
 	}
 
-	return c.JSON(http.StatusOK, token)
+	_, err = s.app.Commands.AddDeposit.Handle(c.Request().Context(), commands.AddDeposit{
+		PairId:             req.PairId,
+		ParticipantAddress: auth.Address,
+		Asset:              req.Asset,
+		TxHash:             req.TxHash,
+		Amount:             req.Amount,
+		Decimals:           req.Decimals,
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.respondToPairCommand(c, req.PairId, http.StatusOK, nil)
 }
 
-type verifyAuthRequest struct {
-	Id        uuid.UUID `json:"id"`
-	Signature []byte    `json:"signature"`
+type signWithdrawalRequest struct {
+	PairId string          `param:"id" json:"-" validate:"required,uuid4"`
+	Tx     domain.SignedTx `json:"tx,omitempty"`
 }
 
-func (s *HttpServer) verifyAuth(c echo.Context) error {
-	var req verifyAuthRequest
-	if err := c.Bind(&req); err != nil {
+func (s *HttpServer) signWithdrawal(c echo.Context) error {
+	var req signWithdrawalRequest
+	if err := bindRequest(c, &req); err != nil {
 		return err
 	}
 
-	err := s.authDB.Verify(req.Id, req.Signature)
+	auth, err := s.authDB.ExtractTokenFromHttp(c.Request())
 	if err != nil {
 		return err
 	}
 
-	return c.NoContent(http.StatusOK)
+	_, err = s.app.Commands.SignWithdrawal.Handle(c.Request().Context(), commands.SignWithdrawal{
+		PairId:             req.PairId,
+		ParticipantAddress: auth.Address,
+		Tx:                 req.Tx,
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.respondToPairCommand(c, req.PairId, http.StatusOK, nil)
 }
 
-type plan struct {
-	Id              string         `json:"id"`
-	Name            string         `json:"name"`
-	Assets          []domain.Asset `json:"assets"`
-	Security        string         `json:"security"`
-	Strategy        string         `json:"strategy"`
-	Quantum         int            `json:"quantum"`
-	LossProtection  float64        `json:"loss_protection"`
-	InvestingPeriod int            `json:"time_frame"`
-	APR             float64        `json:"APR"`
+type submitLPRequest struct {
+	PairId string        `param:"id" json:"-" validate:"required,uuid4"`
+	Asset  domain.Asset  `json:"asset,omitempty" validate:"required,asset"`
+	TxHash domain.TxHash `json:"tx_hash,omitempty" validate:"required,tx_hash"`
 }
 
-func (s *HttpServer) getPlans(c echo.Context) error {
-	plans, err := s.app.Queries.Plans.All(c.Request().Context())
+func (s *HttpServer) submitLP(c echo.Context) error {
+	var req submitLPRequest
+	if err := bindRequest(c, &req); err != nil {
+		return err
+	}
+
+	auth, err := s.authDB.ExtractTokenFromHttp(c.Request())
 	if err != nil {
 		return err
 	}
-	response := make([]plan, len(plans))
-	for i, p := range plans {
-		response[i] = plan{
-			Id:              p.Id,
-			Name:            "Basic Low Risk Plan", // TODO: "Basic Low Risk Plan" is a hardcoded value, it should be fetched from the database
-			Assets:          p.Assets,
-			Security:        string(p.Security),
-			Strategy:        string(p.Strategy),
-			Quantum:         p.Quantum,
-			LossProtection:  p.LossProtection,
-			InvestingPeriod: p.InvestingPeriod,
-			APR:             0.15,
-		}
+
+	_, err = s.app.Commands.SubmitLP.Handle(c.Request().Context(), commands.SubmitLP{
+		PairId:             req.PairId,
+		ParticipantAddress: auth.Address,
+		Asset:              req.Asset,
+		TxHash:             req.TxHash,
+	})
+	if err != nil {
+		return err
 	}
 
-	return c.JSON(http.StatusOK, response)
+	return s.respondToPairCommand(c, req.PairId, http.StatusOK, nil)
 }
 
-var ErrInvalidPlanId = common.NewError("invalid_plan_id", "plan id is required")
+type submitWithdrawalRequest struct {
+	PairId string        `param:"id" json:"-" validate:"required,uuid4"`
+	TxHash domain.TxHash `json:"tx_hash,omitempty" validate:"required,tx_hash"`
+}
 
-func (s *HttpServer) getPlan(c echo.Context) error {
-	id := c.Param("id")
-	if id == "" {
-		return ErrInvalidPlanId
+func (s *HttpServer) submitWithdrawal(c echo.Context) error {
+	var req submitWithdrawalRequest
+	if err := bindRequest(c, &req); err != nil {
+		return err
 	}
 
-	p, err := s.app.Queries.Plans.Get(c.Request().Context(), id)
+	auth, err := s.authDB.ExtractTokenFromHttp(c.Request())
 	if err != nil {
 		return err
 	}
-	return c.JSON(http.StatusOK, plan{
-		Id:              p.Id,
-		Name:            "Basic Low Risk Plan", // TODO: "Basic Low Risk Plan" is a hardcoded value, it should be fetched from the database
-		Assets:          p.Assets,
-		Security:        string(p.Security),
-		Strategy:        string(p.Strategy),
-		Quantum:         p.Quantum,
-		LossProtection:  p.LossProtection,
-		InvestingPeriod: p.InvestingPeriod,
-		APR:             0.15,
-	})
-}
 
-var ErrForbidden = common.NewError("forbidden", "forbidden content access")
+	_, err = s.app.Commands.SubmitWithdrawal.Handle(c.Request().Context(), commands.SubmitWithdrawal{
+		PairId:             req.PairId,
+		ParticipantAddress: &auth.Address,
+		TxHash:             req.TxHash,
+	})
+	if err != nil {
+		return err
+	}
 
-type createOrMatchPairRequest struct {
-	PlanId           string       `json:"plan_id"`
-	ParticipantAsset domain.Asset `json:"participant_asset"`
+	return s.respondToPairCommand(c, req.PairId, http.StatusOK, nil)
 }
 
-type createOrMatchPairResponse struct {
-	Id string `json:"id"`
+type signSaversWithdrawalRequest struct {
+	PairId string          `param:"id" json:"-" validate:"required,uuid4"`
+	Asset  domain.Asset    `json:"asset,omitempty" validate:"required,asset"`
+	Tx     domain.SignedTx `json:"tx,omitempty"`
 }
 
-func (s *HttpServer) createOrMatchPair(c echo.Context) error {
-	var req createOrMatchPairRequest
-	if err := c.Bind(&req); err != nil {
+func (s *HttpServer) signSaversWithdrawal(c echo.Context) error {
+	var req signSaversWithdrawalRequest
+	if err := bindRequest(c, &req); err != nil {
 		return err
 	}
 
@@ -183,25 +1185,29 @@ func (s *HttpServer) createOrMatchPair(c echo.Context) error {
 	if err != nil {
 		return err
 	}
-	if !strings.HasPrefix(req.ParticipantAsset, auth.Chain) {
-		return ErrForbidden
-	}
 
-	pairId, err := s.app.Commands.CreateOrMatchPair.Handle(c.Request().Context(), commands.CreateOrMatchPair{
-		PlanId:             req.PlanId,
-		ParticipantAsset:   req.ParticipantAsset,
+	_, err = s.app.Commands.SignSaversWithdrawal.Handle(c.Request().Context(), commands.SignSaversWithdrawal{
+		PairId:             req.PairId,
 		ParticipantAddress: auth.Address,
+		Asset:              req.Asset,
+		Tx:                 req.Tx,
 	})
 	if err != nil {
 		return err
 	}
 
-	return c.JSON(http.StatusOK, createOrMatchPairResponse{Id: pairId})
+	return s.respondToPairCommand(c, req.PairId, http.StatusOK, nil)
 }
 
-func (s *HttpServer) getPair(c echo.Context) error {
-	pair, err := s.app.Queries.Pairs.Get(c.Request().Context(), c.Param("id"))
-	if err != nil {
+type submitSaversRequest struct {
+	PairId string        `param:"id" json:"-" validate:"required,uuid4"`
+	Asset  domain.Asset  `json:"asset,omitempty" validate:"required,asset"`
+	TxHash domain.TxHash `json:"tx_hash,omitempty" validate:"required,tx_hash"`
+}
+
+func (s *HttpServer) submitSavers(c echo.Context) error {
+	var req submitSaversRequest
+	if err := bindRequest(c, &req); err != nil {
 		return err
 	}
 
@@ -209,29 +1215,24 @@ func (s *HttpServer) getPair(c echo.Context) error {
 	if err != nil {
 		return err
 	}
-	if !pairHasAddress(pair, auth.Address) {
-		return ErrForbidden
-	}
 
-	return c.JSON(http.StatusOK, pair)
-}
-
-func pairHasAddress(pair *queries.Pair, address string) bool {
-	for _, p := range pair.ParticipantAddresses {
-		if p == address {
-			return true
-		}
+	_, err = s.app.Commands.SubmitSavers.Handle(c.Request().Context(), commands.SubmitSavers{
+		PairId:             req.PairId,
+		ParticipantAddress: auth.Address,
+		Asset:              req.Asset,
+		TxHash:             req.TxHash,
+	})
+	if err != nil {
+		return err
 	}
 
-	return false
+	return s.respondToPairCommand(c, req.PairId, http.StatusOK, nil)
 }
 
-var ErrInvalidAddress = common.NewError("invalid_address", "address is required")
-
-func (s *HttpServer) getPairs(c echo.Context) error {
-	planId := c.QueryParam("plan_id")
-	if err := uuid.Validate(planId); err != nil {
-		return ErrInvalidPlanId.IncludeMeta(map[string]interface{}{"plan_id": err})
+func (s *HttpServer) submitSaversWithdrawal(c echo.Context) error {
+	var req submitSaversRequest
+	if err := bindRequest(c, &req); err != nil {
+		return err
 	}
 
 	auth, err := s.authDB.ExtractTokenFromHttp(c.Request())
@@ -239,38 +1240,54 @@ func (s *HttpServer) getPairs(c echo.Context) error {
 		return err
 	}
 
-	plan, err := s.app.Queries.Plans.Get(c.Request().Context(), planId)
+	_, err = s.app.Commands.SubmitSaversWithdrawal.Handle(c.Request().Context(), commands.SubmitSaversWithdrawal{
+		PairId:             req.PairId,
+		ParticipantAddress: &auth.Address,
+		Asset:              req.Asset,
+		TxHash:             req.TxHash,
+	})
 	if err != nil {
 		return err
 	}
 
-	pairs, err := s.app.Queries.Pairs.Find(
-		c.Request().Context(),
-		nil,
-		plan.Assets,
-		false,
-		[]domain.Address{auth.Address},
-		&plan.Quantum,
-		&plan.InvestingPeriod,
-		&plan.Security,
-		&plan.Strategy,
-		&plan.LossProtection,
-	)
+	return s.respondToPairCommand(c, req.PairId, http.StatusOK, nil)
+}
+
+type proposeEarlyWithdrawalRequest struct {
+	PairId string `param:"id" json:"-" validate:"required,uuid4"`
+	Reason string `json:"reason,omitempty" validate:"max=1000"`
+}
+
+func (s *HttpServer) proposeEarlyWithdrawal(c echo.Context) error {
+	var req proposeEarlyWithdrawalRequest
+	if err := bindRequest(c, &req); err != nil {
+		return err
+	}
+
+	auth, err := s.authDB.ExtractTokenFromHttp(c.Request())
+	if err != nil {
+		return err
+	}
+
+	_, err = s.app.Commands.ProposeEarlyWithdrawal.Handle(c.Request().Context(), commands.ProposeEarlyWithdrawal{
+		PairId:             req.PairId,
+		ParticipantAddress: auth.Address,
+		Reason:             req.Reason,
+	})
 	if err != nil {
 		return err
 	}
 
-	return c.JSON(http.StatusOK, pairs)
+	return s.respondToPairCommand(c, req.PairId, http.StatusOK, nil)
 }
 
-type confirmPairWalletRequest struct {
-	ParticipantPublicKey string                          `json:"participant_public_key,omitempty"`
-	WalletAddresses      map[domain.Asset]domain.Address `json:"wallet_addresses,omitempty"`
+type acceptEarlyWithdrawalRequest struct {
+	PairId string `param:"id" json:"-" validate:"required,uuid4"`
 }
 
-func (s *HttpServer) confirmPairWallet(c echo.Context) error {
-	var req confirmPairWalletRequest
-	if err := c.Bind(&req); err != nil {
+func (s *HttpServer) acceptEarlyWithdrawal(c echo.Context) error {
+	var req acceptEarlyWithdrawalRequest
+	if err := bindRequest(c, &req); err != nil {
 		return err
 	}
 
@@ -279,27 +1296,28 @@ func (s *HttpServer) confirmPairWallet(c echo.Context) error {
 		return err
 	}
 
-	_, err = s.app.Commands.ConfirmPairWallet.Handle(c.Request().Context(), commands.ConfirmPairWallet{
-		PairId:               c.Param("id"),
-		ParticipantAddress:   auth.Address,
-		ParticipantPublicKey: req.ParticipantPublicKey,
-		WalletAddresses:      req.WalletAddresses,
+	_, err = s.app.Commands.AcceptEarlyWithdrawal.Handle(c.Request().Context(), commands.AcceptEarlyWithdrawal{
+		PairId:             req.PairId,
+		ParticipantAddress: auth.Address,
 	})
 	if err != nil {
 		return err
 	}
 
-	return c.NoContent(http.StatusOK)
+	return s.respondToPairCommand(c, req.PairId, http.StatusOK, nil)
 }
 
-type setPairAssurancesRequest struct {
-	Asset      domain.Asset      `json:"asset,omitempty"`
-	Assurances []domain.SignedTx `json:"assurances,omitempty"`
+type proposeExtensionRequest struct {
+	PairId              string            `param:"id" json:"-" validate:"required,uuid4"`
+	InvestingPeriod     int               `json:"investing_period" validate:"required,min=1"`
+	InvestingPeriodUnit domain.PeriodUnit `json:"investing_period_unit,omitempty" validate:"omitempty,oneof=day week month"`
+	// Rollover starts a new investing period instead of adding the period to the deadline
+	Rollover bool `json:"rollover,omitempty"`
 }
 
-func (s *HttpServer) setPairAssurances(c echo.Context) error {
-	var req setPairAssurancesRequest
-	if err := c.Bind(&req); err != nil {
+func (s *HttpServer) proposeExtension(c echo.Context) error {
+	var req proposeExtensionRequest
+	if err := bindRequest(c, &req); err != nil {
 		return err
 	}
 
@@ -308,27 +1326,27 @@ func (s *HttpServer) setPairAssurances(c echo.Context) error {
 		return err
 	}
 
-	_, err = s.app.Commands.SetPairAssurances.Handle(c.Request().Context(), commands.SetPairAssurances{
-		PairId:             c.Param("id"),
-		ParticipantAddress: auth.Address,
-		Asset:              req.Asset,
-		Assurances:         req.Assurances,
+	_, err = s.app.Commands.ProposeExtension.Handle(c.Request().Context(), commands.ProposeExtension{
+		PairId:              req.PairId,
+		ParticipantAddress:  auth.Address,
+		InvestingPeriod:     req.InvestingPeriod,
+		InvestingPeriodUnit: req.InvestingPeriodUnit,
+		Rollover:            req.Rollover,
 	})
 	if err != nil {
 		return err
 	}
 
-	return c.NoContent(http.StatusOK)
+	return s.respondToPairCommand(c, req.PairId, http.StatusOK, nil)
 }
 
-type addDepositRequest struct {
-	Asset  domain.Asset  `json:"asset,omitempty"`
-	TxHash domain.TxHash `json:"tx_hash,omitempty"`
+type acceptExtensionRequest struct {
+	PairId string `param:"id" json:"-" validate:"required,uuid4"`
 }
 
-func (s *HttpServer) addDeposit(c echo.Context) error {
-	var req addDepositRequest
-	if err := c.Bind(&req); err != nil {
+func (s *HttpServer) acceptExtension(c echo.Context) error {
+	var req acceptExtensionRequest
+	if err := bindRequest(c, &req); err != nil {
 		return err
 	}
 
@@ -337,26 +1355,26 @@ func (s *HttpServer) addDeposit(c echo.Context) error {
 		return err
 	}
 
-	_, err = s.app.Commands.AddDeposit.Handle(c.Request().Context(), commands.AddDeposit{
-		PairId:             c.Param("id"),
+	_, err = s.app.Commands.AcceptExtension.Handle(c.Request().Context(), commands.AcceptExtension{
+		PairId:             req.PairId,
 		ParticipantAddress: auth.Address,
-		Asset:              req.Asset,
-		TxHash:             req.TxHash,
 	})
 	if err != nil {
 		return err
 	}
 
-	return c.NoContent(http.StatusOK)
+	return s.respondToPairCommand(c, req.PairId, http.StatusOK, nil)
 }
 
-type signWithdrawalRequest struct {
-	Tx domain.SignedTx `json:"tx,omitempty"`
+type requestRefundRequest struct {
+	PairId string `param:"id" json:"-" validate:"required,uuid4"`
+	// Broadcast asks the server to broadcast the refund, otherwise the participant broadcasts the released assurances
+	Broadcast bool `json:"broadcast,omitempty"`
 }
 
-func (s *HttpServer) signWithdrawal(c echo.Context) error {
-	var req signWithdrawalRequest
-	if err := c.Bind(&req); err != nil {
+func (s *HttpServer) requestRefund(c echo.Context) error {
+	var req requestRefundRequest
+	if err := bindRequest(c, &req); err != nil {
 		return err
 	}
 
@@ -365,54 +1383,118 @@ func (s *HttpServer) signWithdrawal(c echo.Context) error {
 		return err
 	}
 
-	_, err = s.app.Commands.SignWithdrawal.Handle(c.Request().Context(), commands.SignWithdrawal{
-		PairId:             c.Param("id"),
+	_, err = s.app.Commands.RequestRefund.Handle(c.Request().Context(), commands.RequestRefund{
+		PairId:             req.PairId,
 		ParticipantAddress: auth.Address,
-		Tx:                 req.Tx,
+		Broadcast:          req.Broadcast,
 	})
 	if err != nil {
 		return err
 	}
 
-	return c.NoContent(http.StatusOK)
+	return s.respondToPairCommand(c, req.PairId, http.StatusOK, nil)
 }
 
-type submitLPRequest struct {
-	Asset  domain.Asset  `json:"asset,omitempty"`
-	TxHash domain.TxHash `json:"tx_hash,omitempty"`
+func (s *HttpServer) getReputation(c echo.Context) error {
+	reputation, err := s.app.Queries.Reputation.Get(c.Request().Context(), c.Param("address"))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, reputation)
 }
 
-func (s *HttpServer) submitLP(c echo.Context) error {
-	var req submitLPRequest
-	if err := c.Bind(&req); err != nil {
+type getStatsRequest struct {
+	Network domain.Network `query:"network" validate:"omitempty,network"`
+	Days    int            `query:"days" validate:"omitempty,min=1,max=365"`
+}
+
+// defaultStatsDays is the number of days the completed pairs are counted for when not requested otherwise
+const defaultStatsDays = 30
+
+func (s *HttpServer) getStats(c echo.Context) error {
+	var req getStatsRequest
+	if err := bindRequest(c, &req); err != nil {
+		return err
+	}
+	if req.Days == 0 {
+		req.Days = defaultStatsDays
+	}
+
+	stats, err := s.app.Queries.Stats.Stats(c.Request().Context(), domain.NetworkOrDefault(req.Network), req.Days)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, stats)
+}
+
+type getStatsTimeseriesRequest struct {
+	Network     domain.Network      `query:"network" validate:"omitempty,network"`
+	From        time.Time           `query:"from"`
+	To          time.Time           `query:"to"`
+	Granularity queries.Granularity `query:"granularity" validate:"omitempty,oneof=day week month"`
+}
+
+// maxTimeseriesBuckets is the most buckets a timeseries is computed for, e.g. two years by day
+const maxTimeseriesBuckets = 731
+
+// getStatsTimeseries returns the history of the pairs by day, week or month, the last 30 days by day when not requested otherwise
+func (s *HttpServer) getStatsTimeseries(c echo.Context) error {
+	var req getStatsTimeseriesRequest
+	if err := bindRequest(c, &req); err != nil {
+		return err
+	}
+	if req.Granularity == "" {
+		req.Granularity = queries.GranularityDay
+	}
+	if req.To.IsZero() {
+		req.To = s.app.Clock.Now()
+	}
+	if req.From.IsZero() {
+		req.From = req.To.AddDate(0, 0, -defaultStatsDays)
+	}
+	if req.From.After(req.To) {
+		return ErrInvalidTimeseriesRange.IncludeMeta(map[string]interface{}{"from": "must be before to"})
+	}
+	if days := req.To.Sub(req.From).Hours() / 24; req.Granularity == queries.GranularityDay && days > maxTimeseriesBuckets {
+		return ErrInvalidTimeseriesRange.IncludeMeta(map[string]interface{}{"to": fmt.Sprintf("daily timeseries span at most %d days", maxTimeseriesBuckets)})
+	}
+
+	ts, err := s.app.Queries.PairsStats.Timeseries(c.Request().Context(), domain.NetworkOrDefault(req.Network), req.From, req.To, req.Granularity)
+	if err != nil {
 		return err
 	}
 
+	return c.JSON(http.StatusOK, ts)
+}
+
+// ErrInvalidTimeseriesRange is returned when the range of a timeseries is reversed or too long for its granularity
+var ErrInvalidTimeseriesRange = common.NewError("invalid_request", "invalid timeseries range")
+
+func (s *HttpServer) getNotificationSettings(c echo.Context) error {
 	auth, err := s.authDB.ExtractTokenFromHttp(c.Request())
 	if err != nil {
 		return err
 	}
 
-	_, err = s.app.Commands.SubmitLP.Handle(c.Request().Context(), commands.SubmitLP{
-		PairId:             c.Param("id"),
-		ParticipantAddress: auth.Address,
-		Asset:              req.Asset,
-		TxHash:             req.TxHash,
-	})
+	settings, err := s.app.Queries.NotificationSettings.Get(c.Request().Context(), auth.Address)
 	if err != nil {
 		return err
 	}
 
-	return c.NoContent(http.StatusOK)
+	return c.JSON(http.StatusOK, settings)
 }
 
-type submitWithdrawalRequest struct {
-	TxHash domain.TxHash `json:"tx_hash,omitempty"`
+type updateNotificationSettingsRequest struct {
+	Email     string                     `json:"email,omitempty" validate:"omitempty,email"`
+	PushToken string                     `json:"push_token,omitempty" validate:"omitempty,max=4096"`
+	Events    []domain.NotificationEvent `json:"events,omitempty" validate:"dive,oneof=matched counterparty_deposit deadline_approaching withdrawal_completed tx_failed match_reverted status_overdue wallet_regenerated"`
 }
 
-func (s *HttpServer) submitWithdrawal(c echo.Context) error {
-	var req submitWithdrawalRequest
-	if err := c.Bind(&req); err != nil {
+func (s *HttpServer) updateNotificationSettings(c echo.Context) error {
+	var req updateNotificationSettingsRequest
+	if err := bindRequest(c, &req); err != nil {
 		return err
 	}
 
@@ -421,10 +1503,11 @@ func (s *HttpServer) submitWithdrawal(c echo.Context) error {
 		return err
 	}
 
-	_, err = s.app.Commands.SubmitWithdrawal.Handle(c.Request().Context(), commands.SubmitWithdrawal{
-		PairId:             c.Param("id"),
-		ParticipantAddress: &auth.Address,
-		TxHash:             req.TxHash,
+	_, err = s.app.Commands.UpdateNotificationSettings.Handle(c.Request().Context(), commands.UpdateNotificationSettings{
+		Address:   auth.Address,
+		Email:     req.Email,
+		PushToken: req.PushToken,
+		Events:    req.Events,
 	})
 	if err != nil {
 		return err
@@ -434,38 +1517,76 @@ func (s *HttpServer) submitWithdrawal(c echo.Context) error {
 }
 
 func (s *HttpServer) handleError(err error, c echo.Context) {
-	var (
-		commonErr     *common.Error
-		validationErr validator.ValidationErrors
-	)
-	if errors.As(err, &commonErr) {
-		c.JSON(convertCodeToHttpStatus(commonErr.Code), commonErr)
-	} else if errors.As(err, &validationErr) {
-		c.JSON(http.StatusBadRequest, common.ErrorFromValidationErrors(validationErr))
+	if c.Response().Committed {
+		return
+	}
+
+	commonErr := toCommonError(err)
+	// The errors of the requests past their deadline aren't always wrapping it, the context tells instead
+	if commonErr == common.ErrInternal && errors.Is(c.Request().Context().Err(), context.DeadlineExceeded) {
+		commonErr = common.ErrRequestTimeout
+	}
+	body := commonErr.WithTraceId(c.Response().Header().Get(echo.HeaderXRequestID))
+
+	status := body.HttpStatus()
+	if c.Request().Method == http.MethodHead {
+		c.NoContent(status)
 	} else {
-		s.echo.DefaultHTTPErrorHandler(err, c)
+		c.JSON(status, body)
 	}
 
-	if c.Response().Status == http.StatusInternalServerError {
-		s.logger.Error().Err(err).Msg("internal server error")
+	if status == http.StatusInternalServerError {
+		s.logger.Error().Err(err).Str("trace_id", body.TraceId).Msg("internal server error")
 	}
 }
 
-func convertCodeToHttpStatus(code string) int {
+// toCommonError maps the errors returned by the handlers to the error body of the API,
+// errors that aren't meant for the clients are hidden behind an internal error
+func toCommonError(err error) *common.Error {
+	var (
+		commonErr     *common.Error
+		validationErr validator.ValidationErrors
+		maxBytesErr   *http.MaxBytesError
+		httpErr       *echo.HTTPError
+	)
 	switch {
-	case strings.Contains(code, "not_found"):
-		return http.StatusNotFound
-	case strings.Contains(code, "invalid"):
-		return http.StatusBadRequest
-	case strings.Contains(code, "already"):
-		return http.StatusBadRequest
-	case strings.Contains(code, "auth"):
-		return http.StatusUnauthorized
-	case strings.Contains(code, "forbidden"):
-		return http.StatusForbidden
+	case errors.As(err, &commonErr):
+		return commonErr
+	case errors.As(err, &validationErr):
+		return common.ErrorFromValidationErrors(validationErr)
+	case errors.Is(err, context.DeadlineExceeded):
+		return common.ErrRequestTimeout
+	case errors.As(err, &maxBytesErr):
+		return common.ErrRequestTooLarge.IncludeMeta(map[string]interface{}{"limit": maxBytesErr.Limit})
+	case errors.As(err, &httpErr):
+		return common.ErrorFromHttpStatus(httpErr.Code, fmt.Sprint(httpErr.Message))
 	default:
-		return http.StatusInternalServerError
+		return common.ErrInternal
+	}
+}
+
+// logRequest logs the request with the logger of the server, the requests failing with a server error at the error level
+func (s *HttpServer) logRequest(c echo.Context, v middleware.RequestLoggerValues) error {
+	status, event := v.Status, s.logger.Info()
+	if v.Error != nil {
+		status = toCommonError(v.Error).HttpStatus()
+	}
+	if status >= http.StatusInternalServerError {
+		event = s.logger.Error().Err(v.Error)
 	}
+	event.Str("id", v.RequestID).
+		Str("remote_ip", v.RemoteIP).
+		Str("host", v.Host).
+		Str("method", v.Method).
+		Str("uri", v.URI).
+		Str("user_agent", v.UserAgent).
+		Int("status", status).
+		Dur("latency", v.Latency).
+		Str("bytes_in", v.ContentLength).
+		Int64("bytes_out", v.ResponseSize).
+		Msg("request")
+
+	return nil
 }
 
 // WithLogger sets the logger for the server
@@ -473,7 +1594,34 @@ func (s *HttpServer) WithLogger(logger zerolog.Logger) {
 	s.logger = logger
 }
 
-// Start starts the HTTP server
+// Start starts the HTTP server, it fails when a route isn't covered by a policy
 func (s *HttpServer) Start(addr string) error {
+	if err := s.checkRoutePolicies(); err != nil {
+		return err
+	}
+
 	return s.echo.Start(addr)
 }
+
+// StartTLS starts the HTTPS server with the given certificate and key files.
+// HTTP/2 is negotiated automatically over TLS.
+func (s *HttpServer) StartTLS(addr, certFile, keyFile string) error {
+	if err := s.checkRoutePolicies(); err != nil {
+		return err
+	}
+
+	return s.echo.StartTLS(addr, certFile, keyFile)
+}
+
+// StartAutoTLS starts the HTTPS server with certificates issued by Let's Encrypt for the given domains.
+// Issued certificates are cached in cacheDir to survive restarts.
+func (s *HttpServer) StartAutoTLS(addr string, domains []string, cacheDir string) error {
+	if err := s.checkRoutePolicies(); err != nil {
+		return err
+	}
+
+	s.echo.AutoTLSManager.HostPolicy = autocert.HostWhitelist(domains...)
+	s.echo.AutoTLSManager.Cache = autocert.DirCache(cacheDir)
+
+	return s.echo.StartAutoTLS(addr)
+}
@@ -0,0 +1,50 @@
+package ports
+
+import (
+	"net/http"
+
+	"github.com/co-defi/api-server/common"
+	"github.com/labstack/echo/v4"
+)
+
+// defaultBodyLimit is the maximum size of the request bodies in bytes, unless set otherwise for their route
+const defaultBodyLimit = 64 << 10
+
+// defaultRouteBodyLimits are the maximum sizes in bytes of the bodies of the routes carrying signed transactions
+var defaultRouteBodyLimits = map[string]int64{
+	"/pairs/:id/assurances":           1 << 20,
+	"/pairs/:id/sign-withdraw":        256 << 10,
+	"/pairs/:id/sign-savers-withdraw": 256 << 10,
+	"/pairs/:id/relay":                256 << 10,
+}
+
+// WithBodyLimits sets the maximum size in bytes of the request bodies, by default and by route path without the API version (e.g. /pairs/:id/assurances).
+// The routes not set keep their default limit.
+func (s *HttpServer) WithBodyLimits(defaultLimit int64, routes map[string]int64) {
+	if defaultLimit > 0 {
+		s.bodyLimit = defaultLimit
+	}
+	for route, limit := range routes {
+		s.routeBodyLimits[route] = limit
+	}
+}
+
+// limitBodies is a middleware rejecting the requests whose body is larger than the limit of their route.
+// It must run before anything reads the body, the declared length is checked upfront and the body is capped
+// for the requests that don't declare it.
+func (s *HttpServer) limitBodies(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		limit, ok := s.routeBodyLimits[unversionedPath(c.Path())]
+		if !ok {
+			limit = s.bodyLimit
+		}
+
+		req := c.Request()
+		if req.ContentLength > limit {
+			return common.ErrRequestTooLarge.IncludeMeta(map[string]interface{}{"limit": limit})
+		}
+		req.Body = http.MaxBytesReader(c.Response(), req.Body, limit)
+
+		return next(c)
+	}
+}
@@ -0,0 +1,73 @@
+package ports
+
+import (
+	"net/http"
+
+	"github.com/co-defi/api-server/common"
+	"github.com/labstack/echo/v4"
+)
+
+// WithLogLevels lets the operators change the levels of the logs at runtime through the admin routes
+func (s *HttpServer) WithLogLevels(levels *common.LogLevels) {
+	s.logLevels = levels
+}
+
+type logLevelsResponse struct {
+	Level   string            `json:"level"`
+	Modules map[string]string `json:"modules"`
+}
+
+func (s *HttpServer) getLogLevels(c echo.Context) error {
+	if s.logLevels == nil {
+		return common.ErrRouteNotFound
+	}
+
+	level, modules := s.logLevels.Levels()
+	return c.JSON(http.StatusOK, logLevelsResponse{Level: level, Modules: modules})
+}
+
+type setLogLevelRequest struct {
+	// Module is the module to set the level of, the default level is set without one
+	Module string `json:"module"`
+	Level  string `json:"level" validate:"required"`
+}
+
+// setLogLevel sets the level of a module or the default one until the server restarts
+func (s *HttpServer) setLogLevel(c echo.Context) error {
+	if s.logLevels == nil {
+		return common.ErrRouteNotFound
+	}
+	var req setLogLevelRequest
+	if err := bindRequest(c, &req); err != nil {
+		return err
+	}
+
+	level, err := common.ParseLogLevel(req.Level)
+	if err != nil {
+		return err
+	}
+	if err := s.logLevels.Set(req.Module, level); err != nil {
+		return err
+	}
+	s.logger.Info().Str("log_module", req.Module).Str("log_level", level.String()).Str("operator", c.Get(adminOperatorKey).(string)).Msg("log level changed")
+
+	return s.getLogLevels(c)
+}
+
+type resetLogLevelRequest struct {
+	Module string `param:"module" validate:"required"`
+}
+
+// resetLogLevel makes a module follow the default level again
+func (s *HttpServer) resetLogLevel(c echo.Context) error {
+	if s.logLevels == nil {
+		return common.ErrRouteNotFound
+	}
+	var req resetLogLevelRequest
+	if err := bindRequest(c, &req); err != nil {
+		return err
+	}
+
+	s.logLevels.Reset(req.Module)
+	return s.getLogLevels(c)
+}
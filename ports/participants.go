@@ -0,0 +1,148 @@
+package ports
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/co-defi/api-server/app/commands"
+	"github.com/co-defi/api-server/app/queries"
+	"github.com/co-defi/api-server/domain"
+	"github.com/labstack/echo/v4"
+)
+
+// meResponse is the profile of the authenticated participant, with the reputation and the notification settings
+// of the address they authenticated with
+type meResponse struct {
+	*queries.Participant
+	Reputation    *queries.Reputation           `json:"reputation"`
+	Notifications *queries.NotificationSettings `json:"notifications"`
+}
+
+func (s *HttpServer) getMe(c echo.Context) error {
+	auth, err := s.authDB.ExtractTokenFromHttp(c.Request())
+	if err != nil {
+		return err
+	}
+
+	ctx := c.Request().Context()
+	participant, err := s.app.Queries.Participants.GetByAddress(ctx, auth.Address)
+	if err != nil {
+		return err
+	}
+	reputation, err := s.app.Queries.Reputation.Get(ctx, auth.Address)
+	if err != nil {
+		return err
+	}
+	notifications, err := s.app.Queries.NotificationSettings.Get(ctx, auth.Address)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, meResponse{
+		Participant:   participant,
+		Reputation:    reputation,
+		Notifications: notifications,
+	})
+}
+
+type linkAddressRequest struct {
+	// Token is a token of the address to link, proving the participant owns it too
+	Token string `json:"token,omitempty" validate:"required,max=4096"`
+}
+
+type linkAddressResponse struct {
+	ParticipantId string `json:"participant_id"`
+}
+
+func (s *HttpServer) linkAddress(c echo.Context) error {
+	var req linkAddressRequest
+	if err := bindRequest(c, &req); err != nil {
+		return err
+	}
+
+	auth, err := s.authDB.ExtractTokenFromHttp(c.Request())
+	if err != nil {
+		return err
+	}
+
+	linked, err := s.authDB.Authenticate(req.Token)
+	if err != nil {
+		return err
+	}
+	if err := s.rejectBlocked(c, linked.Address); err != nil {
+		return err
+	}
+
+	id, err := s.app.Commands.LinkParticipantAddress.Handle(c.Request().Context(), commands.LinkParticipantAddress{
+		ParticipantAddress: auth.Address,
+		Chain:              linked.Chain,
+		Address:            linked.Address,
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, linkAddressResponse{ParticipantId: id})
+}
+
+type getParticipantRequest struct {
+	Address domain.Address `param:"address" validate:"required"`
+}
+
+func (s *HttpServer) getParticipant(c echo.Context) error {
+	var req getParticipantRequest
+	if err := bindRequest(c, &req); err != nil {
+		return err
+	}
+
+	participant, err := s.app.Queries.Participants.GetByAddress(c.Request().Context(), req.Address)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, participant)
+}
+
+// requestDataExport starts exporting the personal data of the authenticated address, the export is polled with getDataExport
+func (s *HttpServer) requestDataExport(c echo.Context) error {
+	auth, err := s.authDB.ExtractTokenFromHttp(c.Request())
+	if err != nil {
+		return err
+	}
+
+	export, err := s.app.DataExports.Request(c.Request().Context(), auth.Address)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusAccepted, export)
+}
+
+func (s *HttpServer) getDataExport(c echo.Context) error {
+	auth, err := s.authDB.ExtractTokenFromHttp(c.Request())
+	if err != nil {
+		return err
+	}
+
+	export, err := s.app.DataExports.Get(c.Request().Context(), auth.Address)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, export)
+}
+
+func (s *HttpServer) downloadDataExport(c echo.Context) error {
+	auth, err := s.authDB.ExtractTokenFromHttp(c.Request())
+	if err != nil {
+		return err
+	}
+
+	archive, err := s.app.DataExports.Archive(c.Request().Context(), auth.Address)
+	if err != nil {
+		return err
+	}
+
+	c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", "co-defi-export-"+string(auth.Address)+".zip"))
+	return c.Blob(http.StatusOK, "application/zip", archive)
+}
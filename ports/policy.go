@@ -0,0 +1,222 @@
+package ports
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/co-defi/api-server/common"
+	"github.com/labstack/echo/v4"
+)
+
+// AuthRequirement is the authentication a route requires
+type AuthRequirement string
+
+const (
+	// AuthNone lets anyone call the route
+	AuthNone AuthRequirement = "none"
+	// AuthParticipant requires the token of a participant, see common.AuthenticationDB
+	AuthParticipant AuthRequirement = "participant"
+	// AuthAdmin requires the admin token of an operator, see WithAdminTokens
+	AuthAdmin AuthRequirement = "admin"
+)
+
+// RoutePolicy is who may call a route: the authentication it requires and the networks the requests must come from
+type RoutePolicy struct {
+	Auth AuthRequirement
+	// AllowedIPs are the networks allowed to call the route, any when empty
+	AllowedIPs []*net.IPNet
+}
+
+var ErrIPNotAllowed = common.NewError("ip_not_allowed", "requests from this address are not allowed on this route")
+
+// defaultRoutePolicies are the policies of the routes by path without the API version, a path ending with /* applies
+// to the routes under it which don't have their own. Every route must be covered by a policy, the server doesn't start
+// otherwise, and the requests to the paths without one, such as the unknown ones, get strictestRoutePolicy.
+var defaultRoutePolicies = map[string]RoutePolicy{
+	"/auth/init":       {Auth: AuthNone},
+	"/auth/verify":     {Auth: AuthNone},
+	"/auth/sessions":   {Auth: AuthParticipant},
+	"/auth/sessions/*": {Auth: AuthParticipant},
+
+	"/server-key": {Auth: AuthNone},
+	"/assets":     {Auth: AuthNone},
+	"/fees":       {Auth: AuthNone},
+	"/plans":      {Auth: AuthNone},
+	"/plan/:id":   {Auth: AuthNone},
+
+	"/pairs":   {Auth: AuthParticipant},
+	"/pairs/*": {Auth: AuthParticipant},
+
+	"/participants/:address/reputation": {Auth: AuthNone},
+	"/stats":                            {Auth: AuthNone},
+	"/stats/timeseries":                 {Auth: AuthNone},
+
+	"/me":   {Auth: AuthParticipant},
+	"/me/*": {Auth: AuthParticipant},
+
+	"/admin/*": {Auth: AuthAdmin},
+	// the dashboard only serves its static files, it calls the admin routes with the token of the operator
+	"/admin/ui":   {Auth: AuthNone},
+	"/admin/ui/*": {Auth: AuthNone},
+}
+
+// strictestRoutePolicy is the policy of the paths which aren't covered by any policy
+var strictestRoutePolicy = RoutePolicy{Auth: AuthAdmin}
+
+// WithRouteAuth sets the authentication the routes require by path without the API version (e.g. /admin/metrics or /admin/*),
+// the routes not set keep their default one
+func (s *HttpServer) WithRouteAuth(routes map[string]AuthRequirement) {
+	for route, auth := range routes {
+		policy, _ := s.routePolicy(route)
+		policy.Auth = auth
+		s.routePolicies[route] = policy
+	}
+}
+
+// WithRouteAllowedIPs restricts the routes to the networks by path without the API version (e.g. /admin/*),
+// the routes not set keep their default allowlist
+func (s *HttpServer) WithRouteAllowedIPs(routes map[string][]*net.IPNet) {
+	for route, networks := range routes {
+		policy, _ := s.routePolicy(route)
+		policy.AllowedIPs = networks
+		s.routePolicies[route] = policy
+	}
+}
+
+// WithTrustedProxies trusts the X-Forwarded-For header set by the proxies of the networks to tell the address of the clients.
+// Without trusted proxies, the address of the clients is the remote address of their connection, so the allowlists can't be spoofed.
+func (s *HttpServer) WithTrustedProxies(proxies []*net.IPNet) {
+	options := []echo.TrustOption{echo.TrustLoopback(false), echo.TrustLinkLocal(false), echo.TrustPrivateNet(false)}
+	for _, proxy := range proxies {
+		options = append(options, echo.TrustIPRange(proxy))
+	}
+	s.echo.IPExtractor = echo.ExtractIPFromXFFHeader(options...)
+}
+
+// routePolicy returns the policy of the route: its own one, else the one of the closest path ending with /* above it.
+// The routes covered by none get strictestRoutePolicy and false.
+func (s *HttpServer) routePolicy(route string) (RoutePolicy, bool) {
+	if policy, ok := s.routePolicies[route]; ok {
+		return policy, true
+	}
+	for prefix := route; ; {
+		i := strings.LastIndex(prefix, "/")
+		if i < 0 {
+			break
+		}
+		prefix = prefix[:i]
+		if policy, ok := s.routePolicies[prefix+"/*"]; ok {
+			return policy, true
+		}
+	}
+
+	return strictestRoutePolicy, false
+}
+
+// checkRoutePolicies fails when a registered route isn't covered by a policy, so that no route is served without
+// the authentication it was meant to require
+func (s *HttpServer) checkRoutePolicies() error {
+	var uncovered []string
+	for _, route := range s.echo.Routes() {
+		if _, ok := s.routePolicy(unversionedPath(route.Path)); !ok {
+			uncovered = append(uncovered, route.Method+" "+route.Path)
+		}
+	}
+	if len(uncovered) == 0 {
+		return nil
+	}
+
+	sort.Strings(uncovered)
+	return fmt.Errorf("routes without a policy: %s", strings.Join(uncovered, ", "))
+}
+
+// enforcePolicies is a middleware rejecting the requests which don't satisfy the policy of their route,
+// before their body is even read
+func (s *HttpServer) enforcePolicies(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		policy, _ := s.routePolicy(unversionedPath(c.Path()))
+
+		if len(policy.AllowedIPs) > 0 && !ipAllowed(s.clientIP(c), policy.AllowedIPs) {
+			return ErrIPNotAllowed
+		}
+
+		switch policy.Auth {
+		case AuthParticipant:
+			if _, err := s.authDB.ExtractTokenFromHttp(c.Request()); err != nil {
+				return err
+			}
+		case AuthAdmin:
+			operator, ok := s.authenticateAdmin(c.Request())
+			if !ok {
+				var err error
+				if operator, err = s.authenticateAdminAPIKey(c); err != nil {
+					return err
+				}
+			}
+			c.Set(adminOperatorKey, operator)
+		}
+
+		return next(c)
+	}
+}
+
+// clientIP returns the address of the client, as told by the trusted proxies if any
+func (s *HttpServer) clientIP(c echo.Context) net.IP {
+	if s.echo.IPExtractor != nil {
+		return net.ParseIP(c.RealIP())
+	}
+
+	return net.ParseIP(echo.ExtractIPDirect()(c.Request()))
+}
+
+func ipAllowed(ip net.IP, networks []*net.IPNet) bool {
+	if ip == nil {
+		return false
+	}
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ParseAuthRequirement parses the authentication required by a route: none, participant or admin
+func ParseAuthRequirement(value string) (AuthRequirement, error) {
+	switch auth := AuthRequirement(value); auth {
+	case AuthNone, AuthParticipant, AuthAdmin:
+		return auth, nil
+	default:
+		return "", fmt.Errorf("invalid auth requirement %q, must be none, participant or admin", value)
+	}
+}
+
+// ParseNetworks parses the networks in CIDR notation, the single addresses are networks of their own
+func ParseNetworks(values []string) ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, 0, len(values))
+	for _, value := range values {
+		if !strings.Contains(value, "/") {
+			ip := net.ParseIP(value)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid IP address %q", value)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, network, err := net.ParseCIDR(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid network %q: %w", value, err)
+		}
+		networks = append(networks, network)
+	}
+
+	return networks, nil
+}
@@ -0,0 +1,64 @@
+package ports
+
+import (
+	"net/http"
+
+	"github.com/co-defi/api-server/common"
+	"github.com/labstack/echo/v4"
+)
+
+func (s *HttpServer) getProjections(c echo.Context) error {
+	statuses, err := s.app.ProjectionStatuses(c.Request().Context())
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, statuses)
+}
+
+type resetProjectionRequest struct {
+	Name string `param:"name" validate:"required"`
+}
+
+type resetProjectionResponse struct {
+	Name string `json:"name"`
+}
+
+// resetProjection requests the projection to be rebuilt, it's accepted as the projection is reset on its next run
+func (s *HttpServer) resetProjection(c echo.Context) error {
+	var req resetProjectionRequest
+	if err := bindRequest(c, &req); err != nil {
+		return err
+	}
+
+	if err := s.app.ResetProjection(req.Name); err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusAccepted, resetProjectionResponse{Name: req.Name})
+}
+
+type getDeadLettersRequest struct {
+	Projection string `query:"projection"`
+	Limit      int    `query:"limit" validate:"omitempty,min=1,max=1000"`
+}
+
+type getDeadLettersResponse struct {
+	// Counts are the numbers of dead letters parked by every projection
+	Counts  map[string]int      `json:"counts"`
+	Letters []common.DeadLetter `json:"letters"`
+}
+
+func (s *HttpServer) getDeadLetters(c echo.Context) error {
+	var req getDeadLettersRequest
+	if err := bindRequest(c, &req); err != nil {
+		return err
+	}
+
+	letters, counts, err := s.app.DeadLetters(c.Request().Context(), req.Projection, req.Limit)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, getDeadLettersResponse{Counts: counts, Letters: letters})
+}
@@ -0,0 +1,119 @@
+package ports
+
+import (
+	"net/http"
+
+	"github.com/co-defi/api-server/app/commands"
+	"github.com/co-defi/api-server/app/queries"
+	"github.com/co-defi/api-server/app/relay"
+	"github.com/co-defi/api-server/domain"
+	"github.com/labstack/echo/v4"
+)
+
+// relayStatuses are the statuses in which the participants of a pair run TSS ceremonies, i.e. matched but not yet finished
+var relayStatuses = map[domain.PairStatus]bool{
+	domain.PairStatusWalletConformation:      true,
+	domain.PairStatusAssurance:               true,
+	domain.PairStatusDeposit:                 true,
+	domain.PairStatusPreSignWithdrawal:       true,
+	domain.PairStatusLP:                      true,
+	domain.PairStatusPreSignSaversWithdrawal: true,
+	domain.PairStatusSavers:                  true,
+}
+
+// relayCounterparty authorizes the participant to relay messages through the pair and returns the counterparty
+func (s *HttpServer) relayCounterparty(c echo.Context, pairId string, participant domain.Address) (domain.Address, error) {
+	pair, err := s.app.Queries.Pairs.Get(c.Request().Context(), pairId)
+	if err != nil {
+		return "", err
+	}
+	if !pairHasAddress(pair, participant) {
+		return "", commands.ErrForbiddenPairForAddress
+	}
+	if !relayStatuses[pair.Status] {
+		return "", commands.ErrInvalidPairStatus
+	}
+
+	return counterpartyOf(pair, participant), nil
+}
+
+func counterpartyOf(pair *queries.Pair, participant domain.Address) domain.Address {
+	for _, address := range pair.ParticipantAddresses {
+		if address != participant {
+			return address
+		}
+	}
+
+	return domain.EmptyAddress
+}
+
+type postRelayMessageRequest struct {
+	PairId  string            `param:"id" json:"-" validate:"required,uuid4"`
+	Kind    relay.MessageKind `json:"kind,omitempty" validate:"required,oneof=keygen keysign"`
+	Round   string            `json:"round,omitempty" validate:"required,max=64"`
+	Payload []byte            `json:"payload,omitempty" validate:"required,max=65536"`
+}
+
+type postRelayMessageResponse struct {
+	Id int64 `json:"id"`
+}
+
+func (s *HttpServer) postRelayMessage(c echo.Context) error {
+	var req postRelayMessageRequest
+	if err := bindRequest(c, &req); err != nil {
+		return err
+	}
+
+	auth, err := s.authDB.ExtractTokenFromHttp(c.Request())
+	if err != nil {
+		return err
+	}
+
+	counterparty, err := s.relayCounterparty(c, req.PairId, auth.Address)
+	if err != nil {
+		return err
+	}
+
+	id, err := s.app.Relay.Post(c.Request().Context(), relay.Message{
+		PairId:  req.PairId,
+		From:    auth.Address,
+		To:      counterparty,
+		Kind:    req.Kind,
+		Round:   req.Round,
+		Payload: req.Payload,
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, postRelayMessageResponse{Id: id})
+}
+
+type getRelayMessagesRequest struct {
+	PairId string `param:"id" validate:"required,uuid4"`
+	After  int64  `query:"after" validate:"min=0"`
+	Limit  int    `query:"limit" validate:"omitempty,min=1,max=1000"`
+}
+
+func (s *HttpServer) getRelayMessages(c echo.Context) error {
+	var req getRelayMessagesRequest
+	if err := bindRequest(c, &req); err != nil {
+		return err
+	}
+
+	auth, err := s.authDB.ExtractTokenFromHttp(c.Request())
+	if err != nil {
+		return err
+	}
+
+	if _, err := s.relayCounterparty(c, req.PairId, auth.Address); err != nil {
+		return err
+	}
+
+	messages, err := s.app.Relay.Receive(c.Request().Context(), req.PairId, auth.Address, req.After, req.Limit)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, messages)
+}
@@ -0,0 +1,306 @@
+package ports
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/co-defi/api-server/domain"
+	"github.com/labstack/echo/v4"
+)
+
+// requestSchema describes the request a route expects, generated from the request type its handler binds
+type requestSchema struct {
+	Method string        `json:"method"`
+	Route  string        `json:"route"`
+	Fields []schemaField `json:"fields"`
+	// Example is a body satisfying the rules of the body fields, the fields sent in the path, the query or the headers aren't part of it
+	Example map[string]interface{} `json:"example,omitempty"`
+}
+
+// schemaField is a field of the request, the rules are the validation rules it must satisfy as declared on the request type
+type schemaField struct {
+	Name string `json:"name"`
+	// In is where the field is sent: body, path, query or header
+	In       string `json:"in"`
+	Type     string `json:"type"`
+	Rules    string `json:"rules,omitempty"`
+	Required bool   `json:"required"`
+}
+
+// maxExampleDepth bounds the nested objects of the examples, so the recursive types end
+const maxExampleDepth = 4
+
+// requestSchemas caches the schemas by method and route, they are generated the first time a request of the route is rejected
+var requestSchemas sync.Map
+
+// withRequestSchema includes the schema of the route in the error of the request which failed to bind or validate,
+// so the integrators can fix their requests on their own. The other errors are returned as is.
+func withRequestSchema(c echo.Context, req interface{}, err error) error {
+	commonErr := toCommonError(err)
+	if commonErr.Code != "invalid_request" || c.Path() == "" {
+		return err
+	}
+
+	key := c.Request().Method + " " + c.Path()
+	schema, ok := requestSchemas.Load(key)
+	if !ok {
+		schema, _ = requestSchemas.LoadOrStore(key, newRequestSchema(c.Request().Method, c.Path(), reflect.TypeOf(req)))
+	}
+
+	meta := make(map[string]interface{}, len(commonErr.Meta)+1)
+	for field, message := range commonErr.Meta {
+		meta[field] = message
+	}
+	meta["schema"] = schema
+
+	return commonErr.IncludeMeta(meta)
+}
+
+func newRequestSchema(method, route string, t reflect.Type) *requestSchema {
+	schema := &requestSchema{Method: method, Route: route, Fields: make([]schemaField, 0)}
+	example := make(map[string]interface{})
+	collectSchemaFields(schema, example, t)
+	if len(example) > 0 {
+		schema.Example = example
+	}
+
+	return schema
+}
+
+// collectSchemaFields adds the fields of the request type to the schema and the example values of its body fields,
+// the fields of the embedded types are flattened as echo binds them
+func collectSchemaFields(schema *requestSchema, example map[string]interface{}, t reflect.Type) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Anonymous && f.Tag.Get("json") == "" {
+			collectSchemaFields(schema, example, f.Type)
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+
+		rules := f.Tag.Get("validate")
+		field := schemaField{Type: schemaType(f.Type), Rules: rules, Required: hasRule(rules, "required")}
+		switch {
+		case tagName(f.Tag.Get("param")) != "":
+			field.Name, field.In = tagName(f.Tag.Get("param")), "path"
+		case tagName(f.Tag.Get("query")) != "":
+			field.Name, field.In = tagName(f.Tag.Get("query")), "query"
+		case tagName(f.Tag.Get("header")) != "":
+			field.Name, field.In = tagName(f.Tag.Get("header")), "header"
+		default:
+			name := tagName(f.Tag.Get("json"))
+			if name == "-" {
+				continue
+			}
+			if name == "" {
+				name = f.Name
+			}
+			field.Name, field.In = name, "body"
+			example[name] = exampleValue(f.Type, rules, 0)
+		}
+		schema.Fields = append(schema.Fields, field)
+	}
+}
+
+func tagName(tag string) string {
+	name, _, _ := strings.Cut(tag, ",")
+	return name
+}
+
+// fieldRules returns the rules applying to the field itself, the ones after dive apply to its elements
+func fieldRules(rules string) (own, elements string) {
+	parts := strings.Split(rules, ",")
+	for i, part := range parts {
+		if part == "dive" {
+			return strings.Join(parts[:i], ","), strings.Join(parts[i+1:], ",")
+		}
+	}
+
+	return rules, ""
+}
+
+func hasRule(rules, name string) bool {
+	_, ok := ruleParam(rules, name)
+	return ok
+}
+
+// ruleParam returns the parameter of the rule of the field, e.g. 1 for min=1
+func ruleParam(rules, name string) (string, bool) {
+	own, _ := fieldRules(rules)
+	for _, rule := range strings.Split(own, ",") {
+		for _, alternative := range strings.Split(rule, "|") {
+			tag, param, _ := strings.Cut(alternative, "=")
+			if tag == name {
+				return param, true
+			}
+		}
+	}
+
+	return "", false
+}
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	durationType = reflect.TypeOf(domain.Duration(0))
+)
+
+// schemaType names the JSON type of the field
+func schemaType(t reflect.Type) string {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == timeType:
+		return "string (RFC 3339 time)"
+	case t == durationType:
+		return "string (duration)"
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "integer"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return "string (base64)"
+		}
+		return "array of " + schemaType(t.Elem())
+	case reflect.Map:
+		return "object of " + schemaType(t.Elem())
+	default:
+		return "object"
+	}
+}
+
+// exampleValue returns a value of the type satisfying the usual rules, the rules it can't tell a value for are ignored
+func exampleValue(t reflect.Type, rules string, depth int) interface{} {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	_, elementRules := fieldRules(rules)
+
+	switch {
+	case t == timeType:
+		return "2024-01-01T00:00:00Z"
+	case t == durationType:
+		return "24h"
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return exampleString(rules)
+	case reflect.Bool:
+		return hasRule(rules, "required")
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(exampleNumber(rules, 1))
+	case reflect.Float32, reflect.Float64:
+		return exampleNumber(rules, 0.1)
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return "AA=="
+		}
+		return []interface{}{exampleValue(t.Elem(), elementRules, depth)}
+	case reflect.Map:
+		// the rules of the keys are enclosed by keys and endkeys, e.g. dive,keys,asset,endkeys,required
+		keyRules, valueRules := "", elementRules
+		if rest, ok := strings.CutPrefix(elementRules, "keys,"); ok {
+			keyRules, valueRules, _ = strings.Cut(rest, ",endkeys")
+			valueRules = strings.TrimPrefix(valueRules, ",")
+		}
+		key := fmt.Sprint(exampleValue(t.Key(), keyRules, depth))
+		return map[string]interface{}{key: exampleValue(t.Elem(), valueRules, depth)}
+	case reflect.Struct:
+		if depth >= maxExampleDepth {
+			return map[string]interface{}{}
+		}
+		object := make(map[string]interface{})
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			name := tagName(f.Tag.Get("json"))
+			if !f.IsExported() || name == "-" {
+				continue
+			}
+			if name == "" {
+				name = f.Name
+			}
+			object[name] = exampleValue(f.Type, f.Tag.Get("validate"), depth+1)
+		}
+		return object
+	default:
+		return nil
+	}
+}
+
+func exampleString(rules string) interface{} {
+	if values, ok := ruleParam(rules, "oneof"); ok {
+		return strings.Fields(values)[0]
+	}
+
+	switch {
+	case hasRule(rules, "uuid4"):
+		return "6f1c2b8e-3d4a-4f5b-9c6d-7e8f9a0b1c2d"
+	case hasRule(rules, "asset"):
+		return string(domain.RuneAsset)
+	case hasRule(rules, "network"):
+		return string(domain.NetworkMainnet)
+	case hasRule(rules, "timeout_status"):
+		return string(domain.TimeoutStatuses[0])
+	case hasRule(rules, "tx_hash"):
+		return strings.Repeat("ab", 32)
+	case hasRule(rules, "address"):
+		return "0x" + strings.Repeat("ab", 20)
+	case hasRule(rules, "email"):
+		return "ops@example.com"
+	case hasRule(rules, "url"):
+		return "https://example.com"
+	case hasRule(rules, "hexadecimal"):
+		return "ab"
+	case hasRule(rules, "number"), hasRule(rules, "numeric"):
+		return "1"
+	}
+
+	return "string"
+}
+
+// exampleNumber returns the lower bound of the number when it has one, otherwise the given value
+func exampleNumber(rules string, value float64) float64 {
+	if values, ok := ruleParam(rules, "oneof"); ok {
+		value, _ = strconv.ParseFloat(strings.Fields(values)[0], 64)
+		return value
+	}
+	for _, bound := range []string{"min", "gte", "gt"} {
+		param, ok := ruleParam(rules, bound)
+		if !ok {
+			continue
+		}
+		lower, err := strconv.ParseFloat(param, 64)
+		if err != nil {
+			continue
+		}
+		if bound == "gt" {
+			lower++
+		}
+		return max(lower, value)
+	}
+
+	return value
+}
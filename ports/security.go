@@ -0,0 +1,71 @@
+package ports
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/co-defi/api-server/common"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+)
+
+const (
+	// apiContentSecurityPolicy forbids the JSON responses of the API from loading or being framed by anything
+	apiContentSecurityPolicy = "default-src 'none'; frame-ancestors 'none'"
+	// swaggerContentSecurityPolicy lets the Swagger UI load its own scripts, styles and images and call the API
+	swaggerContentSecurityPolicy = "default-src 'self'; script-src 'self'; style-src 'self' 'unsafe-inline'; img-src 'self' data:; connect-src 'self'; frame-ancestors 'none'"
+	// swaggerPathPrefix is the path the Swagger UI is served under
+	swaggerPathPrefix = "/swagger"
+	// dashboardContentSecurityPolicy only lets the admin dashboard load its own scripts and styles and call the API
+	dashboardContentSecurityPolicy = "default-src 'none'; script-src 'self'; style-src 'self'; connect-src 'self'; base-uri 'none'; form-action 'none'; frame-ancestors 'none'"
+	// dashboardPathPrefix is the path the admin dashboard is served under, without the API version
+	dashboardPathPrefix = "/admin/ui"
+)
+
+// WithCORS restricts the cross-origin requests to the given origins, methods and headers,
+// every origin is allowed with the default methods and headers of echo when none are set
+func (s *HttpServer) WithCORS(origins, methods, headers []string) {
+	s.cors = middleware.CORSWithConfig(middleware.CORSConfig{
+		AllowOrigins: origins,
+		AllowMethods: methods,
+		AllowHeaders: headers,
+		// The browser wallets verifying the signed responses need to read their signature
+		ExposeHeaders: []string{common.HeaderSignature, common.HeaderSignatureKeyId, common.HeaderSignatureTimestamp},
+	})
+}
+
+// WithHSTS sets how long the browsers must only reach the server over HTTPS, 0 disables the Strict-Transport-Security header
+func (s *HttpServer) WithHSTS(maxAgeSeconds int) {
+	s.hstsMaxAge = maxAgeSeconds
+}
+
+// handleCORS is a middleware applying the CORS policy configured with WithCORS
+func (s *HttpServer) handleCORS(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		return s.cors(next)(c)
+	}
+}
+
+// hardenResponses is a middleware setting the standard security headers on every response.
+// HSTS is only sent over TLS, directly or behind a proxy terminating it, as the browsers ignore it over plaintext HTTP.
+func (s *HttpServer) hardenResponses(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		h := c.Response().Header()
+		h.Set(echo.HeaderXContentTypeOptions, "nosniff")
+		h.Set(echo.HeaderXFrameOptions, "DENY")
+		h.Set(echo.HeaderReferrerPolicy, "no-referrer")
+		switch path := c.Request().URL.Path; {
+		case strings.HasPrefix(path, swaggerPathPrefix):
+			h.Set(echo.HeaderContentSecurityPolicy, swaggerContentSecurityPolicy)
+		case strings.HasPrefix(unversionedPath(path), dashboardPathPrefix):
+			h.Set(echo.HeaderContentSecurityPolicy, dashboardContentSecurityPolicy)
+		default:
+			h.Set(echo.HeaderContentSecurityPolicy, apiContentSecurityPolicy)
+		}
+		if s.hstsMaxAge > 0 && (c.IsTLS() || c.Request().Header.Get(echo.HeaderXForwardedProto) == "https") {
+			h.Set(echo.HeaderStrictTransportSecurity, fmt.Sprintf("max-age=%d; includeSubDomains", s.hstsMaxAge))
+		}
+
+		return next(c)
+	}
+}
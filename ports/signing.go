@@ -0,0 +1,88 @@
+package ports
+
+import (
+	"bytes"
+	"encoding/base64"
+	"net/http"
+	"strconv"
+
+	"github.com/co-defi/api-server/common"
+	"github.com/labstack/echo/v4"
+)
+
+// WithResponseSigning signs the responses of the pair state routes with the signer, the clients fetch its public key from /server-key
+func (s *HttpServer) WithResponseSigning(signer *common.ResponseSigner) {
+	s.signer = signer
+}
+
+// bufferedResponse holds the response of the handler until it's signed
+type bufferedResponse struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *bufferedResponse) WriteHeader(status int) {
+	w.status = status
+}
+
+func (w *bufferedResponse) Write(b []byte) (int, error) {
+	return w.body.Write(b)
+}
+
+// signResponses is a route middleware signing the successful responses of the route with the response signer, when enabled.
+// The signature covers the request path, the time of signing and the exact body sent, see common.VerifyResponse.
+func (s *HttpServer) signResponses(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if s.signer == nil {
+			return next(c)
+		}
+
+		res := c.Response()
+		original := res.Writer
+		buffered := &bufferedResponse{ResponseWriter: original, status: http.StatusOK}
+		res.Writer = buffered
+		err := next(c)
+		res.Writer = original
+		if !res.Committed {
+			return err
+		}
+
+		if buffered.status >= http.StatusOK && buffered.status < http.StatusMultipleChoices && buffered.body.Len() > 0 {
+			now := s.app.Clock.Now()
+			h := original.Header()
+			h.Set(common.HeaderSignature, s.signer.Sign(c.Request().URL.Path, now, buffered.body.Bytes()))
+			h.Set(common.HeaderSignatureKeyId, s.signer.KeyId())
+			h.Set(common.HeaderSignatureTimestamp, strconv.FormatInt(now.Unix(), 10))
+		}
+		original.WriteHeader(buffered.status)
+		if _, werr := original.Write(buffered.body.Bytes()); werr != nil && err == nil {
+			err = werr
+		}
+
+		return err
+	}
+}
+
+type serverKey struct {
+	KeyId     string `json:"key_id"`
+	Algorithm string `json:"algorithm"`
+	// PublicKey is the base64 Ed25519 public key
+	PublicKey string `json:"public_key"`
+	// SignedHeaders are the response headers carrying the signature, its key id and its unix time
+	SignedHeaders []string `json:"signed_headers"`
+}
+
+// getServerKey returns the public key verifying the signed responses, signed over "<path>\n<timestamp>\n<body>"
+func (s *HttpServer) getServerKey(c echo.Context) error {
+	if s.signer == nil {
+		return common.ErrServerKeyNotFound
+	}
+
+	return c.JSON(http.StatusOK, serverKey{
+		KeyId:         s.signer.KeyId(),
+		Algorithm:     "Ed25519",
+		PublicKey:     base64.StdEncoding.EncodeToString(s.signer.PublicKey()),
+		SignedHeaders: []string{common.HeaderSignature, common.HeaderSignatureKeyId, common.HeaderSignatureTimestamp},
+	})
+}
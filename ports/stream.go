@@ -0,0 +1,86 @@
+package ports
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/co-defi/api-server/app/queries"
+	"github.com/co-defi/api-server/domain"
+	"github.com/labstack/echo/v4"
+)
+
+// streamHeartbeat is how often an idle stream is written a comment, so the proxies don't close it
+const streamHeartbeat = 25 * time.Second
+
+// streamRetry is how long in milliseconds the clients wait before they reconnect a closed stream
+const streamRetry = 3000
+
+// streamMyPairs streams the pairs the authenticated address participates in as server-sent events. Every pair is sent
+// as a pair event once the stream opens, then again each time its version advances, until the client disconnects or the
+// request times out. The events are identified by the id and the version of the pair, the clients reconnect the stream
+// and keep the pair of the greatest version they received; the archived pairs aren't streamed.
+func (s *HttpServer) streamMyPairs(c echo.Context) error {
+	auth, err := s.authDB.ExtractTokenFromHttp(c.Request())
+	if err != nil {
+		return err
+	}
+
+	network := domain.NetworkOrDefault(auth.Network)
+	filter := queries.PairFilter{Network: &network, ParticipantAddresses: []domain.Address{auth.Address}}
+	ctx := c.Request().Context()
+
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, "text/event-stream")
+	res.Header().Set(echo.HeaderCacheControl, "no-cache")
+	res.Header().Set("X-Accel-Buffering", "no")
+	res.WriteHeader(http.StatusOK)
+	if _, err := fmt.Fprintf(res, "retry: %d\n\n", streamRetry); err != nil {
+		return nil
+	}
+	res.Flush()
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	versions := map[string]int{}
+	for {
+		changed := s.app.Queries.Pairs.WatchParticipant(auth.Address)
+		pairs, err := s.app.Queries.Pairs.Find(ctx, filter)
+		if err != nil {
+			// the response is already committed, the client reconnects once the stream is closed
+			s.logger.Error().Err(err).Str("address", auth.Address).Msg("failed to stream pairs")
+			return nil
+		}
+
+		for _, pair := range pairs {
+			if version, ok := versions[pair.Id]; ok && version == pair.Version {
+				continue
+			}
+			data, err := json.Marshal(pair)
+			if err != nil {
+				return nil
+			}
+			if _, err := fmt.Fprintf(res, "id: %s:%d\nevent: pair\ndata: %s\n\n", pair.Id, pair.Version, data); err != nil {
+				return nil
+			}
+			versions[pair.Id] = pair.Version
+		}
+		res.Flush()
+
+		for waiting := true; waiting; {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-changed:
+				waiting = false
+			case <-heartbeat.C:
+				if _, err := fmt.Fprint(res, ": heartbeat\n\n"); err != nil {
+					return nil
+				}
+				res.Flush()
+			}
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package ports
+
+import (
+	"context"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// defaultRequestTimeout is how long a request may take, unless set otherwise for its route
+const defaultRequestTimeout = 15 * time.Second
+
+// defaultRouteTimeouts are how long the routes which wait on purpose may take, the long-polls wait up to a minute
+// and the streams are closed every half an hour for the clients to reconnect
+var defaultRouteTimeouts = map[string]time.Duration{
+	"/pairs/:id/wait": 75 * time.Second,
+	"/me/stream":      30 * time.Minute,
+}
+
+// WithRequestTimeouts sets how long the requests may take, by default and by route path without the API version (e.g. /pairs/:id/wait).
+// The routes not set keep their default timeout.
+func (s *HttpServer) WithRequestTimeouts(defaultTimeout time.Duration, routes map[string]time.Duration) {
+	if defaultTimeout > 0 {
+		s.requestTimeout = defaultTimeout
+	}
+	for route, timeout := range routes {
+		s.routeTimeouts[route] = timeout
+	}
+}
+
+// timeoutRequests is a middleware setting the deadline of the request context to the timeout of its route.
+// The event store and the queries run with the request context, so a locked database fails the request with a
+// request timeout error instead of hanging it.
+func (s *HttpServer) timeoutRequests(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		timeout, ok := s.routeTimeouts[unversionedPath(c.Path())]
+		if !ok {
+			timeout = s.requestTimeout
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request().Context(), timeout)
+		defer cancel()
+		c.SetRequest(c.Request().WithContext(ctx))
+
+		return next(c)
+	}
+}
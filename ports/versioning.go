@@ -0,0 +1,89 @@
+package ports
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/co-defi/api-server/common"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// headerAPIVersion is the header the clients request a version of the API with on the unversioned routes,
+	// the responses carry the version they were served with in it
+	headerAPIVersion = "API-Version"
+	// defaultAPIVersion is the version the unversioned routes are served with when the client doesn't request one
+	defaultAPIVersion = "v1"
+)
+
+// apiVersions registers the routes of each version of the API under its /{version} group.
+// A new version registers its own handlers for the routes whose requests or responses change, they share the commands
+// and queries of the application with the previous versions' handlers, and the unchanged handlers are registered as is.
+var apiVersions = map[string]func(s *HttpServer, g *echo.Group){
+	"v1": (*HttpServer).registerV1Routes,
+}
+
+var ErrUnsupportedAPIVersion = common.NewError("unsupported_api_version", "unsupported API version")
+
+// negotiateVersion is a pre-routing middleware serving the unversioned routes with the version requested in the API-Version
+// header, the default one otherwise. The unversioned routes are kept for the existing clients and flagged as deprecated,
+// with a link to their versioned successor.
+func (s *HttpServer) negotiateVersion(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		req := c.Request()
+		if version, _ := splitVersion(req.URL.Path); version != "" {
+			c.Response().Header().Set(headerAPIVersion, version)
+			return next(c)
+		}
+		if strings.HasPrefix(req.URL.Path, swaggerPathPrefix) {
+			return next(c)
+		}
+
+		version := req.Header.Get(headerAPIVersion)
+		if version == "" {
+			version = defaultAPIVersion
+		}
+		if _, ok := apiVersions[version]; !ok {
+			return ErrUnsupportedAPIVersion.IncludeMeta(map[string]interface{}{"supported": supportedVersions()})
+		}
+
+		h := c.Response().Header()
+		h.Set(headerAPIVersion, version)
+		h.Set("Deprecation", "true")
+		h.Add("Link", fmt.Sprintf(`</%s%s>; rel="successor-version"`, version, req.URL.Path))
+
+		req.URL.Path = "/" + version + req.URL.Path
+		if req.URL.RawPath != "" {
+			req.URL.RawPath = "/" + version + req.URL.RawPath
+		}
+
+		return next(c)
+	}
+}
+
+// splitVersion splits the version of the API off the path, the version is empty when the path isn't versioned
+func splitVersion(path string) (string, string) {
+	segment, rest, _ := strings.Cut(strings.TrimPrefix(path, "/"), "/")
+	if _, ok := apiVersions[segment]; !ok {
+		return "", path
+	}
+
+	return segment, "/" + rest
+}
+
+// unversionedPath returns the path of the route without the version of the API
+func unversionedPath(path string) string {
+	_, rest := splitVersion(path)
+	return rest
+}
+
+func supportedVersions() []string {
+	versions := make([]string, 0, len(apiVersions))
+	for version := range apiVersions {
+		versions = append(versions, version)
+	}
+	sort.Strings(versions)
+
+	return versions
+}
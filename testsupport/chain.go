@@ -0,0 +1,371 @@
+package testsupport
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+
+	"github.com/co-defi/api-server/app/commands"
+	"github.com/co-defi/api-server/domain"
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+)
+
+// Tx is a transaction of an in-memory chain
+type Tx struct {
+	Hash   domain.TxHash
+	From   domain.Address
+	To     domain.Address
+	Asset  domain.Asset
+	Amount *big.Int
+	Memo   string
+	// Pool is the pool the transaction adds liquidity to or withdraws it from
+	Pool domain.Asset
+	// Payouts holds the amounts a withdrawal paid out by asset
+	Payouts map[domain.Asset]domain.TokenAmount
+	// Block is the height of the block the transaction is mined in, 0 while it waits in the mempool
+	Block  int
+	Failed bool
+	Reason string
+}
+
+// Chain is an in-memory chain standing in for the RPC nodes in the tests. It implements every chain adapter of the commands:
+// the transactions are mined in a block of their own as soon as they are sent and get a confirmation for every block mined after.
+type Chain struct {
+	name  string
+	clock *Clock
+
+	mu       sync.Mutex
+	height   int
+	txs      map[domain.TxHash]*Tx
+	gasPrice *big.Int
+	fee      *big.Int
+}
+
+// transferGas is the gas used by a native transfer on the chains charging gas
+const transferGas = 21000
+
+// NewChain creates a new empty Chain named after the chain of the assets it holds, e.g. ETH.
+// ETH charges a gas price of 20 gwei and the other chains a flat fee of 0.02 of their native asset.
+func NewChain(name string, clock *Clock) *Chain {
+	c := &Chain{name: name, clock: clock, txs: make(map[domain.TxHash]*Tx)}
+	if name == "ETH" {
+		c.gasPrice = big.NewInt(20_000_000_000)
+	} else {
+		c.fee = big.NewInt(2_000_000)
+	}
+
+	return c
+}
+
+// Name returns the name of the chain
+func (c *Chain) Name() string {
+	return c.name
+}
+
+// Send mines the transaction in a new block and returns its hash, a hash is made up when the transaction has none
+func (c *Chain) Send(tx Tx) domain.TxHash {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.height++
+	if tx.Hash == "" {
+		tx.Hash = c.hash(fmt.Sprintf("tx-%d", c.height))
+	}
+	tx.Block = c.height
+	c.txs[tx.Hash] = &tx
+
+	return tx.Hash
+}
+
+// Hold puts the transaction in the mempool until the next block is mined and returns its hash
+func (c *Chain) Hold(tx Tx) domain.TxHash {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if tx.Hash == "" {
+		tx.Hash = c.hash(fmt.Sprintf("pending-%d-%d", c.height, len(c.txs)))
+	}
+	tx.Block = 0
+	c.txs[tx.Hash] = &tx
+
+	return tx.Hash
+}
+
+// Transfer sends the amount of the asset between the addresses
+func (c *Chain) Transfer(from, to domain.Address, asset domain.Asset, amount *big.Int) domain.TxHash {
+	return c.Send(Tx{From: from, To: to, Asset: asset, Amount: amount})
+}
+
+// AddLiquidity adds the asset held by the address to the pool
+func (c *Chain) AddLiquidity(from domain.Address, asset, pool domain.Asset) domain.TxHash {
+	return c.Send(Tx{From: from, Asset: asset, Pool: pool, Memo: "+:" + pool})
+}
+
+// Withdraw withdraws the liquidity of the address from the pool, paying out the amounts
+func (c *Chain) Withdraw(from domain.Address, pool domain.Asset, payouts map[domain.Asset]domain.TokenAmount) domain.TxHash {
+	return c.Send(Tx{From: from, Pool: pool, Memo: "-:" + pool + ":10000", Payouts: payouts})
+}
+
+// DepositIntoSavers deposits the asset held by the address into the Savers vault
+func (c *Chain) DepositIntoSavers(from domain.Address, asset, vault domain.Asset) domain.TxHash {
+	return c.Send(Tx{From: from, Asset: asset, Pool: vault, Memo: "+:" + vault})
+}
+
+// WithdrawFromSavers withdraws the whole position of the address from the Savers vault
+func (c *Chain) WithdrawFromSavers(from domain.Address, vault domain.Asset) domain.TxHash {
+	return c.Send(Tx{From: from, Pool: vault, Memo: "-:" + vault + ":10000"})
+}
+
+// Mine mines blocks, the transactions held in the mempool are mined in the first one.
+// Every block adds a confirmation to the mined transactions.
+func (c *Chain) Mine(blocks int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if blocks <= 0 {
+		return
+	}
+	for _, tx := range c.txs {
+		if tx.Block == 0 {
+			tx.Block = c.height + 1
+		}
+	}
+	c.height += blocks
+}
+
+// Fail makes the transaction fail with the reason
+func (c *Chain) Fail(hash domain.TxHash, reason string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if tx, ok := c.txs[hash]; ok {
+		tx.Failed = true
+		tx.Reason = reason
+	}
+}
+
+// Reorg moves the transaction to a new block, as a reorganization of the chain would
+func (c *Chain) Reorg(hash domain.TxHash) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if tx, ok := c.txs[hash]; ok {
+		c.height++
+		tx.Block = c.height
+	}
+}
+
+// Tx returns the transaction with the hash
+func (c *Chain) Tx(hash domain.TxHash) (Tx, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	tx, ok := c.txs[hash]
+	if !ok {
+		return Tx{}, false
+	}
+	return *tx, true
+}
+
+// SetGasPrice sets the gas price of the chain in the base units of its native asset
+func (c *Chain) SetGasPrice(gasPrice *big.Int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.gasPrice = gasPrice
+}
+
+// GasPrice returns the gas price of the chain, nil when it charges a flat fee
+func (c *Chain) GasPrice() *big.Int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.gasPrice
+}
+
+// SignTx encodes the transaction as pre-signed by the participants with the key of the pair's wallet, so it decodes back
+// to the same transaction on this chain and its signature verifies
+func (c *Chain) SignTx(tx commands.DecodedTx, key *ecdsa.PrivateKey) domain.SignedTx {
+	if tx.ChainId == "" {
+		tx.ChainId = c.name
+	}
+	encoded, _ := json.Marshal(tx)
+
+	signed := domain.SignedTx{Nonce: tx.Nonce, Tx: encoded, Chain: c.name}
+	signed.SignatureScheme, signed.PayloadHash, _ = c.SigningHash(signed)
+	signed.Signature, _ = ethcrypto.Sign(signed.PayloadHash, key)
+	return signed
+}
+
+// hash returns a hash unique to the chain for the seed, it must be called with the lock held
+func (c *Chain) hash(seed string) domain.TxHash {
+	sum := sha256.Sum256([]byte(c.name + ":" + seed))
+	return hex.EncodeToString(sum[:])
+}
+
+// mined returns the transaction with the hash if it's mined, it must be called with the lock held
+func (c *Chain) mined(hash domain.TxHash) (*Tx, error) {
+	tx, ok := c.txs[hash]
+	if !ok || tx.Block == 0 {
+		return nil, fmt.Errorf("transaction %s not found: %w", hash, commands.ErrTxMismatch)
+	}
+	if tx.Failed {
+		return nil, fmt.Errorf("transaction %s failed, %s: %w", hash, tx.Reason, commands.ErrTxMismatch)
+	}
+
+	return tx, nil
+}
+
+// VerifyLP implements the commands.LPVerifier interface
+func (c *Chain) VerifyLP(ctx context.Context, expected commands.LPTx) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	tx, err := c.mined(expected.TxHash)
+	if err != nil {
+		return err
+	}
+	if !strings.EqualFold(tx.From, expected.From) || tx.Pool != expected.Pool {
+		return fmt.Errorf("transaction doesn't add liquidity from %s to %s: %w", expected.From, expected.Pool, commands.ErrTxMismatch)
+	}
+
+	return nil
+}
+
+// VerifySavers implements the commands.SaversVerifier interface
+func (c *Chain) VerifySavers(ctx context.Context, expected commands.SaversTx) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	tx, err := c.mined(expected.TxHash)
+	if err != nil {
+		return err
+	}
+	if !strings.EqualFold(tx.From, expected.From) || tx.Asset != expected.Asset || tx.Pool != expected.Vault {
+		return fmt.Errorf("transaction doesn't deposit %s from %s into %s: %w", expected.Asset, expected.From, expected.Vault, commands.ErrTxMismatch)
+	}
+
+	return nil
+}
+
+// VerifyDeposit implements the commands.DepositVerifier interface
+func (c *Chain) VerifyDeposit(ctx context.Context, expected commands.DepositTx) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	tx, err := c.mined(expected.TxHash)
+	if err != nil {
+		return err
+	}
+	if !strings.EqualFold(tx.From, expected.From) || !strings.EqualFold(tx.To, expected.To) || tx.Asset != expected.Asset {
+		return fmt.Errorf("transaction doesn't transfer %s from %s to %s: %w", expected.Asset, expected.From, expected.To, commands.ErrTxMismatch)
+	}
+	if tx.Amount == nil || tx.Amount.Cmp(expected.Amount) != 0 {
+		return fmt.Errorf("transaction transfers %v instead of %s: %w", tx.Amount, expected.Amount, commands.ErrTxMismatch)
+	}
+
+	return nil
+}
+
+// DecodeTx implements the commands.TxDecoder interface for the transactions signed with SignTx
+func (c *Chain) DecodeTx(signed domain.SignedTx) (commands.DecodedTx, error) {
+	var tx commands.DecodedTx
+	if err := json.Unmarshal(signed.Tx, &tx); err != nil {
+		return commands.DecodedTx{}, fmt.Errorf("malformed transaction: %w", commands.ErrTxMismatch)
+	}
+	if tx.ChainId != c.name {
+		return commands.DecodedTx{}, fmt.Errorf("transaction is meant for %s: %w", tx.ChainId, commands.ErrTxMismatch)
+	}
+
+	return tx, nil
+}
+
+// SigningHash implements the commands.TxSigningHasher interface, the transactions are signed with secp256k1 over their SHA-256 hash
+func (c *Chain) SigningHash(signed domain.SignedTx) (domain.SignatureScheme, []byte, error) {
+	sum := sha256.Sum256(signed.Tx)
+	return domain.SignatureSchemeECDSASecp256k1, sum[:], nil
+}
+
+// DeriveAddress implements the commands.WalletDeriver interface, the address is a hash of the public key and the chain code
+func (c *Chain) DeriveAddress(publicKey, hexChainCode string) (domain.Address, error) {
+	sum := sha256.Sum256([]byte(publicKey + hexChainCode))
+	return fmt.Sprintf("%s-wallet-%x", strings.ToLower(c.name), sum[:20]), nil
+}
+
+// Broadcast implements the commands.TxBroadcaster interface by sending the decoded transaction
+func (c *Chain) Broadcast(ctx context.Context, signed domain.SignedTx) (domain.TxHash, error) {
+	tx, err := c.DecodeTx(signed)
+	if err != nil {
+		return "", err
+	}
+
+	return c.Send(Tx{From: tx.From, To: tx.To, Asset: tx.Asset, Amount: tx.Amount, Memo: tx.Memo}), nil
+}
+
+// VerifyWithdrawal implements the commands.WithdrawalVerifier interface
+func (c *Chain) VerifyWithdrawal(ctx context.Context, expected commands.WithdrawalTx) (map[domain.Asset]domain.TokenAmount, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if tx, ok := c.txs[expected.TxHash]; !ok || tx.Block == 0 {
+		return nil, commands.ErrTxPending
+	}
+	tx, err := c.mined(expected.TxHash)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.EqualFold(tx.From, expected.From) || tx.Pool != expected.Pool {
+		return nil, fmt.Errorf("transaction doesn't withdraw from %s: %w", expected.Pool, commands.ErrTxMismatch)
+	}
+
+	return tx.Payouts, nil
+}
+
+// CheckTx implements the commands.TxStatusChecker interface, the unknown transactions are reported as not mined
+func (c *Chain) CheckTx(ctx context.Context, hash domain.TxHash) (commands.TxConfirmation, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	tx, ok := c.txs[hash]
+	if !ok || tx.Block == 0 {
+		return commands.TxConfirmation{}, nil
+	}
+
+	return commands.TxConfirmation{
+		Mined:         true,
+		Failed:        tx.Failed,
+		Reason:        tx.Reason,
+		Confirmations: c.height - tx.Block + 1,
+		BlockHash:     c.hash(fmt.Sprintf("block-%d", tx.Block)),
+	}, nil
+}
+
+// EstimateFee implements the commands.FeeEstimator interface
+func (c *Chain) EstimateFee(ctx context.Context) (commands.FeeEstimate, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	estimate := commands.FeeEstimate{Chain: c.name, Asset: c.nativeAsset(), EstimatedAt: c.clock.Now()}
+	if c.gasPrice != nil {
+		estimate.GasPrice = c.gasPrice.String()
+		estimate.Fee = new(big.Int).Mul(c.gasPrice, big.NewInt(transferGas)).String()
+	} else {
+		estimate.Fee = c.fee.String()
+	}
+
+	return estimate, nil
+}
+
+// nativeAsset returns the asset the fees of the chain are paid in
+func (c *Chain) nativeAsset() domain.Asset {
+	if c.name == "THOR" {
+		return domain.RuneAsset
+	}
+	return c.name + "." + c.name
+}
@@ -0,0 +1,149 @@
+// Package testsupport runs the application against in-memory chains, a fake price oracle and a stopped clock, so the
+// integration tests of the commands can drive pairs through their lifecycle without RPC nodes.
+package testsupport
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/co-defi/api-server/adapters"
+	"github.com/co-defi/api-server/app"
+	"github.com/co-defi/api-server/app/queries"
+	"github.com/co-defi/api-server/common"
+	"github.com/co-defi/api-server/domain"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+)
+
+// Env is an application wired to in-memory chains and a stopped clock for a test
+type Env struct {
+	App       *app.Application
+	Clock     *Clock
+	Chains    map[string]*Chain
+	Prices    *Prices
+	Positions *Positions
+
+	tb testing.TB
+}
+
+// chainNames holds the chains the envs run in memory
+var chainNames = []string{"THOR", "BTC", "ETH"}
+
+// defaultPrices holds the prices in USD the oracle of the envs starts with
+var defaultPrices = map[domain.Asset]float64{
+	domain.RuneAsset: 5,
+	"BTC.BTC":        60000,
+	"ETH.ETH":        3000,
+	"ETH.USDC-0XA0B86991C6218B36C1D19D4A2E9EB0CE3606EB48": 1,
+	"ETH.USDT-0XDAC17F958D2EE523A2206206994597C13D831EC7": 1,
+}
+
+// New creates a new Env on a fresh in-memory database which is closed along with the test. The application tells the time
+// by the clock of the env and every chain adapter is an in-memory Chain, the options are applied after them so they can replace any of them.
+// The projections aren't started, the helpers of the env catch them up after each command instead.
+func New(tb testing.TB, opts ...app.Option) *Env {
+	tb.Helper()
+
+	// The clock starts at the time the env is created, as the events are timestamped by the event store with the system time
+	clock := NewClock(time.Now().UTC().Truncate(time.Second))
+	env := &Env{
+		Clock:     clock,
+		Chains:    make(map[string]*Chain, len(chainNames)),
+		Prices:    NewPrices(defaultPrices),
+		Positions: NewPositions(),
+		tb:        tb,
+	}
+
+	// Every env gets a database of its own, so the tests can run in parallel
+	db, err := common.OpenSQLite(fmt.Sprintf("file:%s?mode=memory&cache=shared", uuid.NewString()), 1, 5*time.Second)
+	if err != nil {
+		tb.Fatalf("failed to open database: %v", err)
+	}
+	tb.Cleanup(func() { db.Close() })
+
+	fakes := []app.Option{
+		app.WithClock(clock),
+		app.WithPriceOracle(env.Prices),
+		app.WithPositionSource(env.Positions),
+		app.WithSignatureVerifier(domain.SignatureSchemeECDSASecp256k1, adapters.NewSecp256k1Verifier()),
+	}
+	for _, name := range chainNames {
+		chain := NewChain(name, clock)
+		env.Chains[name] = chain
+		fakes = append(fakes,
+			app.WithLPVerifier(name, chain),
+			app.WithSaversVerifier(name, chain),
+			app.WithDepositVerifier(name, chain),
+			app.WithTxDecoder(name, chain),
+			app.WithWalletDeriver(name, chain),
+			app.WithTxBroadcaster(name, chain),
+			app.WithWithdrawalVerifier(name, chain),
+			app.WithFeeEstimator(name, chain),
+			app.WithTxStatusChecker(name, chain),
+		)
+	}
+
+	env.App, err = app.NewApplication(db, zerolog.New(zerolog.NewTestWriter(tb)), append(fakes, opts...)...)
+	if err != nil {
+		tb.Fatalf("failed to create application: %v", err)
+	}
+
+	return env
+}
+
+// Context returns the context the helpers run the commands with
+func (e *Env) Context() context.Context {
+	return context.Background()
+}
+
+// ChainOf returns the in-memory chain of the asset
+func (e *Env) ChainOf(asset domain.Asset) *Chain {
+	e.tb.Helper()
+
+	info, ok := domain.LookupAsset(asset)
+	if !ok {
+		e.tb.Fatalf("unknown asset %s", asset)
+	}
+	chain, ok := e.Chains[info.Chain]
+	if !ok {
+		e.tb.Fatalf("no in-memory chain for %s", info.Chain)
+	}
+
+	return chain
+}
+
+// Sync catches the projections up with the stored events, so the queries see the changes of the commands run so far
+func (e *Env) Sync() {
+	e.tb.Helper()
+
+	if err := e.App.CatchUpProjections(e.Context()); err != nil {
+		e.tb.Fatalf("failed to catch up projections: %v", err)
+	}
+}
+
+// Must fails the test if the command failed and returns the id of the aggregate it changed otherwise, once the projections
+// caught up with its events. It takes the results of a command handler as is, e.g. env.Must(handler.Handle(ctx, cmd)).
+func (e *Env) Must(id string, err error) string {
+	e.tb.Helper()
+
+	if err != nil {
+		e.tb.Fatalf("command failed: %v", err)
+	}
+	e.Sync()
+
+	return id
+}
+
+// Pair returns the pair as served by the queries
+func (e *Env) Pair(id string) *queries.Pair {
+	e.tb.Helper()
+
+	p, err := e.App.Queries.Pairs.Get(e.Context(), id)
+	if err != nil {
+		e.tb.Fatalf("failed to get pair %s: %v", id, err)
+	}
+
+	return p
+}
@@ -0,0 +1,430 @@
+package testsupport
+
+import (
+	"crypto/ecdsa"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+
+	"github.com/co-defi/api-server/app/commands"
+	"github.com/co-defi/api-server/app/queries"
+	"github.com/co-defi/api-server/common"
+	"github.com/co-defi/api-server/domain"
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+)
+
+// Participant is a participant investing an asset from their address
+type Participant struct {
+	Asset   domain.Asset
+	Address domain.Address
+	// Sign signs the messages the participant acknowledges as their wallet does on the chain of their asset
+	Sign func(message string) ([]byte, error)
+}
+
+// NewParticipant creates a new participant investing the asset, with a key of their own so their signatures verify
+func (e *Env) NewParticipant(asset domain.Asset) Participant {
+	e.tb.Helper()
+
+	chain := e.ChainOf(asset).Name()
+	key, err := ethcrypto.GenerateKey()
+	if err != nil {
+		e.tb.Fatalf("failed to generate key: %v", err)
+	}
+	address, err := common.AddressFromPublicKey(chain, ethcrypto.CompressPubkey(&key.PublicKey))
+	if err != nil {
+		e.tb.Fatalf("failed to derive the address on %s: %v", chain, err)
+	}
+	return Participant{
+		Asset:   asset,
+		Address: address,
+		Sign: func(message string) ([]byte, error) {
+			return common.SignMessage(chain, key, message)
+		},
+	}
+}
+
+// Pair is a pair driven by the env along with its participants, the one who created it first
+type Pair struct {
+	Id           string
+	Participants [2]Participant
+	// PublicKey is the public key the participants generated the wallet of the pair with
+	PublicKey string
+	// WalletKey is the key of the wallet the transactions of the pair are signed with, in place of the participants' key shares
+	WalletKey *ecdsa.PrivateKey
+}
+
+// counterparty returns the participant who doesn't invest the asset
+func (p *Pair) counterparty(asset domain.Asset) Participant {
+	if p.Participants[0].Asset == asset {
+		return p.Participants[1]
+	}
+	return p.Participants[0]
+}
+
+// DefaultPlan is the plan the pairs are driven with when the test doesn't need a specific one, pairing BTC with RUNE
+func DefaultPlan() commands.CreateNewPlan {
+	return commands.CreateNewPlan{
+		Assets:              []domain.Asset{"BTC.BTC", domain.RuneAsset},
+		Security:            "2-2",
+		Strategy:            "equal_share",
+		Quantum:             1000,
+		LossProtection:      0.2,
+		InvestingPeriod:     30,
+		InvestingPeriodUnit: domain.PeriodUnitDay,
+	}
+}
+
+// CreatePlan creates the plan and returns its id
+func (e *Env) CreatePlan(cmd commands.CreateNewPlan) string {
+	e.tb.Helper()
+
+	return e.Must(e.App.Commands.CreateNewPlan.Handle(e.Context(), cmd))
+}
+
+// lifecycle holds the statuses the helpers drive the pairs through, in order
+var lifecycle = []domain.PairStatus{
+	domain.PairStatusWalletConformation,
+	domain.PairStatusAssurance,
+	domain.PairStatusDeposit,
+	domain.PairStatusPreSignWithdrawal,
+	domain.PairStatusLP,
+	domain.PairStatusWithdrawn,
+}
+
+// saversLifecycle holds the statuses the helpers drive the pairs of the savers plans through, in order
+var saversLifecycle = []domain.PairStatus{
+	domain.PairStatusWalletConformation,
+	domain.PairStatusAssurance,
+	domain.PairStatusDeposit,
+	domain.PairStatusPreSignSaversWithdrawal,
+	domain.PairStatusSavers,
+	domain.PairStatusWithdrawn,
+}
+
+// Drive creates a pair of new participants on the plan and drives it until it reaches the status, which must be one of
+// the lifecycle from wallet_conformation to withdrawn. The pair provides liquidity once it reaches lp, or has both assets
+// in their Savers vaults once it reaches savers.
+func (e *Env) Drive(planId string, status domain.PairStatus) *Pair {
+	e.tb.Helper()
+
+	plan, err := e.App.Queries.Plans.Get(e.Context(), planId)
+	if err != nil {
+		e.tb.Fatalf("failed to get plan %s: %v", planId, err)
+	}
+	p := e.MatchPair(planId, e.NewParticipant(plan.Assets[0]), e.NewParticipant(plan.Assets[1]))
+
+	steps := map[domain.PairStatus]func(*Pair){
+		domain.PairStatusAssurance:         e.ConfirmWallet,
+		domain.PairStatusDeposit:           e.Assure,
+		domain.PairStatusPreSignWithdrawal: e.Deposit,
+		domain.PairStatusLP: func(p *Pair) {
+			e.SignWithdrawal(p)
+			e.ProvideLiquidity(p)
+		},
+		domain.PairStatusWithdrawn: func(p *Pair) { e.Withdraw(p) },
+	}
+	statuses := lifecycle
+	if plan.Type == domain.PlanTypeSavers {
+		steps[domain.PairStatusSavers] = func(p *Pair) {
+			e.SignSaversWithdrawals(p)
+			e.DepositIntoSavers(p)
+		}
+		steps[domain.PairStatusWithdrawn] = e.WithdrawFromSavers
+		statuses = saversLifecycle
+	}
+	for _, next := range statuses {
+		if step, ok := steps[next]; ok {
+			step(p)
+		}
+		if next == status {
+			return p
+		}
+	}
+
+	e.tb.Fatalf("pairs can't be driven to %s", status)
+	return nil
+}
+
+// MatchPair creates a pair on the plan for the creator and matches it with the matcher, the pair waits for its wallet to be confirmed
+func (e *Env) MatchPair(planId string, creator, matcher Participant) *Pair {
+	e.tb.Helper()
+
+	id := e.Must(e.App.Commands.CreateOrMatchPair.Handle(e.Context(), commands.CreateOrMatchPair{
+		PlanId:             planId,
+		ParticipantAsset:   creator.Asset,
+		ParticipantAddress: creator.Address,
+	}))
+	matched := e.Must(e.App.Commands.CreateOrMatchPair.Handle(e.Context(), commands.CreateOrMatchPair{
+		PlanId:             planId,
+		ParticipantAsset:   matcher.Asset,
+		ParticipantAddress: matcher.Address,
+	}))
+	if matched != id {
+		e.tb.Fatalf("pair %s was matched instead of %s", matched, id)
+	}
+
+	return &Pair{Id: id, Participants: [2]Participant{creator, matcher}}
+}
+
+// ConfirmWallet confirms the wallet of the pair for both participants with the addresses derived by the chains
+func (e *Env) ConfirmWallet(p *Pair) {
+	e.tb.Helper()
+
+	wallet := e.Pair(p.Id).Wallet
+	key, err := ethcrypto.GenerateKey()
+	if err != nil {
+		e.tb.Fatalf("failed to generate wallet key: %v", err)
+	}
+	p.WalletKey = key
+	p.PublicKey = hex.EncodeToString(ethcrypto.CompressPubkey(&key.PublicKey))
+
+	addresses := make(map[domain.Asset]domain.Address, 2)
+	for _, participant := range p.Participants {
+		address, err := e.ChainOf(participant.Asset).DeriveAddress(p.PublicKey, wallet.HexChainCode)
+		if err != nil {
+			e.tb.Fatalf("failed to derive wallet address: %v", err)
+		}
+		addresses[participant.Asset] = address
+	}
+
+	for _, participant := range p.Participants {
+		e.Must(e.App.Commands.ConfirmPairWallet.Handle(e.Context(), commands.ConfirmPairWallet{
+			PairId:               p.Id,
+			ParticipantAddress:   participant.Address,
+			ParticipantPublicKey: p.PublicKey,
+			WalletAddresses:      addresses,
+		}))
+	}
+}
+
+// Assure sets the assurances refunding each participant, signed by their counterparty, and confirms them for both participants
+func (e *Env) Assure(p *Pair) {
+	e.tb.Helper()
+
+	pair := e.Pair(p.Id)
+	wallet := pair.Wallet
+	for _, participant := range p.Participants {
+		e.Must(e.App.Commands.SetPairAssurances.Handle(e.Context(), commands.SetPairAssurances{
+			PairId:             p.Id,
+			ParticipantAddress: p.counterparty(participant.Asset).Address,
+			Asset:              participant.Asset,
+			Assurances:         e.Assurances(participant, wallet.Addresses[participant.Asset], p.WalletKey, pair.PlanType),
+		}))
+	}
+
+	assurances := e.Pair(p.Id).Assurances
+	for _, participant := range p.Participants {
+		signature, err := participant.Sign(domain.AssurancesAcknowledgement(p.Id, participant.Asset, assurances[participant.Asset]))
+		if err != nil {
+			e.tb.Fatalf("failed to sign assurances acknowledgement: %v", err)
+		}
+		e.Must(e.App.Commands.ConfirmAssurances.Handle(e.Context(), commands.ConfirmAssurances{
+			PairId:             p.Id,
+			ParticipantAddress: participant.Address,
+			Signature:          signature,
+		}))
+	}
+}
+
+// Assurances returns valid assurances refunding the deposit of the participant from the wallet address, signed with the key
+// of the wallet and with the nonces of the default assurance policy of the plan type
+func (e *Env) Assurances(participant Participant, wallet domain.Address, key *ecdsa.PrivateKey, planType domain.PlanType) []domain.SignedTx {
+	e.tb.Helper()
+
+	chainName, _ := domain.ChainOf(participant.Asset)
+	nonces := domain.DefaultAssurancePolicy(planType, chainName).Nonces
+
+	chain := e.ChainOf(participant.Asset)
+	assurances := make([]domain.SignedTx, 0, len(nonces))
+	for _, nonce := range nonces {
+		assurances = append(assurances, chain.SignTx(commands.DecodedTx{
+			Nonce:    nonce,
+			From:     wallet,
+			To:       participant.Address,
+			Asset:    participant.Asset,
+			Amount:   DepositAmount(participant.Asset),
+			GasPrice: chain.GasPrice(),
+		}, key))
+	}
+
+	return assurances
+}
+
+// DepositAmount is the amount the participants deposit, a single unit of the asset in its base units
+func DepositAmount(asset domain.Asset) *big.Int {
+	info, _ := domain.LookupAsset(asset)
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(info.Decimals)), nil)
+}
+
+// Deposit transfers the deposit of both participants to the wallet of the pair and adds them to the pair
+func (e *Env) Deposit(p *Pair) {
+	e.tb.Helper()
+
+	wallet := e.Pair(p.Id).Wallet
+	for _, participant := range p.Participants {
+		info, _ := domain.LookupAsset(participant.Asset)
+		amount := DepositAmount(participant.Asset)
+		hash := e.ChainOf(participant.Asset).Transfer(participant.Address, wallet.Addresses[participant.Asset], participant.Asset, amount)
+		e.Must(e.App.Commands.AddDeposit.Handle(e.Context(), commands.AddDeposit{
+			PairId:             p.Id,
+			ParticipantAddress: participant.Address,
+			Asset:              participant.Asset,
+			TxHash:             hash,
+			Amount:             amount.String(),
+			Decimals:           info.Decimals,
+		}))
+	}
+}
+
+// withdrawalNonce returns the nonce of the withdrawal of the asset pre-signed by the participants, by the default assurance
+// policy of the plan type
+func withdrawalNonce(planType domain.PlanType, asset domain.Asset) int {
+	chain, _ := domain.ChainOf(asset)
+	nonce, _ := domain.DefaultAssurancePolicy(planType, chain).WithdrawalNonce()
+	return nonce
+}
+
+// SignWithdrawal pre-signs the transaction withdrawing the whole position of the pair
+func (e *Env) SignWithdrawal(p *Pair) {
+	e.tb.Helper()
+
+	pair := e.Pair(p.Id)
+	tx := e.Chains["THOR"].SignTx(commands.DecodedTx{
+		Nonce: withdrawalNonce(pair.PlanType, domain.RuneAsset),
+		From:  pair.Wallet.Addresses[domain.RuneAsset],
+		Memo:  fmt.Sprintf("-:%s:10000", poolOf(pair.Assets)),
+	}, p.WalletKey)
+	e.Must(e.App.Commands.SignWithdrawal.Handle(e.Context(), commands.SignWithdrawal{
+		PairId:             p.Id,
+		ParticipantAddress: p.Participants[0].Address,
+		Tx:                 tx,
+	}))
+}
+
+// SignSaversWithdrawals pre-signs the transactions withdrawing both assets of a savers pair from their vaults
+func (e *Env) SignSaversWithdrawals(p *Pair) {
+	e.tb.Helper()
+
+	pair := e.Pair(p.Id)
+	for _, participant := range p.Participants {
+		tx := e.ChainOf(participant.Asset).SignTx(commands.DecodedTx{
+			Nonce: withdrawalNonce(pair.PlanType, participant.Asset),
+			From:  pair.Wallet.Addresses[participant.Asset],
+			Memo:  fmt.Sprintf("-:%s:10000", domain.SaversVault(participant.Asset)),
+		}, p.WalletKey)
+		e.Must(e.App.Commands.SignSaversWithdrawal.Handle(e.Context(), commands.SignSaversWithdrawal{
+			PairId:             p.Id,
+			ParticipantAddress: participant.Address,
+			Asset:              participant.Asset,
+			Tx:                 tx,
+		}))
+	}
+}
+
+// DepositIntoSavers deposits both assets of a savers pair from its wallet into their vaults, starting the investing period
+func (e *Env) DepositIntoSavers(p *Pair) {
+	e.tb.Helper()
+
+	pair := e.Pair(p.Id)
+	for _, participant := range p.Participants {
+		hash := e.ChainOf(participant.Asset).DepositIntoSavers(pair.Wallet.Addresses[participant.Asset], participant.Asset, domain.SaversVault(participant.Asset))
+		e.Must(e.App.Commands.SubmitSavers.Handle(e.Context(), commands.SubmitSavers{
+			PairId:             p.Id,
+			ParticipantAddress: participant.Address,
+			Asset:              participant.Asset,
+			TxHash:             hash,
+		}))
+	}
+}
+
+// WithdrawFromSavers withdraws both assets of a savers pair from their vaults back to its wallet.
+// The participants agree to withdraw early when the deadline isn't reached yet.
+func (e *Env) WithdrawFromSavers(p *Pair) {
+	e.tb.Helper()
+
+	pair := e.Pair(p.Id)
+	e.unlockWithdrawal(p, pair)
+	for _, participant := range p.Participants {
+		hash := e.ChainOf(participant.Asset).WithdrawFromSavers(pair.Wallet.Addresses[participant.Asset], domain.SaversVault(participant.Asset))
+		e.Must(e.App.Commands.SubmitSaversWithdrawal.Handle(e.Context(), commands.SubmitSaversWithdrawal{
+			PairId: p.Id,
+			Asset:  participant.Asset,
+			TxHash: hash,
+		}))
+	}
+}
+
+// ProvideLiquidity adds the liquidity of both assets from the wallet of the pair to its pool, starting the investing period
+func (e *Env) ProvideLiquidity(p *Pair) {
+	e.tb.Helper()
+
+	pair := e.Pair(p.Id)
+	pool := poolOf(pair.Assets)
+	for _, participant := range p.Participants {
+		hash := e.ChainOf(participant.Asset).AddLiquidity(pair.Wallet.Addresses[participant.Asset], participant.Asset, pool)
+		e.Must(e.App.Commands.SubmitLP.Handle(e.Context(), commands.SubmitLP{
+			PairId:             p.Id,
+			ParticipantAddress: participant.Address,
+			Asset:              participant.Asset,
+			TxHash:             hash,
+		}))
+	}
+}
+
+// Withdraw withdraws the liquidity of the pair, paying the deposits back to its wallet, and returns the hash of the withdrawal.
+// The participants agree to withdraw early when the deadline isn't reached yet.
+func (e *Env) Withdraw(p *Pair) domain.TxHash {
+	e.tb.Helper()
+
+	pair := e.Pair(p.Id)
+	e.unlockWithdrawal(p, pair)
+
+	payouts := make(map[domain.Asset]domain.TokenAmount, len(pair.DepositAmounts))
+	for asset, amount := range pair.DepositAmounts {
+		payouts[asset] = amount
+	}
+	hash := e.Chains["THOR"].Withdraw(pair.Wallet.Addresses[domain.RuneAsset], poolOf(pair.Assets), payouts)
+	e.Must(e.App.Commands.SubmitWithdrawal.Handle(e.Context(), commands.SubmitWithdrawal{
+		PairId: p.Id,
+		TxHash: hash,
+	}))
+
+	return hash
+}
+
+// unlockWithdrawal has the participants agree to withdraw early when the deadline of the pair isn't set or reached yet
+func (e *Env) unlockWithdrawal(p *Pair, pair *queries.Pair) {
+	e.tb.Helper()
+
+	if (pair.Deadline != nil && !pair.Deadline.After(e.Clock.Now())) || pair.EarlyWithdrawal != nil {
+		return
+	}
+	e.Must(e.App.Commands.ProposeEarlyWithdrawal.Handle(e.Context(), commands.ProposeEarlyWithdrawal{
+		PairId:             p.Id,
+		ParticipantAddress: p.Participants[0].Address,
+	}))
+	e.Must(e.App.Commands.AcceptEarlyWithdrawal.Handle(e.Context(), commands.AcceptEarlyWithdrawal{
+		PairId:             p.Id,
+		ParticipantAddress: p.Participants[1].Address,
+	}))
+}
+
+// Settle confirms the withdrawal of the pair on chain and reports the results of its participants
+func (e *Env) Settle(p *Pair) *domain.SettlementReport {
+	e.tb.Helper()
+
+	e.Must(e.App.Commands.SettleWithdrawal.Handle(e.Context(), commands.SettleWithdrawal{PairId: p.Id}))
+
+	return e.Pair(p.Id).Settlement
+}
+
+// poolOf returns the pool the assets provide liquidity to, which is named after the non-RUNE asset
+func poolOf(assets []domain.Asset) domain.Asset {
+	for _, a := range assets {
+		if a != domain.RuneAsset {
+			return a
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,33 @@
+package testsupport
+
+import (
+	"testing"
+
+	"github.com/co-defi/api-server/domain"
+)
+
+func TestDrive(t *testing.T) {
+	for _, status := range lifecycle {
+		t.Run(string(status), func(t *testing.T) {
+			env := New(t)
+			p := env.Drive(env.CreatePlan(DefaultPlan()), status)
+
+			if got := env.Pair(p.Id).Status; got != status {
+				t.Fatalf("expected the pair to be driven to %s, got %s", status, got)
+			}
+		})
+	}
+}
+
+func TestSettle(t *testing.T) {
+	env := New(t)
+	p := env.Drive(env.CreatePlan(DefaultPlan()), domain.PairStatusWithdrawn)
+
+	report := env.Settle(p)
+	if report == nil {
+		t.Fatal("expected the withdrawal of the pair to be settled")
+	}
+	if pair := env.Pair(p.Id); pair.Settlement == nil {
+		t.Fatal("expected the pair to report its settlement")
+	}
+}
@@ -0,0 +1,79 @@
+package testsupport
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/co-defi/api-server/app/queries"
+	"github.com/co-defi/api-server/domain"
+)
+
+// Prices is a price oracle serving the prices set by the test
+type Prices struct {
+	mu     sync.Mutex
+	prices map[domain.Asset]float64
+}
+
+// NewPrices creates a new Prices oracle with the given prices in USD
+func NewPrices(prices map[domain.Asset]float64) *Prices {
+	p := &Prices{prices: make(map[domain.Asset]float64, len(prices))}
+	for asset, price := range prices {
+		p.prices[asset] = price
+	}
+
+	return p
+}
+
+// Set sets the price of the asset in USD
+func (p *Prices) Set(asset domain.Asset, price float64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.prices[asset] = price
+}
+
+// PriceUSD implements the queries.PriceOracle interface, the assets without a price fail to be priced
+func (p *Prices) PriceUSD(ctx context.Context, asset domain.Asset) (float64, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	price, ok := p.prices[asset]
+	if !ok {
+		return 0, fmt.Errorf("no price set for %s", asset)
+	}
+
+	return price, nil
+}
+
+// Positions is a position source serving the LP positions set by the test
+type Positions struct {
+	mu        sync.Mutex
+	positions map[positionKey]queries.LPPosition
+}
+
+type positionKey struct {
+	pool    domain.Asset
+	address domain.Address
+}
+
+// NewPositions creates a new empty Positions source
+func NewPositions() *Positions {
+	return &Positions{positions: make(map[positionKey]queries.LPPosition)}
+}
+
+// Set sets the liquidity the address provides to the pool
+func (p *Positions) Set(pool domain.Asset, address domain.Address, position queries.LPPosition) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.positions[positionKey{pool: pool, address: address}] = position
+}
+
+// Position implements the queries.PositionSource interface, the addresses without a position provide no liquidity
+func (p *Positions) Position(ctx context.Context, pool domain.Asset, address domain.Address) (queries.LPPosition, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.positions[positionKey{pool: pool, address: address}], nil
+}